@@ -43,6 +43,7 @@ func SetupAdminRoutes(r *gin.RouterGroup) {
 	r.GET("/scan/unscanned", GetUnscannedArchives)
 	r.GET("/scan/scanned", GetScannedArchives)
 	r.POST("/scan/archive", ScanSpecificArchive)
+	r.POST("/scan/inpx", StartInpxImport)
 	r.DELETE("/scan/reset/:name", ResetArchiveScanStatus)
 
 	// Fix scan routes
@@ -53,6 +54,14 @@ func SetupAdminRoutes(r *gin.RouterGroup) {
 	// Genre management routes
 	r.GET("/genres", GetGenres)
 	r.PUT("/genres/:id", UpdateGenreTitle)
+	r.PUT("/genres/:id/section", MoveGenre)
+	r.GET("/genres/tree", GetGenreTreeAdmin)
+	r.POST("/genres/sections", CreateGenreSection)
+	r.PUT("/genres/sections/:id", UpdateGenreSection)
+	r.DELETE("/genres/sections/:id", DeleteGenreSection)
+	r.GET("/genres/aliases", GetGenreAliases)
+	r.POST("/genres/aliases", SetGenreAlias)
+	r.DELETE("/genres/aliases/:alias", DeleteGenreAlias)
 	r.POST("/genres/generate-titles", GenerateGenreTitles)
 
 	// Setup duplicate management routes
@@ -142,11 +151,18 @@ func GetInvites(c *gin.Context) {
 func ChangeInvite(c *gin.Context) {
 	var inviteRequest models.InviteRequest
 	if err := c.ShouldBindJSON(&inviteRequest); err == nil {
+		var before any
+		if inviteRequest.Action == "update" || inviteRequest.Action == "delete" {
+			if invite, err := database.GetInvite(c.Request.Context(), inviteRequest.Invite.ID); err == nil {
+				before = invite
+			}
+		}
 		err := database.ChangeInvite(inviteRequest)
 		if err != nil {
 			httputil.NewError(c, http.StatusInternalServerError, err)
 			return
 		}
+		recordInviteAudit(c, inviteRequest, before)
 		c.JSON(200, models.Result{
 			Result: "result_ok",
 			Error:  nil,
@@ -175,8 +191,12 @@ func UpdateBook(c *gin.Context) {
 		httputil.NewError(c, http.StatusBadRequest, errors.New("invalid_request_body"))
 		return
 	}
+	if !authorizeBookFlags(c, updateReq.Approved, updateReq.DuplicateHidden) {
+		return
+	}
 
 	// Update the book in database
+	before := auditBook(updateReq.ID)
 	updatedBook, err := database.UpdateBook(updateReq)
 	if err != nil {
 		// Check if book was not found
@@ -187,6 +207,7 @@ func UpdateBook(c *gin.Context) {
 		httputil.NewError(c, http.StatusInternalServerError, err)
 		return
 	}
+	recordAudit(c, models.AuditBookUpdate, "book", updatedBook.ID, before, updatedBook)
 
 	// Return the updated book
 	c.JSON(http.StatusOK, models.Result{
@@ -222,11 +243,15 @@ func UpdateBookByID(c *gin.Context) {
 		httputil.NewError(c, http.StatusBadRequest, errors.New("invalid_request_body"))
 		return
 	}
+	if !authorizeBookFlags(c, updateReq.Approved, updateReq.DuplicateHidden) {
+		return
+	}
 
 	// Set the ID from URL parameter
 	updateReq.ID = bookID
 
 	// Update the book in database
+	before := auditBook(updateReq.ID)
 	updatedBook, err := database.UpdateBook(updateReq)
 	if err != nil {
 		// Check if book was not found
@@ -237,6 +262,7 @@ func UpdateBookByID(c *gin.Context) {
 		httputil.NewError(c, http.StatusInternalServerError, err)
 		return
 	}
+	recordAudit(c, models.AuditBookUpdate, "book", updatedBook.ID, before, updatedBook)
 
 	// Return the updated book
 	c.JSON(http.StatusOK, models.Result{
@@ -405,9 +431,12 @@ func ApproveRescan(c *gin.Context) {
 	rescanService := services.NewRescanService(archivesDir, coversDir, languageDetector)
 
 	var response *models.RescanApprovalResponse
+	before := auditBook(bookID)
+	auditAction := models.AuditBookRescanApprove
 	if approvalReq.Action == "approve" {
 		response, err = rescanService.ApproveRescan(bookID, &approvalReq)
 	} else {
+		auditAction = models.AuditBookRescanReject
 		response, err = rescanService.RejectRescan(bookID)
 	}
 
@@ -419,6 +448,7 @@ func ApproveRescan(c *gin.Context) {
 		}
 		return
 	}
+	recordAudit(c, auditAction, "book", bookID, before, auditBook(bookID))
 
 	c.JSON(http.StatusOK, models.Result{
 		Result: response,
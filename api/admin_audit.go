@@ -0,0 +1,265 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"gopds-api/database"
+	"gopds-api/logging"
+	"gopds-api/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// auditRecordTimeout bounds writing one audit entry. The write is detached
+// from the request, so a client hanging up does not lose the entry.
+const auditRecordTimeout = 5 * time.Second
+
+// AuditLogger is the service-layer view of the admin audit log: append to
+// it, page through it, export it.
+type AuditLogger interface {
+	Record(ctx context.Context, entry *models.AuditEntry) error
+	List(ctx context.Context, f models.AuditFilters) ([]models.AuditEntry, int, error)
+	Export(ctx context.Context, f models.AuditFilters, fn func(*models.AuditEntry) error) error
+}
+
+// auditLog receives the entries admin handlers record. Nil until
+// SetAuditLog, and nothing is recorded while it is.
+var auditLog AuditLogger
+
+// SetAuditLog hands the audit log to the admin handlers.
+func SetAuditLog(l AuditLogger) {
+	auditLog = l
+}
+
+// recordAudit appends an entry for an admin action that has just succeeded:
+// the actor and request come from c, the changes from before and after,
+// either of which may be nil. A failure to record is logged and does not
+// fail the action, which has already happened.
+func recordAudit(c *gin.Context, action, targetType string, targetID any, before, after any) {
+	if auditLog == nil {
+		return
+	}
+	changes, err := models.AuditDiff(before, after)
+	if err != nil {
+		logging.Errorf("Audit log: %s %s %v: diff: %v", action, targetType, targetID, err)
+		changes = nil
+	}
+	entry := &models.AuditEntry{
+		ActorID:    adminUserID(c),
+		ActorName:  c.GetString("username"),
+		Action:     action,
+		TargetType: targetType,
+		Changes:    changes,
+		IP:         c.ClientIP(),
+		UserAgent:  c.Request.UserAgent(),
+		Method:     c.Request.Method,
+		Path:       c.Request.URL.Path,
+		RequestID:  c.GetHeader("X-Request-ID"),
+	}
+	if targetID != nil {
+		entry.TargetID = fmt.Sprint(targetID)
+	}
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(c.Request.Context()), auditRecordTimeout)
+	defer cancel()
+	if err := auditLog.Record(ctx, entry); err != nil {
+		logging.Errorf("Audit log: %s %s %s by %s: %v", action, targetType, entry.TargetID, entry.ActorName, err)
+	}
+}
+
+// auditedUser is a user as the audit log sees it. models.User keeps the
+// password hash out of its JSON, which would hide a password change; here it
+// is in, and the diff redacts it. Collections are left out: they are not
+// loaded alike before and after, and are not what an admin edits here.
+type auditedUser struct {
+	models.User
+	Password    string `json:"password"`
+	Collections any    `json:"collections,omitempty"`
+}
+
+func auditedUserOf(u models.User) auditedUser {
+	return auditedUser{User: u, Password: u.Password}
+}
+
+// auditUser returns the user's current state for the audit log, or nil if
+// nothing is recorded or the user cannot be read.
+func auditUser(c *gin.Context, id int64) any {
+	if auditLog == nil {
+		return nil
+	}
+	u, err := database.GetUserByID(c.Request.Context(), id)
+	if err != nil {
+		return nil
+	}
+	return auditedUserOf(u)
+}
+
+// auditBook returns the book's current state for the audit log, or nil if
+// nothing is recorded or the book cannot be read.
+func auditBook(id int64) any {
+	if auditLog == nil {
+		return nil
+	}
+	book, err := database.GetBookWithRelations(id)
+	if err != nil {
+		return nil
+	}
+	return book
+}
+
+// auditGenre returns the genre's names and place in the tree for the audit
+// log, or nil if nothing is recorded or the genre cannot be read.
+// models.Genre marshals to its display name only, which would hide which of
+// the two titles changed.
+func auditGenre(c *gin.Context, id int64) any {
+	if auditLog == nil {
+		return nil
+	}
+	g, err := database.GetGenre(c.Request.Context(), id)
+	if err != nil {
+		return nil
+	}
+	return gin.H{
+		"genre": g.Genre, "title": g.Title, "title_en": g.TitleEn,
+		"section_id": g.SectionID, "position": g.Position,
+	}
+}
+
+// recordInviteAudit records a change to an invite; before is the invite as
+// it was, for an update or a delete.
+func recordInviteAudit(c *gin.Context, req models.InviteRequest, before any) {
+	switch req.Action {
+	case "create":
+		recordAudit(c, models.AuditInviteCreate, "invite", nil, nil, req.Invite)
+	case "update":
+		recordAudit(c, models.AuditInviteUpdate, "invite", req.Invite.ID, before, req.Invite)
+	case "delete":
+		recordAudit(c, models.AuditInviteDelete, "invite", req.Invite.ID, before, nil)
+	}
+}
+
+// AuditHandler binds AuditLogger to gin routes.
+type AuditHandler struct {
+	Svc AuditLogger
+}
+
+// Register attaches the audit log endpoints to the given group.
+// Caller is expected to have already wrapped the group with admin middleware.
+func (h *AuditHandler) Register(r *gin.RouterGroup) {
+	r.GET("", h.list)
+	r.GET("/export", h.export)
+}
+
+// --- DTOs ---
+
+type auditEntriesResponse struct {
+	Rows     []models.AuditEntry `json:"rows"`
+	Total    int                 `json:"total"`
+	Page     int                 `json:"page"`
+	PageSize int                 `json:"page_size"`
+}
+
+// --- Handlers ---
+
+func (h *AuditHandler) list(c *gin.Context) {
+	f, err := auditFiltersFromQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	f.Page, _ = strconv.Atoi(c.DefaultQuery("page", "1"))
+	f.PageSize, _ = strconv.Atoi(c.DefaultQuery("page_size", "50"))
+	rows, total, err := h.Svc.List(c.Request.Context(), f)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if rows == nil {
+		rows = []models.AuditEntry{}
+	}
+	c.JSON(http.StatusOK, auditEntriesResponse{
+		Rows:     rows,
+		Total:    total,
+		Page:     f.Page,
+		PageSize: f.PageSize,
+	})
+}
+
+// export streams every matching entry as JSON Lines, oldest first.
+func (h *AuditHandler) export(c *gin.Context) {
+	f, err := auditFiltersFromQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Content-Type", "application/x-ndjson")
+	c.Header("Content-Disposition",
+		fmt.Sprintf(`attachment; filename="audit-%s.jsonl"`, time.Now().UTC().Format("20060102-150405")))
+	c.Status(http.StatusOK)
+
+	w := bufio.NewWriter(c.Writer)
+	enc := json.NewEncoder(w)
+	n := 0
+	err = h.Svc.Export(c.Request.Context(), f, func(entry *models.AuditEntry) error {
+		n++
+		return enc.Encode(entry)
+	})
+	if flushErr := w.Flush(); err == nil {
+		err = flushErr
+	}
+	// The status line is gone by now; a broken export can only be logged,
+	// and shows to the client as a truncated file.
+	if err != nil {
+		logging.Errorf("Audit log export stopped after %d entries: %v", n, err)
+	}
+}
+
+// auditFiltersFromQuery reads the audit log filters from the query string.
+// from and to take an RFC 3339 time or a date; a date in to includes that
+// whole day.
+func auditFiltersFromQuery(c *gin.Context) (models.AuditFilters, error) {
+	f := models.AuditFilters{
+		Action:     c.Query("action"),
+		TargetType: c.Query("target_type"),
+		TargetID:   c.Query("target_id"),
+	}
+	if v := c.Query("actor_id"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || id <= 0 {
+			return f, fmt.Errorf("invalid actor_id %q", v)
+		}
+		f.ActorID = id
+	}
+	var err error
+	if f.From, err = parseAuditTime(c.Query("from"), false); err != nil {
+		return f, fmt.Errorf("invalid from: %w", err)
+	}
+	if f.To, err = parseAuditTime(c.Query("to"), true); err != nil {
+		return f, fmt.Errorf("invalid to: %w", err)
+	}
+	return f, nil
+}
+
+func parseAuditTime(v string, endOfDay bool) (*time.Time, error) {
+	if v == "" {
+		return nil, nil
+	}
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return &t, nil
+	}
+	t, err := time.Parse(time.DateOnly, v)
+	if err != nil {
+		return nil, fmt.Errorf("%q is neither an RFC 3339 time nor a date", v)
+	}
+	if endOfDay {
+		t = t.AddDate(0, 0, 1)
+	}
+	return &t, nil
+}
@@ -0,0 +1,219 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"gopds-api/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeAuditLog is an in-memory AuditLogger for httptest.
+type fakeAuditLog struct {
+	recorded []*models.AuditEntry
+	filters  []models.AuditFilters
+	entries  []models.AuditEntry
+}
+
+func (f *fakeAuditLog) Record(ctx context.Context, entry *models.AuditEntry) error {
+	f.recorded = append(f.recorded, entry)
+	return nil
+}
+func (f *fakeAuditLog) List(ctx context.Context, filters models.AuditFilters) ([]models.AuditEntry, int, error) {
+	f.filters = append(f.filters, filters)
+	return f.entries, len(f.entries), nil
+}
+func (f *fakeAuditLog) Export(ctx context.Context, filters models.AuditFilters, fn func(*models.AuditEntry) error) error {
+	f.filters = append(f.filters, filters)
+	for i := range f.entries {
+		if err := fn(&f.entries[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func newAuditTestRouter(svc AuditLogger) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	h := &AuditHandler{Svc: svc}
+	h.Register(r.Group("/api/admin/audit"))
+	return r
+}
+
+func TestAdminAudit_ListFilters(t *testing.T) {
+	svc := &fakeAuditLog{}
+	r := newAuditTestRouter(svc)
+	rec := doJSON(t, r, http.MethodGet,
+		"/api/admin/audit?actor_id=7&action=book.update&target_type=book&target_id=12&from=2026-01-01&to=2026-01-31&page=2", nil)
+
+	require.Equal(t, http.StatusOK, rec.Code, "body=%s", rec.Body.String())
+	require.Len(t, svc.filters, 1)
+	f := svc.filters[0]
+	assert.Equal(t, int64(7), f.ActorID)
+	assert.Equal(t, "book.update", f.Action)
+	assert.Equal(t, "book", f.TargetType)
+	assert.Equal(t, "12", f.TargetID)
+	assert.Equal(t, 2, f.Page)
+	require.NotNil(t, f.From)
+	require.NotNil(t, f.To)
+	assert.True(t, f.From.Equal(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)))
+	// A date in to takes in the whole day.
+	assert.True(t, f.To.Equal(time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)))
+}
+
+func TestAdminAudit_ListRejectsBadFilters(t *testing.T) {
+	r := newAuditTestRouter(&fakeAuditLog{})
+	assert.Equal(t, http.StatusBadRequest, doJSON(t, r, http.MethodGet, "/api/admin/audit?actor_id=x", nil).Code)
+	assert.Equal(t, http.StatusBadRequest, doJSON(t, r, http.MethodGet, "/api/admin/audit?from=yesterday", nil).Code)
+}
+
+func TestAdminAudit_ExportJSONLines(t *testing.T) {
+	svc := &fakeAuditLog{entries: []models.AuditEntry{
+		{ID: 1, Action: models.AuditUserDelete, TargetType: "user", TargetID: "5"},
+		{ID: 2, Action: models.AuditGenreUpdate, TargetType: "genre", TargetID: "9"},
+	}}
+	r := newAuditTestRouter(svc)
+	rec := doJSON(t, r, http.MethodGet, "/api/admin/audit/export?target_type=user", nil)
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/x-ndjson", rec.Header().Get("Content-Type"))
+	assert.Contains(t, rec.Header().Get("Content-Disposition"), ".jsonl")
+	assert.Equal(t, "user", svc.filters[0].TargetType)
+
+	var ids []int64
+	scanner := bufio.NewScanner(rec.Body)
+	for scanner.Scan() {
+		var entry models.AuditEntry
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &entry))
+		ids = append(ids, entry.ID)
+	}
+	assert.Equal(t, []int64{1, 2}, ids)
+}
+
+// An admin action records who did it, to what and from where.
+func TestRecordAudit_BulkEditStart(t *testing.T) {
+	audit := &fakeAuditLog{}
+	SetAuditLog(audit)
+	t.Cleanup(func() { SetAuditLog(nil) })
+
+	r := newBulkEditTestRouter(&fakeBulkEditor{})
+	rec := doJSON(t, r, http.MethodPost, "/api/admin/books/bulk", map[string]any{
+		"selection": map[string]any{"book_ids": []int64{1, 2}},
+		"patch":     map[string]any{"approved": true},
+	})
+	require.Equal(t, http.StatusAccepted, rec.Code)
+
+	require.Len(t, audit.recorded, 1)
+	entry := audit.recorded[0]
+	assert.Equal(t, models.AuditBulkEditStart, entry.Action)
+	assert.Equal(t, "bulk_edit", entry.TargetType)
+	assert.Equal(t, "1", entry.TargetID)
+	require.NotNil(t, entry.ActorID)
+	assert.Equal(t, int64(7), *entry.ActorID)
+	assert.Equal(t, http.MethodPost, entry.Method)
+	assert.Equal(t, "/api/admin/books/bulk", entry.Path)
+	assert.JSONEq(t, `{"approved":true}`, string(entry.Changes["patch"].After))
+}
+
+// auditingFuncs returns the functions and methods of this package that
+// record an audit entry, directly or through a helper, keyed as gin names
+// handlers: "Func" or "(*Type).method".
+func auditingFuncs(t *testing.T) map[string]bool {
+	t.Helper()
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, ".", func(fi os.FileInfo) bool {
+		return !strings.HasSuffix(fi.Name(), "_test.go")
+	}, 0)
+	require.NoError(t, err)
+
+	calls := make(map[string][]string)
+	for _, file := range pkgs["api"].Files {
+		for _, decl := range file.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok || fn.Body == nil {
+				continue
+			}
+			name, recv, recvType := fn.Name.Name, "", ""
+			if fn.Recv != nil && len(fn.Recv.List) == 1 {
+				field := fn.Recv.List[0]
+				if star, ok := field.Type.(*ast.StarExpr); ok {
+					if id, ok := star.X.(*ast.Ident); ok {
+						recvType = id.Name
+					}
+				}
+				if len(field.Names) == 1 {
+					recv = field.Names[0].Name
+				}
+				name = "(*" + recvType + ")." + name
+			}
+			ast.Inspect(fn.Body, func(n ast.Node) bool {
+				call, ok := n.(*ast.CallExpr)
+				if !ok {
+					return true
+				}
+				switch f := call.Fun.(type) {
+				case *ast.Ident:
+					calls[name] = append(calls[name], f.Name)
+				case *ast.SelectorExpr:
+					if x, ok := f.X.(*ast.Ident); ok && recv != "" && x.Name == recv {
+						calls[name] = append(calls[name], "(*"+recvType+")."+f.Sel.Name)
+					}
+				}
+				return true
+			})
+		}
+	}
+
+	auditing := map[string]bool{"recordAudit": true}
+	for changed := true; changed; {
+		changed = false
+		for name, callees := range calls {
+			if auditing[name] {
+				continue
+			}
+			for _, callee := range callees {
+				if auditing[callee] {
+					auditing[name], changed = true, true
+					break
+				}
+			}
+		}
+	}
+	return auditing
+}
+
+// Every admin route that changes something leaves an entry in the audit
+// log. The exceptions change nothing an admin could be asked about.
+func TestRecordAudit_CoversEveryMutatingRoute(t *testing.T) {
+	exempt := map[string]string{
+		"POST /api/admin/users":              "lists users",
+		"POST /api/admin/books/bulk/preview": "counts what a bulk edit would select",
+		"POST /api/admin/books/:id/rescan":   "stages a preview; approving it is audited",
+	}
+	auditing := auditingFuncs(t)
+	for _, route := range allAdminRoutes() {
+		if route.Method == http.MethodGet {
+			continue
+		}
+		if _, ok := exempt[route.Method+" "+route.Path]; ok {
+			continue
+		}
+		handler := strings.TrimSuffix(strings.TrimPrefix(route.Handler, "gopds-api/api."), "-fm")
+		if !auditing[handler] {
+			t.Errorf("%s %s (%s) records no audit entry", route.Method, route.Path, handler)
+		}
+	}
+}
@@ -0,0 +1,229 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"gopds-api/database"
+	"gopds-api/models"
+	"gopds-api/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-pg/pg/v10"
+)
+
+// AuthorsAdmin is the service-layer view of admin author maintenance: aliases,
+// merges and their undo, and merge suggestions.
+type AuthorsAdmin interface {
+	Aliases(ctx context.Context, authorID int64) ([]models.AuthorAlias, error)
+	AddAlias(ctx context.Context, authorID int64, alias, kind string) (*models.AuthorAlias, error)
+	DeleteAlias(ctx context.Context, aliasID int64) (*models.AuthorAlias, error)
+
+	Merge(ctx context.Context, targetID int64, sourceIDs []int64, canonicalName string, mergedBy *int64) (*models.AuthorMerge, error)
+	UndoMerge(ctx context.Context, mergeID int64, undoneBy *int64) (*models.AuthorMerge, error)
+	Merges(ctx context.Context, page, pageSize int) ([]models.AuthorMerge, int, error)
+
+	MergeCandidates(ctx context.Context, authorID int64, limit int) ([]database.AuthorMergeCandidate, error)
+	MergeSuggestions(ctx context.Context, limit int) ([]services.AuthorMergeSuggestion, error)
+}
+
+// AuthorsHandler binds AuthorsAdmin to gin routes.
+type AuthorsHandler struct {
+	Svc AuthorsAdmin
+}
+
+// Register attaches the author maintenance endpoints to the given group.
+// Caller is expected to have already wrapped the group with admin middleware.
+func (h *AuthorsHandler) Register(r *gin.RouterGroup) {
+	r.GET("/:id/aliases", h.aliases)
+	r.POST("/:id/aliases", h.addAlias)
+	r.DELETE("/aliases/:aliasID", h.deleteAlias)
+	r.GET("/:id/merge-candidates", h.mergeCandidates)
+	r.GET("/merge-suggestions", h.mergeSuggestions)
+	r.POST("/merge", h.merge)
+	r.GET("/merges", h.merges)
+	r.POST("/merges/:id/undo", h.undoMerge)
+}
+
+// --- DTOs ---
+
+type authorAliasRequest struct {
+	Alias string `json:"alias" binding:"required,max=128"`
+	Kind  string `json:"kind" binding:"omitempty,oneof=alias pseudonym"`
+}
+
+type authorMergeRequest struct {
+	TargetID      int64   `json:"target_id" binding:"required"`
+	SourceIDs     []int64 `json:"source_ids" binding:"required,min=1,max=100"`
+	CanonicalName string  `json:"canonical_name" binding:"max=128"`
+}
+
+type authorMergesResponse struct {
+	Rows     []models.AuthorMerge `json:"rows"`
+	Total    int                  `json:"total"`
+	Page     int                  `json:"page"`
+	PageSize int                  `json:"page_size"`
+}
+
+// --- Handlers ---
+
+func (h *AuthorsHandler) aliases(c *gin.Context) {
+	id, ok := parseInt64Param(c, "id")
+	if !ok {
+		return
+	}
+	aliases, err := h.Svc.Aliases(c.Request.Context(), id)
+	if err != nil {
+		respondAuthorError(c, err)
+		return
+	}
+	if aliases == nil {
+		aliases = []models.AuthorAlias{}
+	}
+	c.JSON(http.StatusOK, aliases)
+}
+
+func (h *AuthorsHandler) addAlias(c *gin.Context) {
+	id, ok := parseInt64Param(c, "id")
+	if !ok {
+		return
+	}
+	var req authorAliasRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Kind == "" {
+		req.Kind = models.AuthorAliasKindAlias
+	}
+	alias, err := h.Svc.AddAlias(c.Request.Context(), id, req.Alias, req.Kind)
+	if err != nil {
+		respondAuthorError(c, err)
+		return
+	}
+	recordAudit(c, models.AuditAuthorAliasAdd, "author_alias", alias.ID, nil, alias)
+	c.JSON(http.StatusCreated, alias)
+}
+
+func (h *AuthorsHandler) deleteAlias(c *gin.Context) {
+	aliasID, ok := parseInt64Param(c, "aliasID")
+	if !ok {
+		return
+	}
+	alias, err := h.Svc.DeleteAlias(c.Request.Context(), aliasID)
+	if err != nil {
+		respondAuthorError(c, err)
+		return
+	}
+	recordAudit(c, models.AuditAuthorAliasDelete, "author_alias", aliasID, alias, nil)
+	c.Status(http.StatusNoContent)
+}
+
+func (h *AuthorsHandler) mergeCandidates(c *gin.Context) {
+	id, ok := parseInt64Param(c, "id")
+	if !ok {
+		return
+	}
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	candidates, err := h.Svc.MergeCandidates(c.Request.Context(), id, limit)
+	if err != nil {
+		respondAuthorError(c, err)
+		return
+	}
+	if candidates == nil {
+		candidates = []database.AuthorMergeCandidate{}
+	}
+	c.JSON(http.StatusOK, candidates)
+}
+
+func (h *AuthorsHandler) mergeSuggestions(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	suggestions, err := h.Svc.MergeSuggestions(c.Request.Context(), limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if suggestions == nil {
+		suggestions = []services.AuthorMergeSuggestion{}
+	}
+	c.JSON(http.StatusOK, suggestions)
+}
+
+func (h *AuthorsHandler) merge(c *gin.Context) {
+	var req authorMergeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	merge, err := h.Svc.Merge(c.Request.Context(), req.TargetID, req.SourceIDs, req.CanonicalName, adminUserID(c))
+	if err != nil {
+		respondAuthorError(c, err)
+		return
+	}
+	recordAudit(c, models.AuditAuthorMerge, "author", merge.TargetID,
+		gin.H{"full_name": merge.TargetOldName},
+		gin.H{"full_name": merge.TargetNewName, "merge_id": merge.ID, "merged": merge.Sources})
+	c.JSON(http.StatusOK, merge)
+}
+
+func (h *AuthorsHandler) merges(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "50"))
+	rows, total, err := h.Svc.Merges(c.Request.Context(), page, pageSize)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if rows == nil {
+		rows = []models.AuthorMerge{}
+	}
+	c.JSON(http.StatusOK, authorMergesResponse{
+		Rows:     rows,
+		Total:    total,
+		Page:     page,
+		PageSize: pageSize,
+	})
+}
+
+func (h *AuthorsHandler) undoMerge(c *gin.Context) {
+	id, ok := parseInt64Param(c, "id")
+	if !ok {
+		return
+	}
+	merge, err := h.Svc.UndoMerge(c.Request.Context(), id, adminUserID(c))
+	if err != nil {
+		respondAuthorError(c, err)
+		return
+	}
+	recordAudit(c, models.AuditAuthorMergeUndo, "author_merge", merge.ID, nil,
+		gin.H{"target_id": merge.TargetID, "full_name": merge.TargetOldName, "restored": merge.Sources})
+	c.JSON(http.StatusOK, merge)
+}
+
+// adminUserID returns the id of the signed-in user, if the middleware set one.
+func adminUserID(c *gin.Context) *int64 {
+	if v, exists := c.Get("user_id"); exists {
+		if uid, ok := v.(int64); ok {
+			return &uid
+		}
+	}
+	return nil
+}
+
+// respondAuthorError maps author repo errors to HTTP responses: missing rows
+// → 404, a merge that conflicts with the current state → 409, a merge of an
+// author into itself → 400, everything else → 500.
+func respondAuthorError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, database.ErrAuthorNotFound), errors.Is(err, database.ErrMergeNotFound), errors.Is(err, pg.ErrNoRows):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, database.ErrAliasTaken), errors.Is(err, database.ErrMergeUndone), errors.Is(err, database.ErrMergeNotUndoable):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, database.ErrMergeIntoItself):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
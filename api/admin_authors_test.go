@@ -0,0 +1,183 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"gopds-api/database"
+	"gopds-api/models"
+	"gopds-api/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeAuthorsSvc is an in-memory AuthorsAdmin for httptest.
+type fakeAuthorsSvc struct {
+	addAliasCalls []models.AuthorAlias
+	addAliasErr   error
+	deleteCalls   []int64
+	deleteErr     error
+	mergeCalls    []mergeCall
+	mergeErr      error
+	undoCalls     []int64
+	undoErr       error
+}
+
+type mergeCall struct {
+	targetID      int64
+	sourceIDs     []int64
+	canonicalName string
+	mergedBy      *int64
+}
+
+func (f *fakeAuthorsSvc) Aliases(ctx context.Context, authorID int64) ([]models.AuthorAlias, error) {
+	return nil, nil
+}
+func (f *fakeAuthorsSvc) AddAlias(ctx context.Context, authorID int64, alias, kind string) (*models.AuthorAlias, error) {
+	record := models.AuthorAlias{AuthorID: authorID, Alias: alias, Kind: kind}
+	f.addAliasCalls = append(f.addAliasCalls, record)
+	if f.addAliasErr != nil {
+		return nil, f.addAliasErr
+	}
+	return &record, nil
+}
+func (f *fakeAuthorsSvc) DeleteAlias(ctx context.Context, aliasID int64) (*models.AuthorAlias, error) {
+	f.deleteCalls = append(f.deleteCalls, aliasID)
+	if f.deleteErr != nil {
+		return nil, f.deleteErr
+	}
+	return &models.AuthorAlias{ID: aliasID, AuthorID: 12, Alias: "Марк Твен"}, nil
+}
+func (f *fakeAuthorsSvc) Merge(ctx context.Context, targetID int64, sourceIDs []int64, canonicalName string, mergedBy *int64) (*models.AuthorMerge, error) {
+	f.mergeCalls = append(f.mergeCalls, mergeCall{targetID, sourceIDs, canonicalName, mergedBy})
+	if f.mergeErr != nil {
+		return nil, f.mergeErr
+	}
+	return &models.AuthorMerge{ID: 1, TargetID: targetID}, nil
+}
+func (f *fakeAuthorsSvc) UndoMerge(ctx context.Context, mergeID int64, undoneBy *int64) (*models.AuthorMerge, error) {
+	f.undoCalls = append(f.undoCalls, mergeID)
+	if f.undoErr != nil {
+		return nil, f.undoErr
+	}
+	return &models.AuthorMerge{ID: mergeID}, nil
+}
+func (f *fakeAuthorsSvc) Merges(ctx context.Context, page, pageSize int) ([]models.AuthorMerge, int, error) {
+	return nil, 0, nil
+}
+func (f *fakeAuthorsSvc) MergeCandidates(ctx context.Context, authorID int64, limit int) ([]database.AuthorMergeCandidate, error) {
+	return nil, nil
+}
+func (f *fakeAuthorsSvc) MergeSuggestions(ctx context.Context, limit int) ([]services.AuthorMergeSuggestion, error) {
+	return nil, nil
+}
+
+func newAuthorsTestRouter(svc AuthorsAdmin) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) { c.Set("user_id", int64(7)) })
+	h := &AuthorsHandler{Svc: svc}
+	h.Register(r.Group("/api/admin/authors"))
+	return r
+}
+
+func TestAdminAuthors_AddAlias_DefaultsKind(t *testing.T) {
+	svc := &fakeAuthorsSvc{}
+	r := newAuthorsTestRouter(svc)
+	rec := doJSON(t, r, http.MethodPost, "/api/admin/authors/12/aliases", map[string]any{"alias": "Tolstoy, Leo"})
+
+	require.Equal(t, http.StatusCreated, rec.Code, "body=%s", rec.Body.String())
+	require.Len(t, svc.addAliasCalls, 1)
+	assert.Equal(t, int64(12), svc.addAliasCalls[0].AuthorID)
+	assert.Equal(t, models.AuthorAliasKindAlias, svc.addAliasCalls[0].Kind)
+}
+
+func TestAdminAuthors_AddAlias_RejectsUnknownKind(t *testing.T) {
+	svc := &fakeAuthorsSvc{}
+	r := newAuthorsTestRouter(svc)
+	rec := doJSON(t, r, http.MethodPost, "/api/admin/authors/12/aliases", map[string]any{"alias": "X", "kind": "nickname"})
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Empty(t, svc.addAliasCalls)
+}
+
+func TestAdminAuthors_AddAlias_TakenIsConflict(t *testing.T) {
+	svc := &fakeAuthorsSvc{addAliasErr: database.ErrAliasTaken}
+	r := newAuthorsTestRouter(svc)
+	rec := doJSON(t, r, http.MethodPost, "/api/admin/authors/12/aliases", map[string]any{"alias": "Марк Твен", "kind": "pseudonym"})
+
+	assert.Equal(t, http.StatusConflict, rec.Code)
+}
+
+// The static /aliases/:aliasID route must not be read as /:id/aliases.
+func TestAdminAuthors_DeleteAlias(t *testing.T) {
+	svc := &fakeAuthorsSvc{}
+	audit := &fakeAuditLog{}
+	SetAuditLog(audit)
+	t.Cleanup(func() { SetAuditLog(nil) })
+	r := newAuthorsTestRouter(svc)
+	rec := doJSON(t, r, http.MethodDelete, "/api/admin/authors/aliases/5", nil)
+
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	assert.Equal(t, []int64{5}, svc.deleteCalls)
+	require.Len(t, audit.recorded, 1)
+	assert.Equal(t, models.AuditAuthorAliasDelete, audit.recorded[0].Action)
+	assert.Equal(t, "5", audit.recorded[0].TargetID)
+	assert.Contains(t, string(audit.recorded[0].Changes["alias"].Before), "Марк Твен")
+}
+
+func TestAdminAuthors_Merge_RecordsWhoMerged(t *testing.T) {
+	svc := &fakeAuthorsSvc{}
+	r := newAuthorsTestRouter(svc)
+	rec := doJSON(t, r, http.MethodPost, "/api/admin/authors/merge",
+		map[string]any{"target_id": 1, "source_ids": []int64{2, 3}, "canonical_name": "Толстой Лев"})
+
+	require.Equal(t, http.StatusOK, rec.Code, "body=%s", rec.Body.String())
+	require.Len(t, svc.mergeCalls, 1)
+	call := svc.mergeCalls[0]
+	assert.Equal(t, int64(1), call.targetID)
+	assert.Equal(t, []int64{2, 3}, call.sourceIDs)
+	assert.Equal(t, "Толстой Лев", call.canonicalName)
+	require.NotNil(t, call.mergedBy)
+	assert.Equal(t, int64(7), *call.mergedBy)
+}
+
+func TestAdminAuthors_Merge_RejectsNoSources(t *testing.T) {
+	svc := &fakeAuthorsSvc{}
+	r := newAuthorsTestRouter(svc)
+	rec := doJSON(t, r, http.MethodPost, "/api/admin/authors/merge", map[string]any{"target_id": 1, "source_ids": []int64{}})
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Empty(t, svc.mergeCalls)
+}
+
+func TestAdminAuthors_Merge_IntoItselfIsBadRequest(t *testing.T) {
+	svc := &fakeAuthorsSvc{mergeErr: database.ErrMergeIntoItself}
+	r := newAuthorsTestRouter(svc)
+	rec := doJSON(t, r, http.MethodPost, "/api/admin/authors/merge", map[string]any{"target_id": 1, "source_ids": []int64{1}})
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestAdminAuthors_UndoMerge(t *testing.T) {
+	svc := &fakeAuthorsSvc{}
+	r := newAuthorsTestRouter(svc)
+	rec := doJSON(t, r, http.MethodPost, "/api/admin/authors/merges/9/undo", nil)
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	var got models.AuthorMerge
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+	assert.Equal(t, int64(9), got.ID)
+
+	svc.undoErr = database.ErrMergeUndone
+	rec = doJSON(t, r, http.MethodPost, "/api/admin/authors/merges/9/undo", nil)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+
+	svc.undoErr = database.ErrMergeNotFound
+	rec = doJSON(t, r, http.MethodPost, "/api/admin/authors/merges/10/undo", nil)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
@@ -0,0 +1,183 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"gopds-api/database"
+	"gopds-api/models"
+	"gopds-api/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BookBulkEditor is the service-layer view of bulk metadata edits: preview a
+// selection, start an edit in the background, follow it, cancel it, undo it.
+type BookBulkEditor interface {
+	Preview(ctx context.Context, sel models.BookSelection) (*services.BookBulkPreview, error)
+	Start(ctx context.Context, sel models.BookSelection, patch models.BookBulkPatch, createdBy *int64) (*models.BookBulkEdit, error)
+	Get(ctx context.Context, editID int64) (*models.BookBulkEdit, error)
+	List(ctx context.Context, page, pageSize int) ([]models.BookBulkEdit, int, error)
+	Cancel(editID int64) bool
+	Undo(ctx context.Context, editID int64, undoneBy *int64) (*models.BookBulkEdit, error)
+}
+
+// BookBulkEditHandler binds BookBulkEditor to gin routes.
+type BookBulkEditHandler struct {
+	Svc BookBulkEditor
+}
+
+// Register attaches the bulk edit endpoints to the given group.
+// Caller is expected to have already wrapped the group with admin middleware.
+func (h *BookBulkEditHandler) Register(r *gin.RouterGroup) {
+	r.POST("/preview", h.preview)
+	r.POST("", h.start)
+	r.GET("", h.list)
+	r.GET("/:id", h.get)
+	r.POST("/:id/cancel", h.cancel)
+	r.POST("/:id/undo", h.undo)
+}
+
+// --- DTOs ---
+
+type bookBulkEditRequest struct {
+	Selection models.BookSelection `json:"selection"`
+	Patch     models.BookBulkPatch `json:"patch"`
+}
+
+type bookBulkEditsResponse struct {
+	Rows     []models.BookBulkEdit `json:"rows"`
+	Total    int                   `json:"total"`
+	Page     int                   `json:"page"`
+	PageSize int                   `json:"page_size"`
+}
+
+// --- Handlers ---
+
+func (h *BookBulkEditHandler) preview(c *gin.Context) {
+	var sel models.BookSelection
+	if err := c.ShouldBindJSON(&sel); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	preview, err := h.Svc.Preview(c.Request.Context(), sel)
+	if err != nil {
+		respondBulkEditError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, preview)
+}
+
+func (h *BookBulkEditHandler) start(c *gin.Context) {
+	var req bookBulkEditRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !authorizeBookFlags(c, req.Patch.Approved, req.Patch.DuplicateHidden) {
+		return
+	}
+	edit, err := h.Svc.Start(c.Request.Context(), req.Selection, req.Patch, adminUserID(c))
+	if err != nil {
+		respondBulkEditError(c, err)
+		return
+	}
+	recordAudit(c, models.AuditBulkEditStart, "bulk_edit", edit.ID, nil,
+		gin.H{"selection": edit.Selection, "patch": edit.Patch, "total": edit.Total})
+	c.JSON(http.StatusAccepted, edit)
+}
+
+func (h *BookBulkEditHandler) list(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "50"))
+	rows, total, err := h.Svc.List(c.Request.Context(), page, pageSize)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if rows == nil {
+		rows = []models.BookBulkEdit{}
+	}
+	c.JSON(http.StatusOK, bookBulkEditsResponse{
+		Rows:     rows,
+		Total:    total,
+		Page:     page,
+		PageSize: pageSize,
+	})
+}
+
+func (h *BookBulkEditHandler) get(c *gin.Context) {
+	id, ok := parseInt64Param(c, "id")
+	if !ok {
+		return
+	}
+	edit, err := h.Svc.Get(c.Request.Context(), id)
+	if err != nil {
+		respondBulkEditError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, edit)
+}
+
+func (h *BookBulkEditHandler) cancel(c *gin.Context) {
+	id, ok := parseInt64Param(c, "id")
+	if !ok {
+		return
+	}
+	if h.Svc.Cancel(id) {
+		recordAudit(c, models.AuditBulkEditCancel, "bulk_edit", id, nil, gin.H{"status": "cancelling"})
+		c.JSON(http.StatusAccepted, gin.H{"id": id, "status": "cancelling"})
+		return
+	}
+	// Not running here: either there is no such edit or it has ended.
+	if _, err := h.Svc.Get(c.Request.Context(), id); err != nil {
+		respondBulkEditError(c, err)
+		return
+	}
+	c.JSON(http.StatusConflict, gin.H{"error": "bulk edit is not running"})
+}
+
+func (h *BookBulkEditHandler) undo(c *gin.Context) {
+	id, ok := parseInt64Param(c, "id")
+	if !ok {
+		return
+	}
+	// Undoing an edit sets back what it set, and needs what setting it did.
+	edit, err := h.Svc.Get(c.Request.Context(), id)
+	if err != nil {
+		respondBulkEditError(c, err)
+		return
+	}
+	if !authorizeBookFlags(c, edit.Patch.Approved, edit.Patch.DuplicateHidden) {
+		return
+	}
+	edit, err = h.Svc.Undo(c.Request.Context(), id, adminUserID(c))
+	if err != nil {
+		respondBulkEditError(c, err)
+		return
+	}
+	recordAudit(c, models.AuditBulkEditUndo, "bulk_edit", edit.ID, nil, gin.H{"status": edit.Status})
+	c.JSON(http.StatusAccepted, edit)
+}
+
+// respondBulkEditError maps bulk edit errors to HTTP responses: a patch or
+// selection that cannot be applied → 400, an edit, genre, series or author
+// that does not exist → 404, an edit that clashes with another or with its
+// own state → 409, everything else → 500.
+func respondBulkEditError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, models.ErrBulkPatchEmpty), errors.Is(err, models.ErrBulkPatchInvalid),
+		errors.Is(err, services.ErrBulkSelectionInvalid), errors.Is(err, services.ErrBulkSelectionEmpty),
+		errors.Is(err, services.ErrBulkSelectionTooLarge):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, database.ErrBulkEditNotFound), errors.Is(err, database.ErrGenreNotFound),
+		errors.Is(err, database.ErrSeriesNotFound), errors.Is(err, database.ErrAuthorNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, database.ErrBulkEditActive), errors.Is(err, database.ErrBulkEditNotUndoable):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
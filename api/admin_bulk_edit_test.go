@@ -0,0 +1,149 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"gopds-api/database"
+	"gopds-api/models"
+	"gopds-api/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeBulkEditor is an in-memory BookBulkEditor for httptest.
+type fakeBulkEditor struct {
+	startCalls []bookBulkEditRequest
+	startBy    []*int64
+	startErr   error
+	running    map[int64]bool
+	edits      map[int64]*models.BookBulkEdit
+	undoCalls  []int64
+	undoErr    error
+}
+
+func (f *fakeBulkEditor) Preview(ctx context.Context, sel models.BookSelection) (*services.BookBulkPreview, error) {
+	return &services.BookBulkPreview{Total: len(sel.BookIDs), Books: []models.Book{}}, nil
+}
+func (f *fakeBulkEditor) Start(ctx context.Context, sel models.BookSelection, patch models.BookBulkPatch, createdBy *int64) (*models.BookBulkEdit, error) {
+	f.startCalls = append(f.startCalls, bookBulkEditRequest{Selection: sel, Patch: patch})
+	f.startBy = append(f.startBy, createdBy)
+	if f.startErr != nil {
+		return nil, f.startErr
+	}
+	return &models.BookBulkEdit{ID: 1, Status: models.BulkEditRunning, Selection: sel, Patch: patch}, nil
+}
+func (f *fakeBulkEditor) Get(ctx context.Context, editID int64) (*models.BookBulkEdit, error) {
+	if edit, ok := f.edits[editID]; ok {
+		return edit, nil
+	}
+	return nil, database.ErrBulkEditNotFound
+}
+func (f *fakeBulkEditor) List(ctx context.Context, page, pageSize int) ([]models.BookBulkEdit, int, error) {
+	return nil, 0, nil
+}
+func (f *fakeBulkEditor) Cancel(editID int64) bool {
+	return f.running[editID]
+}
+func (f *fakeBulkEditor) Undo(ctx context.Context, editID int64, undoneBy *int64) (*models.BookBulkEdit, error) {
+	f.undoCalls = append(f.undoCalls, editID)
+	if f.undoErr != nil {
+		return nil, f.undoErr
+	}
+	return &models.BookBulkEdit{ID: editID, Status: models.BulkEditUndoing}, nil
+}
+
+func newBulkEditTestRouter(svc BookBulkEditor) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Set("user_id", int64(7))
+		c.Set("is_superuser", true)
+	})
+	h := &BookBulkEditHandler{Svc: svc}
+	h.Register(r.Group("/api/admin/books/bulk"))
+	return r
+}
+
+func TestAdminBulkEdit_Start(t *testing.T) {
+	svc := &fakeBulkEditor{}
+	r := newBulkEditTestRouter(svc)
+	rec := doJSON(t, r, http.MethodPost, "/api/admin/books/bulk", map[string]any{
+		"selection": map[string]any{"filters": map[string]any{"author": 12, "unapproved": true}},
+		"patch": map[string]any{
+			"lang":       "uk",
+			"add_genres": []int64{3},
+			"series":     map[string]any{"name": "Дозоры", "start": 1},
+		},
+	})
+
+	require.Equal(t, http.StatusAccepted, rec.Code, "body=%s", rec.Body.String())
+	require.Len(t, svc.startCalls, 1)
+	call := svc.startCalls[0]
+	require.NotNil(t, call.Selection.Filters)
+	assert.Equal(t, 12, call.Selection.Filters.Author)
+	assert.True(t, call.Selection.Filters.UnApproved)
+	require.NotNil(t, call.Patch.Lang)
+	assert.Equal(t, "uk", *call.Patch.Lang)
+	assert.Equal(t, []int64{3}, call.Patch.AddGenres)
+	require.NotNil(t, call.Patch.Series)
+	assert.Equal(t, int64(1), call.Patch.Series.Start)
+	require.NotNil(t, svc.startBy[0])
+	assert.Equal(t, int64(7), *svc.startBy[0])
+
+	var edit models.BookBulkEdit
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &edit))
+	assert.Equal(t, models.BulkEditRunning, edit.Status)
+}
+
+func TestAdminBulkEdit_Start_ErrorStatuses(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"invalid patch", models.ErrBulkPatchInvalid, http.StatusBadRequest},
+		{"empty selection", services.ErrBulkSelectionEmpty, http.StatusBadRequest},
+		{"unknown genre", database.ErrGenreNotFound, http.StatusNotFound},
+		{"another edit running", database.ErrBulkEditActive, http.StatusConflict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newBulkEditTestRouter(&fakeBulkEditor{startErr: tt.err})
+			rec := doJSON(t, r, http.MethodPost, "/api/admin/books/bulk", map[string]any{
+				"selection": map[string]any{"book_ids": []int64{1}},
+				"patch":     map[string]any{"approved": true},
+			})
+			assert.Equal(t, tt.want, rec.Code)
+		})
+	}
+}
+
+func TestAdminBulkEdit_Cancel(t *testing.T) {
+	svc := &fakeBulkEditor{
+		running: map[int64]bool{3: true},
+		edits:   map[int64]*models.BookBulkEdit{4: {ID: 4, Status: models.BulkEditCompleted}},
+	}
+	r := newBulkEditTestRouter(svc)
+
+	assert.Equal(t, http.StatusAccepted, doJSON(t, r, http.MethodPost, "/api/admin/books/bulk/3/cancel", nil).Code)
+	assert.Equal(t, http.StatusConflict, doJSON(t, r, http.MethodPost, "/api/admin/books/bulk/4/cancel", nil).Code)
+	assert.Equal(t, http.StatusNotFound, doJSON(t, r, http.MethodPost, "/api/admin/books/bulk/5/cancel", nil).Code)
+}
+
+func TestAdminBulkEdit_Undo(t *testing.T) {
+	svc := &fakeBulkEditor{edits: map[int64]*models.BookBulkEdit{9: {ID: 9, Status: models.BulkEditCompleted}}}
+	r := newBulkEditTestRouter(svc)
+	rec := doJSON(t, r, http.MethodPost, "/api/admin/books/bulk/9/undo", nil)
+
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+	assert.Equal(t, []int64{9}, svc.undoCalls)
+
+	svc.undoErr = database.ErrBulkEditNotUndoable
+	rec = doJSON(t, r, http.MethodPost, "/api/admin/books/bulk/9/undo", nil)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+}
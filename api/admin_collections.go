@@ -122,6 +122,8 @@ func (h *CuratedCollectionsHandler) create(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	recordAudit(c, models.AuditCollectionCreate, "collection", id, nil,
+		gin.H{"name": req.Name, "source_url": req.SourceURL, "items": len(req.Items)})
 	c.JSON(http.StatusAccepted, curatedImportResponse{
 		CollectionID: id,
 		Status:       models.ImportStatusImporting,
@@ -220,10 +222,12 @@ func (h *CuratedCollectionsHandler) patch(c *gin.Context) {
 		IsPublic:  req.IsPublic,
 		SourceURL: req.SourceURL,
 	}
+	before := h.auditCollection(c, id)
 	if err := h.Svc.Update(c.Request.Context(), id, patch); err != nil {
 		respondCollectionError(c, err)
 		return
 	}
+	recordAudit(c, models.AuditCollectionUpdate, "collection", id, before, h.auditCollection(c, id))
 	c.JSON(http.StatusOK, gin.H{"ok": true})
 }
 
@@ -232,10 +236,12 @@ func (h *CuratedCollectionsHandler) delete(c *gin.Context) {
 	if !ok {
 		return
 	}
+	before := h.auditCollection(c, id)
 	if err := h.Svc.Delete(c.Request.Context(), id); err != nil {
 		respondCollectionError(c, err)
 		return
 	}
+	recordAudit(c, models.AuditCollectionDelete, "collection", id, before, nil)
 	c.Status(http.StatusNoContent)
 }
 
@@ -259,6 +265,8 @@ func (h *CuratedCollectionsHandler) resolve(c *gin.Context) {
 		respondCollectionError(c, err)
 		return
 	}
+	recordAudit(c, models.AuditCollectionResolve, "collection_item", itemID, nil,
+		gin.H{"collection_id": c.Param("id"), "status": "resolved", "book_id": req.BookID})
 	c.JSON(http.StatusOK, gin.H{"ok": true})
 }
 
@@ -271,6 +279,8 @@ func (h *CuratedCollectionsHandler) ignore(c *gin.Context) {
 		respondCollectionError(c, err)
 		return
 	}
+	recordAudit(c, models.AuditCollectionIgnore, "collection_item", itemID, nil,
+		gin.H{"collection_id": c.Param("id"), "status": "ignored"})
 	c.JSON(http.StatusOK, gin.H{"ok": true})
 }
 
@@ -290,6 +300,7 @@ func (h *CuratedCollectionsHandler) autoResolve(c *gin.Context) {
 		respondCollectionError(c, err)
 		return
 	}
+	recordAudit(c, models.AuditCollectionAutoResolve, "collection", id, nil, gin.H{"resolved": resolved})
 	c.JSON(http.StatusOK, gin.H{"resolved": resolved})
 }
 
@@ -327,6 +338,7 @@ func (h *CuratedCollectionsHandler) llmResolve(c *gin.Context) {
 			_ = err
 		}
 	}(id, decidedBy)
+	recordAudit(c, models.AuditCollectionLLMResolve, "collection", id, nil, nil)
 	c.JSON(http.StatusAccepted, gin.H{"status": "started"})
 }
 
@@ -356,9 +368,23 @@ func (h *CuratedCollectionsHandler) llmSearchNotFound(c *gin.Context) {
 			_ = err
 		}
 	}(id, decidedBy)
+	recordAudit(c, models.AuditCollectionLLMSearch, "collection", id, nil, nil)
 	c.JSON(http.StatusAccepted, gin.H{"status": "started"})
 }
 
+// auditCollection returns what an admin edits of a collection for the audit
+// log, or nil if nothing is recorded or the collection cannot be read.
+func (h *CuratedCollectionsHandler) auditCollection(c *gin.Context, id int64) any {
+	if auditLog == nil {
+		return nil
+	}
+	col, err := h.Svc.Get(c.Request.Context(), id)
+	if err != nil || col == nil {
+		return nil
+	}
+	return gin.H{"name": col.Name, "is_public": col.IsPublic, "source_url": col.SourceURL}
+}
+
 // parseInt64Param reads an int64 path parameter and writes 400 if it is malformed.
 func parseInt64Param(c *gin.Context, name string) (int64, bool) {
 	raw := c.Param(name)
@@ -332,6 +332,25 @@ func TestAdminCollections_Delete_Success(t *testing.T) {
 	assert.Equal(t, int64(7), svc.deleteCalls[0])
 }
 
+// A deleted collection is kept in the audit log as it was.
+func TestAdminCollections_Delete_RecordsTheCollection(t *testing.T) {
+	audit := &fakeAuditLog{}
+	SetAuditLog(audit)
+	t.Cleanup(func() { SetAuditLog(nil) })
+
+	svc := &fakeAdminSvc{getResp: &models.BookCollection{ID: 7, Name: "Хорошие книги", IsPublic: true}}
+	rec := doJSON(t, newAdminTestRouter(svc), http.MethodDelete, "/api/admin/collections/7", nil)
+	require.Equal(t, http.StatusNoContent, rec.Code)
+
+	require.Len(t, audit.recorded, 1)
+	entry := audit.recorded[0]
+	assert.Equal(t, models.AuditCollectionDelete, entry.Action)
+	assert.Equal(t, "collection", entry.TargetType)
+	assert.Equal(t, "7", entry.TargetID)
+	assert.JSONEq(t, `"Хорошие книги"`, string(entry.Changes["name"].Before))
+	assert.JSONEq(t, `null`, string(entry.Changes["name"].After))
+}
+
 func TestAdminCollections_Delete_NotFound(t *testing.T) {
 	svc := &fakeAdminSvc{deleteErr: pg.ErrNoRows}
 	r := newAdminTestRouter(svc)
@@ -0,0 +1,76 @@
+package api
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+
+	"gopds-api/models"
+	"gopds-api/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ConversionCacheAdmin is the slice of the conversion cache the admin
+// endpoints use. An interface so the handler tests need no files on disk.
+type ConversionCacheAdmin interface {
+	Stats() services.ConversionCacheStats
+	Entries() []services.ConversionEntry
+	Purge(md5 string) int
+}
+
+// ConversionCacheHandler binds ConversionCacheAdmin to gin routes.
+type ConversionCacheHandler struct {
+	Cache ConversionCacheAdmin
+}
+
+// Register attaches the conversion-cache admin endpoints to the given group.
+// Caller is expected to have already wrapped the group with admin middleware.
+func (h *ConversionCacheHandler) Register(r *gin.RouterGroup) {
+	r.GET("", h.inspect)
+	r.DELETE("", h.purgeAll)
+	r.DELETE("/:md5", h.purgeBook)
+}
+
+type conversionCacheResponse struct {
+	Stats   services.ConversionCacheStats `json:"stats"`
+	Entries []services.ConversionEntry    `json:"entries"`
+}
+
+type conversionPurgeResponse struct {
+	Removed int `json:"removed"`
+}
+
+// adminMD5Pattern is what a book fingerprint in the URL must look like. An
+// empty one would purge everything, which has its own endpoint.
+var adminMD5Pattern = regexp.MustCompile(`^[0-9a-fA-F]{32}$`)
+
+// inspect reports the cache totals and every cached conversion, most
+// recently used first.
+func (h *ConversionCacheHandler) inspect(c *gin.Context) {
+	c.JSON(http.StatusOK, conversionCacheResponse{
+		Stats:   h.Cache.Stats(),
+		Entries: h.Cache.Entries(),
+	})
+}
+
+// purgeAll empties the cache.
+func (h *ConversionCacheHandler) purgeAll(c *gin.Context) {
+	removed := h.Cache.Purge("")
+	recordAudit(c, models.AuditConversionsPurge, "conversions", nil, nil, gin.H{"removed": removed})
+	c.JSON(http.StatusOK, conversionPurgeResponse{Removed: removed})
+}
+
+// purgeBook removes every cached format of one book, e.g. after its source
+// file was replaced.
+func (h *ConversionCacheHandler) purgeBook(c *gin.Context) {
+	md5 := c.Param("md5")
+	if !adminMD5Pattern.MatchString(md5) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_md5"})
+		return
+	}
+	md5 = strings.ToLower(md5)
+	removed := h.Cache.Purge(md5)
+	recordAudit(c, models.AuditConversionsPurge, "book_file", md5, nil, gin.H{"removed": removed})
+	c.JSON(http.StatusOK, conversionPurgeResponse{Removed: removed})
+}
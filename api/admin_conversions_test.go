@@ -0,0 +1,100 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"gopds-api/models"
+	"gopds-api/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeConversionCache is an in-memory ConversionCacheAdmin for httptest.
+type fakeConversionCache struct {
+	entries []services.ConversionEntry
+	purged  []string
+}
+
+func (f *fakeConversionCache) Stats() services.ConversionCacheStats {
+	return services.ConversionCacheStats{Entries: len(f.entries)}
+}
+
+func (f *fakeConversionCache) Entries() []services.ConversionEntry { return f.entries }
+
+func (f *fakeConversionCache) Purge(md5 string) int {
+	f.purged = append(f.purged, md5)
+	return 1
+}
+
+func newConversionRouter(cache *fakeConversionCache) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	h := &ConversionCacheHandler{Cache: cache}
+	h.Register(r.Group("/api/admin/conversions"))
+	return r
+}
+
+func TestConversionCacheInspect(t *testing.T) {
+	cache := &fakeConversionCache{entries: []services.ConversionEntry{{MD5: "abc", Format: "epub", Bytes: 10}}}
+	w := httptest.NewRecorder()
+	newConversionRouter(cache).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/admin/conversions", nil))
+
+	require.Equal(t, http.StatusOK, w.Code)
+	var body conversionCacheResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, 1, body.Stats.Entries)
+	require.Len(t, body.Entries, 1)
+	assert.Equal(t, "epub", body.Entries[0].Format)
+}
+
+func TestConversionCachePurgeBookNormalizesTheMD5(t *testing.T) {
+	cache := &fakeConversionCache{}
+	w := httptest.NewRecorder()
+	newConversionRouter(cache).ServeHTTP(w,
+		httptest.NewRequest(http.MethodDelete, "/api/admin/conversions/0123456789ABCDEF0123456789ABCDEF", nil))
+
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, []string{"0123456789abcdef0123456789abcdef"}, cache.purged)
+}
+
+// An empty md5 means "everything" to the cache, so a malformed one must not
+// be allowed to reach it.
+func TestConversionCachePurgeBookRejectsMalformedMD5(t *testing.T) {
+	cache := &fakeConversionCache{}
+	w := httptest.NewRecorder()
+	newConversionRouter(cache).ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/api/admin/conversions/nope", nil))
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Empty(t, cache.purged)
+}
+
+func TestConversionCachePurgeAll(t *testing.T) {
+	cache := &fakeConversionCache{}
+	w := httptest.NewRecorder()
+	newConversionRouter(cache).ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/api/admin/conversions", nil))
+
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, []string{""}, cache.purged)
+}
+
+func TestConversionCachePurgeIsAudited(t *testing.T) {
+	audit := &fakeAuditLog{}
+	SetAuditLog(audit)
+	t.Cleanup(func() { SetAuditLog(nil) })
+
+	w := httptest.NewRecorder()
+	newConversionRouter(&fakeConversionCache{}).ServeHTTP(w,
+		httptest.NewRequest(http.MethodDelete, "/api/admin/conversions/0123456789ABCDEF0123456789ABCDEF", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	require.Len(t, audit.recorded, 1)
+	entry := audit.recorded[0]
+	assert.Equal(t, models.AuditConversionsPurge, entry.Action)
+	assert.Equal(t, "0123456789abcdef0123456789abcdef", entry.TargetID)
+	assert.JSONEq(t, `1`, string(entry.Changes["removed"].After))
+}
@@ -84,6 +84,7 @@ func UploadBookCover(c *gin.Context) {
 		httputil.NewError(c, http.StatusNotFound, errors.New("book_not_found"))
 		return
 	}
+	before := *book
 
 	coversDir := viper.GetString("app.posters_path")
 	if coversDir == "" {
@@ -101,8 +102,12 @@ func UploadBookCover(c *gin.Context) {
 		return
 	}
 
+	// jpegData was just encoded from a decoded image, so it decodes.
+	placeholder, _ := posters.PlaceholderOf(jpegData)
 	_, err = db.Model(&models.Book{}).
 		Set("cover = ?", true).
+		Set("cover_blurhash = ?", placeholder.Blurhash).
+		Set("cover_color = ?", placeholder.Color).
 		Where("id = ?", bookID).
 		Update()
 	if err != nil {
@@ -115,6 +120,7 @@ func UploadBookCover(c *gin.Context) {
 		httputil.NewError(c, http.StatusInternalServerError, err)
 		return
 	}
+	recordAudit(c, models.AuditBookCover, "book", bookID, before, updated)
 
 	c.JSON(http.StatusOK, models.Result{
 		Result: updated,
@@ -0,0 +1,93 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"gopds-api/httputil"
+	"gopds-api/middlewares"
+	"gopds-api/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AdminPermissionRules assigns the admin routes to permissions, for
+// middlewares.AdminMiddleware. The first rule whose path prefixes a route's
+// pattern wins, so narrower rules come first. A route no rule covers — the
+// conversion cache, and whatever is added without a rule here — is for
+// superusers only.
+var AdminPermissionRules = []middlewares.PermissionRule{
+	// Accounts, and who may do what.
+	{Path: "/api/admin/user", Permission: models.PermUsersManage}, // /user and /users
+	{Path: "/api/admin/invite", Permission: models.PermUsersManage},
+	{Path: "/api/admin/roles", Permission: models.PermUsersManage},
+	{Path: "/api/admin/audit", Permission: models.PermAuditRead},
+
+	// What removes or hides library content, or throws away work.
+	{Method: http.MethodDelete, Path: "/api/admin/scan/reset/", Permission: models.PermLibraryDelete},
+	{Method: http.MethodDelete, Path: "/api/admin/collections/", Permission: models.PermLibraryDelete},
+	{Method: http.MethodDelete, Path: "/api/admin/genres/", Permission: models.PermLibraryDelete},
+	{Method: http.MethodPost, Path: "/api/admin/duplicates/hide", Permission: models.PermLibraryDelete},
+	// Resolving a group hides every book in it but the winner.
+	{Method: http.MethodPost, Path: "/api/admin/duplicates/fuzzy/:id/resolve", Permission: models.PermLibraryDelete},
+
+	{Path: "/api/admin/scan", Permission: models.PermLibraryScan},
+	{Path: "/api/admin/duplicates/scan", Permission: models.PermLibraryScan},
+
+	{Path: "/api/admin/books/:id/rescan/approve", Permission: models.PermBooksApprove},
+	{Path: "/api/admin/duplicates", Permission: models.PermBooksApprove},
+	{Path: "/api/admin/reviews", Permission: models.PermBooksApprove},
+	{Path: "/api/admin/requests", Permission: models.PermBooksApprove},
+	{Path: "/api/admin/uploads", Permission: models.PermBooksApprove},
+
+	{Path: "/api/admin/books", Permission: models.PermBooksEdit},
+	{Path: "/api/admin/update-book", Permission: models.PermBooksEdit},
+	{Path: "/api/admin/authors", Permission: models.PermBooksEdit},
+	{Path: "/api/admin/series", Permission: models.PermBooksEdit},
+	{Path: "/api/admin/genres", Permission: models.PermBooksEdit},
+
+	{Path: "/api/admin/collections", Permission: models.PermCollectionsManage},
+}
+
+// authorizeBookFlags checks what a book edit needs beyond models.PermBooksEdit,
+// the permission of the edit routes: setting approved publishes or withdraws
+// a book, which is approving it, and setting duplicate_hidden takes a book
+// from readers or gives it back, which is deleting it. It reports false,
+// having answered 403, when the user may not.
+func authorizeBookFlags(c *gin.Context, approved, duplicateHidden *bool) bool {
+	if (approved == nil || middlewares.HasAdminPermission(c, models.PermBooksApprove)) &&
+		(duplicateHidden == nil || middlewares.HasAdminPermission(c, models.PermLibraryDelete)) {
+		return true
+	}
+	httputil.NewError(c, http.StatusForbidden, errors.New("permission_denied"))
+	return false
+}
+
+// authorizeUserChange checks what an account change needs beyond
+// models.PermUsersManage: a superuser's account, and the superuser flag
+// itself, are for superusers alone. Without it a holder of the permission
+// could make themselves superuser, or edit or delete one. target is the
+// account as it stands, nil when it is not known yet; makeSuperUser is the
+// flag the change asks for. It reports false, having answered 403, when the
+// user may not.
+func authorizeUserChange(c *gin.Context, target *models.User, makeSuperUser bool) bool {
+	if c.GetBool("is_superuser") || (!makeSuperUser && (target == nil || !target.IsSuperUser)) {
+		return true
+	}
+	httputil.NewError(c, http.StatusForbidden, errors.New("superuser_required"))
+	return false
+}
+
+// authorizeGrant checks that the user holds every permission they hand on,
+// in a role they define or give to someone: otherwise a holder of
+// models.PermUsersManage could grant themselves all the others. It reports
+// false, having answered 403, when the user may not.
+func authorizeGrant(c *gin.Context, perms []string) bool {
+	for _, perm := range perms {
+		if !middlewares.HasAdminPermission(c, perm) {
+			httputil.NewError(c, http.StatusForbidden, errors.New("permission_denied"))
+			return false
+		}
+	}
+	return true
+}
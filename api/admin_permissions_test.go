@@ -0,0 +1,238 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"gopds-api/middlewares"
+	"gopds-api/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// allAdminRoutes registers every admin route the way cmd/gopds does, with
+// no services behind them: only the route table is of interest.
+func allAdminRoutes() gin.RoutesInfo {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	group := r.Group("/api/admin")
+	(&AuditHandler{}).Register(group.Group("/audit"))
+	(&RolesHandler{}).Register(group.Group("/roles"))
+	SetupAdminRoutes(group)
+	(&CuratedCollectionsHandler{}).Register(group.Group("/collections"))
+	(&AuthorsHandler{}).Register(group.Group("/authors"))
+	(&ReviewsAdminHandler{}).Register(group.Group("/reviews"))
+	(&BookRequestsAdminHandler{}).Register(group.Group("/requests"))
+	(&BookUploadsAdminHandler{}).Register(group.Group("/uploads"))
+	(&BookBulkEditHandler{}).Register(group.Group("/books/bulk"))
+	(&TwoFactorHandler{}).RegisterAdmin(group)
+	(&ConversionCacheHandler{}).Register(group.Group("/conversions"))
+	return r.Routes()
+}
+
+// Every admin route but the conversion cache belongs to a permission; a
+// route added without a rule would be for superusers only, which is safe
+// but should be a decision.
+func TestAdminPermissionRules_CoverEveryRoute(t *testing.T) {
+	for _, route := range allAdminRoutes() {
+		if strings.HasPrefix(route.Path, "/api/admin/conversions") {
+			continue
+		}
+		if _, ok := middlewares.RequiredPermission(AdminPermissionRules, route.Method, route.Path); !ok {
+			t.Errorf("%s %s has no permission rule", route.Method, route.Path)
+		}
+	}
+}
+
+func TestAdminPermissionRules(t *testing.T) {
+	tests := []struct {
+		method, path string
+		want         string
+	}{
+		{http.MethodPost, "/api/admin/users", models.PermUsersManage},
+		{http.MethodDelete, "/api/admin/user/:id", models.PermUsersManage},
+		{http.MethodGet, "/api/admin/invites", models.PermUsersManage},
+		{http.MethodPut, "/api/admin/roles/users/:userID", models.PermUsersManage},
+		{http.MethodDelete, "/api/admin/users/:id/2fa", models.PermUsersManage},
+		{http.MethodGet, "/api/admin/audit/export", models.PermAuditRead},
+		{http.MethodPut, "/api/admin/books/:id", models.PermBooksEdit},
+		{http.MethodPost, "/api/admin/books/bulk", models.PermBooksEdit},
+		{http.MethodPost, "/api/admin/books/:id/rescan", models.PermBooksEdit},
+		{http.MethodPost, "/api/admin/books/:id/rescan/approve", models.PermBooksApprove},
+		{http.MethodPost, "/api/admin/duplicates/fuzzy/:id/resolve", models.PermLibraryDelete},
+		{http.MethodPost, "/api/admin/duplicates/fuzzy/:id/dismiss", models.PermBooksApprove},
+		{http.MethodPost, "/api/admin/reviews/:id/moderate", models.PermBooksApprove},
+		{http.MethodPost, "/api/admin/requests/:id/dismiss", models.PermBooksApprove},
+		{http.MethodPost, "/api/admin/uploads/:id/moderate", models.PermBooksApprove},
+		{http.MethodPost, "/api/admin/duplicates/hide", models.PermLibraryDelete},
+		{http.MethodPost, "/api/admin/duplicates/scan", models.PermLibraryScan},
+		{http.MethodPost, "/api/admin/scan/inpx", models.PermLibraryScan},
+		{http.MethodDelete, "/api/admin/scan/reset/:name", models.PermLibraryDelete},
+		{http.MethodPost, "/api/admin/collections/:id/items/:itemID/resolve", models.PermCollectionsManage},
+		{http.MethodDelete, "/api/admin/collections/:id", models.PermLibraryDelete},
+		{http.MethodPut, "/api/admin/genres/:id", models.PermBooksEdit},
+		{http.MethodDelete, "/api/admin/genres/sections/:id", models.PermLibraryDelete},
+	}
+	for _, tt := range tests {
+		got, ok := middlewares.RequiredPermission(AdminPermissionRules, tt.method, tt.path)
+		assert.True(t, ok, "%s %s", tt.method, tt.path)
+		assert.Equal(t, tt.want, got, "%s %s", tt.method, tt.path)
+	}
+
+	_, ok := middlewares.RequiredPermission(AdminPermissionRules, http.MethodDelete, "/api/admin/conversions")
+	assert.False(t, ok, "the conversion cache is for superusers only")
+}
+
+// A librarian edits books but does not approve or hide them; the edit
+// routes are theirs, those two fields of an edit are not. The refusal comes
+// before the database is asked anything.
+func TestBookEdits_FlagsNeedTheirOwnPermissions(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	approved := true
+	newRouter := func(set func(c *gin.Context)) (*gin.Engine, *fakeBulkEditor) {
+		r := gin.New()
+		r.Use(func(c *gin.Context) {
+			c.Set("user_id", int64(7))
+			set(c)
+		})
+		r.POST("/api/admin/update-book", UpdateBook)
+		r.PUT("/api/admin/books/:id", UpdateBookByID)
+		bulk := &fakeBulkEditor{edits: map[int64]*models.BookBulkEdit{
+			3: {ID: 3, Status: models.BulkEditCompleted, Patch: models.BookBulkPatch{Approved: &approved}},
+		}}
+		(&BookBulkEditHandler{Svc: bulk}).Register(r.Group("/api/admin/books/bulk"))
+		return r, bulk
+	}
+	bulkApprove := map[string]any{
+		"selection": map[string]any{"book_ids": []int64{1}},
+		"patch":     map[string]any{"approved": true},
+	}
+	bulkHide := map[string]any{
+		"selection": map[string]any{"book_ids": []int64{1}},
+		"patch":     map[string]any{"duplicate_hidden": true},
+	}
+
+	librarian, bulk := newRouter(func(c *gin.Context) {
+		c.Set("permissions", []string{models.PermBooksEdit, models.PermLibraryScan})
+	})
+	for _, tc := range []struct {
+		name, method, path string
+		body               any
+	}{
+		{"approving a book", http.MethodPost, "/api/admin/update-book", map[string]any{"id": 1, "approved": true}},
+		{"approving a book by id", http.MethodPut, "/api/admin/books/1", map[string]any{"id": 1, "approved": false}},
+		{"hiding a book", http.MethodPost, "/api/admin/update-book", map[string]any{"id": 1, "duplicate_hidden": true}},
+		{"approving books in bulk", http.MethodPost, "/api/admin/books/bulk", bulkApprove},
+		{"hiding books in bulk", http.MethodPost, "/api/admin/books/bulk", bulkHide},
+		{"undoing a bulk approval", http.MethodPost, "/api/admin/books/bulk/3/undo", nil},
+	} {
+		rec := doJSON(t, librarian, tc.method, tc.path, tc.body)
+		assert.Equal(t, http.StatusForbidden, rec.Code, tc.name)
+	}
+	assert.Empty(t, bulk.startCalls)
+	assert.Empty(t, bulk.undoCalls)
+
+	rec := doJSON(t, librarian, http.MethodPost, "/api/admin/books/bulk", map[string]any{
+		"selection": map[string]any{"book_ids": []int64{1}},
+		"patch":     map[string]any{"lang": "uk"},
+	})
+	assert.Equal(t, http.StatusAccepted, rec.Code, "the rest of an edit is the librarian's")
+
+	approver, bulk := newRouter(func(c *gin.Context) {
+		c.Set("permissions", []string{models.PermBooksEdit, models.PermBooksApprove})
+	})
+	rec = doJSON(t, approver, http.MethodPost, "/api/admin/books/bulk", bulkApprove)
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+	rec = doJSON(t, approver, http.MethodPost, "/api/admin/books/bulk", bulkHide)
+	assert.Equal(t, http.StatusForbidden, rec.Code, "approving is not hiding")
+	require.Len(t, bulk.startCalls, 1)
+
+	editToken, bulk := newRouter(func(c *gin.Context) {
+		c.Set("permissions", []string{models.PermBooksEdit, models.PermBooksApprove})
+		c.Set("api_token_id", int64(5))
+		c.Set("api_token_scopes", []string{models.AdminScope(models.PermBooksEdit)})
+	})
+	rec = doJSON(t, editToken, http.MethodPost, "/api/admin/books/bulk", bulkApprove)
+	assert.Equal(t, http.StatusForbidden, rec.Code, "a token approves only with the approve scope")
+	assert.Empty(t, bulk.startCalls)
+}
+
+// Resolving a near-duplicate group hides the books that lose, so a
+// moderator who approves books but may not delete them is refused before
+// the handler runs; dismissing a group hides nothing and stays theirs.
+func TestDuplicateResolve_NeedsLibraryDelete(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	newRouter := func(perms ...string) *gin.Engine {
+		r := gin.New()
+		r.Use(func(c *gin.Context) {
+			c.Set("user_id", int64(7))
+			c.Set("permissions", perms)
+			c.Set("api_token_id", int64(5))
+			scopes := make([]string, 0, len(perms))
+			for _, perm := range perms {
+				scopes = append(scopes, models.AdminScope(perm))
+			}
+			c.Set("api_token_scopes", scopes)
+		})
+		admin := r.Group("/api/admin", middlewares.AdminMiddleware(AdminPermissionRules))
+		ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+		admin.POST("/duplicates/fuzzy/:id/resolve", ok)
+		admin.POST("/duplicates/fuzzy/:id/dismiss", ok)
+		return r
+	}
+
+	moderator := newRouter(models.PermBooksApprove)
+	rec := doJSON(t, moderator, http.MethodPost, "/api/admin/duplicates/fuzzy/3/resolve", nil)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	rec = doJSON(t, moderator, http.MethodPost, "/api/admin/duplicates/fuzzy/3/dismiss", nil)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	curator := newRouter(models.PermBooksApprove, models.PermLibraryDelete)
+	rec = doJSON(t, curator, http.MethodPost, "/api/admin/duplicates/fuzzy/3/resolve", nil)
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+// A holder of users.manage manages accounts but not superusers: asking for
+// the flag is refused before any account is read, and a superuser's account
+// is out of reach however the change is put.
+func TestUserChanges_SuperusersAreForSuperusers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Set("user_id", int64(7))
+		c.Set("permissions", []string{models.PermUsersManage})
+	})
+	r.POST("/api/admin/user", ActionUser)
+
+	rec := doJSON(t, r, http.MethodPost, "/api/admin/user", map[string]any{
+		"action": "update",
+		"user":   map[string]any{"id": 7, "login": "staff", "is_superuser": true},
+	})
+	assert.Equal(t, http.StatusForbidden, rec.Code, "body=%s", rec.Body.String())
+
+	root := &models.User{ID: 1, IsSuperUser: true}
+	reader := &models.User{ID: 2}
+	for _, tc := range []struct {
+		name          string
+		superuser     bool
+		target        *models.User
+		makeSuperUser bool
+		want          bool
+	}{
+		{"staff edits a reader", false, reader, false, true},
+		{"staff promotes a reader", false, reader, true, false},
+		{"staff edits a superuser", false, root, true, false},
+		{"staff demotes a superuser", false, root, false, false},
+		{"a superuser promotes a reader", true, reader, true, true},
+		{"a superuser demotes a superuser", true, root, false, true},
+	} {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Set("is_superuser", tc.superuser)
+		c.Set("permissions", []string{models.PermUsersManage})
+		assert.Equal(t, tc.want, authorizeUserChange(c, tc.target, tc.makeSuperUser), tc.name)
+	}
+}
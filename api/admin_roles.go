@@ -0,0 +1,227 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+
+	"gopds-api/database"
+	"gopds-api/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RolesAdmin is the service-layer view of admin roles: define them, and
+// give them to users.
+type RolesAdmin interface {
+	List(ctx context.Context) ([]models.Role, error)
+	Get(ctx context.Context, id int64) (*models.Role, error)
+	Create(ctx context.Context, role models.Role) (*models.Role, error)
+	Update(ctx context.Context, role models.Role) (*models.Role, error)
+	Delete(ctx context.Context, id int64) error
+
+	UserRoles(ctx context.Context, userID int64) ([]models.Role, error)
+	SetUserRoles(ctx context.Context, userID int64, roleIDs []int64, grantedBy *int64) ([]models.Role, error)
+}
+
+// RolesHandler binds RolesAdmin to gin routes.
+type RolesHandler struct {
+	Svc RolesAdmin
+}
+
+// Register attaches the role endpoints to the given group.
+// Caller is expected to have already wrapped the group with admin middleware.
+func (h *RolesHandler) Register(r *gin.RouterGroup) {
+	r.GET("", h.list)
+	r.GET("/permissions", h.permissions)
+	r.POST("", h.create)
+	r.PUT("/:id", h.update)
+	r.DELETE("/:id", h.delete)
+	r.GET("/users/:userID", h.userRoles)
+	r.PUT("/users/:userID", h.setUserRoles)
+}
+
+// --- DTOs ---
+
+type roleRequest struct {
+	Name        string   `json:"name" binding:"required"`
+	Description string   `json:"description" binding:"max=1000"`
+	Permissions []string `json:"permissions"`
+}
+
+type userRolesRequest struct {
+	RoleIDs []int64 `json:"role_ids" binding:"max=100"`
+}
+
+// --- Handlers ---
+
+func (h *RolesHandler) list(c *gin.Context) {
+	roles, err := h.Svc.List(c.Request.Context())
+	if err != nil {
+		respondRoleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, roles)
+}
+
+func (h *RolesHandler) permissions(c *gin.Context) {
+	c.JSON(http.StatusOK, models.Permissions)
+}
+
+func (h *RolesHandler) create(c *gin.Context) {
+	var req roleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !authorizeGrant(c, req.Permissions) {
+		return
+	}
+	role, err := h.Svc.Create(c.Request.Context(), models.Role{
+		Name:        req.Name,
+		Description: req.Description,
+		Permissions: req.Permissions,
+	})
+	if err != nil {
+		respondRoleError(c, err)
+		return
+	}
+	recordAudit(c, models.AuditRoleCreate, "role", role.ID, nil, role)
+	c.JSON(http.StatusCreated, role)
+}
+
+func (h *RolesHandler) update(c *gin.Context) {
+	id, ok := parseInt64Param(c, "id")
+	if !ok {
+		return
+	}
+	var req roleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !authorizeGrant(c, req.Permissions) {
+		return
+	}
+	before, err := h.Svc.Get(c.Request.Context(), id)
+	if err != nil {
+		respondRoleError(c, err)
+		return
+	}
+	role, err := h.Svc.Update(c.Request.Context(), models.Role{
+		ID:          id,
+		Name:        req.Name,
+		Description: req.Description,
+		Permissions: req.Permissions,
+	})
+	if err != nil {
+		respondRoleError(c, err)
+		return
+	}
+	recordAudit(c, models.AuditRoleUpdate, "role", id, before, role)
+	c.JSON(http.StatusOK, role)
+}
+
+func (h *RolesHandler) delete(c *gin.Context) {
+	id, ok := parseInt64Param(c, "id")
+	if !ok {
+		return
+	}
+	before, err := h.Svc.Get(c.Request.Context(), id)
+	if err != nil {
+		respondRoleError(c, err)
+		return
+	}
+	if err := h.Svc.Delete(c.Request.Context(), id); err != nil {
+		respondRoleError(c, err)
+		return
+	}
+	recordAudit(c, models.AuditRoleDelete, "role", id, before, nil)
+	c.Status(http.StatusNoContent)
+}
+
+func (h *RolesHandler) userRoles(c *gin.Context) {
+	userID, ok := parseInt64Param(c, "userID")
+	if !ok {
+		return
+	}
+	roles, err := h.Svc.UserRoles(c.Request.Context(), userID)
+	if err != nil {
+		respondRoleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, roles)
+}
+
+func (h *RolesHandler) setUserRoles(c *gin.Context) {
+	userID, ok := parseInt64Param(c, "userID")
+	if !ok {
+		return
+	}
+	var req userRolesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	before, err := h.Svc.UserRoles(c.Request.Context(), userID)
+	if err != nil {
+		respondRoleError(c, err)
+		return
+	}
+	if !h.authorizeRoles(c, before, req.RoleIDs) {
+		return
+	}
+	roles, err := h.Svc.SetUserRoles(c.Request.Context(), userID, req.RoleIDs, adminUserID(c))
+	if err != nil {
+		respondRoleError(c, err)
+		return
+	}
+	recordAudit(c, models.AuditUserRoles, "user", userID,
+		gin.H{"roles": roleNames(before)}, gin.H{"roles": roleNames(roles)})
+	c.JSON(http.StatusOK, roles)
+}
+
+// authorizeRoles checks the roles a user is about to be given, those of
+// roleIDs not among the held ones, with authorizeGrant. Roles the user keeps
+// are not given again, and taking one away grants nothing.
+func (h *RolesHandler) authorizeRoles(c *gin.Context, held []models.Role, roleIDs []int64) bool {
+	for _, id := range roleIDs {
+		if slices.ContainsFunc(held, func(r models.Role) bool { return r.ID == id }) {
+			continue
+		}
+		role, err := h.Svc.Get(c.Request.Context(), id)
+		if err != nil {
+			respondRoleError(c, err)
+			return false
+		}
+		if !authorizeGrant(c, role.Permissions) {
+			return false
+		}
+	}
+	return true
+}
+
+func roleNames(roles []models.Role) []string {
+	names := make([]string, len(roles))
+	for i, r := range roles {
+		names[i] = r.Name
+	}
+	return names
+}
+
+// respondRoleError maps role errors to HTTP responses: an invalid role →
+// 400, a role or user that does not exist → 404, a name already taken →
+// 409, everything else → 500.
+func respondRoleError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, models.ErrRoleInvalid):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, database.ErrRoleNotFound), errors.Is(err, database.ErrUserNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, database.ErrRoleTaken):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,188 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"gopds-api/database"
+	"gopds-api/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeRoles is an in-memory RolesAdmin for httptest.
+type fakeRoles struct {
+	roles     map[int64]models.Role
+	userRoles map[int64][]int64
+	grantedBy *int64
+}
+
+func newFakeRoles() *fakeRoles {
+	return &fakeRoles{
+		roles: map[int64]models.Role{
+			1: {ID: 1, Name: "moderator", Permissions: []string{models.PermBooksApprove}},
+		},
+		userRoles: map[int64][]int64{},
+	}
+}
+
+func (f *fakeRoles) List(ctx context.Context) ([]models.Role, error) {
+	out := make([]models.Role, 0, len(f.roles))
+	for _, r := range f.roles {
+		out = append(out, r)
+	}
+	return out, nil
+}
+func (f *fakeRoles) Get(ctx context.Context, id int64) (*models.Role, error) {
+	r, ok := f.roles[id]
+	if !ok {
+		return nil, database.ErrRoleNotFound
+	}
+	return &r, nil
+}
+func (f *fakeRoles) Create(ctx context.Context, role models.Role) (*models.Role, error) {
+	if err := role.Validate(); err != nil {
+		return nil, err
+	}
+	for _, r := range f.roles {
+		if r.Name == role.Name {
+			return nil, fmt.Errorf("%w: %q", database.ErrRoleTaken, role.Name)
+		}
+	}
+	role.ID = int64(len(f.roles) + 1)
+	f.roles[role.ID] = role
+	return &role, nil
+}
+func (f *fakeRoles) Update(ctx context.Context, role models.Role) (*models.Role, error) {
+	if err := role.Validate(); err != nil {
+		return nil, err
+	}
+	f.roles[role.ID] = role
+	return &role, nil
+}
+func (f *fakeRoles) Delete(ctx context.Context, id int64) error {
+	delete(f.roles, id)
+	return nil
+}
+func (f *fakeRoles) UserRoles(ctx context.Context, userID int64) ([]models.Role, error) {
+	var out []models.Role
+	for _, id := range f.userRoles[userID] {
+		out = append(out, f.roles[id])
+	}
+	return out, nil
+}
+func (f *fakeRoles) SetUserRoles(ctx context.Context, userID int64, roleIDs []int64, grantedBy *int64) ([]models.Role, error) {
+	for _, id := range roleIDs {
+		if _, ok := f.roles[id]; !ok {
+			return nil, database.ErrRoleNotFound
+		}
+	}
+	f.userRoles[userID] = roleIDs
+	f.grantedBy = grantedBy
+	return f.UserRoles(ctx, userID)
+}
+
+func newRolesTestRouter(svc RolesAdmin) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Set("user_id", int64(7))
+		c.Set("is_superuser", true)
+	})
+	h := &RolesHandler{Svc: svc}
+	h.Register(r.Group("/api/admin/roles"))
+	return r
+}
+
+func TestAdminRoles_Create(t *testing.T) {
+	svc := newFakeRoles()
+	r := newRolesTestRouter(svc)
+
+	rec := doJSON(t, r, http.MethodPost, "/api/admin/roles", map[string]any{
+		"name":        "auditor",
+		"permissions": []string{models.PermAuditRead},
+	})
+	require.Equal(t, http.StatusCreated, rec.Code, "body=%s", rec.Body.String())
+
+	rec = doJSON(t, r, http.MethodPost, "/api/admin/roles", map[string]any{"name": "auditor"})
+	assert.Equal(t, http.StatusConflict, rec.Code)
+
+	rec = doJSON(t, r, http.MethodPost, "/api/admin/roles", map[string]any{
+		"name":        "burner",
+		"permissions": []string{"books.burn"},
+	})
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestAdminRoles_UpdateMissing(t *testing.T) {
+	r := newRolesTestRouter(newFakeRoles())
+	rec := doJSON(t, r, http.MethodPut, "/api/admin/roles/99", map[string]any{"name": "ghost"})
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestAdminRoles_SetUserRoles(t *testing.T) {
+	svc := newFakeRoles()
+	audit := &fakeAuditLog{}
+	SetAuditLog(audit)
+	t.Cleanup(func() { SetAuditLog(nil) })
+	r := newRolesTestRouter(svc)
+
+	rec := doJSON(t, r, http.MethodPut, "/api/admin/roles/users/42", map[string]any{"role_ids": []int64{1}})
+	require.Equal(t, http.StatusOK, rec.Code, "body=%s", rec.Body.String())
+	assert.Equal(t, []int64{1}, svc.userRoles[42])
+	require.NotNil(t, svc.grantedBy)
+	assert.Equal(t, int64(7), *svc.grantedBy)
+
+	require.Len(t, audit.recorded, 1)
+	assert.Equal(t, models.AuditUserRoles, audit.recorded[0].Action)
+	assert.Contains(t, string(audit.recorded[0].Changes["roles"].After), "moderator")
+
+	rec = doJSON(t, r, http.MethodPut, "/api/admin/roles/users/42", map[string]any{"role_ids": []int64{5}})
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+// A holder of users.manage hands on only what they hold: a role with more
+// is neither defined nor given by them, so they cannot grant it to
+// themselves. A role the user already has is not given again.
+func TestAdminRoles_StaffGrantOnlyWhatTheyHold(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	svc := newFakeRoles()
+	svc.roles[2] = models.Role{ID: 2, Name: "auditor", Permissions: []string{models.PermAuditRead}}
+	svc.userRoles[42] = []int64{2}
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Set("user_id", int64(7))
+		c.Set("permissions", []string{models.PermUsersManage, models.PermBooksApprove})
+	})
+	(&RolesHandler{Svc: svc}).Register(r.Group("/api/admin/roles"))
+
+	rec := doJSON(t, r, http.MethodPost, "/api/admin/roles", map[string]any{
+		"name":        "everything",
+		"permissions": models.Permissions,
+	})
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	rec = doJSON(t, r, http.MethodPut, "/api/admin/roles/1", map[string]any{
+		"name":        "moderator",
+		"permissions": []string{models.PermBooksApprove, models.PermAuditRead},
+	})
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	assert.Equal(t, []string{models.PermBooksApprove}, svc.roles[1].Permissions)
+
+	rec = doJSON(t, r, http.MethodPut, "/api/admin/roles/users/7", map[string]any{"role_ids": []int64{2}})
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	assert.Empty(t, svc.userRoles[7])
+
+	rec = doJSON(t, r, http.MethodPost, "/api/admin/roles", map[string]any{
+		"name":        "approver",
+		"permissions": []string{models.PermBooksApprove},
+	})
+	assert.Equal(t, http.StatusCreated, rec.Code, "body=%s", rec.Body.String())
+	rec = doJSON(t, r, http.MethodPut, "/api/admin/roles/users/7", map[string]any{"role_ids": []int64{1}})
+	assert.Equal(t, http.StatusOK, rec.Code, "body=%s", rec.Body.String())
+	rec = doJSON(t, r, http.MethodPut, "/api/admin/roles/users/42", map[string]any{"role_ids": []int64{2, 1}})
+	assert.Equal(t, http.StatusOK, rec.Code, "a role already held is kept, not granted")
+}
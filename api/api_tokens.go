@@ -0,0 +1,135 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"gopds-api/database"
+	"gopds-api/middlewares"
+	"gopds-api/models"
+	"gopds-api/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// APITokenScopeRules assigns routes to the scopes a personal access token
+// needs for them, for middlewares.AuthMiddleware. The first rule whose path
+// prefixes a route's pattern wins. A route no rule covers — account
+// settings, sessions, tokens themselves, and whatever is added without a
+// rule here — is for logged-in users only.
+var APITokenScopeRules = append([]middlewares.PermissionRule{
+	{Path: "/api/books/get/", Permission: models.ScopeDownload},
+	{Path: "/api/books/getsigned/", Permission: models.ScopeDownload},
+	{Method: http.MethodPost, Path: "/api/books/file", Permission: models.ScopeDownload},
+	{Path: "/files/books/", Permission: models.ScopeDownload},
+	{Path: "/api/files/books/", Permission: models.ScopeDownload},
+
+	{Method: http.MethodPost, Path: "/api/books/fav", Permission: models.ScopeManageShelves},
+
+	{Method: http.MethodGet, Path: "/api/books/list", Permission: models.ScopeReadCatalog},
+	{Method: http.MethodGet, Path: "/api/books/langs", Permission: models.ScopeReadCatalog},
+	{Method: http.MethodGet, Path: "/api/books/genres", Permission: models.ScopeReadCatalog},
+	{Method: http.MethodGet, Path: "/api/books/autocomplete", Permission: models.ScopeReadCatalog},
+	{Method: http.MethodGet, Path: "/api/books/authors", Permission: models.ScopeReadCatalog},
+	{Method: http.MethodPost, Path: "/api/books/author", Permission: models.ScopeReadCatalog},
+	{Method: http.MethodGet, Path: "/api/books/preview/", Permission: models.ScopeReadCatalog},
+	{Method: http.MethodGet, Path: "/api/books/recommended", Permission: models.ScopeReadCatalog},
+	{Method: http.MethodGet, Path: "/api/books/:id/similar", Permission: models.ScopeReadCatalog},
+	{Method: http.MethodGet, Path: "/api/books/:id/reviews", Permission: models.ScopeReadCatalog},
+	{Method: http.MethodGet, Path: "/api/collections", Permission: models.ScopeReadCatalog},
+	{Method: http.MethodGet, Path: "/api/requests", Permission: models.ScopeReadCatalog},
+	{Method: http.MethodGet, Path: "/api/uploads", Permission: models.ScopeReadCatalog},
+}, adminTokenScopeRules()...)
+
+// adminTokenScopeRules carries AdminPermissionRules over to token scopes,
+// so that an admin route needs the scope of the permission it needs.
+// AdminMiddleware still checks the permission itself.
+func adminTokenScopeRules() []middlewares.PermissionRule {
+	rules := make([]middlewares.PermissionRule, 0, len(AdminPermissionRules))
+	for _, rule := range AdminPermissionRules {
+		rule.Permission = models.AdminScope(rule.Permission)
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+// APITokens is the service-layer view of a user's personal access tokens.
+type APITokens interface {
+	Create(ctx context.Context, userID int64, req models.APITokenRequest) (*models.APITokenCreated, error)
+	List(ctx context.Context, userID int64) ([]models.APIToken, error)
+	Revoke(ctx context.Context, userID, id int64) error
+}
+
+// APITokensHandler binds APITokens to gin routes.
+type APITokensHandler struct {
+	Svc APITokens
+	// RequireTwoFactorForAdmin refuses tokens with admin scopes to a
+	// session opened without a second factor, as the admin API itself is.
+	RequireTwoFactorForAdmin bool
+}
+
+// Register attaches the endpoints of the logged-in user's tokens.
+// Caller is expected to have already wrapped the group with auth middleware.
+func (h *APITokensHandler) Register(r *gin.RouterGroup) {
+	r.GET("", h.list)
+	r.GET("/scopes", h.scopes)
+	r.POST("", middlewares.CSRFMiddleware(), h.create)
+	r.DELETE("/:id", middlewares.CSRFMiddleware(), h.revoke)
+}
+
+func (h *APITokensHandler) list(c *gin.Context) {
+	tokens, err := h.Svc.List(c.Request.Context(), c.GetInt64("user_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, tokens)
+}
+
+// scopes lists the scopes a token can be given, whether or not the user
+// holds the permissions of the admin ones.
+func (h *APITokensHandler) scopes(c *gin.Context) {
+	c.JSON(http.StatusOK, models.APITokenScopes())
+}
+
+// create issues a token. The answer is the only time the token is shown.
+func (h *APITokensHandler) create(c *gin.Context) {
+	var req models.APITokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request"})
+		return
+	}
+	if h.RequireTwoFactorForAdmin && len(req.AdminPermissions()) > 0 && !c.GetBool("two_factor") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "two_factor_required"})
+		return
+	}
+	created, err := h.Svc.Create(c.Request.Context(), c.GetInt64("user_id"), req)
+	switch {
+	case errors.Is(err, models.ErrAPITokenInvalid):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrAPITokenScope):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusCreated, created)
+	}
+}
+
+// revoke deletes a token; requests made with it fail from then on.
+func (h *APITokensHandler) revoke(c *gin.Context) {
+	id, ok := parseInt64Param(c, "id")
+	if !ok {
+		return
+	}
+	err := h.Svc.Revoke(c.Request.Context(), c.GetInt64("user_id"), id)
+	switch {
+	case errors.Is(err, database.ErrAPITokenNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	default:
+		c.Status(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,225 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"gopds-api/database"
+	"gopds-api/middlewares"
+	"gopds-api/models"
+	"gopds-api/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeAPITokens is an in-memory APITokens, and an APITokenAuth for
+// middlewares, whose user 7 holds library.scan only.
+type fakeAPITokens struct {
+	tokens map[string]*models.APIToken
+	nextID int64
+}
+
+func newFakeAPITokens() *fakeAPITokens {
+	return &fakeAPITokens{tokens: map[string]*models.APIToken{}}
+}
+
+func (f *fakeAPITokens) Create(ctx context.Context, userID int64, req models.APITokenRequest) (*models.APITokenCreated, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+	for _, p := range req.AdminPermissions() {
+		if p != models.PermLibraryScan {
+			return nil, services.ErrAPITokenScope
+		}
+	}
+	f.nextID++
+	token := &models.APIToken{ID: f.nextID, UserID: userID, Name: req.Name, Scopes: req.Scopes}
+	raw := models.APITokenPrefix + req.Name
+	f.tokens[raw] = token
+	return &models.APITokenCreated{APIToken: *token, Token: raw}, nil
+}
+func (f *fakeAPITokens) List(ctx context.Context, userID int64) ([]models.APIToken, error) {
+	list := []models.APIToken{}
+	for _, t := range f.tokens {
+		if t.UserID == userID {
+			list = append(list, *t)
+		}
+	}
+	return list, nil
+}
+func (f *fakeAPITokens) Revoke(ctx context.Context, userID, id int64) error {
+	for raw, t := range f.tokens {
+		if t.ID == id && t.UserID == userID {
+			delete(f.tokens, raw)
+			return nil
+		}
+	}
+	return database.ErrAPITokenNotFound
+}
+func (f *fakeAPITokens) Authenticate(ctx context.Context, raw string) (*models.APIToken, *models.User, error) {
+	t, ok := f.tokens[raw]
+	if !ok {
+		return nil, nil, services.ErrAPITokenRejected
+	}
+	return t, &models.User{ID: t.UserID, Login: "reader", Permissions: []string{models.PermLibraryScan}}, nil
+}
+
+func newAPITokensTestRouter(h *APITokensHandler, twoFactor bool) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	g := r.Group("/api/tokens", func(c *gin.Context) {
+		c.Set("user_id", int64(7))
+		c.Set("two_factor", twoFactor)
+	})
+	h.Register(g)
+	return r
+}
+
+func TestAPITokens_CreateListRevoke(t *testing.T) {
+	svc := newFakeAPITokens()
+	r := newAPITokensTestRouter(&APITokensHandler{Svc: svc}, false)
+
+	body := `{"name":"sync","scopes":["download","read-catalog"],"expires_in_days":30}`
+	rec := doJSON(t, r, http.MethodPost, "/api/tokens", json.RawMessage(body))
+	assert.Equal(t, http.StatusForbidden, rec.Code, "the CSRF check comes first")
+
+	rec = doCSRF(t, r, http.MethodPost, "/api/tokens", body)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	var created models.APITokenCreated
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &created))
+	assert.Equal(t, models.APITokenPrefix+"sync", created.Token)
+	assert.Equal(t, []string{models.ScopeReadCatalog, models.ScopeDownload}, created.Scopes)
+
+	rec = doJSON(t, r, http.MethodGet, "/api/tokens", nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.NotContains(t, rec.Body.String(), created.Token, "a token is shown only when created")
+
+	rec = doCSRF(t, r, http.MethodDelete, "/api/tokens/1", "")
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	rec = doCSRF(t, r, http.MethodDelete, "/api/tokens/1", "")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestAPITokens_CreateRefusals(t *testing.T) {
+	svc := newFakeAPITokens()
+	r := newAPITokensTestRouter(&APITokensHandler{Svc: svc}, false)
+
+	rec := doCSRF(t, r, http.MethodPost, "/api/tokens", `{"name":"x","scopes":["everything"]}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = doCSRF(t, r, http.MethodPost, "/api/tokens", `{"name":"x","scopes":["admin:users"]}`)
+	assert.Equal(t, http.StatusForbidden, rec.Code, "beyond the user's permissions")
+
+	rec = doCSRF(t, r, http.MethodPost, "/api/tokens", `{"name":"x","scopes":["admin:scan"]}`)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+
+	r = newAPITokensTestRouter(&APITokensHandler{Svc: svc, RequireTwoFactorForAdmin: true}, false)
+	rec = doCSRF(t, r, http.MethodPost, "/api/tokens", `{"name":"y","scopes":["admin:scan"]}`)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	assert.Contains(t, rec.Body.String(), "two_factor_required")
+
+	rec = doCSRF(t, r, http.MethodPost, "/api/tokens", `{"name":"y","scopes":["download"]}`)
+	assert.Equal(t, http.StatusCreated, rec.Code, "only admin scopes need the second factor")
+}
+
+func TestAPITokenScopeRules(t *testing.T) {
+	tests := []struct {
+		method, path string
+		scope        string // "" when tokens cannot reach the route
+	}{
+		{http.MethodGet, "/api/books/get/:format/:id", models.ScopeDownload},
+		{http.MethodGet, "/api/books/getsigned/:format/:id", models.ScopeDownload},
+		{http.MethodGet, "/files/books/conversion/:id", models.ScopeDownload},
+		{http.MethodHead, "/api/files/books/get/:format/:id", models.ScopeDownload},
+		{http.MethodPost, "/api/books/fav", models.ScopeManageShelves},
+		{http.MethodGet, "/api/books/list", models.ScopeReadCatalog},
+		{http.MethodPost, "/api/books/author", models.ScopeReadCatalog},
+		{http.MethodGet, "/api/books/:id/similar", models.ScopeReadCatalog},
+		{http.MethodGet, "/api/books/recommended", models.ScopeReadCatalog},
+		{http.MethodGet, "/api/books/:id/reviews", models.ScopeReadCatalog},
+		{http.MethodPut, "/api/books/:id/rating", ""},
+		{http.MethodGet, "/api/admin/reviews", "admin:approve"},
+		{http.MethodGet, "/api/requests", models.ScopeReadCatalog},
+		{http.MethodPost, "/api/requests", ""},
+		{http.MethodGet, "/api/admin/requests", "admin:approve"},
+		{http.MethodGet, "/api/uploads", models.ScopeReadCatalog},
+		{http.MethodPost, "/api/uploads", ""},
+		{http.MethodGet, "/api/admin/uploads", "admin:approve"},
+		{http.MethodGet, "/api/collections/:id", models.ScopeReadCatalog},
+		{http.MethodPost, "/api/admin/scan", "admin:scan"},
+		{http.MethodDelete, "/api/admin/scan/reset/:id", "admin:delete"},
+		{http.MethodGet, "/api/admin/users", "admin:users"},
+		{http.MethodGet, "/api/books/self-user", ""},
+		{http.MethodPost, "/api/books/change-me", ""},
+		{http.MethodGet, "/api/tokens", ""},
+		{http.MethodPost, "/api/tokens", ""},
+		{http.MethodDelete, "/api/sessions/:id", ""},
+		{http.MethodGet, "/api/admin/conversions", ""},
+	}
+	for _, tt := range tests {
+		scope, ok := middlewares.RequiredPermission(APITokenScopeRules, tt.method, tt.path)
+		if tt.scope == "" {
+			assert.False(t, ok, "%s %s is reachable with %q", tt.method, tt.path, scope)
+			continue
+		}
+		assert.Equal(t, tt.scope, scope, "%s %s", tt.method, tt.path)
+	}
+}
+
+// TestAPITokens_Middleware runs tokens through the real middlewares: the
+// scopes decide the routes, and token requests skip the CSRF check.
+func TestAPITokens_Middleware(t *testing.T) {
+	svc := newFakeAPITokens()
+	middlewares.SetAPITokens(svc, APITokenScopeRules)
+	t.Cleanup(func() { middlewares.SetAPITokens(nil, nil) })
+	ctx := context.Background()
+	fav, err := svc.Create(ctx, 7, models.APITokenRequest{Name: "fav", Scopes: []string{models.ScopeManageShelves}})
+	require.NoError(t, err)
+	// A token whose user has since lost library.delete.
+	scan := models.APITokenPrefix + "scan"
+	svc.tokens[scan] = &models.APIToken{ID: 99, UserID: 7, Scopes: []string{"admin:scan", "admin:delete"}}
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	ok := func(c *gin.Context) { c.String(http.StatusOK, c.GetString("username")) }
+	g := r.Group("/api", middlewares.AuthMiddleware())
+	g.POST("/books/fav", middlewares.CSRFMiddleware(), ok)
+	g.DELETE("/sessions/:id", middlewares.CSRFMiddleware(), ok)
+	admin := g.Group("/admin", middlewares.AdminMiddleware(AdminPermissionRules))
+	admin.POST("/scan", ok)
+	admin.DELETE("/scan/reset/:id", ok)
+
+	call := func(method, path, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := call(http.MethodPost, "/api/books/fav", fav.Token)
+	assert.Equal(t, http.StatusOK, rec.Code, "no CSRF token needed")
+	assert.Equal(t, "reader", rec.Body.String())
+
+	rec = call(http.MethodDelete, "/api/sessions/s1", fav.Token)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	assert.Contains(t, rec.Body.String(), "insufficient_scope")
+
+	rec = call(http.MethodPost, "/api/admin/scan", fav.Token)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	rec = call(http.MethodPost, "/api/admin/scan", scan)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	rec = call(http.MethodDelete, "/api/admin/scan/reset/1", scan)
+	assert.Equal(t, http.StatusForbidden, rec.Code, "the scope is there, the user's permission is not")
+	assert.Contains(t, rec.Body.String(), "permission_denied")
+
+	rec = call(http.MethodPost, "/api/books/fav", models.APITokenPrefix+"unknown")
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}
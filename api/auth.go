@@ -22,7 +22,7 @@ import (
 // DropAllSessions method for dropping all sessions from Redis
 // Auth godoc
 // @Summary Drop all sessions from Redis
-// @Description Remove all sessions from Redis
+// @Description Sign out of every web session, this one included. OPDS readers and the Telegram bot are revoked one by one from /api/sessions
 // @Tags login
 // @Param Authorization header string true "Token without 'Bearer' prefix"
 // @Accept  json
@@ -65,7 +65,10 @@ func DropAllSessions(c *gin.Context) {
 	}
 
 	// Drop all sessions
-	go sessions.DropAllSessions(userToken)
+	if err := sessions.RevokeWebSessions(ctx, c.GetInt64("user_id")); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
 
 	// Set cookie to expire
 	c.JSON(http.StatusOK, gin.H{"result": "ok"})
@@ -79,7 +82,7 @@ func DropAllSessions(c *gin.Context) {
 // @Accept  json
 // @Produce  json
 // @Param  body body models.LoginRequest true "Login Data"
-// @Success 200 {object} models.LoggedInUser
+// @Success 200 {object} models.LoggedInUser "Logged in, or models.TwoFactorChallenge when a second factor is owed"
 // @Failure 400 {object} httputil.HTTPError
 // @Failure 403 {object} httputil.HTTPError
 // @Failure 500 {object} httputil.HTTPError
@@ -109,20 +112,56 @@ func AuthCheck(c *gin.Context) {
 		return
 	}
 
+	challenge, err := twoFactorChallenge(c.Request.Context(), dbUser)
+	if err != nil {
+		httputil.NewError(c, http.StatusInternalServerError, err)
+		return
+	}
+	if challenge != "" {
+		c.JSON(http.StatusOK, models.TwoFactorChallenge{Required: true, Challenge: challenge})
+		return
+	}
+
+	thisUser, err := openSession(c, dbUser, false)
+	if err != nil {
+		httputil.NewError(c, http.StatusForbidden, err)
+		return
+	}
+	c.JSON(200, thisUser)
+}
+
+// openSession logs dbUser in: it issues the token pair, registers the
+// session and sets the auth cookies. Every way of logging in ends here.
+// twoFactor records that the login was completed with a second factor.
+// A request that already belongs to a session, as when a second factor is
+// added, reopens that session rather than starting another.
+func openSession(c *gin.Context, dbUser models.User, twoFactor bool) (models.LoggedInUser, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
 	defer cancel()
 
+	sessionID := c.GetString("session_id")
+	renew := sessionID != ""
+	if !renew {
+		sessionID = sessions.NewSessionID()
+	}
+
 	// Create token pair instead of single token
-	accessToken, refreshToken, err := utils.CreateTokenPair(dbUser)
+	accessToken, refreshToken, err := utils.CreateTokenPair(dbUser, sessionID, twoFactor)
 	if err != nil {
-		httputil.NewError(c, http.StatusForbidden, err)
-		return
+		return models.LoggedInUser{}, err
+	}
+	if renew {
+		err = sessions.RenewWebSession(ctx, dbUser.ID, sessionID, accessToken, c.ClientIP())
+	} else {
+		err = sessions.OpenWebSession(ctx, dbUser.ID, sessionID, accessToken, c.ClientIP(), c.Request.UserAgent())
+	}
+	if err != nil {
+		return models.LoggedInUser{}, err
 	}
 
 	hf, err := database.HaveFavs(dbUser.ID)
 	if err != nil {
-		httputil.NewError(c, http.StatusBadRequest, err)
-		return
+		return models.LoggedInUser{}, err
 	}
 
 	hasBotToken := dbUser.BotToken != ""
@@ -134,14 +173,14 @@ func AuthCheck(c *gin.Context) {
 		InterfaceLang: dbUser.InterfaceLang,
 		Token:         &accessToken,
 		IsSuperuser:   &dbUser.IsSuperUser,
+		Permissions:   dbUser.Permissions,
 		HaveFavs:      &hf,
 		HasBotToken:   &hasBotToken,
 		DateJoined:    &dbUser.DateJoined,
 	}
 
 	if err := sessions.SetSessionKey(ctx, thisUser); err != nil {
-		httputil.NewError(c, http.StatusForbidden, err)
-		return
+		return models.LoggedInUser{}, err
 	}
 
 	go database.LoginDateSet(&dbUser)
@@ -154,7 +193,7 @@ func AuthCheck(c *gin.Context) {
 	csrfToken := middlewares.GenerateCSRFToken()
 	c.SetCookie("csrf_token", csrfToken, 3600, "/", "", !viper.GetBool("app.devel_mode"), false) // httpOnly=false for JS access
 
-	c.JSON(200, thisUser)
+	return thisUser, nil
 }
 
 // LogOut method for logging out the user
@@ -209,6 +248,12 @@ func LogOut(c *gin.Context) {
 		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
 		return
 	}
+	// And the session it belonged to, so it leaves the list of sessions
+	if sessionID := c.GetString("session_id"); sessionID != "" {
+		if err := sessions.RevokeSession(ctx, c.GetInt64("user_id"), sessionID); err != nil && !errors.Is(err, sessions.ErrSessionNotFound) {
+			logging.Errorf("Failed to revoke session on logout: %v", err)
+		}
+	}
 
 	// Clear authentication cookies by setting them to expire immediately
 	c.SetCookie("token", "", -1, "/", viper.GetString("project_domain"), !viper.GetBool("app.devel_mode"), true)
@@ -249,6 +294,7 @@ func SelfUser(c *gin.Context) {
 			BooksLang:     dbUser.BooksLang,
 			InterfaceLang: dbUser.InterfaceLang,
 			IsSuperuser:   &dbUser.IsSuperUser,
+			Permissions:   dbUser.Permissions,
 			FirstName:     dbUser.FirstName,
 			LastName:      dbUser.LastName,
 			HaveFavs:      &hf,
@@ -299,14 +345,14 @@ func InitSession(c *gin.Context) {
 	if err != nil {
 		refreshToken, refreshErr := c.Cookie("refresh_token")
 		if refreshErr == nil && refreshToken != "" {
-			username, _, _, checkErr := utils.CheckRefreshToken(refreshToken)
+			refreshClaims, checkErr := utils.ParseRefreshToken(refreshToken)
 			if checkErr == nil {
 				// Check if refresh token is blacklisted
 				if !sessions.IsRefreshTokenBlacklisted(ctx, refreshToken) {
-					dbUser, userErr := database.GetUser(strings.ToLower(username))
+					dbUser, userErr := database.GetUser(strings.ToLower(refreshClaims.UserID))
 					if userErr == nil && dbUser.Active {
 						// Create new token pair
-						newAccessToken, newRefreshToken, tokenErr := utils.CreateTokenPair(dbUser)
+						newAccessToken, newRefreshToken, tokenErr := renewSession(ctx, c, dbUser, refreshClaims)
 						if tokenErr == nil {
 							// Blacklist old refresh token (token rotation)
 							if blacklistErr := sessions.BlacklistRefreshToken(ctx, refreshToken); blacklistErr != nil {
@@ -336,6 +382,7 @@ func InitSession(c *gin.Context) {
 									BooksLang:     dbUser.BooksLang,
 									InterfaceLang: dbUser.InterfaceLang,
 									IsSuperuser:   &dbUser.IsSuperUser,
+									Permissions:   dbUser.Permissions,
 									FirstName:     dbUser.FirstName,
 									LastName:      dbUser.LastName,
 									HaveFavs:      &hf,
@@ -388,6 +435,7 @@ func InitSession(c *gin.Context) {
 			BooksLang:     dbUser.BooksLang,
 			InterfaceLang: dbUser.InterfaceLang,
 			IsSuperuser:   &dbUser.IsSuperUser,
+			Permissions:   dbUser.Permissions,
 			FirstName:     dbUser.FirstName,
 			LastName:      dbUser.LastName,
 			HaveFavs:      &hf,
@@ -503,6 +551,7 @@ func ChangeUser(c *gin.Context) {
 			FirstName:     updatedUser.FirstName,
 			LastName:      updatedUser.LastName,
 			IsSuperuser:   &updatedUser.IsSuperUser,
+			Permissions:   updatedUser.Permissions,
 			BooksLang:     updatedUser.BooksLang,
 			InterfaceLang: updatedUser.InterfaceLang,
 			HaveFavs:      &hf,
@@ -530,7 +579,7 @@ func RefreshToken(c *gin.Context) {
 		return
 	}
 
-	username, _, _, err := utils.CheckRefreshToken(refreshToken)
+	refreshClaims, err := utils.ParseRefreshToken(refreshToken)
 	if err != nil {
 		httputil.NewError(c, http.StatusUnauthorized, errors.New("invalid_refresh_token"))
 		return
@@ -546,25 +595,29 @@ func RefreshToken(c *gin.Context) {
 	}
 
 	// Get user from database
-	dbUser, err := database.GetUser(username)
+	dbUser, err := database.GetUser(refreshClaims.UserID)
 	if err != nil || !dbUser.Active {
 		httputil.NewError(c, http.StatusUnauthorized, errors.New("user_not_found_or_inactive"))
 		return
 	}
 
-	// Blacklist the old refresh token (token rotation)
-	if blacklistErr := sessions.BlacklistRefreshToken(ctx, refreshToken); blacklistErr != nil {
-		logging.Errorf("Failed to blacklist old refresh token: %v", blacklistErr)
-		// Continue anyway - this is not critical for the refresh operation
-	}
-
 	// Create new token pair
-	accessToken, newRefreshToken, err := utils.CreateTokenPair(dbUser)
+	accessToken, newRefreshToken, err := renewSession(ctx, c, dbUser, refreshClaims)
+	if errors.Is(err, sessions.ErrSessionNotFound) {
+		httputil.NewError(c, http.StatusUnauthorized, errors.New("refresh_token_revoked"))
+		return
+	}
 	if err != nil {
 		httputil.NewError(c, http.StatusInternalServerError, err)
 		return
 	}
 
+	// Blacklist the old refresh token (token rotation)
+	if blacklistErr := sessions.BlacklistRefreshToken(ctx, refreshToken); blacklistErr != nil {
+		logging.Errorf("Failed to blacklist old refresh token: %v", blacklistErr)
+		// Continue anyway - this is not critical for the refresh operation
+	}
+
 	// Update session in Redis
 	thisUser := models.LoggedInUser{
 		User:  strings.ToLower(dbUser.Login),
@@ -590,3 +643,30 @@ func RefreshToken(c *gin.Context) {
 		"expires_in":    900, // 15 minutes
 	})
 }
+
+// renewSession issues the token pair a refresh token is exchanged for, in
+// the session the refresh token belongs to. A session revoked since gives
+// sessions.ErrSessionNotFound, and so does a refresh token from before the
+// session index: it carries no session, so no revocation reaches it, and
+// its holder logs in again.
+func renewSession(ctx context.Context, c *gin.Context, dbUser models.User, refresh *utils.Token) (string, string, error) {
+	if refresh.SessionID == "" {
+		return "", "", sessions.ErrSessionNotFound
+	}
+	active, err := sessions.WebSessionActive(ctx, dbUser.ID, refresh.SessionID)
+	if err != nil {
+		return "", "", err
+	}
+	if !active {
+		return "", "", sessions.ErrSessionNotFound
+	}
+
+	accessToken, refreshToken, err := utils.CreateTokenPair(dbUser, refresh.SessionID, keepsTwoFactor(ctx, refresh))
+	if err != nil {
+		return "", "", err
+	}
+	if err := sessions.RenewWebSession(ctx, dbUser.ID, refresh.SessionID, accessToken, c.ClientIP()); err != nil {
+		return "", "", err
+	}
+	return accessToken, refreshToken, nil
+}
@@ -15,6 +15,9 @@ import (
 type AuthorAnswer struct {
 	Authors []models.Author `json:"authors"`
 	Length  int             `json:"length"`
+	// Rewrite names the query the authors were found for when it is not
+	// the one asked.
+	Rewrite *models.QueryRewrite `json:"rewrite,omitempty"`
 }
 
 // pageCount turns a number of authors into the number of pages the search page
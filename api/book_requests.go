@@ -0,0 +1,276 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"gopds-api/database"
+	"gopds-api/httputil"
+	"gopds-api/logging"
+	"gopds-api/middlewares"
+	"gopds-api/models"
+	"gopds-api/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BookRequests is the service-layer view of the readers' book requests.
+type BookRequests interface {
+	Submit(
+		ctx context.Context, userID int64, sub models.BookRequestSubmission,
+	) (request *models.BookRequestView, created bool, err error)
+	List(ctx context.Context, filter models.BookRequestFilter, page, pageSize int) ([]models.BookRequestView, int, error)
+	Vote(ctx context.Context, id, userID int64) error
+	Unvote(ctx context.Context, id, userID int64) error
+}
+
+// BookRequestsAdmin is the service-layer view of the request queue the
+// moderators work.
+type BookRequestsAdmin interface {
+	List(ctx context.Context, filter models.BookRequestFilter, page, pageSize int) ([]models.BookRequestView, int, error)
+	Dismiss(ctx context.Context, id int64) (before, after *models.BookRequest, err error)
+}
+
+// BookRequestFulfiller fulfils open requests from the books a scan brought
+// in.
+type BookRequestFulfiller interface {
+	FulfilOpen(ctx context.Context) (int, error)
+}
+
+// BookRequestsHandler binds BookRequests to gin routes.
+type BookRequestsHandler struct {
+	Svc BookRequests
+}
+
+// BookRequestsAdminHandler binds BookRequestsAdmin to gin routes.
+type BookRequestsAdminHandler struct {
+	Svc BookRequestsAdmin
+}
+
+// BookRequestsAnswer is a page of requests and the number of requests on
+// every page.
+type BookRequestsAnswer struct {
+	Requests []models.BookRequestView `json:"requests"`
+	Total    int                      `json:"total"`
+}
+
+// bookRequestFulfiller is run after every scan that brought books in. Nil
+// until main sets it, in which case requests stay open.
+var bookRequestFulfiller BookRequestFulfiller
+
+// SetBookRequestFulfiller installs what the scans fulfil requests with.
+func SetBookRequestFulfiller(f BookRequestFulfiller) {
+	bookRequestFulfiller = f
+}
+
+// bookRequestFulfilTimeout bounds one pass over the open requests.
+const bookRequestFulfilTimeout = 10 * time.Minute
+
+// fulfilBookRequestsAfterScan runs the open requests through the matcher
+// once a scan is done, if it brought any books in.
+func fulfilBookRequestsAfterScan(processedBooks int) {
+	if bookRequestFulfiller == nil || processedBooks == 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), bookRequestFulfilTimeout)
+	defer cancel()
+	if _, err := bookRequestFulfiller.FulfilOpen(ctx); err != nil {
+		logging.Warnf("Failed to fulfil book requests after the scan: %v", err)
+	}
+}
+
+// BookRequestWebSocketNotifier returns the notifier that tells readers of
+// fulfilled requests over their WebSocket connections; nil before
+// InitWebSocketManager.
+func BookRequestWebSocketNotifier() services.BookRequestNotifier {
+	if wsManager == nil {
+		return nil
+	}
+	return services.NewWebSocketBookRequestNotifier(wsManager)
+}
+
+// Register attaches the book request endpoints to the given group.
+// Caller is expected to have already wrapped the group with auth middleware.
+func (h *BookRequestsHandler) Register(r *gin.RouterGroup) {
+	r.GET("", h.List)
+	r.POST("", middlewares.CSRFMiddleware(), h.Submit)
+	r.PUT("/:id/vote", middlewares.CSRFMiddleware(), h.Vote)
+	r.DELETE("/:id/vote", middlewares.CSRFMiddleware(), h.Unvote)
+}
+
+// Register attaches the book request queue endpoints to the given group.
+// Caller is expected to have already wrapped the group with admin middleware.
+func (h *BookRequestsAdminHandler) Register(r *gin.RouterGroup) {
+	r.GET("", h.queue)
+	r.POST("/:id/dismiss", h.dismiss)
+}
+
+// List returns a page of book requests
+// Auth godoc
+// @Summary Book requests
+// @Description Requests in a state, open by default: open ones most asked for first, closed ones most recently closed first
+// @Param Authorization header string true "Token without 'Bearer' prefix"
+// @Param  status query string false "open, fulfilled or dismissed"
+// @Param  mine query bool false "Only the requests the user asked for"
+// @Param  page query int false "Page number, from 1"
+// @Param  page_size query int false "Requests per page, 20 by default, 100 at most"
+// @Tags requests
+// @Produce  json
+// @Success 200 {object} BookRequestsAnswer "Requests"
+// @Failure 400 {object} httputil.HTTPError "Bad request"
+// @Failure 500 {object} httputil.HTTPError "Internal server error"
+// @Router /api/requests [get]
+func (h *BookRequestsHandler) List(c *gin.Context) {
+	listBookRequests(c, h.Svc.List, models.BookRequestFilter{
+		Status:   c.DefaultQuery("status", models.BookRequestOpen),
+		ViewerID: c.GetInt64("user_id"),
+		Mine:     c.Query("mine") == "true",
+	})
+}
+
+// Submit asks the library for a book
+// Auth godoc
+// @Summary Ask for a book
+// @Description Request a book the library does not have; asking for a book already asked for adds the user's vote to that request
+// @Param Authorization header string true "Token without 'Bearer' prefix"
+// @Param  body body models.BookRequestSubmission true "Title, author and ISBN"
+// @Tags requests
+// @Accept  json
+// @Produce  json
+// @Success 201 {object} models.BookRequestView "The new request"
+// @Success 200 {object} models.BookRequestView "The request the user seconded"
+// @Failure 400 {object} httputil.HTTPError "Bad request"
+// @Failure 409 {object} map[string]interface{} "The library has the book, named by book_id"
+// @Failure 500 {object} httputil.HTTPError "Internal server error"
+// @Router /api/requests [post]
+func (h *BookRequestsHandler) Submit(c *gin.Context) {
+	var sub models.BookRequestSubmission
+	if err := c.ShouldBindJSON(&sub); err != nil {
+		httputil.NewError(c, http.StatusBadRequest, errors.New("bad_request"))
+		return
+	}
+	request, created, err := h.Svc.Submit(c.Request.Context(), c.GetInt64("user_id"), sub)
+	if err != nil {
+		respondBookRequestError(c, err)
+		return
+	}
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+	c.JSON(status, request)
+}
+
+// Vote adds the user's vote to a request
+// Auth godoc
+// @Summary Second a book request
+// @Description Add the user's vote to an open request; voting twice is voting once
+// @Param Authorization header string true "Token without 'Bearer' prefix"
+// @Param  id path int true "Request ID"
+// @Tags requests
+// @Success 204 "Voted"
+// @Failure 400 {object} httputil.HTTPError "Bad request"
+// @Failure 404 {object} httputil.HTTPError "Request not found"
+// @Failure 409 {object} httputil.HTTPError "Request is closed"
+// @Failure 500 {object} httputil.HTTPError "Internal server error"
+// @Router /api/requests/{id}/vote [put]
+func (h *BookRequestsHandler) Vote(c *gin.Context) {
+	id, ok := parseInt64Param(c, "id")
+	if !ok {
+		return
+	}
+	if err := h.Svc.Vote(c.Request.Context(), id, c.GetInt64("user_id")); err != nil {
+		respondBookRequestError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// Unvote takes back the user's vote
+// Auth godoc
+// @Summary Take back a vote
+// @Description Remove the user's vote from a request; the request itself stays
+// @Param Authorization header string true "Token without 'Bearer' prefix"
+// @Param  id path int true "Request ID"
+// @Tags requests
+// @Success 204 "Removed"
+// @Failure 400 {object} httputil.HTTPError "Bad request"
+// @Failure 404 {object} httputil.HTTPError "Not voted"
+// @Failure 500 {object} httputil.HTTPError "Internal server error"
+// @Router /api/requests/{id}/vote [delete]
+func (h *BookRequestsHandler) Unvote(c *gin.Context) {
+	id, ok := parseInt64Param(c, "id")
+	if !ok {
+		return
+	}
+	if err := h.Svc.Unvote(c.Request.Context(), id, c.GetInt64("user_id")); err != nil {
+		respondBookRequestError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// queue lists the requests in a state, open by default, most asked for
+// first.
+func (h *BookRequestsAdminHandler) queue(c *gin.Context) {
+	listBookRequests(c, h.Svc.List, models.BookRequestFilter{
+		Status:   c.DefaultQuery("status", models.BookRequestOpen),
+		ViewerID: c.GetInt64("user_id"),
+	})
+}
+
+// dismiss closes a request no book is coming for.
+func (h *BookRequestsAdminHandler) dismiss(c *gin.Context) {
+	id, ok := parseInt64Param(c, "id")
+	if !ok {
+		return
+	}
+	before, after, err := h.Svc.Dismiss(c.Request.Context(), id)
+	if err != nil {
+		respondBookRequestError(c, err)
+		return
+	}
+	recordAudit(c, models.AuditBookRequestDismiss, "book_request", after.ID, before, after)
+	c.JSON(http.StatusOK, after)
+}
+
+// listBookRequests answers a page of requests from either side's list.
+func listBookRequests(
+	c *gin.Context,
+	list func(context.Context, models.BookRequestFilter, int, int) ([]models.BookRequestView, int, error),
+	filter models.BookRequestFilter,
+) {
+	page, _ := strconv.Atoi(c.Query("page"))
+	pageSize, _ := strconv.Atoi(c.Query("page_size"))
+	requests, total, err := list(c.Request.Context(), filter, page, pageSize)
+	if err != nil {
+		respondBookRequestError(c, err)
+		return
+	}
+	if requests == nil {
+		requests = []models.BookRequestView{}
+	}
+	c.JSON(http.StatusOK, BookRequestsAnswer{Requests: requests, Total: total})
+}
+
+// respondBookRequestError maps book request errors to HTTP responses: a
+// request that cannot be stored → 400, a missing request or vote → 404, a
+// closed request or a book the library has → 409, everything else → 500.
+func respondBookRequestError(c *gin.Context, err error) {
+	var inLibrary *models.BookInLibraryError
+	switch {
+	case errors.As(err, &inLibrary):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "book_id": inLibrary.BookID})
+	case errors.Is(err, models.ErrBookRequestInvalid):
+		httputil.NewError(c, http.StatusBadRequest, err)
+	case errors.Is(err, database.ErrBookRequestNotFound):
+		httputil.NewError(c, http.StatusNotFound, err)
+	case errors.Is(err, database.ErrBookRequestClosed):
+		httputil.NewError(c, http.StatusConflict, err)
+	default:
+		httputil.NewError(c, http.StatusInternalServerError, err)
+	}
+}
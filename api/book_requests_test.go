@@ -0,0 +1,210 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"gopds-api/database"
+	"gopds-api/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeBookRequests is an in-memory BookRequests and BookRequestsAdmin in
+// which the library has "Roadside Picnic", as book 1, and nothing else.
+type fakeBookRequests struct {
+	requests []*models.BookRequestView
+	votes    map[int64]map[int64]bool // request -> voters
+	filter   models.BookRequestFilter
+}
+
+func newFakeBookRequests() *fakeBookRequests {
+	return &fakeBookRequests{votes: map[int64]map[int64]bool{}}
+}
+
+func (f *fakeBookRequests) Submit(
+	ctx context.Context, userID int64, sub models.BookRequestSubmission,
+) (*models.BookRequestView, bool, error) {
+	if err := sub.Validate(); err != nil {
+		return nil, false, err
+	}
+	if sub.Title == "Roadside Picnic" {
+		return nil, false, &models.BookInLibraryError{BookID: 1}
+	}
+	for _, r := range f.requests {
+		if r.Title == sub.Title && r.Status == models.BookRequestOpen {
+			return r, false, f.Vote(ctx, r.ID, userID)
+		}
+	}
+	r := &models.BookRequestView{ID: int64(len(f.requests) + 1), Title: sub.Title, Author: sub.Author, Status: models.BookRequestOpen}
+	f.requests = append(f.requests, r)
+	f.votes[r.ID] = map[int64]bool{}
+	return r, true, f.Vote(ctx, r.ID, userID)
+}
+func (f *fakeBookRequests) List(
+	ctx context.Context, filter models.BookRequestFilter, page, pageSize int,
+) ([]models.BookRequestView, int, error) {
+	if !models.IsBookRequestStatus(filter.Status) {
+		return nil, 0, models.ErrBookRequestInvalid
+	}
+	f.filter = filter
+	var list []models.BookRequestView
+	for _, r := range f.requests {
+		if r.Status == filter.Status {
+			view := *r
+			view.Votes = len(f.votes[r.ID])
+			view.Voted = f.votes[r.ID][filter.ViewerID]
+			list = append(list, view)
+		}
+	}
+	return list, len(list), nil
+}
+func (f *fakeBookRequests) Vote(ctx context.Context, id, userID int64) error {
+	r := f.find(id)
+	switch {
+	case r == nil:
+		return database.ErrBookRequestNotFound
+	case r.Status != models.BookRequestOpen:
+		return database.ErrBookRequestClosed
+	}
+	f.votes[id][userID] = true
+	return nil
+}
+func (f *fakeBookRequests) Unvote(ctx context.Context, id, userID int64) error {
+	if !f.votes[id][userID] {
+		return database.ErrBookRequestNotFound
+	}
+	delete(f.votes[id], userID)
+	return nil
+}
+func (f *fakeBookRequests) Dismiss(ctx context.Context, id int64) (before, after *models.BookRequest, err error) {
+	r := f.find(id)
+	switch {
+	case r == nil:
+		return nil, nil, database.ErrBookRequestNotFound
+	case r.Status != models.BookRequestOpen:
+		return nil, nil, database.ErrBookRequestClosed
+	}
+	before = &models.BookRequest{ID: id, Title: r.Title, Status: r.Status}
+	r.Status = models.BookRequestDismissed
+	after = &models.BookRequest{ID: id, Title: r.Title, Status: r.Status}
+	return before, after, nil
+}
+
+func (f *fakeBookRequests) find(id int64) *models.BookRequestView {
+	for _, r := range f.requests {
+		if r.ID == id {
+			return r
+		}
+	}
+	return nil
+}
+
+func newBookRequestsTestRouter(svc *fakeBookRequests) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	setUser := func(c *gin.Context) { c.Set("user_id", int64(7)) }
+	(&BookRequestsHandler{Svc: svc}).Register(r.Group("/api/requests", setUser))
+	(&BookRequestsAdminHandler{Svc: svc}).Register(r.Group("/api/admin/requests", setUser))
+	return r
+}
+
+func decodeBookRequests(t *testing.T, body []byte) BookRequestsAnswer {
+	t.Helper()
+	var answer BookRequestsAnswer
+	require.NoError(t, json.Unmarshal(body, &answer))
+	return answer
+}
+
+func TestBookRequests_AskSecondAndDismiss(t *testing.T) {
+	svc := newFakeBookRequests()
+	r := newBookRequestsTestRouter(svc)
+
+	rec := doJSON(t, r, http.MethodPost, "/api/requests", json.RawMessage(`{"title":"Hard to Be a God"}`))
+	assert.Equal(t, http.StatusForbidden, rec.Code, "the CSRF check comes first")
+
+	rec = doCSRF(t, r, http.MethodPost, "/api/requests", `{"title":"Hard to Be a God","author":"Strugatsky"}`)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	rec = doCSRF(t, r, http.MethodPost, "/api/requests", `{"title":"Hard to Be a God"}`)
+	assert.Equal(t, http.StatusOK, rec.Code, "asking again seconds the open request")
+
+	require.NoError(t, svc.Vote(context.Background(), 1, 8))
+	rec = doJSON(t, r, http.MethodGet, "/api/requests?mine=true", nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	answer := decodeBookRequests(t, rec.Body.Bytes())
+	require.Len(t, answer.Requests, 1)
+	assert.Equal(t, 2, answer.Requests[0].Votes)
+	assert.True(t, answer.Requests[0].Voted)
+	assert.Equal(t, models.BookRequestFilter{Status: models.BookRequestOpen, ViewerID: 7, Mine: true}, svc.filter)
+
+	rec = doCSRF(t, r, http.MethodDelete, "/api/requests/1/vote", "")
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	rec = doCSRF(t, r, http.MethodPut, "/api/requests/1/vote", "")
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+
+	rec = doJSON(t, r, http.MethodPost, "/api/admin/requests/1/dismiss", nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	rec = doJSON(t, r, http.MethodGet, "/api/admin/requests", nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, `{"requests":[],"total":0}`, rec.Body.String(), "the queue lists open requests by default")
+	rec = doJSON(t, r, http.MethodGet, "/api/admin/requests?status=dismissed", nil)
+	assert.Len(t, decodeBookRequests(t, rec.Body.Bytes()).Requests, 1)
+}
+
+func TestBookRequests_Refusals(t *testing.T) {
+	svc := newFakeBookRequests()
+	r := newBookRequestsTestRouter(svc)
+	_, _, err := svc.Submit(context.Background(), 7, models.BookRequestSubmission{Title: "Monday Begins on Saturday"})
+	require.NoError(t, err)
+	_, _, err = svc.Dismiss(context.Background(), 1)
+	require.NoError(t, err)
+
+	for _, tc := range []struct {
+		name, method, path, body string
+		want                     int
+	}{
+		{"no title", http.MethodPost, "/api/requests", `{"author":"Strugatsky"}`, http.StatusBadRequest},
+		{"a bad isbn", http.MethodPost, "/api/requests", `{"title":"Snail on the Slope","isbn":"123"}`, http.StatusBadRequest},
+		{"not a request id", http.MethodPut, "/api/requests/x/vote", "", http.StatusBadRequest},
+		{"unknown request", http.MethodPut, "/api/requests/9/vote", "", http.StatusNotFound},
+		{"a closed request", http.MethodPut, "/api/requests/1/vote", "", http.StatusConflict},
+		{"nothing to take back", http.MethodDelete, "/api/requests/9/vote", "", http.StatusNotFound},
+	} {
+		rec := doCSRF(t, r, tc.method, tc.path, tc.body)
+		assert.Equal(t, tc.want, rec.Code, tc.name)
+	}
+
+	rec := doCSRF(t, r, http.MethodPost, "/api/requests", `{"title":"Roadside Picnic"}`)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"book_id":1`, "the answer names the book the library has")
+
+	rec = doJSON(t, r, http.MethodGet, "/api/requests?status=pending", nil)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	rec = doJSON(t, r, http.MethodPost, "/api/admin/requests/1/dismiss", nil)
+	assert.Equal(t, http.StatusConflict, rec.Code, "a request is dismissed once")
+	rec = doJSON(t, r, http.MethodPost, "/api/admin/requests/9/dismiss", nil)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+// fulfilCounter is a BookRequestFulfiller counting its passes.
+type fulfilCounter struct{ passes int }
+
+func (f *fulfilCounter) FulfilOpen(ctx context.Context) (int, error) {
+	f.passes++
+	return 0, nil
+}
+
+func TestFulfilBookRequestsAfterScan_OnlyWhenBooksCameIn(t *testing.T) {
+	counter := &fulfilCounter{}
+	SetBookRequestFulfiller(counter)
+	t.Cleanup(func() { SetBookRequestFulfiller(nil) })
+
+	fulfilBookRequestsAfterScan(0)
+	assert.Equal(t, 0, counter.passes, "a scan that brought nothing in changes no request")
+	fulfilBookRequestsAfterScan(3)
+	assert.Equal(t, 1, counter.passes)
+}
@@ -15,6 +15,7 @@ import (
 
 	"gopds-api/database"
 	"gopds-api/httputil"
+	"gopds-api/internal/inpx"
 	"gopds-api/llm"
 	"gopds-api/logging"
 	"gopds-api/models"
@@ -131,12 +132,75 @@ func newBookScanService() *services.BookScanService {
 
 	llmSvc := llm.NewLLMService()
 	scanner := services.NewBookScanService(archivesDir, coversDir, languageDetector, skipDuplicates, llmSvc)
+	scanner.SetConcurrency(viper.GetInt("scanning.max_concurrent_files"), viper.GetInt("scanning.batch_size"))
 	if publisher := newScanEventPublisher(); publisher != nil {
 		scanner.SetScanEventPublisher(publisher)
 	}
 	return scanner
 }
 
+// inpxIndexPath returns the INPX catalogue scans take books from: the
+// configured scanning.inpx_path, or else the newest .inpx in the archives
+// directory. Empty when there is none.
+func inpxIndexPath() string {
+	if configured := viper.GetString("scanning.inpx_path"); configured != "" {
+		return configured
+	}
+	entries, err := os.ReadDir(getArchivesDir())
+	if err != nil {
+		return ""
+	}
+	var newest string
+	var newestTime time.Time
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(strings.ToLower(entry.Name()), ".inpx") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		if newest == "" || info.ModTime().After(newestTime) {
+			newest, newestTime = filepath.Join(getArchivesDir(), entry.Name()), info.ModTime()
+		}
+	}
+	return newest
+}
+
+// attachInpxIndex opens the INPX catalogue, if there is one, for the scanner
+// to take books from, and returns what closes it again. A catalogue that
+// does not open is logged and the scan parses every file.
+func attachInpxIndex(scanner *services.BookScanService) func() {
+	path := inpxIndexPath()
+	if path == "" {
+		return func() {}
+	}
+	index, err := inpx.Open(path)
+	if err != nil {
+		logging.Warnf("Failed to open INPX index %s: %v", path, err)
+		return func() {}
+	}
+	logging.Infof("Using INPX index %s (%s %s, %d archives)", path, index.Collection, index.Version, len(index.Archives()))
+	scanner.SetInpxIndex(index)
+	return func() {
+		if err := index.Close(); err != nil {
+			logging.Warnf("Failed to close INPX index %s: %v", path, err)
+		}
+	}
+}
+
+// AdminEventPublisher returns the publisher of admin WebSocket events for
+// services wired outside this package; nil before InitWebSocketManager.
+func AdminEventPublisher() *services.ScanEventPublisher {
+	return newScanEventPublisher()
+}
+
+// BookScanner returns a scanner configured as the admin scans are, for
+// services wired outside this package that parse books as a scan does.
+func BookScanner() *services.BookScanService {
+	return newBookScanService()
+}
+
 func newScanEventPublisher() *services.ScanEventPublisher {
 	if wsManager == nil {
 		return nil
@@ -200,7 +264,8 @@ func StartScan(c *gin.Context) {
 		return
 	}
 
-	go runFullScan(sessionID)
+	go runFullScan(sessionID, (*services.BookScanService).GetUnscannedArchives)
+	recordAudit(c, models.AuditScanStart, "scan", sessionID, nil, nil)
 
 	c.JSON(http.StatusOK, StartScanResponse{
 		SessionID: sessionID,
@@ -209,6 +274,44 @@ func StartScan(c *gin.Context) {
 	})
 }
 
+// StartInpxImport godoc
+// @Summary Import an INPX index
+// @Description Import books from the INPX catalogue (scanning.inpx_path, or the newest .inpx in the archives directory) into the archives it describes (async). Archives already imported from an unchanged .inp are skipped, so a new catalogue only re-imports what changed. Files the index does not describe are parsed as FB2.
+// @Tags admin
+// @Param Authorization header string true "Token without 'Bearer' prefix"
+// @Produce json
+// @Success 200 {object} StartScanResponse
+// @Failure 403 {object} httputil.HTTPError
+// @Failure 404 {object} httputil.HTTPError "No INPX index"
+// @Failure 409 {object} httputil.HTTPError "Scan already running"
+// @Router /api/admin/scan/inpx [post]
+func StartInpxImport(c *gin.Context) {
+	if fixState.isRunning() {
+		httputil.NewError(c, http.StatusConflict, errors.New("fix scan already running"))
+		return
+	}
+	if inpxIndexPath() == "" {
+		httputil.NewError(c, http.StatusNotFound, errors.New("no INPX index found"))
+		return
+	}
+
+	sessionID := fmt.Sprintf("inpx_%d", time.Now().UnixNano())
+	startedAt := time.Now()
+	if !scanState.tryStart(sessionID, startedAt) {
+		httputil.NewError(c, http.StatusConflict, errors.New("scan already running"))
+		return
+	}
+
+	go runFullScan(sessionID, (*services.BookScanService).GetIndexedArchivesToImport)
+	recordAudit(c, models.AuditScanInpx, "scan", sessionID, nil, gin.H{"index": inpxIndexPath()})
+
+	c.JSON(http.StatusOK, StartScanResponse{
+		SessionID: sessionID,
+		StartedAt: startedAt,
+		Message:   "inpx import started",
+	})
+}
+
 // GetScanStatus godoc
 // @Summary Get scan status
 // @Description Returns current scan progress and stats
@@ -421,6 +524,7 @@ func ScanSpecificArchive(c *gin.Context) {
 	}
 
 	go runSingleArchiveScan(sessionID, archivePath)
+	recordAudit(c, models.AuditScanArchive, "archive", req.Name, nil, gin.H{"session_id": sessionID})
 
 	c.JSON(http.StatusAccepted, StartScanResponse{
 		SessionID: sessionID,
@@ -456,8 +560,10 @@ func ResetArchiveScanStatus(c *gin.Context) {
 		return
 	}
 
+	var deleted int
 	if c.Query("delete_books") == "true" {
-		deleted, err := database.DeleteBooksByArchive(name)
+		var err error
+		deleted, err = database.DeleteBooksByArchive(name)
 		if err != nil {
 			httputil.NewError(c, http.StatusInternalServerError, err)
 			return
@@ -473,6 +579,7 @@ func ResetArchiveScanStatus(c *gin.Context) {
 		httputil.NewError(c, http.StatusInternalServerError, err)
 		return
 	}
+	recordAudit(c, models.AuditScanReset, "archive", name, nil, gin.H{"deleted_books": deleted})
 
 	c.JSON(http.StatusOK, models.Result{
 		Result: "reset_ok",
@@ -518,12 +625,15 @@ func GetScannedArchives(c *gin.Context) {
 	c.JSON(http.StatusOK, response)
 }
 
-func runFullScan(sessionID string) {
+// runFullScan scans the archives listArchives picks, taking books from the
+// INPX index where there is one.
+func runFullScan(sessionID string, listArchives func(*services.BookScanService) ([]string, error)) {
 	scanner := newBookScanService()
+	defer attachInpxIndex(scanner)()
 	publisher := newScanEventPublisher()
-	archives, err := scanner.GetUnscannedArchives()
+	archives, err := listArchives(scanner)
 	if err != nil {
-		scanState.fail(sessionID, fmt.Errorf("failed to get unscanned archives: %w", err))
+		scanState.fail(sessionID, fmt.Errorf("failed to list archives to scan: %w", err))
 		if publisher != nil {
 			publisher.PublishScanError(err)
 		}
@@ -555,14 +665,14 @@ func runFullScan(sessionID string) {
 		for {
 			select {
 			case <-progressTicker.C:
+				// The scanner counts files over all the archives it has
+				// scanned, however many run at once.
 				processed, total := scanner.GetScanProgress()
 				if total > 0 {
 					scanState.mu.Lock()
-					scanState.totalBooks = scanState.totalBooks - scanState.totalBooks%1000 + processed
 					archivesProcessed := scanState.archivesProcessed
 					totalArchives := scanState.totalArchives
 					currentArchive := scanState.currentArchive
-					totalBooks := scanState.totalBooks
 					startedAt := scanState.startedAt
 					scanState.mu.Unlock()
 
@@ -573,7 +683,7 @@ func runFullScan(sessionID string) {
 					}
 
 					// Send WebSocket progress update
-					scanner.PublishProgress(currentArchive, archivesProcessed, totalArchives, totalBooks, totalBooks, elapsedSeconds)
+					scanner.PublishProgress(currentArchive, archivesProcessed, totalArchives, processed, total, elapsedSeconds)
 				}
 			case <-progressDone:
 				return
@@ -588,23 +698,44 @@ func runFullScan(sessionID string) {
 		Errors:         []services.ScanError{},
 	}
 	scanStart := time.Now()
-	for _, archivePath := range archives {
-		archiveName := archiveNameFromPath(archivesDir, archivePath)
-		scanState.setCurrentArchive(sessionID, archiveName)
 
-		report, scanErr := scanner.ScanArchive(archivePath)
-		scanState.addErrors(sessionID, report)
-		if report != nil {
-			scanReport.ArchiveReports = append(scanReport.ArchiveReports, *report)
-			scanReport.ProcessedBooks += report.BooksProcessed
-			scanReport.SkippedBooks += report.BooksSkipped
-			scanReport.Errors = append(scanReport.Errors, report.Errors...)
-		}
-		if scanErr != nil && publisher != nil {
-			publisher.PublishScanError(scanErr)
-		}
-		scanState.applyArchiveResult(sessionID, report, scanErr)
+	// Up to max_concurrent_archives archives are scanned at once, each with
+	// its own pool of max_concurrent_files workers.
+	parallel := viper.GetInt("scanning.max_concurrent_archives")
+	if parallel < 1 {
+		parallel = 1
 	}
+	var reportMu sync.Mutex
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, parallel)
+	for _, archivePath := range archives {
+		slots <- struct{}{}
+		wg.Add(1)
+		go func(archivePath string) {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+			archiveName := archiveNameFromPath(archivesDir, archivePath)
+			scanState.setCurrentArchive(sessionID, archiveName)
+
+			report, scanErr := scanner.ScanArchive(archivePath)
+			scanState.addErrors(sessionID, report)
+			if report != nil {
+				reportMu.Lock()
+				scanReport.ArchiveReports = append(scanReport.ArchiveReports, *report)
+				scanReport.ProcessedBooks += report.BooksProcessed
+				scanReport.SkippedBooks += report.BooksSkipped
+				scanReport.Errors = append(scanReport.Errors, report.Errors...)
+				reportMu.Unlock()
+			}
+			if scanErr != nil && publisher != nil {
+				publisher.PublishScanError(scanErr)
+			}
+			scanState.applyArchiveResult(sessionID, report, scanErr)
+		}(archivePath)
+	}
+	wg.Wait()
 
 	// Stop progress monitoring
 	close(progressDone)
@@ -616,6 +747,7 @@ func runFullScan(sessionID string) {
 	}
 
 	scanState.finish(sessionID)
+	fulfilBookRequestsAfterScan(scanReport.ProcessedBooks)
 }
 
 func runSingleArchiveScan(sessionID string, archivePath string) {
@@ -627,6 +759,7 @@ func runSingleArchiveScan(sessionID string, archivePath string) {
 	}()
 
 	scanner := newBookScanService()
+	defer attachInpxIndex(scanner)()
 	publisher := newScanEventPublisher()
 	archivesDir := getArchivesDir()
 	archiveName := archiveNameFromPath(archivesDir, archivePath)
@@ -649,7 +782,6 @@ func runSingleArchiveScan(sessionID string, archivePath string) {
 				processed, total := scanner.GetScanProgress()
 				if total > 0 {
 					scanState.mu.Lock()
-					scanState.totalBooks = processed
 					archivesProcessed := scanState.archivesProcessed
 					totalArchives := scanState.totalArchives
 					currentArchive := scanState.currentArchive
@@ -702,6 +834,9 @@ func runSingleArchiveScan(sessionID string, archivePath string) {
 		publisher.PublishScanCompleted(scanReport)
 	}
 	scanState.finish(sessionID)
+	if report != nil {
+		fulfilBookRequestsAfterScan(report.BooksProcessed)
+	}
 }
 
 func (s *bookScanState) tryStart(sessionID string, startedAt time.Time) bool {
@@ -0,0 +1,234 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"gopds-api/database"
+	"gopds-api/httputil"
+	"gopds-api/internal/safeio"
+	"gopds-api/middlewares"
+	"gopds-api/models"
+	"gopds-api/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BookUploads is the service-layer view of the books readers upload.
+type BookUploads interface {
+	Upload(ctx context.Context, userID int64, fileName string, content []byte) (*models.BookUpload, error)
+	List(ctx context.Context, filter models.BookUploadFilter, page, pageSize int) ([]models.BookUploadView, int, error)
+}
+
+// BookUploadsAdmin is the service-layer view of the upload queue the
+// moderators work.
+type BookUploadsAdmin interface {
+	List(ctx context.Context, filter models.BookUploadFilter, page, pageSize int) ([]models.BookUploadView, int, error)
+	Moderate(
+		ctx context.Context, id int64, decision models.UploadModeration, moderatorID *int64,
+	) (before, after *models.BookUpload, err error)
+}
+
+// BookUploadsHandler binds BookUploads to gin routes.
+type BookUploadsHandler struct {
+	Svc BookUploads
+	// MaxBytes bounds an uploaded file; the request may carry that and the
+	// form around it.
+	MaxBytes int64
+}
+
+// BookUploadsAdminHandler binds BookUploadsAdmin to gin routes.
+type BookUploadsAdminHandler struct {
+	Svc BookUploadsAdmin
+}
+
+// BookUploadsAnswer is a page of uploads and the number of uploads on
+// every page.
+type BookUploadsAnswer struct {
+	Uploads []models.BookUploadView `json:"uploads"`
+	Total   int                     `json:"total"`
+}
+
+// uploadFormOverhead is the room a multipart request needs beside the
+// file: the boundaries and the part headers.
+const uploadFormOverhead = 64 << 10
+
+// Register attaches the upload endpoints to the given group.
+// Caller is expected to have already wrapped the group with auth middleware.
+func (h *BookUploadsHandler) Register(r *gin.RouterGroup) {
+	r.GET("", h.List)
+	r.POST("", middlewares.CSRFMiddleware(), h.Upload)
+}
+
+// Register attaches the upload queue endpoints to the given group.
+// Caller is expected to have already wrapped the group with admin middleware.
+func (h *BookUploadsAdminHandler) Register(r *gin.RouterGroup) {
+	r.GET("", h.queue)
+	r.POST("/:id/moderate", h.moderate)
+}
+
+// List returns a page of the user's uploads
+// Auth godoc
+// @Summary My uploads
+// @Description The books the user uploaded, newest first, with their moderation status and, for a rejected one, the reason
+// @Param Authorization header string true "Token without 'Bearer' prefix"
+// @Param  status query string false "pending, approved or rejected; every state when empty"
+// @Param  page query int false "Page number, from 1"
+// @Param  page_size query int false "Uploads per page, 20 by default, 100 at most"
+// @Tags uploads
+// @Produce  json
+// @Success 200 {object} BookUploadsAnswer "Uploads"
+// @Failure 400 {object} httputil.HTTPError "Bad request"
+// @Failure 500 {object} httputil.HTTPError "Internal server error"
+// @Router /api/uploads [get]
+func (h *BookUploadsHandler) List(c *gin.Context) {
+	listBookUploads(c, h.Svc.List, models.BookUploadFilter{
+		Status: c.Query("status"),
+		UserID: c.GetInt64("user_id"),
+	})
+}
+
+// Upload takes a book from the user
+// Auth godoc
+// @Summary Upload a book
+// @Description Send an FB2, a zip holding one, or an EPUB for the library. It waits as an unapproved book until a moderator decides; an EPUB is downloaded only as itself
+// @Param Authorization header string true "Token without 'Bearer' prefix"
+// @Param  file formData file true "The .fb2, .fb2.zip or .epub file"
+// @Tags uploads
+// @Accept  multipart/form-data
+// @Produce  json
+// @Success 201 {object} models.BookUpload "The upload, pending"
+// @Failure 400 {object} httputil.HTTPError "No file, or not a readable book"
+// @Failure 403 {object} httputil.HTTPError "Uploads are turned off"
+// @Failure 409 {object} map[string]interface{} "The library has the file, named by book_id"
+// @Failure 413 {object} httputil.HTTPError "File too large"
+// @Failure 415 {object} httputil.HTTPError "Not an FB2, a zipped FB2 or an EPUB"
+// @Failure 429 {object} httputil.HTTPError "Daily or pending limit reached"
+// @Failure 500 {object} httputil.HTTPError "Internal server error"
+// @Router /api/uploads [post]
+func (h *BookUploadsHandler) Upload(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, h.MaxBytes+uploadFormOverhead)
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			respondUploadError(c, services.ErrUploadTooLarge)
+			return
+		}
+		httputil.NewError(c, http.StatusBadRequest, errors.New("missing_file"))
+		return
+	}
+	if fileHeader.Size > h.MaxBytes {
+		respondUploadError(c, services.ErrUploadTooLarge)
+		return
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		httputil.NewError(c, http.StatusBadRequest, errors.New("missing_file"))
+		return
+	}
+	defer file.Close()
+	content, err := safeio.ReadAll(file, h.MaxBytes)
+	if err != nil {
+		if errors.Is(err, safeio.ErrTooLarge) {
+			err = services.ErrUploadTooLarge
+		}
+		respondUploadError(c, err)
+		return
+	}
+
+	upload, err := h.Svc.Upload(c.Request.Context(), c.GetInt64("user_id"), fileHeader.Filename, content)
+	if err != nil {
+		respondUploadError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, upload)
+}
+
+// queue lists the uploads in a state, pending by default, with the
+// metadata of their books.
+func (h *BookUploadsAdminHandler) queue(c *gin.Context) {
+	listBookUploads(c, h.Svc.List, models.BookUploadFilter{
+		Status: c.DefaultQuery("status", models.UploadPending),
+	})
+}
+
+// moderate approves or rejects an upload. An approved book may be one a
+// reader asked for, so the open requests are run past it.
+func (h *BookUploadsAdminHandler) moderate(c *gin.Context) {
+	id, ok := parseInt64Param(c, "id")
+	if !ok {
+		return
+	}
+	var req models.UploadModeration
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	before, after, err := h.Svc.Moderate(c.Request.Context(), id, req, adminUserID(c))
+	if err != nil {
+		respondUploadError(c, err)
+		return
+	}
+	action := models.AuditUploadApprove
+	if after.Status == models.UploadRejected {
+		action = models.AuditUploadReject
+	} else {
+		go fulfilBookRequestsAfterScan(1)
+	}
+	recordAudit(c, action, "upload", after.ID, before, after)
+	c.JSON(http.StatusOK, after)
+}
+
+// listBookUploads answers a page of uploads from either side's list.
+func listBookUploads(
+	c *gin.Context,
+	list func(context.Context, models.BookUploadFilter, int, int) ([]models.BookUploadView, int, error),
+	filter models.BookUploadFilter,
+) {
+	page, _ := strconv.Atoi(c.Query("page"))
+	pageSize, _ := strconv.Atoi(c.Query("page_size"))
+	uploads, total, err := list(c.Request.Context(), filter, page, pageSize)
+	if err != nil {
+		respondUploadError(c, err)
+		return
+	}
+	if uploads == nil {
+		uploads = []models.BookUploadView{}
+	}
+	c.JSON(http.StatusOK, BookUploadsAnswer{Uploads: uploads, Total: total})
+}
+
+// respondUploadError maps upload errors to HTTP responses: a file that is
+// not a readable book or a decision that cannot be taken → 400, uploads
+// turned off → 403, a missing upload → 404, a file the library has or an
+// upload already decided → 409, a file too large → 413, a format the
+// library does not keep → 415, a reader over their limits → 429,
+// everything else → 500.
+func respondUploadError(c *gin.Context, err error) {
+	var inLibrary *models.BookInLibraryError
+	switch {
+	case errors.As(err, &inLibrary):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "book_id": inLibrary.BookID})
+	case errors.Is(err, services.ErrUploadUnreadable), errors.Is(err, models.ErrUploadInvalid):
+		httputil.NewError(c, http.StatusBadRequest, err)
+	case errors.Is(err, services.ErrUploadsDisabled):
+		httputil.NewError(c, http.StatusForbidden, err)
+	case errors.Is(err, database.ErrBookUploadNotFound):
+		httputil.NewError(c, http.StatusNotFound, err)
+	case errors.Is(err, services.ErrUploadDuplicate),
+		errors.Is(err, database.ErrBookUploadClosed),
+		errors.Is(err, database.ErrBookUploadBookGone):
+		httputil.NewError(c, http.StatusConflict, err)
+	case errors.Is(err, services.ErrUploadTooLarge):
+		httputil.NewError(c, http.StatusRequestEntityTooLarge, err)
+	case errors.Is(err, services.ErrUploadFormat):
+		httputil.NewError(c, http.StatusUnsupportedMediaType, err)
+	case errors.Is(err, services.ErrUploadQuotaExceeded):
+		httputil.NewError(c, http.StatusTooManyRequests, err)
+	default:
+		httputil.NewError(c, http.StatusInternalServerError, err)
+	}
+}
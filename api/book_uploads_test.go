@@ -0,0 +1,196 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"gopds-api/database"
+	"gopds-api/models"
+	"gopds-api/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeBookUploads is an in-memory BookUploads and BookUploadsAdmin in which
+// the library has "picnic.fb2", as book 1, and takes every other .fb2 and
+// .epub.
+type fakeBookUploads struct {
+	uploads []*models.BookUpload
+	filter  models.BookUploadFilter
+	content []byte
+}
+
+func (f *fakeBookUploads) Upload(ctx context.Context, userID int64, fileName string, content []byte) (*models.BookUpload, error) {
+	switch {
+	case fileName == "picnic.fb2":
+		return nil, &models.BookInLibraryError{BookID: 1}
+	case strings.HasSuffix(fileName, ".pdf"):
+		return nil, services.ErrUploadFormat
+	}
+	f.content = content
+	bookID := int64(len(f.uploads) + 10)
+	upload := &models.BookUpload{
+		ID: int64(len(f.uploads) + 1), UserID: &userID, BookID: &bookID,
+		FileName: fileName, Size: int64(len(content)), Status: models.UploadPending,
+	}
+	f.uploads = append(f.uploads, upload)
+	return upload, nil
+}
+
+func (f *fakeBookUploads) List(
+	ctx context.Context, filter models.BookUploadFilter, page, pageSize int,
+) ([]models.BookUploadView, int, error) {
+	if filter.Status != "" && !models.IsUploadStatus(filter.Status) {
+		return nil, 0, models.ErrUploadInvalid
+	}
+	f.filter = filter
+	var list []models.BookUploadView
+	for _, u := range f.uploads {
+		if filter.Status == "" || u.Status == filter.Status {
+			list = append(list, models.BookUploadView{BookUpload: *u})
+		}
+	}
+	return list, len(list), nil
+}
+
+func (f *fakeBookUploads) Moderate(
+	ctx context.Context, id int64, decision models.UploadModeration, moderatorID *int64,
+) (before, after *models.BookUpload, err error) {
+	if err := decision.Validate(); err != nil {
+		return nil, nil, err
+	}
+	var upload *models.BookUpload
+	for _, u := range f.uploads {
+		if u.ID == id {
+			upload = u
+		}
+	}
+	switch {
+	case upload == nil:
+		return nil, nil, database.ErrBookUploadNotFound
+	case upload.Status != models.UploadPending:
+		return nil, nil, database.ErrBookUploadClosed
+	}
+	was := *upload
+	upload.Status, upload.Reason = decision.Status, decision.Reason
+	now := *upload
+	return &was, &now, nil
+}
+
+func newBookUploadsTestRouter(svc *fakeBookUploads, maxBytes int64) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	setUser := func(c *gin.Context) { c.Set("user_id", int64(7)) }
+	(&BookUploadsHandler{Svc: svc, MaxBytes: maxBytes}).Register(r.Group("/api/uploads", setUser))
+	(&BookUploadsAdminHandler{Svc: svc}).Register(r.Group("/api/admin/uploads", setUser))
+	return r
+}
+
+// doUpload posts content as the file of an upload form, with the CSRF
+// token the endpoint wants.
+func doUpload(t *testing.T, r http.Handler, fileName string, content []byte) *httptest.ResponseRecorder {
+	t.Helper()
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	fw, err := w.CreateFormFile("file", fileName)
+	require.NoError(t, err)
+	_, err = fw.Write(content)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	req := httptest.NewRequest(http.MethodPost, "/api/uploads", &body)
+	req.Header.Set("Content-Type", w.FormDataContentType())
+	req.Header.Set("X-CSRF-Token", "t")
+	req.AddCookie(&http.Cookie{Name: "csrf_token", Value: "t"})
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	return rec
+}
+
+func decodeBookUploads(t *testing.T, body []byte) BookUploadsAnswer {
+	t.Helper()
+	var answer BookUploadsAnswer
+	require.NoError(t, json.Unmarshal(body, &answer))
+	return answer
+}
+
+func TestBookUploads_UploadAndModerate(t *testing.T) {
+	svc := &fakeBookUploads{}
+	r := newBookUploadsTestRouter(svc, 1<<10)
+
+	rec := doUpload(t, r, "snail.fb2", []byte("<FictionBook/>"))
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	assert.Equal(t, "<FictionBook/>", string(svc.content))
+	rec = doUpload(t, r, "beetle.fb2", []byte("<FictionBook/>"))
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+	rec = doJSON(t, r, http.MethodGet, "/api/uploads", nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Len(t, decodeBookUploads(t, rec.Body.Bytes()).Uploads, 2)
+	assert.Equal(t, models.BookUploadFilter{UserID: 7}, svc.filter, "a reader lists their own uploads")
+
+	rec = doJSON(t, r, http.MethodPost, "/api/admin/uploads/1/moderate", json.RawMessage(`{"status":"approved"}`))
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	rec = doJSON(t, r, http.MethodPost, "/api/admin/uploads/2/moderate",
+		json.RawMessage(`{"status":"rejected","reason":"  a duplicate edition  "}`))
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, "a duplicate edition", svc.uploads[1].Reason)
+
+	rec = doJSON(t, r, http.MethodGet, "/api/admin/uploads", nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, `{"uploads":[],"total":0}`, rec.Body.String(), "the queue lists pending uploads by default")
+	assert.Equal(t, models.BookUploadFilter{Status: models.UploadPending}, svc.filter)
+	rec = doJSON(t, r, http.MethodGet, "/api/admin/uploads?status=rejected", nil)
+	assert.Len(t, decodeBookUploads(t, rec.Body.Bytes()).Uploads, 1)
+}
+
+func TestBookUploads_Refusals(t *testing.T) {
+	svc := &fakeBookUploads{}
+	r := newBookUploadsTestRouter(svc, 1<<10)
+
+	rec := doJSON(t, r, http.MethodPost, "/api/uploads", nil)
+	assert.Equal(t, http.StatusForbidden, rec.Code, "the CSRF check comes first")
+	rec = doCSRF(t, r, http.MethodPost, "/api/uploads", `{}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code, "no file")
+
+	rec = doUpload(t, r, "big.fb2", bytes.Repeat([]byte("a"), 2<<10))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+	rec = doUpload(t, r, "huge.fb2", bytes.Repeat([]byte("a"), 256<<10))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code, "a body over the form allowance is cut off")
+	rec = doUpload(t, r, "book.pdf", []byte("%PDF"))
+	assert.Equal(t, http.StatusUnsupportedMediaType, rec.Code)
+	rec = doUpload(t, r, "picnic.fb2", []byte("<FictionBook/>"))
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"book_id":1`, "the answer names the book the library has")
+	assert.Empty(t, svc.uploads)
+
+	rec = doUpload(t, r, "snail.fb2", []byte("<FictionBook/>"))
+	require.Equal(t, http.StatusCreated, rec.Code)
+	for _, tc := range []struct {
+		name, path, body string
+		want             int
+	}{
+		{"not an upload id", "/api/admin/uploads/x/moderate", `{"status":"approved"}`, http.StatusBadRequest},
+		{"no decision", "/api/admin/uploads/1/moderate", `{}`, http.StatusBadRequest},
+		{"pending is no decision", "/api/admin/uploads/1/moderate", `{"status":"pending"}`, http.StatusBadRequest},
+		{"unknown upload", "/api/admin/uploads/9/moderate", `{"status":"approved"}`, http.StatusNotFound},
+	} {
+		rec := doJSON(t, r, http.MethodPost, tc.path, json.RawMessage(tc.body))
+		assert.Equal(t, tc.want, rec.Code, tc.name)
+	}
+	rec = doJSON(t, r, http.MethodPost, "/api/admin/uploads/1/moderate", json.RawMessage(`{"status":"rejected"}`))
+	require.Equal(t, http.StatusOK, rec.Code)
+	rec = doJSON(t, r, http.MethodPost, "/api/admin/uploads/1/moderate", json.RawMessage(`{"status":"approved"}`))
+	assert.Equal(t, http.StatusConflict, rec.Code, "an upload is decided once")
+
+	rec = doJSON(t, r, http.MethodGet, "/api/uploads?status=lost", nil)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
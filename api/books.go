@@ -19,6 +19,15 @@ import (
 type ExportAnswer struct {
 	Books  []models.Book `json:"books"`
 	Length int           `json:"length"`
+	// Rewrite names the query the books were found for when it is not the
+	// one asked.
+	Rewrite *models.QueryRewrite `json:"rewrite,omitempty"`
+	// DidYouMean lists corrected queries that find books, best first, when
+	// the one asked found none.
+	DidYouMean []models.SpellingSuggestion `json:"did_you_mean,omitempty"`
+	// Facets counts a search's results by what the list can be filtered by,
+	// when the search asked for them.
+	Facets *models.BookFacets `json:"facets,omitempty"`
 }
 
 // langsAnswer struct for languages list response
@@ -2,6 +2,7 @@ package api
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
@@ -36,6 +37,23 @@ func SetupDuplicatesRoutes(r *gin.RouterGroup) {
 	r.GET("/duplicates/scan/:id", GetScanJobStatus)
 	r.GET("/duplicates", GetDuplicateGroups)
 	r.POST("/duplicates/hide", HideDuplicates)
+	r.GET("/duplicates/fuzzy", GetFuzzyDuplicates)
+	r.POST("/duplicates/fuzzy/:id/resolve", ResolveFuzzyDuplicate)
+	r.POST("/duplicates/fuzzy/:id/dismiss", DismissFuzzyDuplicate)
+}
+
+// duplicatePolicy returns the winner policy a request asked for, or the
+// configured one when it asked for none. ok is false for an unknown name.
+func duplicatePolicy(requested string) (services.DuplicateWinnerPolicy, bool) {
+	if requested != "" {
+		return services.ParseDuplicateWinnerPolicy(requested)
+	}
+	policy, ok := services.ParseDuplicateWinnerPolicy(viper.GetString("duplicates.winner_policy"))
+	if !ok {
+		logging.Warnf("Unknown duplicates.winner_policy %q, keeping the highest ID", viper.GetString("duplicates.winner_policy"))
+		return services.WinnerHighestID, true
+	}
+	return policy, true
 }
 
 // ScanJobResponse represents the response when starting a scan
@@ -106,13 +124,19 @@ func StartDuplicateScan(c *gin.Context) {
 	scanCtx, cancel := context.WithCancel(context.Background())
 	services.RegisterScanCancel(job.ID, cancel)
 
+	threshold := viper.GetFloat64("duplicates.fuzzy_threshold")
+	if threshold <= 0 || threshold > 1 {
+		threshold = services.DefaultFuzzyDuplicateThreshold
+	}
+
 	go func() {
-		err := services.ScanDuplicates(scanCtx, db, job.ID, wsConn, filesPath, req.Workers)
+		err := services.ScanDuplicates(scanCtx, db, job.ID, wsConn, filesPath, req.Workers, threshold)
 		if err != nil {
 			logging.Errorf("Scan job %d failed: %v", job.ID, err)
 		}
 		services.UnregisterScanCancel(job.ID)
 	}()
+	recordAudit(c, models.AuditDuplicateScanStart, "duplicate_scan", job.ID, nil, gin.H{"workers": req.Workers})
 
 	c.JSON(http.StatusOK, ScanJobResponse{
 		JobID: job.ID,
@@ -158,6 +182,7 @@ func StopDuplicateScan(c *gin.Context) {
 		httputil.NewError(c, http.StatusInternalServerError, err)
 		return
 	}
+	recordAudit(c, models.AuditDuplicateScanStop, "duplicate_scan", jobID, nil, gin.H{"status": "scan_cancelled"})
 
 	c.JSON(http.StatusOK, models.Result{Result: "scan_cancelled", Error: nil})
 }
@@ -215,6 +240,8 @@ func ForceStopDuplicateScan(c *gin.Context) {
 		httputil.NewError(c, http.StatusInternalServerError, err)
 		return
 	}
+	recordAudit(c, models.AuditDuplicateScanStop, "duplicate_scan", jobID,
+		gin.H{"status": job.Status}, gin.H{"status": "scan_force_stopped"})
 
 	c.JSON(http.StatusOK, models.Result{Result: "scan_force_stopped", Error: nil})
 }
@@ -322,14 +349,21 @@ func GetDuplicateGroups(c *gin.Context) {
 	})
 }
 
-// HideDuplicates hides duplicate books based on the newest ID rule
+// HideDuplicatesRequest optionally overrides the configured winner policy
+type HideDuplicatesRequest struct {
+	Policy string `json:"policy" example:"best_metadata"`
+}
+
+// HideDuplicates hides duplicate books keeping one per group by the winner policy
 // @Summary Hide duplicate books
-// @Description Hide duplicate books keeping the newest (highest ID)
+// @Description Hide duplicate books, keeping in each group the one the winner policy picks: highest_id (default), newest, largest, best_metadata or has_cover
 // @Tags admin
 // @Param Authorization header string true "Token without 'Bearer' prefix"
+// @Param body body HideDuplicatesRequest false "Winner policy"
 // @Accept json
 // @Produce json
 // @Success 200 {object} services.HideResult
+// @Failure 400 {object} httputil.HTTPError
 // @Failure 403 {object} httputil.HTTPError
 // @Failure 500 {object} httputil.HTTPError
 // @Router /api/admin/duplicates/hide [post]
@@ -337,16 +371,219 @@ func HideDuplicates(c *gin.Context) {
 	db := getDB()
 	ctx := context.Background()
 
-	result, err := services.HideDuplicates(ctx, db)
+	var req HideDuplicatesRequest
+	_ = c.ShouldBindJSON(&req) // the body is optional
+	policy, ok := duplicatePolicy(req.Policy)
+	if !ok {
+		httputil.NewError(c, http.StatusBadRequest, fmt.Errorf("unknown winner policy"))
+		return
+	}
+
+	result, err := services.HideDuplicates(ctx, db, policy, viper.GetString("app.files_path"))
 	if err != nil {
 		logging.Errorf("Failed to hide duplicates: %v", err)
 		httputil.NewError(c, http.StatusInternalServerError, err)
 		return
 	}
+	recordAudit(c, models.AuditDuplicatesHide, "duplicates", nil, nil, gin.H{"policy": policy, "result": result})
 
 	c.JSON(http.StatusOK, result)
 }
 
+// FuzzyDuplicateGroup is a near-duplicate group with its books, for review
+type FuzzyDuplicateGroup struct {
+	models.DuplicateCandidate
+	Books []models.Book `json:"books"`
+	// SuggestedWinnerID is the book the configured policy would keep; set
+	// for pending groups only.
+	SuggestedWinnerID int64 `json:"suggested_winner_id,omitempty"`
+}
+
+// FuzzyDuplicatesResponse represents one page of near-duplicate groups
+type FuzzyDuplicatesResponse struct {
+	Groups   []FuzzyDuplicateGroup `json:"groups"`
+	Total    int                   `json:"total"`
+	Page     int                   `json:"page"`
+	PageSize int                   `json:"page_size"`
+}
+
+// GetFuzzyDuplicates lists near-duplicate groups for review
+// @Summary Get near-duplicate groups
+// @Description Get the groups of books the last duplicate scan believes to be the same work without the same file, strongest first
+// @Tags admin
+// @Param Authorization header string true "Token without 'Bearer' prefix"
+// @Param status query string false "pending (default), resolved or dismissed"
+// @Param page query int false "Page number, from 1"
+// @Param page_size query int false "Groups per page, up to 200"
+// @Accept json
+// @Produce json
+// @Success 200 {object} FuzzyDuplicatesResponse
+// @Failure 400 {object} httputil.HTTPError
+// @Failure 403 {object} httputil.HTTPError
+// @Failure 500 {object} httputil.HTTPError
+// @Router /api/admin/duplicates/fuzzy [get]
+func GetFuzzyDuplicates(c *gin.Context) {
+	db := getDB()
+	ctx := c.Request.Context()
+
+	status := c.DefaultQuery("status", models.DuplicateCandidatePending)
+	switch status {
+	case models.DuplicateCandidatePending, models.DuplicateCandidateResolved, models.DuplicateCandidateDismissed:
+	default:
+		httputil.NewError(c, http.StatusBadRequest, fmt.Errorf("invalid status"))
+		return
+	}
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "50"))
+
+	candidates, total, err := services.ListDuplicateCandidates(ctx, db, status, page, pageSize)
+	if err != nil {
+		logging.Errorf("Failed to fetch near-duplicate groups: %v", err)
+		httputil.NewError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	// One query for the books of the whole page.
+	var ids []int64
+	for _, candidate := range candidates {
+		ids = append(ids, candidate.BookIDs...)
+	}
+	byID := make(map[int64]models.Book, len(ids))
+	if len(ids) > 0 {
+		var books []models.Book
+		err = db.ModelContext(ctx, &books).
+			Relation("Authors").
+			Relation("Series").
+			WhereIn("book.id IN (?)", ids).
+			Select()
+		if err != nil {
+			logging.Errorf("Failed to fetch books of near-duplicate groups: %v", err)
+			httputil.NewError(c, http.StatusInternalServerError, err)
+			return
+		}
+		for _, book := range books {
+			byID[book.ID] = book
+		}
+	}
+
+	policy, _ := duplicatePolicy("")
+	filesPath := viper.GetString("app.files_path")
+	groups := make([]FuzzyDuplicateGroup, 0, len(candidates))
+	for _, candidate := range candidates {
+		group := FuzzyDuplicateGroup{DuplicateCandidate: candidate, Books: []models.Book{}}
+		for _, id := range candidate.BookIDs {
+			if book, ok := byID[id]; ok {
+				group.Books = append(group.Books, book)
+			}
+		}
+		if candidate.Status == models.DuplicateCandidatePending {
+			winner, err := services.SuggestDuplicateWinner(ctx, db, candidate.BookIDs, policy, filesPath)
+			if err != nil {
+				logging.Warnf("No suggested winner for near-duplicate group %d: %v", candidate.ID, err)
+			}
+			group.SuggestedWinnerID = winner
+		}
+		groups = append(groups, group)
+	}
+
+	c.JSON(http.StatusOK, FuzzyDuplicatesResponse{
+		Groups:   groups,
+		Total:    total,
+		Page:     page,
+		PageSize: pageSize,
+	})
+}
+
+// ResolveFuzzyDuplicateRequest names the book to keep, or the policy to pick it
+type ResolveFuzzyDuplicateRequest struct {
+	WinnerID int64  `json:"winner_id" example:"42"`
+	Policy   string `json:"policy" example:"has_cover"`
+}
+
+// ResolveFuzzyDuplicate accepts a near-duplicate group
+// @Summary Resolve near-duplicate group
+// @Description Hide every book of a near-duplicate group but one: winner_id when given, otherwise the one the policy (or the configured policy) picks
+// @Tags admin
+// @Param Authorization header string true "Token without 'Bearer' prefix"
+// @Param id path int true "Group ID"
+// @Param body body ResolveFuzzyDuplicateRequest false "Winner"
+// @Accept json
+// @Produce json
+// @Success 200 {object} models.DuplicateCandidate
+// @Failure 400 {object} httputil.HTTPError
+// @Failure 403 {object} httputil.HTTPError
+// @Failure 404 {object} httputil.HTTPError
+// @Failure 409 {object} httputil.HTTPError "Group already reviewed"
+// @Failure 500 {object} httputil.HTTPError
+// @Router /api/admin/duplicates/fuzzy/{id}/resolve [post]
+func ResolveFuzzyDuplicate(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		httputil.NewError(c, http.StatusBadRequest, fmt.Errorf("invalid group ID"))
+		return
+	}
+	var req ResolveFuzzyDuplicateRequest
+	_ = c.ShouldBindJSON(&req) // the body is optional
+	policy, ok := duplicatePolicy(req.Policy)
+	if !ok {
+		httputil.NewError(c, http.StatusBadRequest, fmt.Errorf("unknown winner policy"))
+		return
+	}
+
+	candidate, err := services.ResolveDuplicateCandidate(c.Request.Context(), getDB(), id, req.WinnerID, policy, viper.GetString("app.files_path"))
+	if err != nil {
+		duplicateCandidateError(c, err)
+		return
+	}
+	recordAudit(c, models.AuditDuplicateResolve, "duplicate_group", id, nil, candidate)
+	c.JSON(http.StatusOK, candidate)
+}
+
+// DismissFuzzyDuplicate rejects a near-duplicate group
+// @Summary Dismiss near-duplicate group
+// @Description Mark a near-duplicate group as not duplicates; later scans do not propose the same books again
+// @Tags admin
+// @Param Authorization header string true "Token without 'Bearer' prefix"
+// @Param id path int true "Group ID"
+// @Accept json
+// @Produce json
+// @Success 200 {object} models.DuplicateCandidate
+// @Failure 400 {object} httputil.HTTPError
+// @Failure 403 {object} httputil.HTTPError
+// @Failure 404 {object} httputil.HTTPError
+// @Failure 409 {object} httputil.HTTPError "Group already reviewed"
+// @Failure 500 {object} httputil.HTTPError
+// @Router /api/admin/duplicates/fuzzy/{id}/dismiss [post]
+func DismissFuzzyDuplicate(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		httputil.NewError(c, http.StatusBadRequest, fmt.Errorf("invalid group ID"))
+		return
+	}
+
+	candidate, err := services.DismissDuplicateCandidate(c.Request.Context(), getDB(), id)
+	if err != nil {
+		duplicateCandidateError(c, err)
+		return
+	}
+	recordAudit(c, models.AuditDuplicateDismiss, "duplicate_group", id, nil, candidate)
+	c.JSON(http.StatusOK, candidate)
+}
+
+func duplicateCandidateError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrCandidateNotFound):
+		httputil.NewError(c, http.StatusNotFound, err)
+	case errors.Is(err, services.ErrCandidateReviewed):
+		httputil.NewError(c, http.StatusConflict, err)
+	case errors.Is(err, services.ErrWinnerNotInGroup):
+		httputil.NewError(c, http.StatusBadRequest, err)
+	default:
+		logging.Errorf("Failed to review near-duplicate group: %v", err)
+		httputil.NewError(c, http.StatusInternalServerError, err)
+	}
+}
+
 // getDB returns the database connection
 func getDB() *pg.DB {
 	// This assumes database.SetDB was called during initialization
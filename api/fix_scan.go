@@ -12,6 +12,7 @@ import (
 	"gopds-api/httputil"
 	"gopds-api/llm"
 	"gopds-api/logging"
+	"gopds-api/models"
 	"gopds-api/services"
 
 	"github.com/gin-gonic/gin"
@@ -216,6 +217,7 @@ func StartFixScan(c *gin.Context) {
 	}
 
 	go runFixScan(ctx, sessionID, workers)
+	recordAudit(c, models.AuditScanFixStart, "fix_scan", sessionID, nil, gin.H{"workers": workers})
 
 	c.JSON(http.StatusOK, StartScanResponse{
 		SessionID: sessionID,
@@ -248,6 +250,7 @@ func GetFixScanStatus(c *gin.Context) {
 // @Router /api/admin/scan/fix/cancel [post]
 func CancelFixScan(c *gin.Context) {
 	if fixState.cancel() {
+		recordAudit(c, models.AuditScanFixCancel, "fix_scan", nil, nil, nil)
 		c.JSON(http.StatusOK, gin.H{"message": "fix scan cancellation requested"})
 	} else {
 		httputil.NewError(c, http.StatusNotFound, errors.New("no fix scan running"))
@@ -14,10 +14,29 @@ import (
 	"gopds-api/models"
 
 	"github.com/gin-gonic/gin"
+	"github.com/go-pg/pg/v10"
 )
 
 type updateGenreTitleRequest struct {
-	Title string `json:"title" binding:"required"`
+	Title   string  `json:"title" binding:"required"`
+	TitleEn *string `json:"title_en"`
+}
+
+type moveGenreRequest struct {
+	SectionID *int64 `json:"section_id"`
+	Position  int    `json:"position"`
+}
+
+type genreSectionRequest struct {
+	Code     string `json:"code" binding:"max=64"`
+	Title    string `json:"title" binding:"required,max=128"`
+	TitleEn  string `json:"title_en" binding:"max=128"`
+	Position int    `json:"position"`
+}
+
+type genreAliasRequest struct {
+	Alias   string `json:"alias" binding:"required,max=128"`
+	GenreID int64  `json:"genre_id" binding:"required"`
 }
 
 // GetGenres returns all genres with raw id/genre/title fields.
@@ -33,7 +52,8 @@ func GetGenres(c *gin.Context) {
 	})
 }
 
-// UpdateGenreTitle updates a single genre's title.
+// UpdateGenreTitle updates a single genre's title and, when given, its
+// English title.
 func UpdateGenreTitle(c *gin.Context) {
 	idStr := c.Param("id")
 	id, err := strconv.ParseInt(idStr, 10, 64)
@@ -48,10 +68,12 @@ func UpdateGenreTitle(c *gin.Context) {
 		return
 	}
 
-	if err := database.UpdateGenreTitle(id, req.Title); err != nil {
-		httputil.NewError(c, http.StatusInternalServerError, err)
+	before := auditGenre(c, id)
+	if err := database.UpdateGenreNames(c.Request.Context(), id, req.Title, req.TitleEn); err != nil {
+		respondGenreError(c, err)
 		return
 	}
+	recordAudit(c, models.AuditGenreUpdate, "genre", id, before, auditGenre(c, id))
 
 	c.JSON(http.StatusOK, models.Result{
 		Result: "ok",
@@ -59,9 +81,188 @@ func UpdateGenreTitle(c *gin.Context) {
 	})
 }
 
+// GenreTree returns the genre taxonomy for the reader's interface language
+// (or the lang query parameter): sections with their genres and book counts.
+// Genres without books are left out.
+// Auth godoc
+// @Summary Retrieve the genre taxonomy
+// @Description Sections of genres with localized names and book counts
+// @Param Authorization header string true "Token without 'Bearer' prefix"
+// @Param  lang query string false "Interface language (ru, en)"
+// @Tags books
+// @Produce  json
+// @Success 200 {object} database.GenreTree "Genre tree"
+// @Failure 500 {object} httputil.HTTPError "Internal server error"
+// @Router /api/books/genres [get]
+func GenreTree(c *gin.Context) {
+	tree, err := database.GetGenreTree(c.Request.Context(), interfaceLang(c), false)
+	if err != nil {
+		httputil.NewError(c, http.StatusInternalServerError, err)
+		return
+	}
+	c.JSON(http.StatusOK, tree)
+}
+
+// interfaceLang is the language names are given in: the lang query
+// parameter, else the signed-in user's interface language.
+func interfaceLang(c *gin.Context) string {
+	if lang := c.Query("lang"); lang != "" {
+		return lang
+	}
+	return database.UserInterfaceLang(c.GetInt64("user_id"))
+}
+
+// GetGenreTreeAdmin returns the whole taxonomy, empty genres included, with
+// the genres no section claims.
+func GetGenreTreeAdmin(c *gin.Context) {
+	tree, err := database.GetGenreTree(c.Request.Context(), interfaceLang(c), true)
+	if err != nil {
+		httputil.NewError(c, http.StatusInternalServerError, err)
+		return
+	}
+	c.JSON(http.StatusOK, models.Result{Result: tree})
+}
+
+// MoveGenre puts a genre in a section, or in none when section_id is null.
+func MoveGenre(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		httputil.NewError(c, http.StatusBadRequest, errors.New("invalid_genre_id"))
+		return
+	}
+	var req moveGenreRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		httputil.NewError(c, http.StatusBadRequest, errors.New("invalid_request_body"))
+		return
+	}
+	before := auditGenre(c, id)
+	if err := database.MoveGenre(c.Request.Context(), id, req.SectionID, req.Position); err != nil {
+		respondGenreError(c, err)
+		return
+	}
+	recordAudit(c, models.AuditGenreMove, "genre", id, before, auditGenre(c, id))
+	c.JSON(http.StatusOK, models.Result{Result: "ok"})
+}
+
+// CreateGenreSection adds a section to the taxonomy.
+func CreateGenreSection(c *gin.Context) {
+	var req genreSectionRequest
+	if err := c.ShouldBindJSON(&req); err != nil || strings.TrimSpace(req.Code) == "" {
+		httputil.NewError(c, http.StatusBadRequest, errors.New("invalid_request_body"))
+		return
+	}
+	section := &models.GenreSection{Code: req.Code, Title: req.Title, TitleEn: req.TitleEn, Position: req.Position}
+	if err := database.CreateGenreSection(c.Request.Context(), section); err != nil {
+		respondGenreError(c, err)
+		return
+	}
+	recordAudit(c, models.AuditGenreSectionCreate, "genre_section", section.ID, nil, section)
+	c.JSON(http.StatusCreated, models.Result{Result: section})
+}
+
+// UpdateGenreSection renames or moves a section. Its code stays.
+func UpdateGenreSection(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		httputil.NewError(c, http.StatusBadRequest, errors.New("invalid_section_id"))
+		return
+	}
+	var req genreSectionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		httputil.NewError(c, http.StatusBadRequest, errors.New("invalid_request_body"))
+		return
+	}
+	before, err := database.GetGenreSectionRow(c.Request.Context(), id)
+	if err != nil {
+		respondGenreError(c, err)
+		return
+	}
+	section := &models.GenreSection{ID: id, Code: before.Code, Title: req.Title, TitleEn: req.TitleEn, Position: req.Position}
+	if err := database.UpdateGenreSection(c.Request.Context(), section); err != nil {
+		respondGenreError(c, err)
+		return
+	}
+	recordAudit(c, models.AuditGenreSectionUpdate, "genre_section", id, before, section)
+	c.JSON(http.StatusOK, models.Result{Result: "ok"})
+}
+
+// DeleteGenreSection removes a section; its genres are left in none.
+func DeleteGenreSection(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		httputil.NewError(c, http.StatusBadRequest, errors.New("invalid_section_id"))
+		return
+	}
+	section, err := database.DeleteGenreSection(c.Request.Context(), id)
+	if err != nil {
+		respondGenreError(c, err)
+		return
+	}
+	recordAudit(c, models.AuditGenreSectionDelete, "genre_section", id, section, nil)
+	c.JSON(http.StatusOK, models.Result{Result: "ok"})
+}
+
+// GetGenreAliases lists the tags mapped to genres.
+func GetGenreAliases(c *gin.Context) {
+	aliases, err := database.ListGenreAliases(c.Request.Context())
+	if err != nil {
+		httputil.NewError(c, http.StatusInternalServerError, err)
+		return
+	}
+	c.JSON(http.StatusOK, models.Result{Result: aliases})
+}
+
+// SetGenreAlias maps a tag to a genre. Books already filed under the tag
+// move to the genre.
+func SetGenreAlias(c *gin.Context) {
+	var req genreAliasRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		httputil.NewError(c, http.StatusBadRequest, errors.New("invalid_request_body"))
+		return
+	}
+	var before any
+	if previous, err := database.GetGenreAlias(c.Request.Context(), req.Alias); err == nil {
+		before = previous
+	}
+	alias, err := database.SetGenreAlias(c.Request.Context(), req.Alias, req.GenreID)
+	if err != nil {
+		respondGenreError(c, err)
+		return
+	}
+	recordAudit(c, models.AuditGenreAliasSet, "genre_alias", alias.Alias, before, alias)
+	c.JSON(http.StatusOK, models.Result{Result: alias})
+}
+
+// DeleteGenreAlias removes a tag mapping.
+func DeleteGenreAlias(c *gin.Context) {
+	alias, err := database.DeleteGenreAlias(c.Request.Context(), c.Param("alias"))
+	if err != nil {
+		respondGenreError(c, err)
+		return
+	}
+	recordAudit(c, models.AuditGenreAliasDelete, "genre_alias", alias.Alias, alias, nil)
+	c.JSON(http.StatusOK, models.Result{Result: "ok"})
+}
+
+// respondGenreError maps taxonomy errors to HTTP statuses: missing rows →
+// 404, a taken section code → 409, an alias of a genre's own tag → 400.
+func respondGenreError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, database.ErrGenreNotFound), errors.Is(err, database.ErrGenreSectionNotFound), errors.Is(err, pg.ErrNoRows):
+		httputil.NewError(c, http.StatusNotFound, err)
+	case errors.Is(err, database.ErrGenreSectionTaken):
+		httputil.NewError(c, http.StatusConflict, err)
+	case errors.Is(err, database.ErrGenreAliasOfItself):
+		httputil.NewError(c, http.StatusBadRequest, err)
+	default:
+		httputil.NewError(c, http.StatusInternalServerError, err)
+	}
+}
+
 // GenerateGenreTitles launches async genre title generation via LLM.
 func GenerateGenreTitles(c *gin.Context) {
 	go runGenreTitleGeneration()
+	recordAudit(c, models.AuditGenreTitlesGenerate, "genre", nil, nil, nil)
 	c.JSON(http.StatusAccepted, models.Result{
 		Result: "generation_started",
 		Error:  nil,
@@ -0,0 +1,59 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"gopds-api/database"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// The taxonomy handlers reject malformed requests before they reach the
+// database, so these run without one.
+func TestGenreTaxonomyHandlers_RejectBadInput(t *testing.T) {
+	r := gin.New()
+	r.PUT("/genres/:id/section", MoveGenre)
+	r.PUT("/genres/sections/:id", UpdateGenreSection)
+	r.POST("/genres/sections", CreateGenreSection)
+	r.POST("/genres/aliases", SetGenreAlias)
+
+	cases := []struct {
+		name, method, path string
+		body               any
+	}{
+		{"move with a bad id", http.MethodPut, "/genres/x/section", map[string]any{"position": 1}},
+		{"section with a bad id", http.MethodPut, "/genres/sections/x", map[string]any{"title": "T"}},
+		{"section without a code", http.MethodPost, "/genres/sections", map[string]any{"title": "T"}},
+		{"section without a title", http.MethodPost, "/genres/sections", map[string]any{"code": "c"}},
+		{"alias without a genre", http.MethodPost, "/genres/aliases", map[string]any{"alias": "fantasy"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := doJSON(t, r, tc.method, tc.path, tc.body)
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+		})
+	}
+}
+
+func TestRespondGenreError(t *testing.T) {
+	cases := []struct {
+		err  error
+		want int
+	}{
+		{database.ErrGenreNotFound, http.StatusNotFound},
+		{database.ErrGenreSectionNotFound, http.StatusNotFound},
+		{database.ErrGenreSectionTaken, http.StatusConflict},
+		{database.ErrGenreAliasOfItself, http.StatusBadRequest},
+		{errors.New("boom"), http.StatusInternalServerError},
+	}
+	for _, tc := range cases {
+		rec := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(rec)
+		respondGenreError(c, tc.err)
+		assert.Equal(t, tc.want, rec.Code, tc.err.Error())
+	}
+}
@@ -0,0 +1,291 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"gopds-api/database"
+	"gopds-api/internal/oidc"
+	"gopds-api/logging"
+	"gopds-api/middlewares"
+	"gopds-api/models"
+	"gopds-api/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/spf13/viper"
+)
+
+// OIDCLogin is the service-layer view of logging in through an OpenID
+// provider.
+type OIDCLogin interface {
+	AuthCodeURL(ctx context.Context, state, nonce, verifier string) (string, error)
+	Exchange(ctx context.Context, code, verifier, nonce string) (*oidc.Claims, error)
+	Login(ctx context.Context, claims *oidc.Claims) (models.User, error)
+
+	Link(ctx context.Context, userID int64, claims *oidc.Claims) error
+	Identities(ctx context.Context, userID int64) ([]models.UserIdentity, error)
+	Unlink(ctx context.Context, userID, id int64) error
+}
+
+const (
+	// oidcFlowCookie carries a login from its start to the callback.
+	oidcFlowCookie = "oidc_flow"
+	// oidcFlowTTL is how long someone has to get through the provider's
+	// login page.
+	oidcFlowTTL = 10 * time.Minute
+)
+
+// OIDCHandler binds OIDCLogin to gin routes.
+type OIDCHandler struct {
+	Svc OIDCLogin
+	// Label names the provider on the login page.
+	Label string
+	// Secret signs the flow cookie.
+	Secret []byte
+
+	// openSession replaces the package's openSession in tests.
+	openSession func(c *gin.Context, user models.User, twoFactor bool) (models.LoggedInUser, error)
+}
+
+// oidcFlow is what the flow cookie holds: the values the provider must
+// send back, the PKCE verifier, where to go afterwards, and for a link,
+// whose account the identity goes to.
+type oidcFlow struct {
+	State      string `json:"state"`
+	Nonce      string `json:"nonce"`
+	Verifier   string `json:"verifier"`
+	Next       string `json:"next,omitempty"`
+	LinkUserID int64  `json:"link_user_id,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// Register attaches the public login endpoints to the given group.
+func (h *OIDCHandler) Register(r *gin.RouterGroup) {
+	r.GET("", h.provider)
+	r.GET("/login", h.login)
+	r.GET("/callback", h.callback)
+}
+
+// RegisterAccount attaches the endpoints that manage the linked identities
+// of the logged-in user.
+// Caller is expected to have already wrapped the group with auth middleware.
+func (h *OIDCHandler) RegisterAccount(r *gin.RouterGroup) {
+	r.GET("/identities", h.identities)
+	r.POST("/link", middlewares.CSRFMiddleware(), h.link)
+	r.DELETE("/identities/:id", middlewares.CSRFMiddleware(), h.unlink)
+}
+
+// --- Handlers ---
+
+// provider tells the login page which provider to offer.
+func (h *OIDCHandler) provider(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"label": h.Label, "login_url": "/api/oidc/login"})
+}
+
+// login sends the browser to the provider.
+func (h *OIDCHandler) login(c *gin.Context) {
+	authURL, err := h.startFlow(c, oidcFlow{Next: localPath(c.Query("next"), "/")})
+	if err != nil {
+		logging.Errorf("OIDC login: %v", err)
+		h.redirectError(c, "unavailable")
+		return
+	}
+	c.Redirect(http.StatusFound, authURL)
+}
+
+type oidcLinkRequest struct {
+	Next string `json:"next"`
+}
+
+// link starts a login whose identity is linked to the current user. It
+// answers with the provider's URL rather than redirecting: it is called
+// with a CSRF header, which a navigation cannot carry.
+func (h *OIDCHandler) link(c *gin.Context) {
+	var req oidcLinkRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	userID := c.GetInt64("user_id")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "not logged in"})
+		return
+	}
+	authURL, err := h.startFlow(c, oidcFlow{Next: localPath(req.Next, "/"), LinkUserID: userID})
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"url": authURL})
+}
+
+// callback is where the provider sends the browser back. It logs the user
+// in, or links the identity, and sends the browser on into the interface;
+// failures go to the login page with oidc_error set.
+func (h *OIDCHandler) callback(c *gin.Context) {
+	flow, ok := h.takeFlow(c)
+	if !ok {
+		h.redirectError(c, "state")
+		return
+	}
+	if c.Query("error") != "" {
+		// The person declined, or the provider refused them.
+		h.redirectError(c, "denied")
+		return
+	}
+
+	ctx := c.Request.Context()
+	claims, err := h.Svc.Exchange(ctx, c.Query("code"), flow.Verifier, flow.Nonce)
+	if err != nil {
+		logging.Warnf("OIDC callback: %v", err)
+		h.redirectError(c, "exchange")
+		return
+	}
+
+	if flow.LinkUserID != 0 {
+		if err := h.Svc.Link(ctx, flow.LinkUserID, claims); err != nil {
+			h.redirectError(c, oidcErrorCode(err))
+			return
+		}
+		c.Redirect(http.StatusFound, flow.Next)
+		return
+	}
+
+	user, err := h.Svc.Login(ctx, claims)
+	if err != nil {
+		h.redirectError(c, oidcErrorCode(err))
+		return
+	}
+	if !user.Active {
+		h.redirectError(c, "inactive")
+		return
+	}
+	// A second factor is owed here as after a password: the login page
+	// asks for it and finishes the login.
+	challenge, err := twoFactorChallenge(ctx, user)
+	if err != nil {
+		logging.Errorf("OIDC login of user %d: %v", user.ID, err)
+		h.redirectError(c, "session")
+		return
+	}
+	if challenge != "" {
+		c.Redirect(http.StatusFound, "/login?two_factor="+url.QueryEscape(challenge))
+		return
+	}
+	open := h.openSession
+	if open == nil {
+		open = openSession
+	}
+	if _, err := open(c, user, false); err != nil {
+		logging.Errorf("OIDC login of user %d: %v", user.ID, err)
+		h.redirectError(c, "session")
+		return
+	}
+	c.Redirect(http.StatusFound, flow.Next)
+}
+
+func (h *OIDCHandler) identities(c *gin.Context) {
+	identities, err := h.Svc.Identities(c.Request.Context(), c.GetInt64("user_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, identities)
+}
+
+func (h *OIDCHandler) unlink(c *gin.Context) {
+	id, ok := parseInt64Param(c, "id")
+	if !ok {
+		return
+	}
+	err := h.Svc.Unlink(c.Request.Context(), c.GetInt64("user_id"), id)
+	switch {
+	case errors.Is(err, database.ErrIdentityNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	default:
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// --- Flow state ---
+
+// startFlow makes the state, nonce and verifier of a new login, keeps them
+// in the flow cookie, and returns the provider's URL.
+func (h *OIDCHandler) startFlow(c *gin.Context, flow oidcFlow) (string, error) {
+	flow.State, flow.Nonce, flow.Verifier = oidc.NewState(), oidc.NewState(), oidc.NewState()
+	flow.ExpiresAt = jwt.NewNumericDate(time.Now().Add(oidcFlowTTL))
+
+	authURL, err := h.Svc.AuthCodeURL(c.Request.Context(), flow.State, flow.Nonce, flow.Verifier)
+	if err != nil {
+		return "", err
+	}
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, flow).SignedString(h.Secret)
+	if err != nil {
+		return "", err
+	}
+	// Lax, not Strict: the callback is a navigation from the provider's
+	// site, and must bring the cookie along.
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcFlowCookie, signed, int(oidcFlowTTL.Seconds()), "/api/oidc", "", !viper.GetBool("app.devel_mode"), true)
+	return authURL, nil
+}
+
+// takeFlow reads and clears the flow cookie, and checks the state the
+// provider sent back against it.
+func (h *OIDCHandler) takeFlow(c *gin.Context) (*oidcFlow, bool) {
+	raw, err := c.Cookie(oidcFlowCookie)
+	if err != nil {
+		return nil, false
+	}
+	c.SetCookie(oidcFlowCookie, "", -1, "/api/oidc", "", !viper.GetBool("app.devel_mode"), true)
+
+	flow := &oidcFlow{}
+	parser := jwt.NewParser(jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	if _, err := parser.ParseWithClaims(raw, flow, func(*jwt.Token) (interface{}, error) {
+		return h.Secret, nil
+	}); err != nil {
+		return nil, false
+	}
+	if flow.State == "" || c.Query("state") != flow.State {
+		return nil, false
+	}
+	return flow, true
+}
+
+func (h *OIDCHandler) redirectError(c *gin.Context, code string) {
+	c.Redirect(http.StatusFound, "/login?oidc_error="+url.QueryEscape(code))
+}
+
+// oidcErrorCode names a login or link failure for the login page.
+func oidcErrorCode(err error) string {
+	switch {
+	case errors.Is(err, services.ErrNotProvisioned):
+		return "not_linked"
+	case errors.Is(err, services.ErrProvisioningDenied):
+		return "not_allowed"
+	case errors.Is(err, database.ErrEmailTaken):
+		return "email_taken"
+	case errors.Is(err, database.ErrIdentityTaken):
+		return "identity_taken"
+	}
+	logging.Errorf("OIDC: %v", err)
+	return "server"
+}
+
+// localPath returns p when it is a path on this site, and fallback
+// otherwise, so that the next parameter cannot send anyone elsewhere.
+func localPath(p, fallback string) string {
+	if !strings.HasPrefix(p, "/") || strings.HasPrefix(p, "//") || strings.ContainsAny(p, "\\\r\n") {
+		return fallback
+	}
+	return p
+}
@@ -0,0 +1,201 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"gopds-api/internal/oidc"
+	"gopds-api/internal/oidc/oidctest"
+	"gopds-api/models"
+	"gopds-api/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeOIDC talks to a real provider — the mock one — and keeps accounts
+// in memory.
+type fakeOIDC struct {
+	*oidc.Provider
+	users  map[string]models.User // by subject
+	linked map[string]int64       // subject → user id
+}
+
+func (f *fakeOIDC) Login(ctx context.Context, claims *oidc.Claims) (models.User, error) {
+	u, ok := f.users[claims.Subject]
+	if !ok {
+		return models.User{}, services.ErrNotProvisioned
+	}
+	return u, nil
+}
+func (f *fakeOIDC) Link(ctx context.Context, userID int64, claims *oidc.Claims) error {
+	f.linked[claims.Subject] = userID
+	return nil
+}
+func (f *fakeOIDC) Identities(ctx context.Context, userID int64) ([]models.UserIdentity, error) {
+	return nil, nil
+}
+func (f *fakeOIDC) Unlink(ctx context.Context, userID, id int64) error { return nil }
+
+type oidcTestEnv struct {
+	idp      *oidctest.Server
+	svc      *fakeOIDC
+	router   *gin.Engine
+	sessions []models.User
+}
+
+func newOIDCTestEnv(t *testing.T) *oidcTestEnv {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	env := &oidcTestEnv{idp: oidctest.NewServer("gopds", "secret")}
+	t.Cleanup(env.idp.Close)
+
+	env.svc = &fakeOIDC{
+		Provider: oidc.NewProvider(oidc.Config{
+			Issuer:       env.idp.Issuer(),
+			ClientID:     "gopds",
+			ClientSecret: "secret",
+			RedirectURL:  "http://books.test/api/oidc/callback",
+		}),
+		users:  map[string]models.User{},
+		linked: map[string]int64{},
+	}
+	h := &OIDCHandler{
+		Svc:    env.svc,
+		Secret: []byte("flow-secret"),
+		openSession: func(c *gin.Context, user models.User, twoFactor bool) (models.LoggedInUser, error) {
+			env.sessions = append(env.sessions, user)
+			return models.LoggedInUser{User: user.Login}, nil
+		},
+	}
+	env.router = gin.New()
+	h.Register(env.router.Group("/api/oidc"))
+	account := env.router.Group("/api/oidc", func(c *gin.Context) { c.Set("user_id", int64(7)) })
+	h.RegisterAccount(account)
+	return env
+}
+
+// callback replays the provider's redirect to the callback, with the
+// flow cookie the start of the login set.
+func (env *oidcTestEnv) callback(t *testing.T, authURL string, flowCookie *http.Cookie, state string) *httptest.ResponseRecorder {
+	t.Helper()
+	cb, err := env.idp.Approve(authURL)
+	require.NoError(t, err)
+	q := cb.Query()
+	if state != "" {
+		q.Set("state", state)
+	}
+	req := httptest.NewRequest(http.MethodGet, "/api/oidc/callback?"+q.Encode(), nil)
+	if flowCookie != nil {
+		req.AddCookie(flowCookie)
+	}
+	rec := httptest.NewRecorder()
+	env.router.ServeHTTP(rec, req)
+	return rec
+}
+
+// startLogin begins a login and returns the provider's URL and the flow
+// cookie.
+func (env *oidcTestEnv) startLogin(t *testing.T, next string) (string, *http.Cookie) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	env.router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/oidc/login?next="+url.QueryEscape(next), nil))
+	require.Equal(t, http.StatusFound, rec.Code)
+	cookies := rec.Result().Cookies()
+	require.Len(t, cookies, 1)
+	assert.True(t, cookies[0].HttpOnly)
+	return rec.Header().Get("Location"), cookies[0]
+}
+
+func TestOIDC_Login(t *testing.T) {
+	env := newOIDCTestEnv(t)
+	env.svc.users["user-1"] = models.User{ID: 3, Login: "anna", Active: true}
+
+	authURL, cookie := env.startLogin(t, "/books?page=2")
+	rec := env.callback(t, authURL, cookie, "")
+
+	require.Equal(t, http.StatusFound, rec.Code)
+	assert.Equal(t, "/books?page=2", rec.Header().Get("Location"))
+	require.Len(t, env.sessions, 1)
+	assert.Equal(t, "anna", env.sessions[0].Login)
+}
+
+func TestOIDC_LoginFailures(t *testing.T) {
+	env := newOIDCTestEnv(t)
+
+	// Nobody has linked user-1.
+	authURL, cookie := env.startLogin(t, "/")
+	rec := env.callback(t, authURL, cookie, "")
+	assert.Equal(t, "/login?oidc_error=not_linked", rec.Header().Get("Location"))
+
+	env.svc.users["user-1"] = models.User{ID: 3, Login: "anna"}
+	authURL, cookie = env.startLogin(t, "/")
+	rec = env.callback(t, authURL, cookie, "")
+	assert.Equal(t, "/login?oidc_error=inactive", rec.Header().Get("Location"))
+
+	env.svc.users["user-1"] = models.User{ID: 3, Login: "anna", Active: true}
+	authURL, cookie = env.startLogin(t, "/")
+	rec = env.callback(t, authURL, cookie, "forged-state")
+	assert.Equal(t, "/login?oidc_error=state", rec.Header().Get("Location"))
+
+	authURL, _ = env.startLogin(t, "/")
+	rec = env.callback(t, authURL, nil, "")
+	assert.Equal(t, "/login?oidc_error=state", rec.Header().Get("Location"))
+
+	assert.Empty(t, env.sessions)
+}
+
+func TestOIDC_LoginIgnoresForeignNext(t *testing.T) {
+	env := newOIDCTestEnv(t)
+	env.svc.users["user-1"] = models.User{ID: 3, Login: "anna", Active: true}
+
+	authURL, cookie := env.startLogin(t, "//evil.example.com/")
+	rec := env.callback(t, authURL, cookie, "")
+	assert.Equal(t, "/", rec.Header().Get("Location"))
+}
+
+func TestOIDC_Link(t *testing.T) {
+	env := newOIDCTestEnv(t)
+	env.idp.SetClaims(map[string]any{"sub": "ext-42"})
+
+	rec := doJSON(t, env.router, http.MethodPost, "/api/oidc/link", map[string]any{"next": "/profile"})
+	// The CSRF check comes first.
+	require.Equal(t, http.StatusForbidden, rec.Code)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/oidc/link", nil)
+	req.Header.Set("X-CSRF-Token", "t")
+	req.AddCookie(&http.Cookie{Name: "csrf_token", Value: "t"})
+	rec = httptest.NewRecorder()
+	env.router.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code, "body=%s", rec.Body.String())
+
+	var body struct {
+		URL string `json:"url"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	cookies := rec.Result().Cookies()
+	require.Len(t, cookies, 1)
+
+	rec = env.callback(t, body.URL, cookies[0], "")
+	require.Equal(t, http.StatusFound, rec.Code)
+	assert.Equal(t, "/", rec.Header().Get("Location"))
+	assert.Equal(t, int64(7), env.svc.linked["ext-42"])
+	assert.Empty(t, env.sessions, "linking does not log anyone in")
+}
+
+func TestLocalPath(t *testing.T) {
+	for p, want := range map[string]string{
+		"/books":                "/books",
+		"":                      "/",
+		"https://evil.example/": "/",
+		"//evil.example/":       "/",
+		"/\\evil.example/":      "/",
+	} {
+		assert.Equal(t, want, localPath(p, "/"), p)
+	}
+}
@@ -1,19 +1,32 @@
 package api
 
 import (
+	"errors"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 
 	assets "gopds-api"
 	"gopds-api/internal/safepath"
 	"gopds-api/logging"
+	"gopds-api/services"
 
 	"github.com/gin-gonic/gin"
 	"github.com/spf13/viper"
 )
 
+// coverThumbnails scales covers for the w parameter of Posters. Nil until
+// main sets it, in which case the full-size poster is served whatever width
+// was asked for.
+var coverThumbnails *services.CoverThumbnails
+
+// SetCoverThumbnails installs the thumbnail store Posters scales covers with.
+func SetCoverThumbnails(thumbnails *services.CoverThumbnails) {
+	coverThumbnails = thumbnails
+}
+
 // Posters serves a file from a path specified in the request.
 // It constructs the full path to the file by appending the request's relative path
 // to the base posters path defined in the application's configuration.
@@ -26,10 +39,15 @@ import (
 // For example, if the route is defined as "/book-posters/*filepath", the "*filepath" parameter
 // can be retrieved and used to construct the full path to the file.
 //
+// With a w query parameter the cover is scaled to that width — snapped to one
+// of posters.ThumbnailWidths — and sent as WebP or AVIF when the Accept
+// header asks for it and an encoder is installed, JPEG otherwise.
+//
 // @Summary Serve a file from the specified path
 // @Description Serve a file from the specified path, or a default image if not found
 // @Tags posters
 // @Param  filepath path string true "Relative file path"
+// @Param  w query int false "Thumbnail width in pixels, snapped to 96, 200 or 400"
 // @Produce  image/png
 // @Success 200 {file} file "Requested file"
 // @Failure 403 {object} httputil.HTTPError "Invalid file path"
@@ -65,6 +83,10 @@ func Posters(c *gin.Context) {
 		return
 	}
 
+	if width := c.Query("w"); width != "" && servePosterThumbnail(c, safePath, width) {
+		return
+	}
+
 	if _, err := os.Stat(fullPath); err == nil {
 		// #nosec G304 -- fullPath comes from safepath.Resolve above, which
 		// refuses anything outside the configured posters directory.
@@ -89,3 +111,41 @@ func Posters(c *gin.Context) {
 
 	c.Data(http.StatusOK, "image/png", asset)
 }
+
+// servePosterThumbnail answers with a scaled copy of the cover at relPath
+// and reports whether it did. When it did not — no thumbnail store, a width
+// that is not a number, a cover that is missing or does not decode — Posters
+// carries on as if no width had been asked for.
+func servePosterThumbnail(c *gin.Context, relPath, width string) bool {
+	if coverThumbnails == nil {
+		return false
+	}
+	w, err := strconv.Atoi(width)
+	if err != nil || w <= 0 {
+		return false
+	}
+	thumb, err := coverThumbnails.Thumbnail(relPath, w, c.GetHeader("Accept"))
+	if err != nil {
+		if !errors.Is(err, services.ErrNoCover) {
+			logging.Warnf("Cover thumbnail of %s at %d: %v", relPath, w, err)
+		}
+		return false
+	}
+	// #nosec G304 -- the path is built by CoverThumbnails inside its own
+	// directory from a path safepath.Resolve accepted.
+	f, err := os.Open(thumb.Path)
+	if err != nil {
+		logging.Warnf("Opening cover thumbnail %s: %v", thumb.Path, err)
+		return false
+	}
+	defer f.Close()
+
+	h := c.Writer.Header()
+	// The format depends on Accept, so a shared cache must keep one copy
+	// per Accept rather than hand a WebP to a client that asked for JPEG.
+	h.Set("Vary", "Accept")
+	h.Set("Content-Type", thumb.ContentType)
+	h.Set("ETag", thumb.ETag)
+	http.ServeContent(c.Writer, c.Request, "", thumb.ModTime, f)
+	return true
+}
@@ -0,0 +1,253 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"gopds-api/database"
+	"gopds-api/httputil"
+	"gopds-api/middlewares"
+	"gopds-api/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Ratings is the service-layer view of a reader's ratings and the reviews
+// other readers see.
+type Ratings interface {
+	Rate(ctx context.Context, userID, bookID int64, req models.RatingRequest) (*models.BookRating, error)
+	Rating(ctx context.Context, userID, bookID int64) (*models.BookRating, error)
+	Unrate(ctx context.Context, userID, bookID int64) error
+	Reviews(ctx context.Context, bookID int64, page, pageSize int) ([]models.BookReview, int, error)
+}
+
+// ReviewsAdmin is the service-layer view of review moderation.
+type ReviewsAdmin interface {
+	ReviewQueue(ctx context.Context, status string, page, pageSize int) ([]models.BookReview, int, error)
+	Moderate(
+		ctx context.Context, id int64, decision models.ReviewModeration, moderatorID *int64,
+	) (before, after *models.BookRating, err error)
+}
+
+// RatingsHandler binds Ratings to gin routes.
+type RatingsHandler struct {
+	Svc Ratings
+}
+
+// ReviewsAdminHandler binds ReviewsAdmin to gin routes.
+type ReviewsAdminHandler struct {
+	Svc ReviewsAdmin
+}
+
+// BookReviewsAnswer is a page of reviews and the number of reviews on
+// every page.
+type BookReviewsAnswer struct {
+	Reviews []models.BookReview `json:"reviews"`
+	Total   int                 `json:"total"`
+}
+
+// Register attaches the rating endpoints to the books group.
+// Caller is expected to have already wrapped the group with auth middleware.
+func (h *RatingsHandler) Register(r *gin.RouterGroup) {
+	r.GET("/:id/rating", h.Rating)
+	r.PUT("/:id/rating", middlewares.CSRFMiddleware(), h.Rate)
+	r.DELETE("/:id/rating", middlewares.CSRFMiddleware(), h.Unrate)
+	r.GET("/:id/reviews", h.Reviews)
+}
+
+// Register attaches the review moderation endpoints to the given group.
+// Caller is expected to have already wrapped the group with admin middleware.
+func (h *ReviewsAdminHandler) Register(r *gin.RouterGroup) {
+	r.GET("", h.queue)
+	r.POST("/:id/moderate", h.moderate)
+}
+
+// Rating returns the user's own rating of a book
+// Auth godoc
+// @Summary The user's rating of a book
+// @Description The stars the user gave a book and their review, with the review's moderation status
+// @Param Authorization header string true "Token without 'Bearer' prefix"
+// @Param  id path int true "Book ID"
+// @Tags books
+// @Produce  json
+// @Success 200 {object} models.BookRating "The user's rating"
+// @Failure 400 {object} httputil.HTTPError "Bad request"
+// @Failure 404 {object} httputil.HTTPError "Not rated"
+// @Failure 500 {object} httputil.HTTPError "Internal server error"
+// @Router /api/books/{id}/rating [get]
+func (h *RatingsHandler) Rating(c *gin.Context) {
+	bookID, ok := ratingBookID(c)
+	if !ok {
+		return
+	}
+	rating, err := h.Svc.Rating(c.Request.Context(), c.GetInt64("user_id"), bookID)
+	if err != nil {
+		respondRatingError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, rating)
+}
+
+// Rate stores the user's rating of a book
+// Auth godoc
+// @Summary Rate a book
+// @Description One to five stars and an optional review, replacing the user's earlier rating; a new or changed review waits for moderation
+// @Param Authorization header string true "Token without 'Bearer' prefix"
+// @Param  id path int true "Book ID"
+// @Param  body body models.RatingRequest true "Stars and review"
+// @Tags books
+// @Accept  json
+// @Produce  json
+// @Success 200 {object} models.BookRating "The stored rating"
+// @Failure 400 {object} httputil.HTTPError "Bad request"
+// @Failure 404 {object} httputil.HTTPError "Book not found"
+// @Failure 500 {object} httputil.HTTPError "Internal server error"
+// @Router /api/books/{id}/rating [put]
+func (h *RatingsHandler) Rate(c *gin.Context) {
+	bookID, ok := ratingBookID(c)
+	if !ok {
+		return
+	}
+	var req models.RatingRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		httputil.NewError(c, http.StatusBadRequest, errors.New("bad_request"))
+		return
+	}
+	rating, err := h.Svc.Rate(c.Request.Context(), c.GetInt64("user_id"), bookID, req)
+	if err != nil {
+		respondRatingError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, rating)
+}
+
+// Unrate removes the user's rating of a book
+// Auth godoc
+// @Summary Take back a rating
+// @Description Remove the user's rating of a book and their review with it
+// @Param Authorization header string true "Token without 'Bearer' prefix"
+// @Param  id path int true "Book ID"
+// @Tags books
+// @Success 204 "Removed"
+// @Failure 400 {object} httputil.HTTPError "Bad request"
+// @Failure 404 {object} httputil.HTTPError "Not rated"
+// @Failure 500 {object} httputil.HTTPError "Internal server error"
+// @Router /api/books/{id}/rating [delete]
+func (h *RatingsHandler) Unrate(c *gin.Context) {
+	bookID, ok := ratingBookID(c)
+	if !ok {
+		return
+	}
+	if err := h.Svc.Unrate(c.Request.Context(), c.GetInt64("user_id"), bookID); err != nil {
+		respondRatingError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// Reviews returns a book's approved reviews
+// Auth godoc
+// @Summary Reviews of a book
+// @Description A page of the reviews of a book that moderators approved, newest first
+// @Param Authorization header string true "Token without 'Bearer' prefix"
+// @Param  id path int true "Book ID"
+// @Param  page query int false "Page number, from 1"
+// @Param  page_size query int false "Reviews per page, 20 by default, 100 at most"
+// @Tags books
+// @Produce  json
+// @Success 200 {object} BookReviewsAnswer "Reviews"
+// @Failure 400 {object} httputil.HTTPError "Bad request"
+// @Failure 500 {object} httputil.HTTPError "Internal server error"
+// @Router /api/books/{id}/reviews [get]
+func (h *RatingsHandler) Reviews(c *gin.Context) {
+	bookID, ok := ratingBookID(c)
+	if !ok {
+		return
+	}
+	page, pageSize := reviewPageQuery(c)
+	reviews, total, err := h.Svc.Reviews(c.Request.Context(), bookID, page, pageSize)
+	if err != nil {
+		respondRatingError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, reviewsAnswer(reviews, total))
+}
+
+// queue lists the reviews in a moderation state, pending by default.
+func (h *ReviewsAdminHandler) queue(c *gin.Context) {
+	page, pageSize := reviewPageQuery(c)
+	reviews, total, err := h.Svc.ReviewQueue(c.Request.Context(), c.DefaultQuery("status", models.ReviewPending), page, pageSize)
+	if err != nil {
+		respondRatingError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, reviewsAnswer(reviews, total))
+}
+
+// moderate approves or rejects a review.
+func (h *ReviewsAdminHandler) moderate(c *gin.Context) {
+	id, ok := parseInt64Param(c, "id")
+	if !ok {
+		return
+	}
+	var req models.ReviewModeration
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	before, after, err := h.Svc.Moderate(c.Request.Context(), id, req, adminUserID(c))
+	if err != nil {
+		respondRatingError(c, err)
+		return
+	}
+	action := models.AuditReviewApprove
+	if after.ReviewStatus == models.ReviewRejected {
+		action = models.AuditReviewReject
+	}
+	recordAudit(c, action, "review", after.ID, before, after)
+	c.JSON(http.StatusOK, after)
+}
+
+// ratingBookID reads the book of a rating route. It reports false, having
+// answered 400, for an id that is not one.
+func ratingBookID(c *gin.Context) (int64, bool) {
+	bookID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || bookID <= 0 {
+		httputil.NewError(c, http.StatusBadRequest, errors.New("invalid book id"))
+		return 0, false
+	}
+	return bookID, true
+}
+
+// reviewPageQuery reads the page and page size of a review list; the
+// service settles what is left unset or out of bounds.
+func reviewPageQuery(c *gin.Context) (page, pageSize int) {
+	page, _ = strconv.Atoi(c.Query("page"))
+	pageSize, _ = strconv.Atoi(c.Query("page_size"))
+	return page, pageSize
+}
+
+func reviewsAnswer(reviews []models.BookReview, total int) BookReviewsAnswer {
+	if reviews == nil {
+		reviews = []models.BookReview{}
+	}
+	return BookReviewsAnswer{Reviews: reviews, Total: total}
+}
+
+// respondRatingError maps rating errors to HTTP responses: a request that
+// cannot be stored → 400, a missing book, rating or review → 404,
+// everything else → 500.
+func respondRatingError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, models.ErrRatingInvalid):
+		httputil.NewError(c, http.StatusBadRequest, err)
+	case errors.Is(err, database.ErrRatingBookNotFound),
+		errors.Is(err, database.ErrRatingNotFound),
+		errors.Is(err, database.ErrReviewNotFound):
+		httputil.NewError(c, http.StatusNotFound, err)
+	default:
+		httputil.NewError(c, http.StatusInternalServerError, err)
+	}
+}
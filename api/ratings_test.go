@@ -0,0 +1,172 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"gopds-api/database"
+	"gopds-api/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeRatings is an in-memory Ratings and ReviewsAdmin in which book 1
+// exists and every other book does not.
+type fakeRatings struct {
+	ratings map[int64]*models.BookRating // by book, all of user 7
+	page    int
+}
+
+func newFakeRatings() *fakeRatings {
+	return &fakeRatings{ratings: map[int64]*models.BookRating{}}
+}
+
+func (f *fakeRatings) Rate(ctx context.Context, userID, bookID int64, req models.RatingRequest) (*models.BookRating, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+	if bookID != 1 {
+		return nil, database.ErrRatingBookNotFound
+	}
+	status := models.ReviewPending
+	if req.Review == "" {
+		status = models.ReviewNone
+	}
+	rating := &models.BookRating{ID: 10, UserID: userID, BookID: bookID, Rating: req.Rating, Review: req.Review, ReviewStatus: status}
+	f.ratings[bookID] = rating
+	return rating, nil
+}
+func (f *fakeRatings) Rating(ctx context.Context, userID, bookID int64) (*models.BookRating, error) {
+	if r, ok := f.ratings[bookID]; ok {
+		return r, nil
+	}
+	return nil, database.ErrRatingNotFound
+}
+func (f *fakeRatings) Unrate(ctx context.Context, userID, bookID int64) error {
+	if _, ok := f.ratings[bookID]; !ok {
+		return database.ErrRatingNotFound
+	}
+	delete(f.ratings, bookID)
+	return nil
+}
+func (f *fakeRatings) Reviews(ctx context.Context, bookID int64, page, pageSize int) ([]models.BookReview, int, error) {
+	f.page = page
+	return f.list(models.ReviewApproved), len(f.list(models.ReviewApproved)), nil
+}
+func (f *fakeRatings) ReviewQueue(ctx context.Context, status string, page, pageSize int) ([]models.BookReview, int, error) {
+	if !models.IsReviewStatus(status) {
+		return nil, 0, models.ErrRatingInvalid
+	}
+	return f.list(status), len(f.list(status)), nil
+}
+func (f *fakeRatings) Moderate(
+	ctx context.Context, id int64, decision models.ReviewModeration, moderatorID *int64,
+) (before, after *models.BookRating, err error) {
+	if err := decision.Validate(); err != nil {
+		return nil, nil, err
+	}
+	for _, r := range f.ratings {
+		if r.ID == id && r.ReviewStatus != models.ReviewNone {
+			old := *r
+			r.ReviewStatus = decision.Status
+			r.ModeratedBy = moderatorID
+			return &old, r, nil
+		}
+	}
+	return nil, nil, database.ErrReviewNotFound
+}
+
+func (f *fakeRatings) list(status string) []models.BookReview {
+	var reviews []models.BookReview
+	for _, r := range f.ratings {
+		if r.ReviewStatus == status {
+			reviews = append(reviews, models.BookReview{ID: r.ID, BookID: r.BookID, Rating: r.Rating, Review: r.Review})
+		}
+	}
+	return reviews
+}
+
+func newRatingsTestRouter(svc *fakeRatings) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	setUser := func(c *gin.Context) { c.Set("user_id", int64(7)) }
+	(&RatingsHandler{Svc: svc}).Register(r.Group("/api/books", setUser))
+	(&ReviewsAdminHandler{Svc: svc}).Register(r.Group("/api/admin/reviews", setUser))
+	return r
+}
+
+func decodeReviews(t *testing.T, body []byte) BookReviewsAnswer {
+	t.Helper()
+	var answer BookReviewsAnswer
+	require.NoError(t, json.Unmarshal(body, &answer))
+	return answer
+}
+
+func TestRatings_RateModerateAndShow(t *testing.T) {
+	svc := newFakeRatings()
+	r := newRatingsTestRouter(svc)
+
+	rec := doJSON(t, r, http.MethodPut, "/api/books/1/rating", json.RawMessage(`{"rating":4,"review":"Good"}`))
+	assert.Equal(t, http.StatusForbidden, rec.Code, "the CSRF check comes first")
+
+	rec = doCSRF(t, r, http.MethodPut, "/api/books/1/rating", `{"rating":4,"review":"Good"}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var rating models.BookRating
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &rating))
+	assert.Equal(t, models.ReviewPending, rating.ReviewStatus)
+
+	rec = doJSON(t, r, http.MethodGet, "/api/books/1/reviews", nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, `{"reviews":[],"total":0}`, rec.Body.String(), "a pending review is not shown")
+
+	rec = doJSON(t, r, http.MethodGet, "/api/admin/reviews", nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Len(t, decodeReviews(t, rec.Body.Bytes()).Reviews, 1, "the queue lists pending reviews by default")
+
+	rec = doJSON(t, r, http.MethodPost, "/api/admin/reviews/10/moderate", json.RawMessage(`{"status":"approved"}`))
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	rec = doJSON(t, r, http.MethodGet, "/api/books/1/reviews?page=2", nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, 1, decodeReviews(t, rec.Body.Bytes()).Total)
+	assert.Equal(t, 2, svc.page)
+
+	rec = doJSON(t, r, http.MethodGet, "/api/books/1/rating", nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"review_status":"approved"`)
+
+	rec = doCSRF(t, r, http.MethodDelete, "/api/books/1/rating", "")
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	rec = doJSON(t, r, http.MethodGet, "/api/books/1/rating", nil)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestRatings_Refusals(t *testing.T) {
+	svc := newFakeRatings()
+	r := newRatingsTestRouter(svc)
+
+	for _, tc := range []struct {
+		name, method, path, body string
+		want                     int
+	}{
+		{"stars out of range", http.MethodPut, "/api/books/1/rating", `{"rating":6}`, http.StatusBadRequest},
+		{"no stars", http.MethodPut, "/api/books/1/rating", `{"review":"Good"}`, http.StatusBadRequest},
+		{"not a book id", http.MethodPut, "/api/books/x/rating", `{"rating":3}`, http.StatusBadRequest},
+		{"unknown book", http.MethodPut, "/api/books/2/rating", `{"rating":3}`, http.StatusNotFound},
+		{"nothing to take back", http.MethodDelete, "/api/books/1/rating", "", http.StatusNotFound},
+	} {
+		rec := doCSRF(t, r, tc.method, tc.path, tc.body)
+		assert.Equal(t, tc.want, rec.Code, tc.name)
+	}
+
+	rec := doJSON(t, r, http.MethodGet, "/api/admin/reviews?status=none", nil)
+	assert.Equal(t, http.StatusBadRequest, rec.Code, "ratings without a review are not a queue")
+	rec = doJSON(t, r, http.MethodPost, "/api/admin/reviews/10/moderate", json.RawMessage(`{"status":"approved"}`))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	rec = doJSON(t, r, http.MethodPost, "/api/admin/reviews/10/moderate", json.RawMessage(`{"status":"pending"}`))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
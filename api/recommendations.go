@@ -0,0 +1,99 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"gopds-api/httputil"
+	"gopds-api/models"
+	"gopds-api/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+// RecommendationsHandler binds the recommendation service to gin routes.
+type RecommendationsHandler struct {
+	Svc services.Recommendations
+}
+
+// RecommendationsAnswer is a list of recommended books, best first.
+type RecommendationsAnswer struct {
+	Books []models.Book `json:"books"`
+}
+
+// recommendationsQuery is the query string of both recommendation lists.
+type recommendationsQuery struct {
+	Lang  string `form:"lang"`
+	Limit int    `form:"limit"`
+}
+
+// Register attaches the recommendation endpoints to the books group.
+// Caller is expected to have already wrapped the group with auth middleware.
+func (h *RecommendationsHandler) Register(r *gin.RouterGroup) {
+	r.GET("/:id/similar", h.Similar)
+	r.GET("/recommended", h.Recommended)
+}
+
+// Similar returns the books most like one book
+// Auth godoc
+// @Summary Books like a book
+// @Description Books sharing the book's series, authors, collections, readers' favorites and genres, the most alike first
+// @Param Authorization header string true "Token without 'Bearer' prefix"
+// @Param  id path int true "Book ID"
+// @Param  lang query string false "Books language; 'all' or empty for the whole library"
+// @Param  limit query int false "Number of books, 10 by default, 50 at most"
+// @Tags books
+// @Produce  json
+// @Success 200 {object} RecommendationsAnswer "Similar books"
+// @Failure 400 {object} httputil.HTTPError "Bad request"
+// @Failure 500 {object} httputil.HTTPError "Internal server error"
+// @Router /api/books/{id}/similar [get]
+func (h *RecommendationsHandler) Similar(c *gin.Context) {
+	bookID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || bookID <= 0 {
+		httputil.NewError(c, http.StatusBadRequest, errors.New("invalid book id"))
+		return
+	}
+	h.recommend(c, bookID)
+}
+
+// Recommended returns the logged-in user's personal picks
+// Auth godoc
+// @Summary Books recommended for the user
+// @Description Books like the ones the user favorited and downloaded lately, leaving out those they already have
+// @Param Authorization header string true "Token without 'Bearer' prefix"
+// @Param  lang query string false "Books language; 'all' or empty for the whole library"
+// @Param  limit query int false "Number of books, 10 by default, 50 at most"
+// @Tags books
+// @Produce  json
+// @Success 200 {object} RecommendationsAnswer "Recommended books"
+// @Failure 400 {object} httputil.HTTPError "Bad request"
+// @Failure 500 {object} httputil.HTTPError "Internal server error"
+// @Router /api/books/recommended [get]
+func (h *RecommendationsHandler) Recommended(c *gin.Context) {
+	h.recommend(c, 0)
+}
+
+func (h *RecommendationsHandler) recommend(c *gin.Context, bookID int64) {
+	var q recommendationsQuery
+	if err := c.ShouldBindWith(&q, binding.Query); err != nil {
+		httputil.NewError(c, http.StatusBadRequest, errors.New("bad_request"))
+		return
+	}
+	books, err := h.Svc.Recommend(c.Request.Context(), models.RecommendationRequest{
+		BookID:   bookID,
+		UserID:   c.GetInt64("user_id"),
+		Language: q.Lang,
+		Limit:    q.Limit,
+	})
+	if err != nil {
+		httputil.NewError(c, http.StatusInternalServerError, err)
+		return
+	}
+	if books == nil {
+		books = []models.Book{}
+	}
+	c.JSON(http.StatusOK, RecommendationsAnswer{Books: books})
+}
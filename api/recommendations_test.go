@@ -0,0 +1,54 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"gopds-api/internal/testfakes"
+	"gopds-api/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newRecommendationsTestRouter(svc *testfakes.Recommendations) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	g := r.Group("/api/books", func(c *gin.Context) {
+		c.Set("user_id", int64(7))
+	})
+	(&RecommendationsHandler{Svc: svc}).Register(g)
+	// The routes share the group with the static book routes.
+	g.GET("/list", func(c *gin.Context) { c.Status(http.StatusTeapot) })
+	return r
+}
+
+func TestRecommendations_Similar(t *testing.T) {
+	svc := &testfakes.Recommendations{Books: []models.Book{{ID: 2, Title: "Two"}}}
+	r := newRecommendationsTestRouter(svc)
+
+	rec := doJSON(t, r, http.MethodGet, "/api/books/5/similar?lang=ru&limit=3", nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, models.RecommendationRequest{BookID: 5, UserID: 7, Language: "ru", Limit: 3}, svc.Req)
+
+	var got RecommendationsAnswer
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+	require.Len(t, got.Books, 1)
+	assert.Equal(t, "Two", got.Books[0].Title)
+
+	rec = doJSON(t, r, http.MethodGet, "/api/books/abc/similar", nil)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = doJSON(t, r, http.MethodGet, "/api/books/list", nil)
+	assert.Equal(t, http.StatusTeapot, rec.Code, "static routes still win")
+}
+
+func TestRecommendations_Recommended(t *testing.T) {
+	svc := &testfakes.Recommendations{}
+	rec := doJSON(t, newRecommendationsTestRouter(svc), http.MethodGet, "/api/books/recommended", nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, models.RecommendationRequest{UserID: 7}, svc.Req, "no book: the caller's own picks")
+	assert.JSONEq(t, `{"books":[]}`, rec.Body.String())
+}
@@ -16,6 +16,7 @@ func SetupBookRoutes(r *gin.RouterGroup, search *SearchHandler) {
 	r.GET("/get/:format/:id", GetBookFile)
 	r.HEAD("/get/:format/:id", HeadBookFile)
 	r.GET("/langs", GetLangs)
+	r.GET("/genres", GenreTree)
 	r.GET("/self-user", SelfUser)
 	r.GET("/theme", GetThemePreference)
 	r.GET("/getsigned/:format/:id", GetSignedBookUrl)
@@ -31,6 +32,7 @@ func SetupBookRoutes(r *gin.RouterGroup, search *SearchHandler) {
 // SetupAuthRoutes sets up routes for authentication (public routes)
 func SetupAuthRoutes(r *gin.RouterGroup) {
 	r.POST("/login", middlewares.LoginRateLimitMiddleware(), AuthCheck)
+	r.POST("/login/2fa", middlewares.LoginRateLimitMiddleware(), TwoFactorLogin)
 	r.POST("/register", Registration)
 	r.GET("/csrf-token", GetCSRFToken)
 	r.GET("/init", InitSession)
@@ -26,10 +26,11 @@ type SearchHandler struct {
 
 // bookListQuery is the list endpoint's query string: the long-standing list
 // filters plus an exact-ID pin, which navigation links use to open one book
-// directly.
+// directly, and a search's request for its facet counts.
 type bookListQuery struct {
 	models.BookFilters
 	BookID int64 `form:"book_id"`
+	Facets bool  `form:"facets"`
 }
 
 // maxListLimit is the ordinary list's own page-size clamp, mirrored here
@@ -47,6 +48,13 @@ const maxListLimit = 100
 // @Param  title query string false "Title of the book"
 // @Param  author query int false "Author ID"
 // @Param  book_id query int false "Exact book ID"
+// @Param  genre query int false "Genre ID"
+// @Param  genre_section query int false "Genre section ID; list only, not search"
+// @Param  sort query string false "Sort order: title, author, series, added, year or popular; a leading - reverses it"
+// @Param  added_since query string false "Only books added on or after this day, YYYY-MM-DD"
+// @Param  year_from query int false "Earliest publication year"
+// @Param  year_to query int false "Latest publication year"
+// @Param  facets query bool false "Count a search's results by language, genre, author, series and decade; search only"
 // @Tags books
 // @Accept  json
 // @Produce  json
@@ -61,6 +69,11 @@ func (h *SearchHandler) Books(c *gin.Context) {
 		httputil.NewError(c, http.StatusBadRequest, errors.New("bad_request"))
 		return
 	}
+	opts, err := q.Options()
+	if err != nil {
+		httputil.NewError(c, http.StatusBadRequest, err)
+		return
+	}
 	// Both of these flags widen what the request may see, so both belong to
 	// whoever moderates rather than to whoever asks. The search branch no
 	// longer clears them here: it forwards them raw together with the
@@ -87,6 +100,8 @@ func (h *SearchHandler) Books(c *gin.Context) {
 			Unapproved:          q.UnApproved,
 			IncludeHidden:       q.IncludeHidden,
 			Moderator:           moderator,
+			Options:             opts,
+			Facets:              q.Facets,
 			Limit:               q.Limit,
 			Offset:              q.Offset,
 		})
@@ -94,7 +109,13 @@ func (h *SearchHandler) Books(c *gin.Context) {
 			mapSearchError(c, err)
 			return
 		}
-		c.JSON(http.StatusOK, ExportAnswer{Books: page.Books, Length: pageCount(page.Total, page.Limit)})
+		c.JSON(http.StatusOK, ExportAnswer{
+			Books:      page.Books,
+			Length:     pageCount(page.Total, page.Limit),
+			Rewrite:    page.Rewrite,
+			DidYouMean: page.DidYouMean,
+			Facets:     page.Facets,
+		})
 		return
 	}
 
@@ -147,7 +168,7 @@ func (h *SearchHandler) Authors(c *gin.Context) {
 		mapSearchError(c, err)
 		return
 	}
-	c.JSON(http.StatusOK, AuthorAnswer{Authors: page.Authors, Length: pageCount(page.Total, page.Limit)})
+	c.JSON(http.StatusOK, AuthorAnswer{Authors: page.Authors, Length: pageCount(page.Total, page.Limit), Rewrite: page.Rewrite})
 }
 
 // mapSearchError translates the service boundary into HTTP: validation
@@ -221,11 +242,11 @@ func suggestionScope(c *gin.Context) (models.SuggestionRequest, error) {
 // Autocomplete method for getting search suggestions
 // Auth godoc
 // @Summary Get autocomplete suggestions for search
-// @Description Get autocomplete suggestions for books and authors based on query
+// @Description Get autocomplete suggestions for books, authors, series and genres based on query
 // @Tags books
 // @Param Authorization header string true "Token without 'Bearer' prefix"
 // @Param query query string true "Search query"
-// @Param type query string false "Search type: 'title', 'author', or 'all' (default)"
+// @Param type query string false "Search type: 'title', 'author', 'series', 'genre', or 'all' (default)"
 // @Param author query string false "Author ID the reader is browsing"
 // @Param series query string false "Series ID the reader is browsing"
 // @Param genre query string false "Genre ID the reader is browsing"
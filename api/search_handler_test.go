@@ -8,7 +8,9 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"strings"
 	"testing"
+	"time"
 
 	"gopds-api/database"
 	"gopds-api/httputil"
@@ -263,6 +265,99 @@ func TestSearchHandler_Books_ExactBookID(t *testing.T) {
 	assert.Equal(t, 1, got.Length)
 }
 
+func TestSearchHandler_Books_SortAndDates(t *testing.T) {
+	t.Run("the search carries the order and the date range", func(t *testing.T) {
+		fake := &fakeSearch{booksPage: models.BookSearchPage{Limit: 10}}
+		r := newSearchTestRouter(fake, 77, false)
+
+		rec := doJSON(t, r, http.MethodGet,
+			"/api/books/list?title=x&sort=-year&added_since=2024-03-01&year_from=1990&year_to=2000", nil)
+
+		require.Equal(t, http.StatusOK, rec.Code, "body=%s", rec.Body.String())
+		require.Len(t, fake.booksReqs, 1)
+		assert.Equal(t, models.BookListOptions{
+			Sort:       models.BookSort{Key: models.SortYear, Reverse: true},
+			AddedSince: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+			YearFrom:   1990,
+			YearTo:     2000,
+		}, fake.booksReqs[0].Options)
+	})
+
+	for name, query := range map[string]string{
+		"unknown sort":   "sort=stars",
+		"malformed date": "added_since=yesterday",
+		"inverted range": "year_from=2001&year_to=2000",
+	} {
+		t.Run(name+" is a 400 on either path", func(t *testing.T) {
+			fake := &fakeSearch{}
+			r := newSearchTestRouter(fake, 77, false)
+
+			for _, path := range []string{"/api/books/list?title=x&" + query, "/api/books/list?" + query} {
+				rec := doJSON(t, r, http.MethodGet, path, nil)
+				assert.Equal(t, http.StatusBadRequest, rec.Code, path)
+			}
+			assert.Empty(t, fake.booksReqs)
+		})
+	}
+}
+
+func TestSearchHandler_Books_Rewrite(t *testing.T) {
+	for name, rewrite := range map[string]*models.QueryRewrite{
+		"a rewritten query is named": {Query: "война и мир", Kind: models.RewriteLayout},
+		"the query as typed is not":  nil,
+	} {
+		t.Run(name, func(t *testing.T) {
+			fake := &fakeSearch{booksPage: models.BookSearchPage{Total: 1, Limit: 10, Rewrite: rewrite}}
+			r := newSearchTestRouter(fake, 77, false)
+
+			rec := doJSON(t, r, http.MethodGet, "/api/books/list?title="+url.QueryEscape("djqyf b vbh"), nil)
+
+			require.Equal(t, http.StatusOK, rec.Code, "body=%s", rec.Body.String())
+			var got ExportAnswer
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+			assert.Equal(t, rewrite, got.Rewrite)
+			assert.Equal(t, rewrite != nil, strings.Contains(rec.Body.String(), `"rewrite"`))
+		})
+	}
+}
+
+func TestSearchHandler_Books_DidYouMean(t *testing.T) {
+	suggestions := []models.SpellingSuggestion{{Query: "война и мир", Total: 4}, {Query: "война и миф", Total: 1}}
+	fake := &fakeSearch{booksPage: models.BookSearchPage{Limit: 10, DidYouMean: suggestions}}
+	r := newSearchTestRouter(fake, 77, false)
+
+	rec := doJSON(t, r, http.MethodGet, "/api/books/list?title="+url.QueryEscape("вайна и мир"), nil)
+
+	require.Equal(t, http.StatusOK, rec.Code, "body=%s", rec.Body.String())
+	var got ExportAnswer
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+	assert.Empty(t, got.Books)
+	assert.Equal(t, suggestions, got.DidYouMean)
+}
+
+func TestSearchHandler_Books_Facets(t *testing.T) {
+	facets := &models.BookFacets{
+		Language: []models.FacetCount{{Value: "ru", Label: "ru", Count: 120}, {Value: "en", Label: "en", Count: 14}},
+		Decade:   []models.FacetCount{{Value: "1860", Label: "1860", Count: 3}},
+	}
+	fake := &fakeSearch{booksPage: models.BookSearchPage{Books: []models.Book{{ID: 1}}, Total: 134, Limit: 10, Facets: facets}}
+	r := newSearchTestRouter(fake, 77, false)
+
+	rec := doJSON(t, r, http.MethodGet, "/api/books/list?title=war&facets=true&lang=ru&year_from=1860&year_to=1869", nil)
+
+	require.Equal(t, http.StatusOK, rec.Code, "body=%s", rec.Body.String())
+	require.Len(t, fake.booksReqs, 1)
+	req := fake.booksReqs[0]
+	assert.True(t, req.Facets)
+	assert.Equal(t, "ru", req.Language, "a facet applied back is an ordinary filter")
+	assert.Equal(t, 1860, req.Options.YearFrom)
+	assert.Equal(t, 1869, req.Options.YearTo)
+
+	var got ExportAnswer
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+	assert.Equal(t, facets, got.Facets)
+}
+
 func TestSearchHandler_Authors_Search(t *testing.T) {
 	fake := &fakeSearch{authorsPage: models.AuthorSearchPage{
 		Authors: []models.Author{{ID: 1, FullName: "Толстой Лев", BooksCount: 700}},
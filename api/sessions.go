@@ -0,0 +1,54 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"gopds-api/middlewares"
+	"gopds-api/models"
+	"gopds-api/sessions"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SessionManager is the service-layer view of a user's sessions.
+type SessionManager interface {
+	List(ctx context.Context, userID int64, current string) ([]models.Session, error)
+	Revoke(ctx context.Context, userID int64, id string) error
+}
+
+// SessionsHandler binds SessionManager to gin routes.
+type SessionsHandler struct {
+	Svc SessionManager
+}
+
+// Register attaches the endpoints of the logged-in user's sessions.
+// Caller is expected to have already wrapped the group with auth middleware.
+func (h *SessionsHandler) Register(r *gin.RouterGroup) {
+	r.GET("", h.list)
+	r.DELETE("/:id", middlewares.CSRFMiddleware(), h.revoke)
+}
+
+func (h *SessionsHandler) list(c *gin.Context) {
+	list, err := h.Svc.List(c.Request.Context(), c.GetInt64("user_id"), c.GetString("session_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+// revoke ends one session. Revoking the current one is a logout without
+// the cookies cleared; the next request finds the session gone.
+func (h *SessionsHandler) revoke(c *gin.Context) {
+	err := h.Svc.Revoke(c.Request.Context(), c.GetInt64("user_id"), c.Param("id"))
+	switch {
+	case errors.Is(err, sessions.ErrSessionNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	default:
+		c.Status(http.StatusNoContent)
+	}
+}
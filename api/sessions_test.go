@@ -0,0 +1,105 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"gopds-api/models"
+	"gopds-api/sessions"
+	"gopds-api/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSessions is an in-memory SessionManager for httptest.
+type fakeSessions struct {
+	list    []models.Session
+	current string
+}
+
+func (f *fakeSessions) List(ctx context.Context, userID int64, current string) ([]models.Session, error) {
+	f.current = current
+	return f.list, nil
+}
+func (f *fakeSessions) Revoke(ctx context.Context, userID int64, id string) error {
+	for i, s := range f.list {
+		if s.ID == id {
+			f.list = append(f.list[:i], f.list[i+1:]...)
+			return nil
+		}
+	}
+	return sessions.ErrSessionNotFound
+}
+
+func newSessionsTestRouter(svc SessionManager) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	g := r.Group("/api/sessions", func(c *gin.Context) {
+		c.Set("user_id", int64(7))
+		c.Set("session_id", "s1")
+	})
+	(&SessionsHandler{Svc: svc}).Register(g)
+	return r
+}
+
+func TestSessions_List(t *testing.T) {
+	svc := &fakeSessions{list: []models.Session{
+		{ID: "s1", Kind: models.SessionWeb, Device: "Firefox on Linux"},
+		{ID: "telegram", Kind: models.SessionTelegram, Device: "Telegram bot"},
+	}}
+	rec := doJSON(t, newSessionsTestRouter(svc), http.MethodGet, "/api/sessions", nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "s1", svc.current, "the request's own session is passed on")
+
+	var got []models.Session
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+	assert.Len(t, got, 2)
+}
+
+func TestSessions_Revoke(t *testing.T) {
+	svc := &fakeSessions{list: []models.Session{{ID: "s2", Kind: models.SessionWeb}}}
+	r := newSessionsTestRouter(svc)
+
+	rec := doJSON(t, r, http.MethodDelete, "/api/sessions/s2", nil)
+	assert.Equal(t, http.StatusForbidden, rec.Code, "the CSRF check comes first")
+
+	rec = doCSRF(t, r, http.MethodDelete, "/api/sessions/s2", "")
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	assert.Empty(t, svc.list)
+
+	rec = doCSRF(t, r, http.MethodDelete, "/api/sessions/s2", "")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+// A refresh token is honoured only in a session still listed, so that
+// revoking sessions reaches every refresh token there is. One from before
+// the session index names no session and is refused outright: given a new
+// session, it would outlive "log out everywhere".
+func TestRenewSession_OnlyInAListedSession(t *testing.T) {
+	useSessionRedis(t)
+	ctx := context.Background()
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/api/refresh-token", http.NoBody)
+	user := models.User{ID: 3, Login: "anna"}
+
+	_, _, err := renewSession(ctx, c, user, &utils.Token{DatabaseID: 3})
+	assert.ErrorIs(t, err, sessions.ErrSessionNotFound, "a refresh token without a session")
+	list, err := sessions.ListSessions(ctx, 3)
+	require.NoError(t, err)
+	assert.Empty(t, list, "and no session is opened for it")
+
+	require.NoError(t, sessions.OpenWebSession(ctx, 3, "s1", "access-1", "", ""))
+	access, refresh, err := renewSession(ctx, c, user, &utils.Token{DatabaseID: 3, SessionID: "s1"})
+	require.NoError(t, err)
+	assert.NotEmpty(t, access)
+	assert.NotEmpty(t, refresh)
+
+	require.NoError(t, sessions.RevokeWebSessions(ctx, 3))
+	_, _, err = renewSession(ctx, c, user, &utils.Token{DatabaseID: 3, SessionID: "s1"})
+	assert.ErrorIs(t, err, sessions.ErrSessionNotFound, "logging out everywhere ends the session's refresh token")
+}
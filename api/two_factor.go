@@ -0,0 +1,297 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"gopds-api/database"
+	"gopds-api/httputil"
+	"gopds-api/logging"
+	"gopds-api/middlewares"
+	"gopds-api/models"
+	"gopds-api/services"
+	"gopds-api/sessions"
+	"gopds-api/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-pg/pg/v10"
+)
+
+// TwoFactorAuth is the service-layer view of TOTP second factors.
+type TwoFactorAuth interface {
+	Enabled(ctx context.Context, userID int64) (bool, error)
+	Status(ctx context.Context, userID int64) (models.TwoFactorStatus, error)
+	Begin(ctx context.Context, userID int64, account string) (*models.TwoFactorEnrolment, error)
+	Confirm(ctx context.Context, userID int64, code string) ([]string, error)
+	Verify(ctx context.Context, userID int64, code string) error
+	RegenerateRecoveryCodes(ctx context.Context, userID int64, code string) ([]string, error)
+	Disable(ctx context.Context, userID int64, code string) error
+	Reset(ctx context.Context, userID int64) error
+}
+
+// twoFactor is consulted by every login. Nil until SetTwoFactor: no
+// account then has a second factor to ask for.
+var twoFactor TwoFactorAuth
+
+// SetTwoFactor installs the second-factor service the logins use.
+func SetTwoFactor(tf TwoFactorAuth) {
+	twoFactor = tf
+}
+
+// twoFactorChallenge returns a challenge when user has a second factor to
+// give before a session is opened, and "" when not.
+func twoFactorChallenge(ctx context.Context, user models.User) (string, error) {
+	if twoFactor == nil {
+		return "", nil
+	}
+	enabled, err := twoFactor.Enabled(ctx, user.ID)
+	if err != nil || !enabled {
+		return "", err
+	}
+	return sessions.CreateTwoFactorChallenge(ctx, user.ID)
+}
+
+// keepsTwoFactor reports whether a refreshed session keeps the second
+// factor its refresh token records: only while the user still has one.
+func keepsTwoFactor(ctx context.Context, refresh *utils.Token) bool {
+	if !refresh.TwoFactor || twoFactor == nil {
+		return false
+	}
+	enabled, err := twoFactor.Enabled(ctx, refresh.DatabaseID)
+	if err != nil {
+		logging.Errorf("Checking the second factor of user %d: %v", refresh.DatabaseID, err)
+		return false
+	}
+	return enabled
+}
+
+// TwoFactorLogin completes a login with the second factor
+// Auth godoc
+// @Summary Complete a login with a second factor
+// @Description Answer the challenge a login with the right password got, with a TOTP or recovery code
+// @Tags login
+// @Accept  json
+// @Produce  json
+// @Param  body body models.TwoFactorLoginRequest true "Challenge and code"
+// @Success 200 {object} models.LoggedInUser
+// @Failure 400 {object} httputil.HTTPError
+// @Failure 401 {object} httputil.HTTPError "Challenge expired"
+// @Failure 403 {object} httputil.HTTPError "Wrong code"
+// @Router /api/login/2fa [post]
+func TwoFactorLogin(c *gin.Context) {
+	var req models.TwoFactorLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		httputil.NewError(c, http.StatusBadRequest, errors.New("invalid_request"))
+		return
+	}
+	if twoFactor == nil {
+		httputil.NewError(c, http.StatusNotFound, errors.New("two_factor_disabled"))
+		return
+	}
+
+	ctx := c.Request.Context()
+	userID, err := sessions.TwoFactorChallengeUser(ctx, req.Challenge)
+	if err != nil {
+		httputil.NewError(c, http.StatusUnauthorized, errors.New("challenge_expired"))
+		return
+	}
+	if err := twoFactor.Verify(ctx, userID, req.Code); err != nil {
+		if errors.Is(err, services.ErrTwoFactorCode) {
+			httputil.NewError(c, http.StatusForbidden, errors.New("bad_code"))
+			return
+		}
+		httputil.NewError(c, http.StatusInternalServerError, err)
+		return
+	}
+	sessions.DeleteTwoFactorChallenge(ctx, req.Challenge)
+
+	dbUser, err := sessionUser(ctx, userID)
+	if err != nil || !dbUser.Active {
+		httputil.NewError(c, http.StatusForbidden, errors.New("user not active"))
+		return
+	}
+	thisUser, err := openSession(c, dbUser, true)
+	if err != nil {
+		httputil.NewError(c, http.StatusForbidden, err)
+		return
+	}
+	c.JSON(http.StatusOK, thisUser)
+}
+
+// sessionUser loads what a session needs of a user: the user, and the
+// permissions its access token carries.
+func sessionUser(ctx context.Context, userID int64) (models.User, error) {
+	user, err := database.GetUserByID(ctx, userID)
+	if err != nil {
+		return user, err
+	}
+	user.Permissions, err = database.UserPermissions(ctx, userID)
+	return user, err
+}
+
+// TwoFactorHandler binds TwoFactorAuth to gin routes: enrolment and
+// management for the logged-in user, and a reset for admins.
+type TwoFactorHandler struct {
+	Svc TwoFactorAuth
+	// RequireForAdmins marks accounts that may use the admin API as
+	// required to have a second factor.
+	RequireForAdmins bool
+
+	// upgradeSession replaces the package's upgradeSession in tests.
+	upgradeSession func(c *gin.Context) error
+	// getUser replaces database.GetUserByID in tests.
+	getUser func(ctx context.Context, id int64) (models.User, error)
+}
+
+// Register attaches the endpoints of the logged-in user's second factor.
+// Caller is expected to have already wrapped the group with auth middleware.
+func (h *TwoFactorHandler) Register(r *gin.RouterGroup) {
+	r.GET("", h.status)
+	r.POST("/enroll", middlewares.CSRFMiddleware(), h.enroll)
+	r.POST("/confirm", middlewares.CSRFMiddleware(), h.confirm)
+	r.POST("/recovery-codes", middlewares.CSRFMiddleware(), h.recoveryCodes)
+	r.POST("/disable", middlewares.CSRFMiddleware(), h.disable)
+}
+
+// RegisterAdmin attaches the admin reset to the given group.
+// Caller is expected to have already wrapped the group with admin middleware.
+func (h *TwoFactorHandler) RegisterAdmin(r *gin.RouterGroup) {
+	r.DELETE("/users/:id/2fa", h.reset)
+}
+
+func (h *TwoFactorHandler) status(c *gin.Context) {
+	status, err := h.Svc.Status(c.Request.Context(), c.GetInt64("user_id"))
+	if err != nil {
+		respondTwoFactorError(c, err)
+		return
+	}
+	status.Required = h.RequireForAdmins &&
+		(c.GetBool("is_superuser") || len(c.GetStringSlice("permissions")) > 0)
+	c.JSON(http.StatusOK, status)
+}
+
+func (h *TwoFactorHandler) enroll(c *gin.Context) {
+	enrolment, err := h.Svc.Begin(c.Request.Context(), c.GetInt64("user_id"), c.GetString("username"))
+	if err != nil {
+		respondTwoFactorError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, enrolment)
+}
+
+// confirm finishes an enrolment. The code just given is a second factor,
+// so the session is reopened as one that had it.
+func (h *TwoFactorHandler) confirm(c *gin.Context) {
+	var req models.TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	codes, err := h.Svc.Confirm(c.Request.Context(), c.GetInt64("user_id"), req.Code)
+	if err != nil {
+		respondTwoFactorError(c, err)
+		return
+	}
+	upgrade := h.upgradeSession
+	if upgrade == nil {
+		upgrade = upgradeSession
+	}
+	if err := upgrade(c); err != nil {
+		// The second factor is on; the session just does not know yet.
+		logging.Errorf("Reopening the session of user %d: %v", c.GetInt64("user_id"), err)
+	}
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
+
+func (h *TwoFactorHandler) recoveryCodes(c *gin.Context) {
+	var req models.TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	codes, err := h.Svc.RegenerateRecoveryCodes(c.Request.Context(), c.GetInt64("user_id"), req.Code)
+	if err != nil {
+		respondTwoFactorError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
+
+func (h *TwoFactorHandler) disable(c *gin.Context) {
+	var req models.TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.Svc.Disable(c.Request.Context(), c.GetInt64("user_id"), req.Code); err != nil {
+		respondTwoFactorError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (h *TwoFactorHandler) reset(c *gin.Context) {
+	userID, ok := parseInt64Param(c, "id")
+	if !ok {
+		return
+	}
+	ctx := c.Request.Context()
+	getUser := h.getUser
+	if getUser == nil {
+		getUser = database.GetUserByID
+	}
+	// A superuser's second factor is theirs, or another superuser's, to
+	// take away: the admin API may be requiring it of them.
+	target, err := getUser(ctx, userID)
+	if errors.Is(err, pg.ErrNoRows) {
+		httputil.NewError(c, http.StatusNotFound, errors.New("user not found"))
+		return
+	}
+	if err != nil {
+		httputil.NewError(c, http.StatusInternalServerError, err)
+		return
+	}
+	if !authorizeUserChange(c, &target, target.IsSuperUser) {
+		return
+	}
+	enabled, err := h.Svc.Enabled(ctx, userID)
+	if err != nil {
+		respondTwoFactorError(c, err)
+		return
+	}
+	if err := h.Svc.Reset(ctx, userID); err != nil {
+		respondTwoFactorError(c, err)
+		return
+	}
+	recordAudit(c, models.AuditUserTwoFactorReset, "user", userID,
+		gin.H{"two_factor": enabled}, gin.H{"two_factor": false})
+	c.Status(http.StatusNoContent)
+}
+
+// upgradeSession reopens the current user's session as one opened with a
+// second factor.
+func upgradeSession(c *gin.Context) error {
+	user, err := sessionUser(c.Request.Context(), c.GetInt64("user_id"))
+	if err != nil {
+		return err
+	}
+	_, err = openSession(c, user, true)
+	return err
+}
+
+// respondTwoFactorError maps second-factor errors to HTTP responses: a
+// wrong code → 403, nothing to confirm or check → 404, an enrolment over
+// one in use → 409, everything else → 500.
+func respondTwoFactorError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrTwoFactorCode):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, database.ErrTwoFactorNotEnrolled):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, database.ErrTwoFactorEnabled):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
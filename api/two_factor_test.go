@@ -0,0 +1,296 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"gopds-api/database"
+	"gopds-api/models"
+	"gopds-api/services"
+	"gopds-api/sessions"
+	"gopds-api/utils"
+
+	miniredis "github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/go-pg/pg/v10"
+	"github.com/go-redis/redis"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeTwoFactor is an in-memory TwoFactorAuth: "123456" is the one good
+// code, and a user is enrolled once confirmed.
+type fakeTwoFactor struct {
+	enrolled  map[int64]bool
+	confirmed map[int64]bool
+}
+
+func newFakeTwoFactor() *fakeTwoFactor {
+	return &fakeTwoFactor{enrolled: map[int64]bool{}, confirmed: map[int64]bool{}}
+}
+
+func (f *fakeTwoFactor) Enabled(ctx context.Context, userID int64) (bool, error) {
+	return f.confirmed[userID], nil
+}
+func (f *fakeTwoFactor) Status(ctx context.Context, userID int64) (models.TwoFactorStatus, error) {
+	return models.TwoFactorStatus{Enabled: f.confirmed[userID]}, nil
+}
+func (f *fakeTwoFactor) Begin(ctx context.Context, userID int64, account string) (*models.TwoFactorEnrolment, error) {
+	if f.confirmed[userID] {
+		return nil, database.ErrTwoFactorEnabled
+	}
+	f.enrolled[userID] = true
+	return &models.TwoFactorEnrolment{Secret: "ABC", URI: "otpauth://totp/Books:" + account + "?secret=ABC"}, nil
+}
+func (f *fakeTwoFactor) Confirm(ctx context.Context, userID int64, code string) ([]string, error) {
+	if !f.enrolled[userID] {
+		return nil, database.ErrTwoFactorNotEnrolled
+	}
+	if code != "123456" {
+		return nil, services.ErrTwoFactorCode
+	}
+	f.confirmed[userID] = true
+	return []string{"aaaaa-bbbbb"}, nil
+}
+func (f *fakeTwoFactor) Verify(ctx context.Context, userID int64, code string) error {
+	if !f.confirmed[userID] {
+		return database.ErrTwoFactorNotEnrolled
+	}
+	if code != "123456" {
+		return services.ErrTwoFactorCode
+	}
+	return nil
+}
+func (f *fakeTwoFactor) RegenerateRecoveryCodes(ctx context.Context, userID int64, code string) ([]string, error) {
+	if err := f.Verify(ctx, userID, code); err != nil {
+		return nil, err
+	}
+	return []string{"ccccc-ddddd"}, nil
+}
+func (f *fakeTwoFactor) Disable(ctx context.Context, userID int64, code string) error {
+	if err := f.Verify(ctx, userID, code); err != nil {
+		return err
+	}
+	return f.Reset(ctx, userID)
+}
+func (f *fakeTwoFactor) Reset(ctx context.Context, userID int64) error {
+	delete(f.enrolled, userID)
+	delete(f.confirmed, userID)
+	return nil
+}
+
+// newTwoFactorTestRouter serves the account routes as user 7, "anna", and
+// the admin reset, with the CSRF check satisfied by doCSRF.
+func newTwoFactorTestRouter(h *TwoFactorHandler) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	authed := r.Group("/api", func(c *gin.Context) {
+		c.Set("user_id", int64(7))
+		c.Set("username", "anna")
+		c.Set("is_superuser", true)
+	})
+	h.Register(authed.Group("/2fa"))
+	h.RegisterAdmin(authed.Group("/admin"))
+	return r
+}
+
+func doCSRF(t *testing.T, r http.Handler, method, path, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-CSRF-Token", "t")
+	req.AddCookie(&http.Cookie{Name: "csrf_token", Value: "t"})
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestTwoFactor_EnrolConfirmDisable(t *testing.T) {
+	svc := newFakeTwoFactor()
+	upgraded := 0
+	h := &TwoFactorHandler{Svc: svc, upgradeSession: func(c *gin.Context) error {
+		upgraded++
+		return nil
+	}}
+	r := newTwoFactorTestRouter(h)
+
+	// Without a CSRF token nothing is started.
+	rec := doJSON(t, r, http.MethodPost, "/api/2fa/enroll", nil)
+	require.Equal(t, http.StatusForbidden, rec.Code)
+
+	rec = doCSRF(t, r, http.MethodPost, "/api/2fa/enroll", "")
+	require.Equal(t, http.StatusOK, rec.Code, "body=%s", rec.Body.String())
+	assert.Contains(t, rec.Body.String(), "otpauth://totp/Books:anna")
+
+	rec = doCSRF(t, r, http.MethodPost, "/api/2fa/confirm", `{"code":"000000"}`)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	assert.Zero(t, upgraded)
+
+	rec = doCSRF(t, r, http.MethodPost, "/api/2fa/confirm", `{"code":"123456"}`)
+	require.Equal(t, http.StatusOK, rec.Code, "body=%s", rec.Body.String())
+	assert.Contains(t, rec.Body.String(), "aaaaa-bbbbb")
+	assert.Equal(t, 1, upgraded, "the session is reopened with the second factor")
+
+	// A second enrolment over one in use is refused.
+	rec = doCSRF(t, r, http.MethodPost, "/api/2fa/enroll", "")
+	assert.Equal(t, http.StatusConflict, rec.Code)
+
+	rec = doCSRF(t, r, http.MethodPost, "/api/2fa/recovery-codes", `{"code":"123456"}`)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "ccccc-ddddd")
+
+	rec = doCSRF(t, r, http.MethodPost, "/api/2fa/disable", `{"code":"111111"}`)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	rec = doCSRF(t, r, http.MethodPost, "/api/2fa/disable", `{"code":"123456"}`)
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	assert.False(t, svc.confirmed[7])
+}
+
+func TestTwoFactor_Status(t *testing.T) {
+	svc := newFakeTwoFactor()
+	svc.confirmed[7] = true
+
+	rec := doJSON(t, newTwoFactorTestRouter(&TwoFactorHandler{Svc: svc}), http.MethodGet, "/api/2fa", nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"enabled":true,"recovery_codes_left":0,"required":false}`, rec.Body.String())
+
+	rec = doJSON(t, newTwoFactorTestRouter(&TwoFactorHandler{Svc: svc, RequireForAdmins: true}), http.MethodGet, "/api/2fa", nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"required":true`)
+}
+
+func TestTwoFactor_AdminReset(t *testing.T) {
+	svc := newFakeTwoFactor()
+	svc.confirmed[42] = true
+	audit := &fakeAuditLog{}
+	SetAuditLog(audit)
+	t.Cleanup(func() { SetAuditLog(nil) })
+
+	h := &TwoFactorHandler{Svc: svc, getUser: usersByID(models.User{ID: 42})}
+	rec := doJSON(t, newTwoFactorTestRouter(h), http.MethodDelete, "/api/admin/users/42/2fa", nil)
+	require.Equal(t, http.StatusNoContent, rec.Code)
+	assert.False(t, svc.confirmed[42])
+	require.Len(t, audit.recorded, 1)
+	assert.Equal(t, models.AuditUserTwoFactorReset, audit.recorded[0].Action)
+
+	rec = doJSON(t, newTwoFactorTestRouter(h), http.MethodDelete, "/api/admin/users/43/2fa", nil)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+// A holder of users.manage resets a reader's second factor but not a
+// superuser's.
+func TestTwoFactor_AdminResetOfASuperuser(t *testing.T) {
+	svc := newFakeTwoFactor()
+	svc.confirmed[1] = true
+	svc.confirmed[42] = true
+	h := &TwoFactorHandler{Svc: svc, getUser: usersByID(models.User{ID: 1, IsSuperUser: true}, models.User{ID: 42})}
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	staff := r.Group("/api/admin", func(c *gin.Context) {
+		c.Set("user_id", int64(7))
+		c.Set("permissions", []string{models.PermUsersManage})
+	})
+	h.RegisterAdmin(staff)
+
+	rec := doJSON(t, r, http.MethodDelete, "/api/admin/users/1/2fa", nil)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	assert.True(t, svc.confirmed[1])
+
+	rec = doJSON(t, r, http.MethodDelete, "/api/admin/users/42/2fa", nil)
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	assert.False(t, svc.confirmed[42])
+}
+
+// usersByID stands in for database.GetUserByID over the given users.
+func usersByID(users ...models.User) func(ctx context.Context, id int64) (models.User, error) {
+	return func(ctx context.Context, id int64) (models.User, error) {
+		for _, u := range users {
+			if u.ID == id {
+				return u, nil
+			}
+		}
+		return models.User{}, pg.ErrNoRows
+	}
+}
+
+// useTwoFactor installs svc as the login's second factor, with the
+// challenges in a miniredis.
+func useTwoFactor(t *testing.T, svc TwoFactorAuth) {
+	t.Helper()
+	useSessionRedis(t)
+	SetTwoFactor(svc)
+	t.Cleanup(func() { SetTwoFactor(nil) })
+}
+
+// useSessionRedis puts the sessions package on a miniredis for the test.
+func useSessionRedis(t *testing.T) {
+	t.Helper()
+	s, err := miniredis.Run()
+	require.NoError(t, err)
+	client := redis.NewClient(&redis.Options{Addr: s.Addr()})
+	sessions.SetRedisConnections(client, client)
+	t.Cleanup(func() {
+		sessions.SetRedisConnections(nil, nil)
+		_ = client.Close()
+		s.Close()
+	})
+}
+
+func TestTwoFactorChallenge(t *testing.T) {
+	ctx := context.Background()
+	challenge, err := twoFactorChallenge(ctx, models.User{ID: 3})
+	require.NoError(t, err)
+	assert.Empty(t, challenge, "no second factor service, no challenge")
+
+	svc := newFakeTwoFactor()
+	useTwoFactor(t, svc)
+	challenge, err = twoFactorChallenge(ctx, models.User{ID: 3})
+	require.NoError(t, err)
+	assert.Empty(t, challenge, "a user without a second factor logs straight in")
+
+	svc.confirmed[3] = true
+	challenge, err = twoFactorChallenge(ctx, models.User{ID: 3})
+	require.NoError(t, err)
+	require.NotEmpty(t, challenge)
+	userID, err := sessions.TwoFactorChallengeUser(ctx, challenge)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), userID)
+}
+
+func TestKeepsTwoFactor(t *testing.T) {
+	ctx := context.Background()
+	svc := newFakeTwoFactor()
+	useTwoFactor(t, svc)
+	svc.confirmed[3] = true
+
+	assert.True(t, keepsTwoFactor(ctx, &utils.Token{DatabaseID: 3, TwoFactor: true}))
+	assert.False(t, keepsTwoFactor(ctx, &utils.Token{DatabaseID: 3}), "a session without it does not gain it")
+	svc.confirmed[3] = false
+	assert.False(t, keepsTwoFactor(ctx, &utils.Token{DatabaseID: 3, TwoFactor: true}), "turned off, it is gone at the next refresh")
+}
+
+func TestTwoFactorLogin_Refusals(t *testing.T) {
+	svc := newFakeTwoFactor()
+	svc.confirmed[3] = true
+	useTwoFactor(t, svc)
+	r := gin.New()
+	r.POST("/api/login/2fa", TwoFactorLogin)
+
+	rec := doJSON(t, r, http.MethodPost, "/api/login/2fa", map[string]string{"challenge": "nope", "code": "123456"})
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	challenge, err := twoFactorChallenge(context.Background(), models.User{ID: 3})
+	require.NoError(t, err)
+	rec = doJSON(t, r, http.MethodPost, "/api/login/2fa", map[string]string{"challenge": challenge, "code": "000000"})
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	assert.Contains(t, rec.Body.String(), "bad_code")
+
+	// A wrong code leaves the challenge to try again.
+	userID, err := sessions.TwoFactorChallengeUser(context.Background(), challenge)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), userID)
+}
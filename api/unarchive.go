@@ -1,20 +1,15 @@
 package api
 
 import (
-	"bytes"
 	"errors"
 	"fmt"
-	"io"
 	"net/http"
 	"strconv"
 	"strings"
-	"sync"
-	"time"
 
 	"gopds-api/database"
 	"gopds-api/httputil"
-	"gopds-api/logging"
-	"gopds-api/utils"
+	"gopds-api/services"
 
 	"github.com/gin-gonic/gin"
 	"github.com/spf13/viper"
@@ -27,8 +22,6 @@ var bookTypes = map[string]string{
 	"mobi": "application/x-mobipocket-ebook",
 }
 
-var readyChannels sync.Map // Temporary storage for mobi files
-
 // GetBookFile returns the file of a book in the requested format
 // Auth godoc
 // @Summary Return book file in the specified format
@@ -57,90 +50,61 @@ func GetBookFile(c *gin.Context) {
 		return
 	}
 
-	book, err := database.GetBook(bookID)
-	if err != nil {
-		httputil.NewError(c, http.StatusNotFound, err)
-		return
-	}
-	zipPath := viper.GetString("app.files_path") + book.Path
-	if !utils.FileExists(zipPath) {
-		httputil.NewError(c, http.StatusNotFound, errors.New("book file not found"))
-		return
-	}
-
-	bp := utils.NewBookProcessor(book.FileName, zipPath)
-
-	var rc io.ReadCloser
 	switch format {
-	case "epub":
-		rc, err = bp.Epub()
-	case "fb2":
-		rc, err = bp.FB2()
-	case "zip":
-		rc, err = bp.Zip(book.FileName)
+	case "epub", "fb2", "zip":
 	default:
 		httputil.NewError(c, http.StatusBadRequest, errors.New("unsupported format"))
 		return
 	}
+
+	book, err := database.GetBook(bookID)
 	if err != nil {
-		httputil.NewError(c, http.StatusInternalServerError, err)
+		httputil.NewError(c, http.StatusNotFound, err)
 		return
 	}
-	defer rc.Close()
-
-	var buf bytes.Buffer
-	_, err = io.Copy(&buf, rc)
+	filesPath := viper.GetString("app.files_path")
+	modTime, err := services.BookFileModTime(&book, filesPath)
 	if err != nil {
-		logging.Errorf("failed to buffer file: %v", err)
-		httputil.NewError(c, http.StatusInternalServerError, err)
+		bookFileError(c, err)
 		return
 	}
-
-	reader := bytes.NewReader(buf.Bytes())
-	c.Writer.Header().Set("Content-Type", contentType)
-	c.Writer.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s.%s", book.DownloadName(), format))
-	http.ServeContent(c.Writer, c.Request, book.DownloadName()+"."+format, time.Now(), reader)
-}
-
-// DownloadConvertedEpub serves a converted EPUB file from the in-memory store.
-func DownloadConvertedEpub(c *gin.Context) {
-	bookID, err := strconv.ParseInt(c.Param("id"), 10, 64)
-	if err != nil {
-		httputil.NewError(c, http.StatusBadRequest, fmt.Errorf("invalid book ID: %v", err))
+	// A reader revalidating its copy is answered before the archive is
+	// opened: the validators come from the row and a stat.
+	if httputil.NotModified(c, services.BookFileETag(&book, format), modTime) {
 		return
 	}
 
-	val, ok := epubStore.Load(bookID)
-	if !ok {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Converted file not found"})
+	file, err := services.OpenBookFile(conversionCache, &book, filesPath, format)
+	if err != nil {
+		bookFileError(c, err)
 		return
 	}
-	cf := val.(*ConvertedFile)
+	defer file.Content.Close()
 
-	c.Writer.Header().Set("Content-Type", cf.ContentType)
-	c.Writer.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s", cf.Filename))
-	http.ServeContent(c.Writer, c.Request, cf.Filename, time.Now(), bytes.NewReader(cf.Data))
-
-	epubStore.Delete(bookID)
+	if httputil.StartsDownload(c) {
+		services.RecordDownload(c.Request.Context(), c.GetInt64("user_id"), book.ID)
+	}
+	serveBookFile(c, file, book.DownloadName()+"."+format, contentType)
 }
 
-// HeadConvertedEpub handles HEAD requests for converted EPUB files.
-func HeadConvertedEpub(c *gin.Context) {
-	bookID, err := strconv.ParseInt(c.Param("id"), 10, 64)
-	if err != nil {
-		httputil.NewError(c, http.StatusBadRequest, fmt.Errorf("invalid book ID: %v", err))
+// bookFileError answers a book file that could not be found or opened.
+func bookFileError(c *gin.Context, err error) {
+	if errors.Is(err, services.ErrBookFileMissing) || errors.Is(err, services.ErrBookFormatUnavailable) {
+		httputil.NewError(c, http.StatusNotFound, err)
 		return
 	}
+	httputil.NewError(c, http.StatusInternalServerError, err)
+}
 
-	val, ok := epubStore.Load(bookID)
-	if !ok {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Converted file not found"})
-		return
-	}
-	cf := val.(*ConvertedFile)
+// serveBookFile writes a book file with its validators. Ranges, conditional
+// requests and the length are left to http.ServeContent.
+func serveBookFile(c *gin.Context, file *services.BookFile, filename, contentType string) {
+	httputil.ServeDownload(c, file.Content, filename, contentType, file.ETag, file.ModTime)
+}
 
-	c.Writer.Header().Set("Content-Type", cf.ContentType)
-	c.Status(http.StatusOK)
+// HeadConvertedEpub handles HEAD requests for converted EPUB files.
+func HeadConvertedEpub(c *gin.Context) {
+	headConverted(c, "epub")
 }
 
 // HeadBookFile handles HEAD requests for book files without streaming content.
@@ -162,9 +126,9 @@ func HeadBookFile(c *gin.Context) {
 		httputil.NewError(c, http.StatusNotFound, err)
 		return
 	}
-	zipPath := viper.GetString("app.files_path") + book.Path
-	if !utils.FileExists(zipPath) {
-		httputil.NewError(c, http.StatusNotFound, errors.New("book file not found"))
+	modTime, err := services.BookFileModTime(&book, viper.GetString("app.files_path"))
+	if err != nil {
+		bookFileError(c, err)
 		return
 	}
 
@@ -175,8 +139,24 @@ func HeadBookFile(c *gin.Context) {
 		httputil.NewError(c, http.StatusBadRequest, errors.New("unsupported format"))
 		return
 	}
+	if !services.BookFormatAvailable(&book, format) {
+		bookFileError(c, services.ErrBookFormatUnavailable)
+		return
+	}
 
-	c.Writer.Header().Set("Content-Type", contentType)
-	c.Writer.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s.%s", book.DownloadName(), format))
+	etag := services.BookFileETag(&book, format)
+	if httputil.NotModified(c, etag, modTime) {
+		return
+	}
+	// The validators and range support GetBookFile will answer with, without
+	// producing the body; the length would take exactly that.
+	h := c.Writer.Header()
+	h.Set("Content-Type", contentType)
+	h.Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s.%s", book.DownloadName(), format))
+	h.Set("Accept-Ranges", "bytes")
+	h.Set("Last-Modified", modTime.UTC().Format(http.TimeFormat))
+	if etag != "" {
+		h.Set("ETag", etag)
+	}
 	c.Status(http.StatusOK)
 }
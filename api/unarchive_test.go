@@ -0,0 +1,113 @@
+package api
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"gopds-api/models"
+	"gopds-api/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type closingReader struct{ *bytes.Reader }
+
+func (closingReader) Close() error { return nil }
+
+func serveBookFileRequest(t *testing.T, header http.Header) *httptest.ResponseRecorder {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequestWithContext(t.Context(), http.MethodGet, "/api/files/books/conversion/epub/1", http.NoBody)
+	for k, v := range header {
+		c.Request.Header[k] = v
+	}
+
+	converted := &services.BookFile{
+		Content: closingReader{bytes.NewReader([]byte("converted epub"))},
+		Size:    int64(len("converted epub")),
+		ModTime: time.Now(),
+		ETag:    `"0123456789abcdef0123456789abcdef-epub-v1"`,
+	}
+	serveBookFile(c, converted, "book.epub", "application/epub+zip")
+	// What the engine does after the last handler: a response without a body
+	// has its status written only here.
+	c.Writer.WriteHeaderNow()
+	return recorder
+}
+
+func TestServeBookFileSendsItsValidator(t *testing.T) {
+	w := serveBookFileRequest(t, nil)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", w.Code)
+	}
+	if got := w.Header().Get("ETag"); got != `"0123456789abcdef0123456789abcdef-epub-v1"` {
+		t.Errorf("ETag = %q", got)
+	}
+	if body, _ := io.ReadAll(w.Body); string(body) != "converted epub" {
+		t.Errorf("body = %q", body)
+	}
+}
+
+// A reader that already holds this conversion gets a 304 and no body: the
+// point of the validator is that the book is not sent twice.
+func TestServeBookFileAnswersMatchingETagWithNotModified(t *testing.T) {
+	w := serveBookFileRequest(t, http.Header{
+		"If-None-Match": {`"0123456789abcdef0123456789abcdef-epub-v1"`},
+	})
+
+	if w.Code != http.StatusNotModified {
+		t.Fatalf("status = %d, want 304", w.Code)
+	}
+	if w.Body.Len() != 0 {
+		t.Errorf("a 304 carried %d bytes of body", w.Body.Len())
+	}
+}
+
+// An interrupted download resumes where it stopped: a Range request is
+// answered 206 with just the bytes asked for.
+func TestServeBookFileResumesARange(t *testing.T) {
+	w := serveBookFileRequest(t, http.Header{"Range": {"bytes=10-"}})
+
+	if w.Code != http.StatusPartialContent {
+		t.Fatalf("status = %d, want 206", w.Code)
+	}
+	if got := w.Header().Get("Content-Range"); got != "bytes 10-13/14" {
+		t.Errorf("Content-Range = %q", got)
+	}
+	if body, _ := io.ReadAll(w.Body); string(body) != "epub" {
+		t.Errorf("body = %q", body)
+	}
+}
+
+// Without a cache nothing is kept between downloads, so a HEAD cannot wait
+// for a conversion that will never be stored: a book whose archive is there
+// is ready, since a GET converts it.
+func TestConversionReadyWithoutACache(t *testing.T) {
+	saved := conversionCache
+	SetConversionCache(nil)
+	t.Cleanup(func() { SetConversionCache(saved) })
+	dir := t.TempDir() + "/"
+	if err := os.WriteFile(dir+"books.zip", []byte("zip"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	fb2 := &models.Book{Path: "books.zip", Format: "fb2"}
+	if !conversionReady(fb2, dir, "mobi") {
+		t.Error("an FB2 whose archive is there is not ready as MOBI")
+	}
+	if conversionReady(&models.Book{Path: "gone.zip", Format: "fb2"}, dir, "mobi") {
+		t.Error("a book whose archive is gone is ready")
+	}
+	if conversionReady(&models.Book{Path: "books.zip", Format: "epub"}, dir, "mobi") {
+		t.Error("a stored EPUB is ready as MOBI, which it is never converted to")
+	}
+}
@@ -3,6 +3,7 @@ package api
 import (
 	"errors"
 	"net/http"
+	"strconv"
 
 	"gopds-api/database"
 	"gopds-api/httputil"
@@ -10,6 +11,7 @@ import (
 	"gopds-api/models"
 
 	"github.com/gin-gonic/gin"
+	"github.com/go-pg/pg/v10"
 )
 
 // ActionUser method for changing user information
@@ -34,12 +36,33 @@ func ActionUser(c *gin.Context) {
 		if len(action.User.NewPassword) > 0 {
 			action.User.Password = action.User.NewPassword
 		}
+		var before any
+		if action.Action == "update" {
+			// Asking for the flag is refused before the account is read;
+			// keeping it off still needs the account not to be a superuser's.
+			if !authorizeUserChange(c, nil, action.User.IsSuperUser) {
+				return
+			}
+			target, err := database.GetUserByID(c.Request.Context(), action.User.ID)
+			if err != nil {
+				logging.Errorf("ActionUser failed: %v", err)
+				c.JSON(500, err)
+				return
+			}
+			if !authorizeUserChange(c, &target, target.IsSuperUser) {
+				return
+			}
+			before = auditUser(c, action.User.ID)
+		}
 		user, err := database.ActionUser(action)
 		if err != nil {
 			logging.Errorf("ActionUser failed: %v", err)
 			c.JSON(500, err)
 			return
 		}
+		if action.Action == "update" {
+			recordAudit(c, models.AuditUserUpdate, "user", user.ID, before, auditedUserOf(user))
+		}
 		logging.Infof("ActionUser completed successfully, returning user: ID=%d, BotToken=%s",
 			user.ID, user.BotToken)
 		c.JSON(200, user)
@@ -62,6 +85,7 @@ func ActionUser(c *gin.Context) {
 // @Success 200 {object} models.Result "User deleted successfully"
 // @Failure 400 {object} httputil.HTTPError "Bad request - invalid input parameters"
 // @Failure 403 {object} httputil.HTTPError "Forbidden - access denied"
+// @Failure 404 {object} httputil.HTTPError "User not found"
 // @Failure 500 {object} httputil.HTTPError "Internal server error"
 // @Router /api/admin/user/{id} [delete]
 func DeleteUser(c *gin.Context) {
@@ -71,11 +95,31 @@ func DeleteUser(c *gin.Context) {
 		return
 	}
 
-	err := database.DeleteUser(userID)
+	id, err := strconv.ParseInt(userID, 10, 64)
+	if err != nil {
+		httputil.NewError(c, http.StatusBadRequest, errors.New("bad request"))
+		return
+	}
+	target, err := database.GetUserByID(c.Request.Context(), id)
+	if errors.Is(err, pg.ErrNoRows) {
+		httputil.NewError(c, http.StatusNotFound, errors.New("user not found"))
+		return
+	}
+	if err != nil {
+		httputil.NewError(c, http.StatusInternalServerError, err)
+		return
+	}
+	if !authorizeUserChange(c, &target, target.IsSuperUser) {
+		return
+	}
+
+	before := auditUser(c, id)
+	err = database.DeleteUser(userID)
 	if err != nil {
 		httputil.NewError(c, http.StatusInternalServerError, err)
 		return
 	}
+	recordAudit(c, models.AuditUserDelete, "user", userID, before, nil)
 
 	c.JSON(200, models.Result{
 		Result: "user_deleted",
@@ -2,177 +2,143 @@ package api
 
 import (
 	"fmt"
-	"io"
 	"net/http"
-	"os"
-	"path/filepath"
 	"strconv"
-	"sync"
 
 	"gopds-api/database"
 	"gopds-api/httputil"
-	"gopds-api/internal/safeio"
 	"gopds-api/logging"
-	"gopds-api/utils"
+	"gopds-api/models"
+	"gopds-api/services"
 
 	"github.com/gin-gonic/gin"
 	"github.com/spf13/viper"
 )
 
-// ConvertedFile holds an in-memory converted file ready for download.
-type ConvertedFile struct {
-	Data        []byte
-	Filename    string
-	ContentType string
-}
+// conversionCache keeps converted books between downloads. Nil until main
+// sets it, in which case every conversion is done afresh.
+var conversionCache *services.ConversionCache
 
-// epubStore is an in-memory store for converted EPUB files keyed by bookID.
-var epubStore sync.Map // key: int64 (bookID), value: *ConvertedFile
+// SetConversionCache installs the cache the download and conversion handlers
+// share.
+func SetConversionCache(cache *services.ConversionCache) {
+	conversionCache = cache
+}
 
+// ConvertBookToMobi converts a book to MOBI ahead of its download, so the
+// reader is told it is ready only once it is.
 func ConvertBookToMobi(bookID int64) error {
-	book, err := database.GetBook(bookID) // Retrieve the book details from the database
-	if err != nil {
-		return err
-	}
-	zipPath := viper.GetString("app.files_path") + book.Path // Construct the path to the book file.
-	mobiConversionDir := viper.GetString("app.mobi_conversion_dir")
+	return warmConversion(bookID, "mobi")
+}
 
-	if !utils.FileExists(zipPath) {
-		return fmt.Errorf("book file not found: %s", zipPath)
-	}
+// ConvertBookToEpub does the same for EPUB.
+func ConvertBookToEpub(bookID int64) error {
+	return warmConversion(bookID, "epub")
+}
 
-	bp := utils.NewBookProcessor(book.FileName, zipPath) // Create a new BookProcessor for the book file.
-	var rc io.ReadCloser
-	rc, err = bp.Mobi()
+// warmConversion puts one conversion into the cache, or, for a book stored
+// in the format, checks that its file opens. The file is closed straight
+// away: the download that follows opens it again.
+func warmConversion(bookID int64, format string) error {
+	book, err := database.GetBook(bookID)
 	if err != nil {
 		return err
 	}
-	defer rc.Close()
-
-	filePath := filepath.Join(mobiConversionDir, fmt.Sprintf("%d.mobi", bookID))
-	logging.Info("Creating mobi file:", filePath)
-	// #nosec G304 -- filePath is the configured conversion directory
-	// joined with a book id, an integer from the database. No part of it
-	// is text from a request.
-	file, err := os.Create(filePath)
+	converted, err := services.OpenBookFile(conversionCache, &book, viper.GetString("app.files_path"), format)
 	if err != nil {
 		return err
 	}
-	defer file.Close()
-
-	if _, err = io.Copy(file, rc); err != nil {
-		return err
+	if closeErr := converted.Content.Close(); closeErr != nil {
+		logging.Warnf("Closing converted book %d: %v", bookID, closeErr)
 	}
-	// Send the file to the client
-	done := make(chan struct{})
-	readyChannels.Store(bookID, done)
-
-	// Delete the file after it has been sent
-	logging.Infof("Book %d converted and stored at %s", bookID, filePath)
+	logging.Infof("Book %d converted to %s (%d bytes)", bookID, format, converted.Size)
 	return nil
 }
 
-func ConvertBookToEpub(bookID int64) error {
-	book, err := database.GetBook(bookID)
-	if err != nil {
-		return err
-	}
-	zipPath := viper.GetString("app.files_path") + book.Path
+// DownloadConvertedBook serves the MOBI a conversion request produced.
+func DownloadConvertedBook(c *gin.Context) {
+	downloadConverted(c, "mobi")
+}
 
-	if !utils.FileExists(zipPath) {
-		return fmt.Errorf("book file not found: %s", zipPath)
-	}
+// DownloadConvertedEpub serves the EPUB a conversion request produced.
+func DownloadConvertedEpub(c *gin.Context) {
+	downloadConverted(c, "epub")
+}
 
-	bp := utils.NewBookProcessor(book.FileName, zipPath)
-	rc, err := bp.Epub()
+// downloadConverted serves a converted book. It normally comes out of the
+// cache the conversion request filled; if it was evicted in between, it is
+// converted again here rather than refused.
+func downloadConverted(c *gin.Context, format string) {
+	bookID, err := strconv.ParseInt(c.Param("id"), 10, 64)
 	if err != nil {
-		return err
+		httputil.NewError(c, http.StatusBadRequest, fmt.Errorf("invalid book ID: %v", err))
+		return
 	}
-	defer rc.Close()
-
-	data, err := safeio.ReadAll(rc, safeio.MaxBookBytes)
+	book, err := database.GetBook(bookID)
 	if err != nil {
-		return err
+		httputil.NewError(c, http.StatusNotFound, err)
+		return
 	}
-
-	epubStore.Store(bookID, &ConvertedFile{
-		Data:        data,
-		Filename:    book.DownloadName() + ".epub",
-		ContentType: "application/epub+zip",
-	})
-
-	logging.Infof("Book %d converted to EPUB and stored in memory (%d bytes)", bookID, len(data))
-	return nil
-}
-
-// deleteFile deletes the file at the specified path
-func deleteFile(filePath string) error {
-	err := os.Remove(filePath)
+	filesPath := viper.GetString("app.files_path")
+	modTime, err := services.BookFileModTime(&book, filesPath)
 	if err != nil {
-		logging.Errorf("Failed to delete file %s: %v", filePath, err)
-	}
-	return err
-}
-
-func DownloadConvertedBook(c *gin.Context) {
-	bookID := c.Param("id")
-	mobiConversionDir := viper.GetString("app.mobi_conversion_dir")
-	filePath := filepath.Join(mobiConversionDir, fmt.Sprintf("%s.mobi", bookID)) // Construct the path to the mobi file
-	contentDisp := "attachment; filename=%s.%s"
-
-	// Check if the file exists
-	if _, err := os.Stat(filePath); os.IsNotExist(err) {
-		c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+		bookFileError(c, err)
 		return
 	}
-	// Convert book ID to int64
-	bookIDInt, err := strconv.ParseInt(bookID, 10, 64)
-	if err != nil {
-		httputil.NewError(c, http.StatusBadRequest, fmt.Errorf("invalid book ID: %v", err))
+	if httputil.NotModified(c, services.BookFileETag(&book, format), modTime) {
 		return
 	}
-	book, err := database.GetBook(bookIDInt) // Retrieve the book details from the database.
+	file, err := services.OpenBookFile(conversionCache, &book, filesPath, format)
 	if err != nil {
-		httputil.NewError(c, http.StatusNotFound, err) // Send a 404 Not Found if the book is not in the database.
+		bookFileError(c, err)
 		return
 	}
+	defer file.Content.Close()
 
-	c.Header("Content-Disposition", fmt.Sprintf(contentDisp, book.DownloadName(), "mobi")) // Set the Content-Disposition header.
-	c.Header("Content-Type", "application/x-mobipocket-ebook")                             // Set the Content-Type header to the mobi format.
-
-	// Send the file to the client
-	c.File(filePath)
-
-	// Delete the file after it has been sent
-	go func() {
-		err := deleteFile(filePath)
-		if err != nil {
-			logging.Errorf("Failed to delete mobi file: %v", err)
-		}
-	}()
-}
-
-// HeadConvertedBook handles HEAD requests for converted MOBI files.
-func HeadConvertedBook(c *gin.Context) {
-	bookID := c.Param("id")
-	mobiConversionDir := viper.GetString("app.mobi_conversion_dir")
-	filePath := filepath.Join(mobiConversionDir, fmt.Sprintf("%s.mobi", bookID))
-
-	if _, err := os.Stat(filePath); os.IsNotExist(err) {
-		c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
-		return
+	if httputil.StartsDownload(c) {
+		services.RecordDownload(c.Request.Context(), c.GetInt64("user_id"), book.ID)
 	}
+	serveBookFile(c, file, book.DownloadName()+"."+format, bookTypes[format])
+}
 
-	bookIDInt, err := strconv.ParseInt(bookID, 10, 64)
+// headConverted answers whether a conversion is ready to download.
+func headConverted(c *gin.Context, format string) {
+	bookID, err := strconv.ParseInt(c.Param("id"), 10, 64)
 	if err != nil {
 		httputil.NewError(c, http.StatusBadRequest, fmt.Errorf("invalid book ID: %v", err))
 		return
 	}
-	if _, err := database.GetBook(bookIDInt); err != nil {
+	book, err := database.GetBook(bookID)
+	if err != nil {
 		httputil.NewError(c, http.StatusNotFound, err)
 		return
 	}
-
-	c.Header("Content-Type", "application/x-mobipocket-ebook")
+	if !conversionReady(&book, viper.GetString("app.files_path"), format) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+		return
+	}
+	c.Header("Content-Type", bookTypes[format])
+	c.Header("ETag", services.BookFileETag(&book, format))
 	c.Status(http.StatusOK)
 }
+
+// conversionReady reports whether book can be downloaded in format without
+// waiting. A book stored in the format is ready as it is; any other is ready
+// once converted. Without a cache nothing is kept, so any book whose archive
+// is there is ready: a download converts it.
+func conversionReady(book *models.Book, filesPath, format string) bool {
+	switch {
+	case book.Format == format:
+		return true
+	case conversionCache == nil:
+		_, err := services.BookFileModTime(book, filesPath)
+		return err == nil && services.BookFormatAvailable(book, format)
+	default:
+		return conversionCache.Contains(book.MD5, format)
+	}
+}
+
+// HeadConvertedBook handles HEAD requests for converted MOBI files.
+func HeadConvertedBook(c *gin.Context) {
+	headConverted(c, "mobi")
+}
@@ -18,10 +18,15 @@ func UnifiedWebSocketHandler(c *gin.Context) {
 	username, _ := c.Get("username")
 	userIDVal, _ := c.Get("user_id")
 	isSuperVal, _ := c.Get("is_superuser")
+	permsVal, _ := c.Get("permissions")
 
 	userID, _ := userIDVal.(int64)
 	isSuperUser, _ := isSuperVal.(bool)
+	perms, _ := permsVal.([]string)
 	user, _ := username.(string)
+	// Admin events go to every member of the admin staff, not only
+	// superusers: a librarian follows the scans they start.
+	isAdmin := isSuperUser || len(perms) > 0
 
 	conn, err := upgradeWebSocket(c)
 	if err != nil {
@@ -35,11 +40,11 @@ func UnifiedWebSocketHandler(c *gin.Context) {
 
 	var clientID uint64
 	if wsManager != nil {
-		clientID = wsManager.RegisterClient(conn, userID, user, isSuperUser, notifyChan)
+		clientID = wsManager.RegisterClient(conn, userID, user, isAdmin, notifyChan)
 		defer wsManager.UnregisterClient(clientID)
 	}
 
-	logging.Infof("WebSocket connected: user=%s (id=%d, admin=%v)", user, userID, isSuperUser)
+	logging.Infof("WebSocket connected: user=%s (id=%d, admin=%v)", user, userID, isAdmin)
 
 	// Reader goroutine: handles incoming messages from the client.
 	go func() {
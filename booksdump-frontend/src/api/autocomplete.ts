@@ -1,14 +1,14 @@
 import { http } from '@/api/http';
 
 export interface AutocompleteSuggestion {
-    /** Display text: the book title or the author name. */
+    /** Display text: the book title, the author, series or genre name. */
     value: string;
     /** What the suggestion opens. */
-    type: 'book' | 'author';
+    type: 'book' | 'author' | 'series' | 'genre';
     id?: number;
     /** Book lane only: the author, telling identical titles apart. */
     secondary?: string;
-    /** Author lane only: how many visible books the author holds. */
+    /** How many visible books are behind the row. */
     books_count?: number;
 }
 
@@ -59,7 +59,7 @@ export const autocompleteService = {
      */
     getSuggestions: async (
         query: string,
-        type: 'all' | 'title' | 'author' = 'all',
+        type: 'all' | 'title' | 'author' | 'series' | 'genre' = 'all',
         scope?: SuggestionScope,
         lang?: string,
         signal?: AbortSignal,
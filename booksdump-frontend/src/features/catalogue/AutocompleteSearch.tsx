@@ -1,6 +1,6 @@
 import React, { useCallback, useEffect, useRef, useState } from 'react';
 import { useTranslation } from 'react-i18next';
-import { BookOpen, Loader2, User, X } from 'lucide-react';
+import { BookOpen, Library, Loader2, Tag, User, X } from 'lucide-react';
 
 import { inputFocusRing, inputFrame } from '@/shared/ui/input';
 import { cn } from '@/shared/lib/utils';
@@ -394,6 +394,16 @@ const AutocompleteSearch: React.FC<AutocompleteSearchProps> = ({
                                             aria-hidden="true"
                                             className="size-4 shrink-0 text-muted-foreground"
                                         />
+                                    ) : suggestion.type === 'series' ? (
+                                        <Library
+                                            aria-hidden="true"
+                                            className="size-4 shrink-0 text-muted-foreground"
+                                        />
+                                    ) : suggestion.type === 'genre' ? (
+                                        <Tag
+                                            aria-hidden="true"
+                                            className="size-4 shrink-0 text-muted-foreground"
+                                        />
                                     ) : (
                                         <BookOpen
                                             aria-hidden="true"
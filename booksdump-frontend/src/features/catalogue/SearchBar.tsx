@@ -145,6 +145,18 @@ const SearchBar: React.FC = () => {
             navigate(`/books/find/author/${suggestion.id}/1`);
             return;
         }
+        if (suggestion.type === 'series' || suggestion.type === 'genre') {
+            // A series or a genre is a list: open it, and the route puts the
+            // scope on. The name is not a title to filter it by.
+            if (suggestion.id == null) {
+                return;
+            }
+            clearAuthorId();
+            setSearchItem('');
+            const list = suggestion.type === 'series' ? 'category' : 'genre';
+            navigate(`/books/find/${list}/${suggestion.id}/1`);
+            return;
+        }
         // A picked book is a title, not a copy of it. Pinning the suggestion's
         // own id used to answer with exactly one book, which is wrong for the
         // reason readers pick from the list at all: someone choosing "Властелин
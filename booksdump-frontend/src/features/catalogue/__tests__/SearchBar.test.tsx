@@ -88,7 +88,7 @@ vi.mock('@/features/catalogue/AutocompleteSearch', () => ({
         onEnterPressed: () => void;
         onSuggestionSelected?: (suggestion: {
             value: string;
-            type: 'book' | 'author';
+            type: 'book' | 'author' | 'series' | 'genre';
             id?: number;
         }) => void;
         onClear?: () => void;
@@ -135,6 +135,16 @@ vi.mock('@/features/catalogue/AutocompleteSearch', () => ({
                     onSuggestionSelected?.({ value: 'Толстой Лев', type: 'author', id: 42 })
                 }
             />
+            <button
+                type="button"
+                aria-label="pickSeries"
+                onClick={() => onSuggestionSelected?.({ value: 'Ведьмак', type: 'series', id: 7 })}
+            />
+            <button
+                type="button"
+                aria-label="pickGenre"
+                onClick={() => onSuggestionSelected?.({ value: 'Фэнтези', type: 'genre', id: 9 })}
+            />
         </div>
     ),
 }));
@@ -400,6 +410,16 @@ describe('SearchBar suggestion picks', () => {
         expect(currentPath).toBe('/books/find/author/42/1');
     });
 
+    it('opens the listing of a picked series or genre', async () => {
+        renderBar('/books/page/1');
+
+        await userEvent.click(screen.getByRole('button', { name: 'pickSeries' }));
+        expect(currentPath).toBe('/books/find/category/7/1');
+
+        await userEvent.click(screen.getByRole('button', { name: 'pickGenre' }));
+        expect(currentPath).toBe('/books/find/genre/9/1');
+    });
+
     it('gives the row to the chip on a phone, and keeps the row', () => {
         // The chip needs the whole width on 360px, so the label yields — but
         // the row it stood in stays, which is what keeps the card from
@@ -1,13 +1,155 @@
 package main
 
 import (
+	"context"
+	"os"
+	"strings"
+
 	"gopds-api/api"
+	"gopds-api/internal/oidc"
 	"gopds-api/logging"
+	"gopds-api/middlewares"
+	"gopds-api/opds"
+	"gopds-api/services"
+	"gopds-api/telegram"
 )
 
 // initializeServices initializes application services
 func initializeServices() {
 	// Initialize WebSocket manager for admin notifications
 	api.InitWebSocketManager()
+	go services.SeedGenreTaxonomy(context.Background())
+	services.InterruptBookBulkEdits(context.Background())
+	go services.RunAuditRetention(context.Background(), cfg.Audit.RetentionDays)
+	go services.RunSearchDictionaryRefresh(context.Background())
 	logging.Info("Application services initialized")
 }
+
+// initializeBookRequests sets up the book requests and hands them to the
+// scans, which fulfil them. Readers are told over the WebSocket, by e-mail
+// and through their own Telegram bot, whichever reaches them.
+func initializeBookRequests(bots *telegram.BotManager) *services.BookRequestService {
+	svc := services.NewBookRequestService(
+		api.BookRequestWebSocketNotifier(),
+		services.NewEmailBookRequestNotifier(cfg.ProjectURL),
+		bots,
+	)
+	api.SetBookRequestFulfiller(svc)
+	return svc
+}
+
+// initializeBookUploads sets up the uploads readers send, kept in the
+// inbox archives under the library directory until a moderator decides.
+func initializeBookUploads() *services.BookUploadService {
+	return services.NewBookUploadService(
+		api.BookScanner(),
+		services.NewUploadInbox(cfg.App.FilesPath, cfg.Uploads.Dir),
+		services.UploadLimits{
+			MaxBytes:     cfg.Uploads.MaxBytes,
+			DailyLimit:   cfg.Uploads.DailyLimit,
+			PendingLimit: cfg.Uploads.PendingLimit,
+		},
+	)
+}
+
+// initializeConversionCache opens the on-disk cache of converted books and
+// hands it to the download handlers. Like the preview, it degrades rather
+// than stopping the server: without it every EPUB and MOBI is converted
+// afresh, which is slower but still correct.
+func initializeConversionCache() *services.ConversionCache {
+	cache, err := services.NewConversionCache(cfg.Conversion.CacheDir, cfg.Conversion.CacheMaxBytes)
+	if err != nil {
+		logging.Errorf("Conversion cache unavailable: %v — books will be converted on every download", err)
+		return nil
+	}
+	api.SetConversionCache(cache)
+	opds.SetConversionCache(cache)
+	return cache
+}
+
+// initializeCoverThumbnails sets up the on-demand cover thumbnails and starts
+// filling in the cover placeholders of books scanned before they existed.
+// Without the thumbnail directory the full-size poster is served for every
+// width asked for.
+func initializeCoverThumbnails() {
+	go services.BackfillCoverPlaceholders(context.Background(), cfg.App.PostersPath)
+
+	thumbnails, err := services.NewCoverThumbnails(cfg.App.PostersPath, cfg.App.ThumbnailsPath)
+	if err != nil {
+		logging.Errorf("Cover thumbnails unavailable: %v — full-size covers will be served", err)
+		return
+	}
+	logging.Infof("Cover thumbnails in %s, formats: %v", cfg.App.ThumbnailsPath, thumbnails.Formats())
+	api.SetCoverThumbnails(thumbnails)
+}
+
+// initializeOIDC sets up the login through an OpenID provider, when it is
+// enabled. The provider is discovered at the first login, not here, so an
+// unreachable provider does not keep the server from starting.
+func initializeOIDC() *api.OIDCHandler {
+	if !cfg.OIDC.Enabled {
+		return nil
+	}
+	redirectURL := cfg.OIDC.RedirectURL
+	if redirectURL == "" {
+		redirectURL = strings.TrimSuffix(cfg.ProjectURL, "/") + "/api/oidc/callback"
+	}
+	provider := oidc.NewProvider(oidc.Config{
+		Issuer:       cfg.OIDC.Issuer,
+		ClientID:     cfg.OIDC.ClientID,
+		ClientSecret: cfg.OIDC.ClientSecret,
+		RedirectURL:  redirectURL,
+		Scopes:       cfg.OIDC.Scopes,
+	})
+	svc := services.NewOIDCService(provider, services.OIDCRules{
+		AutoProvision:       cfg.OIDC.AutoProvision,
+		GroupsClaim:         cfg.OIDC.GroupsClaim,
+		AllowedGroups:       cfg.OIDC.AllowedGroups,
+		AllowedEmailDomains: cfg.OIDC.AllowedEmailDomains,
+	})
+	logging.Infof("OIDC login through %s, callback %s", cfg.OIDC.Issuer, redirectURL)
+	return &api.OIDCHandler{Svc: svc, Label: cfg.OIDC.Label, Secret: []byte(cfg.Sessions.Key)}
+}
+
+// initializeTwoFactor sets up the TOTP second factor every login checks,
+// and whether the admin API insists on it.
+func initializeTwoFactor() *api.TwoFactorHandler {
+	issuer := cfg.TwoFactor.Issuer
+	if issuer == "" {
+		issuer = cfg.Email.ProductName
+	}
+	svc, err := services.NewTwoFactorService(issuer, cfg.SecretKey)
+	if err != nil {
+		logging.Errorf("Two-factor authentication: %v", err)
+		os.Exit(1)
+	}
+	api.SetTwoFactor(svc)
+	middlewares.SetAdminTwoFactorRequired(cfg.TwoFactor.RequireForAdmins)
+	return &api.TwoFactorHandler{Svc: svc, RequireForAdmins: cfg.TwoFactor.RequireForAdmins}
+}
+
+// initializeAPITokens sets up the personal access tokens AuthMiddleware
+// accepts as bearer credentials, and returns the handler that issues them.
+func initializeAPITokens() *api.APITokensHandler {
+	svc := services.NewAPITokenService()
+	middlewares.SetAPITokens(svc, api.APITokenScopeRules)
+	return &api.APITokensHandler{Svc: svc, RequireTwoFactorForAdmin: cfg.TwoFactor.RequireForAdmins}
+}
+
+// initializeOPDS names the OPDS catalogue and lays out its root feed.
+func initializeOPDS() {
+	title := cfg.OPDS.Title
+	if title == "" {
+		title = cfg.Email.ProductName
+	}
+	err := opds.Configure(opds.Settings{
+		Title:      title,
+		Icon:       cfg.OPDS.Icon,
+		Language:   cfg.OPDS.Language,
+		Navigation: cfg.OPDS.Navigation,
+	})
+	if err != nil {
+		logging.Errorf("OPDS catalogue: %v", err)
+		os.Exit(1)
+	}
+}
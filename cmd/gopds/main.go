@@ -10,6 +10,7 @@ import (
 	"os/signal"
 	"time"
 
+	"gopds-api/api"
 	"gopds-api/database"
 	_ "gopds-api/internal/swaggerdocs" // Import to include documentation for Swagger UI
 	"gopds-api/logging"
@@ -36,6 +37,30 @@ var telegramService *telegram.TelegramService
 // alongside the other dependencies. The phase-4 HTTP handlers consume it.
 var previewService *services.PreviewService
 
+// recommendationService scores "more like this" and "recommended for you"
+// lists for the REST API, the OPDS feeds and the Telegram bot.
+var recommendationService *services.RecommendationService
+
+// conversionCache keeps converted EPUB and MOBI files between downloads. Nil
+// when its directory could not be opened.
+var conversionCache *services.ConversionCache
+
+// oidcHandler serves the OpenID Connect login. Nil unless oidc.enabled.
+var oidcHandler *api.OIDCHandler
+
+// twoFactorHandler serves the TOTP second factor.
+var twoFactorHandler *api.TwoFactorHandler
+
+// apiTokensHandler serves the personal access tokens.
+var apiTokensHandler *api.APITokensHandler
+
+// bookRequestService keeps the readers' book requests, which the scans
+// fulfil.
+var bookRequestService *services.BookRequestService
+
+// bookUploadService takes books from readers into the moderation queue.
+var bookUploadService *services.BookUploadService
+
 func main() {
 	loadConfiguration()
 
@@ -45,7 +70,10 @@ func main() {
 
 	// One search service for every adapter, built on the same pool the
 	// package-global database helpers use.
-	searchService := services.NewSearchService(database.NewPGSearchRepository(db))
+	searchService := services.NewSearchService(database.NewPGSearchRepository(db)).
+		WithQueryRewrites().
+		WithSpellingSuggestions()
+	recommendationService = services.NewRecommendationService(database.NewPGRecommendationRepository(db))
 
 	mainRedisClient, tokenRedisClient := initializeSessionManagement()
 	sessions.SetRedisConnections(mainRedisClient, tokenRedisClient)
@@ -62,7 +90,8 @@ func main() {
 	telegramConfig := &telegram.Config{
 		BaseURL: cfg.GetTelegramWebhookBaseURL(),
 	}
-	telegramBotManager := telegram.NewBotManager(telegramConfig, mainRedisClient, searchService)
+	telegramBotManager := telegram.NewBotManager(telegramConfig, mainRedisClient, searchService).
+		WithRecommendations(recommendationService)
 
 	// Initialize Telegram service
 	var err error
@@ -93,6 +122,14 @@ func main() {
 
 	// Initialize application services (WebSocket manager, etc.)
 	initializeServices()
+	conversionCache = initializeConversionCache()
+	initializeCoverThumbnails()
+	oidcHandler = initializeOIDC()
+	twoFactorHandler = initializeTwoFactor()
+	apiTokensHandler = initializeAPITokens()
+	bookRequestService = initializeBookRequests(telegramBotManager)
+	bookUploadService = initializeBookUploads()
+	initializeOPDS()
 
 	// Start watching the directory for e-book conversion tasks
 	go tasks.WatchDirectory(cfg.App.MobiConversionDir, 10*time.Minute)
@@ -111,6 +111,7 @@ func setupDefaultRoutes(route *gin.Engine, donate []config.DonateMethod) {
 // setupOpdsRoutes configures routes for OPDS feed interactions.
 func setupOpdsRoutes(group *gin.RouterGroup, search services.PublicSearch) {
 	opds.SetupOpdsRoutes(group, search)
+	opds.SetupRecommendationRoutes(group, recommendationService)
 }
 
 func setupLogoutRoutes(group *gin.RouterGroup) {
@@ -125,30 +126,81 @@ func setupApiRoutes(group *gin.RouterGroup, search services.PublicSearch) {
 	// widening SetupBookRoutes to carry it would make every caller — tests
 	// included — supply a dependency none of the other routes use.
 	api.SetupPreviewRoutes(booksGroup, previewService)
+	recommendationsHandler := &api.RecommendationsHandler{Svc: recommendationService}
+	recommendationsHandler.Register(booksGroup)
+	ratingsHandler := &api.RatingsHandler{Svc: services.NewRatingService()}
+	ratingsHandler.Register(booksGroup)
+	bookRequestsHandler := &api.BookRequestsHandler{Svc: bookRequestService}
+	bookRequestsHandler.Register(group.Group("/requests"))
+	bookUploadsHandler := &api.BookUploadsHandler{Svc: bookUploadService, MaxBytes: cfg.Uploads.MaxBytes}
+	bookUploadsHandler.Register(group.Group("/uploads"))
 
 	publicCollections := &api.PublicCollectionsHandler{
 		Svc: services.NewPublicCuratedCollectionsService(),
 	}
 	publicCollections.Register(group.Group("/collections"))
 
+	if oidcHandler != nil {
+		oidcHandler.RegisterAccount(group.Group("/oidc"))
+	}
+	twoFactorHandler.Register(group.Group("/2fa"))
+
+	sessionsHandler := &api.SessionsHandler{Svc: services.NewSessionService()}
+	sessionsHandler.Register(group.Group("/sessions"))
+	apiTokensHandler.Register(group.Group("/tokens"))
+
 	// Setup admin routes with admin middleware
-	adminGroup := group.Group("/admin", middlewares.AdminMiddleware())
+	adminGroup := group.Group("/admin", middlewares.AdminMiddleware(api.AdminPermissionRules))
 	setupAdminRoutes(adminGroup)
 }
 
 // setupAdminRoutes configures routes for administrative functionalities.
 func setupAdminRoutes(group *gin.RouterGroup) {
+	auditLog := services.NewAuditLog()
+	api.SetAuditLog(auditLog)
+	auditHandler := &api.AuditHandler{Svc: auditLog}
+	auditHandler.Register(group.Group("/audit"))
+
+	rolesHandler := &api.RolesHandler{Svc: services.NewRolesService()}
+	rolesHandler.Register(group.Group("/roles"))
+	twoFactorHandler.RegisterAdmin(group)
+
 	api.SetupAdminRoutes(group)
 
 	curatedHandler := &api.CuratedCollectionsHandler{
 		Svc: services.NewCuratedCollectionsService(),
 	}
 	curatedHandler.Register(group.Group("/collections"))
+
+	authorsHandler := &api.AuthorsHandler{Svc: services.NewAuthorsService()}
+	authorsHandler.Register(group.Group("/authors"))
+
+	reviewsHandler := &api.ReviewsAdminHandler{Svc: services.NewRatingService()}
+	reviewsHandler.Register(group.Group("/reviews"))
+
+	bookRequestsHandler := &api.BookRequestsAdminHandler{Svc: bookRequestService}
+	bookRequestsHandler.Register(group.Group("/requests"))
+
+	bookUploadsHandler := &api.BookUploadsAdminHandler{Svc: bookUploadService}
+	bookUploadsHandler.Register(group.Group("/uploads"))
+
+	bulkEditHandler := &api.BookBulkEditHandler{
+		Svc: services.NewBookBulkEditService(api.AdminEventPublisher()),
+	}
+	bulkEditHandler.Register(group.Group("/books/bulk"))
+
+	if conversionCache != nil {
+		conversionHandler := &api.ConversionCacheHandler{Cache: conversionCache}
+		conversionHandler.Register(group.Group("/conversions"))
+	}
 }
 
 // setupPublicAuthRoutes configures public authentication routes that do not require middleware authorization.
 func setupPublicAuthRoutes(group *gin.RouterGroup) {
 	api.SetupAuthRoutes(group)
+	if oidcHandler != nil {
+		oidcHandler.Register(group.Group("/oidc"))
+	}
 }
 
 // setupTelegramWebhookRoutes configures routes for Telegram webhook interactions.
@@ -6,7 +6,12 @@
 // judges the aggregates against a capture-mode baseline; the metric functions
 // below stay the judge either way. Both modes reach the catalog through
 // PGSearchRepository, for books and authors alike — the pre-overhaul paths they
-// were first written against no longer exist.
+// were first written against no longer exist. With -rewrite they go through
+// the search service instead, query rewrites on as in production, so a
+// compare against a plain capture measures what layout and transliteration
+// rewriting wins and what it costs. With -suggest a search that finds nothing
+// offers its corrected queries, and the unanswered rate counts the queries
+// left with neither results nor a suggestion.
 package main
 
 import (
@@ -14,6 +19,7 @@ import (
 	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
 	"math"
 	"os"
 	"os/exec"
@@ -22,7 +28,9 @@ import (
 	"time"
 
 	"gopds-api/database"
+	"gopds-api/logging"
 	"gopds-api/models"
+	"gopds-api/services"
 
 	"github.com/go-pg/pg/v10"
 )
@@ -78,8 +86,9 @@ func main() {
 }
 
 func usage() {
-	fmt.Fprintln(os.Stderr, "usage: search-eval capture -input <queries.json> -out <report.json> [db flags]")
-	fmt.Fprintln(os.Stderr, "       search-eval compare -input <queries.json> -baseline <baseline.json> -out <report.json> [db flags]")
+	fmt.Fprintln(os.Stderr, "usage: search-eval capture -input <queries.json> -out <report.json> [-rewrite] [-suggest] [db flags]")
+	fmt.Fprintln(os.Stderr, "       search-eval compare -input <queries.json> -baseline <baseline.json> -out <report.json>"+
+		" [-rewrite] [-suggest] [db flags]")
 }
 
 // querySet is the reviewed input file: one entry per measured search request.
@@ -130,6 +139,11 @@ type queryReport struct {
 	P50Millis       int64            `json:"p50_ms"`
 	P95Millis       int64            `json:"p95_ms"`
 	CaptureNote     string           `json:"capture_note,omitempty"`
+	// Rewrite is the query the results answer when the service rewrote the
+	// one in the set.
+	Rewrite *models.QueryRewrite `json:"rewrite,omitempty"`
+	// DidYouMean is what the service suggested when the query found nothing.
+	DidYouMean []models.SpellingSuggestion `json:"did_you_mean,omitempty"`
 
 	RelevantIDs    []int64 `json:"relevant_ids"`
 	RecallAtK      float64 `json:"recall_at_k"`
@@ -159,6 +173,12 @@ type aggregateReport struct {
 	RecallAtK      float64 `json:"recall_at_k"`
 	MRR            float64 `json:"mrr"`
 	ZeroResultRate float64 `json:"zero_result_rate"`
+	// RewrittenQueries counts the queries answered for a rewrite.
+	RewrittenQueries int `json:"rewritten_queries"`
+	// SuggestedQueries counts the queries that found nothing but suggested
+	// a correction; UnansweredRate is the fraction that did neither.
+	SuggestedQueries int     `json:"suggested_queries"`
+	UnansweredRate   float64 `json:"unanswered_rate"`
 }
 
 // evalReport is the written artifact of a capture run.
@@ -167,9 +187,13 @@ type evalReport struct {
 	Mode       string             `json:"mode"`
 	Database   string             `json:"database"`
 	Catalog    catalogFingerprint `json:"catalog"`
-	Queries    []queryReport      `json:"queries"`
-	Aggregate  aggregateReport    `json:"aggregate"`
-	Comparison *comparisonReport  `json:"comparison,omitempty"`
+	// Rewrites and Suggestions record that the run searched with query
+	// rewrites and spelling suggestions on.
+	Rewrites    bool              `json:"rewrites"`
+	Suggestions bool              `json:"suggestions"`
+	Queries     []queryReport     `json:"queries"`
+	Aggregate   aggregateReport   `json:"aggregate"`
+	Comparison  *comparisonReport `json:"comparison,omitempty"`
 }
 
 // comparisonReport records how a compare run scored against the capture-mode
@@ -194,9 +218,14 @@ type comparisonReport struct {
 	RecallDelta       float64  `json:"recall_delta"`
 	MRRDelta          float64  `json:"mrr_delta"`
 	ZeroRateDelta     float64  `json:"zero_result_rate_delta"`
-	RegressedQueries  []string `json:"regressed_queries,omitempty"`
-	LostQueries       []string `json:"lost_queries,omitempty"`
-	Verdict           string   `json:"verdict"` // "pass" or "regression"
+	// The unanswered rates are reported, not judged: whether suggestions
+	// were on is a setting of the run, not a change in relevance.
+	BaselineUnansweredRate float64  `json:"baseline_unanswered_rate"`
+	CurrentUnansweredRate  float64  `json:"current_unanswered_rate"`
+	UnansweredRateDelta    float64  `json:"unanswered_rate_delta"`
+	RegressedQueries       []string `json:"regressed_queries,omitempty"`
+	LostQueries            []string `json:"lost_queries,omitempty"`
+	Verdict                string   `json:"verdict"` // "pass" or "regression"
 }
 
 // connectEval opens the eval connection and makes it the package-global one.
@@ -227,7 +256,7 @@ func connectEval(addr, user, pass, name string) *pg.DB {
 // baseline is one frozen sample of code that no longer exists, taken under
 // conditions nothing here can reproduce, and no amount of repetition now can
 // fix its side of the subtraction.
-func runSet(repo *database.PGSearchRepository, db *pg.DB, set querySet, rounds int) []queryReport {
+func runSet(search services.PublicSearch, db *pg.DB, set querySet, rounds int) []queryReport {
 	reports := make([]queryReport, len(set.Queries))
 	for i := range set.Queries {
 		reports[i] = queryReport{evalQuery: set.Queries[i]}
@@ -236,7 +265,7 @@ func runSet(repo *database.PGSearchRepository, db *pg.DB, set querySet, rounds i
 	// Warm-up round, unrecorded: the first execution of each query pays for
 	// plan caching and first-touch page faults that no later round repeats.
 	for i := range set.Queries {
-		if _, _, _, err := runQuery(repo, &set.Queries[i]); err != nil {
+		if _, err := runQuery(search, &set.Queries[i]); err != nil {
 			fatalf("query %q: %v", set.Queries[i].Name, err)
 		}
 	}
@@ -245,15 +274,17 @@ func runSet(repo *database.PGSearchRepository, db *pg.DB, set querySet, rounds i
 		for i := range set.Queries {
 			q := &set.Queries[i]
 			start := time.Now()
-			results, total, note, err := runQuery(repo, q)
+			answer, err := runQuery(search, q)
 			if err != nil {
 				fatalf("query %q: %v", q.Name, err)
 			}
 			reports[i].DurationsMillis = append(reports[i].DurationsMillis, time.Since(start).Milliseconds())
 			if round == 0 {
-				reports[i].Results = results
-				reports[i].Total = total
-				reports[i].CaptureNote = note
+				reports[i].Results = answer.results
+				reports[i].Total = answer.total
+				reports[i].CaptureNote = answer.note
+				reports[i].Rewrite = answer.rewrite
+				reports[i].DidYouMean = answer.didYouMean
 			}
 		}
 	}
@@ -267,6 +298,12 @@ func runSet(repo *database.PGSearchRepository, db *pg.DB, set querySet, rounds i
 			"%-36s total=%-6d relevant=%-5d recall=%.3f rr=%.2f min=%dms p50=%dms p95=%dms\n",
 			rep.Name, rep.Total, len(rep.RelevantIDs), rep.RecallAtK, rep.ReciprocalRank,
 			rep.MinMillis, rep.P50Millis, rep.P95Millis)
+		if rep.Rewrite != nil {
+			fmt.Fprintf(os.Stderr, "%-36s answered for %s rewrite %q\n", "", rep.Rewrite.Kind, rep.Rewrite.Query)
+		}
+		for _, suggestion := range rep.DidYouMean {
+			fmt.Fprintf(os.Stderr, "%-36s did you mean %q (%d)\n", "", suggestion.Query, suggestion.Total)
+		}
 	}
 	return reports
 }
@@ -334,13 +371,15 @@ func writeReport(path string, report *evalReport) {
 func capture(args []string) {
 	fs := flag.NewFlagSet("capture", flag.ExitOnError)
 	var (
-		input  = fs.String("input", "", "reviewed query set JSON (required)")
-		out    = fs.String("out", "", "report output path (required)")
-		repeat = fs.Int("repeat", defaultRepeat, "measured rounds over the whole set, after one unrecorded warm-up round")
-		addr   = fs.String("host", envOr("GOPDS_POSTGRES_DBHOST", "127.0.0.1:5432"), "database host:port")
-		user   = fs.String("user", envOr("GOPDS_POSTGRES_DBUSER", "gopds"), "database user")
-		pass   = fs.String("password", os.Getenv("GOPDS_POSTGRES_DBPASS"), "database password")
-		name   = fs.String("database", envOr("GOPDS_POSTGRES_DBNAME", "gopds"), "database name")
+		input   = fs.String("input", "", "reviewed query set JSON (required)")
+		out     = fs.String("out", "", "report output path (required)")
+		repeat  = fs.Int("repeat", defaultRepeat, "measured rounds over the whole set, after one unrecorded warm-up round")
+		rewrite = fs.Bool("rewrite", false, "search through the service with query rewrites on, as production does")
+		suggest = fs.Bool("suggest", false, "search through the service with spelling suggestions on, as production does")
+		addr    = fs.String("host", envOr("GOPDS_POSTGRES_DBHOST", "127.0.0.1:5432"), "database host:port")
+		user    = fs.String("user", envOr("GOPDS_POSTGRES_DBUSER", "gopds"), "database user")
+		pass    = fs.String("password", os.Getenv("GOPDS_POSTGRES_DBPASS"), "database password")
+		name    = fs.String("database", envOr("GOPDS_POSTGRES_DBNAME", "gopds"), "database name")
 	)
 	_ = fs.Parse(args)
 
@@ -358,12 +397,14 @@ func capture(args []string) {
 	defer func() { _ = db.Close() }()
 
 	report := evalReport{
-		CapturedAt: time.Now().UTC(),
-		Mode:       modeCapture,
-		Database:   fmt.Sprintf("%s@%s/%s", *user, *addr, *name),
-		Catalog:    fingerprint(context.Background(), db),
-	}
-	report.Queries = runSet(database.NewPGSearchRepository(db), db, set, *repeat)
+		CapturedAt:  time.Now().UTC(),
+		Mode:        modeCapture,
+		Database:    fmt.Sprintf("%s@%s/%s", *user, *addr, *name),
+		Catalog:     fingerprint(context.Background(), db),
+		Rewrites:    *rewrite,
+		Suggestions: *suggest,
+	}
+	report.Queries = runSet(evalSearch(db, *rewrite, *suggest), db, set, *repeat)
 	report.Aggregate = aggregate(report.Queries)
 	writeReport(*out, &report)
 }
@@ -383,6 +424,8 @@ func compare(args []string) int {
 		baseline = fs.String("baseline", "", "capture-mode baseline report JSON (required)")
 		out      = fs.String("out", "", "report output path (required)")
 		repeat   = fs.Int("repeat", defaultRepeat, "measured rounds over the whole set, after one unrecorded warm-up round")
+		rewrite  = fs.Bool("rewrite", false, "search through the service with query rewrites on, as production does")
+		suggest  = fs.Bool("suggest", false, "search through the service with spelling suggestions on, as production does")
 		addr     = fs.String("host", envOr("GOPDS_POSTGRES_DBHOST", "127.0.0.1:5432"), "database host:port")
 		user     = fs.String("user", envOr("GOPDS_POSTGRES_DBUSER", "gopds"), "database user")
 		pass     = fs.String("password", os.Getenv("GOPDS_POSTGRES_DBPASS"), "database password")
@@ -408,10 +451,12 @@ func compare(args []string) int {
 	defer func() { _ = db.Close() }()
 
 	report := evalReport{
-		CapturedAt: time.Now().UTC(),
-		Mode:       modeCompare,
-		Database:   fmt.Sprintf("%s@%s/%s", *user, *addr, *name),
-		Catalog:    fingerprint(context.Background(), db),
+		CapturedAt:  time.Now().UTC(),
+		Mode:        modeCompare,
+		Database:    fmt.Sprintf("%s@%s/%s", *user, *addr, *name),
+		Catalog:     fingerprint(context.Background(), db),
+		Rewrites:    *rewrite,
+		Suggestions: *suggest,
 	}
 
 	if report.Catalog.Books != base.Catalog.Books ||
@@ -421,7 +466,16 @@ func compare(args []string) int {
 			report.Catalog, base.Catalog)
 	}
 
-	report.Queries = runSet(database.NewPGSearchRepository(db), db, set, *repeat)
+	if report.Rewrites != base.Rewrites {
+		fmt.Fprintf(os.Stderr, "rewrites %v against a baseline with rewrites %v: the deltas measure them\n",
+			report.Rewrites, base.Rewrites)
+	}
+	if report.Suggestions != base.Suggestions {
+		fmt.Fprintf(os.Stderr, "suggestions %v against a baseline with suggestions %v: the unanswered rates measure them\n",
+			report.Suggestions, base.Suggestions)
+	}
+
+	report.Queries = runSet(evalSearch(db, *rewrite, *suggest), db, set, *repeat)
 	report.Aggregate = aggregate(report.Queries)
 	cmp := compareAggregates(report.Queries, base.Queries)
 	report.Comparison = &cmp
@@ -434,16 +488,38 @@ func compare(args []string) int {
 	return 0
 }
 
+// evalSearch is the search path a run measures: the repository itself, or
+// the service in front of it with query rewrites or spelling suggestions on.
+// The service logs every search; here that is noise, and its writing would
+// be timed with the query.
+func evalSearch(db *pg.DB, rewrite, suggest bool) services.PublicSearch {
+	repo := database.NewPGSearchRepository(db)
+	if !rewrite && !suggest {
+		return repo
+	}
+	logging.GetLogger().SetOutput(io.Discard)
+	svc := services.NewSearchService(repo)
+	if rewrite {
+		svc = svc.WithQueryRewrites()
+	}
+	if suggest {
+		svc = svc.WithSpellingSuggestions()
+	}
+	return svc
+}
+
 // printComparison narrates the verdict: the shared-subset deltas, then every
 // name that did not overlap or stopped behaving, each said out loud rather
 // than folded into an average.
 func printComparison(cmp *comparisonReport) {
 	fmt.Fprintf(os.Stderr,
-		"vs baseline over %d shared queries: recall %.4f (%+.4f), mrr %.4f (%+.4f), zero %.4f (%+.4f) — %s\n",
+		"vs baseline over %d shared queries: recall %.4f (%+.4f), mrr %.4f (%+.4f), zero %.4f (%+.4f), "+
+			"unanswered %.4f (%+.4f) — %s\n",
 		cmp.SharedQueries,
 		cmp.CurrentRecallAtK, cmp.RecallDelta,
 		cmp.CurrentMRR, cmp.MRRDelta,
-		cmp.CurrentZeroRate, cmp.ZeroRateDelta, cmp.Verdict)
+		cmp.CurrentZeroRate, cmp.ZeroRateDelta,
+		cmp.CurrentUnansweredRate, cmp.UnansweredRateDelta, cmp.Verdict)
 	for _, line := range []struct {
 		label string
 		names []string
@@ -548,6 +624,8 @@ func compareAggregates(queries, baselineQueries []queryReport) comparisonReport
 	cmp.RecallDelta = current.RecallAtK - baseline.RecallAtK
 	cmp.MRRDelta = current.MRR - baseline.MRR
 	cmp.ZeroRateDelta = current.ZeroResultRate - baseline.ZeroResultRate
+	cmp.BaselineUnansweredRate, cmp.CurrentUnansweredRate = baseline.UnansweredRate, current.UnansweredRate
+	cmp.UnansweredRateDelta = current.UnansweredRate - baseline.UnansweredRate
 
 	cmp.Verdict = verdictPass
 	switch {
@@ -583,46 +661,62 @@ func loadQuerySet(path string) (querySet, error) {
 	return set, nil
 }
 
+// searchAnswer is what one eval query got back from the search path.
+type searchAnswer struct {
+	results    []capturedResult
+	total      int
+	note       string
+	rewrite    *models.QueryRewrite
+	didYouMean []models.SpellingSuggestion
+}
+
 // runQuery executes one eval query through the current public search path:
-// the search repository, for books and authors alike. Both modes use it, so a
-// capture and a compare on the same catalog measure the same code.
-func runQuery(repo *database.PGSearchRepository, q *evalQuery) (results []capturedResult, total int, note string, err error) {
+// the search repository, or the service with -rewrite or -suggest, for books
+// and authors alike. Both modes use it, so a capture and a compare on the
+// same catalog measure the same code.
+func runQuery(search services.PublicSearch, q *evalQuery) (searchAnswer, error) {
 	switch q.Kind {
 	case kindBooks:
-		page, err := repo.SearchBooks(context.Background(), models.BookSearchRequest{
+		page, err := search.SearchBooks(context.Background(), models.BookSearchRequest{
 			Query:       q.Query,
 			AuthorQuery: q.Author,
 			Language:    q.Language,
 			Limit:       q.TopK,
 		})
 		if err != nil {
-			return nil, 0, "", err
+			return searchAnswer{}, err
 		}
 		note := ""
 		if q.Author != "" {
 			note = "title and author travel in one SQL request; the Phase 1 baseline used a " +
 				"200-requested (effective 100) title window plus a Go-side author filter"
 		}
-		return bookResults(page.Books), page.Total, note, nil
+		return searchAnswer{
+			results:    bookResults(page.Books),
+			total:      page.Total,
+			note:       note,
+			rewrite:    page.Rewrite,
+			didYouMean: page.DidYouMean,
+		}, nil
 	case kindAuthors:
 		// The repository reads an empty language as "every language" — the
 		// service's normalizeLanguage folds AllLanguages down to this before
 		// the call, and the eval talks to the repository directly.
-		page, err := repo.SearchAuthors(context.Background(), models.AuthorSearchRequest{
+		page, err := search.SearchAuthors(context.Background(), models.AuthorSearchRequest{
 			Query:    q.Query,
 			Language: q.Language,
 			Limit:    q.TopK,
 		})
 		if err != nil {
-			return nil, 0, "", err
+			return searchAnswer{}, err
 		}
 		results := make([]capturedResult, 0, len(page.Authors))
 		for _, a := range page.Authors {
 			results = append(results, capturedResult{ID: a.ID, FullName: a.FullName, BooksCount: a.BooksCount})
 		}
-		return results, page.Total, "", nil
+		return searchAnswer{results: results, total: page.Total, rewrite: page.Rewrite}, nil
 	}
-	return nil, 0, "", fmt.Errorf("unknown kind %q", q.Kind)
+	return searchAnswer{}, fmt.Errorf("unknown kind %q", q.Kind)
 }
 
 func bookResults(books []models.Book) []capturedResult {
@@ -687,9 +781,20 @@ func aggregate(reports []queryReport) aggregateReport {
 	agg := aggregateReport{TotalQueries: len(reports)}
 	var recallSum, rrSum float64
 	totals := make([]int, 0, len(reports))
+	unanswered := 0
 	for i := range reports {
 		r := &reports[i]
 		totals = append(totals, r.Total)
+		if r.Rewrite != nil {
+			agg.RewrittenQueries++
+		}
+		if r.Total == 0 {
+			if len(r.DidYouMean) > 0 {
+				agg.SuggestedQueries++
+			} else {
+				unanswered++
+			}
+		}
 		if len(r.RelevantIDs) == 0 {
 			continue
 		}
@@ -702,6 +807,9 @@ func aggregate(reports []queryReport) aggregateReport {
 		agg.MRR = rrSum / float64(agg.ScoredQueries)
 	}
 	agg.ZeroResultRate = zeroResultRate(totals)
+	if len(reports) > 0 {
+		agg.UnansweredRate = float64(unanswered) / float64(len(reports))
+	}
 	return agg
 }
 
@@ -3,6 +3,8 @@ package main
 import (
 	"testing"
 
+	"gopds-api/models"
+
 	"github.com/stretchr/testify/assert"
 )
 
@@ -82,7 +84,8 @@ func TestAggregate(t *testing.T) {
 		// A query with an empty relevance set is not scoreable: it must not
 		// drag Recall/MRR down, but its zero total still counts.
 		{RelevantIDs: []int64{}, RecallAtK: 0, ReciprocalRank: 0, Total: 0},
-		{RelevantIDs: []int64{3}, RecallAtK: 1, ReciprocalRank: 0.5, Total: 3},
+		{RelevantIDs: []int64{3}, RecallAtK: 1, ReciprocalRank: 0.5, Total: 3,
+			Rewrite: &models.QueryRewrite{Query: "война", Kind: models.RewriteLayout}},
 	}
 
 	agg := aggregate(reports)
@@ -92,6 +95,23 @@ func TestAggregate(t *testing.T) {
 	assert.InDelta(t, 0.75, agg.RecallAtK, 1e-9)
 	assert.InDelta(t, 0.75, agg.MRR, 1e-9)
 	assert.InDelta(t, 1.0/3.0, agg.ZeroResultRate, 1e-9)
+	assert.Equal(t, 1, agg.RewrittenQueries)
+}
+
+func TestAggregateUnansweredRate(t *testing.T) {
+	reports := []queryReport{
+		{Total: 4},
+		// Nothing found, but a correction offered: zero, yet answered.
+		{Total: 0, DidYouMean: []models.SpellingSuggestion{{Query: "война и мир", Total: 2}}},
+		{Total: 0},
+		{Total: 1},
+	}
+
+	agg := aggregate(reports)
+
+	assert.InDelta(t, 0.5, agg.ZeroResultRate, 1e-9)
+	assert.Equal(t, 1, agg.SuggestedQueries)
+	assert.InDelta(t, 0.25, agg.UnansweredRate, 1e-9, "only the query with neither results nor a suggestion")
 }
 
 func TestPercentile(t *testing.T) {
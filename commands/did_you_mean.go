@@ -0,0 +1,51 @@
+package commands
+
+import (
+	"fmt"
+	"strings"
+
+	"gopds-api/models"
+
+	tgbot "github.com/go-telegram/bot/models"
+)
+
+// A book search that finds nothing offers the corrected queries the search
+// service suggests, one button each. Pressing one searches for it from the
+// first page.
+
+// didYouMeanCallbackPrefix starts the callback data of a suggestion button;
+// the corrected query follows it.
+const didYouMeanCallbackPrefix = "dym:"
+
+// maxCallbackData is the most bytes Telegram takes as a button's callback
+// data. A suggestion that does not fit gets no button.
+const maxCallbackData = 64
+
+// didYouMeanNote follows the not-found message when there are suggestions.
+const didYouMeanNote = "\n\n🔎 Возможно, вы имели в виду:"
+
+// didYouMeanMarkup lays the suggestions out one button a row, best first, or
+// is nil when none fits.
+func didYouMeanMarkup(suggestions []models.SpellingSuggestion) *tgbot.InlineKeyboardMarkup {
+	var rows [][]tgbot.InlineKeyboardButton
+	for _, s := range suggestions {
+		data := didYouMeanCallbackPrefix + s.Query
+		if len(data) > maxCallbackData {
+			continue
+		}
+		rows = append(rows, []tgbot.InlineKeyboardButton{{
+			Text:         fmt.Sprintf("%s (%d)", s.Query, s.Total),
+			CallbackData: data,
+		}})
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+	return &tgbot.InlineKeyboardMarkup{InlineKeyboard: rows}
+}
+
+// ParseDidYouMeanCallback reads the corrected query of a suggestion
+// button's callback data.
+func ParseDidYouMeanCallback(data string) string {
+	return strings.TrimPrefix(data, didYouMeanCallbackPrefix)
+}
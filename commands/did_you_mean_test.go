@@ -0,0 +1,44 @@
+package commands
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"gopds-api/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBookSearchNotFoundOffersDidYouMean(t *testing.T) {
+	search := &fakePublicSearch{bookPage: models.BookSearchPage{
+		Limit: 5,
+		DidYouMean: []models.SpellingSuggestion{
+			{Query: "война и мир", Total: 4},
+			{Query: strings.Repeat("очень длинное название ", 3), Total: 1},
+			{Query: "война и миф", Total: 1},
+		},
+	}}
+	cp := newTestProcessor(search, &models.User{ID: 42, BooksLang: "ru"})
+
+	result, err := cp.ExecuteDirectBookSearch(context.Background(), "вайна и мир", 777)
+	require.NoError(t, err)
+
+	assert.Contains(t, result.Message, "were not found")
+	assert.Contains(t, result.Message, "Возможно, вы имели в виду")
+	assert.Equal(t, []string{"dym:война и мир", "dym:война и миф"}, callbackDataOf(result.ReplyMarkup),
+		"best first, and a query too long for a button is left out")
+	assert.Equal(t, "война и мир", ParseDidYouMeanCallback("dym:война и мир"))
+	assert.Nil(t, result.SearchParams)
+}
+
+func TestBookSearchNotFoundWithoutSuggestionsHasNoKeyboard(t *testing.T) {
+	search := &fakePublicSearch{bookPage: models.BookSearchPage{Limit: 5}}
+	cp := newTestProcessor(search, &models.User{ID: 42})
+
+	result, err := cp.ExecuteDirectBookSearch(context.Background(), "zzzz", 777)
+	require.NoError(t, err)
+	assert.NotContains(t, result.Message, "Возможно")
+	assert.Nil(t, result.ReplyMarkup)
+}
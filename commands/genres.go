@@ -0,0 +1,184 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"gopds-api/database"
+	"gopds-api/logging"
+	"gopds-api/models"
+
+	tgbot "github.com/go-telegram/bot/models"
+)
+
+// ExecuteShowGenres shows the sections of the genre taxonomy, named in the
+// reader's interface language.
+func (cp *CommandProcessor) ExecuteShowGenres(userID int64) (*CommandResult, error) {
+	user, err := cp.findUser(userID)
+	if err != nil {
+		return userLookupFailure(userID, err), nil
+	}
+
+	tree, err := database.GetGenreTree(context.Background(), user.InterfaceLang, false)
+	if err != nil {
+		logging.Errorf("Failed to load genre tree: %v", err)
+		return &CommandResult{
+			Message: "Произошла ошибка при получении жанров. Попробуйте позже.",
+		}, nil
+	}
+	if len(tree.Sections) == 0 {
+		return &CommandResult{
+			Message: "🏷 Жанры пока недоступны.",
+		}, nil
+	}
+
+	var rows [][]tgbot.InlineKeyboardButton
+	for _, s := range tree.Sections {
+		rows = appendGenreButton(rows, fmt.Sprintf("%s (%d)", s.Name, s.BooksCount), fmt.Sprintf("gsection:%d", s.ID))
+	}
+
+	return &CommandResult{
+		Message:     "🏷 Жанры:\n\n💡 Выберите раздел:",
+		ReplyMarkup: &tgbot.InlineKeyboardMarkup{InlineKeyboard: rows},
+	}, nil
+}
+
+// ExecuteShowGenreSection shows the genres of one section.
+func (cp *CommandProcessor) ExecuteShowGenreSection(sectionID int64, userID int64) (*CommandResult, error) {
+	user, err := cp.findUser(userID)
+	if err != nil {
+		return userLookupFailure(userID, err), nil
+	}
+
+	section, err := database.GetGenreSection(context.Background(), sectionID, user.InterfaceLang)
+	if errors.Is(err, database.ErrGenreSectionNotFound) {
+		return &CommandResult{
+			Message: "🏷 Раздел не найден.",
+		}, nil
+	}
+	if err != nil {
+		logging.Errorf("Failed to load genre section %d: %v", sectionID, err)
+		return &CommandResult{
+			Message: "Произошла ошибка при получении жанров. Попробуйте позже.",
+		}, nil
+	}
+
+	var rows [][]tgbot.InlineKeyboardButton
+	for _, g := range section.Genres {
+		rows = appendGenreButton(rows, fmt.Sprintf("%s (%d)", g.Name, g.BooksCount), fmt.Sprintf("genre:%d", g.ID))
+	}
+	rows = append(rows, []tgbot.InlineKeyboardButton{{
+		Text:         "⬅️ Все разделы",
+		CallbackData: "genres",
+	}})
+
+	return &CommandResult{
+		Message:     fmt.Sprintf("🏷 %s:\n\n💡 Выберите жанр:", section.Name),
+		ReplyMarkup: &tgbot.InlineKeyboardMarkup{InlineKeyboard: rows},
+	}, nil
+}
+
+// appendGenreButton lays genre buttons out two to a row.
+func appendGenreButton(rows [][]tgbot.InlineKeyboardButton, text, data string) [][]tgbot.InlineKeyboardButton {
+	button := tgbot.InlineKeyboardButton{Text: text, CallbackData: data}
+	if n := len(rows); n > 0 && len(rows[n-1]) < 2 {
+		rows[n-1] = append(rows[n-1], button)
+		return rows
+	}
+	return append(rows, []tgbot.InlineKeyboardButton{button})
+}
+
+// ExecuteGenreBooks shows the books of a genre with pagination.
+func (cp *CommandProcessor) ExecuteGenreBooks(genreID int64, userID int64, offset, limit int) (*CommandResult, error) {
+	user, err := cp.findUser(userID)
+	if err != nil {
+		return userLookupFailure(userID, err), nil
+	}
+
+	genre, err := database.GetGenre(context.Background(), genreID)
+	if err != nil {
+		return &CommandResult{
+			Message: "🏷 Жанр не найден.",
+		}, nil
+	}
+	name := genre.LocalizedName(user.InterfaceLang)
+
+	books, total, err := database.GetBooks(user.ID, models.BookFilters{
+		Genre:          int(genreID),
+		Limit:          limit,
+		Offset:         offset,
+		BookListParams: models.BookListParams{Sort: cp.sort.String()},
+	})
+	if err != nil {
+		logging.Errorf("Failed to get genre books: %v", err)
+		return &CommandResult{
+			Message: "Произошла ошибка при получении книг жанра. Попробуйте позже.",
+		}, nil
+	}
+
+	if len(books) == 0 && offset == 0 {
+		return &CommandResult{
+			Message: fmt.Sprintf("🏷 В жанре \"%s\" пока нет книг.", name),
+		}, nil
+	}
+
+	if len(books) == 0 && offset > 0 {
+		return &CommandResult{
+			Message: "На этой странице нет результатов.",
+		}, nil
+	}
+
+	message := cp.formatGenreBooksWithPagination(name, books, total, offset, limit)
+	replyMarkup := cp.appendSortRow(cp.createBookButtonsWithPagination(books, offset, limit, total))
+
+	return &CommandResult{
+		Message:     message,
+		Books:       books,
+		ReplyMarkup: replyMarkup,
+		SearchParams: &SearchParams{
+			Query:      name,
+			QueryType:  "genre_books",
+			RefID:      genreID,
+			Offset:     offset,
+			Limit:      limit,
+			TotalCount: total,
+			Sort:       cp.sort.String(),
+		},
+	}, nil
+}
+
+// formatGenreBooksWithPagination formats genre books list with pagination info
+func (cp *CommandProcessor) formatGenreBooksWithPagination(genreName string, books []models.Book, totalCount, offset, limit int) string {
+	var builder strings.Builder
+
+	currentPage := (offset / limit) + 1
+	totalPages := (totalCount + limit - 1) / limit
+
+	builder.WriteString(fmt.Sprintf("🏷 Жанр \"%s\":\n", genreName))
+	builder.WriteString(fmt.Sprintf("Страница %d из %d (всего %d книг)\n\n", currentPage, totalPages, totalCount))
+
+	for i, book := range books {
+		var authorNames []string
+		for _, author := range book.Authors {
+			authorNames = append(authorNames, author.FullName)
+		}
+		authorsStr := strings.Join(authorNames, ", ")
+		if authorsStr == "" {
+			authorsStr = "Автор неизвестен"
+		}
+
+		bookNumber := offset + i + 1
+		builder.WriteString(fmt.Sprintf("%d. %s — %s", bookNumber, book.Title, authorsStr))
+
+		if len(book.Series) > 0 && book.Series[0].Ser != "" {
+			builder.WriteString(fmt.Sprintf(" (серия: %s)", book.Series[0].Ser))
+		}
+
+		builder.WriteString("\n")
+	}
+
+	builder.WriteString("\n💡 Выберите книгу по номеру или используйте навигацию:")
+	return builder.String()
+}
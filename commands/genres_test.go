@@ -0,0 +1,21 @@
+package commands
+
+import (
+	"fmt"
+	"testing"
+
+	tgbot "github.com/go-telegram/bot/models"
+)
+
+func TestAppendGenreButton_TwoPerRow(t *testing.T) {
+	var rows [][]tgbot.InlineKeyboardButton
+	for i := 0; i < 5; i++ {
+		rows = appendGenreButton(rows, fmt.Sprint(i), fmt.Sprintf("genre:%d", i))
+	}
+	if len(rows) != 3 || len(rows[0]) != 2 || len(rows[1]) != 2 || len(rows[2]) != 1 {
+		t.Fatalf("rows laid out as %v", rows)
+	}
+	if rows[2][0].CallbackData != "genre:4" {
+		t.Errorf("last button = %q", rows[2][0].CallbackData)
+	}
+}
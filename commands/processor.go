@@ -58,6 +58,10 @@ type CommandProcessor struct {
 	llmService *llm.LLMService
 	search     services.PublicSearch
 	findUser   telegramUserLookup
+	// sort orders the book lists that can be re-sorted; see WithSort.
+	sort models.BookSort
+	// recommend lists books like a book; see WithRecommendations.
+	recommend services.Recommendations
 }
 
 // CommandResult represents the result of command execution
@@ -78,6 +82,7 @@ type SearchParams struct {
 	Offset     int    `json:"offset"`
 	Limit      int    `json:"limit"`
 	TotalCount int    `json:"total_count"`
+	Sort       string `json:"sort,omitempty"` // order of a book list, as models.ParseBookSort reads it
 }
 
 // NewCommandProcessor creates a new command processor on the one search
@@ -161,6 +166,7 @@ func (cp *CommandProcessor) executeFindBookWithPagination(
 		Query:    title,
 		UserID:   user.ID,
 		Language: user.BooksLang,
+		Options:  models.BookListOptions{Sort: cp.sort},
 		Limit:    limit,
 		Offset:   offset,
 	})
@@ -173,15 +179,24 @@ func (cp *CommandProcessor) executeFindBookWithPagination(
 
 	books, totalCount := page.Books, page.Total
 
+	// The first page, found or not, also offers the series and genres named
+	// like the query.
+	var lanes [][]tgbot.InlineKeyboardButton
+	if offset == 0 {
+		lanes = cp.laneRows(ctx, title, &user)
+	}
+
 	if len(books) == 0 {
-		return bookSearchNotFound(title, user.BooksLang, offset), nil
+		result := bookSearchNotFound(title, user.BooksLang, offset, page.DidYouMean)
+		result.ReplyMarkup = appendLaneRows(result.ReplyMarkup, lanes)
+		return result, nil
 	}
 
 	// Format the response message with pagination info
-	message := cp.formatBookSearchResultsWithPagination(title, books, totalCount, offset, limit)
+	message := rewriteNote(page.Rewrite) + cp.formatBookSearchResultsWithPagination(title, books, totalCount, offset, limit)
 
 	// Create inline keyboard with number-based buttons and pagination
-	replyMarkup := cp.createBookButtonsWithPagination(books, offset, limit, totalCount)
+	replyMarkup := cp.appendSortRow(appendLaneRows(cp.createBookButtonsWithPagination(books, offset, limit, totalCount), lanes))
 
 	return &CommandResult{
 		Message:     message,
@@ -192,13 +207,15 @@ func (cp *CommandProcessor) executeFindBookWithPagination(
 			Offset:     offset,
 			Limit:      limit,
 			TotalCount: totalCount,
+			Sort:       cp.sort.String(),
 		},
 	}, nil
 }
 
 // bookSearchNotFound renders the empty-result message for a book search: the
-// first page names the language scope, later pages just say they are empty.
-func bookSearchNotFound(title, lang string, offset int) *CommandResult {
+// first page names the language scope and offers the corrected queries the
+// search suggests, later pages just say they are empty.
+func bookSearchNotFound(title, lang string, offset int, suggestions []models.SpellingSuggestion) *CommandResult {
 	if offset > 0 {
 		return &CommandResult{
 			Message: noResultsOnPageMessage,
@@ -208,12 +225,17 @@ func bookSearchNotFound(title, lang string, offset int) *CommandResult {
 	if lang != "" && lang != database.AllLanguages {
 		languageMsg = fmt.Sprintf(" in %s language", lang)
 	}
-	return &CommandResult{
+	result := &CommandResult{
 		Message: fmt.Sprintf(
 			"📚 Books with title %q%s were not found.\n\nTry changing your search query or using other keywords.",
 			title, languageMsg,
 		),
 	}
+	if markup := didYouMeanMarkup(suggestions); markup != nil {
+		result.Message += didYouMeanNote
+		result.ReplyMarkup = markup
+	}
+	return result
 }
 
 // executeFindAuthor executes an author search command
@@ -273,7 +295,7 @@ func (cp *CommandProcessor) executeFindAuthorWithPagination(
 	}
 
 	// Format the response message with pagination info
-	message := cp.formatAuthorSearchResultsWithPagination(author, authors, totalCount, offset, limit)
+	message := rewriteNote(page.Rewrite) + cp.formatAuthorSearchResultsWithPagination(author, authors, totalCount, offset, limit)
 
 	// Create inline keyboard with number-based buttons and pagination
 	replyMarkup := cp.createAuthorButtonsWithPagination(authors, offset, limit, totalCount)
@@ -304,9 +326,10 @@ func (cp *CommandProcessor) ExecuteFindAuthorBooksWithPagination(authorID int64,
 	// ordinary-list path REST uses for /api/books?author=<id>. The search
 	// service has no scope-only mode by design.
 	filters := models.BookFilters{
-		Author: int(authorID),
-		Limit:  limit,
-		Offset: offset,
+		Author:         int(authorID),
+		Limit:          limit,
+		Offset:         offset,
+		BookListParams: models.BookListParams{Sort: cp.sort.String()},
 	}
 
 	// Apply user's language preference if available — the same language the
@@ -374,7 +397,7 @@ func (cp *CommandProcessor) ExecuteFindAuthorBooksWithPagination(authorID int64,
 	messageBuilder.WriteString("\n💡 Select a book by number or use navigation:")
 
 	// Create inline keyboard with book selection buttons and pagination
-	replyMarkup := cp.createBookButtonsWithPagination(books, offset, limit, totalCount)
+	replyMarkup := cp.appendSortRow(cp.createBookButtonsWithPagination(books, offset, limit, totalCount))
 
 	return &CommandResult{
 		Message:     messageBuilder.String(),
@@ -387,6 +410,7 @@ func (cp *CommandProcessor) ExecuteFindAuthorBooksWithPagination(authorID int64,
 			Offset:     offset,
 			Limit:      limit,
 			TotalCount: totalCount,
+			Sort:       cp.sort.String(),
 		},
 	}, nil
 }
@@ -432,6 +456,7 @@ func (cp *CommandProcessor) executeFindBookWithAuthorWithPagination(
 		AuthorQuery: author,
 		UserID:      user.ID,
 		Language:    user.BooksLang,
+		Options:     models.BookListOptions{Sort: cp.sort},
 		Limit:       limit,
 		Offset:      offset,
 	})
@@ -453,7 +478,7 @@ func (cp *CommandProcessor) executeFindBookWithAuthorWithPagination(
 	message := cp.formatCombinedSearchResultsWithPagination(queryDescription, books, totalCount, offset, limit)
 
 	// Create inline keyboard with number-based buttons and pagination
-	replyMarkup := cp.createBookButtonsWithPagination(books, offset, limit, totalCount)
+	replyMarkup := cp.appendSortRow(cp.createBookButtonsWithPagination(books, offset, limit, totalCount))
 
 	return &CommandResult{
 		Message:     message,
@@ -465,6 +490,7 @@ func (cp *CommandProcessor) executeFindBookWithAuthorWithPagination(
 			Offset:     offset,
 			Limit:      limit,
 			TotalCount: totalCount,
+			Sort:       cp.sort.String(),
 		},
 	}, nil
 }
@@ -545,6 +571,15 @@ func (cp *CommandProcessor) formatCombinedSearchResultsWithPagination(query stri
 	return builder.String()
 }
 
+// rewriteNote heads a result list the search found for a rewritten query,
+// so the reader sees what was searched instead of what they typed.
+func rewriteNote(rewrite *models.QueryRewrite) string {
+	if rewrite == nil {
+		return ""
+	}
+	return fmt.Sprintf("🔤 Показаны результаты для «%s»\n\n", rewrite.Query)
+}
+
 // formatBookSearchResultsWithPagination formats the search results into a message with pagination info
 func (cp *CommandProcessor) formatBookSearchResultsWithPagination(query string, books []models.Book, totalCount, offset, limit int) string {
 	var builder strings.Builder
@@ -914,9 +949,10 @@ func (cp *CommandProcessor) ExecuteShowFavorites(userID int64, offset, limit int
 
 	// Get user's favorite books using the Fav filter
 	filters := models.BookFilters{
-		Fav:    true,
-		Limit:  limit,
-		Offset: offset,
+		Fav:            true,
+		Limit:          limit,
+		Offset:         offset,
+		BookListParams: models.BookListParams{Sort: cp.sort.String()},
 	}
 
 	books, totalCount, err := database.GetBooks(user.ID, filters)
@@ -945,7 +981,7 @@ func (cp *CommandProcessor) ExecuteShowFavorites(userID int64, offset, limit int
 	message := cp.formatFavoriteBooksWithPagination(books, totalCount, offset, limit)
 
 	// Create inline keyboard with book selection buttons and pagination
-	replyMarkup := cp.createBookButtonsWithPagination(books, offset, limit, totalCount)
+	replyMarkup := cp.appendSortRow(cp.createBookButtonsWithPagination(books, offset, limit, totalCount))
 
 	return &CommandResult{
 		Message:     message,
@@ -957,6 +993,7 @@ func (cp *CommandProcessor) ExecuteShowFavorites(userID int64, offset, limit int
 			Offset:     offset,
 			Limit:      limit,
 			TotalCount: totalCount,
+			Sort:       cp.sort.String(),
 		},
 	}, nil
 }
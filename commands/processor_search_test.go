@@ -22,6 +22,10 @@ type fakePublicSearch struct {
 	bookPage       models.BookSearchPage
 	authorPage     models.AuthorSearchPage
 	err            error
+
+	suggestionRequests []models.SuggestionRequest
+	suggestions        map[models.SuggestionKind][]models.AutocompleteSuggestion
+	suggestionErr      error
 }
 
 //nolint:gocritic // the port takes the request by value; this implements it
@@ -41,8 +45,12 @@ func (f *fakePublicSearch) SearchAuthors(_ context.Context, req models.AuthorSea
 	return f.authorPage, nil
 }
 
-func (f *fakePublicSearch) Suggestions(_ context.Context, _ models.SuggestionRequest) (models.SuggestionResult, error) {
-	return models.SuggestionResult{}, nil
+func (f *fakePublicSearch) Suggestions(_ context.Context, req models.SuggestionRequest) (models.SuggestionResult, error) {
+	f.suggestionRequests = append(f.suggestionRequests, req)
+	if f.suggestionErr != nil {
+		return models.SuggestionResult{}, f.suggestionErr
+	}
+	return models.SuggestionResult{Suggestions: f.suggestions[req.Kind]}, nil
 }
 
 // fakeUserLookup stands in for database.GetUserByTelegramID.
@@ -110,6 +118,21 @@ func TestDirectBookSearchMapsEveryFieldToTheService(t *testing.T) {
 	assert.NotContains(t, data, "prev_page")
 }
 
+// A page found for a rewritten query says which query it answers, and the
+// pager keeps the query typed: the next page is rewritten the same way.
+func TestDirectBookSearchNamesTheRewrite(t *testing.T) {
+	search := &fakePublicSearch{bookPage: models.BookSearchPage{
+		Books: cannedBooks(1, 2), Total: 2, Limit: 5,
+		Rewrite: &models.QueryRewrite{Query: "война", Kind: models.RewriteLayout},
+	}}
+	cp := newTestProcessor(search, &models.User{ID: 42, BooksLang: "ru"})
+
+	result, err := cp.ExecuteDirectBookSearch(context.Background(), "djqyf", 777)
+	require.NoError(t, err)
+	assert.Contains(t, result.Message, "Показаны результаты для «война»")
+	assert.Equal(t, "djqyf", result.SearchParams.Query)
+}
+
 func TestDirectBookSearchNotFoundMentionsTheLanguageFilter(t *testing.T) {
 	search := &fakePublicSearch{bookPage: models.BookSearchPage{Limit: 5}}
 	cp := newTestProcessor(search, &models.User{ID: 42, BooksLang: "ru"})
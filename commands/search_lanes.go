@@ -0,0 +1,65 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+
+	"gopds-api/logging"
+	"gopds-api/models"
+
+	tgbot "github.com/go-telegram/bot/models"
+)
+
+// laneSuggestions is how many series and how many genres named like a book
+// search's query are offered under its first page.
+const laneSuggestions = 2
+
+// searchLanes are the picker lanes a book search offers as buttons: a series
+// opens its books, a genre its books through the genre browser's callback.
+var searchLanes = []struct {
+	kind   models.SuggestionKind
+	icon   string
+	prefix string
+}{
+	{models.SuggestionSeries, "📖", seriesCallbackPrefix},
+	{models.SuggestionGenre, "🏷", "genre:"},
+}
+
+// laneRows are the buttons of the series and genres named like query, with
+// the books each holds in the reader's language, one to a row. They are
+// extras: a failure is logged and costs the buttons, never the search.
+func (cp *CommandProcessor) laneRows(ctx context.Context, query string, user *models.User) [][]tgbot.InlineKeyboardButton {
+	var rows [][]tgbot.InlineKeyboardButton
+	for _, lane := range searchLanes {
+		result, err := cp.search.Suggestions(ctx, models.SuggestionRequest{
+			Query:    query,
+			Kind:     lane.kind,
+			Language: user.BooksLang,
+			UserID:   user.ID,
+			Limit:    laneSuggestions,
+		})
+		if err != nil {
+			logging.Warnf("Series and genre suggestions: %v", err)
+			return rows
+		}
+		for _, s := range result.Suggestions {
+			rows = append(rows, []tgbot.InlineKeyboardButton{{
+				Text:         fmt.Sprintf("%s %s (%d)", lane.icon, s.Value, s.BooksCount),
+				CallbackData: fmt.Sprintf("%s%d", lane.prefix, s.ID),
+			}})
+		}
+	}
+	return rows
+}
+
+// appendLaneRows adds rows to a keyboard, making one when there is none.
+func appendLaneRows(markup *tgbot.InlineKeyboardMarkup, rows [][]tgbot.InlineKeyboardButton) *tgbot.InlineKeyboardMarkup {
+	if len(rows) == 0 {
+		return markup
+	}
+	if markup == nil {
+		markup = &tgbot.InlineKeyboardMarkup{}
+	}
+	markup.InlineKeyboard = append(markup.InlineKeyboard, rows...)
+	return markup
+}
@@ -0,0 +1,77 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"gopds-api/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func laneSearch(page models.BookSearchPage) *fakePublicSearch {
+	return &fakePublicSearch{
+		bookPage: page,
+		suggestions: map[models.SuggestionKind][]models.AutocompleteSuggestion{
+			models.SuggestionSeries: {{Type: "series", ID: 7, Value: "Ведьмак", BooksCount: 8}},
+			models.SuggestionGenre:  {{Type: "genre", ID: 9, Value: "Фэнтези", BooksCount: 120}},
+		},
+	}
+}
+
+func TestBookSearchOffersSeriesAndGenres(t *testing.T) {
+	search := laneSearch(models.BookSearchPage{Books: cannedBooks(1, 2), Total: 12, Limit: 5})
+	cp := newTestProcessor(search, &models.User{ID: 42, BooksLang: "ru"})
+
+	result, err := cp.ExecuteDirectBookSearch(context.Background(), "ведьмак", 777)
+	require.NoError(t, err)
+
+	require.Len(t, search.suggestionRequests, 2)
+	for _, req := range search.suggestionRequests {
+		assert.Equal(t, "ведьмак", req.Query)
+		assert.Equal(t, "ru", req.Language, "counted in the reader's language")
+		assert.Equal(t, int64(42), req.UserID)
+		assert.Equal(t, laneSuggestions, req.Limit)
+	}
+	data := callbackDataOf(result.ReplyMarkup)
+	assert.Subset(t, data, []string{"series:7", "genre:9"})
+	rows := result.ReplyMarkup.InlineKeyboard
+	assert.Equal(t, "📖 Ведьмак (8)", rows[len(rows)-3][0].Text)
+	assert.Equal(t, "🏷 Фэнтези (120)", rows[len(rows)-2][0].Text, "above the sort row")
+
+	id, err := ParseSeriesCallback("series:7")
+	require.NoError(t, err)
+	assert.Equal(t, int64(7), id)
+}
+
+func TestBookSearchOffersSeriesWhenNoTitleMatches(t *testing.T) {
+	search := laneSearch(models.BookSearchPage{Limit: 5})
+	cp := newTestProcessor(search, &models.User{ID: 42})
+
+	result, err := cp.ExecuteDirectBookSearch(context.Background(), "ведьмак", 777)
+	require.NoError(t, err)
+
+	assert.Contains(t, result.Message, "were not found")
+	assert.Equal(t, []string{"series:7", "genre:9"}, callbackDataOf(result.ReplyMarkup))
+}
+
+func TestBookSearchLaterPagesAndFailuresOfferNoLanes(t *testing.T) {
+	search := laneSearch(models.BookSearchPage{Books: cannedBooks(6, 7), Total: 12, Limit: 5, Offset: 5})
+	cp := newTestProcessor(search, &models.User{ID: 42})
+
+	result, err := cp.ExecuteFindBookWithPagination(context.Background(), "ведьмак", 777, 5, 5)
+	require.NoError(t, err)
+	assert.Empty(t, search.suggestionRequests, "only the first page asks")
+	assert.NotContains(t, callbackDataOf(result.ReplyMarkup), "series:7")
+
+	search = laneSearch(models.BookSearchPage{Books: cannedBooks(1), Total: 1, Limit: 5})
+	search.suggestionErr = errors.New("boom")
+	cp = newTestProcessor(search, &models.User{ID: 42})
+
+	result, err = cp.ExecuteDirectBookSearch(context.Background(), "ведьмак", 777)
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.SearchParams.TotalCount, "the search itself is answered")
+	assert.NotContains(t, callbackDataOf(result.ReplyMarkup), "genre:9")
+}
@@ -0,0 +1,123 @@
+package commands
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gopds-api/database"
+	"gopds-api/logging"
+	"gopds-api/models"
+)
+
+// seriesCallbackPrefix marks a button that opens the books of a series.
+const seriesCallbackPrefix = "series:"
+
+// ParseSeriesCallback reads the series ID of a series button's callback data.
+func ParseSeriesCallback(data string) (int64, error) {
+	return strconv.ParseInt(strings.TrimPrefix(data, seriesCallbackPrefix), 10, 64)
+}
+
+// ExecuteSeriesBooks shows the books of a series in the reader's language
+// with pagination.
+func (cp *CommandProcessor) ExecuteSeriesBooks(seriesID int64, userID int64, offset, limit int) (*CommandResult, error) {
+	user, err := cp.findUser(userID)
+	if err != nil {
+		return userLookupFailure(userID, err), nil
+	}
+
+	series, err := database.GetSeries(seriesID)
+	if err != nil {
+		return &CommandResult{
+			Message: "📖 Серия не найдена.",
+		}, nil
+	}
+
+	// The same language the search that offered the series counted in.
+	filters := models.BookFilters{
+		Series:         int(seriesID),
+		Limit:          limit,
+		Offset:         offset,
+		BookListParams: models.BookListParams{Sort: cp.sort.String()},
+	}
+	if user.BooksLang != "" {
+		filters.Lang = user.BooksLang
+	}
+
+	books, total, err := database.GetBooks(user.ID, filters)
+	if err != nil {
+		logging.Errorf("Failed to get series books: %v", err)
+		return &CommandResult{
+			Message: "Произошла ошибка при получении книг серии. Попробуйте позже.",
+		}, nil
+	}
+
+	if len(books) == 0 && offset == 0 {
+		return &CommandResult{
+			Message: fmt.Sprintf("📖 В серии \"%s\" нет книг на вашем языке.", series.Ser),
+		}, nil
+	}
+
+	if len(books) == 0 && offset > 0 {
+		return &CommandResult{
+			Message: "На этой странице нет результатов.",
+		}, nil
+	}
+
+	message := cp.formatSeriesBooksWithPagination(series, books, total, offset, limit)
+	replyMarkup := cp.appendSortRow(cp.createBookButtonsWithPagination(books, offset, limit, total))
+
+	return &CommandResult{
+		Message:     message,
+		Books:       books,
+		ReplyMarkup: replyMarkup,
+		SearchParams: &SearchParams{
+			Query:      series.Ser,
+			QueryType:  "series_books",
+			RefID:      seriesID,
+			Offset:     offset,
+			Limit:      limit,
+			TotalCount: total,
+			Sort:       cp.sort.String(),
+		},
+	}, nil
+}
+
+// formatSeriesBooksWithPagination formats series books list with pagination
+// info and each book's number in the series.
+func (cp *CommandProcessor) formatSeriesBooksWithPagination(
+	series models.Series, books []models.Book, totalCount, offset, limit int,
+) string {
+	var builder strings.Builder
+
+	currentPage := (offset / limit) + 1
+	totalPages := (totalCount + limit - 1) / limit
+
+	builder.WriteString(fmt.Sprintf("📖 Серия \"%s\":\n", series.Ser))
+	builder.WriteString(fmt.Sprintf("Страница %d из %d (всего %d книг)\n\n", currentPage, totalPages, totalCount))
+
+	for i, book := range books {
+		var authorNames []string
+		for _, author := range book.Authors {
+			authorNames = append(authorNames, author.FullName)
+		}
+		authorsStr := strings.Join(authorNames, ", ")
+		if authorsStr == "" {
+			authorsStr = "Автор неизвестен"
+		}
+
+		bookNumber := offset + i + 1
+		builder.WriteString(fmt.Sprintf("%d. %s — %s", bookNumber, book.Title, authorsStr))
+
+		for _, s := range book.Series {
+			if s.ID == series.ID && s.SerNo > 0 {
+				builder.WriteString(fmt.Sprintf(" (№%d)", s.SerNo))
+			}
+		}
+
+		builder.WriteString("\n")
+	}
+
+	builder.WriteString("\n💡 Выберите книгу по номеру или используйте навигацию:")
+	return builder.String()
+}
@@ -0,0 +1,131 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gopds-api/logging"
+	"gopds-api/models"
+	"gopds-api/services"
+)
+
+// similarCallbackPrefix marks the book card's button that lists the books
+// like that book.
+const similarCallbackPrefix = "similar:"
+
+// similarBooksShown is how many of the most alike books the list pages
+// through; recommendations are a short list, not a catalogue.
+const similarBooksShown = 20
+
+// SimilarCallbackData is the callback data of a book's "similar" button.
+func SimilarCallbackData(bookID int64) string {
+	return similarCallbackPrefix + strconv.FormatInt(bookID, 10)
+}
+
+// ParseSimilarCallback reads the book ID of a "similar" button's callback
+// data.
+func ParseSimilarCallback(data string) (int64, error) {
+	return strconv.ParseInt(strings.TrimPrefix(data, similarCallbackPrefix), 10, 64)
+}
+
+// WithRecommendations lets the processor list books like a book.
+func (cp *CommandProcessor) WithRecommendations(recommend services.Recommendations) *CommandProcessor {
+	cp.recommend = recommend
+	return cp
+}
+
+// ExecuteSimilarBooks shows the books most like a book, in the reader's
+// language, with pagination.
+func (cp *CommandProcessor) ExecuteSimilarBooks(
+	ctx context.Context, bookID int64, userID int64, offset, limit int,
+) (*CommandResult, error) {
+	user, err := cp.findUser(userID)
+	if err != nil {
+		return userLookupFailure(userID, err), nil
+	}
+	if cp.recommend == nil {
+		return &CommandResult{
+			Message: "Похожие книги сейчас недоступны.",
+		}, nil
+	}
+
+	all, err := cp.recommend.Recommend(ctx, models.RecommendationRequest{
+		BookID:   bookID,
+		UserID:   user.ID,
+		Language: user.BooksLang,
+		Limit:    similarBooksShown,
+	})
+	if err != nil {
+		logging.Errorf("Failed to get similar books: %v", err)
+		return &CommandResult{
+			Message: "Произошла ошибка при подборе похожих книг. Попробуйте позже.",
+		}, nil
+	}
+
+	if len(all) == 0 && offset == 0 {
+		return &CommandResult{
+			Message: "🔍 Похожих книг на вашем языке не нашлось.",
+		}, nil
+	}
+
+	if offset >= len(all) {
+		return &CommandResult{
+			Message: "На этой странице нет результатов.",
+		}, nil
+	}
+
+	total := len(all)
+	books := all[offset:min(offset+limit, total)]
+	message := cp.formatSimilarBooksWithPagination(books, total, offset, limit)
+	replyMarkup := cp.createBookButtonsWithPagination(books, offset, limit, total)
+
+	return &CommandResult{
+		Message:     message,
+		Books:       books,
+		ReplyMarkup: replyMarkup,
+		SearchParams: &SearchParams{
+			QueryType:  "similar_books",
+			RefID:      bookID,
+			Offset:     offset,
+			Limit:      limit,
+			TotalCount: total,
+		},
+	}, nil
+}
+
+// formatSimilarBooksWithPagination formats the similar books list with
+// pagination info.
+func (cp *CommandProcessor) formatSimilarBooksWithPagination(books []models.Book, totalCount, offset, limit int) string {
+	var builder strings.Builder
+
+	currentPage := (offset / limit) + 1
+	totalPages := (totalCount + limit - 1) / limit
+
+	builder.WriteString("🔍 Похожие книги:\n")
+	builder.WriteString(fmt.Sprintf("Страница %d из %d (всего %d книг)\n\n", currentPage, totalPages, totalCount))
+
+	for i, book := range books {
+		var authorNames []string
+		for _, author := range book.Authors {
+			authorNames = append(authorNames, author.FullName)
+		}
+		authorsStr := strings.Join(authorNames, ", ")
+		if authorsStr == "" {
+			authorsStr = "Автор неизвестен"
+		}
+
+		bookNumber := offset + i + 1
+		builder.WriteString(fmt.Sprintf("%d. %s — %s", bookNumber, book.Title, authorsStr))
+
+		if len(book.Series) > 0 && book.Series[0].Ser != "" {
+			builder.WriteString(fmt.Sprintf(" (серия: %s)", book.Series[0].Ser))
+		}
+
+		builder.WriteString("\n")
+	}
+
+	builder.WriteString("\n💡 Выберите книгу по номеру или используйте навигацию:")
+	return builder.String()
+}
@@ -0,0 +1,51 @@
+package commands
+
+import (
+	"context"
+	"testing"
+
+	"gopds-api/internal/testfakes"
+	"gopds-api/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSimilarBooksPageThroughTheShortList(t *testing.T) {
+	recommend := &testfakes.Recommendations{Books: cannedBooks(11, 12, 13, 14, 15, 16, 17)}
+	cp := newTestProcessor(&fakePublicSearch{}, &models.User{ID: 42, BooksLang: "ru"}).
+		WithRecommendations(recommend)
+
+	result, err := cp.ExecuteSimilarBooks(context.Background(), 5, 777, 0, 5)
+	require.NoError(t, err)
+	assert.Equal(t, models.RecommendationRequest{BookID: 5, UserID: 42, Language: "ru", Limit: similarBooksShown}, recommend.Req)
+	assert.Len(t, result.Books, 5)
+	require.NotNil(t, result.SearchParams)
+	assert.Equal(t, "similar_books", result.SearchParams.QueryType)
+	assert.Equal(t, int64(5), result.SearchParams.RefID)
+	assert.Equal(t, 7, result.SearchParams.TotalCount)
+	assert.Contains(t, callbackDataOf(result.ReplyMarkup), "next_page")
+
+	result, err = cp.ExecuteSimilarBooks(context.Background(), 5, 777, 5, 5)
+	require.NoError(t, err)
+	require.Len(t, result.Books, 2)
+	assert.Equal(t, int64(16), result.Books[0].ID)
+
+	result, err = cp.ExecuteSimilarBooks(context.Background(), 5, 777, 10, 5)
+	require.NoError(t, err)
+	assert.Empty(t, result.Books)
+}
+
+func TestSimilarBooksWithoutRecommendations(t *testing.T) {
+	cp := newTestProcessor(&fakePublicSearch{}, &models.User{ID: 42})
+	result, err := cp.ExecuteSimilarBooks(context.Background(), 5, 777, 0, 5)
+	require.NoError(t, err)
+	assert.Empty(t, result.Books)
+	assert.NotEmpty(t, result.Message)
+}
+
+func TestParseSimilarCallback(t *testing.T) {
+	id, err := ParseSimilarCallback(SimilarCallbackData(42))
+	require.NoError(t, err)
+	assert.Equal(t, int64(42), id)
+}
@@ -0,0 +1,57 @@
+package commands
+
+import (
+	"strings"
+
+	"gopds-api/models"
+
+	tgbot "github.com/go-telegram/bot/models"
+)
+
+// Book lists a reader can re-sort carry a row of sort buttons under the
+// page. The order chosen travels in SearchParams.Sort, so paging through the
+// list keeps it.
+
+// sortCallbackPrefix starts the callback data of a sort button; the order
+// follows it, and nothing follows it for the list's own order.
+const sortCallbackPrefix = "sort:"
+
+// sortButtons are the orders the keyboard offers, in its order.
+var sortButtons = []struct {
+	key, label string
+}{
+	{models.SortAdded, "🆕 Новые"},
+	{models.SortTitle, "🔤 А–Я"},
+	{models.SortYear, "📅 Год"},
+	{models.SortPopular, "🔥 Популярные"},
+	{models.SortRating, "⭐ Рейтинг"},
+}
+
+// WithSort sorts the book lists the processor builds from then on.
+func (cp *CommandProcessor) WithSort(sort models.BookSort) *CommandProcessor {
+	cp.sort = sort
+	return cp
+}
+
+// appendSortRow adds the sort buttons to a book list's keyboard. The order
+// in effect is checked, and pressing it again goes back to the list's own.
+func (cp *CommandProcessor) appendSortRow(markup *tgbot.InlineKeyboardMarkup) *tgbot.InlineKeyboardMarkup {
+	if markup == nil {
+		return nil
+	}
+	row := make([]tgbot.InlineKeyboardButton, 0, len(sortButtons))
+	for _, b := range sortButtons {
+		label, data := b.label, sortCallbackPrefix+b.key
+		if cp.sort == (models.BookSort{Key: b.key}) {
+			label, data = "✓ "+label, sortCallbackPrefix
+		}
+		row = append(row, tgbot.InlineKeyboardButton{Text: label, CallbackData: data})
+	}
+	markup.InlineKeyboard = append(markup.InlineKeyboard, row)
+	return markup
+}
+
+// ParseSortCallback reads the order of a sort button's callback data.
+func ParseSortCallback(data string) (models.BookSort, error) {
+	return models.ParseBookSort(strings.TrimPrefix(data, sortCallbackPrefix))
+}
@@ -0,0 +1,45 @@
+package commands
+
+import (
+	"context"
+	"testing"
+
+	"gopds-api/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSortedBookSearchKeepsItsOrder(t *testing.T) {
+	search := &fakePublicSearch{bookPage: models.BookSearchPage{
+		Books: cannedBooks(1, 2, 3), Total: 12, Limit: 5,
+	}}
+	year := models.BookSort{Key: models.SortYear}
+	cp := newTestProcessor(search, &models.User{ID: 42}).WithSort(year)
+
+	result, err := cp.ExecuteDirectBookSearch(context.Background(), "война", 777)
+	require.NoError(t, err)
+
+	require.Len(t, search.bookRequests, 1)
+	assert.Equal(t, year, search.bookRequests[0].Options.Sort)
+	assert.Equal(t, "year", result.SearchParams.Sort, "paging keeps the order")
+
+	data := callbackDataOf(result.ReplyMarkup)
+	assert.Contains(t, data, "next_page")
+	assert.Contains(t, data, "sort:title")
+	assert.Contains(t, data, "sort:", "the checked order leads back to the list's own")
+	assert.NotContains(t, data, "sort:year", "the order in effect is not offered again")
+}
+
+func TestParseSortCallback(t *testing.T) {
+	sort, err := ParseSortCallback("sort:popular")
+	require.NoError(t, err)
+	assert.Equal(t, models.BookSort{Key: models.SortPopular}, sort)
+
+	sort, err = ParseSortCallback("sort:")
+	require.NoError(t, err)
+	assert.Equal(t, models.BookSort{}, sort)
+
+	_, err = ParseSortCallback("sort:stars")
+	assert.ErrorIs(t, err, models.ErrInvalidListParams)
+}
@@ -15,18 +15,25 @@ import (
 
 // Config represents the main configuration structure
 type Config struct {
-	Server             ServerConfig   `mapstructure:"server" yaml:"server"`
-	ProjectURL         string         `mapstructure:"project_url" yaml:"project_url"`
-	Domain             string         `mapstructure:"project_domain" yaml:"project_domain"`
-	TelegramWebhookURL string         `mapstructure:"telegram_webhook_url" yaml:"telegram_webhook_url"`
-	SecretKey          string         `mapstructure:"secret_key" yaml:"secret_key"`
-	Postgres           PostgresConfig `mapstructure:"postgres" yaml:"postgres"`
-	Redis              RedisConfig    `mapstructure:"redis" yaml:"redis"`
-	Sessions           SessionsConfig `mapstructure:"sessions" yaml:"sessions"`
-	App                AppConfig      `mapstructure:"app" yaml:"app"`
-	Scanning           ScanningConfig `mapstructure:"scanning" yaml:"scanning"`
-	Email              EmailConfig    `mapstructure:"email" yaml:"email"`
-	Preview            PreviewConfig  `mapstructure:"preview" yaml:"preview"`
+	Server             ServerConfig     `mapstructure:"server" yaml:"server"`
+	ProjectURL         string           `mapstructure:"project_url" yaml:"project_url"`
+	Domain             string           `mapstructure:"project_domain" yaml:"project_domain"`
+	TelegramWebhookURL string           `mapstructure:"telegram_webhook_url" yaml:"telegram_webhook_url"`
+	SecretKey          string           `mapstructure:"secret_key" yaml:"secret_key"`
+	Postgres           PostgresConfig   `mapstructure:"postgres" yaml:"postgres"`
+	Redis              RedisConfig      `mapstructure:"redis" yaml:"redis"`
+	Sessions           SessionsConfig   `mapstructure:"sessions" yaml:"sessions"`
+	App                AppConfig        `mapstructure:"app" yaml:"app"`
+	Scanning           ScanningConfig   `mapstructure:"scanning" yaml:"scanning"`
+	Email              EmailConfig      `mapstructure:"email" yaml:"email"`
+	Preview            PreviewConfig    `mapstructure:"preview" yaml:"preview"`
+	Conversion         ConversionConfig `mapstructure:"conversion" yaml:"conversion"`
+	Duplicates         DuplicatesConfig `mapstructure:"duplicates" yaml:"duplicates"`
+	Audit              AuditConfig      `mapstructure:"audit" yaml:"audit"`
+	OIDC               OIDCConfig       `mapstructure:"oidc" yaml:"oidc"`
+	TwoFactor          TwoFactorConfig  `mapstructure:"two_factor" yaml:"two_factor"`
+	OPDS               OPDSConfig       `mapstructure:"opds" yaml:"opds"`
+	Uploads            UploadsConfig    `mapstructure:"uploads" yaml:"uploads"`
 
 	// Donate is deliberately a list rather than a fixed set of fields: which
 	// ways of giving are offered is the operator's business, not this
@@ -94,6 +101,7 @@ type AppConfig struct {
 	UsersPath         string   `mapstructure:"users_path" yaml:"users_path"`
 	BookCDNKey        string   `mapstructure:"book_cdn_key" yaml:"book_cdn_key"`
 	PostersPath       string   `mapstructure:"posters_path" yaml:"posters_path"`
+	ThumbnailsPath    string   `mapstructure:"thumbnails_path" yaml:"thumbnails_path"`
 	FileBookCDN       string   `mapstructure:"file_book_cdn" yaml:"file_book_cdn"`
 	MobiConversionDir string   `mapstructure:"mobi_conversion_dir" yaml:"mobi_conversion_dir"`
 	AllowedOrigins    []string `mapstructure:"allowed_origins" yaml:"allowed_origins"`
@@ -107,6 +115,10 @@ type ScanningConfig struct {
 	OpenAILangDetectionTimeout string `mapstructure:"openai_lang_detection_timeout" yaml:"openai_lang_detection_timeout"`
 	MaxConcurrentFiles         int    `mapstructure:"max_concurrent_files" yaml:"max_concurrent_files"`
 	BatchSize                  int    `mapstructure:"batch_size" yaml:"batch_size"`
+	MaxConcurrentArchives      int    `mapstructure:"max_concurrent_archives" yaml:"max_concurrent_archives"`
+	// InpxPath is the INPX catalogue scans take books from. Empty means the
+	// newest .inpx in the archives directory, if any.
+	InpxPath string `mapstructure:"inpx_path" yaml:"inpx_path"`
 }
 
 // PreviewConfig holds the book-preview pipeline settings. Every key carries
@@ -151,6 +163,111 @@ type PreviewConfig struct {
 	MaxPreparedImageBytes int `mapstructure:"max_prepared_image_bytes" yaml:"max_prepared_image_bytes"`
 }
 
+// ConversionConfig holds the on-disk cache of converted books. EPUB and MOBI
+// are derived from the FB2 in the archive, the same way for every reader, so a
+// conversion done once is kept and served again until the cache needs the room.
+type ConversionConfig struct {
+	// CacheDir is where converted books are kept. It must not be the
+	// mobi_conversion_dir: that directory is swept of anything older than an
+	// hour, which is exactly what a cache is meant to outlive.
+	CacheDir string `mapstructure:"cache_dir" yaml:"cache_dir"`
+	// CacheMaxBytes bounds the total size of the cache. Past it, the books
+	// used longest ago are removed first.
+	CacheMaxBytes int64 `mapstructure:"cache_max_bytes" yaml:"cache_max_bytes"`
+}
+
+// DuplicatesConfig holds the duplicate detection settings.
+type DuplicatesConfig struct {
+	// WinnerPolicy picks the book a duplicate group keeps visible: highest_id,
+	// newest, largest, best_metadata or has_cover. An admin may override it
+	// per request.
+	WinnerPolicy string `mapstructure:"winner_policy" yaml:"winner_policy"`
+	// FuzzyThreshold is the score, from 0 to 1, two books must reach to be
+	// proposed as the same work by the near-duplicate pass of a scan.
+	FuzzyThreshold float64 `mapstructure:"fuzzy_threshold" yaml:"fuzzy_threshold"`
+}
+
+// AuditConfig holds the admin audit log settings.
+type AuditConfig struct {
+	// RetentionDays is how long an audit entry is kept. Older entries are
+	// deleted once a day; zero keeps the log forever.
+	RetentionDays int `mapstructure:"retention_days" yaml:"retention_days"`
+}
+
+// UploadsConfig holds the limits on the books readers upload. Uploads are
+// kept in inbox archives under files_path and wait for a moderator.
+type UploadsConfig struct {
+	// Dir is where the inbox archives are kept, relative to files_path.
+	Dir string `mapstructure:"dir" yaml:"dir"`
+	// MaxBytes bounds an uploaded file, and the FB2 unpacked from a zipped
+	// one.
+	MaxBytes int64 `mapstructure:"max_bytes" yaml:"max_bytes"`
+	// DailyLimit is how many books a reader may upload in 24 hours; zero
+	// turns uploads off.
+	DailyLimit int `mapstructure:"daily_limit" yaml:"daily_limit"`
+	// PendingLimit is how many of a reader's uploads may wait for a
+	// moderator at once.
+	PendingLimit int `mapstructure:"pending_limit" yaml:"pending_limit"`
+}
+
+// OIDCConfig holds the OpenID Connect login settings. One provider is
+// supported, beside the username and password login rather than instead of
+// it.
+type OIDCConfig struct {
+	Enabled bool `mapstructure:"enabled" yaml:"enabled"`
+	// Issuer is the provider's issuer URL, from which everything else
+	// about it is discovered.
+	Issuer       string `mapstructure:"issuer" yaml:"issuer"`
+	ClientID     string `mapstructure:"client_id" yaml:"client_id"`
+	ClientSecret string `mapstructure:"client_secret" yaml:"client_secret"`
+	// RedirectURL is the callback registered with the provider; empty
+	// means project_url followed by /api/oidc/callback.
+	RedirectURL string   `mapstructure:"redirect_url" yaml:"redirect_url"`
+	Scopes      []string `mapstructure:"scopes" yaml:"scopes"`
+	// Label names the provider on the login page.
+	Label string `mapstructure:"label" yaml:"label"`
+
+	// AutoProvision makes an account for whoever logs in through the
+	// provider for the first time, as the rules below allow, instead of
+	// requiring an invite and a link. Off, an identity logs in only once
+	// the owner of an account has linked it.
+	AutoProvision bool `mapstructure:"auto_provision" yaml:"auto_provision"`
+	// GroupsClaim names the ID token claim that lists groups.
+	GroupsClaim string `mapstructure:"groups_claim" yaml:"groups_claim"`
+	// AllowedGroups, when set, limits new accounts to members of these.
+	AllowedGroups []string `mapstructure:"allowed_groups" yaml:"allowed_groups"`
+	// AllowedEmailDomains, when set, limits new accounts to verified
+	// emails at these domains.
+	AllowedEmailDomains []string `mapstructure:"allowed_email_domains" yaml:"allowed_email_domains"`
+}
+
+// TwoFactorConfig holds the TOTP second factor settings. Any user may
+// enrol one; these only name the installation and decide whether admin
+// staff must.
+type TwoFactorConfig struct {
+	// Issuer names this installation in authenticator apps; empty means
+	// email.product_name.
+	Issuer string `mapstructure:"issuer" yaml:"issuer"`
+	// RequireForAdmins keeps superusers and staff with any permission out
+	// of the admin API until they log in with a second factor.
+	RequireForAdmins bool `mapstructure:"require_for_admins" yaml:"require_for_admins"`
+}
+
+// OPDSConfig shapes the OPDS catalogue. Its feeds are worded in each
+// reader's language; these name the catalogue and lay out its root.
+type OPDSConfig struct {
+	// Title names the catalogue; empty means email.product_name.
+	Title string `mapstructure:"title" yaml:"title"`
+	// Icon is the address of the feeds' icon.
+	Icon string `mapstructure:"icon" yaml:"icon"`
+	// Language, "ru" or "en", is for readers whose account and
+	// Accept-Language name neither.
+	Language string `mapstructure:"language" yaml:"language"`
+	// Navigation lists the entries of the root feed, in order, from
+	// favorites, languages, genres and collections.
+	Navigation []string `mapstructure:"navigation" yaml:"navigation"`
+}
+
 // PreviewRedisConfig is the separate Redis destination for the preview
 // cache. Empty host/port/password mean "take the main Redis value" — see
 // GetPreviewRedisAddress and GetPreviewRedisPassword. DB is the exception:
@@ -334,6 +451,15 @@ const (
 	PreviewMaxPreparedImageBytes = 48 << 20 // 48 MiB
 )
 
+// UploadMaxBytes is the default bound on an uploaded book: room for an
+// illustrated novel, far below what a library archive may hold.
+const UploadMaxBytes = 32 << 20 // 32 MiB
+
+// ConversionCacheMaxBytes is the default ceiling on the conversion cache: room
+// for a few thousand converted novels, and little enough to sit beside the
+// library on the same volume.
+const ConversionCacheMaxBytes = 2 << 30 // 2 GiB
+
 // setDefaults sets default configuration values
 func setDefaults() {
 	// Server defaults
@@ -386,9 +512,37 @@ func setDefaults() {
 	viper.SetDefault("app.files_path", "./files/")
 	viper.SetDefault("app.users_path", "./users/")
 	viper.SetDefault("app.posters_path", "./posters/")
+	viper.SetDefault("app.thumbnails_path", "./thumbnails/")
 	viper.SetDefault("app.mobi_conversion_dir", "./mobi/")
 	viper.SetDefault("app.allowed_origins", []string{})
 
+	// Conversion cache defaults
+	viper.SetDefault("conversion.cache_dir", "./conversions/")
+	viper.SetDefault("conversion.cache_max_bytes", ConversionCacheMaxBytes)
+
+	// Duplicate detection defaults
+	viper.SetDefault("duplicates.winner_policy", "highest_id")
+	viper.SetDefault("duplicates.fuzzy_threshold", 0.75)
+
+	// Audit log defaults
+	viper.SetDefault("audit.retention_days", 365)
+
+	viper.SetDefault("oidc.enabled", false)
+	viper.SetDefault("oidc.scopes", []string{"openid", "profile", "email"})
+	viper.SetDefault("oidc.label", "SSO")
+	viper.SetDefault("oidc.groups_claim", "groups")
+
+	viper.SetDefault("two_factor.require_for_admins", false)
+
+	viper.SetDefault("opds.icon", "/favicon.ico")
+	viper.SetDefault("opds.language", "ru")
+	viper.SetDefault("opds.navigation", []string{"favorites", "recommended", "languages", "genres", "collections"})
+
+	viper.SetDefault("uploads.dir", "uploads")
+	viper.SetDefault("uploads.max_bytes", UploadMaxBytes)
+	viper.SetDefault("uploads.daily_limit", 10)
+	viper.SetDefault("uploads.pending_limit", 20)
+
 	// Scanning defaults
 	viper.SetDefault("scanning.skip_duplicates", true)
 	viper.SetDefault("scanning.enable_language_detection", true)
@@ -396,6 +550,8 @@ func setDefaults() {
 	viper.SetDefault("scanning.openai_lang_detection_timeout", "5s")
 	viper.SetDefault("scanning.max_concurrent_files", 1)
 	viper.SetDefault("scanning.batch_size", 50)
+	viper.SetDefault("scanning.max_concurrent_archives", 1)
+	viper.SetDefault("scanning.inpx_path", "")
 }
 
 // validateConfig validates the loaded configuration
@@ -413,6 +569,10 @@ func validateConfig(cfg *Config) error {
 		return fmt.Errorf("session keys are required")
 	}
 
+	if cfg.OIDC.Enabled && (cfg.OIDC.Issuer == "" || cfg.OIDC.ClientID == "") {
+		return fmt.Errorf("oidc is enabled but oidc.issuer or oidc.client_id is missing")
+	}
+
 	// Validate port range
 	if cfg.Server.Port < 1 || cfg.Server.Port > 65535 {
 		return fmt.Errorf("invalid server port: %d", cfg.Server.Port)
@@ -423,7 +583,9 @@ func validateConfig(cfg *Config) error {
 		cfg.App.FilesPath,
 		cfg.App.UsersPath,
 		cfg.App.PostersPath,
+		cfg.App.ThumbnailsPath,
 		cfg.App.MobiConversionDir,
+		cfg.Conversion.CacheDir,
 	}
 
 	for _, path := range paths {
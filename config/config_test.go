@@ -277,3 +277,29 @@ func TestLoadLeavesDonateEmptyWhenUnset(t *testing.T) {
 		t.Errorf("expected no donate methods, got %+v", cfg.Donate)
 	}
 }
+
+// TestLoadConversionDefaults pins where converted books are kept when nothing
+// says otherwise — a directory of its own, never the swept mobi directory —
+// and that validation creates it.
+func TestLoadConversionDefaults(t *testing.T) {
+	isolate(t)
+	setEnv(t, requiredEnv)
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() = %v, want nil", err)
+	}
+
+	if cfg.Conversion.CacheDir != "./conversions/" {
+		t.Errorf("Conversion.CacheDir = %q, want the default ./conversions/", cfg.Conversion.CacheDir)
+	}
+	if cfg.Conversion.CacheDir == cfg.App.MobiConversionDir {
+		t.Error("the conversion cache shares the directory the hourly sweep empties")
+	}
+	if cfg.Conversion.CacheMaxBytes != 2<<30 {
+		t.Errorf("Conversion.CacheMaxBytes = %d, want the default 2 GiB", cfg.Conversion.CacheMaxBytes)
+	}
+	if _, err := os.Stat(cfg.Conversion.CacheDir); err != nil {
+		t.Errorf("validation did not create the cache directory: %v", err)
+	}
+}
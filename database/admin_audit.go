@@ -0,0 +1,106 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"gopds-api/models"
+
+	"github.com/go-pg/pg/v10/orm"
+)
+
+// auditExportBatch is how many entries an export reads per query.
+const auditExportBatch = 1000
+
+// InsertAuditEntry appends one entry to the audit log.
+func InsertAuditEntry(ctx context.Context, entry *models.AuditEntry) error {
+	if entry.Changes == nil {
+		entry.Changes = map[string]models.AuditChange{}
+	}
+	_, err := db.ModelContext(ctx, entry).Returning("id, created_at").Insert()
+	return err
+}
+
+// applyAuditFilters narrows an audit log query to the filters.
+func applyAuditFilters(q *orm.Query, f models.AuditFilters) *orm.Query {
+	if f.ActorID > 0 {
+		q = q.Where("actor_id = ?", f.ActorID)
+	}
+	if f.Action != "" {
+		q = q.Where("action = ?", f.Action)
+	}
+	if f.TargetType != "" {
+		q = q.Where("target_type = ?", f.TargetType)
+	}
+	if f.TargetID != "" {
+		q = q.Where("target_id = ?", f.TargetID)
+	}
+	if f.From != nil {
+		q = q.Where("created_at >= ?", *f.From)
+	}
+	if f.To != nil {
+		q = q.Where("created_at < ?", *f.To)
+	}
+	return q
+}
+
+// ListAuditEntries returns one page of the entries the filters match,
+// newest first, and how many match in all.
+func ListAuditEntries(ctx context.Context, f models.AuditFilters) ([]models.AuditEntry, int, error) {
+	if f.Page < 1 {
+		f.Page = 1
+	}
+	if f.PageSize < 1 || f.PageSize > 200 {
+		f.PageSize = 50
+	}
+	entries := []models.AuditEntry{}
+	total, err := applyAuditFilters(db.ModelContext(ctx, &entries), f).
+		Order("id DESC").
+		Limit(f.PageSize).
+		Offset((f.Page - 1) * f.PageSize).
+		SelectAndCount()
+	if err != nil {
+		return nil, 0, err
+	}
+	return entries, total, nil
+}
+
+// ExportAuditEntries hands every entry the filters match to fn, oldest
+// first, reading them in batches so an export of the whole log never holds
+// it in memory. It stops at the first error fn returns.
+func ExportAuditEntries(ctx context.Context, f models.AuditFilters, fn func(*models.AuditEntry) error) error {
+	var afterID int64
+	for {
+		var batch []models.AuditEntry
+		err := applyAuditFilters(db.ModelContext(ctx, &batch), f).
+			Where("id > ?", afterID).
+			Order("id ASC").
+			Limit(auditExportBatch).
+			Select()
+		if err != nil {
+			return err
+		}
+		for i := range batch {
+			if err := fn(&batch[i]); err != nil {
+				return err
+			}
+		}
+		if len(batch) < auditExportBatch {
+			return nil
+		}
+		afterID = batch[len(batch)-1].ID
+	}
+}
+
+// PurgeAuditEntries deletes the entries written before the cutoff and
+// returns how many went.
+func PurgeAuditEntries(ctx context.Context, before time.Time) (int, error) {
+	res, err := db.ModelContext(ctx, (*models.AuditEntry)(nil)).
+		Where("created_at < ?", before).
+		Delete()
+	if err != nil {
+		return 0, fmt.Errorf("purging audit log: %w", err)
+	}
+	return res.RowsAffected(), nil
+}
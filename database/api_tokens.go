@@ -0,0 +1,78 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gopds-api/models"
+
+	"github.com/go-pg/pg/v10"
+)
+
+// ErrAPITokenNotFound reports a personal access token that does not exist,
+// or not for the user asking.
+var ErrAPITokenNotFound = errors.New("api token not found")
+
+// apiTokenTouchInterval spaces out the last-used updates of a busy token:
+// one write a minute is enough for a timestamp shown to the minute.
+const apiTokenTouchInterval = time.Minute
+
+// CreateAPIToken stores a new personal access token.
+func CreateAPIToken(ctx context.Context, token *models.APIToken) error {
+	_, err := db.ModelContext(ctx, token).Returning("*").Insert()
+	return err
+}
+
+// ListAPITokens returns a user's personal access tokens, newest first.
+func ListAPITokens(ctx context.Context, userID int64) ([]models.APIToken, error) {
+	tokens := []models.APIToken{}
+	err := db.ModelContext(ctx, &tokens).
+		Where("user_id = ?", userID).
+		Order("created_at DESC", "id DESC").
+		Select()
+	return tokens, err
+}
+
+// APITokenByHash returns the personal access token with a hash.
+func APITokenByHash(ctx context.Context, hash string) (*models.APIToken, error) {
+	token := &models.APIToken{}
+	err := db.ModelContext(ctx, token).Where("token_hash = ?", hash).Select()
+	if errors.Is(err, pg.ErrNoRows) {
+		return nil, ErrAPITokenNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+// TouchAPIToken records that a token was just used, unless that was
+// recorded less than apiTokenTouchInterval ago.
+func TouchAPIToken(ctx context.Context, id int64) error {
+	now := time.Now()
+	_, err := db.ModelContext(ctx, (*models.APIToken)(nil)).
+		Set("last_used_at = ?", now).
+		Where("id = ?", id).
+		WhereGroup(func(q *pg.Query) (*pg.Query, error) {
+			return q.Where("last_used_at IS NULL").
+				WhereOr("last_used_at < ?", now.Add(-apiTokenTouchInterval)), nil
+		}).
+		Update()
+	return err
+}
+
+// DeleteAPIToken revokes one of a user's personal access tokens.
+func DeleteAPIToken(ctx context.Context, userID, id int64) error {
+	res, err := db.ModelContext(ctx, (*models.APIToken)(nil)).
+		Where("id = ?", id).
+		Where("user_id = ?", userID).
+		Delete()
+	if err != nil {
+		return err
+	}
+	if res.RowsAffected() == 0 {
+		return ErrAPITokenNotFound
+	}
+	return nil
+}
@@ -0,0 +1,450 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"gopds-api/models"
+
+	"github.com/go-pg/pg/v10"
+)
+
+var (
+	// ErrAuthorNotFound reports an author id that does not exist.
+	ErrAuthorNotFound = errors.New("author not found")
+	// ErrAliasTaken reports an alias that already names an author.
+	ErrAliasTaken = errors.New("alias already names an author")
+	// ErrMergeIntoItself reports a merge whose sources include its target.
+	ErrMergeIntoItself = errors.New("cannot merge an author into itself")
+	// ErrMergeNotFound reports a merge id that does not exist.
+	ErrMergeNotFound = errors.New("author merge not found")
+	// ErrMergeUndone reports a merge that has already been undone.
+	ErrMergeUndone = errors.New("author merge already undone")
+	// ErrMergeNotUndoable reports a merge whose target is gone — merged into
+	// another author or deleted since. Undo that later change first.
+	ErrMergeNotUndoable = errors.New("merge target no longer exists")
+)
+
+// GetOrCreateAuthor returns the author a name stands for, creating one when
+// nothing does. An alias comes first — it is an admin's decision about that
+// exact spelling — then an author of exactly that name.
+func GetOrCreateAuthor(tx *pg.Tx, name string) (int64, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return 0, errors.New("author name is empty")
+	}
+
+	var aliasedID int64
+	_, err := tx.QueryOne(pg.Scan(&aliasedID), `
+		SELECT author_id FROM opds_catalog_author_alias
+		WHERE public.search_normalize(alias) = public.search_normalize(?)`, name)
+	if err == nil {
+		return aliasedID, nil
+	}
+	if !errors.Is(err, pg.ErrNoRows) {
+		return 0, err
+	}
+
+	existing := &models.Author{}
+	err = tx.Model(existing).
+		Where("full_name = ?", name).
+		Limit(1).
+		Select(existing)
+	if err == nil {
+		return existing.ID, nil
+	}
+	if err != pg.ErrNoRows {
+		return 0, err
+	}
+
+	author := &models.Author{FullName: name}
+	_, err = tx.Model(author).Insert()
+	if err != nil {
+		return 0, err
+	}
+	return author.ID, nil
+}
+
+// ListAuthorAliases returns the aliases of an author, oldest first.
+func ListAuthorAliases(ctx context.Context, authorID int64) ([]models.AuthorAlias, error) {
+	aliases := []models.AuthorAlias{}
+	err := db.ModelContext(ctx, &aliases).
+		Where("author_id = ?", authorID).
+		Order("id ASC").
+		Select()
+	if err != nil {
+		return nil, err
+	}
+	return aliases, nil
+}
+
+// AddAuthorAlias records another name for an author.
+func AddAuthorAlias(ctx context.Context, authorID int64, alias, kind string) (*models.AuthorAlias, error) {
+	record := &models.AuthorAlias{AuthorID: authorID, Alias: strings.TrimSpace(alias), Kind: kind}
+	err := db.RunInTransaction(ctx, func(tx *pg.Tx) error {
+		if err := lockAuthor(tx, authorID, nil); err != nil {
+			return err
+		}
+		taken, err := aliasExists(tx, record.Alias)
+		if err != nil {
+			return err
+		}
+		if taken {
+			return ErrAliasTaken
+		}
+		_, err = tx.Model(record).Insert()
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// DeleteAuthorAlias removes an alias and returns it. Books already linked
+// through it stay with the author; books scanned later under that name get
+// their own.
+func DeleteAuthorAlias(ctx context.Context, aliasID int64) (*models.AuthorAlias, error) {
+	alias := &models.AuthorAlias{}
+	res, err := db.ModelContext(ctx, alias).
+		Where("id = ?", aliasID).
+		Returning("*").
+		Delete()
+	if err != nil {
+		return nil, err
+	}
+	if res.RowsAffected() == 0 {
+		return nil, pg.ErrNoRows
+	}
+	return alias, nil
+}
+
+// lockAuthor loads an author for update into dst, when given.
+func lockAuthor(tx *pg.Tx, id int64, dst *models.Author) error {
+	if dst == nil {
+		dst = &models.Author{}
+	}
+	err := tx.Model(dst).Where("id = ?", id).For("UPDATE").Select()
+	if errors.Is(err, pg.ErrNoRows) {
+		return ErrAuthorNotFound
+	}
+	return err
+}
+
+func aliasExists(tx *pg.Tx, alias string) (bool, error) {
+	return tx.Model((*models.AuthorAlias)(nil)).
+		Where("public.search_normalize(alias) = public.search_normalize(?)", alias).
+		Exists()
+}
+
+// addMergeAlias records name as an alias of the target unless it already
+// names it: an alias or the target's own name. It returns the new alias id,
+// or 0 when none was needed.
+func addMergeAlias(tx *pg.Tx, targetID int64, targetName, name string) (int64, error) {
+	var same bool
+	_, err := tx.QueryOne(pg.Scan(&same),
+		`SELECT public.search_normalize(?) = public.search_normalize(?)`, name, targetName)
+	if err != nil || same {
+		return 0, err
+	}
+	taken, err := aliasExists(tx, name)
+	if err != nil || taken {
+		return 0, err
+	}
+	alias := &models.AuthorAlias{AuthorID: targetID, Alias: name, Kind: models.AuthorAliasKindAlias}
+	if _, err := tx.Model(alias).Insert(); err != nil {
+		return 0, err
+	}
+	return alias.ID, nil
+}
+
+// MergeAuthors folds the sources into the target: their book links move to
+// it, their aliases and their names become its aliases, and the source rows
+// are deleted. A non-empty canonicalName renames the target, keeping the old
+// name as an alias. Everything needed to undo it is kept in the returned
+// audit record.
+func MergeAuthors(ctx context.Context, targetID int64, sourceIDs []int64, canonicalName string, mergedBy *int64) (*models.AuthorMerge, error) {
+	merge := &models.AuthorMerge{
+		TargetID:        targetID,
+		Sources:         []models.AuthorMergeSource{},
+		CreatedAliasIDs: []int64{},
+		MergedBy:        mergedBy,
+	}
+	err := db.RunInTransaction(ctx, func(tx *pg.Tx) error {
+		target := &models.Author{}
+		if err := lockAuthor(tx, targetID, target); err != nil {
+			return err
+		}
+		merge.TargetOldName = target.FullName
+		merge.TargetNewName = target.FullName
+
+		seen := make(map[int64]bool, len(sourceIDs))
+		for _, sourceID := range sourceIDs {
+			if sourceID == targetID {
+				return ErrMergeIntoItself
+			}
+			if seen[sourceID] {
+				continue
+			}
+			seen[sourceID] = true
+
+			source := &models.Author{}
+			if err := lockAuthor(tx, sourceID, source); err != nil {
+				return err
+			}
+			record, err := moveAuthor(tx, source, targetID)
+			if err != nil {
+				return err
+			}
+			aliasID, err := addMergeAlias(tx, targetID, target.FullName, source.FullName)
+			if err != nil {
+				return err
+			}
+			if aliasID != 0 {
+				merge.CreatedAliasIDs = append(merge.CreatedAliasIDs, aliasID)
+			}
+			merge.Sources = append(merge.Sources, record)
+		}
+
+		if name := strings.TrimSpace(canonicalName); name != "" && name != target.FullName {
+			if _, err := tx.Model(target).Set("full_name = ?", name).WherePK().Update(); err != nil {
+				return err
+			}
+			merge.TargetNewName = name
+			aliasID, err := addMergeAlias(tx, targetID, name, merge.TargetOldName)
+			if err != nil {
+				return err
+			}
+			if aliasID != 0 {
+				merge.CreatedAliasIDs = append(merge.CreatedAliasIDs, aliasID)
+			}
+		}
+
+		_, err := tx.Model(merge).Insert()
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return merge, nil
+}
+
+// moveAuthor moves everything of source onto the target and deletes it.
+func moveAuthor(tx *pg.Tx, source *models.Author, targetID int64) (models.AuthorMergeSource, error) {
+	record := models.AuthorMergeSource{
+		ID:            source.ID,
+		FullName:      source.FullName,
+		MovedBookIDs:  []int64{},
+		SharedBookIDs: []int64{},
+		AliasIDs:      []int64{},
+	}
+
+	_, err := tx.QueryOne(pg.Scan(pg.Array(&record.SharedBookIDs)), `
+		SELECT COALESCE(array_agg(DISTINCT s.book_id ORDER BY s.book_id), '{}')
+		FROM opds_catalog_bauthor AS s
+		WHERE s.author_id = ? AND EXISTS (
+			SELECT 1 FROM opds_catalog_bauthor AS t
+			WHERE t.author_id = ? AND t.book_id = s.book_id)`, source.ID, targetID)
+	if err != nil {
+		return record, err
+	}
+	_, err = tx.QueryOne(pg.Scan(pg.Array(&record.MovedBookIDs)), `
+		SELECT COALESCE(array_agg(DISTINCT s.book_id ORDER BY s.book_id), '{}')
+		FROM opds_catalog_bauthor AS s
+		WHERE s.author_id = ? AND NOT EXISTS (
+			SELECT 1 FROM opds_catalog_bauthor AS t
+			WHERE t.author_id = ? AND t.book_id = s.book_id)`, source.ID, targetID)
+	if err != nil {
+		return record, err
+	}
+
+	if len(record.MovedBookIDs) > 0 {
+		_, err = tx.Exec(`UPDATE opds_catalog_bauthor SET author_id = ? WHERE author_id = ? AND book_id IN (?)`,
+			targetID, source.ID, pg.In(record.MovedBookIDs))
+		if err != nil {
+			return record, err
+		}
+	}
+	// What is left are the links to books the target already has.
+	if _, err = tx.Exec(`DELETE FROM opds_catalog_bauthor WHERE author_id = ?`, source.ID); err != nil {
+		return record, err
+	}
+
+	_, err = tx.QueryOne(pg.Scan(pg.Array(&record.AliasIDs)), `
+		WITH moved AS (
+			UPDATE opds_catalog_author_alias SET author_id = ? WHERE author_id = ? RETURNING id
+		)
+		SELECT COALESCE(array_agg(id ORDER BY id), '{}') FROM moved`, targetID, source.ID)
+	if err != nil {
+		return record, err
+	}
+
+	_, err = tx.Model(source).WherePK().Delete()
+	return record, err
+}
+
+// UndoAuthorMerge puts a merge back: the source authors return under their
+// ids and names with their book links and aliases, the aliases the merge
+// created go, and a renamed target gets its old name back unless it has been
+// renamed again since.
+func UndoAuthorMerge(ctx context.Context, mergeID int64, undoneBy *int64) (*models.AuthorMerge, error) {
+	merge := &models.AuthorMerge{ID: mergeID}
+	err := db.RunInTransaction(ctx, func(tx *pg.Tx) error {
+		err := tx.Model(merge).WherePK().For("UPDATE").Select()
+		if errors.Is(err, pg.ErrNoRows) {
+			return ErrMergeNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if merge.UndoneAt != nil {
+			return ErrMergeUndone
+		}
+		target := &models.Author{}
+		if err := lockAuthor(tx, merge.TargetID, target); err != nil {
+			if errors.Is(err, ErrAuthorNotFound) {
+				return ErrMergeNotUndoable
+			}
+			return err
+		}
+
+		if len(merge.CreatedAliasIDs) > 0 {
+			if _, err := tx.Exec(`DELETE FROM opds_catalog_author_alias WHERE id IN (?)`, pg.In(merge.CreatedAliasIDs)); err != nil {
+				return err
+			}
+		}
+		if merge.TargetOldName != merge.TargetNewName && target.FullName == merge.TargetNewName {
+			if _, err := tx.Model(target).Set("full_name = ?", merge.TargetOldName).WherePK().Update(); err != nil {
+				return err
+			}
+		}
+
+		for _, source := range merge.Sources {
+			if _, err := tx.Model(&models.Author{ID: source.ID, FullName: source.FullName}).Insert(); err != nil {
+				return err
+			}
+			if len(source.AliasIDs) > 0 {
+				_, err := tx.Exec(`UPDATE opds_catalog_author_alias SET author_id = ? WHERE author_id = ? AND id IN (?)`,
+					source.ID, merge.TargetID, pg.In(source.AliasIDs))
+				if err != nil {
+					return err
+				}
+			}
+			if len(source.MovedBookIDs) > 0 {
+				_, err := tx.Exec(`UPDATE opds_catalog_bauthor SET author_id = ? WHERE author_id = ? AND book_id IN (?)`,
+					source.ID, merge.TargetID, pg.In(source.MovedBookIDs))
+				if err != nil {
+					return err
+				}
+			}
+			if len(source.SharedBookIDs) > 0 {
+				// Books deleted since the merge are skipped, not resurrected.
+				_, err := tx.Exec(`
+					INSERT INTO opds_catalog_bauthor (author_id, book_id)
+					SELECT ?, b.id FROM opds_catalog_book AS b WHERE b.id IN (?)`,
+					source.ID, pg.In(source.SharedBookIDs))
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		now := time.Now()
+		merge.UndoneAt = &now
+		merge.UndoneBy = undoneBy
+		_, err = tx.Model(merge).Column("undone_at", "undone_by").WherePK().Update()
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return merge, nil
+}
+
+// ListAuthorMerges returns one page of the merge audit trail, newest first,
+// and how many merges there are in all.
+func ListAuthorMerges(ctx context.Context, page, pageSize int) ([]models.AuthorMerge, int, error) {
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 200 {
+		pageSize = 50
+	}
+	merges := []models.AuthorMerge{}
+	total, err := db.ModelContext(ctx, &merges).
+		Order("id DESC").
+		Limit(pageSize).
+		Offset((page - 1) * pageSize).
+		SelectAndCount()
+	if err != nil {
+		return nil, 0, err
+	}
+	return merges, total, nil
+}
+
+// AuthorMergeCandidate is an author that may be the same person as another.
+type AuthorMergeCandidate struct {
+	ID         int64   `pg:"id" json:"id"`
+	FullName   string  `pg:"full_name" json:"full_name"`
+	BooksCount int     `pg:"books_count" json:"books_count"`
+	Score      float64 `pg:"score" json:"score"`
+}
+
+// authorMergeCandidatesSQL finds authors whose normalized name is trigram-
+// similar to either spelling of another's: the name as written, or its
+// Latin transliteration, which is what reaches an author filed from an
+// English-language edition.
+const authorMergeCandidatesSQL = `
+WITH q AS (
+    SELECT public.search_normalize(?::text) AS name,
+        public.search_normalize(?::text) AS translit
+)
+SELECT a.id, a.full_name,
+    GREATEST(
+        similarity(public.search_normalize(a.full_name), (SELECT q.name FROM q)),
+        similarity(public.search_normalize(a.full_name), (SELECT q.translit FROM q))
+    ) AS score,
+    (SELECT count(*) FROM opds_catalog_bauthor AS ba WHERE ba.author_id = a.id) AS books_count
+FROM opds_catalog_author AS a
+WHERE a.id != ?
+    AND (public.search_normalize(a.full_name) % (SELECT q.name FROM q)
+        OR public.search_normalize(a.full_name) % (SELECT q.translit FROM q))
+ORDER BY score DESC, books_count DESC, a.id ASC
+LIMIT ?
+`
+
+// FindAuthorMergeCandidates returns up to limit authors whose names are
+// trigram-similar to name or to translit, excluding the author itself.
+func FindAuthorMergeCandidates(ctx context.Context, authorID int64, name, translit string, limit int) ([]AuthorMergeCandidate, error) {
+	candidates := []AuthorMergeCandidate{}
+	_, err := db.QueryContext(ctx, &candidates, authorMergeCandidatesSQL, name, translit, authorID, limit)
+	if err != nil {
+		return nil, err
+	}
+	return candidates, nil
+}
+
+// AuthorWithCount is an author and how many books are linked to it.
+type AuthorWithCount struct {
+	ID         int64  `pg:"id" json:"id"`
+	FullName   string `pg:"full_name" json:"full_name"`
+	BooksCount int    `pg:"books_count" json:"books_count"`
+}
+
+// ListAuthorsWithCounts returns every author that has books, for the merge
+// suggestion pass. Authors without books are left out: there is nothing of
+// theirs to merge.
+func ListAuthorsWithCounts(ctx context.Context) ([]AuthorWithCount, error) {
+	var authors []AuthorWithCount
+	_, err := db.QueryContext(ctx, &authors, `
+		SELECT a.id, a.full_name, count(ba.book_id) AS books_count
+		FROM opds_catalog_author AS a
+		JOIN opds_catalog_bauthor AS ba ON ba.author_id = a.id
+		GROUP BY a.id, a.full_name`)
+	if err != nil {
+		return nil, err
+	}
+	return authors, nil
+}
@@ -0,0 +1,457 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gopds-api/models"
+
+	"github.com/go-pg/pg/v10"
+)
+
+var (
+	// ErrBulkEditNotFound reports a bulk edit id that does not exist.
+	ErrBulkEditNotFound = errors.New("bulk edit not found")
+	// ErrBulkEditActive reports a bulk edit started, or undone, while another
+	// is still being applied or undone. Two at once would leave the undo
+	// records of one describing books the other has since changed.
+	ErrBulkEditActive = errors.New("another bulk edit is in progress")
+	// ErrBulkEditNotUndoable reports an undo of an edit that is still running
+	// or has been undone already.
+	ErrBulkEditNotUndoable = errors.New("bulk edit cannot be undone in its current state")
+	// ErrSeriesNotFound reports a series id that does not exist.
+	ErrSeriesNotFound = errors.New("series not found")
+)
+
+// SelectBookIDs returns the ids of the books filters select, at most limit
+// of them: in id order for a list scope, in rank order for a title search,
+// which goes through the same ranking the book list searches with.
+//
+//nolint:gocritic // one filter set, as the list path takes it
+func SelectBookIDs(ctx context.Context, filters models.BookFilters, limit int) ([]int64, error) {
+	if query := strings.TrimSpace(filters.Title); query != "" {
+		return NewPGSearchRepository(db).SearchBookIDs(ctx, models.BookSearchRequest{
+			Query:               query,
+			Language:            filters.Lang,
+			AuthorID:            int64(filters.Author),
+			SeriesID:            int64(filters.Series),
+			GenreID:             int64(filters.Genre),
+			CollectionID:        filters.Collection,
+			CuratedCollectionID: filters.CuratedCollection,
+			Unapproved:          filters.UnApproved,
+			IncludeHidden:       filters.IncludeHidden,
+			Moderator:           true,
+			Limit:               limit,
+		})
+	}
+
+	query, err := applyListFilters(db.ModelContext(ctx, (*models.Book)(nil)).Column("book.id"), filters, 0)
+	if err != nil {
+		return nil, err
+	}
+	ids := []int64{}
+	if err := query.Order("book.id ASC").Limit(limit).Select(&ids); err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// CreateBookBulkEdit records a new bulk edit as running. The patch is
+// resolved on the way in — a series or an author given by name becomes an
+// id, created when there is none — so the record says exactly what was
+// applied; genres and series given by id must exist.
+func CreateBookBulkEdit(ctx context.Context, edit *models.BookBulkEdit) error {
+	return db.RunInTransaction(ctx, func(tx *pg.Tx) error {
+		if err := ensureNoActiveBulkEdit(tx, 0); err != nil {
+			return err
+		}
+		if err := resolveBulkPatch(tx, &edit.Patch); err != nil {
+			return err
+		}
+		edit.Status = models.BulkEditRunning
+		_, err := tx.Model(edit).Insert()
+		return err
+	})
+}
+
+// ensureNoActiveBulkEdit fails with ErrBulkEditActive when an edit other
+// than except is running or being undone. The table lock makes the check
+// and the write that follows it one step for concurrent callers.
+func ensureNoActiveBulkEdit(tx *pg.Tx, except int64) error {
+	if _, err := tx.Exec(`LOCK TABLE book_bulk_edits IN SHARE ROW EXCLUSIVE MODE`); err != nil {
+		return err
+	}
+	active, err := tx.Model((*models.BookBulkEdit)(nil)).
+		Where("status IN (?, ?)", models.BulkEditRunning, models.BulkEditUndoing).
+		Where("id != ?", except).
+		Exists()
+	if err != nil {
+		return err
+	}
+	if active {
+		return ErrBulkEditActive
+	}
+	return nil
+}
+
+func resolveBulkPatch(tx *pg.Tx, patch *models.BookBulkPatch) error {
+	genreIDs := append(append([]int64{}, patch.AddGenres...), patch.RemoveGenres...)
+	if len(genreIDs) > 0 {
+		var found int
+		_, err := tx.QueryOne(pg.Scan(&found),
+			`SELECT count(DISTINCT id) FROM opds_catalog_genre WHERE id IN (?)`, pg.In(genreIDs))
+		if err != nil {
+			return err
+		}
+		if found != len(uniqueIDs(genreIDs)) {
+			return ErrGenreNotFound
+		}
+	}
+
+	if s := patch.Series; s != nil {
+		if s.ID > 0 {
+			exists, err := tx.Model((*models.Series)(nil)).Where("id = ?", s.ID).Exists()
+			if err != nil {
+				return err
+			}
+			if !exists {
+				return ErrSeriesNotFound
+			}
+		} else {
+			id, err := getOrCreateSeriesByName(tx, s.Name)
+			if err != nil {
+				return err
+			}
+			s.ID = id
+		}
+	}
+
+	if a := patch.Authors; a != nil {
+		resolved := make([]models.Author, 0, len(a.To))
+		for _, author := range a.To {
+			name := strings.TrimSpace(author.FullName)
+			if author.ID > 0 {
+				found := &models.Author{}
+				err := tx.Model(found).Where("id = ?", author.ID).Select()
+				if errors.Is(err, pg.ErrNoRows) {
+					return ErrAuthorNotFound
+				}
+				if err != nil {
+					return err
+				}
+				resolved = append(resolved, *found)
+				continue
+			}
+			if name == "" {
+				continue
+			}
+			id, err := GetOrCreateAuthor(tx, name)
+			if err != nil {
+				return err
+			}
+			resolved = append(resolved, models.Author{ID: id, FullName: name})
+		}
+		a.To = resolved
+	}
+	return nil
+}
+
+func uniqueIDs(ids []int64) []int64 {
+	seen := make(map[int64]bool, len(ids))
+	out := make([]int64, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			out = append(out, id)
+		}
+	}
+	return out
+}
+
+// ApplyBookBulkEdit applies the edit's patch to one book, index being its
+// place in the selection, and records what the book was before — in one
+// transaction, so the undo log never lags the catalog. It reports whether
+// the book changed; a book the patch leaves as it was, or one deleted since
+// the selection was made, gets no undo record.
+func ApplyBookBulkEdit(ctx context.Context, edit *models.BookBulkEdit, bookID int64, index int) (bool, error) {
+	changed := false
+	err := db.RunInTransaction(ctx, func(tx *pg.Tx) error {
+		before, found, err := loadBookBulkState(tx, bookID)
+		if err != nil || !found {
+			return err
+		}
+		after := edit.Patch.Apply(before, index)
+		if after.Equal(before) {
+			return nil
+		}
+		if err := writeBookBulkState(tx, bookID, before, after); err != nil {
+			return err
+		}
+		_, err = tx.Model(&models.BookBulkEditBook{EditID: edit.ID, BookID: bookID, Before: before}).
+			OnConflict("DO NOTHING").
+			Insert()
+		changed = err == nil
+		return err
+	})
+	return changed, err
+}
+
+// loadBookBulkState reads what a bulk edit can change of a book, locking the
+// book row until the transaction ends. found is false for a missing book.
+func loadBookBulkState(tx *pg.Tx, bookID int64) (models.BookBulkState, bool, error) {
+	var state models.BookBulkState
+	book := &models.Book{}
+	err := tx.Model(book).
+		Column("lang", "approved", "duplicate_hidden").
+		Where("id = ?", bookID).
+		For("UPDATE").
+		Select()
+	if errors.Is(err, pg.ErrNoRows) {
+		return state, false, nil
+	}
+	if err != nil {
+		return state, false, err
+	}
+	state.Lang = book.Lang
+	state.Approved = book.Approved
+	state.DuplicateHidden = book.DuplicateHidden
+
+	state.GenreIDs = []int64{}
+	_, err = tx.Query(&state.GenreIDs,
+		`SELECT genre_id FROM opds_catalog_bgenre WHERE book_id = ? ORDER BY id`, bookID)
+	if err != nil {
+		return state, false, err
+	}
+	state.AuthorIDs = []int64{}
+	_, err = tx.Query(&state.AuthorIDs,
+		`SELECT author_id FROM opds_catalog_bauthor WHERE book_id = ? ORDER BY id`, bookID)
+	if err != nil {
+		return state, false, err
+	}
+	state.Series = []models.BookSeriesRef{}
+	_, err = tx.Query(&state.Series,
+		`SELECT ser_id AS id, ser_no FROM opds_catalog_bseries WHERE book_id = ? ORDER BY id`, bookID)
+	if err != nil {
+		return state, false, err
+	}
+	return state, true, nil
+}
+
+// writeBookBulkState changes a book from one state to another, rewriting
+// only the parts that differ. A link to a genre, series or author that no
+// longer exists is dropped rather than failing the book.
+func writeBookBulkState(tx *pg.Tx, bookID int64, from, to models.BookBulkState) error {
+	if from.Lang != to.Lang || from.Approved != to.Approved || from.DuplicateHidden != to.DuplicateHidden {
+		_, err := tx.Exec(`UPDATE opds_catalog_book SET lang = ?, approved = ?, duplicate_hidden = ? WHERE id = ?`,
+			to.Lang, to.Approved, to.DuplicateHidden, bookID)
+		if err != nil {
+			return err
+		}
+	}
+
+	if !sameIDs(from.GenreIDs, to.GenreIDs) {
+		if _, err := tx.Exec(`DELETE FROM opds_catalog_bgenre WHERE book_id = ?`, bookID); err != nil {
+			return err
+		}
+		if len(to.GenreIDs) > 0 {
+			_, err := tx.Exec(`
+				INSERT INTO opds_catalog_bgenre (book_id, genre_id)
+				SELECT ?, g.id FROM unnest(?::bigint[]) WITH ORDINALITY AS u(id, n)
+				JOIN opds_catalog_genre AS g ON g.id = u.id
+				ORDER BY u.n`, bookID, pg.Array(to.GenreIDs))
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if !sameIDs(from.AuthorIDs, to.AuthorIDs) {
+		if _, err := tx.Exec(`DELETE FROM opds_catalog_bauthor WHERE book_id = ?`, bookID); err != nil {
+			return err
+		}
+		if len(to.AuthorIDs) > 0 {
+			_, err := tx.Exec(`
+				INSERT INTO opds_catalog_bauthor (book_id, author_id)
+				SELECT ?, a.id FROM unnest(?::bigint[]) WITH ORDINALITY AS u(id, n)
+				JOIN opds_catalog_author AS a ON a.id = u.id
+				ORDER BY u.n`, bookID, pg.Array(to.AuthorIDs))
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if !sameSeries(from.Series, to.Series) {
+		if _, err := tx.Exec(`DELETE FROM opds_catalog_bseries WHERE book_id = ?`, bookID); err != nil {
+			return err
+		}
+		for _, s := range to.Series {
+			_, err := tx.Exec(`
+				INSERT INTO opds_catalog_bseries (book_id, ser_id, ser_no)
+				SELECT ?, s.id, ? FROM opds_catalog_series AS s WHERE s.id = ?`, bookID, s.SerNo, s.ID)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func sameIDs(a, b []int64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func sameSeries(a, b []models.BookSeriesRef) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// UpdateBookBulkEditProgress stores the edit's counters.
+func UpdateBookBulkEditProgress(ctx context.Context, edit *models.BookBulkEdit) error {
+	_, err := db.ModelContext(ctx, edit).
+		Column("processed", "changed", "failed").
+		WherePK().
+		Update()
+	return err
+}
+
+// FinishBookBulkEdit stores how the edit ended, with its final counters.
+func FinishBookBulkEdit(ctx context.Context, edit *models.BookBulkEdit, status, errMsg string) error {
+	now := time.Now()
+	edit.Status = status
+	edit.Error = errMsg
+	edit.FinishedAt = &now
+	_, err := db.ModelContext(ctx, edit).
+		Column("status", "error", "finished_at", "processed", "changed", "failed").
+		WherePK().
+		Update()
+	return err
+}
+
+// GetBookBulkEdit returns one bulk edit.
+func GetBookBulkEdit(ctx context.Context, id int64) (*models.BookBulkEdit, error) {
+	edit := &models.BookBulkEdit{ID: id}
+	err := db.ModelContext(ctx, edit).WherePK().Select()
+	if errors.Is(err, pg.ErrNoRows) {
+		return nil, ErrBulkEditNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return edit, nil
+}
+
+// ListBookBulkEdits returns one page of bulk edits, newest first, and how
+// many there are in all. The selections are left out: one can list tens of
+// thousands of ids, and GetBookBulkEdit has it.
+func ListBookBulkEdits(ctx context.Context, page, pageSize int) ([]models.BookBulkEdit, int, error) {
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 200 {
+		pageSize = 50
+	}
+	edits := []models.BookBulkEdit{}
+	total, err := db.ModelContext(ctx, &edits).
+		ExcludeColumn("selection").
+		Order("id DESC").
+		Limit(pageSize).
+		Offset((page - 1) * pageSize).
+		SelectAndCount()
+	if err != nil {
+		return nil, 0, err
+	}
+	return edits, total, nil
+}
+
+// StartBookBulkEditUndo marks an ended edit as being undone and returns it
+// with the undo records to replay. An undo that was itself interrupted can
+// be started again: putting a book back twice puts it back once.
+func StartBookBulkEditUndo(ctx context.Context, id int64) (*models.BookBulkEdit, []models.BookBulkEditBook, error) {
+	edit := &models.BookBulkEdit{ID: id}
+	var records []models.BookBulkEditBook
+	err := db.RunInTransaction(ctx, func(tx *pg.Tx) error {
+		if err := ensureNoActiveBulkEdit(tx, id); err != nil {
+			return err
+		}
+		err := tx.Model(edit).WherePK().For("UPDATE").Select()
+		if errors.Is(err, pg.ErrNoRows) {
+			return ErrBulkEditNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if edit.Active() || edit.Status == models.BulkEditUndone {
+			return ErrBulkEditNotUndoable
+		}
+		if err := tx.Model(&records).Where("edit_id = ?", id).Order("book_id ASC").Select(); err != nil {
+			return err
+		}
+		edit.Status = models.BulkEditUndoing
+		_, err = tx.Model(edit).Column("status").WherePK().Update()
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return edit, records, nil
+}
+
+// UndoBookBulkEditBook puts back what the edit changed in one book. Fields
+// the patch did not touch keep whatever they hold now; a book deleted since
+// is skipped.
+func UndoBookBulkEditBook(ctx context.Context, edit *models.BookBulkEdit, record *models.BookBulkEditBook) error {
+	return db.RunInTransaction(ctx, func(tx *pg.Tx) error {
+		current, found, err := loadBookBulkState(tx, record.BookID)
+		if err != nil || !found {
+			return err
+		}
+		return writeBookBulkState(tx, record.BookID, current, edit.Patch.Restore(current, record.Before))
+	})
+}
+
+// FinishBookBulkEditUndo marks the edit undone.
+func FinishBookBulkEditUndo(ctx context.Context, edit *models.BookBulkEdit, undoneBy *int64) error {
+	now := time.Now()
+	edit.Status = models.BulkEditUndone
+	edit.UndoneAt = &now
+	edit.UndoneBy = undoneBy
+	_, err := db.ModelContext(ctx, edit).
+		Column("status", "undone_at", "undone_by").
+		WherePK().
+		Update()
+	return err
+}
+
+// InterruptBookBulkEdits marks the edits a previous run of the server left
+// running or undoing as interrupted, so they do not block new ones and can
+// be undone. It returns how many there were.
+func InterruptBookBulkEdits(ctx context.Context) (int, error) {
+	res, err := db.ModelContext(ctx, (*models.BookBulkEdit)(nil)).
+		Set("status = ?", models.BulkEditInterrupted).
+		Set("finished_at = COALESCE(finished_at, now())").
+		Where("status IN (?, ?)", models.BulkEditRunning, models.BulkEditUndoing).
+		Update()
+	if err != nil {
+		return 0, fmt.Errorf("interrupting bulk edits: %w", err)
+	}
+	return res.RowsAffected(), nil
+}
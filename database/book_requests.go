@@ -0,0 +1,220 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gopds-api/models"
+
+	"github.com/go-pg/pg/v10"
+)
+
+var (
+	// ErrBookRequestNotFound reports a request that does not exist, or a
+	// vote the user did not cast.
+	ErrBookRequestNotFound = errors.New("book request not found")
+	// ErrBookRequestClosed reports a request that was already fulfilled
+	// or dismissed.
+	ErrBookRequestClosed = errors.New("book request is closed")
+)
+
+// SubmitBookRequest stores a user's request and their vote for it. When an
+// open request for the same normalised author and title exists, the user
+// seconds it instead; created reports which of the two happened.
+func SubmitBookRequest(ctx context.Context, userID int64, req *models.BookRequest) (id int64, created bool, err error) {
+	err = db.RunInTransaction(ctx, func(tx *pg.Tx) error {
+		_, err := tx.QueryOneContext(ctx, pg.Scan(&id), `
+			INSERT INTO book_requests (user_id, title, author, isbn, title_norm, author_norm)
+			VALUES (?, ?, ?, ?, ?, ?)
+			ON CONFLICT (author_norm, title_norm) WHERE status = 'open' DO NOTHING
+			RETURNING id`,
+			userID, req.Title, req.Author, req.ISBN, req.TitleNorm, req.AuthorNorm)
+		switch {
+		case errors.Is(err, pg.ErrNoRows):
+			if _, err := tx.QueryOneContext(ctx, pg.Scan(&id), `
+				SELECT id FROM book_requests
+				WHERE author_norm = ? AND title_norm = ? AND status = 'open'`,
+				req.AuthorNorm, req.TitleNorm); err != nil {
+				return err
+			}
+		case err != nil:
+			return err
+		default:
+			created = true
+		}
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO book_request_votes (request_id, user_id) VALUES (?, ?)
+			ON CONFLICT DO NOTHING`, id, userID)
+		return err
+	})
+	if err != nil {
+		return 0, false, err
+	}
+	return id, created, nil
+}
+
+// bookRequestsSQL selects requests as lists show them; ?0 is the user
+// looking, and callers add the conditions, order and page.
+const bookRequestsSQL = `
+SELECT r.id, r.title, r.author, r.isbn, r.status, r.book_id, r.created_at, r.closed_at,
+	coalesce(u.username, '') AS username,
+	(SELECT count(*) FROM book_request_votes v WHERE v.request_id = r.id) AS votes,
+	EXISTS (SELECT 1 FROM book_request_votes v WHERE v.request_id = r.id AND v.user_id = ?0) AS voted,
+	count(*) OVER () AS total
+FROM book_requests r
+LEFT JOIN auth_user u ON u.id = r.user_id
+`
+
+// bookRequestRow is a request and the size of the list it was read from.
+type bookRequestRow struct {
+	models.BookRequestView
+	Total int
+}
+
+// ListBookRequests returns a page of requests in a state and how many
+// there are. Open requests come most asked for first, then oldest first;
+// closed ones most recently closed first.
+func ListBookRequests(ctx context.Context, filter models.BookRequestFilter, limit, offset int) ([]models.BookRequestView, int, error) {
+	query := bookRequestsSQL + "WHERE r.status = ?1"
+	if filter.Mine {
+		query += " AND EXISTS (SELECT 1 FROM book_request_votes v WHERE v.request_id = r.id AND v.user_id = ?0)"
+	}
+	if filter.Status == models.BookRequestOpen {
+		query += " ORDER BY votes DESC, r.created_at ASC, r.id ASC"
+	} else {
+		query += " ORDER BY r.closed_at DESC, r.id DESC"
+	}
+	query += " LIMIT ?2 OFFSET ?3"
+
+	var rows []bookRequestRow
+	if _, err := db.QueryContext(ctx, &rows, query, filter.ViewerID, filter.Status, limit, offset); err != nil {
+		return nil, 0, err
+	}
+	requests := make([]models.BookRequestView, 0, len(rows))
+	total := 0
+	for _, row := range rows {
+		requests = append(requests, row.BookRequestView)
+		total = row.Total
+	}
+	return requests, total, nil
+}
+
+// BookRequestByID returns a request as lists show it, to the user looking.
+func BookRequestByID(ctx context.Context, id, viewerID int64) (*models.BookRequestView, error) {
+	var row bookRequestRow
+	_, err := db.QueryOneContext(ctx, &row, bookRequestsSQL+"WHERE r.id = ?1", viewerID, id)
+	if errors.Is(err, pg.ErrNoRows) {
+		return nil, ErrBookRequestNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &row.BookRequestView, nil
+}
+
+// VoteBookRequest adds a user's vote to an open request. Voting twice is
+// voting once.
+func VoteBookRequest(ctx context.Context, id, userID int64) error {
+	res, err := db.ExecContext(ctx, `
+		INSERT INTO book_request_votes (request_id, user_id)
+		SELECT id, ? FROM book_requests WHERE id = ? AND status = 'open'
+		ON CONFLICT DO NOTHING`, userID, id)
+	if err != nil {
+		return err
+	}
+	if res.RowsAffected() > 0 {
+		return nil
+	}
+	request := &models.BookRequest{}
+	if err := db.ModelContext(ctx, request).Column("status").Where("id = ?", id).Select(); err != nil {
+		if errors.Is(err, pg.ErrNoRows) {
+			return ErrBookRequestNotFound
+		}
+		return err
+	}
+	if request.Status != models.BookRequestOpen {
+		return ErrBookRequestClosed
+	}
+	return nil
+}
+
+// UnvoteBookRequest takes back a user's vote. The request stays, even
+// without votes, until a book or a moderator closes it.
+func UnvoteBookRequest(ctx context.Context, id, userID int64) error {
+	res, err := db.ExecContext(ctx, `
+		DELETE FROM book_request_votes WHERE request_id = ? AND user_id = ?`, id, userID)
+	if err != nil {
+		return err
+	}
+	if res.RowsAffected() == 0 {
+		return ErrBookRequestNotFound
+	}
+	return nil
+}
+
+// OpenBookRequests returns every open request, oldest first.
+func OpenBookRequests(ctx context.Context) ([]models.BookRequest, error) {
+	var requests []models.BookRequest
+	err := db.ModelContext(ctx, &requests).
+		Where("status = ?", models.BookRequestOpen).
+		Order("created_at ASC", "id ASC").
+		Select()
+	return requests, err
+}
+
+// FulfilBookRequest closes an open request with the book that arrived for
+// it, and returns what its voters are to be told.
+func FulfilBookRequest(ctx context.Context, id, bookID int64) (*models.FulfilledBookRequest, error) {
+	fulfilled := &models.FulfilledBookRequest{RequestID: id, BookID: bookID}
+	err := db.RunInTransaction(ctx, func(tx *pg.Tx) error {
+		_, err := tx.QueryOneContext(ctx, pg.Scan(&fulfilled.Title, &fulfilled.Author), `
+			UPDATE book_requests SET status = ?, book_id = ?, closed_at = ?
+			WHERE id = ? AND status = ?
+			RETURNING title, author`,
+			models.BookRequestFulfilled, bookID, time.Now(), id, models.BookRequestOpen)
+		if errors.Is(err, pg.ErrNoRows) {
+			return ErrBookRequestClosed
+		}
+		if err != nil {
+			return err
+		}
+		if _, err := tx.QueryOneContext(ctx, pg.Scan(&fulfilled.BookTitle),
+			`SELECT title FROM opds_catalog_book WHERE id = ?`, bookID); err != nil {
+			return err
+		}
+		_, err = tx.QueryContext(ctx, &fulfilled.VoterIDs, `
+			SELECT user_id FROM book_request_votes WHERE request_id = ? ORDER BY created_at`, id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return fulfilled, nil
+}
+
+// DismissBookRequest closes an open request no book is coming for. It
+// returns the request as it was before and as it is now.
+func DismissBookRequest(ctx context.Context, id int64) (before, after *models.BookRequest, err error) {
+	err = db.RunInTransaction(ctx, func(tx *pg.Tx) error {
+		before = &models.BookRequest{}
+		if err := tx.ModelContext(ctx, before).Where("id = ?", id).For("UPDATE").Select(); err != nil {
+			if errors.Is(err, pg.ErrNoRows) {
+				return ErrBookRequestNotFound
+			}
+			return err
+		}
+		if before.Status != models.BookRequestOpen {
+			return ErrBookRequestClosed
+		}
+		after = &models.BookRequest{}
+		_, err := tx.QueryOneContext(ctx, after, `
+			UPDATE book_requests SET status = ?, closed_at = ? WHERE id = ? RETURNING *`,
+			models.BookRequestDismissed, time.Now(), id)
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return before, after, nil
+}
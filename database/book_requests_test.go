@@ -0,0 +1,121 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"gopds-api/models"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestBookRequestLifecycle asks for a book twice, seconds and unseconds
+// it, fulfils it and checks that a closed request takes no votes and that
+// the next request for the same book is a new one.
+func TestBookRequestLifecycle(t *testing.T) {
+	requireDatabase(t)
+
+	var bookID int64
+	if _, err := db.QueryOne(pg.Scan(&bookID),
+		`SELECT id FROM opds_catalog_book WHERE approved AND NOT duplicate_hidden LIMIT 1`); err != nil {
+		t.Skip("no visible book to fulfil a request with")
+	}
+	stamp := time.Now().UnixNano()
+	asker := makeUser(t, fmt.Sprintf("request-asker-%d", stamp))
+	seconder := makeUser(t, fmt.Sprintf("request-seconder-%d", stamp))
+	titleNorm := fmt.Sprintf("wanted book %d", stamp)
+	t.Cleanup(func() {
+		_, _ = db.Exec(`DELETE FROM book_requests WHERE title_norm = ?`, titleNorm)
+		_, _ = db.Exec(`DELETE FROM auth_user WHERE id IN (?, ?)`, asker, seconder)
+	})
+	ctx := context.Background()
+	request := &models.BookRequest{Title: "Wanted Book", TitleNorm: titleNorm}
+
+	id, created, err := SubmitBookRequest(ctx, asker, request)
+	require.NoError(t, err)
+	assert.True(t, created)
+	again, created, err := SubmitBookRequest(ctx, seconder, request)
+	require.NoError(t, err)
+	assert.False(t, created, "asking for an open request seconds it")
+	assert.Equal(t, id, again)
+
+	require.NoError(t, VoteBookRequest(ctx, id, seconder), "voting twice is voting once")
+	view, err := BookRequestByID(ctx, id, seconder)
+	require.NoError(t, err)
+	assert.Equal(t, 2, view.Votes)
+	assert.True(t, view.Voted)
+	assert.Equal(t, fmt.Sprintf("request-asker-%d", stamp), view.Username)
+
+	mine, _, err := ListBookRequests(ctx, models.BookRequestFilter{Status: models.BookRequestOpen, ViewerID: seconder, Mine: true}, 100, 0)
+	require.NoError(t, err)
+	assert.Contains(t, requestIDs(mine), id)
+
+	require.NoError(t, UnvoteBookRequest(ctx, id, seconder))
+	assert.ErrorIs(t, UnvoteBookRequest(ctx, id, seconder), ErrBookRequestNotFound)
+	require.NoError(t, VoteBookRequest(ctx, id, seconder))
+
+	fulfilled, err := FulfilBookRequest(ctx, id, bookID)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []int64{asker, seconder}, fulfilled.VoterIDs)
+	assert.NotEmpty(t, fulfilled.BookTitle)
+	_, err = FulfilBookRequest(ctx, id, bookID)
+	assert.ErrorIs(t, err, ErrBookRequestClosed, "a request is fulfilled once")
+	assert.ErrorIs(t, VoteBookRequest(ctx, id, asker), ErrBookRequestClosed)
+	_, _, err = DismissBookRequest(ctx, id)
+	assert.ErrorIs(t, err, ErrBookRequestClosed)
+
+	open, err := OpenBookRequests(ctx)
+	require.NoError(t, err)
+	for _, r := range open {
+		assert.NotEqual(t, id, r.ID, "a fulfilled request is no longer open")
+	}
+
+	next, created, err := SubmitBookRequest(ctx, asker, request)
+	require.NoError(t, err)
+	assert.True(t, created, "a closed request does not hold the book's place")
+	assert.NotEqual(t, id, next)
+	_, after, err := DismissBookRequest(ctx, next)
+	require.NoError(t, err)
+	assert.Equal(t, models.BookRequestDismissed, after.Status)
+}
+
+func requestIDs(requests []models.BookRequestView) []int64 {
+	ids := make([]int64, 0, len(requests))
+	for _, r := range requests {
+		ids = append(ids, r.ID)
+	}
+	return ids
+}
+
+// A book readers cannot see is no candidate for a request: announcing it
+// would send readers to a download that refuses them.
+func TestFindVisibleBookCandidates(t *testing.T) {
+	withSearchFixture(t, func(f *searchFixture) {
+		visible := f.Book("requestVisible", &fixtureBook{Title: "Запрошенная книга", Approved: true})
+		pending := f.Book("requestPending", &fixtureBook{Title: "Запрошенная книга"})
+		hidden := f.Book("requestHidden", &fixtureBook{Title: "Запрошенная книга", Approved: true, Hidden: true})
+		ctx := context.Background()
+
+		all, err := findCandidates(ctx, f.tx, "", "запрошенная книга", false)
+		require.NoError(t, err)
+		assert.Subset(t, candidateIDs(all), []int64{visible, pending, hidden})
+
+		seen, err := findCandidates(ctx, f.tx, "", "запрошенная книга", true)
+		require.NoError(t, err)
+		assert.Contains(t, candidateIDs(seen), visible)
+		assert.NotContains(t, candidateIDs(seen), pending)
+		assert.NotContains(t, candidateIDs(seen), hidden)
+	})
+}
+
+func candidateIDs(candidates []models.MatchCandidate) []int64 {
+	ids := make([]int64, len(candidates))
+	for i, c := range candidates {
+		ids[i] = c.BookID
+	}
+	return ids
+}
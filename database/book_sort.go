@@ -0,0 +1,91 @@
+package database
+
+import (
+	"context"
+	"fmt"
+
+	"gopds-api/models"
+
+	"github.com/go-pg/pg/v10/orm"
+)
+
+// publicationYearSQL extracts the publication year from a book row's
+// docdate: the first four digits of a free-text date, NULL when there are
+// none. %[1]s is the row.
+const publicationYearSQL = `substring(%[1]s.docdate from '\d{4}')::int`
+
+// bookSortSQL is the ORDER BY key of a list sort over a book row, empty for
+// no sort; callers add their own tiebreak. Books without what a sort looks
+// at — no author, no series number, no year — go last in either direction.
+// seriesID, when set, numbers books within that series rather
+// than by the lowest number they carry in any.
+func bookSortSQL(row string, sort models.BookSort, seriesID int64) string {
+	var expr string
+	desc := false
+	switch sort.Key {
+	case models.SortTitle:
+		expr = fmt.Sprintf("lower(%s.title)", row)
+	case models.SortAuthor:
+		// full_name is "Surname Name", so it sorts by surname.
+		expr = fmt.Sprintf(`(SELECT min(a.full_name) FROM opds_catalog_bauthor ba
+			JOIN opds_catalog_author a ON a.id = ba.author_id
+			WHERE ba.book_id = %s.id)`, row)
+	case models.SortSeries:
+		scope := ""
+		if seriesID != 0 {
+			scope = fmt.Sprintf(" AND bs.ser_id = %d", seriesID)
+		}
+		expr = fmt.Sprintf(`(SELECT min(bs.ser_no) FROM opds_catalog_bseries bs
+			WHERE bs.book_id = %s.id%s)`, row, scope)
+	case models.SortAdded:
+		expr, desc = fmt.Sprintf("%s.registerdate", row), true
+	case models.SortYear:
+		expr, desc = fmt.Sprintf(publicationYearSQL, row), true
+	case models.SortPopular:
+		expr = fmt.Sprintf(`((SELECT count(*) FROM favorite_books fbs WHERE fbs.book_id = %[1]s.id)
+			+ %[1]s.downloads)`, row)
+		desc = true
+	case models.SortRating:
+		// NULL for a book nobody rated, so it goes last either way.
+		expr = fmt.Sprintf("(SELECT avg(br.rating) FROM book_ratings br WHERE br.book_id = %s.id)", row)
+		desc = true
+	default:
+		return ""
+	}
+	if sort.Reverse {
+		desc = !desc
+	}
+	dir := "ASC"
+	if desc {
+		dir = "DESC"
+	}
+	return fmt.Sprintf("%s %s NULLS LAST", expr, dir)
+}
+
+// applyListDates narrows a list to books added since a day and published
+// within a year range.
+func applyListDates(query *orm.Query, opts models.BookListOptions) *orm.Query {
+	if !opts.AddedSince.IsZero() {
+		query = query.Where("book.registerdate >= ?", opts.AddedSince)
+	}
+	if opts.YearFrom > 0 {
+		query = query.Where(fmt.Sprintf(publicationYearSQL, "book")+" >= ?", opts.YearFrom)
+	}
+	if opts.YearTo > 0 {
+		query = query.Where(fmt.Sprintf(publicationYearSQL, "book")+" <= ?", opts.YearTo)
+	}
+	return query
+}
+
+// RecordBookDownload counts a download of a book towards its popularity and,
+// when a user is known, adds it to their download history.
+func RecordBookDownload(ctx context.Context, userID, bookID int64) error {
+	if _, err := db.ExecContext(ctx, "UPDATE opds_catalog_book SET downloads = downloads + 1 WHERE id = ?", bookID); err != nil {
+		return err
+	}
+	if userID == 0 {
+		return nil
+	}
+	_, err := db.ExecContext(ctx, "INSERT INTO download_history (user_id, book_id) VALUES (?, ?)", userID, bookID)
+	return err
+}
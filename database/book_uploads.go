@@ -0,0 +1,238 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"time"
+
+	"gopds-api/models"
+
+	"github.com/go-pg/pg/v10"
+)
+
+var (
+	// ErrBookUploadNotFound reports an upload that does not exist.
+	ErrBookUploadNotFound = errors.New("upload not found")
+	// ErrBookUploadClosed reports an upload a moderator already decided on.
+	ErrBookUploadClosed = errors.New("upload is already moderated")
+	// ErrBookUploadBookGone reports a pending upload whose book was deleted
+	// in the meantime, which leaves nothing to approve.
+	ErrBookUploadBookGone = errors.New("uploaded book no longer exists")
+)
+
+// BookIDByMD5 returns a book of the catalogue, approved or not, made from
+// the file with the given MD5; zero when there is none.
+func BookIDByMD5(ctx context.Context, md5 string) (int64, error) {
+	var id int64
+	_, err := db.QueryOneContext(ctx, pg.Scan(&id),
+		`SELECT id FROM opds_catalog_book WHERE md5 = ? ORDER BY id LIMIT 1`, md5)
+	if errors.Is(err, pg.ErrNoRows) {
+		return 0, nil
+	}
+	return id, err
+}
+
+// CountUserUploads returns how many books a user uploaded since the given
+// time, and how many of their uploads wait for a moderator.
+func CountUserUploads(ctx context.Context, userID int64, since time.Time) (recent, pending int, err error) {
+	return countUserUploads(ctx, db, userID, since)
+}
+
+// LockUserUploads takes a transaction-scoped advisory lock on a user's
+// uploads and counts them under it, as CountUserUploads does. Two uploads
+// of one user are then counted and inserted one after the other, so that
+// parallel requests cannot all pass the quota.
+func LockUserUploads(ctx context.Context, tx *pg.Tx, userID int64, since time.Time) (recent, pending int, err error) {
+	h := fnv.New64a()
+	_, _ = fmt.Fprintf(h, "book_upload\x00%d", userID)
+	key := int64(h.Sum64()) // #nosec G115 -- a lock key, any 64 bits will do
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(?)`, key); err != nil {
+		return 0, 0, err
+	}
+	return countUserUploads(ctx, tx, userID, since)
+}
+
+func countUserUploads(ctx context.Context, dbh pg.DBI, userID int64, since time.Time) (recent, pending int, err error) {
+	_, err = dbh.QueryOneContext(ctx, pg.Scan(&recent, &pending), `
+		SELECT count(*) FILTER (WHERE created_at >= ?),
+			count(*) FILTER (WHERE status = ?)
+		FROM book_uploads WHERE user_id = ?`, since, models.UploadPending, userID)
+	return recent, pending, err
+}
+
+// CreateBookUpload records an upload in the transaction that inserted its
+// book.
+func CreateBookUpload(tx *pg.Tx, upload *models.BookUpload) error {
+	_, err := tx.Model(upload).Returning("*").Insert()
+	return err
+}
+
+// bookUploadsSQL selects uploads as lists show them; callers add the
+// conditions, order and page.
+const bookUploadsSQL = `
+SELECT bu.*, coalesce(u.username, '') AS username, count(*) OVER () AS total
+FROM book_uploads bu
+LEFT JOIN auth_user u ON u.id = bu.user_id
+`
+
+// bookUploadRow is an upload and the size of the list it was read from.
+type bookUploadRow struct {
+	models.BookUploadView
+	Total int
+}
+
+// ListBookUploads returns a page of uploads and how many there are, with
+// the books of those not rejected. A reader's own come newest first; the
+// pending queue oldest first, so that it is worked in order; decided ones
+// most recently decided first.
+func ListBookUploads(ctx context.Context, filter models.BookUploadFilter, limit, offset int) ([]models.BookUploadView, int, error) {
+	query := bookUploadsSQL + "WHERE (?0 = '' OR bu.status = ?0) AND (?1 = 0 OR bu.user_id = ?1)"
+	switch {
+	case filter.UserID != 0:
+		query += " ORDER BY bu.created_at DESC, bu.id DESC"
+	case filter.Status == models.UploadPending:
+		query += " ORDER BY bu.created_at ASC, bu.id ASC"
+	default:
+		query += " ORDER BY bu.moderated_at DESC NULLS FIRST, bu.id DESC"
+	}
+	query += " LIMIT ?2 OFFSET ?3"
+
+	var rows []bookUploadRow
+	if _, err := db.QueryContext(ctx, &rows, query, filter.Status, filter.UserID, limit, offset); err != nil {
+		return nil, 0, err
+	}
+	uploads := make([]models.BookUploadView, 0, len(rows))
+	total := 0
+	for _, row := range rows {
+		uploads = append(uploads, row.BookUploadView)
+		total = row.Total
+	}
+	if err := attachUploadBooks(ctx, uploads); err != nil {
+		return nil, 0, err
+	}
+	return uploads, total, nil
+}
+
+// attachUploadBooks loads the books of the uploads, with their authors,
+// series and genres, as the admin editor shows them.
+func attachUploadBooks(ctx context.Context, uploads []models.BookUploadView) error {
+	var ids []int64
+	for _, u := range uploads {
+		if u.BookID != nil {
+			ids = append(ids, *u.BookID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	var books []models.Book
+	if err := db.ModelContext(ctx, &books).
+		Where("book.id IN (?)", pg.In(ids)).
+		Relation("Authors").
+		Relation("Series").
+		Relation("Genres").
+		Select(); err != nil {
+		return err
+	}
+	populateSeriesNumbers(books)
+	byID := make(map[int64]*models.Book, len(books))
+	for i := range books {
+		byID[books[i].ID] = &books[i]
+	}
+	for i := range uploads {
+		if uploads[i].BookID != nil {
+			uploads[i].Book = byID[*uploads[i].BookID]
+		}
+	}
+	return nil
+}
+
+// ApproveBookUpload approves a pending upload and its book, which readers
+// see from then on. It returns the upload as it was before and as it is
+// now.
+func ApproveBookUpload(ctx context.Context, id int64, moderatorID *int64) (before, after *models.BookUpload, err error) {
+	err = db.RunInTransaction(ctx, func(tx *pg.Tx) error {
+		if before, err = lockPendingUpload(ctx, tx, id); err != nil {
+			return err
+		}
+		if before.BookID == nil {
+			return ErrBookUploadBookGone
+		}
+		if _, err := tx.ExecContext(ctx, `UPDATE opds_catalog_book SET approved = true WHERE id = ?`, *before.BookID); err != nil {
+			return err
+		}
+		after = &models.BookUpload{}
+		_, err := tx.QueryOneContext(ctx, after, `
+			UPDATE book_uploads SET status = ?, moderated_by = ?, moderated_at = ?
+			WHERE id = ? RETURNING *`, models.UploadApproved, moderatorID, time.Now(), id)
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return before, after, nil
+}
+
+// RejectBookUpload rejects a pending upload and deletes its book; the
+// upload keeps its title and the reason. It returns the upload as it was
+// before and as it is now. Taking the file out of its inbox archive is the
+// caller's part.
+func RejectBookUpload(ctx context.Context, id int64, reason string, moderatorID *int64) (before, after *models.BookUpload, err error) {
+	err = db.RunInTransaction(ctx, func(tx *pg.Tx) error {
+		if before, err = lockPendingUpload(ctx, tx, id); err != nil {
+			return err
+		}
+		if before.BookID != nil {
+			if err := deleteUploadedBook(ctx, tx, *before.BookID); err != nil {
+				return err
+			}
+		}
+		after = &models.BookUpload{}
+		_, err := tx.QueryOneContext(ctx, after, `
+			UPDATE book_uploads SET status = ?, reason = ?, book_id = NULL, moderated_by = ?, moderated_at = ?
+			WHERE id = ? RETURNING *`, models.UploadRejected, reason, moderatorID, time.Now(), id)
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return before, after, nil
+}
+
+// lockPendingUpload reads an upload for a moderator's decision, holding it
+// until the transaction ends.
+func lockPendingUpload(ctx context.Context, tx *pg.Tx, id int64) (*models.BookUpload, error) {
+	upload := &models.BookUpload{}
+	err := tx.ModelContext(ctx, upload).Where("id = ?", id).For("UPDATE").Select()
+	switch {
+	case errors.Is(err, pg.ErrNoRows):
+		return nil, ErrBookUploadNotFound
+	case err != nil:
+		return nil, err
+	case upload.Status != models.UploadPending:
+		return nil, ErrBookUploadClosed
+	}
+	return upload, nil
+}
+
+// deleteUploadedBook deletes a book readers never saw, with the links the
+// scan gave it and whatever staff attached to it since. Ratings,
+// downloads and the like go with the book by their foreign keys.
+func deleteUploadedBook(ctx context.Context, tx *pg.Tx, bookID int64) error {
+	for _, query := range []string{
+		`DELETE FROM favorite_books WHERE book_id = ?`,
+		`DELETE FROM book_collection_books WHERE book_id = ?`,
+		`DELETE FROM covers WHERE book_id = ?`,
+		`DELETE FROM opds_catalog_bauthor WHERE book_id = ?`,
+		`DELETE FROM opds_catalog_bseries WHERE book_id = ?`,
+		`DELETE FROM opds_catalog_bgenre WHERE book_id = ?`,
+		`DELETE FROM opds_catalog_book WHERE id = ?`,
+	} {
+		if _, err := tx.ExecContext(ctx, query, bookID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"gopds-api/models"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestBookUploadLifecycle uploads two books, approves one and rejects the
+// other, and checks that the approved book is published, the rejected one
+// deleted, and that neither is decided twice.
+func TestBookUploadLifecycle(t *testing.T) {
+	requireDatabase(t)
+
+	stamp := time.Now().UnixNano()
+	uploader := makeUser(t, fmt.Sprintf("uploader-%d", stamp))
+	ctx := context.Background()
+	upload := func(name string) (*models.BookUpload, *models.Book) {
+		t.Helper()
+		book := &models.Book{
+			Path:         fmt.Sprintf("uploads/test-%d.zip", stamp),
+			Format:       "fb2",
+			FileName:     name,
+			RegisterDate: time.Now(),
+			Title:        "Uploaded " + name,
+			MD5:          fmt.Sprintf("%x", fmt.Sprintf("%s-%d", name, stamp))[:32],
+		}
+		u := &models.BookUpload{UserID: &uploader, FileName: name, Title: book.Title, MD5: book.MD5,
+			Archive: book.Path, Entry: name, Status: models.UploadPending}
+		require.NoError(t, db.RunInTransaction(ctx, func(tx *pg.Tx) error {
+			if _, err := tx.Model(book).Insert(); err != nil {
+				return err
+			}
+			u.BookID = &book.ID
+			return CreateBookUpload(tx, u)
+		}))
+		return u, book
+	}
+	kept, keptBook := upload("kept.fb2")
+	dropped, droppedBook := upload("dropped.fb2")
+	t.Cleanup(func() {
+		_, _ = db.Exec(`DELETE FROM book_uploads WHERE user_id = ?`, uploader)
+		_, _ = db.Exec(`DELETE FROM opds_catalog_book WHERE id IN (?, ?)`, keptBook.ID, droppedBook.ID)
+		_, _ = db.Exec(`DELETE FROM auth_user WHERE id = ?`, uploader)
+	})
+
+	found, err := BookIDByMD5(ctx, keptBook.MD5)
+	require.NoError(t, err)
+	assert.Equal(t, keptBook.ID, found, "an unapproved book is still the library's copy of its file")
+	recent, pending, err := CountUserUploads(ctx, uploader, time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, 2, recent)
+	assert.Equal(t, 2, pending)
+
+	mine, total, err := ListBookUploads(ctx, models.BookUploadFilter{UserID: uploader}, 10, 0)
+	require.NoError(t, err)
+	require.Equal(t, 2, total)
+	assert.Equal(t, dropped.ID, mine[0].ID, "a reader's newest upload comes first")
+	require.NotNil(t, mine[0].Book)
+	assert.Equal(t, droppedBook.Title, mine[0].Book.Title)
+	assert.Equal(t, fmt.Sprintf("uploader-%d", stamp), mine[0].Username)
+
+	_, after, err := ApproveBookUpload(ctx, kept.ID, &uploader)
+	require.NoError(t, err)
+	assert.Equal(t, models.UploadApproved, after.Status)
+	assert.True(t, readBook(t, keptBook.ID).Approved)
+
+	before, after, err := RejectBookUpload(ctx, dropped.ID, "a damaged copy", &uploader)
+	require.NoError(t, err)
+	assert.Equal(t, droppedBook.ID, *before.BookID)
+	assert.Nil(t, after.BookID)
+	assert.Equal(t, "a damaged copy", after.Reason)
+	found, err = BookIDByMD5(ctx, droppedBook.MD5)
+	require.NoError(t, err)
+	assert.Zero(t, found, "a rejected upload takes its book with it")
+
+	_, _, err = ApproveBookUpload(ctx, dropped.ID, nil)
+	assert.ErrorIs(t, err, ErrBookUploadClosed, "an upload is decided once")
+	_, _, err = RejectBookUpload(ctx, kept.ID, "", nil)
+	assert.ErrorIs(t, err, ErrBookUploadClosed)
+	_, _, err = ApproveBookUpload(ctx, -1, nil)
+	assert.ErrorIs(t, err, ErrBookUploadNotFound)
+
+	_, pending, err = CountUserUploads(ctx, uploader, time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+	assert.Zero(t, pending)
+}
+
+// TestLockUserUploads_CountsAfterTheOtherUpload holds a user's upload lock
+// in one transaction and checks that a second upload of theirs waits for it
+// and then counts the upload the first one inserted.
+func TestLockUserUploads_CountsAfterTheOtherUpload(t *testing.T) {
+	requireDatabase(t)
+
+	stamp := time.Now().UnixNano()
+	uploader := makeUser(t, fmt.Sprintf("locked-uploader-%d", stamp))
+	t.Cleanup(func() {
+		_, _ = db.Exec(`DELETE FROM book_uploads WHERE user_id = ?`, uploader)
+		_, _ = db.Exec(`DELETE FROM auth_user WHERE id = ?`, uploader)
+	})
+	ctx := context.Background()
+	since := time.Now().Add(-time.Hour)
+
+	first, err := db.BeginContext(ctx)
+	require.NoError(t, err)
+	defer func() { _ = first.Rollback() }()
+	recent, _, err := LockUserUploads(ctx, first, uploader, since)
+	require.NoError(t, err)
+	require.Zero(t, recent)
+
+	counted := make(chan int, 1)
+	go func() {
+		var n int
+		_ = db.RunInTransaction(ctx, func(tx *pg.Tx) error {
+			var err error
+			n, _, err = LockUserUploads(ctx, tx, uploader, since)
+			return err
+		})
+		counted <- n
+	}()
+	select {
+	case <-counted:
+		t.Fatal("the second upload counted while the first held the lock")
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	require.NoError(t, CreateBookUpload(first, &models.BookUpload{UserID: &uploader, FileName: "first.fb2",
+		MD5: fmt.Sprintf("%032d", stamp%1e9), Archive: "uploads/lock.zip", Entry: "first.fb2", Status: models.UploadPending}))
+	require.NoError(t, first.Commit())
+	assert.Equal(t, 1, <-counted, "the second upload counts the first")
+}
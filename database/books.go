@@ -35,11 +35,15 @@ func GetBooks(userID int64, filters models.BookFilters) ([]models.Book, int, err
 	if strings.TrimSpace(filters.Title) != "" {
 		return nil, 0, ErrTextSearchUnsupported
 	}
+	opts, err := filters.Options()
+	if err != nil {
+		return nil, 0, err
+	}
 
 	books := []models.Book{}
 	var userFavs []int64
 
-	err := db.Model(&models.UserToBook{}).Where("user_id = ?", userID).Select(&userFavs)
+	err = db.Model(&models.UserToBook{}).Where("user_id = ?", userID).Select(&userFavs)
 	if err != nil {
 		logging.Error(err)
 		return nil, 0, err
@@ -60,8 +64,14 @@ func GetBooks(userID int64, filters models.BookFilters) ([]models.Book, int, err
 	if err != nil {
 		return nil, 0, err
 	}
+	query = applyListDates(query, opts)
 
-	// Ordering follows the scope that was asked for.
+	// Ordering follows the sort that was asked for, else the scope's own.
+	sorted := opts.Sort.Key != ""
+	if sorted {
+		// The book id breaks ties, newest first, so pages stay stable.
+		query = query.OrderExpr(bookSortSQL("book", opts.Sort, int64(filters.Series)) + ", book.id DESC")
+	}
 	if filters.Fav {
 		var booksIds []models.UserToBook
 		err := db.Model(&booksIds).
@@ -75,21 +85,27 @@ func GetBooks(userID int64, filters models.BookFilters) ([]models.Book, int, err
 				bIds = append(bIds, bid.BookID)
 			}
 			query = query.WhereIn("book.id IN (?)", bIds)
-			query = query.OrderExpr(`
+			if !sorted {
+				query = query.OrderExpr(`
 				(SELECT row_number 
 				 FROM (SELECT book_id, ROW_NUMBER() OVER (ORDER BY id DESC) as row_number 
 				       FROM favorite_books 
 				       WHERE user_id = ?) favs 
 				 WHERE favs.book_id = book.id) ASC`, userID)
+			}
 		}
 	} else if filters.UsersFavorites {
 		query = query.Join("JOIN favorite_books fb ON fb.book_id = book.id").
-			Group("book.id").
-			OrderExpr("favorite_count DESC, book.id DESC")
+			Group("book.id")
+		if !sorted {
+			query = query.OrderExpr("favorite_count DESC, book.id DESC")
+		}
 	} else if filters.Collection != 0 {
 		query = query.Join("JOIN book_collection_books bcb ON bcb.book_id = book.id").
-			Where("bcb.book_collection_id = ?", filters.Collection).
-			Order("bcb.position ASC")
+			Where("bcb.book_collection_id = ?", filters.Collection)
+		if !sorted {
+			query = query.Order("bcb.position ASC")
+		}
 	} else if filters.CuratedCollection != 0 {
 		// Two-step approach: first pull the ordered list of book ids of the
 		// (published, curated) collection, then constrain the main book query
@@ -103,10 +119,11 @@ func GetBooks(userID int64, filters models.BookFilters) ([]models.Book, int, err
 		if len(ids) == 0 {
 			return []models.Book{}, 0, nil
 		}
-		query = query.
-			WhereIn("book.id IN (?)", ids).
-			OrderExpr("array_position(?, book.id)", pg.Array(ids))
-	} else {
+		query = query.WhereIn("book.id IN (?)", ids)
+		if !sorted {
+			query = query.OrderExpr("array_position(?, book.id)", pg.Array(ids))
+		}
+	} else if !sorted {
 		query = query.Order("book.id DESC")
 	}
 
@@ -121,6 +138,7 @@ func GetBooks(userID int64, filters models.BookFilters) ([]models.Book, int, err
 	}
 
 	populateSeriesNumbers(books)
+	populateRatingsWithDB(db, books)
 
 	return books, count, nil
 }
@@ -173,6 +191,13 @@ func applyListFilters(query *orm.Query, filters models.BookFilters, userID int64
 		}
 	}
 
+	if filters.GenreSection != 0 {
+		query = query.Where(`book.id IN (
+			SELECT bg.book_id FROM opds_catalog_bgenre bg
+			JOIN opds_catalog_genre g ON g.id = bg.genre_id
+			WHERE g.section_id = ?)`, filters.GenreSection)
+	}
+
 	if filters.CuratedCollection != 0 {
 		booksIds, err := collectionBookIDsOrdered(filters.CuratedCollection)
 		if err != nil {
@@ -361,6 +386,16 @@ func GetBook(bookID int64) (models.Book, error) {
 	return *book, nil
 }
 
+// GetSeries returns a series by id
+func GetSeries(seriesID int64) (models.Series, error) {
+	series := &models.Series{ID: seriesID}
+	err := db.Model(series).WherePK().Select()
+	if err != nil {
+		return *series, err
+	}
+	return *series, nil
+}
+
 // collectionBookIDsOrdered returns the resolved book ids of one published curated
 // collection in curator-defined order. Returns empty (not error) for missing /
 // unpublished / non-curated ids so callers don't leak drafts.
@@ -552,21 +587,26 @@ func UpdateBook(updateReq models.BookUpdateRequest) (models.Book, error) {
 	commit = true
 
 	// Retrieve the updated book with all relations
-	err = db.Model(&bookToUpdate).
-		Where("id = ?", updateReq.ID).
+	return GetBookWithRelations(updateReq.ID)
+}
+
+// GetBookWithRelations returns a book with its authors, series (numbered)
+// and genres: the book as the admin editor shows it.
+func GetBookWithRelations(bookID int64) (models.Book, error) {
+	var book models.Book
+	err := db.Model(&book).
+		Where("id = ?", bookID).
 		Relation("Authors").
 		Relation("Series").
 		Relation("Genres").
 		Select()
 	if err != nil {
-		return bookToUpdate, err
+		return book, err
 	}
 
-	books := []models.Book{bookToUpdate}
+	books := []models.Book{book}
 	populateSeriesNumbers(books)
-	bookToUpdate = books[0]
-
-	return bookToUpdate, nil
+	return books[0], nil
 }
 
 func updateBookAuthorsFromUpdateRequest(tx *pg.Tx, bookID int64, authors []models.Author) error {
@@ -603,7 +643,7 @@ func updateBookAuthorsFromUpdateRequest(tx *pg.Tx, bookID int64, authors []model
 				if fullName == "" {
 					continue
 				}
-				createdID, err := getOrCreateAuthorByName(tx, fullName)
+				createdID, err := GetOrCreateAuthor(tx, fullName)
 				if err != nil {
 					return err
 				}
@@ -614,7 +654,7 @@ func updateBookAuthorsFromUpdateRequest(tx *pg.Tx, bookID int64, authors []model
 			if _, ok := seenNames[normalized]; ok {
 				continue
 			}
-			createdID, err := getOrCreateAuthorByName(tx, fullName)
+			createdID, err := GetOrCreateAuthor(tx, fullName)
 			if err != nil {
 				return err
 			}
@@ -708,31 +748,6 @@ func updateBookSeriesFromUpdateRequest(tx *pg.Tx, bookID int64, series []models.
 	return nil
 }
 
-func getOrCreateAuthorByName(tx *pg.Tx, name string) (int64, error) {
-	name = strings.TrimSpace(name)
-	if name == "" {
-		return 0, errors.New("author name is empty")
-	}
-
-	existing := &models.Author{}
-	err := tx.Model(existing).
-		Where("full_name = ?", name).
-		Select(existing)
-	if err == nil {
-		return existing.ID, nil
-	}
-	if err != nil && err != pg.ErrNoRows {
-		return 0, err
-	}
-
-	author := &models.Author{FullName: name}
-	_, err = tx.Model(author).Insert()
-	if err != nil {
-		return 0, err
-	}
-	return author.ID, nil
-}
-
 func getOrCreateSeriesByName(tx *pg.Tx, name string) (int64, error) {
 	name = strings.TrimSpace(name)
 	if name == "" {
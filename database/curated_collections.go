@@ -481,6 +481,17 @@ func GetPublicCollectionBooksPage(ctx context.Context, collectionID int64, offse
 // The combined score = title_sim * 0.6 + author_sim * 0.4 still drives the
 // ordering, but we cap at 20 instead of 10 so the LLM has more to choose from.
 func FindCollectionCandidates(ctx context.Context, authorNorm, titleNorm string) ([]models.MatchCandidate, error) {
+	return findCandidates(ctx, db, authorNorm, titleNorm, false)
+}
+
+// FindVisibleBookCandidates is FindCollectionCandidates over the books
+// readers can see: approved, and not hidden as a duplicate. What a match
+// announces to readers must be a book they can download.
+func FindVisibleBookCandidates(ctx context.Context, authorNorm, titleNorm string) ([]models.MatchCandidate, error) {
+	return findCandidates(ctx, db, authorNorm, titleNorm, true)
+}
+
+func findCandidates(ctx context.Context, dbh pg.DBI, authorNorm, titleNorm string, visibleOnly bool) ([]models.MatchCandidate, error) {
 	if titleNorm == "" {
 		return nil, nil
 	}
@@ -499,8 +510,9 @@ func FindCollectionCandidates(ctx context.Context, authorNorm, titleNorm string)
 		            WHERE ba.book_id = b.id
 		        ), 0) * 0.4)::real AS score
 		FROM opds_catalog_book b
-		WHERE similarity(lower(b.title), ?) > ?
-		   OR lower(b.title) LIKE ?
+		WHERE (similarity(lower(b.title), ?) > ?
+		   OR lower(b.title) LIKE ?)
+		  AND (NOT ? OR (b.approved AND NOT b.duplicate_hidden))
 		ORDER BY score DESC
 		LIMIT 20
 	`
@@ -509,13 +521,14 @@ func FindCollectionCandidates(ctx context.Context, authorNorm, titleNorm string)
 		Score  float32 `pg:"score"`
 	}
 	var rows []row
-	_, err := db.QueryContext(ctx, &rows, query,
+	_, err := dbh.QueryContext(ctx, &rows, query,
 		titleNorm,       // similarity(lower(title), ?)
 		prefix,          // CASE WHEN lower(title) LIKE ? THEN 0.7 ELSE 0
 		authorNorm,      // similarity(lower(full_name), ?)
 		titleNorm,       // WHERE similarity > ?
 		trigramTitleMin, // threshold
 		prefix,          // OR lower(title) LIKE ?
+		visibleOnly,     // AND (NOT ? OR visible)
 	)
 	if err != nil {
 		return nil, err
@@ -0,0 +1,506 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"gopds-api/internal/genres"
+	"gopds-api/models"
+
+	"github.com/go-pg/pg/v10"
+)
+
+var (
+	// ErrGenreNotFound reports a genre id that does not exist.
+	ErrGenreNotFound = errors.New("genre not found")
+	// ErrGenreSectionNotFound reports a section id that does not exist.
+	ErrGenreSectionNotFound = errors.New("genre section not found")
+	// ErrGenreSectionTaken reports a section code already in use.
+	ErrGenreSectionTaken = errors.New("genre section code already exists")
+	// ErrGenreAliasOfItself reports an alias that is the tag of its own genre.
+	ErrGenreAliasOfItself = errors.New("alias is the genre's own tag")
+)
+
+// GenreNode is a genre of the taxonomy with the number of visible books
+// tagged with it. Name is the title in the language asked for.
+type GenreNode struct {
+	ID         int64  `json:"id"`
+	Genre      string `json:"genre"`
+	Title      string `json:"title"`
+	TitleEn    string `json:"title_en"`
+	Name       string `json:"name"`
+	SectionID  *int64 `json:"section_id"`
+	Position   int    `json:"position"`
+	BooksCount int    `json:"books_count"`
+}
+
+// GenreSectionNode is a section of the taxonomy with its genres. BooksCount
+// counts each book once, however many of the section's genres it has.
+type GenreSectionNode struct {
+	ID         int64       `json:"id"`
+	Code       string      `json:"code"`
+	Title      string      `json:"title"`
+	TitleEn    string      `json:"title_en"`
+	Name       string      `json:"name"`
+	Position   int         `json:"position"`
+	BooksCount int         `json:"books_count"`
+	Genres     []GenreNode `json:"genres"`
+}
+
+// GenreTree is the taxonomy as browsed: sections in order, and the genres no
+// section claims — tags scanning met that neither the tree nor an alias knows.
+type GenreTree struct {
+	Sections    []GenreSectionNode `json:"sections"`
+	Unsectioned []GenreNode        `json:"unsectioned"`
+}
+
+// GetGenreTree returns the taxonomy with names in lang. Unless withEmpty is
+// set, genres without visible books are left out, and so are sections left
+// with no genres.
+func GetGenreTree(ctx context.Context, lang string, withEmpty bool) (*GenreTree, error) {
+	var sections []models.GenreSection
+	if err := db.ModelContext(ctx, &sections).Order("position ASC", "id ASC").Select(); err != nil {
+		return nil, err
+	}
+
+	var rows []struct {
+		models.Genre
+		BooksCount int
+	}
+	_, err := db.QueryContext(ctx, &rows, `
+		SELECT g.id, g.genre, g.title, g.title_en, g.section_id, g.position,
+		       count(b.id) AS books_count
+		FROM opds_catalog_genre g
+		LEFT JOIN opds_catalog_bgenre bg ON bg.genre_id = g.id
+		LEFT JOIN opds_catalog_book b ON b.id = bg.book_id
+		     AND b.approved AND NOT b.duplicate_hidden
+		GROUP BY g.id
+		ORDER BY g.position ASC, g.genre ASC`)
+	if err != nil {
+		return nil, err
+	}
+
+	var sectionCounts []struct {
+		SectionID  int64
+		BooksCount int
+	}
+	_, err = db.QueryContext(ctx, &sectionCounts, `
+		SELECT g.section_id, count(DISTINCT b.id) AS books_count
+		FROM opds_catalog_genre g
+		JOIN opds_catalog_bgenre bg ON bg.genre_id = g.id
+		JOIN opds_catalog_book b ON b.id = bg.book_id
+		     AND b.approved AND NOT b.duplicate_hidden
+		WHERE g.section_id IS NOT NULL
+		GROUP BY g.section_id`)
+	if err != nil {
+		return nil, err
+	}
+	counts := make(map[int64]int, len(sectionCounts))
+	for _, sc := range sectionCounts {
+		counts[sc.SectionID] = sc.BooksCount
+	}
+
+	tree := &GenreTree{Sections: []GenreSectionNode{}, Unsectioned: []GenreNode{}}
+	index := make(map[int64]int, len(sections))
+	for _, s := range sections {
+		index[s.ID] = len(tree.Sections)
+		tree.Sections = append(tree.Sections, GenreSectionNode{
+			ID:         s.ID,
+			Code:       s.Code,
+			Title:      s.Title,
+			TitleEn:    s.TitleEn,
+			Name:       s.LocalizedName(lang),
+			Position:   s.Position,
+			BooksCount: counts[s.ID],
+			Genres:     []GenreNode{},
+		})
+	}
+	for _, r := range rows {
+		if r.BooksCount == 0 && !withEmpty {
+			continue
+		}
+		node := GenreNode{
+			ID:         r.ID,
+			Genre:      r.Genre.Genre,
+			Title:      r.Title,
+			TitleEn:    r.TitleEn,
+			Name:       r.LocalizedName(lang),
+			SectionID:  r.SectionID,
+			Position:   r.Position,
+			BooksCount: r.BooksCount,
+		}
+		if r.SectionID != nil {
+			if i, ok := index[*r.SectionID]; ok {
+				tree.Sections[i].Genres = append(tree.Sections[i].Genres, node)
+				continue
+			}
+		}
+		tree.Unsectioned = append(tree.Unsectioned, node)
+	}
+	if !withEmpty {
+		kept := tree.Sections[:0]
+		for _, s := range tree.Sections {
+			if len(s.Genres) > 0 {
+				kept = append(kept, s)
+			}
+		}
+		tree.Sections = kept
+	}
+	return tree, nil
+}
+
+// GetGenreSection returns one section with its genres, names in lang, empty
+// genres left out.
+func GetGenreSection(ctx context.Context, sectionID int64, lang string) (*GenreSectionNode, error) {
+	exists, err := db.ModelContext(ctx, (*models.GenreSection)(nil)).Where("id = ?", sectionID).Exists()
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrGenreSectionNotFound
+	}
+	tree, err := GetGenreTree(ctx, lang, true)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range tree.Sections {
+		if s.ID != sectionID {
+			continue
+		}
+		kept := s.Genres[:0]
+		for _, g := range s.Genres {
+			if g.BooksCount > 0 {
+				kept = append(kept, g)
+			}
+		}
+		s.Genres = kept
+		return &s, nil
+	}
+	return nil, ErrGenreSectionNotFound
+}
+
+// GetGenre returns one genre by id.
+func GetGenre(ctx context.Context, id int64) (*models.Genre, error) {
+	genre := &models.Genre{ID: id}
+	err := db.ModelContext(ctx, genre).WherePK().Select()
+	if errors.Is(err, pg.ErrNoRows) {
+		return nil, ErrGenreNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return genre, nil
+}
+
+// SeedGenreTaxonomy brings the built-in tree into a catalog that has no
+// sections yet: sections are created, the genres of the tree are created or
+// put in theirs, and names are filled in where there are none — a title that
+// is still the bare tag, an empty English one. Aliases are recorded, and books
+// already filed under an aliased tag move to the genre it means. A catalog
+// with sections is left alone: the tree is the admins' from then on. seeded
+// reports whether anything was done.
+func SeedGenreTaxonomy(ctx context.Context, sections []genres.Section, aliases map[string]string) (seeded bool, err error) {
+	err = db.RunInTransaction(ctx, func(tx *pg.Tx) error {
+		// Two instances starting together must not both seed.
+		if _, err := tx.Exec(`LOCK TABLE genre_sections IN EXCLUSIVE MODE`); err != nil {
+			return err
+		}
+		var exists bool
+		if _, err := tx.QueryOne(pg.Scan(&exists), `SELECT EXISTS (SELECT 1 FROM genre_sections)`); err != nil {
+			return err
+		}
+		if exists {
+			return nil
+		}
+		seeded = true
+
+		for i, s := range sections {
+			var sectionID int64
+			_, err := tx.QueryOne(pg.Scan(&sectionID), `
+				INSERT INTO genre_sections (code, title, title_en, position)
+				VALUES (?, ?, ?, ?)
+				RETURNING id`, s.Code, s.Title, s.TitleEn, i)
+			if err != nil {
+				return err
+			}
+			for j, g := range s.Genres {
+				_, err := tx.Exec(`
+					INSERT INTO opds_catalog_genre (genre, title, title_en, section_id, position)
+					VALUES (?, ?, ?, ?, ?)
+					ON CONFLICT (genre) DO UPDATE SET
+						title = CASE WHEN opds_catalog_genre.title IN ('', opds_catalog_genre.genre)
+						             THEN EXCLUDED.title ELSE opds_catalog_genre.title END,
+						title_en = CASE WHEN opds_catalog_genre.title_en = ''
+						                THEN EXCLUDED.title_en ELSE opds_catalog_genre.title_en END,
+						section_id = COALESCE(opds_catalog_genre.section_id, EXCLUDED.section_id)`,
+					g.Code, g.Title, g.TitleEn, sectionID, j)
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		for alias, code := range aliases {
+			var genreID int64
+			_, err := tx.QueryOne(pg.Scan(&genreID), `SELECT id FROM opds_catalog_genre WHERE genre = ?`, code)
+			if errors.Is(err, pg.ErrNoRows) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			res, err := tx.Exec(`
+				INSERT INTO genre_aliases (alias, genre_id) VALUES (?, ?)
+				ON CONFLICT (alias) DO NOTHING`, alias, genreID)
+			if err != nil {
+				return err
+			}
+			if res.RowsAffected() == 0 {
+				continue
+			}
+			if err := foldGenreTag(tx, alias, genreID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return seeded, err
+}
+
+// ResolveGenre returns the genre a tag stands for: the genre of its alias,
+// else the genre of that tag. found is false when neither exists.
+func ResolveGenre(tx *pg.Tx, tag string) (genreID int64, found bool, err error) {
+	_, err = tx.QueryOne(pg.Scan(&genreID), `SELECT genre_id FROM genre_aliases WHERE alias = ?`, tag)
+	if err == nil {
+		return genreID, true, nil
+	}
+	if !errors.Is(err, pg.ErrNoRows) {
+		return 0, false, err
+	}
+	_, err = tx.QueryOne(pg.Scan(&genreID), `SELECT id FROM opds_catalog_genre WHERE genre = ?`, tag)
+	if err == nil {
+		return genreID, true, nil
+	}
+	if errors.Is(err, pg.ErrNoRows) {
+		return 0, false, nil
+	}
+	return 0, false, err
+}
+
+// foldGenreTag moves the books of the genre row whose tag is alias, if there
+// is one, to the genre genreID, and drops that row.
+func foldGenreTag(tx *pg.Tx, alias string, genreID int64) error {
+	var fromID int64
+	_, err := tx.QueryOne(pg.Scan(&fromID), `SELECT id FROM opds_catalog_genre WHERE genre = ?`, alias)
+	if errors.Is(err, pg.ErrNoRows) || fromID == genreID {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	statements := []string{
+		`INSERT INTO opds_catalog_bgenre (genre_id, book_id)
+		 SELECT DISTINCT ?1::int, bg.book_id FROM opds_catalog_bgenre bg
+		 WHERE bg.genre_id = ?0
+		   AND NOT EXISTS (SELECT 1 FROM opds_catalog_bgenre t
+		                   WHERE t.genre_id = ?1 AND t.book_id = bg.book_id)`,
+		`DELETE FROM opds_catalog_bgenre WHERE genre_id = ?0`,
+		`UPDATE genre_aliases SET genre_id = ?1 WHERE genre_id = ?0`,
+		`DELETE FROM opds_catalog_genre WHERE id = ?0`,
+	}
+	for _, stmt := range statements {
+		if _, err := tx.Exec(stmt, fromID, genreID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ListGenreAliases returns every alias, alphabetically.
+func ListGenreAliases(ctx context.Context) ([]models.GenreAlias, error) {
+	aliases := []models.GenreAlias{}
+	if err := db.ModelContext(ctx, &aliases).Order("alias ASC").Select(); err != nil {
+		return nil, err
+	}
+	return aliases, nil
+}
+
+// SetGenreAlias maps a tag to a genre, replacing whatever it mapped to. If
+// the tag has a genre row of its own — scanning met it before anyone mapped
+// it — that row's books move to the genre and the row goes.
+func SetGenreAlias(ctx context.Context, alias string, genreID int64) (*models.GenreAlias, error) {
+	alias = genres.Canonical(alias)
+	record := &models.GenreAlias{Alias: alias, GenreID: genreID}
+	err := db.RunInTransaction(ctx, func(tx *pg.Tx) error {
+		target := &models.Genre{ID: genreID}
+		if err := tx.Model(target).WherePK().For("UPDATE").Select(); err != nil {
+			if errors.Is(err, pg.ErrNoRows) {
+				return ErrGenreNotFound
+			}
+			return err
+		}
+		if strings.EqualFold(target.Genre, alias) {
+			return ErrGenreAliasOfItself
+		}
+		if _, err := tx.Model(record).
+			OnConflict("(alias) DO UPDATE").
+			Set("genre_id = EXCLUDED.genre_id").
+			Returning("*").
+			Insert(); err != nil {
+			return err
+		}
+		return foldGenreTag(tx, alias, genreID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// GetGenreAlias returns the alias of a tag, given in any spelling the
+// aliases are kept in.
+func GetGenreAlias(ctx context.Context, alias string) (*models.GenreAlias, error) {
+	record := &models.GenreAlias{}
+	err := db.ModelContext(ctx, record).Where("alias = ?", genres.Canonical(alias)).Select()
+	if err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// DeleteGenreAlias removes an alias and returns it. Books already filed
+// through it stay with the genre.
+func DeleteGenreAlias(ctx context.Context, alias string) (*models.GenreAlias, error) {
+	record := &models.GenreAlias{}
+	res, err := db.ModelContext(ctx, record).
+		Where("alias = ?", alias).
+		Returning("*").
+		Delete()
+	if err != nil {
+		return nil, err
+	}
+	if res.RowsAffected() == 0 {
+		return nil, pg.ErrNoRows
+	}
+	return record, nil
+}
+
+// CreateGenreSection adds a section.
+func CreateGenreSection(ctx context.Context, section *models.GenreSection) error {
+	section.Code = strings.ToLower(strings.TrimSpace(section.Code))
+	return db.RunInTransaction(ctx, func(tx *pg.Tx) error {
+		taken, err := tx.Model((*models.GenreSection)(nil)).Where("code = ?", section.Code).Exists()
+		if err != nil {
+			return err
+		}
+		if taken {
+			return ErrGenreSectionTaken
+		}
+		_, err = tx.Model(section).Insert()
+		return err
+	})
+}
+
+// UpdateGenreSection renames or moves a section.
+func UpdateGenreSection(ctx context.Context, section *models.GenreSection) error {
+	res, err := db.ModelContext(ctx, section).
+		Column("title", "title_en", "position").
+		WherePK().
+		Update()
+	if err != nil {
+		return err
+	}
+	if res.RowsAffected() == 0 {
+		return ErrGenreSectionNotFound
+	}
+	return nil
+}
+
+// GetGenreSectionRow returns a section as it is stored, without its genres.
+func GetGenreSectionRow(ctx context.Context, sectionID int64) (*models.GenreSection, error) {
+	section := &models.GenreSection{ID: sectionID}
+	err := db.ModelContext(ctx, section).WherePK().Select()
+	if errors.Is(err, pg.ErrNoRows) {
+		return nil, ErrGenreSectionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return section, nil
+}
+
+// DeleteGenreSection removes a section and returns it. Its genres stay, in
+// no section.
+func DeleteGenreSection(ctx context.Context, sectionID int64) (*models.GenreSection, error) {
+	section := &models.GenreSection{}
+	res, err := db.ModelContext(ctx, section).
+		Where("id = ?", sectionID).
+		Returning("*").
+		Delete()
+	if err != nil {
+		return nil, err
+	}
+	if res.RowsAffected() == 0 {
+		return nil, ErrGenreSectionNotFound
+	}
+	return section, nil
+}
+
+// UpdateGenreNames sets a genre's Russian title and, when given, its English
+// one.
+func UpdateGenreNames(ctx context.Context, id int64, title string, titleEn *string) error {
+	q := db.ModelContext(ctx, (*models.Genre)(nil)).
+		Set("title = ?", title).
+		Where("id = ?", id)
+	if titleEn != nil {
+		q = q.Set("title_en = ?", *titleEn)
+	}
+	res, err := q.Update()
+	if err != nil {
+		return err
+	}
+	if res.RowsAffected() == 0 {
+		return ErrGenreNotFound
+	}
+	return nil
+}
+
+// MoveGenre puts a genre in a section at a position; a nil section takes it
+// out of every section.
+func MoveGenre(ctx context.Context, id int64, sectionID *int64, position int) error {
+	return db.RunInTransaction(ctx, func(tx *pg.Tx) error {
+		if sectionID != nil {
+			exists, err := tx.Model((*models.GenreSection)(nil)).Where("id = ?", *sectionID).Exists()
+			if err != nil {
+				return err
+			}
+			if !exists {
+				return ErrGenreSectionNotFound
+			}
+		}
+		res, err := tx.Model((*models.Genre)(nil)).
+			Set("section_id = ?", sectionID).
+			Set("position = ?", position).
+			Where("id = ?", id).
+			Update()
+		if err != nil {
+			return err
+		}
+		if res.RowsAffected() == 0 {
+			return ErrGenreNotFound
+		}
+		return nil
+	})
+}
+
+// UserInterfaceLang returns the interface language of a user, empty when it
+// cannot be read.
+func UserInterfaceLang(userID int64) string {
+	var lang string
+	_, err := db.QueryOne(pg.Scan(&lang), `SELECT interface_lang FROM auth_user WHERE id = ?`, userID)
+	if err != nil {
+		return ""
+	}
+	return lang
+}
@@ -0,0 +1,167 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gopds-api/models"
+
+	"github.com/go-pg/pg/v10"
+)
+
+var (
+	// ErrIdentityNotFound reports an external identity linked to no user.
+	ErrIdentityNotFound = errors.New("identity not linked")
+	// ErrIdentityTaken reports an external identity already linked to
+	// another user.
+	ErrIdentityTaken = errors.New("identity is linked to another user")
+	// ErrEmailTaken reports a new user whose email a local account already
+	// has: that person should log in and link the identity instead.
+	ErrEmailTaken = errors.New("email belongs to an existing account")
+)
+
+// maxLoginSuffix bounds the numbers tried after a taken username.
+const maxLoginSuffix = 100
+
+// UserByIdentity returns the user an external identity logs in as, with
+// permissions loaded, and notes the login on the identity.
+func UserByIdentity(ctx context.Context, issuer, subject string) (models.User, error) {
+	var identity models.UserIdentity
+	err := db.ModelContext(ctx, &identity).
+		Where("issuer = ?", issuer).
+		Where("subject = ?", subject).
+		Select()
+	if errors.Is(err, pg.ErrNoRows) {
+		return models.User{}, ErrIdentityNotFound
+	}
+	if err != nil {
+		return models.User{}, err
+	}
+
+	user, err := GetUserByID(ctx, identity.UserID)
+	if err != nil {
+		return user, err
+	}
+	if err := loadUserPermissions(&user); err != nil {
+		return user, err
+	}
+	if _, err := db.ModelContext(ctx, &identity).Set("last_login_at = ?", time.Now()).WherePK().Update(); err != nil {
+		return user, err
+	}
+	return user, nil
+}
+
+// LinkIdentity links an external identity to identity.UserID. Linking it
+// again to the same user only refreshes the email.
+func LinkIdentity(ctx context.Context, identity *models.UserIdentity) error {
+	return db.RunInTransaction(ctx, func(tx *pg.Tx) error {
+		var existing models.UserIdentity
+		err := tx.Model(&existing).
+			Where("issuer = ?", identity.Issuer).
+			Where("subject = ?", identity.Subject).
+			For("UPDATE").
+			Select()
+		switch {
+		case errors.Is(err, pg.ErrNoRows):
+			_, err = tx.Model(identity).Insert()
+			return err
+		case err != nil:
+			return err
+		case existing.UserID != identity.UserID:
+			return ErrIdentityTaken
+		}
+		identity.ID = existing.ID
+		identity.CreatedAt = existing.CreatedAt
+		_, err = tx.Model(identity).Column("email").WherePK().Update()
+		return err
+	})
+}
+
+// ListIdentities returns the external identities linked to a user.
+func ListIdentities(ctx context.Context, userID int64) ([]models.UserIdentity, error) {
+	identities := []models.UserIdentity{}
+	err := db.ModelContext(ctx, &identities).
+		Where("user_id = ?", userID).
+		Order("created_at ASC").
+		Select()
+	return identities, err
+}
+
+// DeleteIdentity unlinks one of a user's external identities.
+func DeleteIdentity(ctx context.Context, userID, id int64) error {
+	res, err := db.ModelContext(ctx, (*models.UserIdentity)(nil)).
+		Where("id = ?", id).
+		Where("user_id = ?", userID).
+		Delete()
+	if err != nil {
+		return err
+	}
+	if res.RowsAffected() == 0 {
+		return ErrIdentityNotFound
+	}
+	return nil
+}
+
+// ProvisionUser creates a user for an external identity and links the two.
+// The username is user.Login, numbered when it is taken.
+func ProvisionUser(ctx context.Context, user *models.User, identity *models.UserIdentity) error {
+	user.Email = strings.ToLower(user.Email)
+	return db.RunInTransaction(ctx, func(tx *pg.Tx) error {
+		if user.Email != "" {
+			taken, err := tx.Model((*models.User)(nil)).Where("lower(email) = ?", user.Email).Exists()
+			if err != nil {
+				return err
+			}
+			if taken {
+				return ErrEmailTaken
+			}
+		}
+
+		login, err := freeLogin(tx, user.Login)
+		if err != nil {
+			return err
+		}
+		user.Login = login
+		if _, err := tx.Model(user).Insert(); err != nil {
+			return err
+		}
+
+		taken, err := tx.Model((*models.UserIdentity)(nil)).
+			Where("issuer = ?", identity.Issuer).
+			Where("subject = ?", identity.Subject).
+			Exists()
+		if err != nil {
+			return err
+		}
+		if taken {
+			return ErrIdentityTaken
+		}
+		identity.UserID = user.ID
+		now := time.Now()
+		identity.LastLoginAt = &now
+		_, err = tx.Model(identity).Insert()
+		return err
+	})
+}
+
+// freeLogin returns base, or base followed by the first number that makes
+// it a username nobody has.
+func freeLogin(tx *pg.Tx, base string) (string, error) {
+	for i := 1; i <= maxLoginSuffix; i++ {
+		login := base
+		if i > 1 {
+			login = fmt.Sprintf("%s%d", base, i)
+		}
+		taken, err := tx.Model((*models.User)(nil)).Where("lower(username) = lower(?)", login).Exists()
+		if err != nil {
+			return "", err
+		}
+		if !taken {
+			return login, nil
+		}
+	}
+	return "", fmt.Errorf("no free username like %q", base)
+}
@@ -0,0 +1,61 @@
+package database
+
+import (
+	"fmt"
+
+	"gopds-api/logging"
+	"gopds-api/models"
+)
+
+// ArchiveBookIDs returns the books already scanned from an archive, by file
+// name, so that importing an index over it adds only what is new.
+func ArchiveBookIDs(archiveName string) (map[string]int64, error) {
+	var rows []struct {
+		ID       int64
+		FileName string `pg:"filename"`
+	}
+	err := db.Model((*models.Book)(nil)).
+		Column("id", "filename").
+		Where("path = ?", archiveName).
+		Select(&rows)
+	if err != nil {
+		return nil, err
+	}
+	ids := make(map[string]int64, len(rows))
+	for _, r := range rows {
+		ids[r.FileName] = r.ID
+	}
+	return ids, nil
+}
+
+// SyncIndexedBook applies what an index says about a book already in the
+// catalog: its library id, and the library's deleted flag. A change of the
+// flag unapproves or approves the book again; the rest of its metadata may
+// have been edited here since it was imported and is left alone. Reports
+// whether anything changed.
+func SyncIndexedBook(bookID int64, libID string, deleted bool) (bool, error) {
+	res, err := db.Model((*models.Book)(nil)).
+		Set("lib_id = NULLIF(?, '')", libID).
+		Set("approved = CASE WHEN lib_deleted <> ? THEN NOT ? ELSE approved END", deleted, deleted).
+		Set("lib_deleted = ?", deleted).
+		Where("id = ?", bookID).
+		Where("(lib_id IS DISTINCT FROM NULLIF(?, '') OR lib_deleted <> ?)", libID, deleted).
+		Update()
+	if err != nil {
+		return false, err
+	}
+	return res.RowsAffected() > 0, nil
+}
+
+// SetCatalogInpxChecksum records which INPX description an archive was
+// imported from.
+func SetCatalogInpxChecksum(archiveName, checksum string) error {
+	_, err := db.Model((*models.Catalog)(nil)).
+		Set("inpx_checksum = ?", checksum).
+		Where("cat_name = ?", archiveName).
+		Update()
+	if err != nil {
+		logging.Error(fmt.Sprintf("Failed to record INPX checksum for %s: %v", archiveName, err))
+	}
+	return err
+}
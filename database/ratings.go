@@ -0,0 +1,203 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gopds-api/logging"
+	"gopds-api/models"
+
+	"github.com/go-pg/pg/v10"
+)
+
+var (
+	// ErrRatingNotFound reports a rating that does not exist, or not for
+	// the user asking.
+	ErrRatingNotFound = errors.New("rating not found")
+	// ErrRatingBookNotFound reports a rating of a book that does not
+	// exist or is not in the catalogue.
+	ErrRatingBookNotFound = errors.New("book not found")
+	// ErrReviewNotFound reports a review that does not exist: no rating
+	// with that id, or one without a review text.
+	ErrReviewNotFound = errors.New("review not found")
+)
+
+// upsertRatingSQL stores a reader's rating of a book that readers can see.
+// A review text that changed, and only one that changed, goes back to the
+// moderation queue; an empty one needs no moderation. ?0 is the user, ?1
+// the book, ?2 the stars and ?3 the review.
+const upsertRatingSQL = `
+INSERT INTO book_ratings (user_id, book_id, rating, review, review_status)
+SELECT ?0, b.id, ?2, ?3, CASE WHEN ?3 = '' THEN 'none' ELSE 'pending' END
+FROM opds_catalog_book b
+WHERE b.id = ?1 AND b.approved AND NOT b.duplicate_hidden
+ON CONFLICT (user_id, book_id) DO UPDATE SET
+	rating = EXCLUDED.rating,
+	review = EXCLUDED.review,
+	review_status = CASE
+		WHEN EXCLUDED.review = '' THEN 'none'
+		WHEN EXCLUDED.review = book_ratings.review THEN book_ratings.review_status
+		ELSE 'pending' END,
+	moderated_by = CASE WHEN EXCLUDED.review = book_ratings.review THEN book_ratings.moderated_by END,
+	moderated_at = CASE WHEN EXCLUDED.review = book_ratings.review THEN book_ratings.moderated_at END,
+	updated_at = CURRENT_TIMESTAMP
+RETURNING *`
+
+// UpsertBookRating stores a user's rating of a book, replacing the one
+// they gave before.
+func UpsertBookRating(ctx context.Context, userID, bookID int64, req models.RatingRequest) (*models.BookRating, error) {
+	rating := &models.BookRating{}
+	_, err := db.QueryOneContext(ctx, rating, upsertRatingSQL, userID, bookID, req.Rating, req.Review)
+	if errors.Is(err, pg.ErrNoRows) {
+		return nil, ErrRatingBookNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return rating, nil
+}
+
+// UserBookRating returns a user's rating of a book.
+func UserBookRating(ctx context.Context, userID, bookID int64) (*models.BookRating, error) {
+	rating := &models.BookRating{}
+	err := db.ModelContext(ctx, rating).
+		Where("user_id = ? AND book_id = ?", userID, bookID).
+		Select()
+	if errors.Is(err, pg.ErrNoRows) {
+		return nil, ErrRatingNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return rating, nil
+}
+
+// DeleteBookRating removes a user's rating of a book, and their review
+// with it.
+func DeleteBookRating(ctx context.Context, userID, bookID int64) error {
+	res, err := db.ModelContext(ctx, (*models.BookRating)(nil)).
+		Where("user_id = ? AND book_id = ?", userID, bookID).
+		Delete()
+	if err != nil {
+		return err
+	}
+	if res.RowsAffected() == 0 {
+		return ErrRatingNotFound
+	}
+	return nil
+}
+
+// bookReviewsSQL selects reviews as lists show them; callers add the
+// conditions, order and page.
+const bookReviewsSQL = `
+SELECT br.id, br.book_id, b.title AS book_title, u.username, br.rating, br.review,
+	br.review_status, br.updated_at, count(*) OVER () AS total
+FROM book_ratings br
+JOIN opds_catalog_book b ON b.id = br.book_id
+JOIN auth_user u ON u.id = br.user_id
+`
+
+// bookReviewRow is a review and the size of the list it was read from.
+type bookReviewRow struct {
+	models.BookReview
+	Total int
+}
+
+// selectBookReviews reads a page of reviews and the number of reviews on
+// every page.
+func selectBookReviews(ctx context.Context, where, order string, limit, offset int, params ...any) ([]models.BookReview, int, error) {
+	var rows []bookReviewRow
+	params = append(params, limit, offset)
+	query := bookReviewsSQL + "WHERE " + where + " ORDER BY " + order + " LIMIT ? OFFSET ?"
+	if _, err := db.QueryContext(ctx, &rows, query, params...); err != nil {
+		return nil, 0, err
+	}
+	reviews := make([]models.BookReview, 0, len(rows))
+	total := 0
+	for _, row := range rows {
+		reviews = append(reviews, row.BookReview)
+		total = row.Total
+	}
+	return reviews, total, nil
+}
+
+// ApprovedBookReviews returns a page of a book's approved reviews, newest
+// first, and how many there are.
+func ApprovedBookReviews(ctx context.Context, bookID int64, limit, offset int) ([]models.BookReview, int, error) {
+	return selectBookReviews(ctx, "br.book_id = ? AND br.review_status = ?", "br.updated_at DESC, br.id DESC",
+		limit, offset, bookID, models.ReviewApproved)
+}
+
+// BookReviewsByStatus returns a page of the reviews in a moderation state,
+// oldest first so that the queue is worked in order, and how many there
+// are.
+func BookReviewsByStatus(ctx context.Context, status string, limit, offset int) ([]models.BookReview, int, error) {
+	return selectBookReviews(ctx, "br.review_status = ?", "br.updated_at ASC, br.id ASC",
+		limit, offset, status)
+}
+
+// ModerateBookReview approves or rejects a review. It returns the rating
+// as it was before and as it is now.
+func ModerateBookReview(ctx context.Context, id int64, status string, moderatorID *int64) (before, after *models.BookRating, err error) {
+	err = db.RunInTransaction(ctx, func(tx *pg.Tx) error {
+		before = &models.BookRating{}
+		if err := tx.ModelContext(ctx, before).
+			Where("id = ? AND review_status <> ?", id, models.ReviewNone).
+			For("UPDATE").
+			Select(); err != nil {
+			if errors.Is(err, pg.ErrNoRows) {
+				return ErrReviewNotFound
+			}
+			return err
+		}
+		after = &models.BookRating{}
+		_, err := tx.QueryOneContext(ctx, after, `
+			UPDATE book_ratings SET review_status = ?, moderated_by = ?, moderated_at = ?
+			WHERE id = ? RETURNING *`, status, moderatorID, time.Now(), id)
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return before, after, nil
+}
+
+// populateRatingsWithDB sets the average rating and the number of ratings
+// on already-loaded books. A failure leaves the books unrated: the list is
+// still worth showing without the stars.
+func populateRatingsWithDB(dbh pg.DBI, books []models.Book) {
+	if len(books) == 0 {
+		return
+	}
+	bookIDs := make([]int64, 0, len(books))
+	for i := range books {
+		bookIDs = append(bookIDs, books[i].ID)
+	}
+
+	var rows []struct {
+		BookID int64
+		Rating float64
+		Count  int
+	}
+	if _, err := dbh.Query(&rows, `
+		SELECT book_id, avg(rating) AS rating, count(*) AS count
+		FROM book_ratings WHERE book_id IN (?) GROUP BY book_id`, pg.In(bookIDs)); err != nil {
+		logging.Warnf("Failed to load book ratings: %v", err)
+		return
+	}
+	type aggregate struct {
+		rating float64
+		count  int
+	}
+	lookup := make(map[int64]aggregate, len(rows))
+	for _, r := range rows {
+		lookup[r.BookID] = aggregate{r.Rating, r.Count}
+	}
+	for i := range books {
+		if a, ok := lookup[books[i].ID]; ok {
+			books[i].Rating = a.rating
+			books[i].RatingCount = a.count
+		}
+	}
+}
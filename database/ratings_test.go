@@ -0,0 +1,91 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"gopds-api/models"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestBookRatingLifecycle rates a book, changes the stars and the review,
+// has the review moderated and takes the rating back, checking at each step
+// what other readers and moderators would see.
+func TestBookRatingLifecycle(t *testing.T) {
+	requireDatabase(t)
+
+	var bookID int64
+	if _, err := db.QueryOne(pg.Scan(&bookID),
+		`SELECT id FROM opds_catalog_book WHERE approved AND NOT duplicate_hidden LIMIT 1`); err != nil {
+		t.Skip("no visible book to rate")
+	}
+	stamp := time.Now().UnixNano()
+	reader := makeUser(t, fmt.Sprintf("rating-reader-%d", stamp))
+	moderator := makeUser(t, fmt.Sprintf("rating-moderator-%d", stamp))
+	t.Cleanup(func() { _, _ = db.Exec(`DELETE FROM auth_user WHERE id IN (?, ?)`, reader, moderator) })
+	ctx := context.Background()
+
+	rating, err := UpsertBookRating(ctx, reader, bookID, models.RatingRequest{Rating: 4, Review: "Worth it"})
+	require.NoError(t, err)
+	assert.Equal(t, models.ReviewPending, rating.ReviewStatus, "a new review waits for a moderator")
+
+	reviews, _, err := ApprovedBookReviews(ctx, bookID, 50, 0)
+	require.NoError(t, err)
+	for _, r := range reviews {
+		assert.NotEqual(t, rating.ID, r.ID, "a pending review is not shown")
+	}
+
+	_, after, err := ModerateBookReview(ctx, rating.ID, models.ReviewApproved, &moderator)
+	require.NoError(t, err)
+	assert.Equal(t, models.ReviewApproved, after.ReviewStatus)
+
+	rating, err = UpsertBookRating(ctx, reader, bookID, models.RatingRequest{Rating: 5, Review: "Worth it"})
+	require.NoError(t, err)
+	assert.Equal(t, models.ReviewApproved, rating.ReviewStatus, "new stars alone keep the review approved")
+	assert.Equal(t, 5, rating.Rating)
+
+	rating, err = UpsertBookRating(ctx, reader, bookID, models.RatingRequest{Rating: 5, Review: "Worth it twice"})
+	require.NoError(t, err)
+	assert.Equal(t, models.ReviewPending, rating.ReviewStatus, "a changed text goes back to the queue")
+	assert.Nil(t, rating.ModeratedBy)
+
+	_, _, err = ModerateBookReview(ctx, rating.ID, models.ReviewRejected, &moderator)
+	require.NoError(t, err)
+
+	rating, err = UpsertBookRating(ctx, reader, bookID, models.RatingRequest{Rating: 3})
+	require.NoError(t, err)
+	assert.Equal(t, models.ReviewNone, rating.ReviewStatus, "stars without a text need no moderation")
+	_, _, err = ModerateBookReview(ctx, rating.ID, models.ReviewApproved, &moderator)
+	assert.ErrorIs(t, err, ErrReviewNotFound, "there is no review to moderate")
+
+	require.NoError(t, DeleteBookRating(ctx, reader, bookID))
+	_, err = UserBookRating(ctx, reader, bookID)
+	assert.ErrorIs(t, err, ErrRatingNotFound)
+	assert.ErrorIs(t, DeleteBookRating(ctx, reader, bookID), ErrRatingNotFound)
+
+	_, err = UpsertBookRating(ctx, reader, -1, models.RatingRequest{Rating: 3})
+	assert.ErrorIs(t, err, ErrRatingBookNotFound)
+}
+
+func TestPopulateRatings(t *testing.T) {
+	withSearchFixture(t, func(f *searchFixture) {
+		rated := f.Book("ratingsRated", &fixtureBook{Title: "Оценённая книга", Approved: true})
+		unrated := f.Book("ratingsUnrated", &fixtureBook{Title: "Неоценённая книга", Approved: true})
+		other := f.User("ratingsOther", "ratingsfixture_other")
+		f.exec(`INSERT INTO book_ratings (user_id, book_id, rating) VALUES (?, ?, 5), (?, ?, 2)`,
+			f.UserIDs["reader"], rated, other, rated)
+
+		books := []models.Book{{ID: rated}, {ID: unrated}}
+		populateRatingsWithDB(f.tx, books)
+
+		assert.InDelta(t, 3.5, books[0].Rating, 0.001)
+		assert.Equal(t, 2, books[0].RatingCount)
+		assert.Zero(t, books[1].Rating)
+		assert.Zero(t, books[1].RatingCount)
+	})
+}
@@ -0,0 +1,195 @@
+package database
+
+import (
+	"context"
+
+	"gopds-api/models"
+
+	"github.com/go-pg/pg/v10"
+)
+
+// recommendationSeeds is how many of a reader's latest favorites, and as
+// many of their latest downloads, a personal list starts from.
+const recommendationSeeds = 20
+
+// PGRecommendationRepository scores books like others in PostgreSQL. Like
+// PGSearchRepository it takes a pg.DBI so that tests can run it inside the
+// rollback fixture transaction.
+type PGRecommendationRepository struct {
+	db pg.DBI
+}
+
+// NewPGRecommendationRepository wires the repository to a database handle.
+func NewPGRecommendationRepository(db pg.DBI) *PGRecommendationRepository {
+	return &PGRecommendationRepository{db: db}
+}
+
+// recommendationSQL ranks the books that have something in common with the
+// seed books. Each signal walks from a seed to the books it shares:
+//
+//   - series (weight 4) — another book of one of its series;
+//   - author (weight 3) — another book by one of its authors;
+//   - collection (weight 2) — another book of a public collection, a user's
+//     or a curated one, that holds it;
+//   - reader (weight 1.5) — a book favorited by a reader who favorited it;
+//   - genre (weight 1) — one of the newest books of one of its genres.
+//
+// A book scores weight × ln(1 + hits) for each signal, so a second shared
+// author counts for less than the first, and a run of co-favorites cannot
+// outweigh a shared series. Every walk is capped per step (the newest books
+// of a genre, the latest readers of a book and their latest favorites), so
+// a seed in a huge genre or a popular book costs the same as any other.
+//
+// The seeds never come back, nor do other copies of them — a book with a
+// seed's normalized title — and a personal list leaves out everything the
+// reader favorited or downloaded. Visibility is the book list's: approved,
+// not a hidden duplicate, in the reader's language. Ties go to the more
+// downloaded book, then to the newer one.
+//
+// Parameters: the seed ids, the caller (0 for none), whether to leave out
+// the caller's own books, the language twice, and the limit.
+const recommendationSQL = `
+WITH seeds AS (
+    SELECT DISTINCT unnest(?0::int[]) AS book_id
+),
+seed_titles AS (
+    SELECT DISTINCT public.search_normalize(b.title) AS norm_title
+    FROM opds_catalog_book b
+    JOIN seeds s ON s.book_id = b.id
+),
+signals AS (
+    SELECT o.book_id, 'series' AS signal, 4.0 AS weight
+    FROM seeds s
+    JOIN opds_catalog_bseries bs ON bs.book_id = s.book_id
+    CROSS JOIN LATERAL (
+        SELECT x.book_id FROM opds_catalog_bseries x
+        WHERE x.ser_id = bs.ser_id
+        ORDER BY x.book_id DESC
+        LIMIT 200
+    ) o
+    UNION ALL
+    SELECT o.book_id, 'author', 3.0
+    FROM seeds s
+    JOIN opds_catalog_bauthor ba ON ba.book_id = s.book_id
+    CROSS JOIN LATERAL (
+        SELECT x.book_id FROM opds_catalog_bauthor x
+        WHERE x.author_id = ba.author_id
+        ORDER BY x.book_id DESC
+        LIMIT 200
+    ) o
+    UNION ALL
+    SELECT o.book_id, 'collection', 2.0
+    FROM seeds s
+    JOIN book_collection_books cb ON cb.book_id = s.book_id
+    JOIN book_collections c ON c.id = cb.book_collection_id AND c.is_public
+    CROSS JOIN LATERAL (
+        SELECT x.book_id FROM book_collection_books x
+        WHERE x.book_collection_id = c.id
+        ORDER BY x.position
+        LIMIT 200
+    ) o
+    UNION ALL
+    SELECT o.book_id, 'collection', 2.0
+    FROM seeds s
+    JOIN book_collection_items ci ON ci.book_id = s.book_id
+        AND ci.match_status IN ('auto_matched', 'manual')
+    JOIN book_collections c ON c.id = ci.collection_id AND c.is_curated AND c.is_public
+    CROSS JOIN LATERAL (
+        SELECT x.book_id FROM book_collection_items x
+        WHERE x.collection_id = c.id
+            AND x.book_id IS NOT NULL
+            AND x.match_status IN ('auto_matched', 'manual')
+        ORDER BY x.position
+        LIMIT 200
+    ) o
+    UNION ALL
+    SELECT o.book_id, 'reader', 1.5
+    FROM seeds s
+    CROSS JOIN LATERAL (
+        SELECT fb.user_id FROM favorite_books fb
+        WHERE fb.book_id = s.book_id AND fb.user_id <> ?1
+        ORDER BY fb.id DESC
+        LIMIT 50
+    ) r
+    CROSS JOIN LATERAL (
+        SELECT x.book_id FROM favorite_books x
+        WHERE x.user_id = r.user_id
+        ORDER BY x.id DESC
+        LIMIT 50
+    ) o
+    UNION ALL
+    SELECT o.book_id, 'genre', 1.0
+    FROM seeds s
+    JOIN opds_catalog_bgenre bg ON bg.book_id = s.book_id
+    CROSS JOIN LATERAL (
+        SELECT x.book_id FROM opds_catalog_bgenre x
+        WHERE x.genre_id = bg.genre_id
+        ORDER BY x.book_id DESC
+        LIMIT 200
+    ) o
+),
+scored AS (
+    SELECT h.book_id, sum(h.weight * ln(1 + h.hits)) AS score
+    FROM (
+        SELECT book_id, signal, weight, count(*) AS hits
+        FROM signals
+        GROUP BY book_id, signal, weight
+    ) h
+    GROUP BY h.book_id
+)
+SELECT b.id
+FROM scored sc
+JOIN opds_catalog_book b ON b.id = sc.book_id
+WHERE b.approved
+    AND NOT b.duplicate_hidden
+    AND (?3::text = '' OR b.lang = ?3::text)
+    AND NOT EXISTS (SELECT 1 FROM seeds s WHERE s.book_id = b.id)
+    AND public.search_normalize(b.title) NOT IN (SELECT norm_title FROM seed_titles)
+    AND NOT (?2::bool AND EXISTS (
+        SELECT 1 FROM favorite_books fb WHERE fb.user_id = ?1 AND fb.book_id = b.id
+    ))
+    AND NOT (?2::bool AND EXISTS (
+        SELECT 1 FROM download_history dh WHERE dh.user_id = ?1 AND dh.book_id = b.id
+    ))
+ORDER BY sc.score DESC, b.downloads DESC, b.id DESC
+LIMIT ?4
+`
+
+// Recommend returns up to req.Limit books like the request's seed, best
+// first. A personal request from a reader with no favorites and no
+// downloads has no seed and gets no books.
+//
+//nolint:gocritic // the repository port takes the request by value; this implements it
+func (r *PGRecommendationRepository) Recommend(ctx context.Context, req models.RecommendationRequest) ([]models.Book, error) {
+	seeds := []int64{req.BookID}
+	if req.Personal() {
+		var err error
+		if seeds, err = r.readerSeeds(ctx, req.UserID); err != nil {
+			return nil, preferContextError(ctx, err)
+		}
+	}
+	if len(seeds) == 0 {
+		return nil, nil
+	}
+
+	var ids []int64
+	if _, err := r.db.QueryContext(ctx, &ids, recommendationSQL,
+		pg.Array(seeds), req.UserID, req.Personal(), req.Language, req.Limit); err != nil {
+		return nil, preferContextError(ctx, err)
+	}
+	return loadRankedBooks(ctx, r.db, req.UserID, ids)
+}
+
+// readerSeeds are the books a reader favorited and downloaded lately.
+func (r *PGRecommendationRepository) readerSeeds(ctx context.Context, userID int64) ([]int64, error) {
+	if userID == 0 {
+		return nil, nil
+	}
+	var ids []int64
+	_, err := r.db.QueryContext(ctx, &ids, `
+		(SELECT book_id FROM favorite_books WHERE user_id = ?0 ORDER BY id DESC LIMIT ?1)
+		UNION
+		(SELECT book_id FROM download_history WHERE user_id = ?0 ORDER BY downloaded_at DESC LIMIT ?1)`,
+		userID, recommendationSeeds)
+	return ids, err
+}
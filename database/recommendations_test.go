@@ -0,0 +1,86 @@
+package database
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"gopds-api/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func recommendedIDs(books []models.Book) []int64 {
+	ids := make([]int64, 0, len(books))
+	for i := range books {
+		ids = append(ids, books[i].ID)
+	}
+	return ids
+}
+
+func TestPGRecommendationRepository(t *testing.T) {
+	withSearchFixture(t, func(f *searchFixture) {
+		author := f.Author("recsAuthor", "Рекомендов Автор")
+		stranger := f.Author("recsStranger", "Чужой Автор")
+		series := f.Series("recsSeries", "Рекомендованная серия")
+		genre := f.Genre("recsGenre", "Рекомендованный жанр")
+		reader := f.User("recsReader", "recsfixture_reader")
+		fan := f.User("recsFan", "recsfixture_fan")
+
+		seed := f.Book("recsSeed", &fixtureBook{Title: "Исходная книга", Approved: true,
+			Authors: []int64{author}, Series: []int64{series}, Genres: []int64{genre}})
+		sameSeries := f.Book("recsSameSeries", &fixtureBook{Title: "Вторая книга серии", Approved: true,
+			Authors: []int64{stranger}, Series: []int64{series}})
+		sameAuthor := f.Book("recsSameAuthor", &fixtureBook{Title: "Другая книга автора", Approved: true,
+			Authors: []int64{author}})
+		sameGenre := f.Book("recsSameGenre", &fixtureBook{Title: "Книга того же жанра", Approved: true,
+			Authors: []int64{stranger}, Genres: []int64{genre}})
+		coFavorite := f.Book("recsCoFavorite", &fixtureBook{Title: "Любимая книга поклонника", Approved: true,
+			Authors: []int64{stranger}})
+		copyOfSeed := f.Book("recsCopy", &fixtureBook{Title: "Исходная книга", Approved: true,
+			Authors: []int64{author}})
+		hidden := f.Book("recsHidden", &fixtureBook{Title: "Скрытая книга автора", Approved: true, Hidden: true,
+			Authors: []int64{author}})
+		otherLang := f.Book("recsOtherLang", &fixtureBook{Title: "Book by the author", Lang: "en", Approved: true,
+			Authors: []int64{author}})
+		f.Favorite(fan, seed)
+		f.Favorite(fan, coFavorite)
+
+		repo := NewPGRecommendationRepository(f.tx)
+		ctx := context.Background()
+
+		t.Run("a shared series outranks a shared author, which outranks a shared genre", func(t *testing.T) {
+			books, err := repo.Recommend(ctx, models.RecommendationRequest{BookID: seed, Language: "fx", Limit: 10})
+			require.NoError(t, err)
+			ids := recommendedIDs(books)
+			require.Subset(t, ids, []int64{sameSeries, sameAuthor, sameGenre, coFavorite})
+			assert.Equal(t, sameSeries, ids[0])
+			assert.Equal(t, sameAuthor, ids[1])
+			assert.Less(t, slices.Index(ids, coFavorite), slices.Index(ids, sameGenre), "co-favorites weigh more than a genre")
+			assert.NotContains(t, ids, seed)
+			assert.NotContains(t, ids, copyOfSeed, "another copy of the book is not a recommendation")
+			assert.NotContains(t, ids, hidden)
+			assert.NotContains(t, ids, otherLang)
+			require.NotEmpty(t, books[0].Series, "books come hydrated")
+		})
+
+		t.Run("a personal list starts from favorites and downloads and leaves them out", func(t *testing.T) {
+			f.Favorite(reader, seed)
+			f.exec(`INSERT INTO download_history (user_id, book_id) VALUES (?, ?)`, reader, sameAuthor)
+
+			books, err := repo.Recommend(ctx, models.RecommendationRequest{UserID: reader, Language: "fx", Limit: 10})
+			require.NoError(t, err)
+			ids := recommendedIDs(books)
+			assert.Contains(t, ids, sameSeries)
+			assert.NotContains(t, ids, seed)
+			assert.NotContains(t, ids, sameAuthor, "a downloaded book is the reader's already")
+		})
+
+		t.Run("a reader with nothing to start from gets nothing", func(t *testing.T) {
+			books, err := repo.Recommend(ctx, models.RecommendationRequest{UserID: fan + 1000, Limit: 10})
+			require.NoError(t, err)
+			assert.Empty(t, books)
+		})
+	})
+}
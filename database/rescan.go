@@ -3,7 +3,10 @@ package database
 import (
 	"errors"
 	"fmt"
+	"strings"
 
+	"gopds-api/internal/genres"
+	"gopds-api/internal/posters"
 	"gopds-api/llm"
 	"gopds-api/logging"
 	"gopds-api/models"
@@ -140,6 +143,17 @@ func ApplySelectiveRescanChanges(bookID int64, selectedFields *models.RescanAppr
 
 	if models.ShouldUpdate(getFieldFlag(selectedFields, "cover")) {
 		updateQuery = updateQuery.Set("cover = ?", pending.CoverUpdated)
+		// The placeholder follows the cover it describes. A removed cover
+		// or one that does not decode leaves it empty.
+		var placeholder posters.Placeholder
+		if pending.CoverUpdated {
+			if p, phErr := posters.PlaceholderOf(pending.CoverData); phErr == nil {
+				placeholder = p
+			}
+		}
+		updateQuery = updateQuery.
+			Set("cover_blurhash = ?", placeholder.Blurhash).
+			Set("cover_color = ?", placeholder.Color)
 		updatedFields = append(updatedFields, "cover")
 	} else {
 		skippedFields = append(skippedFields, "cover")
@@ -257,36 +271,25 @@ func updateBookAuthors(tx *pg.Tx, bookID int64, authors []models.RescanAuthor) e
 		return err
 	}
 
-	// Insert new author links
+	// Insert new author links. Two names may stand for one author through an
+	// alias, and the author is linked once.
+	seen := make(map[int64]bool, len(authors))
 	for _, author := range authors {
-		// Get or create author
-		authorObj := &models.Author{
-			FullName: author.Name,
+		if strings.TrimSpace(author.Name) == "" {
+			continue
 		}
-
-		// Try to find existing author
-		existingAuthor := &models.Author{}
-		err := tx.Model(existingAuthor).
-			Where("full_name = ?", author.Name).
-			Select(existingAuthor)
-
-		if err != nil && err != pg.ErrNoRows {
+		authorID, err := GetOrCreateAuthor(tx, author.Name)
+		if err != nil {
 			return err
 		}
-
-		// If author doesn't exist, create it
-		if err == pg.ErrNoRows {
-			_, err := tx.Model(authorObj).Insert()
-			if err != nil {
-				return err
-			}
-		} else {
-			authorObj.ID = existingAuthor.ID
+		if seen[authorID] {
+			continue
 		}
+		seen[authorID] = true
 
 		// Create link
 		link := &models.OrderToAuthor{
-			AuthorID: authorObj.ID,
+			AuthorID: authorID,
 			BookID:   bookID,
 		}
 
@@ -371,42 +374,46 @@ func updateBookTags(tx *pg.Tx, bookID int64, tags []string, llmService *llm.LLMS
 		return err
 	}
 
-	// Deduplicate tags
+	// Deduplicate tags, and the genres they resolve to: a legacy tag and
+	// its canonical code land on one genre.
 	seen := make(map[string]bool, len(tags))
+	linked := make(map[int64]bool, len(tags))
 
 	// Insert new genre links
 	for _, tag := range tags {
+		tag = genres.Canonical(tag)
 		if tag == "" || seen[tag] {
 			continue
 		}
 		seen[tag] = true
 
-		// Try to find existing genre
-		genreObj := &models.Genre{}
-		err := tx.Model(genreObj).
-			Where("genre = ?", tag).
-			Select(genreObj)
-
-		if err != nil && err != pg.ErrNoRows {
+		// An alias first, then a genre of that tag
+		genreID, found, err := ResolveGenre(tx, tag)
+		if err != nil {
 			return err
 		}
 
 		// If genre doesn't exist, create it
-		if err == pg.ErrNoRows {
+		if !found {
 			title := tag
 			if llmService != nil {
 				title = llmService.GenerateGenreTitle(tag)
 			}
-			genreObj = &models.Genre{Genre: tag, Title: title}
+			genreObj := &models.Genre{Genre: tag, Title: title}
 			_, err = tx.Model(genreObj).Insert()
 			if err != nil {
 				return err
 			}
+			genreID = genreObj.ID
+		}
+		if linked[genreID] {
+			continue
 		}
+		linked[genreID] = true
 
 		// Create link
 		link := &models.OrderToGenre{
-			GenreID: genreObj.ID,
+			GenreID: genreID,
 			BookID:  bookID,
 		}
 
@@ -0,0 +1,246 @@
+package database
+
+import (
+	"context"
+	"errors"
+
+	"gopds-api/models"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
+)
+
+var (
+	// ErrRoleNotFound reports a role id that names no role.
+	ErrRoleNotFound = errors.New("role not found")
+	// ErrRoleTaken reports a role name already in use.
+	ErrRoleTaken = errors.New("role name already exists")
+	// ErrUserNotFound reports a user id that names no user.
+	ErrUserNotFound = errors.New("user not found")
+)
+
+// ListRoles returns every role with its permissions, by name.
+func ListRoles(ctx context.Context) ([]models.Role, error) {
+	roles := []models.Role{}
+	if err := db.ModelContext(ctx, &roles).Order("name ASC").Select(); err != nil {
+		return nil, err
+	}
+	if err := loadRolePermissions(ctx, db, roles); err != nil {
+		return nil, err
+	}
+	return roles, nil
+}
+
+// GetRole returns one role with its permissions.
+func GetRole(ctx context.Context, id int64) (*models.Role, error) {
+	role := &models.Role{ID: id}
+	err := db.ModelContext(ctx, role).WherePK().Select()
+	if errors.Is(err, pg.ErrNoRows) {
+		return nil, ErrRoleNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	roles := []models.Role{*role}
+	if err := loadRolePermissions(ctx, db, roles); err != nil {
+		return nil, err
+	}
+	return &roles[0], nil
+}
+
+// loadRolePermissions fills in the permissions of each role.
+func loadRolePermissions(ctx context.Context, q orm.DB, roles []models.Role) error {
+	if len(roles) == 0 {
+		return nil
+	}
+	ids := make([]int64, len(roles))
+	for i := range roles {
+		ids[i] = roles[i].ID
+		roles[i].Permissions = []string{}
+	}
+	var grants []models.RolePermission
+	err := q.ModelContext(ctx, &grants).
+		Where("role_id IN (?)", pg.In(ids)).
+		Order("role_id ASC", "permission ASC").
+		Select()
+	if err != nil {
+		return err
+	}
+	byID := make(map[int64]int, len(roles))
+	for i := range roles {
+		byID[roles[i].ID] = i
+	}
+	for _, g := range grants {
+		if i, ok := byID[g.RoleID]; ok {
+			roles[i].Permissions = append(roles[i].Permissions, g.Permission)
+		}
+	}
+	return nil
+}
+
+// CreateRole stores a new, validated role with its permissions.
+func CreateRole(ctx context.Context, role *models.Role) error {
+	return db.RunInTransaction(ctx, func(tx *pg.Tx) error {
+		taken, err := tx.Model((*models.Role)(nil)).Where("name = ?", role.Name).Exists()
+		if err != nil {
+			return err
+		}
+		if taken {
+			return ErrRoleTaken
+		}
+		if _, err := tx.Model(role).Returning("id, created_at").Insert(); err != nil {
+			return err
+		}
+		return writeRolePermissions(tx, role)
+	})
+}
+
+// UpdateRole renames a validated role and replaces its description and
+// permissions.
+func UpdateRole(ctx context.Context, role *models.Role) error {
+	return db.RunInTransaction(ctx, func(tx *pg.Tx) error {
+		taken, err := tx.Model((*models.Role)(nil)).
+			Where("name = ?", role.Name).
+			Where("id <> ?", role.ID).
+			Exists()
+		if err != nil {
+			return err
+		}
+		if taken {
+			return ErrRoleTaken
+		}
+		res, err := tx.Model(role).
+			Column("name", "description").
+			WherePK().
+			Returning("created_at").
+			Update()
+		if err != nil {
+			return err
+		}
+		if res.RowsAffected() == 0 {
+			return ErrRoleNotFound
+		}
+		if _, err := tx.Model((*models.RolePermission)(nil)).Where("role_id = ?", role.ID).Delete(); err != nil {
+			return err
+		}
+		return writeRolePermissions(tx, role)
+	})
+}
+
+func writeRolePermissions(tx *pg.Tx, role *models.Role) error {
+	if len(role.Permissions) == 0 {
+		return nil
+	}
+	grants := make([]models.RolePermission, len(role.Permissions))
+	for i, p := range role.Permissions {
+		grants[i] = models.RolePermission{RoleID: role.ID, Permission: p}
+	}
+	_, err := tx.Model(&grants).Insert()
+	return err
+}
+
+// DeleteRole deletes a role; the users holding it lose it.
+func DeleteRole(ctx context.Context, id int64) error {
+	res, err := db.ModelContext(ctx, (*models.Role)(nil)).Where("id = ?", id).Delete()
+	if err != nil {
+		return err
+	}
+	if res.RowsAffected() == 0 {
+		return ErrRoleNotFound
+	}
+	return nil
+}
+
+// UserRoles returns the roles a user holds, with their permissions.
+func UserRoles(ctx context.Context, userID int64) ([]models.Role, error) {
+	return userRoles(ctx, db, userID)
+}
+
+func userRoles(ctx context.Context, q orm.DB, userID int64) ([]models.Role, error) {
+	roles := []models.Role{}
+	err := q.ModelContext(ctx, &roles).
+		Where("id IN (SELECT role_id FROM user_roles WHERE user_id = ?)", userID).
+		Order("name ASC").
+		Select()
+	if err != nil {
+		return nil, err
+	}
+	if err := loadRolePermissions(ctx, q, roles); err != nil {
+		return nil, err
+	}
+	return roles, nil
+}
+
+// SetUserRoles makes roleIDs the roles the user holds, and returns them.
+// Roles the user already held keep when and by whom they were granted.
+func SetUserRoles(ctx context.Context, userID int64, roleIDs []int64, grantedBy *int64) ([]models.Role, error) {
+	roleIDs = uniqueIDs(roleIDs)
+	var roles []models.Role
+	err := db.RunInTransaction(ctx, func(tx *pg.Tx) error {
+		exists, err := tx.Model((*models.User)(nil)).Where("id = ?", userID).Exists()
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return ErrUserNotFound
+		}
+
+		if len(roleIDs) > 0 {
+			n, err := tx.Model((*models.Role)(nil)).Where("id IN (?)", pg.In(roleIDs)).Count()
+			if err != nil {
+				return err
+			}
+			if n != len(roleIDs) {
+				return ErrRoleNotFound
+			}
+		}
+
+		drop := tx.Model((*models.UserRole)(nil)).Where("user_id = ?", userID)
+		if len(roleIDs) > 0 {
+			drop = drop.Where("role_id NOT IN (?)", pg.In(roleIDs))
+		}
+		if _, err := drop.Delete(); err != nil {
+			return err
+		}
+		if len(roleIDs) > 0 {
+			grants := make([]models.UserRole, len(roleIDs))
+			for i, id := range roleIDs {
+				grants[i] = models.UserRole{UserID: userID, RoleID: id, GrantedBy: grantedBy}
+			}
+			if _, err := tx.Model(&grants).OnConflict("DO NOTHING").Insert(); err != nil {
+				return err
+			}
+		}
+
+		roles, err = userRoles(ctx, tx, userID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return roles, nil
+}
+
+// UserPermissions returns every permission the user's roles grant, once
+// each, sorted.
+func UserPermissions(ctx context.Context, userID int64) ([]string, error) {
+	var perms []string
+	_, err := db.QueryContext(ctx, &perms, `
+		SELECT DISTINCT rp.permission
+		FROM role_permissions rp
+		JOIN user_roles ur ON ur.role_id = rp.role_id
+		WHERE ur.user_id = ?
+		ORDER BY rp.permission`, userID)
+	return perms, err
+}
+
+// loadUserPermissions fills in the permissions of a user about to be given
+// a session.
+func loadUserPermissions(user *models.User) error {
+	perms, err := UserPermissions(context.Background(), user.ID)
+	if err != nil {
+		return err
+	}
+	user.Permissions = perms
+	return nil
+}
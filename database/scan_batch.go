@@ -0,0 +1,80 @@
+package database
+
+import (
+	"hash/fnv"
+	"sort"
+	"strings"
+
+	"gopds-api/models"
+
+	"github.com/go-pg/pg/v10"
+)
+
+// Kinds of name LockCatalogNames takes locks on.
+const (
+	LockAuthor = "author"
+	LockSeries = "series"
+	LockGenre  = "genre"
+)
+
+// CatalogName is a name a scan looks up and, if it is not there, creates:
+// an author, a series or a genre.
+type CatalogName struct {
+	Kind string
+	Name string
+}
+
+// LockCatalogNames takes a transaction-scoped advisory lock on each name, so
+// that two scans that both miss a name do not both create it: the second
+// waits for the first to commit and then finds its row. The locks are taken
+// in one statement and in key order, which keeps two transactions locking
+// overlapping sets from deadlocking; PostgreSQL evaluates a volatile output
+// column after the ORDER BY.
+func LockCatalogNames(tx *pg.Tx, names []CatalogName) error {
+	keys := make([]int64, 0, len(names))
+	seen := make(map[int64]bool, len(names))
+	for _, n := range names {
+		h := fnv.New64a()
+		_, _ = h.Write([]byte(n.Kind + "\x00" + strings.ToLower(strings.TrimSpace(n.Name))))
+		key := int64(h.Sum64()) // #nosec G115 -- a lock key, any 64 bits will do
+		if !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	_, err := tx.Exec(`SELECT pg_advisory_xact_lock(k) FROM unnest(?::bigint[]) AS k ORDER BY k`, pg.Array(keys))
+	return err
+}
+
+// ExistingBookMD5s returns which of the hashes a book in the catalog has.
+func ExistingBookMD5s(hashes []string) (map[string]bool, error) {
+	return existingBookValues("md5", hashes)
+}
+
+// ExistingLibIDs returns which of the library ids a book in the catalog has.
+func ExistingLibIDs(libIDs []string) (map[string]bool, error) {
+	return existingBookValues("lib_id", libIDs)
+}
+
+func existingBookValues(column string, values []string) (map[string]bool, error) {
+	found := make(map[string]bool)
+	if len(values) == 0 {
+		return found, nil
+	}
+	var rows []string
+	err := db.Model((*models.Book)(nil)).
+		ColumnExpr("DISTINCT ?", pg.Ident(column)).
+		Where("? IN (?)", pg.Ident(column), pg.In(values)).
+		Select(&rows)
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range rows {
+		found[v] = true
+	}
+	return found, nil
+}
@@ -0,0 +1,72 @@
+package database
+
+import (
+	"context"
+
+	"gopds-api/models"
+)
+
+// spellingSQL splits a query into its normalized words and reads each one
+// against the search dictionary: whether the catalogue uses it, and, when
+// it does not, the nearest dictionary words by trigram distance. The
+// lateral KNN walks the GiST index, so only the few nearest words are
+// visited however large the dictionary. Words under three runes are never
+// in the dictionary and get no candidates: too short to correct. Every word
+// yields at least one row, candidates or not, in query order.
+const spellingSQL = `
+WITH q AS (
+    SELECT t.token, t.pos
+    FROM unnest(string_to_array(public.search_normalize(?), ' ')) WITH ORDINALITY AS t(token, pos)
+    WHERE t.token <> ''
+)
+SELECT q.pos, q.token,
+    EXISTS (SELECT 1 FROM public.search_dictionary k WHERE k.token = q.token) AS known,
+    c.token AS candidate, c.frequency
+FROM q
+LEFT JOIN LATERAL (
+    SELECT d.token, d.frequency, d.token <-> q.token AS distance
+    FROM public.search_dictionary d
+    WHERE char_length(q.token) >= 3
+        AND NOT EXISTS (SELECT 1 FROM public.search_dictionary k WHERE k.token = q.token)
+    ORDER BY d.token <-> q.token
+    LIMIT ?
+) c ON true
+ORDER BY q.pos, c.distance NULLS LAST`
+
+type spellingRow struct {
+	Pos       int
+	Token     string
+	Known     bool
+	Candidate *string
+	Frequency *int
+}
+
+// SpellingCandidates reads every word of query against the search
+// dictionary, with up to perWord nearest dictionary words for each word the
+// catalogue does not use.
+func (r *PGSearchRepository) SpellingCandidates(ctx context.Context, query string, perWord int) ([]models.TokenCandidates, error) {
+	var rows []spellingRow
+	if _, err := r.db.QueryContext(ctx, &rows, spellingSQL, query, perWord); err != nil {
+		return nil, preferContextError(ctx, err)
+	}
+	var words []models.TokenCandidates
+	pos := 0
+	for _, row := range rows {
+		if row.Pos != pos {
+			pos = row.Pos
+			words = append(words, models.TokenCandidates{Token: row.Token, Known: row.Known})
+		}
+		if row.Candidate != nil && row.Frequency != nil {
+			w := &words[len(words)-1]
+			w.Candidates = append(w.Candidates, models.DictionaryWord{Token: *row.Candidate, Frequency: *row.Frequency})
+		}
+	}
+	return words, nil
+}
+
+// RefreshSearchDictionary rebuilds the search dictionary from the catalogue
+// as it is now. Searches keep reading the previous one meanwhile.
+func RefreshSearchDictionary(ctx context.Context) error {
+	_, err := db.ExecContext(ctx, "REFRESH MATERIALIZED VIEW CONCURRENTLY public.search_dictionary")
+	return err
+}
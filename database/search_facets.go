@@ -0,0 +1,96 @@
+package database
+
+import (
+	"context"
+
+	"gopds-api/models"
+
+	"github.com/go-pg/pg/v10"
+)
+
+// bookFacetSQL counts the books a search admits by language, genre, author,
+// series and decade of publication, keeping the commonest values of each
+// facet. Only the sample most relevant books are counted: the ranking is
+// paid anyway, the joins behind the counts grow with the result, and a
+// reader narrowing a result of thousands needs its proportions, not its
+// census. A book of three genres counts under each, one without a year under
+// no decade. The decade and the year filter read the same four digits of
+// the free-text docdate.
+const bookFacetSQL = bookRankingSQL + `,
+sample AS (
+    SELECT r.id FROM ranked r ORDER BY r.pos LIMIT ?
+),
+counted AS (
+    SELECT 'language' AS facet, b.lang AS value, b.lang AS label, count(*) AS count
+    FROM sample s
+    JOIN opds_catalog_book b ON b.id = s.id
+    WHERE b.lang <> ''
+    GROUP BY b.lang
+    UNION ALL
+    SELECT 'genre', g.id::text, COALESCE(NULLIF(g.title, ''), g.genre), count(*)
+    FROM sample s
+    JOIN opds_catalog_bgenre bg ON bg.book_id = s.id
+    JOIN opds_catalog_genre g ON g.id = bg.genre_id
+    GROUP BY g.id, g.title, g.genre
+    UNION ALL
+    SELECT 'author', a.id::text, a.full_name, count(*)
+    FROM sample s
+    JOIN opds_catalog_bauthor ba ON ba.book_id = s.id
+    JOIN opds_catalog_author a ON a.id = ba.author_id
+    GROUP BY a.id, a.full_name
+    UNION ALL
+    SELECT 'series', sr.id::text, sr.ser, count(*)
+    FROM sample s
+    JOIN opds_catalog_bseries bs ON bs.book_id = s.id
+    JOIN opds_catalog_series sr ON sr.id = bs.ser_id
+    GROUP BY sr.id, sr.ser
+    UNION ALL
+    SELECT 'decade', d.decade::text, d.decade::text, count(*)
+    FROM (
+        SELECT substring(b.docdate from '\d{4}')::int / 10 * 10 AS decade
+        FROM sample s
+        JOIN opds_catalog_book b ON b.id = s.id
+    ) d
+    WHERE d.decade IS NOT NULL
+    GROUP BY d.decade
+),
+ordered AS (
+    SELECT c.*, row_number() OVER (
+        PARTITION BY c.facet ORDER BY c.count DESC, c.label, c.value) AS pos
+    FROM counted c
+)
+SELECT o.facet, o.value, o.label, o.count
+FROM ordered o
+WHERE o.pos <= ?
+ORDER BY o.facet, o.pos`
+
+type facetRow struct {
+	Facet string
+	Value string
+	Label string
+	Count int
+}
+
+// BookFacets counts the sample most relevant books of a search by facet,
+// the perFacet commonest values of each. The request's sort is ignored:
+// relevance picks the sample.
+//
+//nolint:gocritic // takes the request by value like SearchBooks
+func (r *PGSearchRepository) BookFacets(
+	ctx context.Context, req models.BookSearchRequest, sample, perFacet int,
+) (models.BookFacets, error) {
+	var rows []facetRow
+	args := append(bookRankingArgs(req, false), sample, perFacet)
+	query := func(q pg.DBI) error {
+		_, err := q.QueryContext(ctx, &rows, bookFacetSQL, args...)
+		return err
+	}
+	var facets models.BookFacets
+	if err := r.queryWithBookThreshold(ctx, query); err != nil {
+		return facets, preferContextError(ctx, err)
+	}
+	for _, row := range rows {
+		facets.Add(row.Facet, models.FacetCount{Value: row.Value, Label: row.Label, Count: row.Count})
+	}
+	return facets, nil
+}
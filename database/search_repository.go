@@ -2,6 +2,7 @@ package database
 
 import (
 	"context"
+	"math"
 
 	"gopds-api/models"
 
@@ -21,9 +22,9 @@ func NewPGSearchRepository(db pg.DBI) *PGSearchRepository {
 	return &PGSearchRepository{db: db}
 }
 
-// bookSearchSQL ranks candidates lexicographically and returns one page of
-// IDs together with the exact pre-pagination total and the query correlation
-// hash. Lanes, from strongest to weakest:
+// bookRankingSQL admits and ranks the candidates of a book search
+// lexicographically, up to the ranked CTE; bookSearchSQL pages it and
+// bookFacetSQL counts it. Lanes, from strongest to weakest:
 //
 //  1. exact_match — the normalized title equals the needle;
 //  2. prefix_match — the normalized title starts with the needle;
@@ -55,10 +56,8 @@ func NewPGSearchRepository(db pg.DBI) *PGSearchRepository {
 // scores, so no threshold hides in a Go constant or a session mutation.
 // An ExactBookID pin narrows the candidate set to that one visible book
 // and bypasses the textual lanes. Every candidate is one row of the book
-// table, which makes dedupe by book.id structural. LIMIT/OFFSET apply
-// only to the fully ranked page; meta carries the total over the whole
-// admitted set and always produces a row, even with zero candidates.
-const bookSearchSQL = `
+// table, which makes dedupe by book.id structural.
+const bookRankingSQL = `
 WITH q AS (
     SELECT
         ?::text AS raw,
@@ -101,9 +100,17 @@ visible AS NOT MATERIALIZED (
                 -- models.MatchStatusManual; anything else (ignored,
                 -- pending) is not membership.
                 AND ci.match_status IN ('auto_matched', 'manual')))
+        -- Dates: added on or after a day, published within a year range.
+        -- The year is the first four digits of the free-text docdate, as
+        -- publicationYearSQL reads it; a book without one is outside any
+        -- range.
+        AND (?::timestamptz IS NULL OR b.registerdate >= ?::timestamptz)
+        AND (? = 0 OR substring(b.docdate from '\d{4}')::int >= ?)
+        AND (? = 0 OR substring(b.docdate from '\d{4}')::int <= ?)
         -- AuthorQuery narrows the same request: exact/prefix at any length,
         -- the index-served word-similarity lane from three runes up. It
         -- never falls back to a Go-side author pass.
+        -- An alias or pseudonym narrows to its author's books the same way.
         AND ((SELECT q.author_needle FROM q) IS NULL OR EXISTS (
             SELECT 1 FROM opds_catalog_bauthor ba
             JOIN opds_catalog_author a ON a.id = ba.author_id
@@ -111,7 +118,15 @@ visible AS NOT MATERIALIZED (
                 AND (public.search_normalize(a.full_name) = (SELECT q.author_needle FROM q)
                     OR public.search_normalize(a.full_name) LIKE (SELECT q.author_needle FROM q) || '%'
                     OR (char_length((SELECT q.author_needle FROM q)) >= 3
-                        AND public.search_normalize(a.full_name) %> (SELECT q.author_needle FROM q)))))
+                        AND public.search_normalize(a.full_name) %> (SELECT q.author_needle FROM q))))
+            OR EXISTS (
+            SELECT 1 FROM opds_catalog_bauthor ba
+            JOIN opds_catalog_author_alias al ON al.author_id = ba.author_id
+            WHERE ba.book_id = b.id
+                AND (public.search_normalize(al.alias) = (SELECT q.author_needle FROM q)
+                    OR public.search_normalize(al.alias) LIKE (SELECT q.author_needle FROM q) || '%'
+                    OR (char_length((SELECT q.author_needle FROM q)) >= 3
+                        AND public.search_normalize(al.alias) %> (SELECT q.author_needle FROM q)))))
 ),
 anchor AS (
     -- The longest needle word, used as the word-coverage lane's index qual.
@@ -277,9 +292,12 @@ admitted AS (
         OR s.trigram_score >= 0.30
 ),
 ranked AS (
+    -- A requested sort comes first and the relevance tiers break its ties;
+    -- its key is spliced in by searchBookRows over the book row sb, joined
+    -- only then.
     SELECT a.id,
         row_number() OVER (
-            ORDER BY
+            ORDER BY ?
                 a.exact_match DESC,
                 a.prefix_match DESC,
                 a.word_set_match DESC,
@@ -292,8 +310,14 @@ ranked AS (
                 a.favorite_count DESC,
                 a.id ASC
         ) AS pos
-    FROM admitted a
-),
+    FROM admitted a ?
+)`
+
+// bookSearchSQL returns one page of the ranked IDs together with the exact
+// pre-pagination total and the query correlation hash. LIMIT/OFFSET apply
+// only to the fully ranked page; meta carries the total over the whole
+// admitted set and always produces a row, even with zero candidates.
+const bookSearchSQL = bookRankingSQL + `,
 page AS (
     SELECT r.id, r.pos
     FROM ranked r
@@ -355,23 +379,9 @@ func orderByIDs(ids []int64, books []models.Book) []models.Book {
 func (r *PGSearchRepository) SearchBooks(ctx context.Context, req models.BookSearchRequest) (models.BookSearchPage, error) {
 	page := models.BookSearchPage{Limit: req.Limit, Offset: req.Offset}
 
-	var rows []searchBookRow
-	query := func(q pg.DBI) error {
-		_, err := q.QueryContext(ctx, &rows, bookSearchSQL,
-			req.Query, req.Query, req.AuthorQuery, req.Query, req.Query, req.ExactBookID,
-			req.Unapproved, req.IncludeHidden,
-			req.Language, req.Language, req.Language,
-			req.Favorites, req.UserID,
-			req.AuthorID, req.AuthorID,
-			req.SeriesID, req.SeriesID,
-			req.GenreID, req.GenreID,
-			req.CollectionID, req.CollectionID,
-			req.CuratedCollectionID, req.CuratedCollectionID,
-			req.Limit, req.Offset)
-		return err
-	}
-	if err := r.queryWithBookThreshold(ctx, query); err != nil {
-		return page, preferContextError(ctx, err)
+	rows, err := r.searchBookRows(ctx, req)
+	if err != nil {
+		return page, err
 	}
 
 	ids := make([]int64, 0, len(rows))
@@ -383,25 +393,97 @@ func (r *PGSearchRepository) SearchBooks(ctx context.Context, req models.BookSea
 		}
 	}
 
-	if len(ids) > 0 {
-		var books []models.Book
-		if err := r.db.ModelContext(ctx, &books).
-			Relation("Authors").
-			Relation("Series").
-			Relation("Genres").
-			ColumnExpr("book.*, (SELECT COUNT(*) FROM favorite_books WHERE book_id = book.id) AS favorite_count").
-			Where("book.id IN (?)", pg.In(ids)).
-			Select(); err != nil {
-			return page, preferContextError(ctx, err)
-		}
-		page.Books = orderByIDs(ids, books)
-		if err := r.markCallerFavorites(ctx, req.UserID, page.Books); err != nil {
-			return page, preferContextError(ctx, err)
+	books, err := loadRankedBooks(ctx, r.db, req.UserID, ids)
+	if err != nil {
+		return page, err
+	}
+	page.Books = books
+	return page, nil
+}
+
+// loadRankedBooks loads the books of ranked ids for display, in rank order:
+// authors, series with their numbers, genres, the favorite count and the
+// caller's own mark.
+func loadRankedBooks(ctx context.Context, db pg.DBI, userID int64, ids []int64) ([]models.Book, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	var books []models.Book
+	if err := db.ModelContext(ctx, &books).
+		Relation("Authors").
+		Relation("Series").
+		Relation("Genres").
+		ColumnExpr("book.*, (SELECT COUNT(*) FROM favorite_books WHERE book_id = book.id) AS favorite_count").
+		Where("book.id IN (?)", pg.In(ids)).
+		Select(); err != nil {
+		return nil, preferContextError(ctx, err)
+	}
+	books = orderByIDs(ids, books)
+	if err := markCallerFavorites(ctx, db, userID, books); err != nil {
+		return nil, preferContextError(ctx, err)
+	}
+	populateSeriesNumbersWithDB(db, books)
+	populateRatingsWithDB(db, books)
+	return books, nil
+}
+
+// SearchBookIDs returns the ids of one ranked page without loading the
+// books, for callers that act on the matches rather than show them.
+//
+//nolint:gocritic // takes the request by value like SearchBooks
+func (r *PGSearchRepository) SearchBookIDs(ctx context.Context, req models.BookSearchRequest) ([]int64, error) {
+	rows, err := r.searchBookRows(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]int64, 0, len(rows))
+	for _, row := range rows {
+		if row.ID != nil {
+			ids = append(ids, *row.ID)
 		}
-		populateSeriesNumbersWithDB(r.db, page.Books)
 	}
+	return ids, nil
+}
 
-	return page, nil
+//nolint:gocritic // takes the request by value like SearchBooks
+func (r *PGSearchRepository) searchBookRows(ctx context.Context, req models.BookSearchRequest) ([]searchBookRow, error) {
+	var rows []searchBookRow
+	args := append(bookRankingArgs(req, true), req.Limit, req.Offset)
+	query := func(q pg.DBI) error {
+		_, err := q.QueryContext(ctx, &rows, bookSearchSQL, args...)
+		return err
+	}
+	if err := r.queryWithBookThreshold(ctx, query); err != nil {
+		return nil, preferContextError(ctx, err)
+	}
+	return rows, nil
+}
+
+// bookRankingArgs are the parameters of bookRankingSQL, in order. Without
+// sorted the requested sort is left out and relevance alone ranks.
+//
+//nolint:gocritic // takes the request by value like SearchBooks
+func bookRankingArgs(req models.BookSearchRequest, sorted bool) []interface{} {
+	sortKey, sortJoin := pg.Safe(""), pg.Safe("")
+	if key := bookSortSQL("sb", req.Options.Sort, req.SeriesID); sorted && key != "" {
+		sortKey = pg.Safe(key + ",")
+		sortJoin = pg.Safe("JOIN opds_catalog_book sb ON sb.id = a.id")
+	}
+	return []interface{}{
+		req.Query, req.Query, req.AuthorQuery, req.Query, req.Query, req.ExactBookID,
+		req.Unapproved, req.IncludeHidden,
+		req.Language, req.Language, req.Language,
+		req.Favorites, req.UserID,
+		req.AuthorID, req.AuthorID,
+		req.SeriesID, req.SeriesID,
+		req.GenreID, req.GenreID,
+		req.CollectionID, req.CollectionID,
+		req.CuratedCollectionID, req.CuratedCollectionID,
+		req.Options.AddedSincePtr(), req.Options.AddedSincePtr(),
+		req.Options.YearFrom, req.Options.YearFrom,
+		req.Options.YearTo, req.Options.YearTo,
+		sortKey, sortJoin,
+	}
 }
 
 // queryWithBookThreshold runs fn inside one transaction with the book-search
@@ -434,7 +516,7 @@ func (r *PGSearchRepository) queryWithBookThreshold(ctx context.Context, fn func
 // markCallerFavorites sets Fav on the page books the caller favorited. The
 // global FavoriteCount is part of the hydration query; Fav is caller-specific
 // and is resolved here, for the page IDs only.
-func (r *PGSearchRepository) markCallerFavorites(ctx context.Context, userID int64, books []models.Book) error {
+func markCallerFavorites(ctx context.Context, db pg.DBI, userID int64, books []models.Book) error {
 	if userID == 0 || len(books) == 0 {
 		return nil
 	}
@@ -443,7 +525,7 @@ func (r *PGSearchRepository) markCallerFavorites(ctx context.Context, userID int
 		ids[i] = books[i].ID
 	}
 	var favIDs []int64
-	if _, err := r.db.QueryContext(ctx, &favIDs,
+	if _, err := db.QueryContext(ctx, &favIDs,
 		`SELECT book_id FROM favorite_books WHERE user_id = ? AND book_id IN (?)`,
 		userID, pg.In(ids)); err != nil {
 		return err
@@ -490,6 +572,9 @@ func preferContextError(ctx context.Context, err error) error {
 // because abbreviated names ("Толкин Дж." at 0.360) live between the two
 // floors.
 //
+// Aliases and pseudonyms find their author as well, in the same two ways;
+// an author matched several times keeps its nearest name for the ordering.
+//
 // Ordering runs normalized word distance, then size, then id. Whole-string
 // distance ranks by length as much as by likeness and buried the largest
 // Tolstoy under one-book namesakes; word distance ties on the surname, so
@@ -505,25 +590,37 @@ WITH q AS (
         md5(public.search_normalize(?::text)) AS query_hash
 ),
 matched AS (
-    SELECT a.id, a.full_name
+    SELECT a.id, a.full_name, public.search_normalize(a.full_name) AS norm_name
     FROM opds_catalog_author AS a
     WHERE public.search_normalize(a.full_name) % (SELECT q.needle FROM q)
         OR public.search_normalize(a.full_name) %> (SELECT q.needle FROM q)
+    UNION ALL
+    SELECT a.id, a.full_name, public.search_normalize(al.alias)
+    FROM opds_catalog_author_alias AS al
+    JOIN opds_catalog_author AS a ON a.id = al.author_id
+    WHERE public.search_normalize(al.alias) % (SELECT q.needle FROM q)
+        OR public.search_normalize(al.alias) %> (SELECT q.needle FROM q)
 ),
-counted AS (
-    SELECT m.id, m.full_name, count(b.id) AS books_count
+nearest AS (
+    SELECT m.id, m.full_name,
+        min((SELECT q.needle FROM q) <<-> m.norm_name) AS distance
     FROM matched AS m
+    GROUP BY m.id, m.full_name
+),
+counted AS (
+    SELECT m.id, m.full_name, m.distance, count(b.id) AS books_count
+    FROM nearest AS m
     JOIN opds_catalog_bauthor AS ba ON ba.author_id = m.id
     JOIN opds_catalog_book AS b ON b.id = ba.book_id
         AND b.approved
         AND NOT b.duplicate_hidden
         AND (? = '' OR b.lang = ?)
-    GROUP BY m.id, m.full_name
+    GROUP BY m.id, m.full_name, m.distance
 ),
 page AS (
     SELECT c.id, c.full_name, c.books_count,
         row_number() OVER (
-            ORDER BY (SELECT q.needle FROM q) <<-> public.search_normalize(c.full_name) ASC,
+            ORDER BY c.distance ASC,
                 c.books_count DESC,
                 c.id ASC
         ) AS pos
@@ -580,25 +677,30 @@ func (r *PGSearchRepository) SearchAuthors(ctx context.Context, req models.Autho
 }
 
 // Autocomplete geometry: a single kind answers up to defaultSuggestionLimit
-// rows, while the combined picker halves the same budget between a book shelf
-// and an author shelf so neither lane can shout the other down. The halves are
-// computed rather than named — a constant pair beside the budget is a second
-// source of truth, and it was already wrong for every budget but the default.
+// rows, while the combined picker splits the same budget between the book,
+// author, series and genre shelves so no lane can shout the others down. The
+// shares are computed rather than named — a constant set beside the budget is
+// a second source of truth, and it was already wrong for every budget but the
+// default.
 const (
 	defaultSuggestionLimit = 15
 	suggestionLaneBook     = 1
 	suggestionLaneAuthor   = 2
-
-	// laneCount is how many shelves the combined budget is split between, and
-	// bookLaneRoundUp gives books the odd row.
-	laneCount       = 2
-	bookLaneRoundUp = 1
+	suggestionLaneSeries   = 3
+	suggestionLaneGenre    = 4
 
 	// The suggestion kinds as the picker labels them.
 	suggestionTypeAuthor = "author"
 	suggestionTypeBook   = "book"
+	suggestionTypeSeries = "series"
+	suggestionTypeGenre  = "genre"
 )
 
+// suggestionLaneWeights are the combined picker's shares of its budget, in
+// lane order. Readers type titles and names far more often than they look
+// for a series or a genre, so those two get half a share each.
+var suggestionLaneWeights = [...]int{2, 2, 1, 1}
+
 // suggestionSQL drives the autocomplete picker: the same normalization,
 // visibility and language semantics as the search paths, but a compact list
 // meant to be chosen from, not browsed. Book lanes mirror SearchBooks —
@@ -624,7 +726,12 @@ const (
 // under the reader's language drop out through the inner join, because a
 // suggestion that opens an empty list is a dead end offered as a choice.
 //
-// Both shelves rank strongest signal first with id as the final tiebreak, so
+// The series and genre lanes match names the way the author lane does, a
+// genre by its Russian or its English name, and count only the books of the
+// list the reader stands in: a series they can see nothing of is a dead end
+// too, and the count is what picking it will show.
+//
+// Every shelf ranks strongest signal first with id as the final tiebreak, so
 // two runs of the same keystrokes produce the same picker. meta always
 // produces a row, so an empty picker still carries the correlation hash.
 const suggestionSQL = `
@@ -748,42 +855,144 @@ books_page AS (
     ORDER BY d.pos
     LIMIT ?
 ),
-author_matched AS (
+author_lane AS (
+    SELECT ? IN ('all', 'author') AND (SELECT q.rune_count FROM q) >= 3 AS active
+),
+author_names AS (
+    -- A name and its aliases; the alias rows carry the alias as norm_name so
+    -- a pseudonym ranks as exactly what the reader typed.
     SELECT a.id, a.full_name, public.search_normalize(a.full_name) AS norm_name
     FROM opds_catalog_author AS a
-    WHERE ? IN ('all', 'author')
-        AND (SELECT q.rune_count FROM q) >= 3
+    WHERE (SELECT l.active FROM author_lane AS l)
         AND (public.search_normalize(a.full_name) LIKE '%' || (SELECT q.needle FROM q) || '%'
             OR public.search_normalize(a.full_name) %> (SELECT q.needle FROM q))
+    UNION ALL
+    SELECT a.id, a.full_name, public.search_normalize(al.alias)
+    FROM opds_catalog_author_alias AS al
+    JOIN opds_catalog_author AS a ON a.id = al.author_id
+    WHERE (SELECT l.active FROM author_lane AS l)
+        AND (public.search_normalize(al.alias) LIKE '%' || (SELECT q.needle FROM q) || '%'
+            OR public.search_normalize(al.alias) %> (SELECT q.needle FROM q))
+),
+author_matched AS (
+    -- One row per author, with the best of its matching names.
+    SELECT n.id, n.full_name,
+        bool_or(n.norm_name = (SELECT q.needle FROM q)) AS exact,
+        bool_or(n.norm_name LIKE (SELECT q.needle FROM q) || '%') AS prefix,
+        min((SELECT q.needle FROM q) <<-> n.norm_name) AS distance
+    FROM author_names AS n
+    GROUP BY n.id, n.full_name
 ),
 author_counted AS (
-    SELECT m.id, m.full_name, m.norm_name, count(b.id) AS books_count
+    SELECT m.id, m.full_name, m.exact, m.prefix, m.distance, count(b.id) AS books_count
     FROM author_matched AS m
     JOIN opds_catalog_bauthor AS ba ON ba.author_id = m.id
     JOIN opds_catalog_book AS b ON b.id = ba.book_id
         AND b.approved
         AND NOT b.duplicate_hidden
         AND (?::text = '' OR ?::text = 'all' OR b.lang = ?::text)
-    GROUP BY m.id, m.full_name, m.norm_name
+    GROUP BY m.id, m.full_name, m.exact, m.prefix, m.distance
 ),
 authors_page AS (
     SELECT c.id, c.full_name, c.books_count,
         row_number() OVER (
-            ORDER BY (c.norm_name = (SELECT q.needle FROM q)) DESC,
-                (c.norm_name LIKE (SELECT q.needle FROM q) || '%') DESC,
-                (SELECT q.needle FROM q) <<-> c.norm_name ASC,
+            ORDER BY c.exact DESC,
+                c.prefix DESC,
+                c.distance ASC,
                 c.books_count DESC, c.id ASC
         ) AS pos
     FROM author_counted AS c
     ORDER BY pos
     LIMIT ?
 ),
+series_lane AS (
+    SELECT ? IN ('all', 'series') AND (SELECT q.rune_count FROM q) >= 3 AS active
+),
+series_matched AS (
+    SELECT s.id, s.ser,
+        public.search_normalize(s.ser) = (SELECT q.needle FROM q) AS exact,
+        public.search_normalize(s.ser) LIKE (SELECT q.needle FROM q) || '%' AS prefix,
+        (SELECT q.needle FROM q) <<-> public.search_normalize(s.ser) AS distance
+    FROM opds_catalog_series AS s
+    WHERE (SELECT l.active FROM series_lane AS l)
+        AND (public.search_normalize(s.ser) LIKE '%' || (SELECT q.needle FROM q) || '%'
+            OR public.search_normalize(s.ser) %> (SELECT q.needle FROM q))
+),
+series_counted AS (
+    -- Counted over the list the reader stands in, so a series with nothing
+    -- in it drops out like an author with nothing in their language.
+    SELECT m.id, m.ser, m.exact, m.prefix, m.distance, count(v.id) AS books_count
+    FROM series_matched AS m
+    JOIN opds_catalog_bseries AS bs ON bs.ser_id = m.id
+    JOIN visible_books AS v ON v.id = bs.book_id
+    GROUP BY m.id, m.ser, m.exact, m.prefix, m.distance
+),
+series_page AS (
+    SELECT c.id, c.ser, c.books_count,
+        row_number() OVER (
+            ORDER BY c.exact DESC,
+                c.prefix DESC,
+                c.distance ASC,
+                c.books_count DESC, c.id ASC
+        ) AS pos
+    FROM series_counted AS c
+    ORDER BY pos
+    LIMIT ?
+),
+genre_lane AS (
+    SELECT ? IN ('all', 'genre') AND (SELECT q.rune_count FROM q) >= 3 AS active
+),
+genre_names AS (
+    -- A genre answers to its Russian and its English name and shows the
+    -- first it has, the bare tag when it has neither.
+    SELECT g.id, COALESCE(NULLIF(g.title, ''), g.genre) AS label,
+        public.search_normalize(n.name) AS norm_name
+    FROM opds_catalog_genre AS g
+    CROSS JOIN LATERAL (VALUES (g.title), (g.title_en)) AS n(name)
+    WHERE (SELECT l.active FROM genre_lane AS l)
+        AND n.name <> ''
+        AND (public.search_normalize(n.name) LIKE '%' || (SELECT q.needle FROM q) || '%'
+            OR public.search_normalize(n.name) %> (SELECT q.needle FROM q))
+),
+genre_matched AS (
+    SELECT n.id, n.label,
+        bool_or(n.norm_name = (SELECT q.needle FROM q)) AS exact,
+        bool_or(n.norm_name LIKE (SELECT q.needle FROM q) || '%') AS prefix,
+        min((SELECT q.needle FROM q) <<-> n.norm_name) AS distance
+    FROM genre_names AS n
+    GROUP BY n.id, n.label
+),
+genre_counted AS (
+    SELECT m.id, m.label, m.exact, m.prefix, m.distance, count(v.id) AS books_count
+    FROM genre_matched AS m
+    JOIN opds_catalog_bgenre AS bg ON bg.genre_id = m.id
+    JOIN visible_books AS v ON v.id = bg.book_id
+    GROUP BY m.id, m.label, m.exact, m.prefix, m.distance
+),
+genres_page AS (
+    SELECT c.id, c.label, c.books_count,
+        row_number() OVER (
+            ORDER BY c.exact DESC,
+                c.prefix DESC,
+                c.distance ASC,
+                c.books_count DESC, c.id ASC
+        ) AS pos
+    FROM genre_counted AS c
+    ORDER BY pos
+    LIMIT ?
+),
 combined AS (
     SELECT 1 AS lane, bp.pos, bp.id, bp.title AS value, bp.secondary, bp.copies AS books_count
     FROM books_page AS bp
     UNION ALL
     SELECT 2 AS lane, ap.pos, ap.id, ap.full_name, NULL::text, ap.books_count
     FROM authors_page AS ap
+    UNION ALL
+    SELECT 3 AS lane, sp.pos, sp.id, sp.ser, NULL::text, sp.books_count
+    FROM series_page AS sp
+    UNION ALL
+    SELECT 4 AS lane, gp.pos, gp.id, gp.label, NULL::text, gp.books_count
+    FROM genres_page AS gp
 ),
 meta AS (
     SELECT (SELECT q.query_hash FROM q) AS query_hash
@@ -806,39 +1015,112 @@ type suggestionRow struct {
 	QueryHash  string  `pg:"query_hash"`
 }
 
-// suggestionLaneLimits splits the picker budget between the book and author
-// lanes. The combined kind ignores the caller's limit and takes the fixed
-// shelf sizes; a single kind answers up to its own limit.
-//
-//nolint:gocritic // named results document which lane each number is
-func suggestionLaneLimits(kind models.SuggestionKind, limit int) (books, authors int) {
+// suggestionLanes is the picker budget of each lane.
+type suggestionLanes struct {
+	books, authors, series, genres int
+}
+
+// suggestionLaneLimits splits the picker budget between the lanes when each
+// has rows enough for its share. A single kind answers up to its own limit;
+// the combined kind shares the limit out by suggestionLaneWeights.
+func suggestionLaneLimits(kind models.SuggestionKind, limit int) suggestionLanes {
+	plenty := suggestionLanes{books: math.MaxInt, authors: math.MaxInt, series: math.MaxInt, genres: math.MaxInt}
+	return spendSuggestionBudget(kind, limit, plenty)
+}
+
+// suggestionFetchLimits is how many rows suggestionSQL reads from each lane.
+// The combined kind reads a whole budget from every lane, so that the share
+// of a lane with less to offer — most prefixes name no series and no genre —
+// goes to the lanes that have more.
+func suggestionFetchLimits(kind models.SuggestionKind, limit int) suggestionLanes {
+	switch kind {
+	case models.SuggestionBook, models.SuggestionAuthor, models.SuggestionSeries, models.SuggestionGenre:
+		return suggestionLaneLimits(kind, limit)
+	}
+	if limit <= 0 {
+		limit = defaultSuggestionLimit
+	}
+	return suggestionLanes{books: limit, authors: limit, series: limit, genres: limit}
+}
+
+// spendSuggestionBudget returns how many of the rows found in each lane the
+// picker keeps. A single kind keeps up to its own limit; the combined kind
+// shares the limit out by suggestionLaneWeights, and a lane that found fewer
+// rows than its share keeps them all and hands the rest on to the others,
+// shared out again the same way.
+func spendSuggestionBudget(kind models.SuggestionKind, limit int, found suggestionLanes) suggestionLanes {
 	if limit <= 0 {
 		limit = defaultSuggestionLimit
 	}
 	switch kind {
 	case models.SuggestionBook:
-		return limit, 0
+		return suggestionLanes{books: min(limit, found.books)}
 	case models.SuggestionAuthor:
-		return 0, limit
+		return suggestionLanes{authors: min(limit, found.authors)}
+	case models.SuggestionSeries:
+		return suggestionLanes{series: min(limit, found.series)}
+	case models.SuggestionGenre:
+		return suggestionLanes{genres: min(limit, found.genres)}
 	case models.SuggestionAll:
 		fallthrough
 	default: //nolint:gocritic // the explicit SuggestionAll case documents the exhaustive set
-		// Split rather than fixed shelves: the two used to be constants that
-		// ignored the budget, so a picker asked for five suggestions answered
-		// with fifteen. Rounding up gives books the odd row, which is where the
-		// default fifteen gets its eight and seven — the geometry the picker
-		// was built around is the formula's own answer, not a second copy of it.
-		books = (limit + bookLaneRoundUp) / laneCount
-		return books, limit - books
+		have := [...]int{found.books, found.authors, found.series, found.genres}
+		var kept [len(suggestionLaneWeights)]int
+		var settled [len(suggestionLaneWeights)]bool
+		left := limit
+		for {
+			shares := shareSuggestionBudget(left, settled)
+			short := false
+			for i := range shares {
+				if !settled[i] && have[i] <= shares[i] {
+					kept[i], settled[i], short = have[i], true, true
+					left -= have[i]
+				}
+			}
+			if !short {
+				for i := range shares {
+					if !settled[i] {
+						kept[i] = shares[i]
+					}
+				}
+				return suggestionLanes{books: kept[0], authors: kept[1], series: kept[2], genres: kept[3]}
+			}
+		}
 	}
 }
 
+// shareSuggestionBudget splits budget between the lanes not settled, by
+// suggestionLaneWeights. Each lane in turn takes its share of what is left,
+// rounded up: the earlier lanes get the odd rows, books lead every budget,
+// and the last lane takes the remainder, so the shares always add up to the
+// budget. The default fifteen is five books, five authors, three series and
+// two genres; with no series or genre to offer, eight books and seven
+// authors.
+func shareSuggestionBudget(budget int, settled [len(suggestionLaneWeights)]bool) [len(suggestionLaneWeights)]int {
+	var shares [len(suggestionLaneWeights)]int
+	left, weight := budget, 0
+	for i, w := range suggestionLaneWeights {
+		if !settled[i] {
+			weight += w
+		}
+	}
+	for i, w := range suggestionLaneWeights {
+		if settled[i] {
+			continue
+		}
+		shares[i] = (left*w + weight - 1) / weight
+		left -= shares[i]
+		weight -= w
+	}
+	return shares
+}
+
 // Suggestions returns the autocomplete picker for one prefix. The statement
 // runs inside the book-search threshold transaction so the % lane keeps its
 // 0.5 floor; the author lane deliberately avoids % and is unaffected.
 func (r *PGSearchRepository) Suggestions(ctx context.Context, req models.SuggestionRequest) (models.SuggestionResult, error) {
 	result := models.SuggestionResult{Suggestions: []models.AutocompleteSuggestion{}}
-	bookLimit, authorLimit := suggestionLaneLimits(req.Kind, req.Limit)
+	lanes := suggestionFetchLimits(req.Kind, req.Limit)
 	kind := string(req.Kind)
 
 	var rows []suggestionRow
@@ -853,21 +1135,36 @@ func (r *PGSearchRepository) Suggestions(ctx context.Context, req models.Suggest
 			req.CollectionID, req.CollectionID,
 			req.CuratedCollectionID, req.CuratedCollectionID,
 			kind, kind, kind,
-			bookLimit,
+			lanes.books,
 			kind,
 			req.Language, req.Language, req.Language,
-			authorLimit)
+			lanes.authors,
+			kind, lanes.series,
+			kind, lanes.genres)
 		return err
 	}
 	if err := r.queryWithBookThreshold(ctx, query); err != nil {
 		return result, preferContextError(ctx, err)
 	}
 
+	var found suggestionLanes
+	for _, row := range rows {
+		if row.ID != nil && row.Lane != nil {
+			*suggestionLaneCount(&found, *row.Lane)++
+		}
+	}
+	keep := spendSuggestionBudget(req.Kind, req.Limit, found)
+
 	for _, row := range rows {
 		result.QueryHash = row.QueryHash
 		if row.ID == nil || row.Lane == nil {
 			continue
 		}
+		left := suggestionLaneCount(&keep, *row.Lane)
+		if *left == 0 {
+			continue
+		}
+		*left--
 		suggestion := models.AutocompleteSuggestion{ID: *row.ID}
 		if row.Value != nil {
 			suggestion.Value = *row.Value
@@ -878,12 +1175,31 @@ func (r *PGSearchRepository) Suggestions(ctx context.Context, req models.Suggest
 		if row.BooksCount != nil {
 			suggestion.BooksCount = *row.BooksCount
 		}
-		if *row.Lane == suggestionLaneAuthor {
+		switch *row.Lane {
+		case suggestionLaneAuthor:
 			suggestion.Type = suggestionTypeAuthor
-		} else {
+		case suggestionLaneSeries:
+			suggestion.Type = suggestionTypeSeries
+		case suggestionLaneGenre:
+			suggestion.Type = suggestionTypeGenre
+		default:
 			suggestion.Type = suggestionTypeBook
 		}
 		result.Suggestions = append(result.Suggestions, suggestion)
 	}
 	return result, nil
 }
+
+// suggestionLaneCount is the field of lanes that counts the rows of lane.
+func suggestionLaneCount(lanes *suggestionLanes, lane int) *int {
+	switch lane {
+	case suggestionLaneAuthor:
+		return &lanes.authors
+	case suggestionLaneSeries:
+		return &lanes.series
+	case suggestionLaneGenre:
+		return &lanes.genres
+	default:
+		return &lanes.books
+	}
+}
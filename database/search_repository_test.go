@@ -1247,6 +1247,8 @@ func TestPGSearchRepositorySuggestions(t *testing.T) {
 		})
 	})
 
+	// No series and no genre of the fixture answers to these prefixes, so
+	// their share goes back to the books and the authors.
 	t.Run("the combined kind caps eight books and seven authors", func(t *testing.T) {
 		withSearchFixture(t, func(f *searchFixture) {
 			repo := NewPGSearchRepository(f.tx)
@@ -1352,34 +1354,239 @@ func suggestionIDs(suggestions []models.AutocompleteSuggestion) []int64 {
  * it was harmless: the day a narrower picker is wanted, it would quietly be
  * ignored.
  *
- * The split stays weighted towards books, and the default budget still lands on
- * the eight and seven the picker was built around.
+ * The split stays weighted towards books and authors; series and genres share
+ * what is left, and give it back when they have nothing to show, so a prefix
+ * that names only books and authors still lands on the eight and seven the
+ * picker was built around.
  */
 func TestSuggestionLaneLimitsSpendExactlyTheBudget(t *testing.T) {
-	t.Run("the default budget keeps the shelves it was designed with", func(t *testing.T) {
-		books, authors := suggestionLaneLimits(models.SuggestionAll, 0)
-		assert.Equal(t, defaultSuggestionLimit, books+authors)
-		assert.Equal(t, 8, books)
-		assert.Equal(t, 7, authors)
+	t.Run("the default budget is five, five, three and two", func(t *testing.T) {
+		lanes := suggestionLaneLimits(models.SuggestionAll, 0)
+		assert.Equal(t, suggestionLanes{books: 5, authors: 5, series: 3, genres: 2}, lanes)
 	})
 
 	t.Run("a smaller budget is split, not ignored", func(t *testing.T) {
 		for _, budget := range []int{1, 2, 5, 9} {
-			books, authors := suggestionLaneLimits(models.SuggestionAll, budget)
-			assert.Equal(t, budget, books+authors, "budget %d", budget)
-			assert.GreaterOrEqual(t, books, authors, "books lead the split at %d", budget)
-			assert.GreaterOrEqual(t, authors, 0, "budget %d", budget)
+			lanes := suggestionLaneLimits(models.SuggestionAll, budget)
+			assert.Equal(t, budget, lanes.books+lanes.authors+lanes.series+lanes.genres, "budget %d", budget)
+			assert.GreaterOrEqual(t, lanes.books, lanes.authors, "books lead the split at %d", budget)
+			assert.GreaterOrEqual(t, lanes.authors, lanes.series, "budget %d", budget)
+			assert.GreaterOrEqual(t, lanes.series, lanes.genres, "budget %d", budget)
+			assert.GreaterOrEqual(t, lanes.genres, 0, "budget %d", budget)
 		}
 	})
 
 	// A named kind owns the whole budget: there is no other lane to share with.
 	t.Run("a single kind takes it all", func(t *testing.T) {
-		books, authors := suggestionLaneLimits(models.SuggestionBook, 5)
-		assert.Equal(t, 5, books)
-		assert.Zero(t, authors)
+		assert.Equal(t, suggestionLanes{books: 5}, suggestionLaneLimits(models.SuggestionBook, 5))
+		assert.Equal(t, suggestionLanes{authors: 5}, suggestionLaneLimits(models.SuggestionAuthor, 5))
+		assert.Equal(t, suggestionLanes{series: 5}, suggestionLaneLimits(models.SuggestionSeries, 5))
+		assert.Equal(t, suggestionLanes{genres: 5}, suggestionLaneLimits(models.SuggestionGenre, 5))
+	})
+
+	t.Run("books and authors alone keep eight and seven", func(t *testing.T) {
+		found := suggestionLanes{books: 40, authors: 40}
+		assert.Equal(t, suggestionLanes{books: 8, authors: 7}, spendSuggestionBudget(models.SuggestionAll, 0, found))
+	})
+
+	t.Run("a lane short of its share hands the rest on", func(t *testing.T) {
+		found := suggestionLanes{books: 40, authors: 2, series: 40, genres: 1}
+		lanes := spendSuggestionBudget(models.SuggestionAll, 0, found)
+		assert.Equal(t, 2, lanes.authors)
+		assert.Equal(t, 1, lanes.genres)
+		assert.Equal(t, defaultSuggestionLimit, lanes.books+lanes.authors+lanes.series+lanes.genres)
+		assert.Greater(t, lanes.books, lanes.series, "books still lead")
+	})
+
+	t.Run("fewer rows than the budget are all kept", func(t *testing.T) {
+		found := suggestionLanes{books: 3, authors: 1, series: 2}
+		assert.Equal(t, found, spendSuggestionBudget(models.SuggestionAll, 0, found))
+		assert.Equal(t, suggestionLanes{books: 3}, spendSuggestionBudget(models.SuggestionBook, 0, found))
+	})
 
-		books, authors = suggestionLaneLimits(models.SuggestionAuthor, 5)
-		assert.Zero(t, books)
-		assert.Equal(t, 5, authors)
+	t.Run("the combined kind reads a whole budget from every lane", func(t *testing.T) {
+		assert.Equal(t, suggestionLanes{books: 15, authors: 15, series: 15, genres: 15},
+			suggestionFetchLimits(models.SuggestionAll, 0))
+		assert.Equal(t, suggestionLanes{series: 5}, suggestionFetchLimits(models.SuggestionSeries, 5))
+	})
+}
+
+// TestPGSearchRepositorySortAndDates pins a requested sort over the rank and
+// the date filters on fixture books that differ only in title, publication
+// year, date added, favorites, downloads and ratings. Books without a year or a
+// rating sort last either way, and books without a year fall outside any year range.
+func TestPGSearchRepositorySortAndDates(t *testing.T) {
+	withSearchFixture(t, func(f *searchFixture) {
+		alpha := f.Book("sort-alpha", &fixtureBook{Title: "Сортировка альфа", Approved: true})
+		beta := f.Book("sort-beta", &fixtureBook{Title: "Сортировка бета", Approved: true})
+		gamma := f.Book("sort-gamma", &fixtureBook{Title: "Сортировка гамма", Approved: true})
+		f.exec(`UPDATE opds_catalog_book SET docdate = '1999', registerdate = ? WHERE id = ?`,
+			f.now.AddDate(0, 0, -10), alpha)
+		f.exec(`UPDATE opds_catalog_book SET docdate = '2010-05-01', registerdate = ? WHERE id = ?`,
+			f.now.AddDate(0, 0, -1), beta)
+		f.exec(`UPDATE opds_catalog_book SET docdate = '', downloads = 3 WHERE id = ?`, gamma)
+		f.Favorite(f.UserIDs["reader"], beta)
+		f.exec(`INSERT INTO book_ratings (user_id, book_id, rating) VALUES (?, ?, 5), (?, ?, 2)`,
+			f.UserIDs["reader"], alpha, f.UserIDs["reader"], beta)
+
+		search := func(opts models.BookListOptions) []int64 {
+			t.Helper()
+			page, err := NewPGSearchRepository(f.tx).SearchBooks(context.Background(), models.BookSearchRequest{
+				Query: "Сортировка", UserID: f.UserIDs["reader"], Language: "fx", Options: opts, Limit: 50,
+			})
+			require.NoError(t, err)
+			ids := make([]int64, 0, len(page.Books))
+			for _, b := range page.Books {
+				ids = append(ids, b.ID)
+			}
+			return ids
+		}
+
+		cases := []struct {
+			name string
+			opts models.BookListOptions
+			want []int64
+		}{
+			{"title", models.BookListOptions{Sort: models.BookSort{Key: models.SortTitle}}, []int64{alpha, beta, gamma}},
+			{"title reversed", models.BookListOptions{Sort: models.BookSort{Key: models.SortTitle, Reverse: true}}, []int64{gamma, beta, alpha}},
+			{"year", models.BookListOptions{Sort: models.BookSort{Key: models.SortYear}}, []int64{beta, alpha, gamma}},
+			{"year reversed keeps undated last", models.BookListOptions{Sort: models.BookSort{Key: models.SortYear, Reverse: true}}, []int64{alpha, beta, gamma}},
+			{"added", models.BookListOptions{Sort: models.BookSort{Key: models.SortAdded}}, []int64{gamma, beta, alpha}},
+			{"popular", models.BookListOptions{Sort: models.BookSort{Key: models.SortPopular}}, []int64{gamma, beta, alpha}},
+			{"rating", models.BookListOptions{Sort: models.BookSort{Key: models.SortRating}}, []int64{alpha, beta, gamma}},
+			{"rating reversed keeps unrated last", models.BookListOptions{Sort: models.BookSort{Key: models.SortRating, Reverse: true}}, []int64{beta, alpha, gamma}},
+			{"year range", models.BookListOptions{YearFrom: 2000, YearTo: 2020}, []int64{beta}},
+			{"added since", models.BookListOptions{
+				Sort:       models.BookSort{Key: models.SortTitle},
+				AddedSince: f.now.AddDate(0, 0, -2),
+			}, []int64{beta, gamma}},
+		}
+		for _, tc := range cases {
+			assert.Equal(t, tc.want, search(tc.opts), tc.name)
+		}
+	})
+}
+
+// TestPGSearchRepositorySpellingCandidates reads a query against the search
+// dictionary rebuilt over the fixture: a word the catalogue uses is known, a
+// misspelled one is offered the words near it, and a hidden book's words are
+// not in the dictionary at all.
+func TestPGSearchRepositorySpellingCandidates(t *testing.T) {
+	withSearchFixture(t, func(f *searchFixture) {
+		f.Book("dictionary-visible", &fixtureBook{Title: "Квазиморфология зыбунов", Approved: true})
+		f.Book("dictionary-hidden", &fixtureBook{Title: "Квазиморфография", Approved: true, Hidden: true})
+		// Not CONCURRENTLY: that cannot run inside the fixture transaction.
+		f.exec(`REFRESH MATERIALIZED VIEW public.search_dictionary`)
+
+		words, err := NewPGSearchRepository(f.tx).SpellingCandidates(context.Background(),
+			"Квазиморфалогия зыбунов и", 8)
+		require.NoError(t, err)
+		require.Len(t, words, 3, "one entry a word, in query order")
+
+		assert.Equal(t, "квазиморфалогия", words[0].Token)
+		assert.False(t, words[0].Known)
+		var near []string
+		for _, c := range words[0].Candidates {
+			near = append(near, c.Token)
+		}
+		assert.Contains(t, near, "квазиморфология")
+		assert.NotContains(t, near, "квазиморфография", "hidden books stay out of the dictionary")
+
+		assert.Equal(t, "зыбунов", words[1].Token)
+		assert.True(t, words[1].Known)
+		assert.Empty(t, words[1].Candidates, "a known word is not corrected")
+
+		assert.Equal(t, "и", words[2].Token)
+		assert.Empty(t, words[2].Candidates, "too short to correct")
+	})
+}
+
+// TestPGSearchRepositoryBookFacets counts a search's admitted books by
+// facet, over the fixture's own language so no catalog row can join in.
+func TestPGSearchRepositoryBookFacets(t *testing.T) {
+	withSearchFixture(t, func(f *searchFixture) {
+		author := f.Author("facet-author", "Фасетов Фасет")
+		other := f.Author("facet-other", "Другой Автор")
+		series := f.Series("facet-series", "Фасетная серия")
+		genre := f.Genre("facet-genre", "Фасетный жанр")
+		one := f.Book("facet-1", &fixtureBook{Title: "Фасетная книга первая", Approved: true,
+			Authors: []int64{author}, Series: []int64{series}, Genres: []int64{genre}})
+		two := f.Book("facet-2", &fixtureBook{Title: "Фасетная книга вторая", Approved: true,
+			Authors: []int64{author, other}, Genres: []int64{genre}})
+		f.Book("facet-3", &fixtureBook{Title: "Фасетная книга третья", Approved: true, Authors: []int64{other}})
+		f.Book("facet-hidden", &fixtureBook{Title: "Фасетная книга скрытая", Approved: true, Hidden: true,
+			Authors: []int64{author}})
+		f.exec(`UPDATE opds_catalog_book SET docdate = '1865' WHERE id = ?`, one)
+		f.exec(`UPDATE opds_catalog_book SET docdate = '1869-03-01' WHERE id = ?`, two)
+
+		repo := NewPGSearchRepository(f.tx)
+		req := models.BookSearchRequest{Query: "Фасетная книга", Language: "fx", Limit: 1}
+		facets, err := repo.BookFacets(context.Background(), req, 50, 10)
+		require.NoError(t, err)
+
+		assert.Equal(t, []models.FacetCount{{Value: "fx", Label: "fx", Count: 3}}, facets.Language,
+			"the hidden book is not counted, and the page size does not bound the count")
+		assert.Equal(t, []models.FacetCount{
+			{Value: fmt.Sprint(other), Label: "Другой Автор", Count: 2},
+			{Value: fmt.Sprint(author), Label: "Фасетов Фасет", Count: 2},
+		}, facets.Author, "ties break on the label")
+		assert.Equal(t, []models.FacetCount{{Value: fmt.Sprint(series), Label: "Фасетная серия", Count: 1}}, facets.Series)
+		assert.Equal(t, []models.FacetCount{{Value: fmt.Sprint(genre), Label: "Фасетный жанр", Count: 2}}, facets.Genre)
+		assert.Equal(t, []models.FacetCount{{Value: "1860", Label: "1860", Count: 2}}, facets.Decade,
+			"a book without a year has no decade")
+
+		sampled, err := repo.BookFacets(context.Background(), req, 1, 10)
+		require.NoError(t, err)
+		assert.Equal(t, 1, sampled.Language[0].Count, "only the sample is counted")
+
+		top, err := repo.BookFacets(context.Background(), req, 50, 1)
+		require.NoError(t, err)
+		assert.Len(t, top.Author, 1, "the commonest values only")
+	})
+}
+
+// TestPGSearchRepositorySeriesAndGenreSuggestions pins the series and genre
+// lanes: a genre answers to its English name too, each row counts the books
+// of the list the reader stands in, and a series with nothing in that list
+// is not offered.
+func TestPGSearchRepositorySeriesAndGenreSuggestions(t *testing.T) {
+	withSearchFixture(t, func(f *searchFixture) {
+		series := f.Series("lane-series", "Лунная трилогия")
+		empty := f.Series("lane-empty", "Лунная дорога")
+		genre := f.Genre("lane-genre", "Лунная фантастика")
+		f.exec(`UPDATE opds_catalog_genre SET title_en = 'Moon fiction' WHERE id = ?`, genre)
+		one := f.Book("lane-1", &fixtureBook{Title: "Восход", Approved: true,
+			Series: []int64{series}, Genres: []int64{genre}})
+		f.Book("lane-2", &fixtureBook{Title: "Закат", Approved: true, Series: []int64{series}, Genres: []int64{genre}})
+		f.Book("lane-hidden", &fixtureBook{Title: "Полдень", Approved: true, Hidden: true, Series: []int64{empty}})
+		f.Favorite(f.UserIDs["reader"], one)
+
+		repo := NewPGSearchRepository(f.tx)
+		result, err := repo.Suggestions(context.Background(), models.SuggestionRequest{
+			Query: "лунная", Kind: models.SuggestionAll, Language: "fx",
+		})
+		require.NoError(t, err)
+		assert.Contains(t, result.Suggestions,
+			models.AutocompleteSuggestion{Type: "series", ID: series, Value: "Лунная трилогия", BooksCount: 2})
+		assert.Contains(t, result.Suggestions,
+			models.AutocompleteSuggestion{Type: "genre", ID: genre, Value: "Лунная фантастика", BooksCount: 2})
+		assert.NotContains(t, suggestionIDs(result.Suggestions), empty, "only a hidden book is in it")
+
+		english, err := repo.Suggestions(context.Background(), models.SuggestionRequest{
+			Query: "moon fiction", Kind: models.SuggestionGenre, Language: "fx",
+		})
+		require.NoError(t, err)
+		require.NotEmpty(t, english.Suggestions)
+		assert.Equal(t, genre, english.Suggestions[0].ID)
+		assert.Equal(t, "Лунная фантастика", english.Suggestions[0].Value, "the Russian name is shown")
+
+		favorites, err := repo.Suggestions(context.Background(), models.SuggestionRequest{
+			Query: "лунная", Kind: models.SuggestionSeries, Language: "fx",
+			UserID: f.UserIDs["reader"], Favorites: true,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []models.AutocompleteSuggestion{
+			{Type: "series", ID: series, Value: "Лунная трилогия", BooksCount: 1},
+		}, favorites.Suggestions, "counted over the reader's favorites only")
 	})
 }
@@ -0,0 +1,152 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gopds-api/models"
+
+	"github.com/go-pg/pg/v10"
+)
+
+var (
+	// ErrTwoFactorNotEnrolled reports a user with no TOTP secret, or none
+	// confirmed, as the operation needs.
+	ErrTwoFactorNotEnrolled = errors.New("two-factor authentication is not set up")
+	// ErrTwoFactorEnabled reports an enrolment started over one in use.
+	ErrTwoFactorEnabled = errors.New("two-factor authentication is already enabled")
+)
+
+// GetTOTP returns a user's TOTP secret, confirmed or not.
+func GetTOTP(ctx context.Context, userID int64) (*models.UserTOTP, error) {
+	t := &models.UserTOTP{UserID: userID}
+	err := db.ModelContext(ctx, t).WherePK().Select()
+	if errors.Is(err, pg.ErrNoRows) {
+		return nil, ErrTwoFactorNotEnrolled
+	}
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// TwoFactorEnabled reports whether a user has a confirmed TOTP secret.
+func TwoFactorEnabled(ctx context.Context, userID int64) (bool, error) {
+	return db.ModelContext(ctx, (*models.UserTOTP)(nil)).
+		Where("user_id = ?", userID).
+		Where("confirmed_at IS NOT NULL").
+		Exists()
+}
+
+// SaveTOTPSecret stores the secret of an enrolment just started, replacing
+// one started before and never confirmed. A confirmed secret is left alone.
+func SaveTOTPSecret(ctx context.Context, userID int64, secret string) error {
+	res, err := db.ExecContext(ctx, `
+		INSERT INTO user_totp (user_id, secret) VALUES (?, ?)
+		ON CONFLICT (user_id) DO UPDATE
+			SET secret = EXCLUDED.secret, last_step = 0, created_at = now()
+			WHERE user_totp.confirmed_at IS NULL`, userID, secret)
+	if err != nil {
+		return err
+	}
+	if res.RowsAffected() == 0 {
+		return ErrTwoFactorEnabled
+	}
+	return nil
+}
+
+// ConfirmTOTP puts a user's secret in use, at the step of the code that
+// proved it, along with their recovery codes.
+func ConfirmTOTP(ctx context.Context, userID, step int64, codeHashes []string) error {
+	return db.RunInTransaction(ctx, func(tx *pg.Tx) error {
+		res, err := tx.Model((*models.UserTOTP)(nil)).
+			Set("confirmed_at = ?", time.Now()).
+			Set("last_step = ?", step).
+			Where("user_id = ?", userID).
+			Where("confirmed_at IS NULL").
+			Update()
+		if err != nil {
+			return err
+		}
+		if res.RowsAffected() == 0 {
+			return ErrTwoFactorNotEnrolled
+		}
+		return replaceRecoveryCodes(tx, userID, codeHashes)
+	})
+}
+
+// AdvanceTOTPStep records that a code for step was accepted. It reports
+// false when a code for that step or a later one already was, which makes
+// the check and the record one statement: two logins racing with the same
+// code cannot both succeed.
+func AdvanceTOTPStep(ctx context.Context, userID, step int64) (bool, error) {
+	res, err := db.ModelContext(ctx, (*models.UserTOTP)(nil)).
+		Set("last_step = ?", step).
+		Where("user_id = ?", userID).
+		Where("confirmed_at IS NOT NULL").
+		Where("last_step < ?", step).
+		Update()
+	if err != nil {
+		return false, err
+	}
+	return res.RowsAffected() == 1, nil
+}
+
+// UseRecoveryCode spends a recovery code, reporting false for one the user
+// does not have or has used.
+func UseRecoveryCode(ctx context.Context, userID int64, codeHash string) (bool, error) {
+	res, err := db.ModelContext(ctx, (*models.RecoveryCode)(nil)).
+		Set("used_at = ?", time.Now()).
+		Where("user_id = ?", userID).
+		Where("code_hash = ?", codeHash).
+		Where("used_at IS NULL").
+		Update()
+	if err != nil {
+		return false, err
+	}
+	return res.RowsAffected() == 1, nil
+}
+
+// CountRecoveryCodes returns how many unused recovery codes a user has.
+func CountRecoveryCodes(ctx context.Context, userID int64) (int, error) {
+	return db.ModelContext(ctx, (*models.RecoveryCode)(nil)).
+		Where("user_id = ?", userID).
+		Where("used_at IS NULL").
+		Count()
+}
+
+// ReplaceRecoveryCodes throws away a user's recovery codes, used or not,
+// for new ones.
+func ReplaceRecoveryCodes(ctx context.Context, userID int64, codeHashes []string) error {
+	return db.RunInTransaction(ctx, func(tx *pg.Tx) error {
+		return replaceRecoveryCodes(tx, userID, codeHashes)
+	})
+}
+
+func replaceRecoveryCodes(tx *pg.Tx, userID int64, codeHashes []string) error {
+	if _, err := tx.Model((*models.RecoveryCode)(nil)).Where("user_id = ?", userID).Delete(); err != nil {
+		return err
+	}
+	if len(codeHashes) == 0 {
+		return nil
+	}
+	codes := make([]models.RecoveryCode, len(codeHashes))
+	for i, h := range codeHashes {
+		codes[i] = models.RecoveryCode{UserID: userID, CodeHash: h}
+	}
+	_, err := tx.Model(&codes).Insert()
+	return err
+}
+
+// DeleteTwoFactor removes a user's second factor: the secret and the
+// recovery codes.
+func DeleteTwoFactor(ctx context.Context, userID int64) error {
+	return db.RunInTransaction(ctx, func(tx *pg.Tx) error {
+		if _, err := tx.Model((*models.RecoveryCode)(nil)).Where("user_id = ?", userID).Delete(); err != nil {
+			return err
+		}
+		_, err := tx.Model((*models.UserTOTP)(nil)).Where("user_id = ?", userID).Delete()
+		return err
+	})
+}
@@ -1,6 +1,7 @@
 package database
 
 import (
+	"context"
 	"crypto/sha256"
 	"errors"
 	"fmt"
@@ -43,6 +44,9 @@ func CheckUser(u models.LoginRequest) (bool, models.User, error) {
 		return false, userDB, nil
 	}
 
+	if err := loadUserPermissions(&userDB); err != nil {
+		return false, userDB, err
+	}
 	return true, userDB, nil
 }
 
@@ -140,9 +144,27 @@ func GetUser(u string) (models.User, error) {
 
 	userDB.Collections = collections
 
+	if err := loadUserPermissions(userDB); err != nil {
+		return *userDB, err
+	}
+
 	return *userDB, nil
 }
 
+// GetUserByID returns the user with the given id.
+func GetUserByID(ctx context.Context, id int64) (models.User, error) {
+	var user models.User
+	err := db.ModelContext(ctx, &user).Where("id = ?", id).Select()
+	return user, err
+}
+
+// GetInvite returns the invite with the given id.
+func GetInvite(ctx context.Context, id int64) (models.Invite, error) {
+	invite := models.Invite{ID: id}
+	err := db.ModelContext(ctx, &invite).WherePK().Select()
+	return invite, err
+}
+
 // GetUserList function returns an users list
 func GetUserList(filters models.UserFilters) ([]models.User, int, error) {
 	users := []models.User{}
@@ -213,6 +235,9 @@ func UpdateUserProfile(userID int64, updates models.SelfUserChangeRequest) (mode
 	if _, err := db.Model(&user).WherePK().Update(); err != nil {
 		return user, err
 	}
+	if err := loadUserPermissions(&user); err != nil {
+		return user, err
+	}
 	return user, nil
 }
 
@@ -388,6 +413,20 @@ func DeleteUser(id string) error {
 		return err
 	}
 
+	// Ratings and reviews the user gave. Reviews they moderated stay, with
+	// the moderator cleared by the foreign key.
+	if _, err = tx.Model((*models.BookRating)(nil)).
+		Where("user_id = ?", id).
+		Delete(); err != nil {
+		return err
+	}
+
+	// Votes on book requests. The requests they made stay for the others
+	// who asked, with the requester cleared by the foreign key.
+	if _, err = tx.Exec(`DELETE FROM book_request_votes WHERE user_id = ?`, id); err != nil {
+		return err
+	}
+
 	if _, err = tx.Model(&models.User{}).Where("id = ?", id).Delete(); err != nil {
 		return err
 	}
@@ -441,6 +480,17 @@ func ClearBotToken(userID int64) error {
 	return err
 }
 
+// RemoveUserBot takes a user's Telegram bot down and forgets it: the bot
+// token and the Telegram account linked through it.
+func RemoveUserBot(user models.User) error {
+	if user.BotToken != "" {
+		if err := removeBotFromManagerSync(user.BotToken); err != nil {
+			logging.Errorf("Failed to remove bot of user %d: %v", user.ID, err)
+		}
+	}
+	return ClearBotToken(user.ID)
+}
+
 // GetUserByWebhookUUID finds user by webhook UUID
 func GetUserByWebhookUUID(webhookUUID string) (models.User, error) {
 	var user models.User
@@ -84,9 +84,28 @@ func TestDeleteUserRemovesWhatBelongsToThem(t *testing.T) {
 	); err != nil {
 		t.Fatalf("adding a book to the collection: %v", err)
 	}
+	if _, err := db.Exec(
+		`INSERT INTO book_ratings (user_id, book_id, rating, review, review_status)
+		SELECT ?, id, 4, 'Good', 'pending' FROM opds_catalog_book LIMIT 1`, id,
+	); err != nil {
+		t.Fatalf("adding a rating: %v", err)
+	}
+
+	var requestID int64
+	if _, err := db.QueryOne(pg.Scan(&requestID),
+		`INSERT INTO book_requests (user_id, title, title_norm, author_norm) VALUES (?, 'Wanted', ?, '') RETURNING id`,
+		id, fmt.Sprintf("wanted %d", stamp),
+	); err != nil {
+		t.Fatalf("adding a book request: %v", err)
+	}
+	if _, err := db.Exec(
+		`INSERT INTO book_request_votes (request_id, user_id) VALUES (?, ?)`, requestID, id,
+	); err != nil {
+		t.Fatalf("adding a book request vote: %v", err)
+	}
 
 	if err := DeleteUser(fmt.Sprint(id)); err != nil {
-		t.Fatalf("deleting a user with favorites, votes and a collection: %v", err)
+		t.Fatalf("deleting a user with favorites, votes, a collection, a rating and a request: %v", err)
 	}
 
 	for _, check := range []struct {
@@ -99,11 +118,16 @@ func TestDeleteUserRemovesWhatBelongsToThem(t *testing.T) {
 		{"collection_votes", "user_id = ?", id},
 		{"book_collections", "user_id = ?", id},
 		{"book_collection_books", "book_collection_id = ?", collectionID},
+		{"book_ratings", "user_id = ?", id},
+		{"book_request_votes", "user_id = ?", id},
 	} {
 		if n := countRows(t, check.table, check.where, check.arg); n != 0 {
 			t.Errorf("%s still holds %d row(s) for the deleted user", check.table, n)
 		}
 	}
+	if n := countRows(t, "book_requests", "id = ? AND user_id IS NULL", requestID); n != 1 {
+		t.Errorf("the user's book request should stay without its requester, found %d", n)
+	}
 }
 
 func TestDeleteUserLeavesTheLibraryAlone(t *testing.T) {
@@ -126,6 +150,13 @@ func TestDeleteUserLeavesTheLibraryAlone(t *testing.T) {
 			t.Fatalf("adding a vote: %v", err)
 		}
 	}
+	// And approved the other reader's review.
+	if _, err := db.Exec(
+		`INSERT INTO book_ratings (user_id, book_id, rating, review, review_status, moderated_by)
+		SELECT ?, id, 5, 'Loved it', 'approved', ? FROM opds_catalog_book LIMIT 1`, other, id,
+	); err != nil {
+		t.Fatalf("adding a moderated review: %v", err)
+	}
 
 	if err := DeleteUser(fmt.Sprint(id)); err != nil {
 		t.Fatalf("deleting the user: %v", err)
@@ -140,6 +171,9 @@ func TestDeleteUserLeavesTheLibraryAlone(t *testing.T) {
 	if n := countRows(t, "auth_user", "id = ?", other); n != 1 {
 		t.Error("another reader was removed")
 	}
+	if n := countRows(t, "book_ratings", "user_id = ? AND review_status = 'approved' AND moderated_by IS NULL", other); n != 1 {
+		t.Error("a review the deleted user moderated did not stay approved")
+	}
 
 	// Only the departing user's votes go.
 	if n := countRows(t, "collection_votes", "user_id = ?", id); n != 0 {
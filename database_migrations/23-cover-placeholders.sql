@@ -0,0 +1,16 @@
+-- Cover placeholders: a BlurHash and a dominant colour a client paints while
+-- the cover itself loads.
+--
+-- NULL means not computed yet; the backfill at startup fills those in for
+-- books with a cover. An empty string means it was tried and the poster could
+-- not be read, so the backfill does not try it again on every start.
+SET LOCAL lock_timeout = '5s';
+
+ALTER TABLE public.opds_catalog_book
+    ADD COLUMN IF NOT EXISTS cover_blurhash VARCHAR(64);
+ALTER TABLE public.opds_catalog_book
+    ADD COLUMN IF NOT EXISTS cover_color VARCHAR(7);
+
+CREATE INDEX IF NOT EXISTS idx_book_cover_placeholder_pending
+    ON public.opds_catalog_book (id)
+    WHERE cover AND cover_blurhash IS NULL;
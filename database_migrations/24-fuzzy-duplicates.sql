@@ -0,0 +1,30 @@
+-- Near-duplicate detection.
+--
+-- text_simhash is a 64-bit SimHash of the opening of the book's body, so two
+-- editions of one text — different OCR, other images, another FB2 program —
+-- land a few bits apart while their MD5s have nothing in common. NULL means
+-- not computed yet; 0 means the book had no body text to hash.
+SET LOCAL lock_timeout = '5s';
+
+ALTER TABLE public.opds_catalog_book
+    ADD COLUMN IF NOT EXISTS text_simhash BIGINT;
+
+-- Candidate groups a duplicate scan proposes and an admin reviews. A group is
+-- a set of books with the score of its weakest link; resolving it hides every
+-- book but the winner, dismissing it keeps the next scan from proposing the
+-- same set again.
+CREATE TABLE IF NOT EXISTS public.book_duplicate_candidates (
+    id          BIGSERIAL PRIMARY KEY,
+    job_id      INTEGER REFERENCES public.admin_scan_jobs (id) ON DELETE SET NULL,
+    match_key   TEXT NOT NULL,
+    score       REAL NOT NULL,
+    book_ids    BIGINT[] NOT NULL,
+    status      VARCHAR(16) NOT NULL DEFAULT 'pending'
+        CHECK (status IN ('pending', 'resolved', 'dismissed')),
+    winner_id   BIGINT,
+    created_at  TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    reviewed_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS book_duplicate_candidates_status_idx
+    ON public.book_duplicate_candidates (status, score DESC);
@@ -0,0 +1,49 @@
+-- Author aliases, pseudonyms and merges.
+--
+-- Authors are created from whatever spelling a file carries, so one writer
+-- ends up as several rows. An alias maps another spelling, or a pseudonym, to
+-- the author row that stands for the person: scanning links a book credited
+-- under the alias to that author, and search finds the author by it.
+SET LOCAL lock_timeout = '5s';
+
+CREATE TABLE IF NOT EXISTS public.opds_catalog_author_alias (
+    id         BIGSERIAL PRIMARY KEY,
+    author_id  INTEGER NOT NULL REFERENCES public.opds_catalog_author (id) ON DELETE CASCADE,
+    alias      VARCHAR(128) NOT NULL,
+    kind       VARCHAR(16) NOT NULL DEFAULT 'alias'
+        CHECK (kind IN ('alias', 'pseudonym')),
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- One spelling names one author; the lookup at scan time goes through this.
+CREATE UNIQUE INDEX IF NOT EXISTS idx_author_alias_search_norm
+    ON public.opds_catalog_author_alias (public.search_normalize(alias));
+
+-- The search lanes match aliases the way they match names, see migration 22.
+CREATE INDEX IF NOT EXISTS idx_author_alias_search_norm_trgm
+    ON public.opds_catalog_author_alias
+    USING gin (public.search_normalize(alias) gin_trgm_ops);
+
+CREATE INDEX IF NOT EXISTS idx_author_alias_author_id
+    ON public.opds_catalog_author_alias (author_id);
+
+-- The audit trail of merges, and what undoing one needs: the merged-away
+-- authors as they were, the book links moved or dropped, the aliases moved
+-- and created, and the target's name before a rename. No foreign key on the
+-- authors: the sources are gone by design, and the record has to outlive
+-- them to bring them back.
+CREATE TABLE IF NOT EXISTS public.author_merges (
+    id                BIGSERIAL PRIMARY KEY,
+    target_id         INTEGER NOT NULL,
+    target_old_name   VARCHAR(128) NOT NULL,
+    target_new_name   VARCHAR(128) NOT NULL,
+    sources           JSONB NOT NULL,
+    created_alias_ids BIGINT[] NOT NULL DEFAULT '{}',
+    merged_by         INTEGER REFERENCES public.auth_user (id) ON DELETE SET NULL,
+    created_at        TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    undone_by         INTEGER REFERENCES public.auth_user (id) ON DELETE SET NULL,
+    undone_at         TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS idx_author_merges_target
+    ON public.author_merges (target_id);
@@ -0,0 +1,22 @@
+-- INPX catalogue import.
+--
+-- lib_id is the book's id in the library the dump came from, as the INPX
+-- index gives it; NULL for books scanned without one. lib_deleted follows the
+-- index's deleted flag: a book the library withdraws is unapproved rather
+-- than removed, and approved again should a later index restore it.
+SET LOCAL lock_timeout = '5s';
+
+ALTER TABLE public.opds_catalog_book
+    ADD COLUMN IF NOT EXISTS lib_id VARCHAR(32),
+    ADD COLUMN IF NOT EXISTS lib_deleted BOOLEAN NOT NULL DEFAULT false;
+
+CREATE INDEX IF NOT EXISTS opds_catalog_book_lib_id_idx
+    ON public.opds_catalog_book (lib_id)
+    WHERE lib_id IS NOT NULL;
+
+-- The CRC and size of the .inp an archive was last imported from. A new
+-- catalogue re-imports only the archives whose .inp no longer matches.
+ALTER TABLE public.opds_catalog_catalog
+    ADD COLUMN IF NOT EXISTS inpx_checksum VARCHAR(32);
+
+COMMENT ON COLUMN public.opds_catalog_catalog.inpx_checksum IS 'CRC and size of the INPX .inp the archive was last imported from';
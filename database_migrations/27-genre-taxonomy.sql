@@ -0,0 +1,39 @@
+-- Genre taxonomy.
+--
+-- opds_catalog_genre held one row per raw FB2 tag. Genres now belong to
+-- sections (the built-in FB2 2.1 tree is seeded at startup, admins edit it
+-- afterwards), carry an English name next to the Russian one, and aliases
+-- map legacy or misspelled tags found in files to the genre they mean.
+SET LOCAL lock_timeout = '5s';
+
+CREATE TABLE IF NOT EXISTS public.genre_sections (
+    id       SERIAL PRIMARY KEY,
+    code     VARCHAR(64) NOT NULL,
+    title    VARCHAR(128) NOT NULL,
+    title_en VARCHAR(128) NOT NULL DEFAULT '',
+    position INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_genre_sections_code
+    ON public.genre_sections (code);
+
+-- title predates this migration on most installs; added here for the rest.
+ALTER TABLE public.opds_catalog_genre
+    ADD COLUMN IF NOT EXISTS title VARCHAR(256) NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS title_en VARCHAR(256) NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS section_id INTEGER
+        REFERENCES public.genre_sections (id) ON DELETE SET NULL,
+    ADD COLUMN IF NOT EXISTS position INTEGER NOT NULL DEFAULT 0;
+
+CREATE INDEX IF NOT EXISTS idx_genre_section_id
+    ON public.opds_catalog_genre (section_id);
+
+-- One tag names one genre; scanning resolves tags through this first.
+CREATE TABLE IF NOT EXISTS public.genre_aliases (
+    alias      VARCHAR(128) PRIMARY KEY,
+    genre_id   INTEGER NOT NULL REFERENCES public.opds_catalog_genre (id) ON DELETE CASCADE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_genre_aliases_genre_id
+    ON public.genre_aliases (genre_id);
@@ -0,0 +1,35 @@
+-- Bulk metadata edits.
+--
+-- An admin patch — language, approval, the hidden flag, genres, series,
+-- authors — applied to many books at once, in the background. Each book the
+-- patch changed gets an undo record holding what it was before, written in
+-- the same transaction as the change, so an edit that was cancelled or died
+-- halfway undoes exactly as far as it got.
+SET LOCAL lock_timeout = '5s';
+
+CREATE TABLE IF NOT EXISTS public.book_bulk_edits (
+    id          BIGSERIAL PRIMARY KEY,
+    status      VARCHAR(16) NOT NULL DEFAULT 'running'
+        CHECK (status IN ('running', 'completed', 'cancelled', 'failed', 'interrupted', 'undoing', 'undone')),
+    selection   JSONB NOT NULL,
+    patch       JSONB NOT NULL,
+    total       INTEGER NOT NULL DEFAULT 0,
+    processed   INTEGER NOT NULL DEFAULT 0,
+    changed     INTEGER NOT NULL DEFAULT 0,
+    failed      INTEGER NOT NULL DEFAULT 0,
+    error       TEXT NOT NULL DEFAULT '',
+    created_by  INTEGER REFERENCES public.auth_user (id) ON DELETE SET NULL,
+    created_at  TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    finished_at TIMESTAMP WITH TIME ZONE,
+    undone_by   INTEGER REFERENCES public.auth_user (id) ON DELETE SET NULL,
+    undone_at   TIMESTAMP WITH TIME ZONE
+);
+
+-- No foreign key on the book: the record says what to put back, and a book
+-- deleted since is simply skipped.
+CREATE TABLE IF NOT EXISTS public.book_bulk_edit_books (
+    edit_id BIGINT NOT NULL REFERENCES public.book_bulk_edits (id) ON DELETE CASCADE,
+    book_id INTEGER NOT NULL,
+    before  JSONB NOT NULL,
+    PRIMARY KEY (edit_id, book_id)
+);
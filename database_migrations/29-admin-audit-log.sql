@@ -0,0 +1,58 @@
+-- Audit log of administrative actions.
+--
+-- One row per admin action that changed something: who did it, what they did
+-- to which object, the fields it changed with their values before and after,
+-- and where the request came from. Rows are only ever added; the retention
+-- sweep deleting the oldest is the one way out.
+SET LOCAL lock_timeout = '5s';
+
+CREATE TABLE IF NOT EXISTS public.admin_audit_log (
+    id          BIGSERIAL PRIMARY KEY,
+    actor_id    INTEGER REFERENCES public.auth_user (id) ON DELETE SET NULL,
+    -- Kept beside the id so the entry still says who once the user is gone.
+    actor_name  VARCHAR(150) NOT NULL DEFAULT '',
+    action      VARCHAR(64) NOT NULL,
+    target_type VARCHAR(32) NOT NULL,
+    target_id   VARCHAR(64) NOT NULL DEFAULT '',
+    -- Changed fields only: {"field": {"before": ..., "after": ...}}.
+    changes     JSONB NOT NULL DEFAULT '{}'::jsonb,
+    ip          VARCHAR(64) NOT NULL DEFAULT '',
+    user_agent  TEXT NOT NULL DEFAULT '',
+    method      VARCHAR(8) NOT NULL DEFAULT '',
+    path        TEXT NOT NULL DEFAULT '',
+    request_id  VARCHAR(64) NOT NULL DEFAULT '',
+    created_at  TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_admin_audit_log_created_at
+    ON public.admin_audit_log (created_at);
+CREATE INDEX IF NOT EXISTS idx_admin_audit_log_actor
+    ON public.admin_audit_log (actor_id, id DESC);
+CREATE INDEX IF NOT EXISTS idx_admin_audit_log_action
+    ON public.admin_audit_log (action, id DESC);
+CREATE INDEX IF NOT EXISTS idx_admin_audit_log_target
+    ON public.admin_audit_log (target_type, target_id, id DESC);
+
+-- An entry, once written, is not rewritten. actor_id going NULL when the
+-- user is deleted is the foreign key's doing and the only change let through.
+CREATE OR REPLACE FUNCTION public.admin_audit_log_append_only()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF NEW.id = OLD.id AND NEW.actor_id IS NULL AND OLD.actor_id IS NOT NULL
+        AND (NEW.actor_name, NEW.action, NEW.target_type, NEW.target_id, NEW.changes,
+             NEW.ip, NEW.user_agent, NEW.method, NEW.path, NEW.request_id, NEW.created_at)
+        IS NOT DISTINCT FROM
+            (OLD.actor_name, OLD.action, OLD.target_type, OLD.target_id, OLD.changes,
+             OLD.ip, OLD.user_agent, OLD.method, OLD.path, OLD.request_id, OLD.created_at)
+    THEN
+        RETURN NEW;
+    END IF;
+    RAISE EXCEPTION 'admin_audit_log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS admin_audit_log_no_update ON public.admin_audit_log;
+CREATE TRIGGER admin_audit_log_no_update
+    BEFORE UPDATE ON public.admin_audit_log
+    FOR EACH ROW
+    EXECUTE FUNCTION public.admin_audit_log_append_only();
@@ -0,0 +1,52 @@
+-- Roles and permissions for admin staff.
+--
+-- is_superuser was all or nothing. A role is a named set of permissions;
+-- a user holds any number of roles, and may use the admin endpoints their
+-- permissions cover. A superuser still may do everything, and is the only
+-- one who may manage what is not covered by a permission.
+--
+-- Permission names are checked by the application, which is where the
+-- endpoints they guard are listed.
+SET LOCAL lock_timeout = '5s';
+
+CREATE TABLE IF NOT EXISTS public.roles (
+    id          SERIAL PRIMARY KEY,
+    name        VARCHAR(32) NOT NULL UNIQUE,
+    description TEXT NOT NULL DEFAULT '',
+    created_at  TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS public.role_permissions (
+    role_id    INTEGER NOT NULL REFERENCES public.roles (id) ON DELETE CASCADE,
+    permission VARCHAR(64) NOT NULL,
+    PRIMARY KEY (role_id, permission)
+);
+
+CREATE TABLE IF NOT EXISTS public.user_roles (
+    user_id    INTEGER NOT NULL REFERENCES public.auth_user (id) ON DELETE CASCADE,
+    role_id    INTEGER NOT NULL REFERENCES public.roles (id) ON DELETE CASCADE,
+    granted_by INTEGER REFERENCES public.auth_user (id) ON DELETE SET NULL,
+    granted_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, role_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_roles_role ON public.user_roles (role_id);
+
+-- The two roles the admin interface was asked for. They are ordinary rows:
+-- an operator may change or drop them.
+INSERT INTO public.roles (name, description) VALUES
+    ('moderator', 'Edits and approves books, resolves duplicates and curated collections'),
+    ('librarian', 'Scans and imports the library and edits books, deletes nothing')
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO public.role_permissions (role_id, permission)
+SELECT r.id, p.permission
+FROM public.roles r
+JOIN (VALUES
+    ('moderator', 'books.edit'),
+    ('moderator', 'books.approve'),
+    ('moderator', 'collections.manage'),
+    ('librarian', 'library.scan'),
+    ('librarian', 'books.edit')
+) AS p (role, permission) ON p.role = r.name
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,21 @@
+-- External identities: accounts at an OpenID Connect provider that log in
+-- as a local user.
+--
+-- An identity is the provider's issuer and its subject for the person,
+-- which together never change and are never reused; the email is kept for
+-- showing which account is linked, and is not used to match anyone. A
+-- user may have several identities, an identity belongs to one user.
+SET LOCAL lock_timeout = '5s';
+
+CREATE TABLE IF NOT EXISTS public.user_identities (
+    id            BIGSERIAL PRIMARY KEY,
+    user_id       INTEGER NOT NULL REFERENCES public.auth_user (id) ON DELETE CASCADE,
+    issuer        TEXT NOT NULL,
+    subject       TEXT NOT NULL,
+    email         TEXT NOT NULL DEFAULT '',
+    created_at    TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_login_at TIMESTAMP WITH TIME ZONE,
+    UNIQUE (issuer, subject)
+);
+
+CREATE INDEX IF NOT EXISTS user_identities_user_idx ON public.user_identities (user_id);
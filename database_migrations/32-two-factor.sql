@@ -0,0 +1,26 @@
+-- Second factor: TOTP secrets and recovery codes.
+--
+-- A secret is written when enrolment starts and counts only once
+-- confirmed_at is set, which happens when the user proves their app has
+-- it. last_step is the time step of the last code accepted; a code for it
+-- or an earlier step is refused, so a code cannot be replayed. The secret
+-- is stored encrypted with a key derived from secret_key.
+--
+-- Recovery codes are stored hashed and are used once each.
+SET LOCAL lock_timeout = '5s';
+
+CREATE TABLE IF NOT EXISTS public.user_totp (
+    user_id      INTEGER PRIMARY KEY REFERENCES public.auth_user (id) ON DELETE CASCADE,
+    secret       TEXT NOT NULL,
+    confirmed_at TIMESTAMP WITH TIME ZONE,
+    last_step    BIGINT NOT NULL DEFAULT 0,
+    created_at   TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS public.user_recovery_codes (
+    id        BIGSERIAL PRIMARY KEY,
+    user_id   INTEGER NOT NULL REFERENCES public.auth_user (id) ON DELETE CASCADE,
+    code_hash TEXT NOT NULL,
+    used_at   TIMESTAMP WITH TIME ZONE,
+    UNIQUE (user_id, code_hash)
+);
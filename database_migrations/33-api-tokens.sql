@@ -0,0 +1,22 @@
+-- Personal access tokens: long-lived, scoped credentials for scripts.
+--
+-- Only a SHA-256 hash of a token is stored; the token itself is shown once,
+-- when it is created. prefix keeps its first characters so that a user can
+-- tell their tokens apart. scopes limit what the token reaches, on top of
+-- what the user may do. A token past expires_at is refused; one with no
+-- expiry lasts until it is revoked, which deletes it.
+SET LOCAL lock_timeout = '5s';
+
+CREATE TABLE IF NOT EXISTS public.api_tokens (
+    id           BIGSERIAL PRIMARY KEY,
+    user_id      INTEGER NOT NULL REFERENCES public.auth_user (id) ON DELETE CASCADE,
+    name         TEXT NOT NULL,
+    prefix       TEXT NOT NULL,
+    token_hash   TEXT NOT NULL UNIQUE,
+    scopes       TEXT[] NOT NULL DEFAULT '{}',
+    expires_at   TIMESTAMP WITH TIME ZONE,
+    last_used_at TIMESTAMP WITH TIME ZONE,
+    created_at   TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS api_tokens_user_id_idx ON public.api_tokens (user_id);
@@ -0,0 +1,13 @@
+-- Download counts, for sorting book lists by popularity.
+--
+-- A book's popularity is its favorites plus its downloads. A download is
+-- counted once when a file is served from its first byte; the later ranges
+-- of a resumed download are not counted again. The registerdate index serves
+-- the "added since" filter and the sort by date added.
+SET LOCAL lock_timeout = '5s';
+
+ALTER TABLE public.opds_catalog_book
+    ADD COLUMN IF NOT EXISTS downloads INTEGER NOT NULL DEFAULT 0;
+
+CREATE INDEX IF NOT EXISTS opds_catalog_book_registerdate_idx
+    ON public.opds_catalog_book (registerdate);
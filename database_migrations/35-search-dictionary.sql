@@ -0,0 +1,35 @@
+-- Search dictionary for "did you mean" suggestions.
+--
+-- Every word of the visible books' titles and of their authors' names, in
+-- the canonical search normalization, with the number of titles and names
+-- it occurs in. A search that finds nothing looks its words up here: a
+-- word the catalogue does not use is matched to the nearest ones that it
+-- does. Words shorter than three runes are left out; they are too short to
+-- correct. The view is refreshed after a scan and once a day; the unique
+-- index lets that run concurrently with searches, the trigram index serves
+-- the nearest-word lookup.
+SET LOCAL lock_timeout = '5s';
+
+CREATE MATERIALIZED VIEW IF NOT EXISTS public.search_dictionary AS
+SELECT w.token, count(*)::int AS frequency
+FROM (
+    SELECT unnest(string_to_array(public.search_normalize(b.title), ' ')) AS token
+    FROM public.opds_catalog_book b
+    WHERE b.approved AND NOT b.duplicate_hidden
+    UNION ALL
+    SELECT unnest(string_to_array(public.search_normalize(a.full_name), ' '))
+    FROM public.opds_catalog_author a
+    WHERE EXISTS (
+        SELECT 1 FROM public.opds_catalog_bauthor ba
+        JOIN public.opds_catalog_book b ON b.id = ba.book_id
+        WHERE ba.author_id = a.id AND b.approved AND NOT b.duplicate_hidden)
+) w
+WHERE char_length(w.token) >= 3
+GROUP BY w.token;
+
+CREATE UNIQUE INDEX IF NOT EXISTS search_dictionary_token_idx
+    ON public.search_dictionary (token);
+
+CREATE INDEX IF NOT EXISTS search_dictionary_token_trgm_idx
+    ON public.search_dictionary
+    USING gist (token gist_trgm_ops);
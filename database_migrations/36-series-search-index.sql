@@ -0,0 +1,11 @@
+-- Series search index over the canonical normalized series name.
+--
+-- The autocomplete picker suggests series the way it suggests authors, by
+-- substring and word similarity; like the author index, the expression must
+-- be the exact predicate expression for either lane to reach it. Genres are
+-- few enough to scan.
+SET LOCAL lock_timeout = '5s';
+
+CREATE INDEX IF NOT EXISTS idx_series_ser_search_norm_trgm
+    ON public.opds_catalog_series
+    USING gin (public.search_normalize(ser) gin_trgm_ops);
@@ -0,0 +1,24 @@
+-- Download history and the genre index, for book recommendations.
+--
+-- A reader's "recommended for you" list starts from their favorites and
+-- from the books they downloaded lately; the download count on the book
+-- says how popular a book is, not who read it, so each download by a
+-- known user is kept here too. The history goes with its user and with its
+-- book. The bgenre index serves the recommendation's genre signal, which
+-- looks at the newest books of a genre only.
+SET LOCAL lock_timeout = '5s';
+
+CREATE TABLE IF NOT EXISTS public.download_history (
+    id            BIGSERIAL PRIMARY KEY,
+    user_id       INTEGER NOT NULL REFERENCES public.auth_user (id) ON DELETE CASCADE,
+    book_id       INTEGER NOT NULL REFERENCES public.opds_catalog_book (id) ON DELETE CASCADE,
+    downloaded_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS download_history_user_idx
+    ON public.download_history (user_id, downloaded_at DESC);
+CREATE INDEX IF NOT EXISTS download_history_book_idx
+    ON public.download_history (book_id);
+
+CREATE INDEX IF NOT EXISTS opds_catalog_bgenre_genre_book_idx
+    ON public.opds_catalog_bgenre (genre_id, book_id DESC);
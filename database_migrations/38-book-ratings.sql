@@ -0,0 +1,31 @@
+-- Ratings and reviews: a reader's one to five stars for a book, with an
+-- optional short review.
+--
+-- A reader rates a book once and may change it. A review is shown to other
+-- readers only once a moderator approved it; writing a new text puts it
+-- back in the queue, changing the stars alone does not. A book's rating is
+-- the average of every rating, reviewed or not. Ratings go with the reader
+-- and with the book; a moderator's account going leaves their decisions.
+SET LOCAL lock_timeout = '5s';
+
+CREATE TABLE IF NOT EXISTS public.book_ratings (
+    id            BIGSERIAL PRIMARY KEY,
+    user_id       INTEGER NOT NULL REFERENCES public.auth_user (id) ON DELETE CASCADE,
+    book_id       INTEGER NOT NULL REFERENCES public.opds_catalog_book (id) ON DELETE CASCADE,
+    rating        SMALLINT NOT NULL CHECK (rating BETWEEN 1 AND 5),
+    review        TEXT NOT NULL DEFAULT '',
+    review_status TEXT NOT NULL DEFAULT 'none'
+        CHECK (review_status IN ('none', 'pending', 'approved', 'rejected')),
+    moderated_by  INTEGER REFERENCES public.auth_user (id) ON DELETE SET NULL,
+    moderated_at  TIMESTAMP WITH TIME ZONE,
+    created_at    TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at    TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (user_id, book_id)
+);
+
+-- The aggregate on every book list and the sort by rating.
+CREATE INDEX IF NOT EXISTS book_ratings_book_idx
+    ON public.book_ratings (book_id) INCLUDE (rating);
+-- The moderation queue, oldest first.
+CREATE INDEX IF NOT EXISTS book_ratings_review_queue_idx
+    ON public.book_ratings (review_status, updated_at);
@@ -0,0 +1,43 @@
+-- Book requests: a title the library does not have, asked for by a reader
+-- and seconded by others.
+--
+-- A request is open until a scan brings in a book it matches, which
+-- fulfils it, or until a moderator dismisses it. There is one open request
+-- per normalised author and title, so asking for a book already asked for
+-- seconds it. Every reader who asked, the first one included, holds a vote;
+-- the votes are how the queue is prioritised and who is told when the book
+-- arrives. A reader's votes go with their account, the request stays.
+SET LOCAL lock_timeout = '5s';
+
+CREATE TABLE IF NOT EXISTS public.book_requests (
+    id           BIGSERIAL PRIMARY KEY,
+    user_id      INTEGER REFERENCES public.auth_user (id) ON DELETE SET NULL,
+    title        TEXT NOT NULL,
+    author       TEXT NOT NULL DEFAULT '',
+    isbn         TEXT NOT NULL DEFAULT '',
+    title_norm   TEXT NOT NULL,
+    author_norm  TEXT NOT NULL,
+    status       TEXT NOT NULL DEFAULT 'open'
+        CHECK (status IN ('open', 'fulfilled', 'dismissed')),
+    book_id      INTEGER REFERENCES public.opds_catalog_book (id) ON DELETE SET NULL,
+    closed_at    TIMESTAMP WITH TIME ZONE,
+    created_at   TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- One open request per book asked for.
+CREATE UNIQUE INDEX IF NOT EXISTS book_requests_open_idx
+    ON public.book_requests (author_norm, title_norm) WHERE status = 'open';
+-- The lists by state, oldest first.
+CREATE INDEX IF NOT EXISTS book_requests_status_idx
+    ON public.book_requests (status, created_at);
+
+CREATE TABLE IF NOT EXISTS public.book_request_votes (
+    request_id BIGINT NOT NULL REFERENCES public.book_requests (id) ON DELETE CASCADE,
+    user_id    INTEGER NOT NULL REFERENCES public.auth_user (id) ON DELETE CASCADE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (request_id, user_id)
+);
+
+-- A reader's own votes, and their removal with the account.
+CREATE INDEX IF NOT EXISTS book_request_votes_user_idx
+    ON public.book_request_votes (user_id);
@@ -0,0 +1,35 @@
+-- Book uploads: FB2 files readers send in, held back until a moderator
+-- decides.
+--
+-- An upload is stored in an inbox archive under the library directory and
+-- registered as an unapproved book at once, so the moderator reviews the
+-- metadata as the catalogue will show it. Approving it approves the book;
+-- rejecting it removes the book and its file, and the upload stays, with its
+-- title and the reason, for the reader who sent it. An uploader's account
+-- going leaves the books they sent.
+SET LOCAL lock_timeout = '5s';
+
+CREATE TABLE IF NOT EXISTS public.book_uploads (
+    id           BIGSERIAL PRIMARY KEY,
+    user_id      INTEGER REFERENCES public.auth_user (id) ON DELETE SET NULL,
+    book_id      INTEGER REFERENCES public.opds_catalog_book (id) ON DELETE SET NULL,
+    file_name    TEXT NOT NULL,
+    size         BIGINT NOT NULL,
+    md5          TEXT NOT NULL,
+    title        TEXT NOT NULL,
+    archive      TEXT NOT NULL,
+    entry        TEXT NOT NULL,
+    status       TEXT NOT NULL DEFAULT 'pending'
+        CHECK (status IN ('pending', 'approved', 'rejected')),
+    reason       TEXT NOT NULL DEFAULT '',
+    moderated_by INTEGER REFERENCES public.auth_user (id) ON DELETE SET NULL,
+    moderated_at TIMESTAMP WITH TIME ZONE,
+    created_at   TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- The moderation queue, oldest first.
+CREATE INDEX IF NOT EXISTS book_uploads_status_idx
+    ON public.book_uploads (status, created_at);
+-- A reader's own uploads and their quota.
+CREATE INDEX IF NOT EXISTS book_uploads_user_idx
+    ON public.book_uploads (user_id, created_at);
@@ -223,6 +223,15 @@ func SendPasswordResetEmail(data SendType) error {
 	return sendEmail(&data, notificationTemplate)
 }
 
+// SendBookRequestFulfilledEmail tells a reader that a book they asked for
+// has arrived. The title of the book that arrived follows the message.
+func SendBookRequestFulfilledEmail(data SendType, bookTitle string) error {
+	wording := resolve(kindBookRequest)
+	wording.apply(&data)
+	data.Message += " " + bookTitle
+	return sendEmail(&data, notificationTemplate)
+}
+
 // notificationTemplate is the only one there is; which email this is comes from
 // the values handed to it.
 const notificationTemplate = "notification.gohtml"
@@ -34,13 +34,14 @@ const (
 // DefaultProductName names an installation that has not named itself.
 const DefaultProductName = "Booksdump"
 
-// The two kinds of email this application sends.
+// The kinds of email this application sends.
 const (
 	kindRegistration = "registration"
 	kindReset        = "reset"
+	kindBookRequest  = "book_request"
 )
 
-// The one line both emails share, per language.
+// The one line every email shares, per language.
 const (
 	linkFallbackRU = "Если кнопка не работает, скопируйте эту ссылку в браузер:"
 	linkFallbackEN = "If the button doesn't work, copy this link into your browser:"
@@ -90,6 +91,17 @@ func builtin(lang, kind, product string) texts {
 				Warning:      "Если вы не запрашивали смену пароля, просто не открывайте ссылку — пароль останется прежним.",
 				Footer:       fmt.Sprintf("Вы получили это письмо, потому что для вашего аккаунта на %s запросили смену пароля.", product),
 			}
+		case kindBookRequest:
+			const heading = "Книга, которую вы просили, появилась"
+			return texts{
+				Subject:      heading,
+				Title:        heading,
+				Message:      "В библиотеке появилась книга, которую вы просили:",
+				Button:       "Найти книгу",
+				Thanks:       fmt.Sprintf("С уважением, команда %s", product),
+				LinkFallback: linkFallbackRU,
+				Footer:       fmt.Sprintf("Вы получили это письмо, потому что просили эту книгу на %s.", product),
+			}
 		}
 	}
 
@@ -117,6 +129,17 @@ func builtin(lang, kind, product string) texts {
 			Warning:      "If you didn't ask to change your password, simply don't open the link — your password stays as it is.",
 			Footer:       fmt.Sprintf("You received this email because a password reset was requested for your %s account.", product),
 		}
+	case kindBookRequest:
+		const heading = "The book you asked for has arrived"
+		return texts{
+			Subject:      heading,
+			Title:        heading,
+			Message:      "The book you asked for is now in the library:",
+			Button:       "Find the book",
+			Thanks:       fmt.Sprintf("Best regards, the %s team", product),
+			LinkFallback: linkFallbackEN,
+			Footer:       fmt.Sprintf("You received this email because you asked for this book on %s.", product),
+		}
 	}
 
 	return texts{}
@@ -80,7 +80,7 @@ func TestLanguageIsReadWithoutCeremony(t *testing.T) {
 // existed, so an instance that had filled none of this in sent an empty
 // subject, an empty heading and a nameless button.
 func TestAnUnconfiguredInstanceStillSendsACompleteEmail(t *testing.T) {
-	for _, kind := range []string{kindRegistration, kindReset} {
+	for _, kind := range []string{kindRegistration, kindReset, kindBookRequest} {
 		t.Run(kind, func(t *testing.T) {
 			got := resolve(kind)
 
@@ -101,6 +101,9 @@ func TestOnlyAResetCarriesAWarning(t *testing.T) {
 	if resolve(kindRegistration).Warning != "" {
 		t.Error("a registration warns about something")
 	}
+	if resolve(kindBookRequest).Warning != "" {
+		t.Error("a fulfilled book request warns about something")
+	}
 	if resolve(kindReset).Warning == "" {
 		t.Error("a password reset warns about nothing")
 	}
@@ -178,7 +181,7 @@ func TestAnUnnamedInstallationFallsBackToADefault(t *testing.T) {
 func TestTheHeadingDoesNotRepeatTheWordmark(t *testing.T) {
 	setConfig(t, map[string]string{"email.language": "en", "email.product_name": "Booksdump"})
 
-	for _, kind := range []string{kindRegistration, kindReset} {
+	for _, kind := range []string{kindRegistration, kindReset, kindBookRequest} {
 		if title := resolve(kind).Title; strings.Contains(title, "Booksdump") {
 			t.Errorf("the %s heading repeats the wordmark: %q", kind, title)
 		}
@@ -0,0 +1,88 @@
+package httputil
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ServeDownload writes content as an attachment named filename.
+// http.ServeContent does the protocol work from there: Content-Length,
+// Accept-Ranges and single or multipart range responses, If-Range, and the
+// If-None-Match and If-Modified-Since checks against etag and modTime.
+func ServeDownload(c *gin.Context, content io.ReadSeeker, filename, contentType, etag string, modTime time.Time) {
+	h := c.Writer.Header()
+	h.Set("Content-Type", contentType)
+	h.Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s", filename))
+	if etag != "" {
+		h.Set("ETag", etag)
+	}
+	http.ServeContent(c.Writer, c.Request, filename, modTime, content)
+}
+
+// NotModified answers a GET or HEAD with 304 when the client already holds
+// the representation identified by etag and modTime, and reports whether it
+// did. It exists so a handler can ask before producing the body: ServeDownload
+// would give the same answer, but only after the book was read or converted.
+//
+// The precedence is RFC 9110's: If-None-Match, when sent, decides alone, and
+// If-Modified-Since is only consulted without it.
+func NotModified(c *gin.Context, etag string, modTime time.Time) bool {
+	r := c.Request
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		if etag == "" || !etagListMatches(inm, etag) {
+			return false
+		}
+	} else {
+		ims := r.Header.Get("If-Modified-Since")
+		if ims == "" || modTime.IsZero() || modTime.Equal(time.Unix(0, 0)) {
+			return false
+		}
+		t, err := http.ParseTime(ims)
+		if err != nil || modTime.Truncate(time.Second).After(t) {
+			return false
+		}
+	}
+
+	h := c.Writer.Header()
+	if etag != "" {
+		h.Set("ETag", etag)
+	}
+	if !modTime.IsZero() {
+		h.Set("Last-Modified", modTime.UTC().Format(http.TimeFormat))
+	}
+	c.Status(http.StatusNotModified)
+	return true
+}
+
+// StartsDownload reports whether a request fetches a file from its first
+// byte: a whole download, or the first part of a ranged one. The later
+// ranges of a resumed download, and HEAD requests, are the same download
+// seen again, so counting downloads counts only these.
+func StartsDownload(c *gin.Context) bool {
+	if c.Request.Method != http.MethodGet {
+		return false
+	}
+	rng := strings.TrimSpace(c.Request.Header.Get("Range"))
+	return rng == "" || strings.HasPrefix(rng, "bytes=0-")
+}
+
+// etagListMatches applies the weak comparison If-None-Match calls for: the
+// W/ prefix is ignored on either side, and "*" matches anything that exists.
+func etagListMatches(list, etag string) bool {
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(list, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,100 @@
+package httputil
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const testETag = `"0123456789abcdef0123456789abcdef-fb2"`
+
+var testModTime = time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+func notModified(t *testing.T, method string, header http.Header) (bool, *httptest.ResponseRecorder) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequestWithContext(t.Context(), method, "/books/1", http.NoBody)
+	for k, v := range header {
+		c.Request.Header[k] = v
+	}
+	answered := NotModified(c, testETag, testModTime)
+	c.Writer.WriteHeaderNow()
+	return answered, recorder
+}
+
+func TestNotModified(t *testing.T) {
+	later := testModTime.Add(time.Hour).Format(http.TimeFormat)
+	earlier := testModTime.Add(-time.Hour).Format(http.TimeFormat)
+
+	cases := []struct {
+		name   string
+		method string
+		header http.Header
+		want   bool
+	}{
+		{"no validators", http.MethodGet, nil, false},
+		{"matching etag", http.MethodGet, http.Header{"If-None-Match": {testETag}}, true},
+		{"matching etag among others", http.MethodHead, http.Header{"If-None-Match": {`"x", ` + testETag}}, true},
+		{"weak form of the etag", http.MethodGet, http.Header{"If-None-Match": {"W/" + testETag}}, true},
+		{"wildcard", http.MethodGet, http.Header{"If-None-Match": {"*"}}, true},
+		{"other etag", http.MethodGet, http.Header{"If-None-Match": {`"other"`}}, false},
+		{"unchanged since", http.MethodGet, http.Header{"If-Modified-Since": {later}}, true},
+		{"changed since", http.MethodGet, http.Header{"If-Modified-Since": {earlier}}, false},
+		{"unparseable date", http.MethodGet, http.Header{"If-Modified-Since": {"yesterday"}}, false},
+		// If-None-Match decides alone: a date that would match does not
+		// rescue a stale ETag.
+		{"etag wins over date", http.MethodGet, http.Header{"If-None-Match": {`"other"`}, "If-Modified-Since": {later}}, false},
+		{"not a read", http.MethodPost, http.Header{"If-None-Match": {testETag}}, false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, w := notModified(t, tc.method, tc.header)
+			if got != tc.want {
+				t.Fatalf("NotModified = %v, want %v", got, tc.want)
+			}
+			if !got {
+				return
+			}
+			if w.Code != http.StatusNotModified {
+				t.Errorf("status = %d, want 304", w.Code)
+			}
+			if w.Header().Get("ETag") != testETag {
+				t.Errorf("a 304 without its ETag: %q", w.Header().Get("ETag"))
+			}
+		})
+	}
+}
+
+func TestStartsDownload(t *testing.T) {
+	cases := []struct {
+		name   string
+		method string
+		rng    string
+		want   bool
+	}{
+		{"whole file", http.MethodGet, "", true},
+		{"first range", http.MethodGet, "bytes=0-1023", true},
+		{"open first range", http.MethodGet, "bytes=0-", true},
+		{"resumed", http.MethodGet, "bytes=1024-", false},
+		{"suffix range", http.MethodGet, "bytes=-500", false},
+		{"head", http.MethodHead, "", false},
+	}
+	gin.SetMode(gin.TestMode)
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequestWithContext(t.Context(), tc.method, "/books/1", http.NoBody)
+			if tc.rng != "" {
+				c.Request.Header.Set("Range", tc.rng)
+			}
+			if got := StartsDownload(c); got != tc.want {
+				t.Fatalf("StartsDownload() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
@@ -18,6 +18,13 @@ import (
 	"gopds-api/internal/parser"
 )
 
+// OutputVersion names the generation of EPUB this package produces. Whatever
+// keeps converted books around carries it in its keys, so bump it whenever a
+// change here alters the bytes of a generated book: files made under the old
+// rules then stop being found instead of being served as if nothing happened.
+// MOBI is built from the EPUB, so it follows the same number.
+const OutputVersion = "1"
+
 // EPUBGenerator builds a valid EPUB 3.0 archive with EPUB 2.0 compatibility.
 // It manages image references, section anchors, and note links during generation.
 type EPUBGenerator struct {
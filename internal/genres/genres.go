@@ -0,0 +1,236 @@
+// Package genres holds the FB2 2.1 genre tree: the genre codes FictionBook
+// defines, grouped in the sections readers browse by, with their Russian and
+// English names, and the legacy and misspelled codes files carry instead.
+// The catalog seeds its genre tables from it; admins edit the copy there.
+package genres
+
+import "strings"
+
+// Genre is one FB2 genre code.
+type Genre struct {
+	Code    string
+	Title   string
+	TitleEn string
+}
+
+// Section is a group of genres, e.g. "Детективы и триллеры".
+type Section struct {
+	Code    string
+	Title   string
+	TitleEn string
+	Genres  []Genre
+}
+
+// Sections is the built-in tree, in browsing order.
+var Sections = []Section{
+	{Code: "sf", Title: "Фантастика", TitleEn: "Science Fiction & Fantasy", Genres: []Genre{
+		{"sf", "Научная фантастика", "Science Fiction"},
+		{"sf_history", "Альтернативная история", "Alternative History"},
+		{"sf_action", "Боевая фантастика", "Action Science Fiction"},
+		{"sf_epic", "Эпическая фантастика", "Epic Science Fiction"},
+		{"sf_heroic", "Героическая фантастика", "Heroic Fantasy"},
+		{"sf_detective", "Детективная фантастика", "Science Fiction Detective"},
+		{"sf_cyberpunk", "Киберпанк", "Cyberpunk"},
+		{"sf_space", "Космическая фантастика", "Space Fiction"},
+		{"sf_social", "Социально-психологическая фантастика", "Social Science Fiction"},
+		{"sf_postapocalyptic", "Постапокалипсис", "Post-Apocalyptic"},
+		{"sf_horror", "Ужасы и мистика", "Horror & Mystic"},
+		{"sf_humor", "Юмористическая фантастика", "Humorous Science Fiction"},
+		{"sf_fantasy", "Фэнтези", "Fantasy"},
+		{"sf_fantasy_city", "Городское фэнтези", "Urban Fantasy"},
+		{"sf_mystic", "Мистика", "Mystic"},
+		{"sf_etc", "Фантастика: прочее", "Other Science Fiction"},
+	}},
+	{Code: "det", Title: "Детективы и триллеры", TitleEn: "Detectives & Thrillers", Genres: []Genre{
+		{"det_classic", "Классический детектив", "Classic Detective"},
+		{"det_police", "Полицейский детектив", "Police Stories"},
+		{"det_action", "Боевик", "Action"},
+		{"det_irony", "Иронический детектив", "Ironical Detective"},
+		{"det_history", "Исторический детектив", "Historical Detective"},
+		{"det_espionage", "Шпионский детектив", "Espionage Detective"},
+		{"det_crime", "Криминальный детектив", "Crime Detective"},
+		{"det_political", "Политический детектив", "Political Detective"},
+		{"det_maniac", "Маньяки", "Maniacs"},
+		{"det_hard", "Крутой детектив", "Hard-boiled Detective"},
+		{"thriller", "Триллер", "Thriller"},
+		{"detective", "Детектив", "Detective"},
+	}},
+	{Code: "prose", Title: "Проза", TitleEn: "Prose", Genres: []Genre{
+		{"prose_classic", "Классическая проза", "Classic Prose"},
+		{"prose_history", "Историческая проза", "Historical Prose"},
+		{"prose_contemporary", "Современная проза", "Contemporary Prose"},
+		{"prose_counter", "Контркультура", "Counterculture"},
+		{"prose_rus_classic", "Русская классическая проза", "Russian Classic Prose"},
+		{"prose_su_classics", "Советская классическая проза", "Soviet Classic Prose"},
+		{"prose_military", "Военная проза", "Military Prose"},
+	}},
+	{Code: "love", Title: "Любовные романы", TitleEn: "Romance", Genres: []Genre{
+		{"love_contemporary", "Современные любовные романы", "Contemporary Romance"},
+		{"love_history", "Исторические любовные романы", "Historical Romance"},
+		{"love_detective", "Остросюжетные любовные романы", "Detective Romance"},
+		{"love_short", "Короткие любовные романы", "Short Romance"},
+		{"love_sf", "Любовное фэнтези", "Romantic Fantasy"},
+		{"love_erotica", "Эротика", "Erotica"},
+	}},
+	{Code: "adv", Title: "Приключения", TitleEn: "Adventure", Genres: []Genre{
+		{"adventure", "Приключения", "Adventure"},
+		{"adv_western", "Вестерн", "Western"},
+		{"adv_history", "Исторические приключения", "Historical Adventure"},
+		{"adv_indian", "Приключения про индейцев", "Indian Adventure"},
+		{"adv_maritime", "Морские приключения", "Maritime Fiction"},
+		{"adv_geo", "Путешествия и география", "Travel & Geography"},
+		{"adv_animal", "Природа и животные", "Nature & Animals"},
+	}},
+	{Code: "child", Title: "Детское", TitleEn: "Children's", Genres: []Genre{
+		{"children", "Детская литература", "Children's Literature"},
+		{"child_tale", "Сказка", "Fairy Tales"},
+		{"child_verse", "Детские стихи", "Children's Verses"},
+		{"child_prose", "Детская проза", "Children's Prose"},
+		{"child_sf", "Детская фантастика", "Children's Science Fiction"},
+		{"child_det", "Детские остросюжетные", "Children's Action"},
+		{"child_adv", "Детские приключения", "Children's Adventure"},
+		{"child_education", "Детская образовательная литература", "Children's Education"},
+	}},
+	{Code: "poetry", Title: "Поэзия и драматургия", TitleEn: "Poetry & Drama", Genres: []Genre{
+		{"poetry", "Поэзия", "Poetry"},
+		{"dramaturgy", "Драматургия", "Drama"},
+	}},
+	{Code: "antique", Title: "Старинное", TitleEn: "Antique Literature", Genres: []Genre{
+		{"antique", "Старинная литература", "Antique Literature"},
+		{"antique_ant", "Античная литература", "Antique"},
+		{"antique_european", "Европейская старинная литература", "European Antique"},
+		{"antique_russian", "Древнерусская литература", "Old Russian"},
+		{"antique_east", "Древневосточная литература", "Old East"},
+		{"antique_myths", "Мифы, легенды, эпос", "Myths, Legends, Epos"},
+	}},
+	{Code: "sci", Title: "Наука, образование", TitleEn: "Science & Education", Genres: []Genre{
+		{"science", "Научная литература", "Science"},
+		{"sci_history", "История", "History"},
+		{"sci_psychology", "Психология", "Psychology"},
+		{"sci_culture", "Культурология", "Cultural Science"},
+		{"sci_religion", "Религиоведение", "Religious Studies"},
+		{"sci_philosophy", "Философия", "Philosophy"},
+		{"sci_politics", "Политика", "Politics"},
+		{"sci_business", "Деловая литература", "Business"},
+		{"sci_juris", "Юриспруденция", "Jurisprudence"},
+		{"sci_linguistic", "Языкознание", "Linguistics"},
+		{"sci_medicine", "Медицина", "Medicine"},
+		{"sci_phys", "Физика", "Physics"},
+		{"sci_math", "Математика", "Mathematics"},
+		{"sci_chem", "Химия", "Chemistry"},
+		{"sci_biology", "Биология", "Biology"},
+		{"sci_tech", "Технические науки", "Technical Sciences"},
+	}},
+	{Code: "comp", Title: "Компьютеры и интернет", TitleEn: "Computers & Internet", Genres: []Genre{
+		{"computers", "Компьютерная литература", "Computers"},
+		{"comp_www", "Интернет", "Internet"},
+		{"comp_programming", "Программирование", "Programming"},
+		{"comp_hard", "Компьютерное железо", "Hardware"},
+		{"comp_soft", "Программы", "Software"},
+		{"comp_db", "Базы данных", "Databases"},
+		{"comp_osnet", "ОС и сети", "OS & Networking"},
+	}},
+	{Code: "ref", Title: "Справочная литература", TitleEn: "Reference", Genres: []Genre{
+		{"reference", "Справочная литература", "Reference"},
+		{"ref_encyc", "Энциклопедии", "Encyclopedias"},
+		{"ref_dict", "Словари", "Dictionaries"},
+		{"ref_ref", "Справочники", "Handbooks"},
+		{"ref_guide", "Руководства", "Guides"},
+	}},
+	{Code: "nonf", Title: "Документальная литература", TitleEn: "Nonfiction", Genres: []Genre{
+		{"nonfiction", "Документальная литература", "Nonfiction"},
+		{"nonf_biography", "Биографии и мемуары", "Biography & Memoirs"},
+		{"nonf_publicism", "Публицистика", "Publicism"},
+		{"nonf_criticism", "Критика", "Criticism"},
+		{"nonf_military", "Военная документалистика", "Military Nonfiction"},
+		{"design", "Искусство и дизайн", "Art & Design"},
+	}},
+	{Code: "religion", Title: "Религия и духовность", TitleEn: "Religion & Spirituality", Genres: []Genre{
+		{"religion", "Религия", "Religion"},
+		{"religion_rel", "Религиозная литература", "Religious Literature"},
+		{"religion_esoterics", "Эзотерика", "Esoterics"},
+		{"religion_self", "Самосовершенствование", "Self-improvement"},
+	}},
+	{Code: "humor", Title: "Юмор", TitleEn: "Humor", Genres: []Genre{
+		{"humor", "Юмор", "Humor"},
+		{"humor_anecdote", "Анекдоты", "Anecdotes"},
+		{"humor_prose", "Юмористическая проза", "Humorous Prose"},
+		{"humor_verse", "Юмористические стихи", "Humorous Verses"},
+	}},
+	{Code: "home", Title: "Дом и семья", TitleEn: "Home & Family", Genres: []Genre{
+		{"home", "Домоводство", "Home"},
+		{"home_cooking", "Кулинария", "Cooking"},
+		{"home_pets", "Домашние животные", "Pets"},
+		{"home_crafts", "Хобби и ремёсла", "Hobbies & Crafts"},
+		{"home_entertain", "Развлечения", "Entertaining"},
+		{"home_health", "Здоровье", "Health"},
+		{"home_garden", "Сад и огород", "Garden"},
+		{"home_diy", "Сделай сам", "Do It Yourself"},
+		{"home_sport", "Спорт", "Sports"},
+		{"home_sex", "Эротика и секс", "Erotica & Sex"},
+	}},
+}
+
+// Aliases maps codes found in the wild — older FB2 tables, other catalogues'
+// spellings, common typos — to the code of the tree they mean.
+var Aliases = map[string]string{
+	"fantasy":                 "sf_fantasy",
+	"science_fiction":         "sf",
+	"sci_fi":                  "sf",
+	"scifi":                   "sf",
+	"horror":                  "sf_horror",
+	"cyberpunk":               "sf_cyberpunk",
+	"sf_postapocalypse":       "sf_postapocalyptic",
+	"sf_fantasy_urban":        "sf_fantasy_city",
+	"love_erotic":             "love_erotica",
+	"romance":                 "love_contemporary",
+	"literature_classics":     "prose_classic",
+	"literature_history":      "prose_history",
+	"literature_rus_classsic": "prose_rus_classic",
+	"literature_rus_classic":  "prose_rus_classic",
+	"literature_su_classics":  "prose_su_classics",
+	"prose_rus_classics":      "prose_rus_classic",
+	"prose_classics":          "prose_classic",
+	"nonf_biographies":        "nonf_biography",
+	"biography":               "nonf_biography",
+	"sci_economy":             "sci_business",
+	"economics":               "sci_business",
+	"history":                 "sci_history",
+	"philosophy":              "sci_philosophy",
+	"psychology":              "sci_psychology",
+	"humour":                  "humor",
+	"poem":                    "poetry",
+	"poems":                   "poetry",
+	"drama":                   "dramaturgy",
+	"child_tales":             "child_tale",
+	"adv_travel":              "adv_geo",
+	"det_spy":                 "det_espionage",
+}
+
+var byCode = func() map[string]Genre {
+	m := make(map[string]Genre)
+	for _, s := range Sections {
+		for _, g := range s.Genres {
+			m[g.Code] = g
+		}
+	}
+	return m
+}()
+
+// Canonical returns the code a tag from a file stands for: lower-cased, with
+// dashes and spaces read as underscores, and an alias resolved. A tag the
+// tree does not know comes back normalized but otherwise as it was.
+func Canonical(tag string) string {
+	code := strings.ToLower(strings.TrimSpace(tag))
+	code = strings.NewReplacer("-", "_", " ", "_").Replace(code)
+	if target, ok := Aliases[code]; ok {
+		return target
+	}
+	return code
+}
+
+// Lookup returns the genre of the tree with the given code.
+func Lookup(code string) (Genre, bool) {
+	g, ok := byCode[code]
+	return g, ok
+}
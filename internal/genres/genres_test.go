@@ -0,0 +1,53 @@
+package genres
+
+import "testing"
+
+func TestTreeCodesAreUnique(t *testing.T) {
+	seen := make(map[string]string)
+	sections := make(map[string]bool)
+	for _, s := range Sections {
+		if sections[s.Code] {
+			t.Errorf("section %q listed twice", s.Code)
+		}
+		sections[s.Code] = true
+		if s.Title == "" || s.TitleEn == "" {
+			t.Errorf("section %q lacks a name", s.Code)
+		}
+		for _, g := range s.Genres {
+			if prev, ok := seen[g.Code]; ok {
+				t.Errorf("genre %q is in both %q and %q", g.Code, prev, s.Code)
+			}
+			seen[g.Code] = s.Code
+			if g.Title == "" || g.TitleEn == "" {
+				t.Errorf("genre %q lacks a name", g.Code)
+			}
+		}
+	}
+}
+
+func TestAliasesPointIntoTheTree(t *testing.T) {
+	for alias, code := range Aliases {
+		if _, ok := Lookup(code); !ok {
+			t.Errorf("alias %q maps to %q, which is not in the tree", alias, code)
+		}
+		if _, ok := Lookup(alias); ok {
+			t.Errorf("alias %q shadows a genre of the tree", alias)
+		}
+	}
+}
+
+func TestCanonical(t *testing.T) {
+	cases := map[string]string{
+		"det_classic":             "det_classic",
+		" SF_Fantasy ":            "sf_fantasy",
+		"sf-fantasy":              "sf_fantasy",
+		"fantasy":                 "sf_fantasy",
+		"literature_rus_classsic": "prose_rus_classic",
+		"custom_tag":              "custom_tag",
+	}
+	for in, want := range cases {
+		if got := Canonical(in); got != want {
+			t.Errorf("Canonical(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
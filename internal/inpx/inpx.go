@@ -0,0 +1,318 @@
+// Package inpx reads the .inpx catalogues that Flibusta and lib.rus.ec dumps
+// ship alongside their archives. An .inpx is a ZIP holding one .inp file per
+// book archive, named after it, plus a few .info files; every line of an .inp
+// describes one book in that archive, its fields separated by 0x04 in the
+// order structure.info gives, or the historical default when it is absent.
+package inpx
+
+import (
+	"archive/zip"
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopds-api/internal/safeio"
+)
+
+// DefaultFields is the field order of an .inp line when the catalogue does
+// not carry a structure.info.
+var DefaultFields = []string{
+	"AUTHOR", "GENRE", "TITLE", "SERIES", "SERNO", "FILE", "SIZE",
+	"LIBID", "DEL", "EXT", "DATE", "LANG", "LIBRATE", "KEYWORDS",
+}
+
+const (
+	fieldSeparator = "\x04"
+	// maxInfoBytes bounds the .info files, a line or two of text each.
+	maxInfoBytes = 64 << 10
+	// maxLineBytes bounds one .inp line; real ones stay under a kilobyte.
+	maxLineBytes = 1 << 20
+)
+
+// ErrNotIndexed is returned for an archive the catalogue has no .inp for.
+var ErrNotIndexed = errors.New("archive is not in the INPX index")
+
+// Author is one author of an .inp line.
+type Author struct {
+	Last   string
+	First  string
+	Middle string
+}
+
+// Name returns the name the way the FB2 scanner spells it: family name first,
+// then the given name, so that a book imported from the index and one parsed
+// from its file land on the same author.
+func (a Author) Name() string {
+	return strings.TrimSpace(strings.Join(strings.Fields(a.Last+" "+a.First), " "))
+}
+
+// Record is one book as the index describes it.
+type Record struct {
+	Archive  string
+	Authors  []Author
+	Genres   []string
+	Title    string
+	Series   string
+	SerNo    int
+	File     string
+	Ext      string
+	Size     int64
+	LibID    string
+	Deleted  bool
+	Date     string
+	Lang     string
+	Keywords string
+}
+
+// FileName is the name of the book's file inside its archive.
+func (r Record) FileName() string {
+	return r.File + "." + r.Ext
+}
+
+// Index is an opened .inpx. Its .inp files are read on demand, one archive at
+// a time, so a catalogue of half a million books does not sit in memory.
+type Index struct {
+	// Collection and Version come from collection.info and version.info and
+	// are empty when the catalogue has none.
+	Collection string
+	Version    string
+
+	zr     *zip.ReadCloser
+	fields []string
+	inps   map[string]*zip.File
+}
+
+// Open opens the .inpx at path.
+func Open(path string) (*Index, error) {
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, fmt.Errorf("open inpx: %w", err)
+	}
+	idx := &Index{zr: zr, fields: DefaultFields, inps: make(map[string]*zip.File)}
+	if err := idx.load(); err != nil {
+		_ = zr.Close()
+		return nil, err
+	}
+	return idx, nil
+}
+
+func (x *Index) load() error {
+	for _, f := range x.zr.File {
+		name := strings.ToLower(path.Base(f.Name))
+		switch {
+		case strings.HasSuffix(name, ".inp"):
+			x.inps[strings.TrimSuffix(path.Base(f.Name), path.Ext(f.Name))+".zip"] = f
+		case name == "structure.info":
+			text, err := readInfo(f)
+			if err != nil {
+				return err
+			}
+			if fields := parseStructure(text); len(fields) > 0 {
+				x.fields = fields
+			}
+		case name == "collection.info":
+			text, err := readInfo(f)
+			if err != nil {
+				return err
+			}
+			x.Collection = firstLine(text)
+		case name == "version.info":
+			text, err := readInfo(f)
+			if err != nil {
+				return err
+			}
+			x.Version = firstLine(text)
+		}
+	}
+	return nil
+}
+
+// Close releases the catalogue file.
+func (x *Index) Close() error {
+	return x.zr.Close()
+}
+
+// Archives returns the names of the archives the catalogue describes, sorted.
+func (x *Index) Archives() []string {
+	names := make([]string, 0, len(x.inps))
+	for name := range x.inps {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Checksum identifies the current description of an archive: the CRC and
+// size of its .inp as the ZIP directory records them, so it costs no read.
+// A new catalogue that leaves an archive's .inp alone leaves its checksum
+// alone too. Empty for an archive the catalogue does not describe.
+func (x *Index) Checksum(archive string) string {
+	f, ok := x.inps[path.Base(archive)]
+	if !ok {
+		return ""
+	}
+	return fmt.Sprintf("%08x-%d", f.CRC32, f.UncompressedSize64)
+}
+
+// Records returns the records of an archive keyed by file name, or
+// ErrNotIndexed. Only the base name of archive is looked at, so a path
+// relative to the library root will do.
+func (x *Index) Records(archive string) (map[string]Record, error) {
+	base := path.Base(strings.ReplaceAll(archive, "\\", "/"))
+	f, ok := x.inps[base]
+	if !ok {
+		return nil, ErrNotIndexed
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return nil, fmt.Errorf("open %s: %w", f.Name, err)
+	}
+	defer func() { _ = rc.Close() }()
+
+	records, err := Parse(rc, base, x.fields)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", f.Name, err)
+	}
+	byFile := make(map[string]Record, len(records))
+	for _, r := range records {
+		// An .inp with a FOLDER column may describe other archives too.
+		if r.Archive != base {
+			continue
+		}
+		byFile[r.FileName()] = r
+	}
+	return byFile, nil
+}
+
+// Parse reads the lines of an .inp, fields in the given order. archive is
+// what records are attributed to unless a FOLDER field says otherwise. Lines
+// without a file name are skipped.
+func Parse(r io.Reader, archive string, fields []string) ([]Record, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64<<10), maxLineBytes)
+	var records []Record
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if line == "" {
+			continue
+		}
+		values := strings.Split(line, fieldSeparator)
+		rec := Record{Archive: archive, Ext: "fb2"}
+		for i, field := range fields {
+			if i >= len(values) {
+				break
+			}
+			setField(&rec, field, strings.TrimSpace(values[i]))
+		}
+		if rec.File == "" {
+			continue
+		}
+		records = append(records, rec)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+func setField(rec *Record, field, value string) {
+	switch field {
+	case "AUTHOR":
+		rec.Authors = parseAuthors(value)
+	case "GENRE":
+		rec.Genres = splitList(value)
+	case "TITLE":
+		rec.Title = value
+	case "SERIES":
+		rec.Series = value
+	case "SERNO":
+		rec.SerNo, _ = strconv.Atoi(value)
+	case "FILE":
+		rec.File = value
+	case "SIZE":
+		rec.Size, _ = strconv.ParseInt(value, 10, 64)
+	case "LIBID":
+		rec.LibID = value
+	case "DEL":
+		rec.Deleted = value == "1"
+	case "EXT":
+		if value != "" {
+			rec.Ext = strings.ToLower(value)
+		}
+	case "DATE":
+		rec.Date = value
+	case "LANG":
+		rec.Lang = strings.ToLower(value)
+	case "KEYWORDS":
+		rec.Keywords = value
+	case "FOLDER":
+		if value != "" {
+			rec.Archive = path.Base(value)
+		}
+	}
+}
+
+// parseAuthors reads "Last,First,Middle:" entries, colon-terminated.
+func parseAuthors(value string) []Author {
+	var authors []Author
+	for _, entry := range splitList(value) {
+		parts := strings.Split(entry, ",")
+		for len(parts) < 3 {
+			parts = append(parts, "")
+		}
+		a := Author{
+			Last:   strings.TrimSpace(parts[0]),
+			First:  strings.TrimSpace(parts[1]),
+			Middle: strings.TrimSpace(parts[2]),
+		}
+		if a.Name() != "" {
+			authors = append(authors, a)
+		}
+	}
+	return authors
+}
+
+// splitList splits a colon-terminated list, dropping empty entries.
+func splitList(value string) []string {
+	var out []string
+	for _, item := range strings.Split(value, ":") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+// parseStructure reads the "AUTHOR;GENRE;TITLE;..." line of structure.info.
+func parseStructure(text string) []string {
+	var fields []string
+	for _, f := range strings.Split(firstLine(text), ";") {
+		if f = strings.ToUpper(strings.TrimSpace(f)); f != "" {
+			fields = append(fields, f)
+		}
+	}
+	return fields
+}
+
+func readInfo(f *zip.File) (string, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return "", fmt.Errorf("open %s: %w", f.Name, err)
+	}
+	defer func() { _ = rc.Close() }()
+	data, err := safeio.ReadAll(rc, maxInfoBytes)
+	if err != nil {
+		return "", fmt.Errorf("read %s: %w", f.Name, err)
+	}
+	return strings.TrimPrefix(string(data), "\ufeff"), nil
+}
+
+func firstLine(text string) string {
+	line, _, _ := strings.Cut(text, "\n")
+	return strings.TrimSpace(line)
+}
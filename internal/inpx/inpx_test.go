@@ -0,0 +1,107 @@
+package inpx
+
+import (
+	"archive/zip"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeInpx writes an .inpx holding the given files at a temporary path.
+func writeInpx(t *testing.T, files map[string]string) string {
+	t.Helper()
+	p := filepath.Join(t.TempDir(), "library.inpx")
+	f, err := os.Create(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(f)
+	for name, body := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func line(fields ...string) string {
+	return strings.Join(fields, "\x04") + "\r\n"
+}
+
+func TestParseReadsTheDefaultLayout(t *testing.T) {
+	inp := line("Стругацкий,Аркадий,Натанович:Стругацкий,Борис,Натанович:", "sf_social:sf:", "Пикник на обочине",
+		"Миры Стругацких", "7", "123456", "340211", "123456", "0", "fb2", "2009-03-01", "RU", "5", "") +
+		line("Автор,Удалённый,:", "prose:", "Снятая книга", "", "", "123457", "1000", "123457", "1", "fb2", "2009-03-02", "ru") +
+		line("", "", "Без файла")
+
+	records, err := Parse(strings.NewReader(inp), "fb2-123456-123457.zip", DefaultFields)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("records = %+v, want two", records)
+	}
+	r := records[0]
+	if r.Title != "Пикник на обочине" || r.Series != "Миры Стругацких" || r.SerNo != 7 {
+		t.Errorf("title/series = %q/%q/%d", r.Title, r.Series, r.SerNo)
+	}
+	if len(r.Authors) != 2 || r.Authors[1].Name() != "Стругацкий Борис" || r.Authors[1].Middle != "Натанович" {
+		t.Errorf("authors = %+v", r.Authors)
+	}
+	if strings.Join(r.Genres, ",") != "sf_social,sf" {
+		t.Errorf("genres = %v", r.Genres)
+	}
+	if r.FileName() != "123456.fb2" || r.Size != 340211 || r.LibID != "123456" || r.Lang != "ru" || r.Deleted {
+		t.Errorf("record = %+v", r)
+	}
+	if !records[1].Deleted {
+		t.Error("DEL=1 was not read as deleted")
+	}
+}
+
+func TestIndexReadsArchivesOnDemand(t *testing.T) {
+	p := writeInpx(t, map[string]string{
+		"structure.info":        "FILE;TITLE;EXT;FOLDER;\r\n",
+		"collection.info":       "Test library\r\nsecond line\r\n",
+		"version.info":          "20261018\r\n",
+		"fb2-000001-000002.inp": line("1", "Первая", "fb2", "") + line("2", "Вторая", "FB2", "fb2-000001-000002.zip") + line("9", "Чужая", "fb2", "fb2-000009.zip"),
+	})
+	idx, err := Open(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = idx.Close() }()
+
+	if idx.Collection != "Test library" || idx.Version != "20261018" {
+		t.Errorf("collection/version = %q/%q", idx.Collection, idx.Version)
+	}
+	if got := idx.Archives(); len(got) != 1 || got[0] != "fb2-000001-000002.zip" {
+		t.Errorf("archives = %v", got)
+	}
+	if idx.Checksum("sub/fb2-000001-000002.zip") == "" || idx.Checksum("other.zip") != "" {
+		t.Error("checksums do not follow the archives the index describes")
+	}
+
+	records, err := idx.Records("sub/fb2-000001-000002.zip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || records["1.fb2"].Title != "Первая" || records["2.fb2"].Title != "Вторая" {
+		t.Errorf("records = %+v", records)
+	}
+	if _, err := idx.Records("missing.zip"); !errors.Is(err, ErrNotIndexed) {
+		t.Errorf("err = %v, want ErrNotIndexed", err)
+	}
+}
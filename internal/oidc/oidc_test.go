@@ -0,0 +1,153 @@
+package oidc
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"gopds-api/internal/oidc/oidctest"
+)
+
+const redirectURL = "https://books.example.com/api/oidc/callback"
+
+func newTestProvider(t *testing.T, secret string) (*oidctest.Server, *Provider) {
+	t.Helper()
+	idp := oidctest.NewServer("gopds", secret)
+	t.Cleanup(idp.Close)
+	return idp, NewProvider(Config{
+		Issuer:       idp.Issuer(),
+		ClientID:     "gopds",
+		ClientSecret: secret,
+		RedirectURL:  redirectURL,
+		Scopes:       []string{"openid", "email", "profile"},
+	})
+}
+
+// login runs the code flow against the mock provider and returns the
+// claims, or the error, the exchange ends with.
+func login(t *testing.T, idp *oidctest.Server, p *Provider, exchangeVerifier string) (*Claims, error) {
+	t.Helper()
+	ctx := context.Background()
+	state, nonce, verifier := NewState(), NewState(), NewState()
+
+	authURL, err := p.AuthCodeURL(ctx, state, nonce, verifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	callback, err := idp.Approve(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := callback.Scheme + "://" + callback.Host + callback.Path; got != redirectURL {
+		t.Fatalf("redirected to %s, want %s", got, redirectURL)
+	}
+	if callback.Query().Get("state") != state {
+		t.Fatalf("state = %q, want %q", callback.Query().Get("state"), state)
+	}
+	if exchangeVerifier == "" {
+		exchangeVerifier = verifier
+	}
+	return p.Exchange(ctx, callback.Query().Get("code"), exchangeVerifier, nonce)
+}
+
+func TestCodeFlow(t *testing.T) {
+	for _, secret := range []string{"s3cret:&=", ""} {
+		idp, p := newTestProvider(t, secret)
+		idp.SetClaims(map[string]any{
+			"email":              "reader@example.com",
+			"email_verified":     "true",
+			"preferred_username": "reader",
+			"groups":             []string{"staff", "readers"},
+		})
+
+		claims, err := login(t, idp, p, "")
+		if err != nil {
+			t.Fatalf("secret %q: %v", secret, err)
+		}
+		if claims.Issuer != idp.Issuer() || claims.Subject != "user-1" {
+			t.Errorf("identity = %s %s", claims.Issuer, claims.Subject)
+		}
+		if claims.Email != "reader@example.com" || !claims.EmailVerified || claims.PreferredUsername != "reader" {
+			t.Errorf("claims = %+v", claims)
+		}
+		if got := claims.Strings("groups"); len(got) != 2 || got[0] != "staff" {
+			t.Errorf("groups = %v", got)
+		}
+	}
+}
+
+func TestAuthCodeURL(t *testing.T) {
+	_, p := newTestProvider(t, "")
+	raw, err := p.AuthCodeURL(context.Background(), "st", "no", "verifier")
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, _ := url.Parse(raw)
+	q := u.Query()
+	if q.Get("code_challenge") != CodeChallenge("verifier") || q.Get("code_challenge_method") != "S256" {
+		t.Errorf("PKCE parameters = %v", q)
+	}
+	if q.Get("scope") != "openid email profile" || q.Get("nonce") != "no" || q.Get("state") != "st" {
+		t.Errorf("query = %v", q)
+	}
+}
+
+func TestExchange_WrongVerifier(t *testing.T) {
+	idp, p := newTestProvider(t, "")
+	if _, err := login(t, idp, p, "not-the-verifier"); !errors.Is(err, ErrExchange) {
+		t.Fatalf("err = %v, want %v", err, ErrExchange)
+	}
+}
+
+func TestVerify_Rejects(t *testing.T) {
+	idp, p := newTestProvider(t, "")
+	past := time.Now().Add(-time.Hour).Unix()
+	tests := []struct {
+		name   string
+		claims map[string]any
+	}{
+		{"wrong nonce", map[string]any{"sub": "u", "nonce": "other"}},
+		{"no nonce", map[string]any{"sub": "u"}},
+		{"wrong audience", map[string]any{"sub": "u", "nonce": "n", "aud": "someone-else"}},
+		{"wrong issuer", map[string]any{"sub": "u", "nonce": "n", "iss": "https://evil.example.com"}},
+		{"expired", map[string]any{"sub": "u", "nonce": "n", "exp": past}},
+		{"no subject", map[string]any{"nonce": "n"}},
+		{"foreign authorized party", map[string]any{"sub": "u", "nonce": "n", "aud": []string{"gopds", "other"}, "azp": "other"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := p.Verify(context.Background(), idp.SignIDToken(tt.claims), "n")
+			if !errors.Is(err, ErrInvalidToken) {
+				t.Fatalf("err = %v, want %v", err, ErrInvalidToken)
+			}
+		})
+	}
+}
+
+func TestVerify_RejectsUnsignedAndSymmetric(t *testing.T) {
+	idp, p := newTestProvider(t, "secret")
+	good := idp.SignIDToken(map[string]any{"sub": "u", "nonce": "n"})
+	if _, err := p.Verify(context.Background(), good, "n"); err != nil {
+		t.Fatalf("a good token was refused: %v", err)
+	}
+
+	// The header of {"alg":"none"} and {"alg":"HS256"}, on the good payload.
+	for _, header := range []string{"eyJhbGciOiJub25lIn0", "eyJhbGciOiJIUzI1NiJ9"} {
+		forged := header + good[strings.Index(good, "."):]
+		if _, err := p.Verify(context.Background(), forged, "n"); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("forged token accepted: %v", err)
+		}
+	}
+}
+
+func TestDiscovery_IssuerMismatch(t *testing.T) {
+	idp := oidctest.NewServer("gopds", "")
+	defer idp.Close()
+	p := NewProvider(Config{Issuer: idp.Issuer() + "/realms/other", ClientID: "gopds"})
+	if _, err := p.AuthCodeURL(context.Background(), "s", "n", "v"); !errors.Is(err, ErrDiscovery) {
+		t.Fatalf("err = %v, want %v", err, ErrDiscovery)
+	}
+}
@@ -1,5 +1,9 @@
 package parser
 
+// IssueParsedWithoutBody is the issue of a book whose metadata parsed only
+// once everything after the description was dropped: its body is damaged.
+const IssueParsedWithoutBody = "parsed_without_body"
+
 // BookFile holds parsed FB2 metadata used during rescans.
 type BookFile struct {
 	Title               string
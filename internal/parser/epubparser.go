@@ -0,0 +1,370 @@
+package parser
+
+import (
+	"archive/zip"
+	"bytes"
+	"cmp"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"strings"
+
+	"golang.org/x/net/html"
+
+	"gopds-api/internal/fb2image"
+	"gopds-api/internal/safeio"
+)
+
+const (
+	// epubContainerPath is where every EPUB names its package document.
+	epubContainerPath = "META-INF/container.xml"
+	// epubMetadataLimit bounds the container and the package document.
+	epubMetadataLimit = 4 << 20
+	// epubDocumentLimit bounds a content document read for the body sample.
+	epubDocumentLimit = 16 << 20
+)
+
+// ErrNotEPUB reports a file that is not a zip with a package document.
+var ErrNotEPUB = errors.New("not an EPUB")
+
+// EPUBParser extracts metadata from EPUB files: the package document the
+// container names, and the text of the first content documents of the spine
+// for the body sample.
+type EPUBParser struct {
+	readCover bool
+}
+
+// NewEPUBParser creates a parser configured to read cover data if requested.
+func NewEPUBParser(readCover bool) *EPUBParser {
+	return &EPUBParser{readCover: readCover}
+}
+
+type epubContainer struct {
+	Rootfiles []struct {
+		FullPath string `xml:"full-path,attr"`
+	} `xml:"rootfiles>rootfile"`
+}
+
+type epubPackage struct {
+	Metadata struct {
+		Titles       []string      `xml:"title"`
+		Creators     []epubCreator `xml:"creator"`
+		Languages    []string      `xml:"language"`
+		Descriptions []string      `xml:"description"`
+		Dates        []string      `xml:"date"`
+		Metas        []epubMeta    `xml:"meta"`
+	} `xml:"metadata"`
+	Manifest []epubItem `xml:"manifest>item"`
+	Spine    []struct {
+		IDRef string `xml:"idref,attr"`
+	} `xml:"spine>itemref"`
+}
+
+// epubCreator is a dc:creator; EPUB 2 puts the role and the sort form in
+// attributes, EPUB 3 in meta elements refining it by id.
+type epubCreator struct {
+	ID     string `xml:"id,attr"`
+	Role   string `xml:"role,attr"`
+	FileAs string `xml:"file-as,attr"`
+	Name   string `xml:",chardata"`
+}
+
+// epubMeta is an EPUB 2 meta (name and content) or an EPUB 3 one
+// (property, the element it refines, and its text).
+type epubMeta struct {
+	ID       string `xml:"id,attr"`
+	Name     string `xml:"name,attr"`
+	Content  string `xml:"content,attr"`
+	Property string `xml:"property,attr"`
+	Refines  string `xml:"refines,attr"`
+	Value    string `xml:",chardata"`
+}
+
+type epubItem struct {
+	ID         string `xml:"id,attr"`
+	Href       string `xml:"href,attr"`
+	MediaType  string `xml:"media-type,attr"`
+	Properties string `xml:"properties,attr"`
+}
+
+// Parse reads the EPUB of the given size from r and returns its metadata.
+func (p *EPUBParser) Parse(r io.ReaderAt, size int64) (*BookFile, error) {
+	archive, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrNotEPUB, err)
+	}
+	files := make(map[string]*zip.File, len(archive.File))
+	for _, f := range archive.File {
+		files[f.Name] = f
+	}
+
+	var container epubContainer
+	if err := decodeEPUBXML(files, epubContainerPath, &container); err != nil {
+		return nil, err
+	}
+	if len(container.Rootfiles) == 0 || container.Rootfiles[0].FullPath == "" {
+		return nil, fmt.Errorf("%w: the container names no package document", ErrNotEPUB)
+	}
+	opfPath := container.Rootfiles[0].FullPath
+	var pkg epubPackage
+	if err := decodeEPUBXML(files, opfPath, &pkg); err != nil {
+		return nil, err
+	}
+
+	md := pkg.Metadata
+	refines := epubRefinements(md.Metas)
+	book := &BookFile{
+		Title:      normalizeNameCase(normalizeWhitespace(sanitizeText(firstOf(md.Titles)))),
+		Authors:    epubAuthors(md.Creators, refines),
+		Series:     epubSeries(md.Metas, refines),
+		Language:   strings.TrimSpace(strings.ToLower(firstOf(md.Languages))),
+		DocDate:    strings.TrimSpace(firstOf(md.Dates)),
+		Annotation: htmlText(firstOf(md.Descriptions), 0),
+		Mimetype:   "epub",
+	}
+
+	dir := path.Dir(opfPath)
+	items := make(map[string]epubItem, len(pkg.Manifest))
+	for _, item := range pkg.Manifest {
+		items[item.ID] = item
+	}
+	var sample strings.Builder
+	for _, ref := range pkg.Spine {
+		if sample.Len() >= bodySampleLimit {
+			break
+		}
+		item, ok := items[ref.IDRef]
+		if !ok {
+			continue
+		}
+		doc, err := readEPUBFile(files, epubHref(dir, item.Href), epubDocumentLimit)
+		if err != nil {
+			continue
+		}
+		if text := htmlText(string(doc), bodySampleLimit-sample.Len()); text != "" {
+			if sample.Len() > 0 {
+				sample.WriteByte(' ')
+			}
+			sample.WriteString(text)
+		}
+	}
+	book.BodySample = strings.TrimSpace(sample.String())
+	book.TextSample = truncateSample(book.Annotation, book.BodySample)
+
+	if p.readCover {
+		book.Cover = epubCover(files, dir, pkg.Manifest, md.Metas)
+	}
+	return book, nil
+}
+
+// decodeEPUBXML unmarshals the XML file at name into v.
+func decodeEPUBXML(files map[string]*zip.File, name string, v any) error {
+	data, err := readEPUBFile(files, name, epubMetadataLimit)
+	if err != nil {
+		return err
+	}
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	decoder.CharsetReader = makeCharsetReader
+	decoder.Strict = false
+	if err := decoder.Decode(v); err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrNotEPUB, name, err)
+	}
+	return nil
+}
+
+// readEPUBFile reads the file at name, at most limit bytes of it.
+func readEPUBFile(files map[string]*zip.File, name string, limit int64) ([]byte, error) {
+	f, ok := files[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: no %s", ErrNotEPUB, name)
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrNotEPUB, name, err)
+	}
+	defer rc.Close()
+	return safeio.ReadAll(rc, limit)
+}
+
+// epubHref resolves a manifest href, relative to the package document in
+// dir, to the name of a file in the archive.
+func epubHref(dir, href string) string {
+	if unescaped, err := url.PathUnescape(href); err == nil {
+		href = unescaped
+	}
+	if i := strings.IndexByte(href, '#'); i >= 0 {
+		href = href[:i]
+	}
+	return path.Join(dir, href)
+}
+
+// epubRefinements indexes the EPUB 3 metas by the id they refine and then
+// by property.
+func epubRefinements(metas []epubMeta) map[string]map[string]string {
+	refines := make(map[string]map[string]string)
+	for _, m := range metas {
+		id := strings.TrimPrefix(m.Refines, "#")
+		if id == "" || m.Property == "" {
+			continue
+		}
+		if refines[id] == nil {
+			refines[id] = make(map[string]string)
+		}
+		refines[id][m.Property] = strings.TrimSpace(m.Value)
+	}
+	return refines
+}
+
+// epubAuthors returns the creators that are authors, named as FB2 names
+// them, family name first.
+func epubAuthors(creators []epubCreator, refines map[string]map[string]string) []Author {
+	var authors []Author
+	for _, c := range creators {
+		role, fileAs := c.Role, c.FileAs
+		if r := refines[c.ID]; r != nil {
+			role = cmp.Or(role, r["role"])
+			fileAs = cmp.Or(fileAs, r["file-as"])
+		}
+		if role != "" && !strings.EqualFold(role, "aut") {
+			continue
+		}
+		if author, ok := epubAuthor(c.Name, fileAs); ok {
+			authors = append(authors, author)
+		}
+	}
+	return authors
+}
+
+// epubAuthor turns a creator into an author. The sort form, "Family,
+// Given", says which name is the family one; without it the last word is.
+func epubAuthor(name, fileAs string) (Author, bool) {
+	if last, first, ok := strings.Cut(fileAs, ","); ok && strings.TrimSpace(last) != "" {
+		last = normalizeNameCase(normalizeWhitespace(last))
+		full := normalizeNameCase(normalizeWhitespace(last + " " + first))
+		return Author{Name: full, Sortkey: last}, true
+	}
+	parts := strings.Fields(normalizeNameCase(normalizeWhitespace(name)))
+	switch len(parts) {
+	case 0:
+		return Author{}, false
+	case 1:
+		return Author{Name: parts[0], Sortkey: parts[0]}, true
+	}
+	last := parts[len(parts)-1]
+	return Author{Name: last + " " + strings.Join(parts[:len(parts)-1], " "), Sortkey: last}, true
+}
+
+// epubSeries reads the series the way calibre writes it, or as an EPUB 3
+// collection of type series.
+func epubSeries(metas []epubMeta, refines map[string]map[string]string) *Series {
+	var series Series
+	for _, m := range metas {
+		switch {
+		case m.Name == "calibre:series":
+			series.Title = strings.TrimSpace(m.Content)
+		case m.Name == "calibre:series_index":
+			series.Index = strings.TrimSpace(m.Content)
+		case m.Property == "belongs-to-collection" && series.Title == "":
+			r := refines[m.ID]
+			if r != nil && r["collection-type"] != "" && r["collection-type"] != "series" {
+				continue
+			}
+			series.Title = strings.TrimSpace(m.Value)
+			if r != nil {
+				series.Index = r["group-position"]
+			}
+		}
+	}
+	if series.Title == "" {
+		return nil
+	}
+	return &series
+}
+
+// epubCover returns the cover image, as the EPUB 3 cover-image property or
+// the EPUB 2 cover meta names it, or nil when there is none a reader can
+// draw.
+func epubCover(files map[string]*zip.File, dir string, manifest []epubItem, metas []epubMeta) []byte {
+	var coverID string
+	for _, m := range metas {
+		if m.Name == "cover" {
+			coverID = m.Content
+		}
+	}
+	var href string
+	for _, item := range manifest {
+		if strings.Contains(" "+item.Properties+" ", " cover-image ") ||
+			(item.ID == coverID && strings.HasPrefix(item.MediaType, "image/")) {
+			href = item.Href
+			break
+		}
+	}
+	if href == "" {
+		return nil
+	}
+	data, err := readEPUBFile(files, epubHref(dir, href), safeio.MaxBookBytes)
+	if err != nil {
+		return nil
+	}
+	// As for an FB2: a cover nothing can draw is dropped for the
+	// placeholder.
+	cover, _, err := fb2image.Normalize(data)
+	if err != nil {
+		return nil
+	}
+	return cover
+}
+
+// htmlText returns the text of an XHTML document or fragment, outside
+// scripts and styles, with its whitespace collapsed; at most limit bytes of
+// it, cut at a word, when limit is positive.
+func htmlText(doc string, limit int) string {
+	var out strings.Builder
+	z := html.NewTokenizer(strings.NewReader(doc))
+	skip := 0
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return strings.TrimSpace(out.String())
+		case html.StartTagToken:
+			if name, _ := z.TagName(); isHiddenElement(name) {
+				skip++
+			}
+		case html.EndTagToken:
+			if name, _ := z.TagName(); isHiddenElement(name) && skip > 0 {
+				skip--
+			}
+		case html.TextToken:
+			if skip > 0 {
+				continue
+			}
+			for _, word := range strings.Fields(string(z.Text())) {
+				if limit > 0 && out.Len()+len(word)+1 > limit {
+					return strings.TrimSpace(out.String())
+				}
+				if out.Len() > 0 {
+					out.WriteByte(' ')
+				}
+				out.WriteString(word)
+			}
+		}
+	}
+}
+
+func isHiddenElement(name []byte) bool {
+	switch string(name) {
+	case "script", "style", "head":
+		return true
+	}
+	return false
+}
+
+func firstOf(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
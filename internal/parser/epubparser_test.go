@@ -0,0 +1,161 @@
+package parser
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"testing"
+)
+
+const testEPUBContainer = `<?xml version="1.0"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+  <rootfiles><rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/></rootfiles>
+</container>`
+
+// buildEPUB zips files, name to content, into an EPUB.
+func buildEPUB(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for name, content := range files {
+		fw, err := w.Create(name)
+		if err != nil {
+			t.Fatalf("create %s: %v", name, err)
+		}
+		if _, err := fw.Write([]byte(content)); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestEPUBParserParseEPUB2(t *testing.T) {
+	opf := `<?xml version="1.0" encoding="utf-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="2.0">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:opf="http://www.idpf.org/2007/opf">
+    <dc:title>  Test Title </dc:title>
+    <dc:creator opf:role="aut" opf:file-as="Doe, John">John Doe</dc:creator>
+    <dc:creator opf:role="trl">Jane Roe</dc:creator>
+    <dc:creator>Ann Mary Smith</dc:creator>
+    <dc:language>EN</dc:language>
+    <dc:date>2020-01-01</dc:date>
+    <dc:description>&lt;p&gt;Line1&lt;/p&gt;&lt;p&gt;Line &amp;amp; 2&lt;/p&gt;</dc:description>
+    <meta name="calibre:series" content="Saga"/>
+    <meta name="calibre:series_index" content="2"/>
+  </metadata>
+  <manifest>
+    <item id="ch1" href="Text/chapter%201.xhtml" media-type="application/xhtml+xml"/>
+    <item id="ch2" href="Text/ch2.xhtml" media-type="application/xhtml+xml"/>
+  </manifest>
+  <spine><itemref idref="ch1"/><itemref idref="ch2"/></spine>
+</package>`
+	content := buildEPUB(t, map[string]string{
+		"mimetype":                     "application/epub+zip",
+		epubContainerPath:              testEPUBContainer,
+		"OEBPS/content.opf":            opf,
+		"OEBPS/Text/chapter 1.xhtml":   `<html><head><title>Skip</title><style>p{}</style></head><body><p>First   chapter.</p></body></html>`,
+		"OEBPS/Text/ch2.xhtml":         `<html><body><script>skip()</script><p>Second.</p></body></html>`,
+		"OEBPS/Text/unlisted.xhtml":    `<html><body>Never read.</body></html>`,
+		"OEBPS/Images/not-a-cover.png": "x",
+	})
+
+	book, err := NewEPUBParser(true).Parse(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if book.Title != "Test Title" {
+		t.Errorf("title = %q", book.Title)
+	}
+	want := []Author{{Name: "Doe John", Sortkey: "Doe"}, {Name: "Smith Ann Mary", Sortkey: "Smith"}}
+	if len(book.Authors) != len(want) {
+		t.Fatalf("authors = %+v, want %+v", book.Authors, want)
+	}
+	for i := range want {
+		if book.Authors[i] != want[i] {
+			t.Errorf("author %d = %+v, want %+v", i, book.Authors[i], want[i])
+		}
+	}
+	if book.Series == nil || book.Series.Title != "Saga" || book.Series.Index != "2" {
+		t.Errorf("series = %+v", book.Series)
+	}
+	if book.Language != "en" || book.DocDate != "2020-01-01" {
+		t.Errorf("language = %q, date = %q", book.Language, book.DocDate)
+	}
+	if book.Annotation != "Line1 Line & 2" {
+		t.Errorf("annotation = %q", book.Annotation)
+	}
+	if book.BodySample != "First chapter. Second." {
+		t.Errorf("body sample = %q", book.BodySample)
+	}
+	if book.Cover != nil {
+		t.Errorf("a book without a cover has cover %q", book.Cover)
+	}
+	if book.Mimetype != "epub" {
+		t.Errorf("mimetype = %q", book.Mimetype)
+	}
+}
+
+func TestEPUBParserParseEPUB3(t *testing.T) {
+	opf := `<?xml version="1.0" encoding="utf-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+    <dc:title>Война и мир</dc:title>
+    <dc:creator id="a1">Лев Толстой</dc:creator>
+    <meta refines="#a1" property="role" scheme="marc:relators">aut</meta>
+    <meta refines="#a1" property="file-as">Толстой, Лев</meta>
+    <dc:creator id="e1">Some Editor</dc:creator>
+    <meta refines="#e1" property="role" scheme="marc:relators">edt</meta>
+    <meta property="belongs-to-collection" id="c1">Эпопея</meta>
+    <meta refines="#c1" property="collection-type">series</meta>
+    <meta refines="#c1" property="group-position">1</meta>
+  </metadata>
+  <manifest>
+    <item id="nav" href="nav.xhtml" properties="nav" media-type="application/xhtml+xml"/>
+    <item id="img" href="cover.svg" properties="cover-image" media-type="image/svg+xml"/>
+  </manifest>
+  <spine><itemref idref="nav"/></spine>
+</package>`
+	content := buildEPUB(t, map[string]string{
+		epubContainerPath:   testEPUBContainer,
+		"OEBPS/content.opf": opf,
+		"OEBPS/nav.xhtml":   `<html><body><p>Оглавление</p></body></html>`,
+		"OEBPS/cover.svg":   `<svg xmlns="http://www.w3.org/2000/svg" width="10" height="10"/>`,
+	})
+
+	book, err := NewEPUBParser(true).Parse(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(book.Authors) != 1 || book.Authors[0] != (Author{Name: "Толстой Лев", Sortkey: "Толстой"}) {
+		t.Errorf("authors = %+v", book.Authors)
+	}
+	if book.Series == nil || book.Series.Title != "Эпопея" || book.Series.Index != "1" {
+		t.Errorf("series = %+v", book.Series)
+	}
+	if book.BodySample != "Оглавление" {
+		t.Errorf("body sample = %q", book.BodySample)
+	}
+	if len(book.Cover) == 0 {
+		t.Error("the cover-image item is the cover")
+	}
+}
+
+func TestEPUBParserRefusesWhatIsNotAnEPUB(t *testing.T) {
+	for name, content := range map[string][]byte{
+		"not a zip":    []byte("<FictionBook/>"),
+		"no container": buildEPUB(t, map[string]string{"mimetype": "application/epub+zip"}),
+		"no package": buildEPUB(t, map[string]string{
+			epubContainerPath: testEPUBContainer,
+		}),
+		"empty container": buildEPUB(t, map[string]string{
+			epubContainerPath: `<container><rootfiles/></container>`,
+		}),
+	} {
+		if _, err := NewEPUBParser(false).Parse(bytes.NewReader(content), int64(len(content))); !errors.Is(err, ErrNotEPUB) {
+			t.Errorf("%s: err = %v, want ErrNotEPUB", name, err)
+		}
+	}
+}
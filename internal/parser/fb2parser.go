@@ -102,7 +102,7 @@ func (p *FB2Parser) Parse(reader io.Reader) (*BookFile, error) {
 		fallbackBook, fallbackErr := p.parseContent(fallback)
 		if fallbackErr == nil {
 			p.ensureBodySample(decodedContent, fallbackBook)
-			fallbackBook.Issues = append(fallbackBook.Issues, err.Error(), "parsed_without_body")
+			fallbackBook.Issues = append(fallbackBook.Issues, err.Error(), IssueParsedWithoutBody)
 			return fallbackBook, nil
 		}
 	}
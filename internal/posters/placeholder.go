@@ -0,0 +1,176 @@
+package posters
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"math"
+	"strings"
+
+	// Decoders for the formats a poster file may be in: covers are stored as
+	// JPEG, but ones written before the conversion existed can be anything.
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+)
+
+// Placeholder is what a client paints while the cover itself loads: a
+// BlurHash (https://blurha.sh) of the image and its dominant colour as
+// "#rrggbb", for clients that draw a flat box instead.
+type Placeholder struct {
+	Blurhash string
+	Color    string
+}
+
+// placeholderSide is the width the image is shrunk to before either value is
+// computed. Both describe the picture at a glance; more pixels than this only
+// cost time.
+const placeholderSide = 32
+
+// PlaceholderOf decodes a cover and computes its placeholder.
+func PlaceholderOf(data []byte) (Placeholder, error) {
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return Placeholder{}, fmt.Errorf("decoding cover: %w", err)
+	}
+	small := Thumbnail(img, placeholderSide)
+	return Placeholder{
+		Blurhash: Blurhash(small, 4, 3),
+		Color:    DominantColor(small),
+	}, nil
+}
+
+// DominantColor returns the most common colour of img as "#rrggbb". Pixels
+// are grouped into 4-bit-per-channel buckets so near-identical shades count
+// together, and the answer is the mean of the biggest bucket rather than its
+// corner, so it is a colour that actually occurs in the picture.
+func DominantColor(img image.Image) string {
+	type bucket struct {
+		n       int
+		r, g, b int
+	}
+	buckets := make(map[int]*bucket)
+	var best *bucket
+	bounds := img.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			r, g, b := rgb8(img, x, y)
+			key := (r>>4)<<8 | (g>>4)<<4 | b>>4
+			bk := buckets[key]
+			if bk == nil {
+				bk = &bucket{}
+				buckets[key] = bk
+			}
+			bk.n++
+			bk.r += r
+			bk.g += g
+			bk.b += b
+			if best == nil || bk.n > best.n {
+				best = bk
+			}
+		}
+	}
+	if best == nil {
+		return ""
+	}
+	return fmt.Sprintf("#%02x%02x%02x", best.r/best.n, best.g/best.n, best.b/best.n)
+}
+
+// Blurhash encodes img with xComponents × yComponents cosine components
+// (each 1..9), following the reference algorithm.
+func Blurhash(img image.Image, xComponents, yComponents int) string {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width == 0 || height == 0 {
+		return ""
+	}
+
+	factors := make([][3]float64, 0, xComponents*yComponents)
+	for j := 0; j < yComponents; j++ {
+		for i := 0; i < xComponents; i++ {
+			normalisation := 2.0
+			if i == 0 && j == 0 {
+				normalisation = 1
+			}
+			var r, g, b float64
+			for y := 0; y < height; y++ {
+				for x := 0; x < width; x++ {
+					basis := math.Cos(math.Pi*float64(i)*float64(x)/float64(width)) *
+						math.Cos(math.Pi*float64(j)*float64(y)/float64(height))
+					pr, pg, pb := rgb8(img, bounds.Min.X+x, bounds.Min.Y+y)
+					r += basis * srgbToLinear(pr)
+					g += basis * srgbToLinear(pg)
+					b += basis * srgbToLinear(pb)
+				}
+			}
+			scale := normalisation / float64(width*height)
+			factors = append(factors, [3]float64{r * scale, g * scale, b * scale})
+		}
+	}
+
+	var hash strings.Builder
+	hash.WriteString(encode83((xComponents-1)+(yComponents-1)*9, 1))
+
+	dc, ac := factors[0], factors[1:]
+	maximumValue := 1.0
+	if len(ac) > 0 {
+		actualMax := 0.0
+		for _, f := range ac {
+			actualMax = math.Max(actualMax, math.Max(math.Abs(f[0]), math.Max(math.Abs(f[1]), math.Abs(f[2]))))
+		}
+		quantisedMax := int(math.Max(0, math.Min(82, math.Floor(actualMax*166-0.5))))
+		maximumValue = float64(quantisedMax+1) / 166
+		hash.WriteString(encode83(quantisedMax, 1))
+	} else {
+		hash.WriteString(encode83(0, 1))
+	}
+
+	hash.WriteString(encode83(linearToSRGB(dc[0])<<16|linearToSRGB(dc[1])<<8|linearToSRGB(dc[2]), 4))
+	for _, f := range ac {
+		quant := func(v float64) int {
+			return int(math.Max(0, math.Min(18, math.Floor(signPow(v/maximumValue, 0.5)*9+9.5))))
+		}
+		hash.WriteString(encode83(quant(f[0])*19*19+quant(f[1])*19+quant(f[2]), 2))
+	}
+	return hash.String()
+}
+
+const base83Chars = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz#$%*+,-.:;=?@[]^_{|}~"
+
+func encode83(value, length int) string {
+	out := make([]byte, length)
+	for i := length - 1; i >= 0; i-- {
+		out[i] = base83Chars[value%83]
+		value /= 83
+	}
+	return string(out)
+}
+
+// rgb8 reads one pixel as 8-bit channels, composited over white: a
+// transparent cover is shown on a light page, and the placeholder should
+// match what ends up there.
+func rgb8(img image.Image, x, y int) (int, int, int) {
+	r, g, b, a := img.At(x, y).RGBA()
+	white := 0xffff - a
+	return int((r + white) >> 8), int((g + white) >> 8), int((b + white) >> 8)
+}
+
+func srgbToLinear(v int) float64 {
+	c := float64(v) / 255
+	if c <= 0.04045 {
+		return c / 12.92
+	}
+	return math.Pow((c+0.055)/1.055, 2.4)
+}
+
+func linearToSRGB(v float64) int {
+	c := math.Max(0, math.Min(1, v))
+	if c <= 0.0031308 {
+		return int(c*12.92*255 + 0.5)
+	}
+	return int((1.055*math.Pow(c, 1/2.4)-0.055)*255 + 0.5)
+}
+
+func signPow(v, exp float64) float64 {
+	return math.Copysign(math.Pow(math.Abs(v), exp), v)
+}
@@ -0,0 +1,92 @@
+package posters
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"strings"
+	"testing"
+)
+
+func solid(w, h int, c color.Color) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, c)
+		}
+	}
+	return img
+}
+
+// The first character encodes the component counts and the DC — characters
+// three to six — carries the image's mean colour.
+func TestBlurhashOfAFlatImage(t *testing.T) {
+	got := Blurhash(solid(8, 8, color.RGBA{R: 255, A: 255}), 4, 3)
+
+	if got[:1] != "L" {
+		t.Errorf("size flag %q, want L for 4x3 components", got[:1])
+	}
+	if dc := got[2:6]; dc != encode83(255<<16, 4) {
+		t.Errorf("DC %q, want %q", dc, encode83(255<<16, 4))
+	}
+}
+
+func TestBlurhashLength(t *testing.T) {
+	img := solid(10, 10, color.White)
+	for x := 0; x < 5; x++ {
+		for y := 0; y < 10; y++ {
+			img.Set(x, y, color.Black)
+		}
+	}
+	// 1 size flag + 1 max + 4 DC + 2 per AC component.
+	if got := Blurhash(img, 4, 3); len(got) != 1+1+4+2*11 {
+		t.Errorf("Blurhash %q has length %d", got, len(got))
+	}
+}
+
+func TestDominantColorPicksTheLargestArea(t *testing.T) {
+	img := solid(10, 10, color.RGBA{R: 0x20, G: 0x40, B: 0x80, A: 255})
+	for x := 0; x < 3; x++ {
+		img.Set(x, 0, color.RGBA{R: 0xff, A: 255})
+	}
+	if got := DominantColor(img); got != "#204080" {
+		t.Errorf("DominantColor = %q, want #204080", got)
+	}
+}
+
+func TestPlaceholderOfDecodesTheCover(t *testing.T) {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, solid(120, 180, color.RGBA{G: 200, A: 255}), nil); err != nil {
+		t.Fatal(err)
+	}
+	p, err := PlaceholderOf(buf.Bytes())
+	if err != nil {
+		t.Fatalf("PlaceholderOf: %v", err)
+	}
+	if p.Blurhash == "" || !strings.HasPrefix(p.Color, "#") {
+		t.Errorf("placeholder = %+v", p)
+	}
+	if _, err := PlaceholderOf([]byte("not an image")); err == nil {
+		t.Error("garbage decoded into a placeholder")
+	}
+}
+
+func TestThumbnailKeepsProportionsAndNeverEnlarges(t *testing.T) {
+	small := Thumbnail(solid(400, 600, color.White), 96)
+	if b := small.Bounds(); b.Dx() != 96 || b.Dy() != 144 {
+		t.Errorf("thumbnail is %dx%d, want 96x144", b.Dx(), b.Dy())
+	}
+	narrow := solid(50, 80, color.White)
+	if Thumbnail(narrow, 96) != narrow {
+		t.Error("an image narrower than the thumbnail was scaled")
+	}
+}
+
+func TestSnapWidth(t *testing.T) {
+	for in, want := range map[int]int{1: 96, 96: 96, 97: 200, 400: 400, 5000: 400} {
+		if got := SnapWidth(in); got != want {
+			t.Errorf("SnapWidth(%d) = %d, want %d", in, got, want)
+		}
+	}
+}
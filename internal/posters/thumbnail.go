@@ -0,0 +1,42 @@
+package posters
+
+import (
+	"image"
+
+	"golang.org/x/image/draw"
+)
+
+// ThumbnailWidths are the widths a cover is scaled to. Requests snap to one
+// of them, so a client asking for every width from 1 to 2000 cannot fill the
+// disk with two thousand copies of each cover.
+var ThumbnailWidths = []int{96, 200, 400}
+
+// ThumbnailSmall is the width feeds advertise as a book's thumbnail.
+const ThumbnailSmall = 96
+
+// SnapWidth returns the smallest thumbnail width at least w wide, or the
+// largest one when w exceeds them all.
+func SnapWidth(w int) int {
+	for _, candidate := range ThumbnailWidths {
+		if w <= candidate {
+			return candidate
+		}
+	}
+	return ThumbnailWidths[len(ThumbnailWidths)-1]
+}
+
+// Thumbnail scales img to width, keeping its proportions. An image already
+// that narrow is returned as is: enlarging only adds bytes.
+func Thumbnail(img image.Image, width int) image.Image {
+	bounds := img.Bounds()
+	if width <= 0 || bounds.Dx() <= width {
+		return img
+	}
+	height := (bounds.Dy()*width + bounds.Dx()/2) / bounds.Dx()
+	if height < 1 {
+		height = 1
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, bounds, draw.Src, nil)
+	return dst
+}
@@ -0,0 +1,25 @@
+// Package testfakes holds the in-memory stand-ins for service ports that
+// tests in more than one package drive: one fake per port, kept beside no
+// single consumer, so that the adapters are tested against the same thing.
+package testfakes
+
+import (
+	"context"
+
+	"gopds-api/models"
+)
+
+// Recommendations is a recommender that records the request it receives
+// and answers with Books.
+type Recommendations struct {
+	Req   models.RecommendationRequest
+	Books []models.Book
+}
+
+// Recommend records req and returns f.Books.
+//
+//nolint:gocritic // the port takes the request by value; this implements it
+func (f *Recommendations) Recommend(ctx context.Context, req models.RecommendationRequest) ([]models.Book, error) {
+	f.Req = req
+	return f.Books, nil
+}
@@ -0,0 +1,75 @@
+package totp
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA1 key of the RFC 6238 test vectors, "12345678901234567890".
+var rfcSecret = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+
+// The RFC 6238 appendix B vectors are eight digits; six-digit codes are
+// their last six.
+func TestCode_RFC6238(t *testing.T) {
+	vectors := map[int64]string{
+		59:          "94287082",
+		1111111109:  "07081804",
+		1111111111:  "14050471",
+		1234567890:  "89005924",
+		2000000000:  "69279037",
+		20000000000: "65353130",
+	}
+	for unix, want := range vectors {
+		got, err := Code(rfcSecret, Step(time.Unix(unix, 0)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want[2:] {
+			t.Errorf("Code at %d = %s, want %s", unix, got, want[2:])
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+	step := Step(now)
+	code, _ := Code(rfcSecret, step)
+
+	if got, ok := Validate(rfcSecret, code, now, 0); !ok || got != step {
+		t.Fatalf("Validate() = %d, %v; want %d, true", got, ok, step)
+	}
+	// The neighbouring steps are within the skew, the ones after are not.
+	if _, ok := Validate(rfcSecret, code, now.Add(Period), 0); !ok {
+		t.Error("a code from the previous step was refused")
+	}
+	if _, ok := Validate(rfcSecret, code, now.Add(2*Period), 0); ok {
+		t.Error("a code two steps old was accepted")
+	}
+	// Once used, a code is spent.
+	if _, ok := Validate(rfcSecret, code, now, step); ok {
+		t.Error("a code was accepted twice")
+	}
+	if _, ok := Validate(rfcSecret, "12345", now, 0); ok {
+		t.Error("a short code was accepted")
+	}
+}
+
+func TestNewSecret(t *testing.T) {
+	s := NewSecret()
+	if len(s) != 32 || strings.ContainsRune(s, '=') {
+		t.Fatalf("NewSecret() = %q", s)
+	}
+	if _, err := Code(s, 1); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestProvisioningURI(t *testing.T) {
+	got := ProvisioningURI("Book Shelf", "anna@example.com", "ABC")
+	want := "otpauth://totp/Book%20Shelf:anna@example.com?algorithm=SHA1&digits=6&issuer=Book%20Shelf&period=30&secret=ABC"
+	if got != want {
+		t.Errorf("ProvisioningURI() =\n%s\nwant\n%s", got, want)
+	}
+}
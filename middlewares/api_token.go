@@ -0,0 +1,79 @@
+package middlewares
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"gopds-api/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// APITokenAuth checks the personal access tokens scripts send as bearer
+// credentials.
+type APITokenAuth interface {
+	Authenticate(ctx context.Context, token string) (*models.APIToken, *models.User, error)
+}
+
+var (
+	// apiTokens is nil until SetAPITokens: personal access tokens are then
+	// refused like any other unknown credential.
+	apiTokens APITokenAuth
+	// apiTokenScopeRules assign routes to the scopes a token needs for
+	// them. A route no rule covers cannot be reached with a token.
+	apiTokenScopeRules []PermissionRule
+)
+
+// SetAPITokens installs the check of personal access tokens, and the rules
+// that assign routes to token scopes.
+func SetAPITokens(auth APITokenAuth, rules []PermissionRule) {
+	apiTokens = auth
+	apiTokenScopeRules = rules
+}
+
+// bearerToken returns the credential of an Authorization header, which may
+// or may not say "Bearer".
+func bearerToken(header string) string {
+	if len(header) > 7 && strings.EqualFold(header[:7], "bearer ") {
+		return strings.TrimSpace(header[7:])
+	}
+	return header
+}
+
+// authenticateAPIToken lets a request made with a personal access token
+// through to the routes its scopes cover, with its user on the context.
+// It reports false, having aborted the request, otherwise.
+func authenticateAPIToken(c *gin.Context, raw string) bool {
+	if apiTokens == nil {
+		abortWithStatus(c, http.StatusUnauthorized, "invalid_session")
+		return false
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 2*time.Second)
+	defer cancel()
+	token, user, err := apiTokens.Authenticate(ctx, raw)
+	if err != nil {
+		abortWithStatus(c, http.StatusUnauthorized, "invalid_token")
+		return false
+	}
+	scope, ok := RequiredPermission(apiTokenScopeRules, c.Request.Method, c.FullPath())
+	if !ok || !token.HasScope(scope) {
+		abortWithStatus(c, http.StatusForbidden, "insufficient_scope")
+		return false
+	}
+
+	c.Set("username", user.Login)
+	c.Set("user_id", user.ID)
+	c.Set("is_superuser", user.IsSuperUser)
+	c.Set("permissions", user.Permissions)
+	c.Set("api_token_id", token.ID)
+	c.Set("api_token_scopes", token.Scopes)
+	return true
+}
+
+// viaAPIToken reports whether the request was authenticated with a
+// personal access token.
+func viaAPIToken(c *gin.Context) bool {
+	return c.GetInt64("api_token_id") != 0
+}
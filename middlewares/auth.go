@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"time"
 
+	"gopds-api/logging"
 	"gopds-api/models"
 	"gopds-api/sessions"
 	"gopds-api/utils"
@@ -15,33 +16,61 @@ import (
 
 // ValidateTokenPublic is a public wrapper for validateToken for use in WebSocket handlers
 func ValidateTokenPublic(token string) (string, int64, bool, error) {
-	return validateToken(token)
+	claims, err := validateToken(token)
+	if err != nil {
+		return "", 0, false, err
+	}
+	return claims.UserID, claims.DatabaseID, claims.IsSuperUser, nil
 }
 
 // validateToken validates an access token: checks Redis session and verifies
 // the JWT signature with the access token key (sessions.key).
-func validateToken(token string) (string, int64, bool, error) {
+func validateToken(token string) (*utils.Token, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
 	defer cancel()
 
 	// If token not in Redis, return error
 	_, err := sessions.CheckSessionKeyInRedis(ctx, token)
 	if err != nil {
-		return "", 0, false, errors.New("invalid_session")
+		return nil, errors.New("invalid_session")
 	}
 
-	username, dbID, isSuperUser, err := utils.CheckAccessToken(token)
+	claims, err := utils.ParseAccessToken(token)
 	if err != nil {
-		return "", 0, false, errors.New("invalid_session")
+		return nil, errors.New("invalid_session")
 	}
 
 	// Update the session timestamp in Redis
-	err = sessions.SetSessionKey(ctx, models.LoggedInUser{User: username, Token: &token})
+	err = sessions.SetSessionKey(ctx, models.LoggedInUser{User: claims.UserID, Token: &token})
 	if err != nil {
-		return "", 0, false, errors.New("session_update_failed")
+		return nil, errors.New("session_update_failed")
 	}
 
-	return username, dbID, isSuperUser, nil
+	return claims, nil
+}
+
+// setUserContext puts the token's user on the request context.
+func setUserContext(c *gin.Context, claims *utils.Token) {
+	c.Set("username", claims.UserID)
+	c.Set("user_id", claims.DatabaseID)
+	c.Set("is_superuser", claims.IsSuperUser)
+	c.Set("permissions", claims.Permissions)
+	c.Set("session_id", claims.SessionID)
+	c.Set("two_factor", claims.TwoFactor)
+}
+
+// touchSession records the use of the token's session in the user's
+// session index. A failure costs the list of sessions a timestamp, not the
+// request.
+func touchSession(c *gin.Context, claims *utils.Token) {
+	if claims.SessionID == "" {
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 2*time.Second)
+	defer cancel()
+	if err := sessions.TouchSession(ctx, claims.DatabaseID, claims.SessionID, c.ClientIP()); err != nil {
+		logging.Warnf("Recording use of session %s: %v", claims.SessionID, err)
+	}
 }
 
 // abortWithStatus simplifies error responses.
@@ -50,6 +79,8 @@ func abortWithStatus(c *gin.Context, status int, message string) {
 }
 
 // AuthMiddleware checks if user is logged in and sets username in context.
+// A personal access token in the Authorization header is accepted too, for
+// the routes its scopes cover.
 func AuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var token string
@@ -58,7 +89,13 @@ func AuthMiddleware() gin.HandlerFunc {
 		// Try to get token from header or cookie
 		authHeader := c.GetHeader("Authorization")
 		if authHeader != "" {
-			token = authHeader
+			token = bearerToken(authHeader)
+			if models.IsAPIToken(token) {
+				if authenticateAPIToken(c, token) {
+					c.Next()
+				}
+				return
+			}
 		} else {
 			token, err = c.Cookie("token")
 			if err != nil || token == "" {
@@ -68,16 +105,14 @@ func AuthMiddleware() gin.HandlerFunc {
 		}
 
 		// Validate token
-		username, dbID, isSuperUser, err := validateToken(token)
+		claims, err := validateToken(token)
 		if err != nil {
 			abortWithStatus(c, http.StatusUnauthorized, err.Error())
 			return
 		}
 
-		// Set username and user_id in context
-		c.Set("username", username)
-		c.Set("user_id", dbID)
-		c.Set("is_superuser", isSuperUser)
+		setUserContext(c, claims)
+		touchSession(c, claims)
 		c.Next()
 	}
 }
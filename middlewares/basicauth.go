@@ -6,7 +6,9 @@ import (
 	"github.com/gin-gonic/gin"
 
 	"gopds-api/database"
+	"gopds-api/logging"
 	"gopds-api/models"
+	"gopds-api/sessions"
 )
 
 // BasicAuth Get the Basic Authentication credentials
@@ -25,8 +27,22 @@ func BasicAuth() gin.HandlerFunc {
 			return
 		}
 
+		// A reader revoked from the list of sessions stays out until the
+		// password changes.
+		ctx := c.Request.Context()
+		userAgent := c.Request.UserAgent()
+		if sessions.OPDSSessionBlocked(ctx, dbUser.ID, userAgent, dbUser.Password) {
+			abortWithAuthRequired(c)
+			return
+		}
+		if err := sessions.TouchOPDSSession(ctx, dbUser.ID, userAgent, c.ClientIP()); err != nil {
+			logging.Warnf("Recording OPDS reader of user %d: %v", dbUser.ID, err)
+		}
+
 		c.Set("username", user)
 		c.Set("user_id", dbUser.ID)
+		c.Set("interface_lang", dbUser.InterfaceLang)
+		c.Set("books_lang", dbUser.BooksLang)
 		c.Next()
 	}
 }
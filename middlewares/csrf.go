@@ -19,6 +19,12 @@ func CSRFMiddleware() gin.HandlerFunc {
 			c.Next()
 			return
 		}
+		// And for requests made with a personal access token: a browser
+		// does not send one on its own, as it does cookies
+		if viaAPIToken(c) {
+			c.Next()
+			return
+		}
 
 		// Get CSRF token from header
 		csrfToken := c.GetHeader("X-CSRF-Token")
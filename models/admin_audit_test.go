@@ -0,0 +1,94 @@
+package models
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func auditKeys(changes map[string]AuditChange) []string {
+	keys := make([]string, 0, len(changes))
+	for k := range changes {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func TestAuditDiff_ChangedFieldsOnly(t *testing.T) {
+	before := Book{ID: 3, Title: "Черновик", Lang: "ru", Genres: []Genre{{ID: 1}}}
+	after := Book{ID: 3, Title: "Дозоры", Lang: "ru", Approved: true, Genres: []Genre{{ID: 1}}}
+
+	changes, err := AuditDiff(before, after)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := auditKeys(changes), []string{"approved", "title"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("changed fields = %v, want %v", got, want)
+	}
+	if got := string(changes["title"].Before); got != `"Черновик"` {
+		t.Errorf("title before = %s", got)
+	}
+	if got := string(changes["approved"].After); got != "true" {
+		t.Errorf("approved after = %s", got)
+	}
+}
+
+func TestAuditDiff_CreatedAndDeleted(t *testing.T) {
+	invite := Invite{ID: 4, Invite: "spring"}
+
+	created, err := AuditDiff(nil, invite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := created["invite"]; string(c.Before) != "null" || string(c.After) != `"spring"` {
+		t.Errorf("created: invite = %s -> %s", c.Before, c.After)
+	}
+
+	deleted, err := AuditDiff(invite, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := deleted["id"]; string(c.Before) != "4" || string(c.After) != "null" {
+		t.Errorf("deleted: id = %s -> %s", c.Before, c.After)
+	}
+}
+
+func TestAuditDiff_ScalarValue(t *testing.T) {
+	changes, err := AuditDiff("a", "b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c, ok := changes["value"]; !ok || string(c.Before) != `"a"` || string(c.After) != `"b"` {
+		t.Errorf("changes = %v", changes)
+	}
+}
+
+// A secret that changed is logged as changed, never with its value; one
+// that was set or cleared shows which.
+func TestAuditDiff_RedactsSecrets(t *testing.T) {
+	before := map[string]any{"bot_token": "", "password": "hash-1", "nested": map[string]any{"api_secret": "s1", "name": "a"}}
+	after := map[string]any{"bot_token": "123:abc", "password": "hash-2", "nested": map[string]any{"api_secret": "s1", "name": "b"}}
+
+	changes, err := AuditDiff(before, after)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := auditKeys(changes), []string{"bot_token", "nested", "password"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("changed fields = %v, want %v", got, want)
+	}
+	if c := changes["bot_token"]; string(c.Before) != `""` || string(c.After) != `"[redacted]"` {
+		t.Errorf("bot_token = %s -> %s", c.Before, c.After)
+	}
+	if c := changes["password"]; string(c.Before) != `"[redacted]"` || string(c.After) != `"[redacted]"` {
+		t.Errorf("password = %s -> %s", c.Before, c.After)
+	}
+	var nested map[string]string
+	if err := json.Unmarshal(changes["nested"].After, &nested); err != nil {
+		t.Fatal(err)
+	}
+	if nested["api_secret"] != AuditRedacted || nested["name"] != "b" {
+		t.Errorf("nested after = %v", nested)
+	}
+}
@@ -0,0 +1,82 @@
+package models
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestAPITokenRequest_Validate(t *testing.T) {
+	r := APITokenRequest{
+		Name:   " backup script ",
+		Scopes: []string{ScopeDownload, "admin:scan", ScopeReadCatalog, ScopeDownload},
+	}
+	if err := r.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if r.Name != "backup script" {
+		t.Errorf("Name = %q", r.Name)
+	}
+	if want := []string{ScopeReadCatalog, ScopeDownload, "admin:scan"}; !reflect.DeepEqual(r.Scopes, want) {
+		t.Errorf("Scopes = %v, want %v", r.Scopes, want)
+	}
+	if want := []string{PermLibraryScan}; !reflect.DeepEqual(r.AdminPermissions(), want) {
+		t.Errorf("AdminPermissions() = %v, want %v", r.AdminPermissions(), want)
+	}
+}
+
+func TestAPITokenRequest_Validate_Rejects(t *testing.T) {
+	tests := []struct {
+		name string
+		req  APITokenRequest
+	}{
+		{"empty name", APITokenRequest{Name: " ", Scopes: []string{ScopeDownload}}},
+		{"no scope", APITokenRequest{Name: "x"}},
+		{"unknown scope", APITokenRequest{Name: "x", Scopes: []string{"admin:everything"}}},
+		{"negative expiry", APITokenRequest{Name: "x", Scopes: []string{ScopeDownload}, ExpiresInDays: -1}},
+		{"expiry too far", APITokenRequest{Name: "x", Scopes: []string{ScopeDownload}, ExpiresInDays: MaxAPITokenDays + 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.req.Validate(); !errors.Is(err, ErrAPITokenInvalid) {
+				t.Fatalf("Validate() = %v, want %v", err, ErrAPITokenInvalid)
+			}
+		})
+	}
+}
+
+func TestAPITokenScopes_CoverEveryPermission(t *testing.T) {
+	for _, p := range Permissions {
+		if AdminScope(p) == "" {
+			t.Errorf("permission %q has no token scope", p)
+		}
+	}
+	if got, want := len(APITokenScopes()), 3+len(Permissions); got != want {
+		t.Errorf("len(APITokenScopes()) = %d, want %d", got, want)
+	}
+}
+
+func TestAPIToken_Expired(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	if (&APIToken{}).Expired(now) {
+		t.Error("a token without expiry expired")
+	}
+	expires := now.Add(time.Hour)
+	token := &APIToken{ExpiresAt: &expires}
+	if token.Expired(now) {
+		t.Error("expired an hour early")
+	}
+	if !token.Expired(expires) {
+		t.Error("not expired at its expiry")
+	}
+}
+
+func TestIsAPIToken(t *testing.T) {
+	if !IsAPIToken(APITokenPrefix + "abc") {
+		t.Error("prefixed token not recognized")
+	}
+	if IsAPIToken("eyJhbGciOiJIUzI1NiJ9.e30.sig") {
+		t.Error("a JWT taken for a personal access token")
+	}
+}
@@ -1,5 +1,10 @@
+// name the table; staticcheck sees a field nobody mentions.
+//
+//lint:file-ignore U1000 tableName is read by go-pg through reflection to
 package models
 
+import "time"
+
 // AuthorFilters filters for authors list
 type AuthorFilters struct {
 	Limit  int    `form:"limit" json:"limit"`
@@ -12,3 +17,49 @@ type AuthorFilters struct {
 type AuthorRequest struct {
 	ID int64 `json:"author_id" form:"author_id"`
 }
+
+// Author alias kinds.
+const (
+	AuthorAliasKindAlias     = "alias"
+	AuthorAliasKindPseudonym = "pseudonym"
+)
+
+// AuthorAlias is another name an author is known by: a spelling, a
+// transliteration, or a pseudonym. Scanning links a book credited under it to
+// the author, and search finds the author by it.
+type AuthorAlias struct {
+	tableName struct{}  `pg:"opds_catalog_author_alias,discard_unknown_columns" json:"-"`
+	ID        int64     `pg:"id,pk" json:"id"`
+	AuthorID  int64     `pg:"author_id" json:"author_id"`
+	Alias     string    `pg:"alias" json:"alias"`
+	Kind      string    `pg:"kind" json:"kind"`
+	CreatedAt time.Time `pg:"created_at,default:now()" json:"created_at"`
+}
+
+// AuthorMergeSource is one author folded into another by a merge, with what
+// undoing the merge needs to put it back.
+type AuthorMergeSource struct {
+	ID       int64  `json:"id"`
+	FullName string `json:"full_name"`
+	// MovedBookIDs are the books whose link was moved to the target.
+	MovedBookIDs []int64 `json:"moved_book_ids"`
+	// SharedBookIDs were linked to both authors; the source link was dropped.
+	SharedBookIDs []int64 `json:"shared_book_ids"`
+	// AliasIDs are the source's aliases, moved to the target.
+	AliasIDs []int64 `json:"alias_ids"`
+}
+
+// AuthorMerge is the audit record of one merge of authors into a target.
+type AuthorMerge struct {
+	tableName       struct{}            `pg:"author_merges,discard_unknown_columns" json:"-"`
+	ID              int64               `pg:"id,pk" json:"id"`
+	TargetID        int64               `pg:"target_id" json:"target_id"`
+	TargetOldName   string              `pg:"target_old_name" json:"target_old_name"`
+	TargetNewName   string              `pg:"target_new_name" json:"target_new_name"`
+	Sources         []AuthorMergeSource `pg:"sources,type:jsonb" json:"sources"`
+	CreatedAliasIDs []int64             `pg:"created_alias_ids,array" json:"created_alias_ids"`
+	MergedBy        *int64              `pg:"merged_by" json:"merged_by,omitempty"`
+	CreatedAt       time.Time           `pg:"created_at,default:now()" json:"created_at"`
+	UndoneBy        *int64              `pg:"undone_by" json:"undone_by,omitempty"`
+	UndoneAt        *time.Time          `pg:"undone_at" json:"undone_at,omitempty"`
+}
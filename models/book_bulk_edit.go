@@ -0,0 +1,300 @@
+// name the table; staticcheck sees a field nobody mentions.
+//
+//lint:file-ignore U1000 tableName is read by go-pg through reflection to
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Bulk edit statuses. An edit runs, then ends one of four ways; an ended
+// edit can be undone, which runs in the background too.
+const (
+	BulkEditRunning     = "running"
+	BulkEditCompleted   = "completed"
+	BulkEditCancelled   = "cancelled"
+	BulkEditFailed      = "failed"
+	BulkEditInterrupted = "interrupted" // the server stopped while it ran
+	BulkEditUndoing     = "undoing"
+	BulkEditUndone      = "undone"
+)
+
+// BookSelection names the books a bulk edit applies to: the books listed, or
+// every book the filters match. Filters with a Title select through the
+// title search, like the book list does.
+type BookSelection struct {
+	BookIDs []int64      `json:"book_ids,omitempty"`
+	Filters *BookFilters `json:"filters,omitempty"`
+}
+
+// BookBulkPatch is what a bulk edit changes in every selected book. A field
+// left nil or empty is left alone.
+type BookBulkPatch struct {
+	Lang            *string           `json:"lang,omitempty"`
+	Approved        *bool             `json:"approved,omitempty"`
+	DuplicateHidden *bool             `json:"duplicate_hidden,omitempty"`
+	AddGenres       []int64           `json:"add_genres,omitempty"`
+	RemoveGenres    []int64           `json:"remove_genres,omitempty"`
+	Series          *BulkSeriesPatch  `json:"series,omitempty"`
+	Authors         *BulkAuthorsPatch `json:"authors,omitempty"`
+}
+
+// BulkSeriesPatch puts every selected book in one series, by id or by name,
+// in place of the series it was in. With Start the books are numbered from
+// it in selection order; with KeepNumbers each keeps the number it had in
+// its first series; otherwise they are unnumbered.
+type BulkSeriesPatch struct {
+	ID          int64  `json:"id,omitempty"`
+	Name        string `json:"name,omitempty"`
+	Start       int64  `json:"start,omitempty"`
+	KeepNumbers bool   `json:"keep_numbers,omitempty"`
+}
+
+// BulkAuthorsPatch replaces authors of the selected books with To, each by
+// id or by name as in BookUpdateRequest. With From only that author is
+// replaced, in the books credited to it; without, a book's authors become
+// To.
+type BulkAuthorsPatch struct {
+	From int64    `json:"from,omitempty"`
+	To   []Author `json:"to"`
+}
+
+var (
+	// ErrBulkPatchEmpty reports a patch that changes nothing.
+	ErrBulkPatchEmpty = errors.New("bulk patch changes nothing")
+	// ErrBulkPatchInvalid reports a patch that contradicts itself or names
+	// nothing to set.
+	ErrBulkPatchInvalid = errors.New("invalid bulk patch")
+)
+
+// Validate checks that the patch changes something and that what it asks
+// for is well formed.
+func (p *BookBulkPatch) Validate() error {
+	if p.Lang == nil && p.Approved == nil && p.DuplicateHidden == nil &&
+		len(p.AddGenres) == 0 && len(p.RemoveGenres) == 0 && p.Series == nil && p.Authors == nil {
+		return ErrBulkPatchEmpty
+	}
+	if p.Lang != nil {
+		lang := strings.TrimSpace(*p.Lang)
+		if lang == "" || len(lang) > 16 {
+			return invalidPatch("lang must be 1 to 16 characters")
+		}
+		p.Lang = &lang
+	}
+	for _, id := range p.AddGenres {
+		if containsID(p.RemoveGenres, id) {
+			return invalidPatch("a genre cannot be both added and removed")
+		}
+	}
+	if s := p.Series; s != nil {
+		s.Name = strings.TrimSpace(s.Name)
+		if s.ID == 0 && s.Name == "" {
+			return invalidPatch("series needs an id or a name")
+		}
+		if s.Start < 0 {
+			return invalidPatch("series start must not be negative")
+		}
+		if s.Start > 0 && s.KeepNumbers {
+			return invalidPatch("series start and keep_numbers exclude each other")
+		}
+	}
+	if a := p.Authors; a != nil {
+		named := 0
+		for _, author := range a.To {
+			if author.ID > 0 || strings.TrimSpace(author.FullName) != "" {
+				named++
+			}
+		}
+		if named == 0 {
+			return invalidPatch("authors need at least one author to set")
+		}
+	}
+	return nil
+}
+
+func invalidPatch(reason string) error {
+	return fmt.Errorf("%w: %s", ErrBulkPatchInvalid, reason)
+}
+
+// BookSeriesRef is a book's place in a series.
+type BookSeriesRef struct {
+	ID    int64 `json:"id"`
+	SerNo int64 `json:"ser_no"`
+}
+
+// BookBulkState is the part of a book a bulk edit can change. Links are in
+// the order the book lists them.
+type BookBulkState struct {
+	Lang            string          `json:"lang"`
+	Approved        bool            `json:"approved"`
+	DuplicateHidden bool            `json:"duplicate_hidden"`
+	GenreIDs        []int64         `json:"genre_ids"`
+	Series          []BookSeriesRef `json:"series"`
+	AuthorIDs       []int64         `json:"author_ids"`
+}
+
+// Apply returns the state s becomes under the patch; index is the book's
+// place in the selection, which numbers it in a series. Series and authors
+// named by name must have been resolved to ids.
+func (p *BookBulkPatch) Apply(s BookBulkState, index int) BookBulkState {
+	out := s
+	if p.Lang != nil {
+		out.Lang = *p.Lang
+	}
+	if p.Approved != nil {
+		out.Approved = *p.Approved
+	}
+	if p.DuplicateHidden != nil {
+		out.DuplicateHidden = *p.DuplicateHidden
+	}
+	if len(p.AddGenres) > 0 || len(p.RemoveGenres) > 0 {
+		genres := make([]int64, 0, len(s.GenreIDs)+len(p.AddGenres))
+		for _, id := range s.GenreIDs {
+			if !containsID(p.RemoveGenres, id) {
+				genres = append(genres, id)
+			}
+		}
+		out.GenreIDs = appendMissing(genres, p.AddGenres...)
+	}
+	if p.Series != nil {
+		var serNo int64
+		switch {
+		case p.Series.Start > 0:
+			serNo = p.Series.Start + int64(index)
+		case p.Series.KeepNumbers && len(s.Series) > 0:
+			serNo = s.Series[0].SerNo
+		}
+		out.Series = []BookSeriesRef{{ID: p.Series.ID, SerNo: serNo}}
+	}
+	if p.Authors != nil {
+		to := make([]int64, 0, len(p.Authors.To))
+		for _, a := range p.Authors.To {
+			if a.ID > 0 {
+				to = append(to, a.ID)
+			}
+		}
+		switch {
+		case p.Authors.From == 0:
+			out.AuthorIDs = appendMissing(nil, to...)
+		case containsID(s.AuthorIDs, p.Authors.From):
+			authors := make([]int64, 0, len(s.AuthorIDs)+len(to))
+			for _, id := range s.AuthorIDs {
+				if id == p.Authors.From {
+					authors = appendMissing(authors, to...)
+					continue
+				}
+				authors = appendMissing(authors, id)
+			}
+			out.AuthorIDs = authors
+		}
+	}
+	return out
+}
+
+// Restore returns current with every field the patch touches put back as it
+// was in before: undoing the patch leaves later edits of other fields be.
+func (p *BookBulkPatch) Restore(current, before BookBulkState) BookBulkState {
+	out := current
+	if p.Lang != nil {
+		out.Lang = before.Lang
+	}
+	if p.Approved != nil {
+		out.Approved = before.Approved
+	}
+	if p.DuplicateHidden != nil {
+		out.DuplicateHidden = before.DuplicateHidden
+	}
+	if len(p.AddGenres) > 0 || len(p.RemoveGenres) > 0 {
+		out.GenreIDs = before.GenreIDs
+	}
+	if p.Series != nil {
+		out.Series = before.Series
+	}
+	if p.Authors != nil {
+		out.AuthorIDs = before.AuthorIDs
+	}
+	return out
+}
+
+// Equal reports whether two states are the same, links in the same order.
+func (s BookBulkState) Equal(o BookBulkState) bool {
+	if s.Lang != o.Lang || s.Approved != o.Approved || s.DuplicateHidden != o.DuplicateHidden {
+		return false
+	}
+	if !equalIDs(s.GenreIDs, o.GenreIDs) || !equalIDs(s.AuthorIDs, o.AuthorIDs) || len(s.Series) != len(o.Series) {
+		return false
+	}
+	for i := range s.Series {
+		if s.Series[i] != o.Series[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func containsID(ids []int64, id int64) bool {
+	for _, v := range ids {
+		if v == id {
+			return true
+		}
+	}
+	return false
+}
+
+// appendMissing appends the ids not already in dst, keeping their order.
+func appendMissing(dst []int64, ids ...int64) []int64 {
+	for _, id := range ids {
+		if !containsID(dst, id) {
+			dst = append(dst, id)
+		}
+	}
+	return dst
+}
+
+func equalIDs(a, b []int64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// BookBulkEdit is one bulk edit: what it selected, the patch — its series
+// and authors resolved to ids — and how far it got.
+type BookBulkEdit struct {
+	tableName  struct{}      `pg:"book_bulk_edits,discard_unknown_columns" json:"-"`
+	ID         int64         `pg:"id,pk" json:"id"`
+	Status     string        `pg:"status" json:"status"`
+	Selection  BookSelection `pg:"selection,type:jsonb" json:"selection"`
+	Patch      BookBulkPatch `pg:"patch,type:jsonb" json:"patch"`
+	Total      int           `pg:"total,use_zero" json:"total"`
+	Processed  int           `pg:"processed,use_zero" json:"processed"`
+	Changed    int           `pg:"changed,use_zero" json:"changed"`
+	Failed     int           `pg:"failed,use_zero" json:"failed"`
+	Error      string        `pg:"error,use_zero" json:"error,omitempty"`
+	CreatedBy  *int64        `pg:"created_by" json:"created_by,omitempty"`
+	CreatedAt  time.Time     `pg:"created_at,default:now()" json:"created_at"`
+	FinishedAt *time.Time    `pg:"finished_at" json:"finished_at,omitempty"`
+	UndoneBy   *int64        `pg:"undone_by" json:"undone_by,omitempty"`
+	UndoneAt   *time.Time    `pg:"undone_at" json:"undone_at,omitempty"`
+}
+
+// Active reports whether the edit is still being applied or undone.
+func (e *BookBulkEdit) Active() bool {
+	return e.Status == BulkEditRunning || e.Status == BulkEditUndoing
+}
+
+// BookBulkEditBook is the undo record of one book a bulk edit changed.
+type BookBulkEditBook struct {
+	tableName struct{}      `pg:"book_bulk_edit_books,discard_unknown_columns" json:"-"`
+	EditID    int64         `pg:"edit_id,pk" json:"edit_id"`
+	BookID    int64         `pg:"book_id,pk" json:"book_id"`
+	Before    BookBulkState `pg:"before,type:jsonb" json:"before"`
+}
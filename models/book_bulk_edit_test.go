@@ -0,0 +1,117 @@
+package models
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func strPtr(s string) *string { return &s }
+func boolPtr(b bool) *bool    { return &b }
+
+func TestBookBulkPatch_Validate(t *testing.T) {
+	tests := []struct {
+		name  string
+		patch BookBulkPatch
+		want  error
+	}{
+		{"empty", BookBulkPatch{}, ErrBulkPatchEmpty},
+		{"lang", BookBulkPatch{Lang: strPtr(" uk ")}, nil},
+		{"blank lang", BookBulkPatch{Lang: strPtr("  ")}, ErrBulkPatchInvalid},
+		{"genre added and removed", BookBulkPatch{AddGenres: []int64{1, 2}, RemoveGenres: []int64{2}}, ErrBulkPatchInvalid},
+		{"series without id or name", BookBulkPatch{Series: &BulkSeriesPatch{Start: 1}}, ErrBulkPatchInvalid},
+		{"series start and keep numbers", BookBulkPatch{Series: &BulkSeriesPatch{ID: 3, Start: 1, KeepNumbers: true}}, ErrBulkPatchInvalid},
+		{"series by name", BookBulkPatch{Series: &BulkSeriesPatch{Name: "Дозоры", Start: 1}}, nil},
+		{"authors without a target", BookBulkPatch{Authors: &BulkAuthorsPatch{From: 4, To: []Author{{FullName: " "}}}}, ErrBulkPatchInvalid},
+		{"authors by name", BookBulkPatch{Authors: &BulkAuthorsPatch{To: []Author{{FullName: "Лукьяненко Сергей"}}}}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.patch.Validate()
+			if tt.want == nil && err != nil {
+				t.Fatalf("Validate() = %v, want nil", err)
+			}
+			if tt.want != nil && !errors.Is(err, tt.want) {
+				t.Fatalf("Validate() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestBookBulkPatch_Validate_TrimsLang(t *testing.T) {
+	p := BookBulkPatch{Lang: strPtr(" uk ")}
+	if err := p.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if *p.Lang != "uk" {
+		t.Errorf("Lang = %q, want %q", *p.Lang, "uk")
+	}
+}
+
+func TestBookBulkPatch_Apply_Genres(t *testing.T) {
+	p := BookBulkPatch{AddGenres: []int64{7, 3}, RemoveGenres: []int64{5}}
+	got := p.Apply(BookBulkState{GenreIDs: []int64{3, 5, 9}}, 0)
+	if want := []int64{3, 9, 7}; !reflect.DeepEqual(got.GenreIDs, want) {
+		t.Errorf("GenreIDs = %v, want %v", got.GenreIDs, want)
+	}
+}
+
+func TestBookBulkPatch_Apply_SeriesNumbering(t *testing.T) {
+	before := BookBulkState{Series: []BookSeriesRef{{ID: 1, SerNo: 4}, {ID: 2, SerNo: 9}}}
+
+	numbered := BookBulkPatch{Series: &BulkSeriesPatch{ID: 8, Start: 10}}
+	if got := numbered.Apply(before, 2).Series; !reflect.DeepEqual(got, []BookSeriesRef{{ID: 8, SerNo: 12}}) {
+		t.Errorf("Start: Series = %v", got)
+	}
+	kept := BookBulkPatch{Series: &BulkSeriesPatch{ID: 8, KeepNumbers: true}}
+	if got := kept.Apply(before, 2).Series; !reflect.DeepEqual(got, []BookSeriesRef{{ID: 8, SerNo: 4}}) {
+		t.Errorf("KeepNumbers: Series = %v", got)
+	}
+	plain := BookBulkPatch{Series: &BulkSeriesPatch{ID: 8}}
+	if got := plain.Apply(before, 2).Series; !reflect.DeepEqual(got, []BookSeriesRef{{ID: 8}}) {
+		t.Errorf("unnumbered: Series = %v", got)
+	}
+}
+
+func TestBookBulkPatch_Apply_ReplacesOneAuthorInPlace(t *testing.T) {
+	p := BookBulkPatch{Authors: &BulkAuthorsPatch{From: 2, To: []Author{{ID: 5}, {ID: 3}}}}
+	got := p.Apply(BookBulkState{AuthorIDs: []int64{1, 2, 3}}, 0)
+	if want := []int64{1, 5, 3}; !reflect.DeepEqual(got.AuthorIDs, want) {
+		t.Errorf("AuthorIDs = %v, want %v", got.AuthorIDs, want)
+	}
+
+	untouched := BookBulkState{AuthorIDs: []int64{1, 3}}
+	if got := p.Apply(untouched, 0); !got.Equal(untouched) {
+		t.Errorf("a book without the author changed: %v", got.AuthorIDs)
+	}
+}
+
+func TestBookBulkPatch_Apply_ReplacesAllAuthors(t *testing.T) {
+	p := BookBulkPatch{Authors: &BulkAuthorsPatch{To: []Author{{ID: 5}, {ID: 5}, {ID: 6}}}}
+	got := p.Apply(BookBulkState{AuthorIDs: []int64{1, 2}}, 0)
+	if want := []int64{5, 6}; !reflect.DeepEqual(got.AuthorIDs, want) {
+		t.Errorf("AuthorIDs = %v, want %v", got.AuthorIDs, want)
+	}
+}
+
+func TestBookBulkPatch_Apply_UnchangedIsEqual(t *testing.T) {
+	before := BookBulkState{Lang: "ru", Approved: true, GenreIDs: []int64{3}}
+	p := BookBulkPatch{Lang: strPtr("ru"), Approved: boolPtr(true), AddGenres: []int64{3}}
+	if got := p.Apply(before, 0); !got.Equal(before) {
+		t.Errorf("patch matching the book reported a change: %+v", got)
+	}
+}
+
+// Undo puts back only what the patch touched; what changed since in other
+// fields stays.
+func TestBookBulkPatch_Restore(t *testing.T) {
+	p := BookBulkPatch{Lang: strPtr("uk"), AddGenres: []int64{9}}
+	before := BookBulkState{Lang: "ru", GenreIDs: []int64{3}, AuthorIDs: []int64{1}}
+	current := BookBulkState{Lang: "uk", Approved: true, GenreIDs: []int64{3, 9}, AuthorIDs: []int64{2}}
+
+	got := p.Restore(current, before)
+	want := BookBulkState{Lang: "ru", Approved: true, GenreIDs: []int64{3}, AuthorIDs: []int64{2}}
+	if !got.Equal(want) {
+		t.Errorf("Restore() = %+v, want %+v", got, want)
+	}
+}
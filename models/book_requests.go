@@ -0,0 +1,150 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Book request states. A request is open until a scan brings in a book it
+// matches, which fulfils it, or until a moderator dismisses it.
+const (
+	BookRequestOpen      = "open"
+	BookRequestFulfilled = "fulfilled"
+	BookRequestDismissed = "dismissed"
+)
+
+// BookRequestStatuses lists the states requests can be listed by.
+var BookRequestStatuses = []string{BookRequestOpen, BookRequestFulfilled, BookRequestDismissed}
+
+// MaxBookRequestFieldLength bounds the title and the author of a request,
+// in characters.
+const MaxBookRequestFieldLength = 300
+
+// ErrBookRequestInvalid reports a book request that cannot be stored.
+var ErrBookRequestInvalid = errors.New("invalid book request")
+
+// BookInLibraryError reports a request for a book the library already has.
+type BookInLibraryError struct {
+	BookID int64
+}
+
+func (e *BookInLibraryError) Error() string {
+	return fmt.Sprintf("book %d is already in the library", e.BookID)
+}
+
+// BookRequest is a book a reader asked the library for. Author and title
+// are kept as asked and normalised, the latter being what requests are
+// matched and told apart by.
+type BookRequest struct {
+	tableName  struct{}   `pg:"book_requests,discard_unknown_columns" json:"-"`
+	ID         int64      `pg:"id,pk" json:"id"`
+	UserID     *int64     `pg:"user_id" json:"-"`
+	Title      string     `pg:"title" json:"title"`
+	Author     string     `pg:"author,use_zero" json:"author"`
+	ISBN       string     `pg:"isbn,use_zero" json:"isbn"`
+	TitleNorm  string     `pg:"title_norm" json:"-"`
+	AuthorNorm string     `pg:"author_norm,use_zero" json:"-"`
+	Status     string     `pg:"status" json:"status"`
+	BookID     *int64     `pg:"book_id" json:"book_id,omitempty"`
+	ClosedAt   *time.Time `pg:"closed_at" json:"closed_at,omitempty"`
+	CreatedAt  time.Time  `pg:"created_at,default:now()" json:"created_at"`
+}
+
+// BookRequestSubmission is a reader asking for a book: a title, and an
+// author and an ISBN if they know them.
+type BookRequestSubmission struct {
+	Title  string `json:"title" binding:"required"`
+	Author string `json:"author"`
+	ISBN   string `json:"isbn"`
+}
+
+// Validate trims the fields, checks their length and reduces the ISBN to
+// its digits.
+func (s *BookRequestSubmission) Validate() error {
+	s.Title = strings.TrimSpace(s.Title)
+	s.Author = strings.TrimSpace(s.Author)
+	if s.Title == "" {
+		return fmt.Errorf("%w: title is required", ErrBookRequestInvalid)
+	}
+	if utf8.RuneCountInString(s.Title) > MaxBookRequestFieldLength ||
+		utf8.RuneCountInString(s.Author) > MaxBookRequestFieldLength {
+		return fmt.Errorf("%w: title and author must be at most %d characters", ErrBookRequestInvalid, MaxBookRequestFieldLength)
+	}
+	isbn, ok := normalizeISBN(s.ISBN)
+	if !ok {
+		return fmt.Errorf("%w: isbn must have 10 or 13 digits", ErrBookRequestInvalid)
+	}
+	s.ISBN = isbn
+	return nil
+}
+
+// normalizeISBN drops the hyphens and spaces of an ISBN and checks what
+// is left: 13 digits, or 10 of which the last may be an X. An empty ISBN
+// is a request without one.
+func normalizeISBN(raw string) (string, bool) {
+	var b strings.Builder
+	for _, r := range strings.ToUpper(raw) {
+		switch {
+		case r == '-' || r == ' ':
+		case r >= '0' && r <= '9', r == 'X':
+			b.WriteRune(r)
+		default:
+			return "", false
+		}
+	}
+	isbn := b.String()
+	switch {
+	case isbn == "":
+		return "", true
+	case len(isbn) == 13 && !strings.Contains(isbn, "X"),
+		len(isbn) == 10 && !strings.Contains(isbn[:9], "X"):
+		return isbn, true
+	}
+	return "", false
+}
+
+// BookRequestView is a request as lists show it, with the number of
+// readers who asked for it and whether the one looking is among them.
+type BookRequestView struct {
+	ID        int64      `json:"id"`
+	Title     string     `json:"title"`
+	Author    string     `json:"author"`
+	ISBN      string     `json:"isbn,omitempty"`
+	Status    string     `json:"status"`
+	BookID    *int64     `json:"book_id,omitempty"`
+	Username  string     `json:"username,omitempty"`
+	Votes     int        `json:"votes"`
+	Voted     bool       `json:"voted"`
+	CreatedAt time.Time  `json:"created_at"`
+	ClosedAt  *time.Time `json:"closed_at,omitempty"`
+}
+
+// BookRequestFilter narrows a request list. ViewerID is the reader
+// looking, whose votes are marked; Mine keeps only the requests they
+// voted for.
+type BookRequestFilter struct {
+	Status   string
+	ViewerID int64
+	Mine     bool
+}
+
+// FulfilledBookRequest is a request a new book fulfilled, and the readers
+// to tell about it.
+type FulfilledBookRequest struct {
+	RequestID int64   `json:"request_id"`
+	Title     string  `json:"title"`
+	Author    string  `json:"author"`
+	BookID    int64   `json:"book_id"`
+	BookTitle string  `json:"book_title"`
+	VoterIDs  []int64 `json:"-"`
+}
+
+// IsBookRequestStatus reports whether s is a state requests can be listed
+// by.
+func IsBookRequestStatus(s string) bool {
+	return slices.Contains(BookRequestStatuses, s)
+}
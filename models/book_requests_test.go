@@ -0,0 +1,40 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestBookRequestSubmission_Validate(t *testing.T) {
+	sub := BookRequestSubmission{Title: "  Roadside Picnic ", Author: " Strugatsky\n", ISBN: "978-0-575-07074-8"}
+	if err := sub.Validate(); err != nil {
+		t.Fatalf("Validate() = %v", err)
+	}
+	if sub.Title != "Roadside Picnic" || sub.Author != "Strugatsky" {
+		t.Errorf("Validate() left %q by %q, want both trimmed", sub.Title, sub.Author)
+	}
+	if sub.ISBN != "9780575070748" {
+		t.Errorf("Validate() left ISBN %q, want its digits", sub.ISBN)
+	}
+
+	for name, s := range map[string]BookRequestSubmission{
+		"no title":         {Title: "  ", Author: "Strugatsky"},
+		"too long title":   {Title: strings.Repeat("я", MaxBookRequestFieldLength+1)},
+		"too long author":  {Title: "Picnic", Author: strings.Repeat("я", MaxBookRequestFieldLength+1)},
+		"short isbn":       {Title: "Picnic", ISBN: "12345"},
+		"letters in isbn":  {Title: "Picnic", ISBN: "978-0-575-O7074-8"},
+		"X inside isbn-10": {Title: "Picnic", ISBN: "05750X7074"},
+	} {
+		if err := s.Validate(); !errors.Is(err, ErrBookRequestInvalid) {
+			t.Errorf("%s: Validate() = %v, want ErrBookRequestInvalid", name, err)
+		}
+	}
+
+	for _, isbn := range []string{"", "0-575-07074-X", "0575070746"} {
+		s := BookRequestSubmission{Title: "Picnic", ISBN: isbn}
+		if err := s.Validate(); err != nil {
+			t.Errorf("ISBN %q: Validate() = %v", isbn, err)
+		}
+	}
+}
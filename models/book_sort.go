@@ -0,0 +1,115 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// The orders a book list can be sorted in, by the names requests use. Each
+// has a natural direction; a leading "-" reverses it. A list asked for in
+// no order keeps its own: newest first for the catalogue, relevance for a
+// search, the reader's order for favorites and the curator's for a
+// collection.
+const (
+	SortTitle   = "title"   // A to Z
+	SortAuthor  = "author"  // by the first author's surname, A to Z
+	SortSeries  = "series"  // by number in the series, first to last
+	SortAdded   = "added"   // latest additions first
+	SortYear    = "year"    // latest publication year first
+	SortPopular = "popular" // most favorites and downloads first
+	SortRating  = "rating"  // highest average rating first, unrated last
+)
+
+var bookSorts = []string{SortTitle, SortAuthor, SortSeries, SortAdded, SortYear, SortPopular, SortRating}
+
+// BookSorts returns the orders a book list can be sorted in.
+func BookSorts() []string {
+	return slices.Clone(bookSorts)
+}
+
+// AddedSinceLayout is the layout of the added_since filter.
+const AddedSinceLayout = "2006-01-02"
+
+// ErrInvalidListParams reports an unknown sort order or a malformed date
+// filter.
+var ErrInvalidListParams = errors.New("invalid list parameters")
+
+// BookSort is an order a list is sorted in.
+type BookSort struct {
+	Key     string
+	Reverse bool
+}
+
+// ParseBookSort reads "key" or "-key". The empty string is no order.
+func ParseBookSort(s string) (BookSort, error) {
+	s = strings.TrimSpace(s)
+	sort := BookSort{Key: strings.TrimPrefix(s, "-"), Reverse: strings.HasPrefix(s, "-")}
+	if sort.Key == "" && !sort.Reverse {
+		return BookSort{}, nil
+	}
+	if !slices.Contains(bookSorts, sort.Key) {
+		return BookSort{}, fmt.Errorf("%w: unknown sort %q", ErrInvalidListParams, s)
+	}
+	return sort, nil
+}
+
+// String is the order as requests write it.
+func (s BookSort) String() string {
+	if s.Reverse {
+		return "-" + s.Key
+	}
+	return s.Key
+}
+
+// BookListParams are the order and date range of a book list as a query
+// string gives them.
+type BookListParams struct {
+	Sort string `form:"sort" json:"sort"`
+	// AddedSince keeps books added to the catalogue on or after a day,
+	// written YYYY-MM-DD.
+	AddedSince string `form:"added_since" json:"added_since"`
+	// YearFrom and YearTo bound the publication year, both inclusive. A
+	// book whose date names no year is outside any such range.
+	YearFrom int `form:"year_from" json:"year_from"`
+	YearTo   int `form:"year_to" json:"year_to"`
+}
+
+// BookListOptions are BookListParams read and checked.
+type BookListOptions struct {
+	Sort       BookSort
+	AddedSince time.Time
+	YearFrom   int
+	YearTo     int
+}
+
+// Options reads and checks the parameters.
+func (p BookListParams) Options() (BookListOptions, error) {
+	sort, err := ParseBookSort(p.Sort)
+	if err != nil {
+		return BookListOptions{}, err
+	}
+	opts := BookListOptions{Sort: sort, YearFrom: p.YearFrom, YearTo: p.YearTo}
+	if p.AddedSince != "" {
+		if opts.AddedSince, err = time.Parse(AddedSinceLayout, strings.TrimSpace(p.AddedSince)); err != nil {
+			return BookListOptions{}, fmt.Errorf("%w: added_since must be YYYY-MM-DD", ErrInvalidListParams)
+		}
+	}
+	if p.YearFrom < 0 || p.YearTo < 0 {
+		return BookListOptions{}, fmt.Errorf("%w: years must not be negative", ErrInvalidListParams)
+	}
+	if p.YearFrom > 0 && p.YearTo > 0 && p.YearFrom > p.YearTo {
+		return BookListOptions{}, fmt.Errorf("%w: year_from is after year_to", ErrInvalidListParams)
+	}
+	return opts, nil
+}
+
+// AddedSincePtr is AddedSince for a query parameter: nil when unset.
+func (o BookListOptions) AddedSincePtr() *time.Time {
+	if o.AddedSince.IsZero() {
+		return nil
+	}
+	return &o.AddedSince
+}
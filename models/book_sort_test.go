@@ -0,0 +1,68 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseBookSort(t *testing.T) {
+	tests := []struct {
+		in   string
+		want BookSort
+		err  bool
+	}{
+		{"", BookSort{}, false},
+		{"title", BookSort{Key: SortTitle}, false},
+		{" -year ", BookSort{Key: SortYear, Reverse: true}, false},
+		{"-", BookSort{}, true},
+		{"-rating", BookSort{Key: SortRating, Reverse: true}, false},
+		{"stars", BookSort{}, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseBookSort(tt.in)
+		if tt.err {
+			if !errors.Is(err, ErrInvalidListParams) {
+				t.Errorf("ParseBookSort(%q) error = %v, want ErrInvalidListParams", tt.in, err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("ParseBookSort(%q) = %+v, %v; want %+v", tt.in, got, err, tt.want)
+		}
+		if got.String() != strings.TrimSpace(tt.in) {
+			t.Errorf("ParseBookSort(%q).String() = %q", tt.in, got.String())
+		}
+	}
+}
+
+func TestBookListParams_Options(t *testing.T) {
+	opts, err := BookListParams{Sort: "-popular", AddedSince: "2024-03-01", YearFrom: 1990, YearTo: 2000}.Options()
+	if err != nil {
+		t.Fatalf("Options() = %v", err)
+	}
+	want := BookListOptions{
+		Sort:       BookSort{Key: SortPopular, Reverse: true},
+		AddedSince: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+		YearFrom:   1990,
+		YearTo:     2000,
+	}
+	if opts != want {
+		t.Fatalf("Options() = %+v, want %+v", opts, want)
+	}
+	if opts.AddedSincePtr() == nil || (BookListOptions{}).AddedSincePtr() != nil {
+		t.Fatal("AddedSincePtr() must be nil exactly when unset")
+	}
+
+	for name, p := range map[string]BookListParams{
+		"unknown sort":   {Sort: "stars"},
+		"malformed date": {AddedSince: "01.03.2024"},
+		"negative year":  {YearFrom: -1},
+		"inverted range": {YearFrom: 2001, YearTo: 2000},
+	} {
+		if _, err := p.Options(); !errors.Is(err, ErrInvalidListParams) {
+			t.Errorf("%s: Options() = %v, want ErrInvalidListParams", name, err)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Upload moderation states. An upload waits as an unapproved book until a
+// moderator approves it, which approves the book, or rejects it, which
+// removes the book.
+const (
+	UploadPending  = "pending"
+	UploadApproved = "approved"
+	UploadRejected = "rejected"
+)
+
+// UploadStatuses lists the states uploads can be listed by.
+var UploadStatuses = []string{UploadPending, UploadApproved, UploadRejected}
+
+// MaxUploadReasonLength bounds the reason a moderator gives, in
+// characters.
+const MaxUploadReasonLength = 500
+
+// ErrUploadInvalid reports an upload request or decision that cannot be
+// acted on.
+var ErrUploadInvalid = errors.New("invalid upload")
+
+// BookUpload is a book a reader sent in. Archive and Entry are where its
+// file is kept; the title is kept as parsed, so that a rejected upload
+// still says what it was.
+type BookUpload struct {
+	tableName   struct{}   `pg:"book_uploads,discard_unknown_columns" json:"-"`
+	ID          int64      `pg:"id,pk" json:"id"`
+	UserID      *int64     `pg:"user_id" json:"-"`
+	BookID      *int64     `pg:"book_id" json:"book_id,omitempty"`
+	FileName    string     `pg:"file_name" json:"file_name"`
+	Size        int64      `pg:"size" json:"size"`
+	MD5         string     `pg:"md5" json:"md5"`
+	Title       string     `pg:"title" json:"title"`
+	Archive     string     `pg:"archive" json:"-"`
+	Entry       string     `pg:"entry" json:"-"`
+	Status      string     `pg:"status" json:"status"`
+	Reason      string     `pg:"reason,use_zero" json:"reason,omitempty"`
+	ModeratedBy *int64     `pg:"moderated_by" json:"-"`
+	ModeratedAt *time.Time `pg:"moderated_at" json:"moderated_at,omitempty"`
+	CreatedAt   time.Time  `pg:"created_at,default:now()" json:"created_at"`
+}
+
+// BookUploadView is an upload as lists show it: for readers, their own;
+// for moderators, the queue. Book is the metadata as the catalogue will
+// show it, with its authors, series and genres; nil once the upload is
+// rejected.
+type BookUploadView struct {
+	BookUpload
+	Username string `pg:"username" json:"username"`
+	Book     *Book  `pg:"-" json:"book,omitempty"`
+}
+
+// BookUploadFilter narrows a list of uploads. An empty Status lists every
+// state; a UserID other than zero lists that reader's uploads alone.
+type BookUploadFilter struct {
+	Status string
+	UserID int64
+}
+
+// UploadModeration is a moderator's decision on an upload, and the reason
+// the uploader is shown.
+type UploadModeration struct {
+	Status string `json:"status" binding:"required"`
+	Reason string `json:"reason"`
+}
+
+// Validate trims the reason and checks that the decision is to approve or
+// to reject.
+func (m *UploadModeration) Validate() error {
+	if m.Status != UploadApproved && m.Status != UploadRejected {
+		return fmt.Errorf("%w: status must be %s or %s", ErrUploadInvalid, UploadApproved, UploadRejected)
+	}
+	m.Reason = strings.TrimSpace(m.Reason)
+	if utf8.RuneCountInString(m.Reason) > MaxUploadReasonLength {
+		return fmt.Errorf("%w: reason must be at most %d characters", ErrUploadInvalid, MaxUploadReasonLength)
+	}
+	return nil
+}
+
+// IsUploadStatus reports whether s is a state uploads can be listed by.
+func IsUploadStatus(s string) bool {
+	return slices.Contains(UploadStatuses, s)
+}
@@ -0,0 +1,27 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestUploadModeration_Validate(t *testing.T) {
+	decision := UploadModeration{Status: UploadRejected, Reason: "  not a book \n"}
+	if err := decision.Validate(); err != nil {
+		t.Fatalf("Validate() = %v", err)
+	}
+	if decision.Reason != "not a book" {
+		t.Errorf("Validate() left reason %q, want it trimmed", decision.Reason)
+	}
+
+	for name, d := range map[string]UploadModeration{
+		"no decision":     {},
+		"back to pending": {Status: UploadPending},
+		"too long reason": {Status: UploadRejected, Reason: strings.Repeat("я", MaxUploadReasonLength+1)},
+	} {
+		if err := d.Validate(); !errors.Is(err, ErrUploadInvalid) {
+			t.Errorf("%s: Validate() = %v, want ErrUploadInvalid", name, err)
+		}
+	}
+}
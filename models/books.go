@@ -127,6 +127,10 @@ type Catalog struct {
 	ScannedAt   *time.Time `pg:"scanned_at" json:"scanned_at,omitempty" form:"scanned_at"`
 	BooksCount  int        `pg:"books_count" json:"books_count" form:"books_count"`
 	ErrorsCount int        `pg:"errors_count" json:"errors_count" form:"errors_count"`
+	// InpxChecksum identifies the INPX description the archive was last
+	// imported from, see inpx.Index.Checksum. Empty for archives scanned
+	// without an index.
+	InpxChecksum string `pg:"inpx_checksum" json:"-"`
 }
 
 // Book struct for books
@@ -141,22 +145,34 @@ type Book struct {
 	Lang         string    `pg:"lang,use_zero" json:"lang"`
 	Title        string    `pg:"title" json:"title"`
 	Cover        bool      `pg:"cover" json:"cover"`
-	Annotation   string    `pg:"annotation,use_zero" json:"annotation"`
-	Fav          bool      `pg:"-" json:"fav"`
+	// CoverBlurhash and CoverColor are what a client paints while the cover
+	// loads, see posters.Placeholder. Empty until computed, and for books
+	// whose poster could not be read.
+	CoverBlurhash string `pg:"cover_blurhash" json:"cover_blurhash,omitempty"`
+	CoverColor    string `pg:"cover_color" json:"cover_color,omitempty"`
+	Annotation    string `pg:"annotation,use_zero" json:"annotation"`
+	Fav           bool   `pg:"-" json:"fav"`
 	// approved and duplicate_hidden are NOT NULL in the schema, and go-pg
 	// writes a zero value as NULL unless told otherwise — so a full-model
 	// update of an unapproved or unhidden book violated the constraint.
 	Approved        bool      `pg:"approved,use_zero" json:"approved"`
 	MD5             string    `pg:"md5" json:"md5"`
+	TextSimhash     *int64    `pg:"text_simhash" json:"-"` // nil until computed, 0 without body text
 	DuplicateHidden bool      `pg:"duplicate_hidden,use_zero" json:"duplicate_hidden"`
 	DuplicateOfID   *int64    `pg:"duplicate_of_id" json:"duplicate_of_id,omitempty"`
+	LibID           string    `pg:"lib_id" json:"lib_id,omitempty"`          // id in the library an INPX index came from
+	LibDeleted      bool      `pg:"lib_deleted,use_zero" json:"lib_deleted"` // withdrawn by that library
 	Authors         []Author  `pg:"many2many:opds_catalog_bauthor,join_fk:author_id" json:"authors"`
 	Series          []*Series `pg:"many2many:opds_catalog_bseries,join_fk:ser_id" json:"series"`
 	Genres          []Genre   `pg:"many2many:opds_catalog_bgenre,join_fk:genre_id" json:"genres"`
 	Users           []*User   `pg:"many2many:favorite_books,join_fk:book_id" json:"favorites"`
 	Covers          []*Cover  `pg:"covers,rel:has-many" json:"covers"`
 	FavoriteCount   int       `pg:"-" json:"favorite_count"`
-	Position        int       `pg:"-" json:"position"`
+	// Rating is the average of the readers' stars, zero when nobody rated
+	// the book; RatingCount is how many did.
+	Rating      float64 `pg:"-" json:"rating"`
+	RatingCount int     `pg:"-" json:"rating_count"`
+	Position    int     `pg:"-" json:"position"`
 }
 
 func (b *Book) DownloadName() string {
@@ -194,6 +210,9 @@ type Genre struct {
 	ID        int64    `pg:"id" json:"id"`
 	Genre     string   `pg:"genre" json:"-"`
 	Title     string   `pg:"title" json:"-"`
+	TitleEn   string   `pg:"title_en,use_zero" json:"-"`
+	SectionID *int64   `pg:"section_id" json:"-"`
+	Position  int      `pg:"position,use_zero" json:"-"`
 }
 
 // DisplayName returns Title if set, otherwise falls back to Genre.
@@ -204,6 +223,15 @@ func (g Genre) DisplayName() string {
 	return g.Genre
 }
 
+// LocalizedName returns the name for an interface language: the English
+// name for "en" when there is one, DisplayName otherwise.
+func (g Genre) LocalizedName(lang string) string {
+	if lang == "en" && g.TitleEn != "" {
+		return g.TitleEn
+	}
+	return g.DisplayName()
+}
+
 // MarshalJSON provides custom JSON with "genre" field using DisplayName fallback.
 func (g Genre) MarshalJSON() ([]byte, error) {
 	type genreJSON struct {
@@ -267,6 +295,9 @@ type BookFilters struct {
 	CuratedCollection int64 `form:"curated_collection" json:"curated_collection"`
 	IncludeHidden     bool  `form:"include_hidden" json:"include_hidden"`
 	Genre             int   `form:"genre" json:"genre"`
+	// GenreSection filters books by any genre of a taxonomy section.
+	GenreSection int64 `form:"genre_section" json:"genre_section"`
+	BookListParams
 }
 
 // CollectionFilters params for filtering collections list
@@ -308,7 +339,7 @@ type BookUpdateRequest struct {
 type AutocompleteSuggestion struct {
 	Value      string `json:"value"`
 	Secondary  string `json:"secondary,omitempty"`
-	Type       string `json:"type"` // "book" or "author"
+	Type       string `json:"type"` // "book", "author", "series" or "genre"
 	ID         int64  `json:"id,omitempty"`
 	BooksCount int    `json:"books_count,omitempty"`
 }
@@ -322,6 +353,28 @@ type Language struct {
 // Languages is a slice of Language
 type Languages []Language
 
+// Duplicate candidate statuses.
+const (
+	DuplicateCandidatePending   = "pending"
+	DuplicateCandidateResolved  = "resolved"
+	DuplicateCandidateDismissed = "dismissed"
+)
+
+// DuplicateCandidate is a group of books a duplicate scan believes to be the
+// same work, awaiting an admin's decision.
+type DuplicateCandidate struct {
+	tableName  struct{}   `pg:"book_duplicate_candidates,discard_unknown_columns" json:"-"`
+	ID         int64      `pg:"id,pk" json:"id"`
+	JobID      *int64     `pg:"job_id" json:"job_id,omitempty"`
+	MatchKey   string     `pg:"match_key" json:"match_key"`
+	Score      float64    `pg:"score" json:"score"`
+	BookIDs    []int64    `pg:"book_ids,array" json:"book_ids"`
+	Status     string     `pg:"status" json:"status"`
+	WinnerID   *int64     `pg:"winner_id" json:"winner_id,omitempty"`
+	CreatedAt  time.Time  `pg:"created_at,default:now()" json:"created_at"`
+	ReviewedAt *time.Time `pg:"reviewed_at" json:"reviewed_at,omitempty"`
+}
+
 // AdminScanJob struct for tracking duplicate scan job progress
 type AdminScanJob struct {
 	tableName       struct{}   `pg:"admin_scan_jobs,discard_unknown_columns" json:"-"`
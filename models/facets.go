@@ -0,0 +1,51 @@
+package models
+
+// Facets of a book search, as the repository names them. Each one is a list
+// filter a client can apply back: language is lang, genre, author and series
+// are their IDs, and a decade is the year range from its first year to nine
+// years later.
+const (
+	FacetLanguage = "language"
+	FacetGenre    = "genre"
+	FacetAuthor   = "author"
+	FacetSeries   = "series"
+	FacetDecade   = "decade"
+)
+
+// BookFacets counts the books of a search result by language, genre, author,
+// series and decade of publication, the commonest values of each first.
+type BookFacets struct {
+	Language []FacetCount `json:"language"`
+	Genre    []FacetCount `json:"genre"`
+	Author   []FacetCount `json:"author"`
+	Series   []FacetCount `json:"series"`
+	Decade   []FacetCount `json:"decade"`
+	// Sampled is set when the result was too large to count whole and the
+	// counts cover its most relevant books only.
+	Sampled bool `json:"sampled"`
+}
+
+// FacetCount is one value of a facet: what to filter by, what to show and
+// how many books of the result have it.
+type FacetCount struct {
+	Value string `json:"value"`
+	Label string `json:"label"`
+	Count int    `json:"count"`
+}
+
+// Add files a counted value under its facet. A facet it does not know is
+// ignored.
+func (f *BookFacets) Add(facet string, count FacetCount) {
+	switch facet {
+	case FacetLanguage:
+		f.Language = append(f.Language, count)
+	case FacetGenre:
+		f.Genre = append(f.Genre, count)
+	case FacetAuthor:
+		f.Author = append(f.Author, count)
+	case FacetSeries:
+		f.Series = append(f.Series, count)
+	case FacetDecade:
+		f.Decade = append(f.Decade, count)
+	}
+}
@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+// GenreSection is a group of genres readers browse by, e.g. "Детективы и
+// триллеры" over det_classic, det_police and the rest.
+type GenreSection struct {
+	tableName struct{} `pg:"genre_sections,discard_unknown_columns" json:"-"`
+	ID        int64    `pg:"id,pk" json:"id"`
+	Code      string   `pg:"code" json:"code"`
+	Title     string   `pg:"title" json:"title"`
+	TitleEn   string   `pg:"title_en,use_zero" json:"title_en"`
+	Position  int      `pg:"position,use_zero" json:"position"`
+}
+
+// LocalizedName returns the name for an interface language: the English
+// name for "en" when there is one, the Russian one otherwise.
+func (s GenreSection) LocalizedName(lang string) string {
+	if lang == "en" && s.TitleEn != "" {
+		return s.TitleEn
+	}
+	return s.Title
+}
+
+// GenreAlias maps a tag found in files — a legacy code, a typo — to the
+// genre it means. Scanning links a book tagged with the alias to that genre.
+type GenreAlias struct {
+	tableName struct{}  `pg:"genre_aliases,discard_unknown_columns" json:"-"`
+	Alias     string    `pg:"alias,pk" json:"alias"`
+	GenreID   int64     `pg:"genre_id" json:"genre_id"`
+	CreatedAt time.Time `pg:"created_at,default:now()" json:"created_at"`
+}
@@ -0,0 +1,38 @@
+package models
+
+import (
+	"regexp"
+	"strings"
+	"time"
+)
+
+// UserIdentity is an account at an external OpenID provider that logs in
+// as a local user.
+type UserIdentity struct {
+	tableName   struct{}   `pg:"user_identities" json:"-"`
+	ID          int64      `pg:"id,pk" json:"id"`
+	UserID      int64      `pg:"user_id" json:"-"`
+	Issuer      string     `pg:"issuer" json:"issuer"`
+	Subject     string     `pg:"subject" json:"subject"`
+	Email       string     `pg:"email,use_zero" json:"email"`
+	CreatedAt   time.Time  `pg:"created_at,default:now()" json:"created_at"`
+	LastLoginAt *time.Time `pg:"last_login_at" json:"last_login_at,omitempty"`
+}
+
+var loginDisallowed = regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
+
+// LoginFromExternal makes a local username out of what a provider knows a
+// person by: the preferred username, else the part of the email before
+// the @. Characters a local username may not hold are dropped; the result
+// may be empty, and may be taken.
+func LoginFromExternal(preferred, email string) string {
+	candidate := preferred
+	if candidate == "" {
+		candidate, _, _ = strings.Cut(email, "@")
+	}
+	login := loginDisallowed.ReplaceAllString(candidate, "")
+	if len(login) > 30 {
+		login = login[:30]
+	}
+	return login
+}
@@ -0,0 +1,20 @@
+package models
+
+import "testing"
+
+func TestLoginFromExternal(t *testing.T) {
+	tests := []struct {
+		preferred, email, want string
+	}{
+		{"anna.k", "anna@example.com", "annak"},
+		{"", "boris_1@example.com", "boris_1"},
+		{"", "", ""},
+		{"Вера", "", ""},
+		{"", "a-very-long-local-part-of-an-address@example.com", "a-very-long-local-part-of-an-a"},
+	}
+	for _, tt := range tests {
+		if got := LoginFromExternal(tt.preferred, tt.email); got != tt.want {
+			t.Errorf("LoginFromExternal(%q, %q) = %q, want %q", tt.preferred, tt.email, got, tt.want)
+		}
+	}
+}
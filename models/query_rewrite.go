@@ -0,0 +1,180 @@
+package models
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// The ways a search query can be rewritten when the text as typed finds
+// little.
+const (
+	// RewriteLayout retypes the query on the other keyboard layout:
+	// "djqyf b vbh" typed with the English layout on is "война и мир".
+	RewriteLayout = "layout"
+	// RewriteTranslit reads the query as transliteration, either way:
+	// "voina i mir" is "воина и мир", "шерлок" is "sherlok".
+	RewriteTranslit = "translit"
+)
+
+// QueryRewrite names the query a search answered instead of the one it was
+// asked, so a client can say "showing results for …".
+type QueryRewrite struct {
+	Query string `json:"query"`
+	Kind  string `json:"kind"`
+}
+
+// latinKeys and cyrillicKeys are the same keys of a QWERTY and a ЙЦУКЕН
+// keyboard, position for position.
+const (
+	latinKeys    = "`qwertyuiop[]asdfghjkl;'zxcvbnm,."
+	cyrillicKeys = "ёйцукенгшщзхъфывапролджэячсмитьбю"
+)
+
+var (
+	latinToCyrillicKeys = keyMap(latinKeys, cyrillicKeys)
+	cyrillicToLatinKeys = keyMap(cyrillicKeys, latinKeys)
+)
+
+func keyMap(from, to string) map[rune]rune {
+	src, dst := []rune(from), []rune(to)
+	m := make(map[rune]rune, len(src))
+	for i := range src {
+		m[src[i]] = dst[i]
+	}
+	return m
+}
+
+// SwapKeyboardLayout retypes s on the other layout: Latin keys become the
+// Cyrillic ones in the same place and the other way round. It reports false
+// when s mixes both alphabets, or has neither, since then there is no
+// layout it was typed in by mistake. The result is lower case.
+func SwapKeyboardLayout(s string) (string, bool) {
+	var keys map[rune]rune
+	switch queryScript(s) {
+	case scriptLatin:
+		keys = latinToCyrillicKeys
+	case scriptCyrillic:
+		keys = cyrillicToLatinKeys
+	default:
+		return "", false
+	}
+	return strings.Map(func(r rune) rune {
+		if k, ok := keys[r]; ok {
+			return k
+		}
+		return r
+	}, strings.ToLower(s)), true
+}
+
+// TranslitQuery reads s as transliteration: a Cyrillic query is spelled in
+// Latin the way Translit spells file names, a Latin one is read back into
+// Cyrillic by the common spellings ("zh", "sh", "ya" and "ja", "y" after a
+// vowel as "й"). It reports false when s mixes both alphabets or has
+// neither. The result is lower case.
+func TranslitQuery(s string) (string, bool) {
+	switch queryScript(s) {
+	case scriptLatin:
+		return untranslit(strings.ToLower(s)), true
+	case scriptCyrillic:
+		return strings.ToLower(Translit(s)), true
+	default:
+		return "", false
+	}
+}
+
+// translitPair is a Latin spelling of one Cyrillic letter.
+type translitPair struct{ latin, cyrillic string }
+
+// untranslitPairs are tried longest first at every position.
+var untranslitPairs = []translitPair{
+	{"shch", "щ"}, {"sch", "щ"}, {"shh", "щ"},
+	{"zh", "ж"}, {"kh", "х"}, {"ts", "ц"}, {"ch", "ч"}, {"sh", "ш"},
+	{"yo", "ё"}, {"jo", "ё"}, {"yu", "ю"}, {"ju", "ю"}, {"ya", "я"}, {"ja", "я"},
+	{"ye", "е"}, {"je", "э"},
+}
+
+var untranslitLetters = map[byte]string{
+	'a': "а", 'b': "б", 'c': "ц", 'd': "д", 'e': "е", 'f': "ф", 'g': "г",
+	'h': "х", 'i': "и", 'j': "й", 'k': "к", 'l': "л", 'm': "м", 'n': "н",
+	'o': "о", 'p': "п", 'q': "к", 'r': "р", 's': "с", 't': "т", 'u': "у",
+	'v': "в", 'w': "в", 'x': "кс", 'y': "ы", 'z': "з",
+}
+
+// untranslit spells a lower-case Latin s in Cyrillic. A "y" is "й" after a
+// vowel ("tolstoy") and "ий" at the end of a word after a consonant
+// ("dostoevsky"); anywhere else it is "ы".
+func untranslit(s string) string {
+	var b strings.Builder
+	prevVowel := false
+	for i := 0; i < len(s); {
+		if p, ok := untranslitPair(s[i:]); ok {
+			b.WriteString(p.cyrillic)
+			i += len(p.latin)
+			prevVowel = strings.ContainsAny(p.cyrillic, "ёюяеэ")
+			continue
+		}
+		c := s[i]
+		wordEnd := i+1 == len(s) || !unicode.IsLetter(rune(s[i+1]))
+		switch {
+		case c == 'y' && prevVowel:
+			b.WriteString("й")
+		case c == 'y' && wordEnd && i > 0 && unicode.IsLetter(rune(s[i-1])):
+			b.WriteString("ий")
+		case untranslitLetters[c] != "":
+			b.WriteString(untranslitLetters[c])
+		default:
+			// Not a letter: copied whole, however many bytes it takes.
+			_, size := utf8.DecodeRuneInString(s[i:])
+			b.WriteString(s[i : i+size])
+			i += size
+			prevVowel = false
+			continue
+		}
+		prevVowel = strings.IndexByte("aeiou", c) >= 0
+		i++
+	}
+	return b.String()
+}
+
+func untranslitPair(s string) (translitPair, bool) {
+	for _, p := range untranslitPairs {
+		if strings.HasPrefix(s, p.latin) {
+			return p, true
+		}
+	}
+	return translitPair{}, false
+}
+
+const (
+	scriptNone = iota
+	scriptLatin
+	scriptCyrillic
+	scriptMixed
+)
+
+// queryScript tells which alphabet the letters of s are in. Letters of any
+// other alphabet make it mixed: there is nothing to swap or spell.
+func queryScript(s string) int {
+	latin, cyrillic, other := false, false, false
+	for _, r := range s {
+		switch {
+		case r < unicode.MaxASCII && unicode.IsLetter(r):
+			latin = true
+		case unicode.Is(unicode.Cyrillic, r):
+			cyrillic = true
+		case unicode.IsLetter(r):
+			other = true
+		}
+	}
+	switch {
+	case other || (latin && cyrillic):
+		return scriptMixed
+	case latin:
+		return scriptLatin
+	case cyrillic:
+		return scriptCyrillic
+	default:
+		return scriptNone
+	}
+}
@@ -0,0 +1,46 @@
+package models
+
+import "testing"
+
+func TestSwapKeyboardLayout(t *testing.T) {
+	tests := []struct {
+		in, want string
+		ok       bool
+	}{
+		{"djqyf b vbh", "война и мир", true},
+		{"Vfcnth b Vfhufhbnf", "мастер и маргарита", true},
+		{"t;br", "ежик", true},
+		{"Цфк фтв зуфсу", "war and peace", true},
+		{"война и war", "", false},
+		{"1984", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := SwapKeyboardLayout(tt.in)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("SwapKeyboardLayout(%q) = %q, %v; want %q, %v", tt.in, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestTranslitQuery(t *testing.T) {
+	tests := []struct {
+		in, want string
+		ok       bool
+	}{
+		{"voina i mir", "воина и мир", true},
+		{"Voyna i mir", "война и мир", true},
+		{"tolstoy", "толстой", true},
+		{"dostoevsky", "достоевский", true},
+		{"doktor zhivago", "доктор живаго", true},
+		{"shchukin", "щукин", true},
+		{"Шерлок Холмс", "sherlok holms", true},
+		{"мастер и margarita", "", false},
+		{"451", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := TranslitQuery(tt.in)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("TranslitQuery(%q) = %q, %v; want %q, %v", tt.in, got, ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Review moderation states. A rating without a review text has none; a
+// new or changed text waits for a moderator, who approves or rejects it.
+// Only approved reviews are shown to other readers.
+const (
+	ReviewNone     = "none"
+	ReviewPending  = "pending"
+	ReviewApproved = "approved"
+	ReviewRejected = "rejected"
+)
+
+// ReviewStatuses lists the states a moderator can list reviews by.
+var ReviewStatuses = []string{ReviewPending, ReviewApproved, ReviewRejected}
+
+// Rating bounds, in stars, and the longest review text, in characters.
+const (
+	MinRating       = 1
+	MaxRating       = 5
+	MaxReviewLength = 2000
+)
+
+// ErrRatingInvalid reports a rating request that cannot be stored.
+var ErrRatingInvalid = errors.New("invalid rating")
+
+// BookRating is a reader's rating of a book, with their review if they
+// wrote one. A reader has one rating per book.
+type BookRating struct {
+	tableName    struct{}   `pg:"book_ratings,discard_unknown_columns" json:"-"`
+	ID           int64      `pg:"id,pk" json:"id"`
+	UserID       int64      `pg:"user_id" json:"-"`
+	BookID       int64      `pg:"book_id" json:"book_id"`
+	Rating       int        `pg:"rating" json:"rating"`
+	Review       string     `pg:"review,use_zero" json:"review"`
+	ReviewStatus string     `pg:"review_status" json:"review_status"`
+	ModeratedBy  *int64     `pg:"moderated_by" json:"-"`
+	ModeratedAt  *time.Time `pg:"moderated_at" json:"moderated_at,omitempty"`
+	CreatedAt    time.Time  `pg:"created_at,default:now()" json:"created_at"`
+	UpdatedAt    time.Time  `pg:"updated_at,default:now()" json:"updated_at"`
+}
+
+// RatingRequest is a reader's stars for a book and, optionally, a review.
+type RatingRequest struct {
+	Rating int    `json:"rating" binding:"required"`
+	Review string `json:"review"`
+}
+
+// Validate trims the review and checks the stars and the review length.
+func (r *RatingRequest) Validate() error {
+	if r.Rating < MinRating || r.Rating > MaxRating {
+		return fmt.Errorf("%w: rating must be %d-%d", ErrRatingInvalid, MinRating, MaxRating)
+	}
+	r.Review = strings.TrimSpace(r.Review)
+	if utf8.RuneCountInString(r.Review) > MaxReviewLength {
+		return fmt.Errorf("%w: review must be at most %d characters", ErrRatingInvalid, MaxReviewLength)
+	}
+	return nil
+}
+
+// BookReview is a review as lists show it: for readers, the approved
+// reviews of one book; for moderators, the queue across books.
+type BookReview struct {
+	ID           int64     `json:"id"`
+	BookID       int64     `json:"book_id"`
+	BookTitle    string    `json:"book_title,omitempty"`
+	Username     string    `json:"username"`
+	Rating       int       `json:"rating"`
+	Review       string    `json:"review"`
+	ReviewStatus string    `json:"review_status,omitempty"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// ReviewModeration is a moderator's decision on a review.
+type ReviewModeration struct {
+	Status string `json:"status" binding:"required"`
+}
+
+// Validate checks that the decision is to approve or to reject.
+func (m ReviewModeration) Validate() error {
+	if m.Status != ReviewApproved && m.Status != ReviewRejected {
+		return fmt.Errorf("%w: status must be %s or %s", ErrRatingInvalid, ReviewApproved, ReviewRejected)
+	}
+	return nil
+}
+
+// IsReviewStatus reports whether s is a state reviews can be listed by.
+func IsReviewStatus(s string) bool {
+	return slices.Contains(ReviewStatuses, s)
+}
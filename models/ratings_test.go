@@ -0,0 +1,48 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestRatingRequest_Validate(t *testing.T) {
+	req := RatingRequest{Rating: 4, Review: "  Worth reading.\n"}
+	if err := req.Validate(); err != nil {
+		t.Fatalf("Validate() = %v", err)
+	}
+	if req.Review != "Worth reading." {
+		t.Errorf("Validate() left review %q, want it trimmed", req.Review)
+	}
+
+	for name, r := range map[string]RatingRequest{
+		"no stars":        {Rating: 0},
+		"too many stars":  {Rating: 6},
+		"too long review": {Rating: 3, Review: strings.Repeat("я", MaxReviewLength+1)},
+		"negative rating": {Rating: -1, Review: "fine"},
+	} {
+		if err := r.Validate(); !errors.Is(err, ErrRatingInvalid) {
+			t.Errorf("%s: Validate() = %v, want ErrRatingInvalid", name, err)
+		}
+	}
+
+	longest := RatingRequest{Rating: 5, Review: strings.Repeat("я", MaxReviewLength)}
+	if err := longest.Validate(); err != nil {
+		t.Errorf("a review of %d characters: Validate() = %v", MaxReviewLength, err)
+	}
+}
+
+func TestReviewModeration_Validate(t *testing.T) {
+	for status, ok := range map[string]bool{
+		ReviewApproved: true,
+		ReviewRejected: true,
+		ReviewPending:  false,
+		ReviewNone:     false,
+		"":             false,
+	} {
+		err := ReviewModeration{Status: status}.Validate()
+		if ok != (err == nil) {
+			t.Errorf("ReviewModeration{%q}.Validate() = %v", status, err)
+		}
+	}
+}
@@ -0,0 +1,19 @@
+package models
+
+// RecommendationRequest asks for books like some others. With a BookID the
+// seed is that one book ("more like this"); without one it is what the user
+// favorited and downloaded lately ("recommended for you").
+type RecommendationRequest struct {
+	BookID int64
+	// UserID is the caller: their favorite marks are set on the books, and
+	// a personal list leaves out what they already have.
+	UserID   int64
+	Language string
+	Limit    int
+}
+
+// Personal reports whether the request is for the caller's own list rather
+// than for books like one book.
+func (r RecommendationRequest) Personal() bool {
+	return r.BookID == 0
+}
@@ -0,0 +1,53 @@
+package models
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestRole_Validate(t *testing.T) {
+	r := Role{Name: " editor ", Description: " Fixes titles ", Permissions: []string{PermLibraryScan, PermBooksEdit, PermLibraryScan}}
+	if err := r.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if r.Name != "editor" || r.Description != "Fixes titles" {
+		t.Errorf("not trimmed: %q, %q", r.Name, r.Description)
+	}
+	if want := []string{PermBooksEdit, PermLibraryScan}; !reflect.DeepEqual(r.Permissions, want) {
+		t.Errorf("Permissions = %v, want %v", r.Permissions, want)
+	}
+}
+
+func TestRole_Validate_Rejects(t *testing.T) {
+	tests := []struct {
+		name string
+		role Role
+	}{
+		{"empty name", Role{Name: " "}},
+		{"upper case", Role{Name: "Moderator"}},
+		{"starts with a digit", Role{Name: "1st"}},
+		{"too long", Role{Name: "abcdefghijklmnopqrstuvwxyzabcdefg"}},
+		{"unknown permission", Role{Name: "x", Permissions: []string{"books.burn"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.role.Validate(); !errors.Is(err, ErrRoleInvalid) {
+				t.Fatalf("Validate() = %v, want %v", err, ErrRoleInvalid)
+			}
+		})
+	}
+}
+
+func TestHasPermission(t *testing.T) {
+	perms := []string{PermBooksEdit}
+	if !HasPermission(false, perms, PermBooksEdit) {
+		t.Error("a granted permission was refused")
+	}
+	if HasPermission(false, perms, PermUsersManage) {
+		t.Error("a permission not granted was allowed")
+	}
+	if !HasPermission(true, nil, PermUsersManage) {
+		t.Error("a superuser was refused")
+	}
+}
@@ -23,8 +23,13 @@ type BookSearchRequest struct {
 	// without this declaration both widening flags above are cleared before
 	// the request reaches the repository.
 	Moderator bool
-	Limit     int
-	Offset    int
+	// Options sort the results, relevance breaking ties, and bound their
+	// dates.
+	Options BookListOptions
+	// Facets asks for the result's facet counts besides the page.
+	Facets bool
+	Limit  int
+	Offset int
 }
 
 // BookSearchPage is one ranked page plus the uncapped exact total computed
@@ -35,6 +40,14 @@ type BookSearchPage struct {
 	Limit     int
 	Offset    int
 	QueryHash string
+	// Rewrite is set when the query as typed found little and the page
+	// answers a rewritten one, below whatever the original found.
+	Rewrite *QueryRewrite
+	// DidYouMean are queries that find something, best first, when this
+	// one found nothing.
+	DidYouMean []SpellingSuggestion
+	// Facets counts the whole result by facet, when the request asked.
+	Facets *BookFacets
 }
 
 // AuthorSearchRequest carries a validated author search.
@@ -52,6 +65,9 @@ type AuthorSearchPage struct {
 	Limit     int
 	Offset    int
 	QueryHash string
+	// Rewrite is set when the query as typed found little and the page
+	// answers a rewritten one, below whatever the original found.
+	Rewrite *QueryRewrite
 }
 
 // SuggestionKind selects which autocomplete lanes answer a request.
@@ -61,6 +77,8 @@ const (
 	SuggestionAll    SuggestionKind = "all"
 	SuggestionBook   SuggestionKind = "title"
 	SuggestionAuthor SuggestionKind = "author"
+	SuggestionSeries SuggestionKind = "series"
+	SuggestionGenre  SuggestionKind = "genre"
 )
 
 // SuggestionRequest carries a validated autocomplete request.
@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+// Kinds of session a user can see and revoke.
+const (
+	// SessionWeb is a login to the web interface: a refresh token and the
+	// access tokens issued from it.
+	SessionWeb = "web"
+	// SessionOPDS is an OPDS reader, which sends the password with every
+	// request rather than logging in.
+	SessionOPDS = "opds"
+	// SessionTelegram is the user's Telegram bot and the account linked to
+	// it.
+	SessionTelegram = "telegram"
+)
+
+// Session is one place a user is signed in from.
+type Session struct {
+	ID   string `json:"id"`
+	Kind string `json:"kind"`
+	// Device is a short name for the client, read from its user agent:
+	// "Firefox on Linux", "KOReader".
+	Device    string `json:"device"`
+	IP        string `json:"ip,omitempty"`
+	UserAgent string `json:"user_agent,omitempty"`
+	// CreatedAt and LastSeenAt are unknown for the Telegram bot.
+	CreatedAt  *time.Time `json:"created_at,omitempty"`
+	LastSeenAt *time.Time `json:"last_seen_at,omitempty"`
+	// Current marks the session the request listing them came from.
+	Current bool `json:"current,omitempty"`
+}
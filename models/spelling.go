@@ -0,0 +1,24 @@
+package models
+
+// SpellingSuggestion is a query a search that found nothing may have meant,
+// with the number of books it finds.
+type SpellingSuggestion struct {
+	Query string `json:"query"`
+	Total int    `json:"total"`
+}
+
+// TokenCandidates is one word of a query as the search dictionary reads
+// it: normalized, whether the catalogue uses it at all, and the dictionary
+// words that look most like it.
+type TokenCandidates struct {
+	Token      string
+	Known      bool
+	Candidates []DictionaryWord
+}
+
+// DictionaryWord is a word of the catalogue's titles and author names, with
+// the number of titles and names it occurs in.
+type DictionaryWord struct {
+	Token     string
+	Frequency int
+}
@@ -0,0 +1,57 @@
+package models
+
+import "time"
+
+// UserTOTP is a user's TOTP secret, encrypted. It is in use once
+// ConfirmedAt is set.
+type UserTOTP struct {
+	tableName   struct{}   `pg:"user_totp" json:"-"`
+	UserID      int64      `pg:"user_id,pk" json:"-"`
+	Secret      string     `pg:"secret" json:"-"`
+	ConfirmedAt *time.Time `pg:"confirmed_at" json:"confirmed_at,omitempty"`
+	LastStep    int64      `pg:"last_step,use_zero" json:"-"`
+	CreatedAt   time.Time  `pg:"created_at,default:now()" json:"created_at"`
+}
+
+// RecoveryCode is one single-use recovery code, hashed.
+type RecoveryCode struct {
+	tableName struct{}   `pg:"user_recovery_codes" json:"-"`
+	ID        int64      `pg:"id,pk" json:"-"`
+	UserID    int64      `pg:"user_id" json:"-"`
+	CodeHash  string     `pg:"code_hash" json:"-"`
+	UsedAt    *time.Time `pg:"used_at" json:"-"`
+}
+
+// TwoFactorStatus is what a user sees of their second factor.
+type TwoFactorStatus struct {
+	Enabled           bool `json:"enabled"`
+	RecoveryCodesLeft int  `json:"recovery_codes_left"`
+	// Required is set for accounts that may not use the admin API without
+	// a second factor.
+	Required bool `json:"required"`
+}
+
+// TwoFactorEnrolment is what an authenticator app needs to add an account:
+// the secret, and the otpauth:// URI to show as a QR code.
+type TwoFactorEnrolment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
+// TwoFactorChallenge answers a login whose password was right but which
+// still owes a second factor.
+type TwoFactorChallenge struct {
+	Required  bool   `json:"two_factor_required"`
+	Challenge string `json:"challenge"`
+}
+
+// TwoFactorLoginRequest completes a login with a TOTP or recovery code.
+type TwoFactorLoginRequest struct {
+	Challenge string `json:"challenge" binding:"required"`
+	Code      string `json:"code" binding:"required"`
+}
+
+// TwoFactorCodeRequest carries a TOTP or recovery code.
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
@@ -48,6 +48,9 @@ type User struct {
 	Active          bool                `pg:"active" json:"active"`
 	Collections     []BookCollection    `pg:"rel:has-many" json:"collections"`
 	TelegramRequest UserTelegramRequest `pg:"-" json:"-"`
+	// Permissions come from the user's roles. Loaded where a session is
+	// opened, to be carried in the access token.
+	Permissions []string `pg:"-" json:"permissions,omitempty"`
 }
 
 // LoggedInUser struct for user table with token
@@ -61,6 +64,7 @@ type LoggedInUser struct {
 	Token         *string          `json:"token,omitempty"`
 	Collections   []BookCollection `json:"collections"`
 	IsSuperuser   *bool            `json:"is_superuser,omitempty"`
+	Permissions   []string         `json:"permissions,omitempty"`
 	HasBotToken   *bool            `json:"has_bot_token,omitempty"`
 	DateJoined    *time.Time       `json:"date_joined,omitempty"`
 }
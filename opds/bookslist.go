@@ -4,7 +4,6 @@ import (
 	"fmt"
 	"net/http"
 	"strconv"
-	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -21,6 +20,35 @@ func hasNextPage(limit, currentPage, totalCount int) bool {
 	return currentPage < totalPages
 }
 
+// navigationItem is an entry of the root feed.
+func navigationItem(entry string, t *texts) *opdsutils.Item {
+	var title, summary, href string
+	switch entry {
+	case NavFavorites:
+		title, summary, href = t.Favorites, t.FavoritesSummary, "/opds/favorites/0"
+	case NavRecommended:
+		title, summary, href = t.Recommended, t.RecommendedSummary, "/opds/recommended"
+	case NavLanguages:
+		title, summary, href = t.Languages, t.LanguagesSummary, "/opds/languages"
+	case NavGenres:
+		title, summary, href = t.Genres, t.GenresSummary, "/opds/genres"
+	case NavCollections:
+		title, summary, href = t.Collections, t.CollectionsSummary, "/opds/collections/0"
+	}
+	return &opdsutils.Item{
+		Title: title,
+		Link: []opdsutils.Link{
+			{
+				Href: href,
+				Type: "application/atom+xml;profile=opds-catalog",
+			},
+		},
+		Id:      "tag:nav:" + entry,
+		Updated: time.Now(),
+		Content: summary,
+	}
+}
+
 func GetNewBooks(c *gin.Context) {
 	filters := models.BookFilters{
 		Limit:  10,
@@ -60,6 +88,11 @@ func GetNewBooks(c *gin.Context) {
 	if authorID > 0 {
 		filters.Author = authorID
 	}
+	sort, ok := listSort(c)
+	if !ok {
+		return
+	}
+	filters.Sort = sort.String()
 
 	books, tc, err := database.GetBooks(userID, filters)
 	if err != nil {
@@ -67,11 +100,13 @@ func GetNewBooks(c *gin.Context) {
 		c.AbortWithStatus(http.StatusInternalServerError)
 		return
 	}
-	var np string
+	var np, first string
 	if filters.Fav {
 		np = fmt.Sprintf("/opds/favorites/%d", pageNum+1)
+		first = "/opds/favorites/0"
 	} else {
 		np = fmt.Sprintf("/opds/new/%d/%d", pageNum+1, authorID)
+		first = fmt.Sprintf("/opds/new/0/%d", authorID)
 	}
 	rootLinks := []opdsutils.Link{
 		{
@@ -93,10 +128,11 @@ func GetNewBooks(c *gin.Context) {
 
 	if hasNextPage(filters.Limit, pageNum, tc) {
 		rootLinks = append(rootLinks, opdsutils.Link{
-			Href: np,
+			Href: withSort(np, sort),
 			Rel:  "next",
 			Type: "application/atom+xml;profile=opds-catalog"})
 	}
+	rootLinks = append(rootLinks, sortFacets(feedTexts(c), first, sort)...)
 
 	feedId := fmt.Sprintf("tag:root:new:%d:%d", pageNum, authorID)
 	if filters.Fav {
@@ -104,8 +140,9 @@ func GetNewBooks(c *gin.Context) {
 	}
 
 	feed := &opdsutils.Feed{
-		Title:   "Лепробиблиотека",
+		Title:   settings.Title,
 		Id:      feedId,
+		Icon:    settings.Icon,
 		Links:   rootLinks,
 		Updated: time.Now(),
 	}
@@ -113,61 +150,17 @@ func GetNewBooks(c *gin.Context) {
 
 	// Show navigation items only on the root page (page 0, no author filter, not favorites)
 	if !filters.Fav && pageNum == 0 && filters.Author == 0 {
-		// Add favorites link if user has favorites
-		if hf {
-			feed.Items = append(feed.Items, &opdsutils.Item{
-				Title: "Избранное",
-				Link: []opdsutils.Link{
-					{
-						Href: "/opds/favorites/0",
-						Type: "application/atom+xml;profile=opds-catalog",
-					},
-				},
-				Id:      "tag:nav:favorites",
-				Updated: time.Now(),
-				Content: "Избранное",
-			})
+		t := feedTexts(c)
+		for _, entry := range settings.Navigation {
+			// The favorites link only for a user who has favorites
+			if entry == NavFavorites && !hf {
+				continue
+			}
+			feed.Items = append(feed.Items, navigationItem(entry, t))
 		}
-
-		// Add languages navigation
-		feed.Items = append(feed.Items, &opdsutils.Item{
-			Title: "По языкам",
-			Link: []opdsutils.Link{
-				{
-					Href: "/opds/languages",
-					Type: "application/atom+xml;profile=opds-catalog",
-				},
-			},
-			Id:      "tag:nav:languages",
-			Updated: time.Now(),
-			Content: "Книги по языкам",
-		})
-
-		// Add collections navigation
-		feed.Items = append(feed.Items, &opdsutils.Item{
-			Title: "Подборки",
-			Link: []opdsutils.Link{
-				{
-					Href: "/opds/collections/0",
-					Type: "application/atom+xml;profile=opds-catalog",
-				},
-			},
-			Id:      "tag:nav:collections",
-			Updated: time.Now(),
-			Content: "Подборки книг",
-		})
 	}
 
-	// Check if userAgent contains koreader
-	isKoreader := false
-	if strings.Contains(c.GetHeader("User-Agent"), "KOReader") {
-		isKoreader = true
-	}
-
-	for _, book := range books {
-		bookItem := opdsutils.CreateItem(book, isKoreader)
-		feed.Items = append(feed.Items, &bookItem)
-	}
+	feed.Items = append(feed.Items, bookItems(c, books)...)
 
 	atom, err := feed.ToAtom()
 	if err != nil {
@@ -58,8 +58,9 @@ func GetCollections(c *gin.Context) {
 	}
 
 	feed := &opdsutils.Feed{
-		Title:   "Подборки",
+		Title:   feedTexts(c).Collections,
 		Id:      fmt.Sprintf("tag:collections:%d", pageNum),
+		Icon:    settings.Icon,
 		Links:   rootLinks,
 		Updated: time.Now(),
 	}
@@ -156,15 +157,11 @@ func GetCollectionBooks(c *gin.Context) {
 	feed := &opdsutils.Feed{
 		Title:   col.Name,
 		Id:      fmt.Sprintf("tag:collection:%d:books:%d", collectionID, pageNum),
+		Icon:    settings.Icon,
 		Links:   rootLinks,
 		Updated: time.Now(),
 	}
-	feed.Items = []*opdsutils.Item{}
-
-	for _, book := range books {
-		bookItem := opdsutils.CreateItem(book, false)
-		feed.Items = append(feed.Items, &bookItem)
-	}
+	feed.Items = bookItems(c, books)
 
 	atom, err := feed.ToAtom()
 	if err != nil {
@@ -2,8 +2,6 @@ package opds
 
 import (
 	"errors"
-	"fmt"
-	"io"
 	"net/http"
 	"strconv"
 	"strings"
@@ -11,7 +9,7 @@ import (
 	"gopds-api/database"
 	"gopds-api/httputil"
 	"gopds-api/logging"
-	"gopds-api/utils"
+	"gopds-api/services"
 
 	"github.com/gin-gonic/gin"
 	"github.com/spf13/viper"
@@ -24,7 +22,19 @@ var bookTypes = map[string]string{
 	"mobi": "application/x-mobipocket-ebook",
 }
 
-// DownloadBook returns a book file
+// conversionCache keeps converted books between downloads; the web API
+// shares the same instance. Nil until main sets it, in which case every
+// conversion is done afresh.
+var conversionCache *services.ConversionCache
+
+// SetConversionCache installs the cache EPUB and MOBI downloads go through.
+func SetConversionCache(cache *services.ConversionCache) {
+	conversionCache = cache
+}
+
+// DownloadBook returns a book file. Downloads carry a strong ETag and the
+// archive's modification time, honour conditional requests and can be
+// resumed with a Range request, in every format.
 func DownloadBook(c *gin.Context) {
 	bookID, err := strconv.ParseInt(c.Param("id"), 10, 0)
 	if err != nil {
@@ -51,48 +61,46 @@ func DownloadBook(c *gin.Context) {
 		logging.Infof("User %d downloading book %d in format %s", userID, bookID, format)
 	}
 
-	zipPath := viper.GetString("app.files_path") + book.Path
-	if !utils.FileExists(zipPath) {
-		httputil.NewError(c, http.StatusNotFound, errors.New("book file not found"))
+	format = strings.ToLower(format)
+	contentType, ok := bookTypes[format]
+	if !ok {
+		httputil.NewError(c, http.StatusBadRequest, errors.New("unknown book format"))
 		return
 	}
 
-	bp := utils.NewBookProcessor(book.FileName, zipPath)
-
-	var rc io.ReadCloser
-	contentDisp := "attachment; filename=%s.%s"
-
-	switch strings.ToLower(format) {
-	case "epub":
-		rc, err = bp.Epub()
-	case "mobi":
-		rc, err = bp.Mobi()
-	case "fb2":
-		rc, err = bp.FB2()
-	case "zip":
-		rc, err = bp.Zip(book.FileName)
-	default:
-		httputil.NewError(c, http.StatusBadRequest, errors.New("unknown book format"))
+	filesPath := viper.GetString("app.files_path")
+	modTime, err := services.BookFileModTime(&book, filesPath)
+	if err != nil {
+		if errors.Is(err, services.ErrBookFileMissing) {
+			httputil.NewError(c, http.StatusNotFound, err)
+			return
+		}
+		httputil.NewError(c, http.StatusInternalServerError, err)
+		return
+	}
+	// Readers resume interrupted downloads and revalidate the ones they
+	// kept; the second is answered before anything is read or converted.
+	if httputil.NotModified(c, services.BookFileETag(&book, format), modTime) {
 		return
 	}
 
+	file, err := services.OpenBookFile(conversionCache, &book, filesPath, format)
 	if err != nil {
+		if errors.Is(err, services.ErrBookFormatUnavailable) {
+			httputil.NewError(c, http.StatusNotFound, err)
+			return
+		}
 		httputil.NewError(c, http.StatusInternalServerError, err)
 		return
 	}
-
 	defer func() {
-		if cerr := rc.Close(); cerr != nil {
+		if cerr := file.Content.Close(); cerr != nil {
 			logging.Errorf("failed to close file: %v", cerr)
 		}
 	}()
 
-	c.Header("Content-Disposition", fmt.Sprintf(contentDisp, book.DownloadName(), format))
-	c.Header("Content-Type", bookTypes[strings.ToLower(format)])
-	_, err = io.Copy(c.Writer, rc)
-
-	if err != nil {
-		logging.Infof("Client closed connection: %v", err)
-		return
+	if httputil.StartsDownload(c) {
+		services.RecordDownload(c.Request.Context(), c.GetInt64("user_id"), book.ID)
 	}
+	httputil.ServeDownload(c, file.Content, book.DownloadName()+"."+format, contentType, file.ETag, file.ModTime)
 }
@@ -0,0 +1,153 @@
+package opds
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+
+	"gopds-api/models"
+	"gopds-api/opdsutils"
+)
+
+// A book search feed counts its results by language, genre, author, series
+// and decade, and offers the counts as OPDS facet groups. A facet link
+// narrows the search to its value; the one in effect is marked active and
+// leads back to the search without it. Every link of the feed — the next
+// page, the sort facets, a suggestion — carries the filters in effect.
+
+// bookSearchFilters are the facet filters of a book search, as its facet
+// links write them.
+type bookSearchFilters struct {
+	Lang   string `form:"lang"`
+	Author int64  `form:"author"`
+	Series int64  `form:"series"`
+	Genre  int64  `form:"genre"`
+	// Decade is the first year of one: 1860 is 1860 to 1869.
+	Decade int `form:"decade"`
+}
+
+// apply narrows a search request to the filters. A language in the path
+// outranks the lang filter, which is then never offered.
+func (f *bookSearchFilters) apply(req *models.BookSearchRequest) {
+	if req.Language == "" {
+		req.Language = f.Lang
+	}
+	req.AuthorID, req.SeriesID, req.GenreID = f.Author, f.Series, f.Genre
+	if f.Decade > 0 {
+		req.Options.YearFrom, req.Options.YearTo = f.Decade, f.Decade+9
+	}
+}
+
+// values writes the filters in effect into a query string.
+func (f *bookSearchFilters) values(v url.Values) {
+	if f.Lang != "" {
+		v.Set("lang", f.Lang)
+	}
+	for _, p := range []struct {
+		name string
+		id   int64
+	}{{"author", f.Author}, {"series", f.Series}, {"genre", f.Genre}, {"decade", int64(f.Decade)}} {
+		if p.id > 0 {
+			v.Set(p.name, strconv.FormatInt(p.id, 10))
+		}
+	}
+}
+
+// set is a facet value's filter: the filters with that one facet set to the
+// value, and whether it already was.
+func (f bookSearchFilters) set(facet, value string) (bookSearchFilters, bool) {
+	id, _ := strconv.ParseInt(value, 10, 64)
+	var active bool
+	switch facet {
+	case models.FacetLanguage:
+		active, f.Lang = f.Lang == value, value
+	case models.FacetAuthor:
+		active, f.Author = f.Author == id, id
+	case models.FacetSeries:
+		active, f.Series = f.Series == id, id
+	case models.FacetGenre:
+		active, f.Genre = f.Genre == id, id
+	case models.FacetDecade:
+		active, f.Decade = int64(f.Decade) == id, int(id)
+	}
+	return f, active
+}
+
+// clear is the filters without one facet.
+func (f bookSearchFilters) clear(facet string) bookSearchFilters {
+	switch facet {
+	case models.FacetLanguage:
+		f.Lang = ""
+	case models.FacetAuthor:
+		f.Author = 0
+	case models.FacetSeries:
+		f.Series = 0
+	case models.FacetGenre:
+		f.Genre = 0
+	case models.FacetDecade:
+		f.Decade = 0
+	}
+	return f
+}
+
+// bookSearchHref is a page of a book search with its filters, the first
+// page without a page parameter.
+func bookSearchHref(path, needle string, filters *bookSearchFilters, page int) string {
+	values := url.Values{}
+	values.Set("title", needle)
+	filters.values(values)
+	if page > 0 {
+		values.Set("page", strconv.Itoa(page))
+	}
+	return path + "?" + values.Encode()
+}
+
+// searchFacets are the facet links of a book search feed, group by group.
+// The language group is left out of a feed whose path fixes the language.
+func searchFacets(
+	t *texts, path, needle string, filters *bookSearchFilters, sort models.BookSort,
+	facets *models.BookFacets, pathLanguage bool,
+) []opdsutils.Link {
+	if facets == nil {
+		return nil
+	}
+	groups := []struct {
+		facet, title string
+		counts       []models.FacetCount
+		label        func(models.FacetCount) string
+	}{
+		{models.FacetLanguage, t.FacetLanguage, facets.Language, func(c models.FacetCount) string { return getLangName(c.Value) }},
+		{models.FacetGenre, t.FacetGenre, facets.Genre, nil},
+		{models.FacetAuthor, t.FacetAuthor, facets.Author, nil},
+		{models.FacetSeries, t.FacetSeries, facets.Series, nil},
+		{models.FacetDecade, t.FacetDecade, facets.Decade, func(c models.FacetCount) string {
+			return fmt.Sprintf(t.DecadeLabel, c.Value)
+		}},
+	}
+	var links []opdsutils.Link
+	for _, g := range groups {
+		if g.facet == models.FacetLanguage && pathLanguage {
+			continue
+		}
+		for _, count := range g.counts {
+			narrowed, active := filters.set(g.facet, count.Value)
+			if active {
+				narrowed = narrowed.clear(g.facet)
+			}
+			title := count.Label
+			if g.label != nil {
+				title = g.label(count)
+			}
+			links = append(links, opdsutils.Link{
+				Href:        withSort(bookSearchHref(path, needle, &narrowed, 0), sort),
+				Rel:         relFacet,
+				Type:        typeOpdsCatalog,
+				Title:       title,
+				FacetGroup:  g.title,
+				ActiveFacet: active,
+				Count:       count.Count,
+			})
+		}
+	}
+	return links
+}
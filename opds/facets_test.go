@@ -0,0 +1,98 @@
+package opds
+
+import (
+	"encoding/xml"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"gopds-api/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countedFacetLink reads a facet link with its count.
+type countedFacetLink struct {
+	facetLink
+	Count int `xml:"count,attr"`
+}
+
+// facetGroups reads a feed's facet links by group, the sort group left out.
+func facetGroups(t *testing.T, body []byte) map[string][]countedFacetLink {
+	t.Helper()
+	var feed struct {
+		Links []countedFacetLink `xml:"link"`
+	}
+	require.NoError(t, xml.Unmarshal(body, &feed))
+	groups := map[string][]countedFacetLink{}
+	for _, l := range feed.Links {
+		if l.Rel == relFacet && l.FacetGroup != textsRU.SortGroup {
+			groups[l.FacetGroup] = append(groups[l.FacetGroup], l)
+		}
+	}
+	return groups
+}
+
+func searchFacetsPage() models.BookSearchPage {
+	return models.BookSearchPage{
+		Books: cannedBooks(10), Total: 134, Limit: 10,
+		Facets: &models.BookFacets{
+			Language: []models.FacetCount{{Value: "ru", Label: "ru", Count: 120}, {Value: "en", Label: "en", Count: 14}},
+			Author:   []models.FacetCount{{Value: "5", Label: "Толстой Лев", Count: 90}},
+			Decade:   []models.FacetCount{{Value: "1860", Label: "1860", Count: 40}},
+		},
+	}
+}
+
+func TestOpdsSearch_BooksFacetGroups(t *testing.T) {
+	fake := &fakePublicSearch{booksPage: searchFacetsPage()}
+	r := newOpdsTestRouter(fake)
+
+	rec := doGET(t, r, "/opds/books?title="+url.QueryEscape("война")+"&lang=ru&author=5&decade=1860&sort=year")
+
+	require.Equal(t, http.StatusOK, rec.Code, "body=%s", rec.Body.String())
+	require.Len(t, fake.booksReqs, 1)
+	req := fake.booksReqs[0]
+	assert.True(t, req.Facets)
+	assert.Equal(t, "ru", req.Language)
+	assert.Equal(t, int64(5), req.AuthorID)
+	assert.Equal(t, 1860, req.Options.YearFrom)
+	assert.Equal(t, 1869, req.Options.YearTo)
+
+	groups := facetGroups(t, rec.Body.Bytes())
+	require.Len(t, groups[textsRU.FacetLanguage], 2)
+	ru, en := groups[textsRU.FacetLanguage][0], groups[textsRU.FacetLanguage][1]
+	assert.Equal(t, 120, ru.Count)
+	assert.Equal(t, "true", ru.ActiveFacet)
+	assert.Equal(t, "/opds/books?author=5&decade=1860&title=%D0%B2%D0%BE%D0%B9%D0%BD%D0%B0&sort=year", ru.Href,
+		"the facet in effect leads back without it, keeping the rest")
+	assert.Empty(t, en.ActiveFacet)
+	assert.Equal(t, "/opds/books?author=5&decade=1860&lang=en&title=%D0%B2%D0%BE%D0%B9%D0%BD%D0%B0&sort=year", en.Href)
+
+	require.Len(t, groups[textsRU.FacetDecade], 1)
+	assert.Equal(t, "1860-е", groups[textsRU.FacetDecade][0].Title)
+	require.Len(t, groups[textsRU.FacetAuthor], 1)
+	assert.Equal(t, "Толстой Лев", groups[textsRU.FacetAuthor][0].Title)
+
+	next, ok := nextFeedLink(parseFeed(t, rec).Links)
+	require.True(t, ok)
+	query := nextQuery(t, next)
+	assert.Equal(t, "ru", query.Get("lang"), "the next page keeps the filters")
+	assert.Equal(t, "5", query.Get("author"))
+	assert.Equal(t, "1860", query.Get("decade"))
+}
+
+func TestOpdsSearch_BooksByLanguageHasNoLanguageFacet(t *testing.T) {
+	fake := &fakePublicSearch{booksPage: searchFacetsPage()}
+	r := newOpdsTestRouter(fake)
+
+	rec := doGET(t, r, "/opds/lang/ru/search-books?title=war&lang=en")
+
+	require.Equal(t, http.StatusOK, rec.Code, "body=%s", rec.Body.String())
+	assert.Equal(t, "ru", fake.booksReqs[0].Language, "the path's language is the search's")
+	groups := facetGroups(t, rec.Body.Bytes())
+	assert.NotContains(t, groups, textsRU.FacetLanguage)
+	require.Len(t, groups[textsRU.FacetAuthor], 1)
+	assert.Equal(t, "/opds/lang/ru/search-books?author=5&title=war", groups[textsRU.FacetAuthor][0].Href)
+}
@@ -0,0 +1,258 @@
+package opds
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopds-api/database"
+	"gopds-api/logging"
+	"gopds-api/models"
+	"gopds-api/opdsutils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// genreLinks are the links of a genre feed: start, up, and search.
+func genreLinks(up string) []opdsutils.Link {
+	return []opdsutils.Link{
+		{
+			Href: "/opds",
+			Rel:  "start",
+			Type: "application/atom+xml;profile=opds-catalog",
+		},
+		{
+			Href: up,
+			Rel:  "up",
+			Type: "application/atom+xml;profile=opds-catalog",
+		},
+		{
+			Href: "/opds-opensearch.xml",
+			Rel:  "search",
+			Type: "application/opensearchdescription+xml",
+		},
+		{
+			Href: "/opds/search?searchTerms={searchTerms}",
+			Rel:  "search",
+			Type: "application/atom+xml",
+		},
+	}
+}
+
+func writeFeed(c *gin.Context, feed *opdsutils.Feed) {
+	feed.Icon = settings.Icon
+	atom, err := feed.ToAtom()
+	if err != nil {
+		logging.Errorf("Error converting feed to Atom: %v", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	c.Data(200, "application/atom+xml;charset=utf-8", []byte(atom))
+}
+
+// GetGenreSections returns the sections of the genre taxonomy, named in the
+// reader's interface language.
+func GetGenreSections(c *gin.Context) {
+	lang := readerLanguage(c)
+	tree, err := database.GetGenreTree(context.Background(), lang, false)
+	if err != nil {
+		logging.Errorf("Failed to load genre tree: %v", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	feed := &opdsutils.Feed{
+		Title:   feedTexts(c).GenresTitle,
+		Id:      "tag:root:genres",
+		Links:   genreLinks("/opds"),
+		Updated: time.Now(),
+	}
+	feed.Items = []*opdsutils.Item{}
+
+	for _, s := range tree.Sections {
+		feed.Items = append(feed.Items, &opdsutils.Item{
+			Title: fmt.Sprintf("%s (%d)", s.Name, s.BooksCount),
+			Link: []opdsutils.Link{
+				{
+					Href: fmt.Sprintf("/opds/genres/%d", s.ID),
+					Type: "application/atom+xml;profile=opds-catalog",
+				},
+			},
+			Id:      fmt.Sprintf("tag:genres:%d", s.ID),
+			Updated: time.Now(),
+			Content: genreNames(s.Genres),
+		})
+	}
+
+	writeFeed(c, feed)
+}
+
+// genreNames lists a few genres of a section for its entry's summary.
+func genreNames(genres []database.GenreNode) string {
+	names := make([]string, 0, 5)
+	for i, g := range genres {
+		if i == 5 {
+			names = append(names, "…")
+			break
+		}
+		names = append(names, g.Name)
+	}
+	return strings.Join(names, ", ")
+}
+
+// GetGenreSection returns the genres of one section, after an entry for all
+// the books of the section.
+func GetGenreSection(c *gin.Context) {
+	sectionID, err := strconv.ParseInt(c.Param("section"), 10, 64)
+	if err != nil {
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+	lang := readerLanguage(c)
+	section, err := database.GetGenreSection(context.Background(), sectionID, lang)
+	if errors.Is(err, database.ErrGenreSectionNotFound) {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		logging.Errorf("Failed to load genre section %d: %v", sectionID, err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	feed := &opdsutils.Feed{
+		Title:   section.Name,
+		Id:      fmt.Sprintf("tag:genres:%d", section.ID),
+		Links:   genreLinks("/opds/genres"),
+		Updated: time.Now(),
+	}
+	t := feedTexts(c)
+	feed.Items = []*opdsutils.Item{
+		{
+			Title: fmt.Sprintf(t.AllBooksCount, section.BooksCount),
+			Link: []opdsutils.Link{
+				{
+					Href: fmt.Sprintf("/opds/genres/%d/books/0", section.ID),
+					Type: "application/atom+xml;profile=opds-catalog",
+				},
+			},
+			Id:      fmt.Sprintf("tag:genres:%d:all", section.ID),
+			Updated: time.Now(),
+			Content: fmt.Sprintf(t.AllBooksOfSection, section.Name),
+		},
+	}
+	for _, g := range section.Genres {
+		feed.Items = append(feed.Items, &opdsutils.Item{
+			Title: fmt.Sprintf("%s (%d)", g.Name, g.BooksCount),
+			Link: []opdsutils.Link{
+				{
+					Href: fmt.Sprintf("/opds/genre/%d/0", g.ID),
+					Type: "application/atom+xml;profile=opds-catalog",
+				},
+			},
+			Id:      fmt.Sprintf("tag:genre:%d", g.ID),
+			Updated: time.Now(),
+			Content: g.Name,
+		})
+	}
+
+	writeFeed(c, feed)
+}
+
+// GetGenreSectionBooks returns the books of any genre of a section.
+func GetGenreSectionBooks(c *gin.Context) {
+	sectionID, err := strconv.ParseInt(c.Param("section"), 10, 64)
+	if err != nil {
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+	lang := readerLanguage(c)
+	section, err := database.GetGenreSection(context.Background(), sectionID, lang)
+	if errors.Is(err, database.ErrGenreSectionNotFound) {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		logging.Errorf("Failed to load genre section %d: %v", sectionID, err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	writeGenreBooks(c, models.BookFilters{GenreSection: sectionID}, section.Name,
+		fmt.Sprintf("/opds/genres/%d", sectionID), fmt.Sprintf("/opds/genres/%d/books", sectionID),
+		fmt.Sprintf("tag:genres:%d:books", sectionID))
+}
+
+// GetGenreBooks returns the books of one genre.
+func GetGenreBooks(c *gin.Context) {
+	genreID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+	genre, err := database.GetGenre(context.Background(), genreID)
+	if errors.Is(err, database.ErrGenreNotFound) {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		logging.Errorf("Failed to load genre %d: %v", genreID, err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	up := "/opds/genres"
+	if genre.SectionID != nil {
+		up = fmt.Sprintf("/opds/genres/%d", *genre.SectionID)
+	}
+	lang := readerLanguage(c)
+	writeGenreBooks(c, models.BookFilters{Genre: int(genreID)}, genre.LocalizedName(lang),
+		up, fmt.Sprintf("/opds/genre/%d", genreID), fmt.Sprintf("tag:genre:%d:books", genreID))
+}
+
+// writeGenreBooks writes a page of the books filters select; pages are
+// pagePrefix/N.
+//
+//nolint:gocritic // one filter set per feed, built by the caller
+func writeGenreBooks(c *gin.Context, filters models.BookFilters, title, up, pagePrefix, id string) {
+	pageNum, err := strconv.Atoi(c.Param("page"))
+	if err != nil {
+		pageNum = 0
+	}
+	sort, ok := listSort(c)
+	if !ok {
+		return
+	}
+	filters.Limit = 10
+	filters.Offset = pageNum * 10
+	filters.Sort = sort.String()
+
+	books, tc, err := database.GetBooks(c.GetInt64("user_id"), filters)
+	if err != nil {
+		logging.Error(err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	links := genreLinks(up)
+	if hasNextPage(filters.Limit, pageNum, tc) {
+		links = append(links, opdsutils.Link{
+			Href: withSort(fmt.Sprintf("%s/%d", pagePrefix, pageNum+1), sort),
+			Rel:  "next",
+			Type: "application/atom+xml;profile=opds-catalog",
+		})
+	}
+	links = append(links, sortFacets(feedTexts(c), pagePrefix+"/0", sort)...)
+
+	feed := &opdsutils.Feed{
+		Title:   title,
+		Id:      fmt.Sprintf("%s:%d", id, pageNum),
+		Links:   links,
+		Updated: time.Now(),
+	}
+	feed.Items = bookItems(c, books)
+
+	writeFeed(c, feed)
+}
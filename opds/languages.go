@@ -64,6 +64,7 @@ func langLinks(lang string) []opdsutils.Link {
 // GetLanguages returns a list of available languages
 func GetLanguages(c *gin.Context) {
 	languages := database.GetLanguages()
+	t := feedTexts(c)
 
 	rootLinks := []opdsutils.Link{
 		{
@@ -84,8 +85,9 @@ func GetLanguages(c *gin.Context) {
 	}
 
 	feed := &opdsutils.Feed{
-		Title:   "Книги по языкам",
+		Title:   t.LanguagesTitle,
 		Id:      "tag:root:languages",
+		Icon:    settings.Icon,
 		Links:   rootLinks,
 		Updated: time.Now(),
 	}
@@ -102,7 +104,7 @@ func GetLanguages(c *gin.Context) {
 			},
 			Id:      fmt.Sprintf("tag:lang:%s", lang.Lang),
 			Updated: time.Now(),
-			Content: fmt.Sprintf("Книги на языке: %s", getLangName(lang.Lang)),
+			Content: fmt.Sprintf(t.LanguageSummary, getLangName(lang.Lang)),
 		})
 	}
 
@@ -120,6 +122,7 @@ func GetLanguages(c *gin.Context) {
 func GetLanguageRoot(c *gin.Context) {
 	lang := c.Param("lang")
 	langName := getLangName(lang)
+	t := feedTexts(c)
 
 	rootLinks := []opdsutils.Link{
 		{
@@ -147,12 +150,13 @@ func GetLanguageRoot(c *gin.Context) {
 	feed := &opdsutils.Feed{
 		Title:   langName,
 		Id:      fmt.Sprintf("tag:lang:%s:root", lang),
+		Icon:    settings.Icon,
 		Links:   rootLinks,
 		Updated: time.Now(),
 	}
 	feed.Items = []*opdsutils.Item{
 		{
-			Title: "Все книги",
+			Title: t.AllBooks,
 			Link: []opdsutils.Link{
 				{
 					Href: fmt.Sprintf("/opds/lang/%s/books/0", lang),
@@ -161,7 +165,7 @@ func GetLanguageRoot(c *gin.Context) {
 			},
 			Id:      fmt.Sprintf("tag:lang:%s:all", lang),
 			Updated: time.Now(),
-			Content: fmt.Sprintf("Все книги на языке %s", langName),
+			Content: fmt.Sprintf(t.AllBooksInLanguage, langName),
 		},
 	}
 	// Note: Search is available via the search icon in the reader (rel="search" link in header)
@@ -186,10 +190,15 @@ func GetBooksByLanguage(c *gin.Context) {
 
 	userID := c.GetInt64("user_id")
 
+	sort, ok := listSort(c)
+	if !ok {
+		return
+	}
 	filters := models.BookFilters{
-		Limit:  10,
-		Offset: 0,
-		Lang:   lang,
+		Limit:          10,
+		Offset:         0,
+		Lang:           lang,
+		BookListParams: models.BookListParams{Sort: sort.String()},
 	}
 
 	if pageNum > 0 {
@@ -207,26 +216,21 @@ func GetBooksByLanguage(c *gin.Context) {
 
 	if hasNextPage(filters.Limit, pageNum, tc) {
 		rootLinks = append(rootLinks, opdsutils.Link{
-			Href: fmt.Sprintf("/opds/lang/%s/books/%d", lang, pageNum+1),
+			Href: withSort(fmt.Sprintf("/opds/lang/%s/books/%d", lang, pageNum+1), sort),
 			Rel:  "next",
 			Type: "application/atom+xml;profile=opds-catalog",
 		})
 	}
+	rootLinks = append(rootLinks, sortFacets(feedTexts(c), fmt.Sprintf("/opds/lang/%s/books/0", lang), sort)...)
 
 	feed := &opdsutils.Feed{
-		Title:   fmt.Sprintf("Книги: %s", getLangName(lang)),
+		Title:   fmt.Sprintf(feedTexts(c).LanguageBooks, getLangName(lang)),
 		Id:      fmt.Sprintf("tag:lang:%s:books:%d", lang, pageNum),
+		Icon:    settings.Icon,
 		Links:   rootLinks,
 		Updated: time.Now(),
 	}
-	feed.Items = []*opdsutils.Item{}
-
-	isKoreader := strings.Contains(c.GetHeader("User-Agent"), "KOReader")
-
-	for _, book := range books {
-		bookItem := opdsutils.CreateItem(book, isKoreader)
-		feed.Items = append(feed.Items, &bookItem)
-	}
+	feed.Items = bookItems(c, books)
 
 	atom, err := feed.ToAtom()
 	if err != nil {
@@ -250,15 +254,17 @@ func SearchByLanguage(c *gin.Context) {
 
 	rootLinks := langLinks(lang)
 
+	t := feedTexts(c)
 	feed := &opdsutils.Feed{
-		Title:   fmt.Sprintf("Поиск: %s", getLangName(lang)),
+		Title:   fmt.Sprintf(t.SearchIn, getLangName(lang)),
 		Id:      fmt.Sprintf("tag:lang:%s:search", lang),
+		Icon:    settings.Icon,
 		Links:   rootLinks,
 		Updated: time.Now(),
 	}
 	feed.Items = []*opdsutils.Item{
 		{
-			Title: "Поиск авторов",
+			Title: t.SearchAuthors,
 			Link: []opdsutils.Link{
 				{
 					Href: fmt.Sprintf("/opds/lang/%s/search-authors?name=%s", lang, url.QueryEscape(searchTerms)),
@@ -267,10 +273,10 @@ func SearchByLanguage(c *gin.Context) {
 			},
 			Id:      "tag:search:author",
 			Updated: time.Now(),
-			Content: "Поиск авторов по фамилии",
+			Content: t.SearchAuthorsSummary,
 		},
 		{
-			Title: "Поиск книг",
+			Title: t.SearchBooks,
 			Link: []opdsutils.Link{
 				{
 					Href: fmt.Sprintf("/opds/lang/%s/search-books?title=%s", lang, url.QueryEscape(searchTerms)),
@@ -279,7 +285,7 @@ func SearchByLanguage(c *gin.Context) {
 			},
 			Id:      "tag:search:book",
 			Updated: time.Now(),
-			Content: "Поиск книг по названию",
+			Content: t.SearchBooksSummary,
 		},
 	}
 
@@ -309,11 +315,16 @@ func GetAuthorBooksByLanguage(c *gin.Context) {
 
 	userID := c.GetInt64("user_id")
 
+	sort, ok := listSort(c)
+	if !ok {
+		return
+	}
 	filters := models.BookFilters{
-		Limit:  10,
-		Offset: 0,
-		Lang:   lang,
-		Author: authorID,
+		Limit:          10,
+		Offset:         0,
+		Lang:           lang,
+		Author:         authorID,
+		BookListParams: models.BookListParams{Sort: sort.String()},
 	}
 
 	if pageNum > 0 {
@@ -331,14 +342,15 @@ func GetAuthorBooksByLanguage(c *gin.Context) {
 
 	if hasNextPage(filters.Limit, pageNum, tc) {
 		rootLinks = append(rootLinks, opdsutils.Link{
-			Href: fmt.Sprintf("/opds/lang/%s/author/%d/%d", lang, authorID, pageNum+1),
+			Href: withSort(fmt.Sprintf("/opds/lang/%s/author/%d/%d", lang, authorID, pageNum+1), sort),
 			Rel:  "next",
 			Type: "application/atom+xml;profile=opds-catalog",
 		})
 	}
+	rootLinks = append(rootLinks, sortFacets(feedTexts(c), fmt.Sprintf("/opds/lang/%s/author/%d/0", lang, authorID), sort)...)
 
 	// Get author name for title
-	authorName := "Автор"
+	authorName := feedTexts(c).Author
 	if len(books) > 0 {
 		for _, a := range books[0].Authors {
 			if int(a.ID) == authorID {
@@ -351,17 +363,11 @@ func GetAuthorBooksByLanguage(c *gin.Context) {
 	feed := &opdsutils.Feed{
 		Title:   fmt.Sprintf("%s (%s)", authorName, getLangName(lang)),
 		Id:      fmt.Sprintf("tag:lang:%s:author:%d:%d", lang, authorID, pageNum),
+		Icon:    settings.Icon,
 		Links:   rootLinks,
 		Updated: time.Now(),
 	}
-	feed.Items = []*opdsutils.Item{}
-
-	isKoreader := strings.Contains(c.GetHeader("User-Agent"), "KOReader")
-
-	for _, book := range books {
-		bookItem := opdsutils.CreateItem(book, isKoreader)
-		feed.Items = append(feed.Items, &bookItem)
-	}
+	feed.Items = bookItems(c, books)
 
 	atom, err := feed.ToAtom()
 	if err != nil {
@@ -1,19 +1,32 @@
 package opds
 
 import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+
 	"github.com/gin-gonic/gin"
 )
 
 const openSearchXML = `<?xml version="1.0" encoding="UTF-8"?>
 <OpenSearchDescription xmlns="http://a9.com/-/spec/opensearch/1.1/">
-  <ShortName>Лепробиблиотека</ShortName>
-  <Description>Поиск книг и авторов</Description>
+  <ShortName>%s</ShortName>
+  <Description>%s</Description>
   <InputEncoding>UTF-8</InputEncoding>
   <OutputEncoding>UTF-8</OutputEncoding>
   <Url type="application/atom+xml;profile=opds-catalog" template="/opds/search?searchTerms={searchTerms}"/>
 </OpenSearchDescription>`
 
-// OpenSearch returns the OpenSearch description document
+// OpenSearch returns the OpenSearch description document, named after the
+// catalogue and described in the reader's language.
 func OpenSearch(c *gin.Context) {
-	c.Data(200, "application/opensearchdescription+xml; charset=utf-8", []byte(openSearchXML))
+	doc := fmt.Sprintf(openSearchXML, escapeXML(settings.Title), escapeXML(feedTexts(c).OpenSearch))
+	c.Data(200, "application/opensearchdescription+xml; charset=utf-8", []byte(doc))
+}
+
+// escapeXML escapes text for an element's content.
+func escapeXML(s string) string {
+	var b bytes.Buffer
+	_ = xml.EscapeText(&b, []byte(s))
+	return b.String()
 }
@@ -0,0 +1,71 @@
+package opds
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"gopds-api/httputil"
+	"gopds-api/models"
+	"gopds-api/opdsutils"
+	"gopds-api/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// recommendationFeedSize is how many books a recommendation feed holds. It
+// is one page: recommendations are a short list, not a catalogue.
+const recommendationFeedSize = 20
+
+// RecommendationHandler serves the reader's recommendations and the books
+// like a book.
+type RecommendationHandler struct {
+	Recommend services.Recommendations
+}
+
+// SetupRecommendationRoutes sets up the recommendation feeds. They are
+// registered apart from SetupOpdsRoutes, which every caller would otherwise
+// have to hand a service none of the other feeds use.
+func SetupRecommendationRoutes(r *gin.RouterGroup, recommend services.Recommendations) {
+	h := &RecommendationHandler{Recommend: recommend}
+	r.GET("/recommended", h.Recommended)
+	r.GET("/similar/:id", h.Similar)
+}
+
+// Recommended serves the reader's personal picks: /opds/recommended.
+func (h *RecommendationHandler) Recommended(c *gin.Context) {
+	h.feed(c, 0, "tag:root:recommended", feedTexts(c).Recommended)
+}
+
+// Similar serves the books like a book: /opds/similar/:id.
+func (h *RecommendationHandler) Similar(c *gin.Context) {
+	bookID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || bookID <= 0 {
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+	h.feed(c, bookID, fmt.Sprintf("tag:book:%d:similar", bookID), feedTexts(c).SimilarBooks)
+}
+
+// feed renders the books like bookID, or the reader's own picks for 0, in
+// the reader's books language.
+func (h *RecommendationHandler) feed(c *gin.Context, bookID int64, id, title string) {
+	books, err := h.Recommend.Recommend(c.Request.Context(), models.RecommendationRequest{
+		BookID:   bookID,
+		UserID:   c.GetInt64("user_id"),
+		Language: c.GetString("books_lang"),
+		Limit:    recommendationFeedSize,
+	})
+	if err != nil {
+		httputil.NewError(c, http.StatusInternalServerError, err)
+		return
+	}
+	renderFeed(c, &opdsutils.Feed{
+		Title:   title,
+		Id:      id,
+		Links:   globalSearchLinks(),
+		Updated: time.Now(),
+		Items:   bookItems(c, books),
+	})
+}
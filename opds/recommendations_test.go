@@ -0,0 +1,59 @@
+package opds
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"gopds-api/internal/testfakes"
+	"gopds-api/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newRecommendationTestRouter(recommend *testfakes.Recommendations) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	g := r.Group("/opds", func(c *gin.Context) {
+		c.Set("user_id", int64(77))
+		c.Set("books_lang", "ru")
+		c.Next()
+	})
+	SetupRecommendationRoutes(g, recommend)
+	return r
+}
+
+func TestOpdsRecommended_ReaderPicksInTheirLanguage(t *testing.T) {
+	fake := &testfakes.Recommendations{Books: []models.Book{{ID: 1, Title: "One"}, {ID: 2, Title: "Two"}}}
+	req := httptest.NewRequest(http.MethodGet, "/opds/recommended", nil)
+	req.Header.Set("Accept-Language", "en")
+	rec := httptest.NewRecorder()
+	newRecommendationTestRouter(fake).ServeHTTP(rec, req)
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, models.RecommendationRequest{UserID: 77, Language: "ru", Limit: recommendationFeedSize}, fake.Req)
+	feed := parseFeed(t, rec)
+	assert.Equal(t, "Recommended", feed.Title)
+	assert.Len(t, feed.Entries, 2)
+}
+
+func TestOpdsSimilar_EntriesLinkToTheirOwnSimilarFeed(t *testing.T) {
+	fake := &testfakes.Recommendations{Books: []models.Book{{ID: 9, Title: "Nine"}}}
+	rec := httptest.NewRecorder()
+	newRecommendationTestRouter(fake).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/opds/similar/5", nil))
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, int64(5), fake.Req.BookID)
+	feed := parseFeed(t, rec)
+	assert.Equal(t, "tag:book:5:similar", feed.ID)
+	require.Len(t, feed.Entries, 1)
+	assert.Contains(t, feed.Entries[0].Links, testLink{
+		Href: "/opds/similar/9", Rel: "related", Type: "application/atom+xml;profile=opds-catalog",
+	})
+
+	rec = httptest.NewRecorder()
+	newRecommendationTestRouter(fake).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/opds/similar/x", nil))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
@@ -32,6 +32,12 @@ func SetupOpdsRoutes(r *gin.RouterGroup, search services.PublicSearch) {
 	r.GET("/lang/:lang/search-authors", searchHandler.AuthorsByLanguage)
 	r.GET("/lang/:lang/author/:author/:page", GetAuthorBooksByLanguage)
 
+	// Genres navigation
+	r.GET("/genres", GetGenreSections)
+	r.GET("/genres/:section", GetGenreSection)
+	r.GET("/genres/:section/books/:page", GetGenreSectionBooks)
+	r.GET("/genre/:id/:page", GetGenreBooks)
+
 	// Collections navigation
 	r.GET("/collections/:page", GetCollections)
 	r.GET("/collection/:id/:page", GetCollectionBooks)
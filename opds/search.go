@@ -2,6 +2,7 @@ package opds
 
 import (
 	"errors"
+	"fmt"
 	"net/url"
 	"time"
 
@@ -17,16 +18,23 @@ import (
 const notFound = `<?xml version="1.0" encoding="utf-8"?>
  <feed xmlns="http://www.w3.org/2005/Atom" xmlns:dc="http://purl.org/dc/terms/" xmlns:os="http://a9.com/-/spec/opensearch/1.1/" xmlns:opds="http://opds-spec.org/2010/catalog">
  <id>tag:search:books:notfound</id>
- <title>Результат поиска</title>
+ <title>%s</title>
  <link href="/opds-opensearch.xml" rel="search" type="application/opensearchdescription+xml" />
  <link href="/opds/search?searchTerms={searchTerms}" rel="search" type="application/atom+xml" />
  <link href="/opds" rel="start" type="application/atom+xml;profile=opds-catalog" />
 </feed>`
 
+// notFoundFeed is the feed of a search that found nothing.
+func notFoundFeed(c *gin.Context) string {
+	return fmt.Sprintf(notFound, feedTexts(c).SearchResults)
+}
+
 // OpdsBooksSearch struct for book search
 type OpdsBooksSearch struct {
 	Title string `form:"title" json:"title" binding:"required"`
 	Page  int    `form:"page" json:"page"`
+	Sort  string `form:"sort" json:"sort"`
+	bookSearchFilters
 }
 
 // OpdsAuthorSearch struct for author search
@@ -61,15 +69,17 @@ func Search(c *gin.Context) {
 		},
 	}
 
+	t := feedTexts(c)
 	feed := &opdsutils.Feed{
-		Title:   "Поиск книг",
+		Title:   t.SearchBooks,
 		Id:      "tag:root:search",
+		Icon:    settings.Icon,
 		Links:   searchRootLinks,
 		Updated: time.Now(),
 	}
 	feed.Items = []*opdsutils.Item{
 		{
-			Title: "Поиск авторов",
+			Title: t.SearchAuthors,
 			Link: []opdsutils.Link{
 				{
 					Href: "/opds/search-author?name=" + url.QueryEscape(searchTerms),
@@ -78,10 +88,10 @@ func Search(c *gin.Context) {
 			},
 			Id:      "tag:search:author",
 			Updated: time.Now(),
-			Content: "Поиск авторов по фамилии",
+			Content: t.SearchAuthorsSummary,
 		},
 		{
-			Title: "Поиск книг",
+			Title: t.SearchBooks,
 			Link: []opdsutils.Link{
 				{
 					Href:  "/opds/books?title=" + url.QueryEscape(searchTerms),
@@ -91,7 +101,7 @@ func Search(c *gin.Context) {
 			},
 			Id:      "tag:search:book",
 			Updated: time.Now(),
-			Content: "Поиск книг по названию",
+			Content: t.SearchBooksSummary,
 		},
 	}
 
@@ -6,7 +6,6 @@ import (
 	"net/http"
 	"net/url"
 	"strconv"
-	"strings"
 	"time"
 
 	"gopds-api/httputil"
@@ -39,10 +38,9 @@ const (
 	typeOpenSearchDsc = "application/opensearchdescription+xml"
 	typeAtom          = "application/atom+xml"
 
-	hrefRoot         = "/opds"
-	hrefOpenSearch   = "/opds-opensearch.xml"
-	hrefSearchTerms  = "/opds/search?searchTerms={searchTerms}"
-	titleSearchBooks = "Поиск книг"
+	hrefRoot        = "/opds"
+	hrefOpenSearch  = "/opds-opensearch.xml"
+	hrefSearchTerms = "/opds/search?searchTerms={searchTerms}"
 )
 
 // opdsPageSize is the feed page every OPDS list in this package serves.
@@ -68,8 +66,9 @@ func hasNextSearchPage(offset, items, total int) bool {
 	return offset+items < total
 }
 
-// nextSearchHref builds the rel="next" target through url.Values, so Unicode
-// and spaces are escaped exactly once and the current query survives.
+// nextSearchHref builds an author search's rel="next" target through
+// url.Values, so Unicode and spaces are escaped exactly once and the current
+// query survives.
 func nextSearchHref(path, param, needle string, page int) string {
 	values := url.Values{}
 	values.Set(param, needle)
@@ -113,6 +112,46 @@ func globalSearchLinks() []opdsutils.Link {
 	}
 }
 
+// searchTitle is a search feed's title, naming the query its results answer
+// when the service rewrote the one typed.
+func searchTitle(t *texts, title string, rewrite *models.QueryRewrite) string {
+	if rewrite == nil {
+		return title
+	}
+	return fmt.Sprintf(t.RewrittenFor, title, rewrite.Query)
+}
+
+// didYouMeanFeed answers a book search that found nothing but has corrected
+// queries to offer: one navigation entry a query, keeping the filters and
+// the sort asked.
+func didYouMeanFeed(c *gin.Context, id, path string, links []opdsutils.Link,
+	suggestions []models.SpellingSuggestion, filters *bookSearchFilters, sort models.BookSort,
+) {
+	t := feedTexts(c)
+	items := make([]*opdsutils.Item, 0, len(suggestions))
+	for _, suggestion := range suggestions {
+		items = append(items, &opdsutils.Item{
+			Title: suggestion.Query,
+			Link: []opdsutils.Link{
+				{
+					Href: withSort(bookSearchHref(path, suggestion.Query, filters, 0), sort),
+					Type: typeOpdsCatalog,
+				},
+			},
+			Id:      "tag:search:didyoumean:" + url.QueryEscape(suggestion.Query),
+			Updated: time.Now(),
+			Content: fmt.Sprintf(t.DidYouMeanBooks, suggestion.Total),
+		})
+	}
+	renderFeed(c, &opdsutils.Feed{
+		Title:   t.DidYouMean,
+		Id:      id,
+		Links:   links,
+		Updated: time.Now(),
+		Items:   items,
+	})
+}
+
 // mapOpdsSearchError translates the service boundary into HTTP: a validation
 // rejection is the caller's bug (400), everything else is ours (500). Neither
 // is ever converted into the not-found feed — that feed belongs to empty
@@ -129,6 +168,7 @@ func mapOpdsSearchError(c *gin.Context, err error) {
 // renderFeed serializes the feed and answers. A serialization failure is a
 // 500, never the not-found feed.
 func renderFeed(c *gin.Context, feed *opdsutils.Feed) {
+	feed.Icon = settings.Icon
 	atom, err := feed.ToAtom()
 	if err != nil {
 		logging.Errorf("Error converting feed to Atom: %v", err)
@@ -138,13 +178,13 @@ func renderFeed(c *gin.Context, feed *opdsutils.Feed) {
 	c.Data(http.StatusOK, atomContentType, []byte(atom))
 }
 
-// bookItems renders book rows as acquisition entries, keeping the KOReader
-// annotation truncation the feeds always had.
+// bookItems renders book rows as acquisition entries in the reader's
+// language, keeping the KOReader annotation truncation the feeds always had.
 func bookItems(c *gin.Context, books []models.Book) []*opdsutils.Item {
 	items := []*opdsutils.Item{}
-	isKoreader := strings.Contains(c.GetHeader("User-Agent"), "KOReader")
+	opts := itemOptions(c)
 	for i := range books {
-		bookItem := opdsutils.CreateItem(books[i], isKoreader)
+		bookItem := opdsutils.CreateItem(books[i], opts)
 		items = append(items, &bookItem)
 	}
 	return items
@@ -178,31 +218,49 @@ func (h *SearchHandler) Books(c *gin.Context) {
 		httputil.NewError(c, http.StatusBadRequest, errors.New("bad_request"))
 		return
 	}
+	sort, err := models.ParseBookSort(filters.Sort)
+	if err != nil {
+		httputil.NewError(c, http.StatusBadRequest, err)
+		return
+	}
 	page := clampPage(filters.Page)
 	offset := page * opdsPageSize
 
-	result, err := h.Search.SearchBooks(c.Request.Context(), models.BookSearchRequest{
-		Query:  filters.Title,
-		UserID: c.GetInt64("user_id"),
-		Limit:  opdsPageSize,
-		Offset: offset,
-	})
+	req := models.BookSearchRequest{
+		Query:   filters.Title,
+		UserID:  c.GetInt64("user_id"),
+		Options: models.BookListOptions{Sort: sort},
+		Facets:  true,
+		Limit:   opdsPageSize,
+		Offset:  offset,
+	}
+	filters.apply(&req)
+	result, err := h.Search.SearchBooks(c.Request.Context(), req)
 	if err != nil {
 		mapOpdsSearchError(c, err)
 		return
 	}
 	if len(result.Books) == 0 {
-		c.Data(http.StatusOK, atomContentType, []byte(notFound))
+		if len(result.DidYouMean) == 0 {
+			c.Data(http.StatusOK, atomContentType, []byte(notFoundFeed(c)))
+			return
+		}
+		didYouMeanFeed(c, fmt.Sprintf("tag:search:books:%s:didyoumean", url.QueryEscape(filters.Title)),
+			"/opds/books", globalSearchLinks(), result.DidYouMean, &filters.bookSearchFilters, sort)
 		return
 	}
 
 	links := globalSearchLinks()
 	if hasNextSearchPage(offset, len(result.Books), result.Total) {
-		links = nextSearchLink(links, nextSearchHref("/opds/books", "title", filters.Title, page), true)
+		next := bookSearchHref("/opds/books", filters.Title, &filters.bookSearchFilters, page+1)
+		links = nextSearchLink(links, withSort(next, sort), true)
 	}
+	links = append(links, sortFacets(feedTexts(c), bookSearchHref("/opds/books", filters.Title, &filters.bookSearchFilters, 0), sort)...)
+	links = append(links, searchFacets(feedTexts(c), "/opds/books", filters.Title, &filters.bookSearchFilters, sort,
+		result.Facets, false)...)
 
 	renderFeed(c, &opdsutils.Feed{
-		Title:   titleSearchBooks,
+		Title:   searchTitle(feedTexts(c), feedTexts(c).SearchBooks, result.Rewrite),
 		Id:      fmt.Sprintf("tag:search:books:%s:%d", url.QueryEscape(filters.Title), page),
 		Links:   links,
 		Updated: time.Now(),
@@ -230,7 +288,7 @@ func (h *SearchHandler) Authors(c *gin.Context) {
 		return
 	}
 	if len(result.Authors) == 0 {
-		c.Data(http.StatusOK, atomContentType, []byte(notFound))
+		c.Data(http.StatusOK, atomContentType, []byte(notFoundFeed(c)))
 		return
 	}
 
@@ -240,7 +298,7 @@ func (h *SearchHandler) Authors(c *gin.Context) {
 	}
 
 	renderFeed(c, &opdsutils.Feed{
-		Title:   "Поиск автора",
+		Title:   searchTitle(feedTexts(c), feedTexts(c).AuthorResults, result.Rewrite),
 		Id:      fmt.Sprintf("tag:search:authors:%s:%d", url.QueryEscape(filters.Name), page),
 		Links:   links,
 		Updated: time.Now(),
@@ -259,33 +317,53 @@ func (h *SearchHandler) BooksByLanguage(c *gin.Context) {
 		httputil.NewError(c, http.StatusBadRequest, errors.New("bad_request"))
 		return
 	}
+	sort, err := models.ParseBookSort(filters.Sort)
+	if err != nil {
+		httputil.NewError(c, http.StatusBadRequest, err)
+		return
+	}
 	page := clampPage(filters.Page)
 	offset := page * opdsPageSize
 
-	result, err := h.Search.SearchBooks(c.Request.Context(), models.BookSearchRequest{
+	// The path's language is the search's; a lang filter is not applied.
+	filters.Lang = ""
+	req := models.BookSearchRequest{
 		Query:    filters.Title,
 		UserID:   c.GetInt64("user_id"),
 		Language: lang,
+		Options:  models.BookListOptions{Sort: sort},
+		Facets:   true,
 		Limit:    opdsPageSize,
 		Offset:   offset,
-	})
+	}
+	filters.apply(&req)
+	result, err := h.Search.SearchBooks(c.Request.Context(), req)
 	if err != nil {
 		mapOpdsSearchError(c, err)
 		return
 	}
+	path := fmt.Sprintf("/opds/lang/%s/search-books", lang)
 	if len(result.Books) == 0 {
-		c.Data(http.StatusOK, atomContentType, []byte(notFound))
+		if len(result.DidYouMean) == 0 {
+			c.Data(http.StatusOK, atomContentType, []byte(notFoundFeed(c)))
+			return
+		}
+		didYouMeanFeed(c, fmt.Sprintf("tag:lang:%s:search:books:%s:didyoumean", lang, url.QueryEscape(filters.Title)),
+			path, langLinks(lang), result.DidYouMean, &filters.bookSearchFilters, sort)
 		return
 	}
 
 	links := langLinks(lang)
 	if hasNextSearchPage(offset, len(result.Books), result.Total) {
-		path := fmt.Sprintf("/opds/lang/%s/search-books", lang)
-		links = nextSearchLink(links, nextSearchHref(path, "title", filters.Title, page), false)
+		next := bookSearchHref(path, filters.Title, &filters.bookSearchFilters, page+1)
+		links = nextSearchLink(links, withSort(next, sort), false)
 	}
+	links = append(links, sortFacets(feedTexts(c), bookSearchHref(path, filters.Title, &filters.bookSearchFilters, 0), sort)...)
+	links = append(links, searchFacets(feedTexts(c), path, filters.Title, &filters.bookSearchFilters, sort,
+		result.Facets, true)...)
 
 	renderFeed(c, &opdsutils.Feed{
-		Title:   fmt.Sprintf("Поиск книг: %s", getLangName(lang)),
+		Title:   searchTitle(feedTexts(c), fmt.Sprintf(feedTexts(c).SearchBooksIn, getLangName(lang)), result.Rewrite),
 		Id:      fmt.Sprintf("tag:lang:%s:search:books:%s:%d", lang, url.QueryEscape(filters.Title), page),
 		Links:   links,
 		Updated: time.Now(),
@@ -316,7 +394,7 @@ func (h *SearchHandler) AuthorsByLanguage(c *gin.Context) {
 		return
 	}
 	if len(result.Authors) == 0 {
-		c.Data(http.StatusOK, atomContentType, []byte(notFound))
+		c.Data(http.StatusOK, atomContentType, []byte(notFoundFeed(c)))
 		return
 	}
 
@@ -327,7 +405,7 @@ func (h *SearchHandler) AuthorsByLanguage(c *gin.Context) {
 	}
 
 	renderFeed(c, &opdsutils.Feed{
-		Title:   fmt.Sprintf("Поиск авторов: %s", getLangName(lang)),
+		Title:   searchTitle(feedTexts(c), fmt.Sprintf(feedTexts(c).SearchAuthorsIn, getLangName(lang)), result.Rewrite),
 		Id:      fmt.Sprintf("tag:lang:%s:search:authors:%s:%d", lang, url.QueryEscape(filters.Name), page),
 		Links:   links,
 		Updated: time.Now(),
@@ -355,3 +355,41 @@ func TestOpdsSearch_AuthorsByLanguageMapsRequestAndFeed(t *testing.T) {
 	assert.Equal(t, "/opds/lang/ru/author/5/0", feed.Entries[0].Links[0].Href,
 		"language author entries keep browsing inside the language")
 }
+
+// A page the service answered for a rewritten query says so in the feed's
+// title, since an OPDS reader has nowhere else to show it.
+func TestOpdsSearch_BooksNamesTheRewrite(t *testing.T) {
+	fake := &fakePublicSearch{booksPage: models.BookSearchPage{
+		Books: cannedBooks(2), Total: 2, Limit: 10,
+		Rewrite: &models.QueryRewrite{Query: "война и мир", Kind: models.RewriteLayout},
+	}}
+	r := newOpdsTestRouter(fake)
+
+	rec := doGET(t, r, "/opds/books?title="+url.QueryEscape("djqyf b vbh"))
+
+	require.Equal(t, http.StatusOK, rec.Code, "body=%s", rec.Body.String())
+	feed := parseFeed(t, rec)
+	assert.Equal(t, "Поиск книг — показаны результаты для «война и мир»", feed.Title)
+	assert.Equal(t, "djqyf b vbh", fake.booksReqs[0].Query, "the query typed is what the service is asked")
+}
+
+func TestOpdsSearch_BooksOffersDidYouMean(t *testing.T) {
+	fake := &fakePublicSearch{booksPage: models.BookSearchPage{
+		Limit: 10,
+		DidYouMean: []models.SpellingSuggestion{
+			{Query: "война и мир", Total: 4},
+			{Query: "война и миф", Total: 1},
+		},
+	}}
+	r := newOpdsTestRouter(fake)
+
+	rec := doGET(t, r, "/opds/lang/ru/search-books?sort=title&title="+url.QueryEscape("вайна и мир"))
+
+	require.Equal(t, http.StatusOK, rec.Code, "body=%s", rec.Body.String())
+	feed := parseFeed(t, rec)
+	assert.Equal(t, "Возможно, вы искали", feed.Title)
+	require.Len(t, feed.Entries, 2)
+	assert.Equal(t, "/opds/lang/ru/search-books?title=%D0%B2%D0%BE%D0%B9%D0%BD%D0%B0+%D0%B8+%D0%BC%D0%B8%D1%80&sort=title",
+		feed.Entries[0].Links[0].Href, "a suggestion searches again in the same scope and order")
+	assert.Contains(t, feed.Entries[1].Links[0].Href, "title="+url.QueryEscape("война и миф"))
+}
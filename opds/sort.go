@@ -0,0 +1,71 @@
+package opds
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"gopds-api/httputil"
+	"gopds-api/models"
+	"gopds-api/opdsutils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Book lists are sorted by a sort query parameter, which readers find as
+// OPDS facet links: one per order, the one in effect marked active. Every
+// page of a list carries its order over to the next.
+
+// relFacet is the rel of a facet link.
+const relFacet = "http://opds-spec.org/facet"
+
+// facetSorts are the orders a feed offers, after the list's own. Series
+// order is left out: no feed lists a series.
+var facetSorts = []string{models.SortTitle, models.SortAuthor, models.SortAdded, models.SortYear, models.SortPopular, models.SortRating}
+
+// listSort reads the order a list is asked for. It reports false, having
+// answered 400, for an order no list is sorted in.
+func listSort(c *gin.Context) (models.BookSort, bool) {
+	sort, err := models.ParseBookSort(c.Query("sort"))
+	if err != nil {
+		httputil.NewError(c, http.StatusBadRequest, err)
+		return models.BookSort{}, false
+	}
+	return sort, true
+}
+
+// withSort carries an order over to a link to another page of the list.
+func withSort(href string, sort models.BookSort) string {
+	if sort.Key == "" {
+		return href
+	}
+	sep := "?"
+	if strings.Contains(href, "?") {
+		sep = "&"
+	}
+	return href + sep + "sort=" + url.QueryEscape(sort.String())
+}
+
+// sortFacets are the links that sort a list from its first page, first.
+func sortFacets(t *texts, first string, current models.BookSort) []opdsutils.Link {
+	links := []opdsutils.Link{{
+		Href:        first,
+		Rel:         relFacet,
+		Type:        typeOpdsCatalog,
+		Title:       t.SortDefault,
+		FacetGroup:  t.SortGroup,
+		ActiveFacet: current.Key == "",
+	}}
+	for _, key := range facetSorts {
+		sort := models.BookSort{Key: key}
+		links = append(links, opdsutils.Link{
+			Href:        withSort(first, sort),
+			Rel:         relFacet,
+			Type:        typeOpdsCatalog,
+			Title:       t.Sorts[key],
+			FacetGroup:  t.SortGroup,
+			ActiveFacet: current == sort,
+		})
+	}
+	return links
+}
@@ -0,0 +1,83 @@
+package opds
+
+import (
+	"encoding/xml"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"gopds-api/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// facetLink reads the facet attributes testLink leaves out.
+type facetLink struct {
+	Href        string `xml:"href,attr"`
+	Rel         string `xml:"rel,attr"`
+	Title       string `xml:"title,attr"`
+	FacetGroup  string `xml:"facetGroup,attr"`
+	ActiveFacet string `xml:"activeFacet,attr"`
+}
+
+func TestOpdsSearch_BooksSortFacets(t *testing.T) {
+	fake := &fakePublicSearch{booksPage: models.BookSearchPage{
+		Books: cannedBooks(10), Total: 21, Limit: 10,
+	}}
+	r := newOpdsTestRouter(fake)
+
+	rec := doGET(t, r, "/opds/books?title="+url.QueryEscape("война")+"&sort=title")
+
+	require.Equal(t, http.StatusOK, rec.Code, "body=%s", rec.Body.String())
+	require.Len(t, fake.booksReqs, 1)
+	assert.Equal(t, models.BookSort{Key: models.SortTitle}, fake.booksReqs[0].Options.Sort)
+
+	next, ok := nextFeedLink(parseFeed(t, rec).Links)
+	require.True(t, ok)
+	assert.Equal(t, "title", nextQuery(t, next).Get("sort"), "the next page keeps the order")
+
+	var feed struct {
+		Links []facetLink `xml:"link"`
+	}
+	require.NoError(t, xml.Unmarshal(rec.Body.Bytes(), &feed))
+	var facets []facetLink
+	for _, l := range feed.Links {
+		if l.Rel == relFacet {
+			facets = append(facets, l)
+		}
+	}
+	require.Len(t, facets, len(facetSorts)+1, "the list's own order and one facet per sort")
+
+	active := 0
+	for _, f := range facets {
+		assert.Equal(t, textsRU.SortGroup, f.FacetGroup)
+		u, err := url.Parse(f.Href)
+		require.NoError(t, err)
+		assert.Equal(t, "/opds/books", u.Path)
+		assert.Equal(t, "война", u.Query().Get("title"))
+		assert.Empty(t, u.Query().Get("page"), "a facet starts the list over")
+		if f.ActiveFacet == "true" {
+			active++
+			assert.Equal(t, "title", u.Query().Get("sort"))
+			assert.Equal(t, textsRU.Sorts[models.SortTitle], f.Title)
+		}
+	}
+	assert.Equal(t, 1, active, "exactly the order in effect is active")
+}
+
+func TestOpdsSearch_BooksUnknownSortIs400(t *testing.T) {
+	fake := &fakePublicSearch{}
+	r := newOpdsTestRouter(fake)
+
+	rec := doGET(t, r, "/opds/books?title=x&sort=stars")
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Empty(t, fake.booksReqs)
+}
+
+func TestWithSort(t *testing.T) {
+	assert.Equal(t, "/opds/new/1/0", withSort("/opds/new/1/0", models.BookSort{}))
+	assert.Equal(t, "/opds/new/1/0?sort=-year", withSort("/opds/new/1/0", models.BookSort{Key: models.SortYear, Reverse: true}))
+	assert.Equal(t, "/opds/books?title=x&sort=added", withSort("/opds/books?title=x", models.BookSort{Key: models.SortAdded}))
+}
@@ -0,0 +1,303 @@
+package opds
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"gopds-api/models"
+	"gopds-api/opdsutils"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/text/language"
+)
+
+// The wording of the feeds, in both languages the interface offers. A reader
+// is answered in the interface language of their account, else in the first
+// language of their Accept-Language the feeds speak, else in the one the
+// catalogue is configured with. Names of books, authors, series and
+// collections are the library's and are never translated.
+
+// The two languages the feeds speak.
+const (
+	langRU = "ru"
+	langEN = "en"
+)
+
+// feedLanguages are the languages the feeds speak, matched against
+// Accept-Language in this order.
+var feedLanguages = []string{langRU, langEN}
+
+var feedLanguageMatcher = language.NewMatcher([]language.Tag{language.Russian, language.English})
+
+// The entries the root feed can offer, by the names the configuration uses.
+const (
+	NavFavorites   = "favorites"
+	NavRecommended = "recommended"
+	NavLanguages   = "languages"
+	NavGenres      = "genres"
+	NavCollections = "collections"
+)
+
+// DefaultNavigation is the root feed of a catalogue that does not lay it
+// out itself.
+var DefaultNavigation = []string{NavFavorites, NavRecommended, NavLanguages, NavGenres, NavCollections}
+
+// Settings shape the catalogue every reader sees.
+type Settings struct {
+	// Title names the catalogue in its root feed and its search description.
+	Title string
+	// Icon is the address of the feeds' icon.
+	Icon string
+	// Language is the language of readers who state none.
+	Language string
+	// Navigation lists the entries of the root feed, in order.
+	Navigation []string
+}
+
+// settings are those Configure was given; the defaults keep the feeds as
+// they were before they could be configured.
+var settings = Settings{
+	Title:      "Лепробиблиотека",
+	Icon:       "/favicon.ico",
+	Language:   langRU,
+	Navigation: DefaultNavigation,
+}
+
+// Configure sets up the catalogue. Empty settings keep their defaults; an
+// unknown language or navigation entry is an error.
+func Configure(s Settings) error {
+	if s.Language != "" && !slices.Contains(feedLanguages, s.Language) {
+		return fmt.Errorf("opds language %q is not one of %s", s.Language, strings.Join(feedLanguages, ", "))
+	}
+	for _, entry := range s.Navigation {
+		if !slices.Contains(DefaultNavigation, entry) {
+			return fmt.Errorf("opds navigation entry %q is not one of %s", entry, strings.Join(DefaultNavigation, ", "))
+		}
+	}
+	if s.Title != "" {
+		settings.Title = s.Title
+	}
+	if s.Icon != "" {
+		settings.Icon = s.Icon
+	}
+	if s.Language != "" {
+		settings.Language = s.Language
+	}
+	if s.Navigation != nil {
+		settings.Navigation = s.Navigation
+	}
+	return nil
+}
+
+// readerLanguage is the language a request is answered in.
+func readerLanguage(c *gin.Context) string {
+	if lang := c.GetString("interface_lang"); slices.Contains(feedLanguages, lang) {
+		return lang
+	}
+	if header := c.GetHeader("Accept-Language"); header != "" {
+		tags, _, err := language.ParseAcceptLanguage(header)
+		if err == nil && len(tags) > 0 {
+			if _, i, confidence := feedLanguageMatcher.Match(tags...); confidence != language.No {
+				return feedLanguages[i]
+			}
+		}
+	}
+	return settings.Language
+}
+
+// feedTexts is the wording of the feeds for a request.
+func feedTexts(c *gin.Context) *texts {
+	if readerLanguage(c) == langEN {
+		return &textsEN
+	}
+	return &textsRU
+}
+
+// itemOptions adapt book entries to the reader of a request.
+func itemOptions(c *gin.Context) opdsutils.ItemOptions {
+	return opdsutils.ItemOptions{
+		Koreader:    strings.Contains(c.GetHeader("User-Agent"), "KOReader"),
+		Lang:        readerLanguage(c),
+		AuthorBooks: feedTexts(c).AuthorBooks,
+		Similar:     feedTexts(c).SimilarBooks,
+		Rating:      feedTexts(c).Rating,
+	}
+}
+
+// texts is the wording of the feeds in one language. A field with a %
+// verb is a format, and says what it is given.
+type texts struct {
+	Favorites          string
+	FavoritesSummary   string
+	Recommended        string
+	RecommendedSummary string
+	Languages          string
+	LanguagesSummary   string
+	Genres             string
+	GenresSummary      string
+	Collections        string
+	CollectionsSummary string
+
+	LanguagesTitle     string
+	LanguageBooks      string // the language's name
+	LanguageSummary    string // the language's name
+	AllBooks           string
+	AllBooksInLanguage string // the language's name
+	GenresTitle        string
+	AllBooksCount      string // the number of books
+	AllBooksOfSection  string // the section's name
+
+	SearchBooks          string
+	SearchBooksSummary   string
+	SearchAuthors        string
+	SearchAuthorsSummary string
+	AuthorResults        string
+	SearchIn             string // the language's name
+	SearchBooksIn        string // the language's name
+	SearchAuthorsIn      string // the language's name
+	SearchResults        string
+	OpenSearch           string
+	// RewrittenFor is a search feed's title, then the query its results
+	// were found for when the one typed found little.
+	RewrittenFor string
+	// DidYouMean titles the corrected queries offered by a search that
+	// found nothing; DidYouMeanBooks describes each.
+	DidYouMean      string
+	DidYouMeanBooks string // the number of books
+	// The facet groups of a book search, and a decade's facet.
+	FacetLanguage string
+	FacetGenre    string
+	FacetAuthor   string
+	FacetSeries   string
+	FacetDecade   string
+	DecadeLabel   string // the decade's first year
+
+	Author      string
+	AuthorBooks string // the author's name
+	// SimilarBooks titles a book's link to the books like it, and that
+	// feed.
+	SimilarBooks string
+	// Rating opens the summary of a book readers rated.
+	Rating string // the average stars, then the number of ratings
+
+	SortGroup   string
+	SortDefault string
+	Sorts       map[string]string // by sort order
+}
+
+var textsRU = texts{
+	Favorites:          "Избранное",
+	FavoritesSummary:   "Избранное",
+	Recommended:        "Рекомендации",
+	RecommendedSummary: "Книги, похожие на ваше избранное и скачанное",
+	Languages:          "По языкам",
+	LanguagesSummary:   "Книги по языкам",
+	Genres:             "По жанрам",
+	GenresSummary:      "Книги по жанрам",
+	Collections:        "Подборки",
+	CollectionsSummary: "Подборки книг",
+
+	LanguagesTitle:     "Книги по языкам",
+	LanguageBooks:      "Книги: %s",
+	LanguageSummary:    "Книги на языке: %s",
+	AllBooks:           "Все книги",
+	AllBooksInLanguage: "Все книги на языке %s",
+	GenresTitle:        "Жанры",
+	AllBooksCount:      "Все книги (%d)",
+	AllBooksOfSection:  "Все книги раздела «%s»",
+
+	SearchBooks:          "Поиск книг",
+	SearchBooksSummary:   "Поиск книг по названию",
+	SearchAuthors:        "Поиск авторов",
+	SearchAuthorsSummary: "Поиск авторов по фамилии",
+	AuthorResults:        "Поиск автора",
+	SearchIn:             "Поиск: %s",
+	SearchBooksIn:        "Поиск книг: %s",
+	SearchAuthorsIn:      "Поиск авторов: %s",
+	SearchResults:        "Результат поиска",
+	OpenSearch:           "Поиск книг и авторов",
+	RewrittenFor:         "%s — показаны результаты для «%s»",
+	DidYouMean:           "Возможно, вы искали",
+	DidYouMeanBooks:      "Найдено книг: %d",
+	FacetLanguage:        "Язык",
+	FacetGenre:           "Жанр",
+	FacetAuthor:          "Автор",
+	FacetSeries:          "Серия",
+	FacetDecade:          "Десятилетие",
+	DecadeLabel:          "%s-е",
+
+	Author:       "Автор",
+	AuthorBooks:  "Все книги: %s",
+	SimilarBooks: "Похожие книги",
+	Rating:       "Рейтинг: %.1f из 5 (оценок: %d)",
+
+	SortGroup:   "Сортировка",
+	SortDefault: "Обычный порядок",
+	Sorts: map[string]string{
+		models.SortTitle:   "По названию",
+		models.SortAuthor:  "По автору",
+		models.SortAdded:   "Новые поступления",
+		models.SortYear:    "По году издания",
+		models.SortPopular: "Популярные",
+		models.SortRating:  "По рейтингу",
+	},
+}
+
+var textsEN = texts{
+	Favorites:          "Favorites",
+	FavoritesSummary:   "Your favorite books",
+	Recommended:        "Recommended",
+	RecommendedSummary: "Books like the ones you favorited and downloaded",
+	Languages:          "By language",
+	LanguagesSummary:   "Books by language",
+	Genres:             "By genre",
+	GenresSummary:      "Books by genre",
+	Collections:        "Collections",
+	CollectionsSummary: "Book collections",
+
+	LanguagesTitle:     "Books by language",
+	LanguageBooks:      "Books: %s",
+	LanguageSummary:    "Books in %s",
+	AllBooks:           "All books",
+	AllBooksInLanguage: "All books in %s",
+	GenresTitle:        "Genres",
+	AllBooksCount:      "All books (%d)",
+	AllBooksOfSection:  "All books in %s",
+
+	SearchBooks:          "Book search",
+	SearchBooksSummary:   "Search books by title",
+	SearchAuthors:        "Author search",
+	SearchAuthorsSummary: "Search authors by last name",
+	AuthorResults:        "Author search",
+	SearchIn:             "Search: %s",
+	SearchBooksIn:        "Book search: %s",
+	SearchAuthorsIn:      "Author search: %s",
+	SearchResults:        "Search results",
+	OpenSearch:           "Search books and authors",
+	RewrittenFor:         "%s — showing results for “%s”",
+	DidYouMean:           "Did you mean",
+	DidYouMeanBooks:      "Books found: %d",
+	FacetLanguage:        "Language",
+	FacetGenre:           "Genre",
+	FacetAuthor:          "Author",
+	FacetSeries:          "Series",
+	FacetDecade:          "Decade",
+	DecadeLabel:          "%ss",
+
+	Author:       "Author",
+	AuthorBooks:  "All books: %s",
+	SimilarBooks: "Similar books",
+	Rating:       "Rating: %.1f of 5 (%d ratings)",
+
+	SortGroup:   "Sort",
+	SortDefault: "Default order",
+	Sorts: map[string]string{
+		models.SortTitle:   "By title",
+		models.SortAuthor:  "By author",
+		models.SortAdded:   "Recently added",
+		models.SortYear:    "By publication year",
+		models.SortPopular: "Most popular",
+		models.SortRating:  "Top rated",
+	},
+}
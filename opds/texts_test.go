@@ -0,0 +1,148 @@
+package opds
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"gopds-api/models"
+	"gopds-api/opdsutils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// keepSettings restores the catalogue settings a test changes.
+func keepSettings(t *testing.T) {
+	t.Helper()
+	old := settings
+	t.Cleanup(func() { settings = old })
+}
+
+func TestReaderLanguage(t *testing.T) {
+	keepSettings(t)
+	require.NoError(t, Configure(Settings{Language: langEN}))
+
+	tests := []struct {
+		name, account, acceptLanguage, want string
+	}{
+		{"account wins", "ru", "en-US,en;q=0.9", langRU},
+		{"Accept-Language when the account names none", "", "de-DE,ru;q=0.8,en;q=0.5", langRU},
+		{"regional variant", "", "en-GB", langEN},
+		{"default when nothing matches", "", "de, fr", langEN},
+		{"default when nothing is said", "", "", langEN},
+		{"unknown account language is ignored", "uk", "ru", langRU},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodGet, "/opds", nil)
+			if tt.acceptLanguage != "" {
+				c.Request.Header.Set("Accept-Language", tt.acceptLanguage)
+			}
+			if tt.account != "" {
+				c.Set("interface_lang", tt.account)
+			}
+			assert.Equal(t, tt.want, readerLanguage(c))
+		})
+	}
+}
+
+func TestConfigure(t *testing.T) {
+	keepSettings(t)
+	assert.Error(t, Configure(Settings{Language: "de"}))
+	assert.Error(t, Configure(Settings{Navigation: []string{NavGenres, "authors"}}))
+
+	require.NoError(t, Configure(Settings{Title: "Home Library", Navigation: []string{NavGenres}}))
+	assert.Equal(t, "Home Library", settings.Title)
+	assert.Equal(t, "/favicon.ico", settings.Icon, "unset settings keep their defaults")
+	assert.Equal(t, []string{NavGenres}, settings.Navigation)
+}
+
+// TestTexts_Complete guards against a wording added to one language only.
+func TestTexts_Complete(t *testing.T) {
+	for lang, tx := range map[string]texts{langRU: textsRU, langEN: textsEN} {
+		for _, s := range []string{
+			tx.Favorites, tx.Languages, tx.Genres, tx.Collections,
+			tx.LanguagesTitle, tx.LanguageBooks, tx.AllBooks, tx.GenresTitle,
+			tx.SearchBooks, tx.SearchAuthors, tx.AuthorResults, tx.SearchResults,
+			tx.OpenSearch, tx.Author, tx.AuthorBooks, tx.Recommended, tx.SimilarBooks, tx.Rating,
+		} {
+			assert.NotEmpty(t, s, lang)
+		}
+	}
+}
+
+func TestOpenSearch_Localized(t *testing.T) {
+	keepSettings(t)
+	require.NoError(t, Configure(Settings{Title: "Books & Co"}))
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/opds-opensearch.xml", OpenSearch)
+
+	req := httptest.NewRequest(http.MethodGet, "/opds-opensearch.xml", nil)
+	req.Header.Set("Accept-Language", "en")
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "<ShortName>Books &amp; Co</ShortName>")
+	assert.Contains(t, rec.Body.String(), "<Description>Search books and authors</Description>")
+}
+
+func TestOpdsSearch_FeedFollowsAcceptLanguage(t *testing.T) {
+	fake := &fakePublicSearch{booksPage: models.BookSearchPage{
+		Books: []models.Book{{ID: 1, Title: "One"}},
+		Total: 1,
+	}}
+	req := httptest.NewRequest(http.MethodGet, "/opds/books?title=one", nil)
+	req.Header.Set("Accept-Language", "en-US")
+	rec := httptest.NewRecorder()
+	newOpdsTestRouter(fake).ServeHTTP(rec, req)
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "<title>Book search</title>")
+}
+
+func TestCreateItem_GenresAndAuthors(t *testing.T) {
+	book := models.Book{
+		ID:      5,
+		Title:   "Solaris",
+		Authors: []models.Author{{ID: 12, FullName: "Stanisław Lem"}},
+		Genres:  []models.Genre{{ID: 3, Genre: "sf", Title: "Фантастика", TitleEn: "Science fiction"}},
+	}
+	item := opdsutils.CreateItem(book, opdsutils.ItemOptions{Lang: langEN, AuthorBooks: textsEN.AuthorBooks})
+	atom, err := (&opdsutils.Feed{Title: "t", Id: "t", Items: []*opdsutils.Item{&item}}).ToAtom()
+	require.NoError(t, err)
+
+	assert.Contains(t, atom, `<category term="sf" label="Science fiction" scheme="`+opdsutils.GenreScheme+`">`)
+	assert.Contains(t, atom, "<uri>/opds/new/0/12</uri>")
+	assert.Contains(t, atom, `title="All books: Stanisław Lem"`)
+	assert.Contains(t, atom, "<icon>/favicon.ico</icon>")
+}
+
+func TestCreateItem_RatingOpensTheSummary(t *testing.T) {
+	opts := opdsutils.ItemOptions{Lang: langEN, AuthorBooks: textsEN.AuthorBooks, Rating: textsEN.Rating}
+
+	rated := opdsutils.CreateItem(models.Book{ID: 5, Annotation: "A planet.", Rating: 4.375, RatingCount: 8}, opts)
+	assert.Equal(t, "Rating: 4.4 of 5 (8 ratings)\nA planet.", rated.Description)
+
+	unrated := opdsutils.CreateItem(models.Book{ID: 6, Annotation: "A planet."}, opts)
+	assert.Equal(t, "A planet.", unrated.Description)
+}
+
+func TestCreateItem_StoredEPUBIsHadOnlyAsOne(t *testing.T) {
+	acquisitions := func(book models.Book) []string {
+		var hrefs []string
+		for _, l := range opdsutils.CreateItem(book, opdsutils.ItemOptions{Lang: langEN}).Link {
+			if l.Rel == "http://opds-spec.org/acquisition/open-access" {
+				hrefs = append(hrefs, l.Href)
+			}
+		}
+		return hrefs
+	}
+	assert.Equal(t, []string{"/opds/get/fb2/5", "/opds/get/epub/5", "/opds/get/mobi/5"},
+		acquisitions(models.Book{ID: 5, Format: "fb2"}))
+	assert.Equal(t, []string{"/opds/get/epub/6"}, acquisitions(models.Book{ID: 6, Format: "epub"}))
+}
@@ -10,54 +10,105 @@ import (
 	"gopds-api/models"
 )
 
-func rels() []string {
-	return []string{
+// coverRels are the link relations of the full-size cover; thumbnailRels
+// point at the small copy, which is all a catalogue list draws.
+var (
+	coverRels = []string{
 		"http://opds-spec.org/image",
 		"x-stanza-cover-image",
+	}
+	thumbnailRels = []string{
+		"http://opds-spec.org/image/thumbnail",
 		"http://opds-spec.org/thumbnail",
 		"x-stanza-cover-image-thumbnail",
 	}
+)
+
+// GenreScheme names the vocabulary of book categories: the FB2 genre codes.
+const GenreScheme = "http://www.fictionbook.org/index.php/Eng:FictionBook_2.1_genres"
+
+// AuthorFeedHref is the feed of an author's books, which entries give as
+// the author's uri.
+func AuthorFeedHref(authorID int64) string {
+	return fmt.Sprintf("/opds/new/0/%d", authorID)
+}
+
+// SimilarFeedHref is the feed of the books like a book.
+func SimilarFeedHref(bookID int64) string {
+	return fmt.Sprintf("/opds/similar/%d", bookID)
+}
+
+// ItemOptions adapt a book entry to the reader.
+type ItemOptions struct {
+	// Koreader shortens the annotation, which KOReader shows in full in its
+	// lists.
+	Koreader bool
+	// Lang is the reader's language, for the names of genres.
+	Lang string
+	// AuthorBooks titles the link to an author's books, the name given
+	// for %s.
+	AuthorBooks string
+	// Similar titles the link to the books like the entry's, none when
+	// empty.
+	Similar string
+	// Rating opens the summary of a rated book, the average stars and the
+	// number of ratings given for its verbs; no line when empty.
+	Rating string
 }
 
 func createPostersLink(book models.Book) []Link {
 	var links []Link
 	posterLink := viper.GetString("app.cdn") + "/books-posters/no-cover.png"
+	thumbnailLink := posterLink
 	if book.Cover {
 		posterLink = fmt.Sprintf("%s/books-posters/%s",
 			viper.GetString("app.cdn"),
 			posters.RelativePath(book.Path, book.FileName))
+		thumbnailLink = fmt.Sprintf("%s?w=%d", posterLink, posters.ThumbnailSmall)
 	}
-	for _, r := range rels() {
+	for _, r := range coverRels {
 		links = append(links, Link{
 			Href: posterLink,
 			Rel:  r,
 			Type: "image/jpeg",
 		})
 	}
+	for _, r := range thumbnailRels {
+		links = append(links, Link{
+			Href: thumbnailLink,
+			Rel:  r,
+			Type: "image/jpeg",
+		})
+	}
 	return links
 }
 
 // CreateItem creates an BookItem for xml generate
-func CreateItem(book models.Book, isKoreader bool) Item {
+func CreateItem(book models.Book, opts ItemOptions) Item {
 	posterLinks := createPostersLink(book)
 	linkPath := "/opds/get/"
 
-	links := []Link{
-		{
-			Href: linkPath + "fb2/" + strconv.FormatInt(book.ID, 10),
-			Rel:  "http://opds-spec.org/acquisition/open-access",
-			Type: "application/fb2+zip",
-		},
-		{
-			Href: linkPath + "epub/" + strconv.FormatInt(book.ID, 10),
-			Rel:  "http://opds-spec.org/acquisition/open-access",
-			Type: "application/epub+zip",
-		},
-		{
-			Href: linkPath + "mobi/" + strconv.FormatInt(book.ID, 10),
-			Rel:  "http://opds-spec.org/acquisition/open-access",
-			Type: "application/x-mobipocket-ebook",
-		},
+	epub := Link{
+		Href: linkPath + "epub/" + strconv.FormatInt(book.ID, 10),
+		Rel:  "http://opds-spec.org/acquisition/open-access",
+		Type: "application/epub+zip",
+	}
+	// A book stored as EPUB is had only as one: the converters read FB2.
+	links := []Link{epub}
+	if book.Format != "epub" {
+		links = []Link{
+			{
+				Href: linkPath + "fb2/" + strconv.FormatInt(book.ID, 10),
+				Rel:  "http://opds-spec.org/acquisition/open-access",
+				Type: "application/fb2+zip",
+			},
+			epub,
+			{
+				Href: linkPath + "mobi/" + strconv.FormatInt(book.ID, 10),
+				Rel:  "http://opds-spec.org/acquisition/open-access",
+				Type: "application/x-mobipocket-ebook",
+			},
+		}
 	}
 	links = append(links, posterLinks...)
 
@@ -70,23 +121,41 @@ func CreateItem(book models.Book, isKoreader bool) Item {
 		})
 		// Link to browse all books by this author
 		links = append(links, Link{
-			Href:  fmt.Sprintf("/opds/new/0/%d", author.ID),
+			Href:  AuthorFeedHref(author.ID),
+			Rel:   "related",
+			Type:  "application/atom+xml;profile=opds-catalog",
+			Title: fmt.Sprintf(opts.AuthorBooks, author.FullName),
+		})
+	}
+
+	if opts.Similar != "" {
+		links = append(links, Link{
+			Href:  SimilarFeedHref(book.ID),
 			Rel:   "related",
 			Type:  "application/atom+xml;profile=opds-catalog",
-			Title: fmt.Sprintf("Все книги: %s", author.FullName),
+			Title: opts.Similar,
 		})
 	}
 
-	if isKoreader {
+	var categories []Category
+	for _, g := range book.Genres {
+		categories = append(categories, Category{Term: g.Genre, Label: g.LocalizedName(opts.Lang)})
+	}
+
+	if opts.Koreader {
 		if len(book.Annotation) > 20 {
 			book.Annotation = book.Annotation[:20]
 		}
 	}
+	if opts.Rating != "" && book.RatingCount > 0 {
+		book.Annotation = fmt.Sprintf(opts.Rating, book.Rating, book.RatingCount) + "\n" + book.Annotation
+	}
 
 	return Item{
 		Title:       book.Title,
 		Link:        links,
 		Authors:     itemAuthors,
+		Categories:  categories,
 		Description: book.Annotation,
 		Id:          strconv.FormatInt(book.ID, 10),
 		Updated:     book.RegisterDate,
@@ -10,6 +10,11 @@ type AtomLink struct {
 	Type    string   `xml:"type,attr,omitempty"`
 	Length  string   `xml:"length,attr,omitempty"`
 	Title   string   `xml:"title,attr,omitempty"`
+	// FacetGroup and ActiveFacet are the OPDS facet attributes.
+	FacetGroup  string `xml:"opds:facetGroup,attr,omitempty"`
+	ActiveFacet string `xml:"opds:activeFacet,attr,omitempty"`
+	// Count is the Atom threading count OPDS facets carry.
+	Count int `xml:"thr:count,attr,omitempty"`
 }
 
 type AtomAuthor struct {
@@ -27,6 +32,14 @@ type AtomContributor struct {
 	AtomPerson
 }
 
+// AtomCategory classifies an entry; books carry one per genre.
+type AtomCategory struct {
+	XMLName xml.Name `xml:"category"`
+	Term    string   `xml:"term,attr"`
+	Label   string   `xml:"label,attr,omitempty"`
+	Scheme  string   `xml:"scheme,attr,omitempty"`
+}
+
 type AtomSummary struct {
 	XMLName xml.Name `xml:"summary"`
 	Content string   `xml:",chardata"`
@@ -44,7 +57,7 @@ type AtomEntry struct {
 	Title       string   `xml:"title"`   // required
 	Updated     string   `xml:"updated"` // required
 	Id          string   `xml:"id"`      // required
-	Category    string   `xml:"category,omitempty"`
+	Categories  []AtomCategory
 	Content     *AtomContent
 	Language    string `xml:"dc:language,omitempty"`
 	Issued      string `xml:"dc:issued,omitempty"`
@@ -63,6 +76,7 @@ type AtomFeed struct {
 	XmlnsDc   string   `xml:"xmlns:dc,attr"`
 	XmlnsOs   string   `xml:"xmlns:os,attr"`
 	XmlnsOpds string   `xml:"xmlns:opds,attr,omitempty"`
+	XmlnsThr  string   `xml:"xmlns:thr,attr,omitempty"`
 	Title     string   `xml:"title"` // required
 	Id        string   `xml:"id"`    // required
 	Icon      string   `xml:"icon,omitempty"`
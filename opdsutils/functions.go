@@ -2,7 +2,6 @@ package opdsutils
 
 import (
 	"encoding/xml"
-	"fmt"
 	"time"
 )
 
@@ -29,15 +28,27 @@ func anyTimeFormat(format string, times ...time.Time) string {
 func (a *Atom) AtomFeed() *AtomFeed {
 	updated := anyTimeFormat(time.RFC3339, a.Updated)
 	links := []AtomLink{}
+	counted := false
 	for _, l := range a.Links {
-		links = append(links, AtomLink{
-			Href:  l.Href,
-			Rel:   l.Rel,
-			Type:  l.Type,
-			Title: l.Title,
-		})
+		link := AtomLink{
+			Href:       l.Href,
+			Rel:        l.Rel,
+			Type:       l.Type,
+			Title:      l.Title,
+			FacetGroup: l.FacetGroup,
+			Count:      l.Count,
+		}
+		if l.ActiveFacet {
+			link.ActiveFacet = "true"
+		}
+		counted = counted || l.Count > 0
+		links = append(links, link)
 	}
 
+	icon := a.Icon
+	if icon == "" {
+		icon = "/favicon.ico"
+	}
 	feed := &AtomFeed{
 		Xmlns:     "http://www.w3.org/2005/Atom",
 		XmlnsDc:   "http://purl.org/dc/terms/",
@@ -46,9 +57,12 @@ func (a *Atom) AtomFeed() *AtomFeed {
 		Title:     a.Title,
 		Id:        a.Id,
 		Links:     links,
-		Icon:      "/favicon.ico",
+		Icon:      icon,
 		Updated:   updated,
 	}
+	if counted {
+		feed.XmlnsThr = "http://purl.org/syndication/thread/1.0"
+	}
 	for _, e := range a.Items {
 		feed.Entries = append(feed.Entries, newAtomEntry(e))
 	}
@@ -76,7 +90,7 @@ func newAtomEntry(i *Item) *AtomEntry {
 		atomAuthors = append(atomAuthors, AtomAuthor{
 			AtomPerson: AtomPerson{
 				Name: a.Name,
-				Uri:  fmt.Sprintf("/opds/author/%d", a.ID),
+				Uri:  AuthorFeedHref(a.ID),
 			},
 		})
 	}
@@ -90,6 +104,15 @@ func newAtomEntry(i *Item) *AtomEntry {
 			Title: l.Title,
 		})
 	}
+	var categories []AtomCategory
+	for _, c := range i.Categories {
+		categories = append(categories, AtomCategory{
+			Term:   c.Term,
+			Label:  c.Label,
+			Scheme: GenreScheme,
+		})
+	}
+
 	x := &AtomEntry{
 		Title:    i.Title,
 		Links:    atomLinks,
@@ -99,6 +122,9 @@ func newAtomEntry(i *Item) *AtomEntry {
 		Language: i.Language,
 		Issued:   i.Issued,
 	}
+	if len(categories) > 0 {
+		x.Categories = categories
+	}
 
 	// if there's a content, assume it's html
 	if len(i.Content) > 0 {
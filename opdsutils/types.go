@@ -18,6 +18,7 @@ type XmlFeed interface {
 type Feed struct {
 	Title   string
 	Id      string
+	Icon    string // "/favicon.ico" when empty
 	Links   []Link
 	Updated time.Time
 	Items   []*Item
@@ -25,6 +26,13 @@ type Feed struct {
 
 type Link struct {
 	Href, Rel, Type, Length, Title string
+	// FacetGroup and ActiveFacet make a facet link: FacetGroup names the
+	// group it belongs to, ActiveFacet marks the facet in effect.
+	FacetGroup  string
+	ActiveFacet bool
+	// Count is how many entries a facet link leads to, or zero for a link
+	// that does not say.
+	Count int
 }
 
 type Author struct {
@@ -32,11 +40,17 @@ type Author struct {
 	ID   int64
 }
 
+// Category is a genre of a book: its code, and its name for the reader.
+type Category struct {
+	Term, Label string
+}
+
 type Item struct {
 	Title       string
 	Link        []Link
 	Source      *Link
 	Authors     []Author
+	Categories  []Category
 	Description string // used as description in rss, summary in atom
 	Id          string // used as guid in rss, id in atom
 	Updated     time.Time
@@ -0,0 +1,68 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"gopds-api/database"
+	"gopds-api/logging"
+	"gopds-api/models"
+)
+
+// auditRetentionInterval is how often the retention sweep runs.
+const auditRetentionInterval = 24 * time.Hour
+
+// AuditLog is the append-only record of administrative actions.
+type AuditLog struct{}
+
+// NewAuditLog returns the audit log.
+func NewAuditLog() *AuditLog {
+	return &AuditLog{}
+}
+
+// Record appends an entry.
+func (l *AuditLog) Record(ctx context.Context, entry *models.AuditEntry) error {
+	return database.InsertAuditEntry(ctx, entry)
+}
+
+// List returns one page of the entries the filters match, newest first.
+func (l *AuditLog) List(ctx context.Context, f models.AuditFilters) ([]models.AuditEntry, int, error) {
+	return database.ListAuditEntries(ctx, f)
+}
+
+// Export hands every entry the filters match to fn, oldest first.
+func (l *AuditLog) Export(ctx context.Context, f models.AuditFilters, fn func(*models.AuditEntry) error) error {
+	return database.ExportAuditEntries(ctx, f, fn)
+}
+
+// RunAuditRetention deletes audit entries older than retentionDays, once at
+// startup and then daily, until ctx is done. Zero or less keeps the log
+// forever.
+func RunAuditRetention(ctx context.Context, retentionDays int) {
+	if retentionDays <= 0 {
+		logging.Info("Audit log: kept forever, no retention configured")
+		return
+	}
+	ticker := time.NewTicker(auditRetentionInterval)
+	defer ticker.Stop()
+	for {
+		purgeAuditLog(ctx, retentionDays)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func purgeAuditLog(ctx context.Context, retentionDays int) {
+	cutoff := time.Now().AddDate(0, 0, -retentionDays)
+	n, err := database.PurgeAuditEntries(ctx, cutoff)
+	if err != nil {
+		logging.Errorf("Audit log: %v", err)
+		return
+	}
+	if n > 0 {
+		logging.Infof("Audit log: %d entries older than %d days deleted", n, retentionDays)
+	}
+}
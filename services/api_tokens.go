@@ -0,0 +1,126 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"gopds-api/database"
+	"gopds-api/logging"
+	"gopds-api/models"
+	"gopds-api/utils"
+)
+
+var (
+	// ErrAPITokenScope reports an admin scope asked for by a user who does
+	// not hold its permission.
+	ErrAPITokenScope = errors.New("scope exceeds the user's permissions")
+	// ErrAPITokenRejected reports a token that is unknown, expired, or
+	// whose user is no longer active.
+	ErrAPITokenRejected = errors.New("invalid api token")
+)
+
+// apiTokenRandomLength is the random part of a token: 40 characters of 62
+// are some 238 bits.
+const apiTokenRandomLength = 40
+
+// apiTokenShownPrefix is how much of a token is kept to tell it apart: the
+// fixed prefix and a few random characters.
+const apiTokenShownPrefix = len(models.APITokenPrefix) + 6
+
+// APITokenService issues, checks and revokes personal access tokens.
+type APITokenService struct {
+	now func() time.Time
+}
+
+// NewAPITokenService returns the personal access token service.
+func NewAPITokenService() *APITokenService {
+	return &APITokenService{now: time.Now}
+}
+
+// Create issues a token for a user. The token is in the answer and nowhere
+// else: only its hash is stored.
+func (s *APITokenService) Create(ctx context.Context, userID int64, req models.APITokenRequest) (*models.APITokenCreated, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+	user, err := database.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	perms, err := database.UserPermissions(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range req.AdminPermissions() {
+		if !models.HasPermission(user.IsSuperUser, perms, p) {
+			return nil, fmt.Errorf("%w: %s", ErrAPITokenScope, models.AdminScope(p))
+		}
+	}
+
+	raw := models.APITokenPrefix + utils.GetRandomString(apiTokenRandomLength)
+	token := models.APIToken{
+		UserID:    userID,
+		Name:      req.Name,
+		Prefix:    raw[:apiTokenShownPrefix],
+		TokenHash: hashAPIToken(raw),
+		Scopes:    req.Scopes,
+	}
+	if req.ExpiresInDays > 0 {
+		expires := s.now().AddDate(0, 0, req.ExpiresInDays)
+		token.ExpiresAt = &expires
+	}
+	if err := database.CreateAPIToken(ctx, &token); err != nil {
+		return nil, err
+	}
+	return &models.APITokenCreated{APIToken: token, Token: raw}, nil
+}
+
+// List returns a user's tokens, newest first.
+func (s *APITokenService) List(ctx context.Context, userID int64) ([]models.APIToken, error) {
+	return database.ListAPITokens(ctx, userID)
+}
+
+// Revoke deletes one of a user's tokens; it stops working at once.
+func (s *APITokenService) Revoke(ctx context.Context, userID, id int64) error {
+	return database.DeleteAPIToken(ctx, userID, id)
+}
+
+// Authenticate checks a token presented as a bearer credential and returns
+// it with its user, permissions loaded. Its use is recorded.
+func (s *APITokenService) Authenticate(ctx context.Context, raw string) (*models.APIToken, *models.User, error) {
+	token, err := database.APITokenByHash(ctx, hashAPIToken(raw))
+	if errors.Is(err, database.ErrAPITokenNotFound) {
+		return nil, nil, ErrAPITokenRejected
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if token.Expired(s.now()) {
+		return nil, nil, ErrAPITokenRejected
+	}
+	user, err := database.GetUserByID(ctx, token.UserID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !user.Active {
+		return nil, nil, ErrAPITokenRejected
+	}
+	if user.Permissions, err = database.UserPermissions(ctx, user.ID); err != nil {
+		return nil, nil, err
+	}
+	if err := database.TouchAPIToken(ctx, token.ID); err != nil {
+		logging.Warnf("Recording use of API token %d: %v", token.ID, err)
+	}
+	return token, &user, nil
+}
+
+// hashAPIToken hashes a token for storage and lookup. A bare hash is
+// enough: tokens are long and random, not chosen by people.
+func hashAPIToken(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
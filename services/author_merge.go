@@ -0,0 +1,153 @@
+package services
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"gopds-api/database"
+	"gopds-api/models"
+)
+
+// latinFolds even out the usual differences between the library's own
+// transliteration (models.Translit: й→j, х→h, ц→c, я→ja) and the spellings
+// English-language editions use (y, kh, ts, ya). Applied in order.
+var latinFolds = strings.NewReplacer(
+	"shch", "shh",
+	"kh", "h",
+	"ts", "c",
+	"j", "y",
+)
+
+// authorMergeKey reduces a name to what stays the same across its spellings:
+// transliterated to Latin, normalized, folded, with a final -iy read as -y,
+// and its words sorted, so "Толстой Лев", "Лев Толстой" and "Tolstoy, Lev"
+// share one key. Empty for the unknown author.
+func authorMergeKey(name string) string {
+	if duplicateAuthorKey(name) == "" {
+		return ""
+	}
+	latin := normalizeText(models.Translit(strings.ReplaceAll(strings.ToLower(name), "ё", "е")))
+	words := strings.Fields(latinFolds.Replace(latin))
+	for i, w := range words {
+		w = strings.ReplaceAll(w, "yy", "y")
+		if strings.HasSuffix(w, "iy") {
+			w = strings.TrimSuffix(w, "iy") + "y"
+		}
+		words[i] = w
+	}
+	sort.Strings(words)
+	return strings.Join(words, " ")
+}
+
+// AuthorMergeSuggestion is a set of authors whose names reduce to one key.
+// Authors come with the most books first: the likeliest merge target.
+type AuthorMergeSuggestion struct {
+	Key        string                     `json:"key"`
+	Authors    []database.AuthorWithCount `json:"authors"`
+	BooksCount int                        `json:"books_count"`
+}
+
+// groupAuthorsByMergeKey returns the sets of authors sharing a merge key, the
+// sets with the most books first.
+func groupAuthorsByMergeKey(authors []database.AuthorWithCount) []AuthorMergeSuggestion {
+	byKey := make(map[string][]database.AuthorWithCount)
+	for _, a := range authors {
+		if key := authorMergeKey(a.FullName); key != "" {
+			byKey[key] = append(byKey[key], a)
+		}
+	}
+	suggestions := make([]AuthorMergeSuggestion, 0)
+	for key, group := range byKey {
+		if len(group) < 2 {
+			continue
+		}
+		sort.Slice(group, func(i, j int) bool {
+			if group[i].BooksCount != group[j].BooksCount {
+				return group[i].BooksCount > group[j].BooksCount
+			}
+			return group[i].ID < group[j].ID
+		})
+		s := AuthorMergeSuggestion{Key: key, Authors: group}
+		for _, a := range group {
+			s.BooksCount += a.BooksCount
+		}
+		suggestions = append(suggestions, s)
+	}
+	sort.Slice(suggestions, func(i, j int) bool {
+		if suggestions[i].BooksCount != suggestions[j].BooksCount {
+			return suggestions[i].BooksCount > suggestions[j].BooksCount
+		}
+		return suggestions[i].Key < suggestions[j].Key
+	})
+	return suggestions
+}
+
+// AuthorsService implements the admin author operations on the package-level
+// database.
+type AuthorsService struct{}
+
+// NewAuthorsService returns the admin author service.
+func NewAuthorsService() *AuthorsService {
+	return &AuthorsService{}
+}
+
+func (AuthorsService) Aliases(ctx context.Context, authorID int64) ([]models.AuthorAlias, error) {
+	return database.ListAuthorAliases(ctx, authorID)
+}
+
+func (AuthorsService) AddAlias(ctx context.Context, authorID int64, alias, kind string) (*models.AuthorAlias, error) {
+	return database.AddAuthorAlias(ctx, authorID, alias, kind)
+}
+
+func (AuthorsService) DeleteAlias(ctx context.Context, aliasID int64) (*models.AuthorAlias, error) {
+	return database.DeleteAuthorAlias(ctx, aliasID)
+}
+
+func (AuthorsService) Merge(ctx context.Context, targetID int64, sourceIDs []int64, canonicalName string, mergedBy *int64) (*models.AuthorMerge, error) {
+	return database.MergeAuthors(ctx, targetID, sourceIDs, canonicalName, mergedBy)
+}
+
+func (AuthorsService) UndoMerge(ctx context.Context, mergeID int64, undoneBy *int64) (*models.AuthorMerge, error) {
+	return database.UndoAuthorMerge(ctx, mergeID, undoneBy)
+}
+
+func (AuthorsService) Merges(ctx context.Context, page, pageSize int) ([]models.AuthorMerge, int, error) {
+	return database.ListAuthorMerges(ctx, page, pageSize)
+}
+
+// MergeCandidates returns the authors that may be the same person as the one
+// given: trigram-similar to its name or to the name's transliteration, with
+// those sharing its merge key scored as certain.
+func (AuthorsService) MergeCandidates(ctx context.Context, authorID int64, limit int) ([]database.AuthorMergeCandidate, error) {
+	author, err := database.GetAuthor(models.AuthorRequest{ID: authorID})
+	if err != nil {
+		return nil, err
+	}
+	candidates, err := database.FindAuthorMergeCandidates(ctx, authorID, author.FullName, models.Translit(author.FullName), limit)
+	if err != nil {
+		return nil, err
+	}
+	key := authorMergeKey(author.FullName)
+	for i := range candidates {
+		if key != "" && authorMergeKey(candidates[i].FullName) == key {
+			candidates[i].Score = 1
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].Score > candidates[j].Score })
+	return candidates, nil
+}
+
+// MergeSuggestions returns up to limit sets of authors whose names are one
+// name spelled differently, across the whole library.
+func (AuthorsService) MergeSuggestions(ctx context.Context, limit int) ([]AuthorMergeSuggestion, error) {
+	authors, err := database.ListAuthorsWithCounts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	suggestions := groupAuthorsByMergeKey(authors)
+	if limit > 0 && len(suggestions) > limit {
+		suggestions = suggestions[:limit]
+	}
+	return suggestions, nil
+}
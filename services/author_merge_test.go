@@ -0,0 +1,50 @@
+package services
+
+import (
+	"testing"
+
+	"gopds-api/database"
+)
+
+func TestAuthorMergeKeyBridgesSpellings(t *testing.T) {
+	same := [][]string{
+		{"Толстой Лев", "Лев Толстой", "Tolstoy, Lev"},
+		{"Фёдор Достоевский", "Dostoevsky Fedor"},
+		{"Михаил Булгаков", "Mikhail Bulgakov"},
+		{"Марина Цветаева", "Marina Tsvetaeva"},
+	}
+	for _, names := range same {
+		for _, n := range names[1:] {
+			if authorMergeKey(n) != authorMergeKey(names[0]) {
+				t.Errorf("%q = %q, %q = %q", names[0], authorMergeKey(names[0]), n, authorMergeKey(n))
+			}
+		}
+	}
+	if authorMergeKey("Толстой Алексей") == authorMergeKey("Толстой Лев") {
+		t.Error("two Tolstoys share a key")
+	}
+	if authorMergeKey("Автор неизвестен") != "" {
+		t.Error("the unknown author has a key")
+	}
+}
+
+func TestGroupAuthorsByMergeKey(t *testing.T) {
+	groups := groupAuthorsByMergeKey([]database.AuthorWithCount{
+		{ID: 1, FullName: "Tolstoy Lev", BooksCount: 2},
+		{ID: 2, FullName: "Толстой Лев", BooksCount: 40},
+		{ID: 3, FullName: "Чехов Антон", BooksCount: 30},
+		{ID: 4, FullName: "Mikhail Bulgakov", BooksCount: 1},
+		{ID: 5, FullName: "Булгаков Михаил", BooksCount: 1},
+		{ID: 6, FullName: "Автор неизвестен", BooksCount: 9},
+		{ID: 7, FullName: "Неизвестен Автор", BooksCount: 9},
+	})
+	if len(groups) != 2 {
+		t.Fatalf("groups = %+v, want two", groups)
+	}
+	if groups[0].BooksCount != 42 || groups[0].Authors[0].ID != 2 {
+		t.Errorf("first group = %+v, want the Tolstoys led by the author with most books", groups[0])
+	}
+	if groups[1].Authors[0].ID != 4 {
+		t.Errorf("a tie in books went to %d, want the lower ID", groups[1].Authors[0].ID)
+	}
+}
@@ -0,0 +1,306 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"gopds-api/database"
+	"gopds-api/logging"
+	"gopds-api/models"
+)
+
+// MaxBulkEditBooks is the most books one bulk edit may select. A selection
+// past it is refused rather than cut short, so an edit never silently
+// covers only part of what the admin asked for.
+const MaxBulkEditBooks = 50000
+
+const (
+	// bulkEditProgressEvery is how many books pass between progress updates.
+	bulkEditProgressEvery = 50
+	// bulkEditMaxConsecutiveFailures stops an edit whose books keep failing:
+	// that is the database going away, not a few bad rows.
+	bulkEditMaxConsecutiveFailures = 20
+	// bulkEditPreviewSize is how many selected books a preview shows.
+	bulkEditPreviewSize = 20
+)
+
+var (
+	// ErrBulkSelectionInvalid reports a selection that names both explicit
+	// ids and filters, or neither.
+	ErrBulkSelectionInvalid = errors.New("select books either by ids or by filters")
+	// ErrBulkSelectionEmpty reports a selection that matches no book.
+	ErrBulkSelectionEmpty = errors.New("the selection matches no book")
+	// ErrBulkSelectionTooLarge reports a selection of more than
+	// MaxBulkEditBooks books.
+	ErrBulkSelectionTooLarge = fmt.Errorf("the selection matches more than %d books", MaxBulkEditBooks)
+)
+
+// BookBulkPreview is what a selection matches: how many books, and the
+// first few of them.
+type BookBulkPreview struct {
+	Total int           `json:"total"`
+	Books []models.Book `json:"books"`
+}
+
+// BookBulkEditService applies admin patches to many books in the
+// background, reporting progress to the admin WebSocket, and undoes them.
+type BookBulkEditService struct {
+	events *ScanEventPublisher
+
+	mu      sync.Mutex
+	cancels map[int64]context.CancelFunc
+}
+
+// NewBookBulkEditService returns the bulk edit service; events may be nil.
+func NewBookBulkEditService(events *ScanEventPublisher) *BookBulkEditService {
+	return &BookBulkEditService{
+		events:  events,
+		cancels: make(map[int64]context.CancelFunc),
+	}
+}
+
+// InterruptBookBulkEdits marks the edits the previous run of the server left
+// unfinished. It runs at startup, before any edit can be started.
+func InterruptBookBulkEdits(ctx context.Context) {
+	n, err := database.InterruptBookBulkEdits(ctx)
+	if err != nil {
+		logging.Errorf("Bulk edits: %v", err)
+		return
+	}
+	if n > 0 {
+		logging.Warnf("Bulk edits: %d left unfinished by the previous run marked interrupted", n)
+	}
+}
+
+// resolveSelection returns the ids of the selected books, each once, in
+// selection order.
+func resolveSelection(ctx context.Context, sel models.BookSelection) ([]int64, error) {
+	var ids []int64
+	switch {
+	case len(sel.BookIDs) > 0 && sel.Filters != nil, len(sel.BookIDs) == 0 && sel.Filters == nil:
+		return nil, ErrBulkSelectionInvalid
+	case len(sel.BookIDs) > 0:
+		seen := make(map[int64]bool, len(sel.BookIDs))
+		for _, id := range sel.BookIDs {
+			if id > 0 && !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+	default:
+		var err error
+		ids, err = database.SelectBookIDs(ctx, *sel.Filters, MaxBulkEditBooks+1)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(ids) == 0 {
+		return nil, ErrBulkSelectionEmpty
+	}
+	if len(ids) > MaxBulkEditBooks {
+		return nil, ErrBulkSelectionTooLarge
+	}
+	return ids, nil
+}
+
+// Preview returns what a selection matches, without changing anything.
+func (s *BookBulkEditService) Preview(ctx context.Context, sel models.BookSelection) (*BookBulkPreview, error) {
+	ids, err := resolveSelection(ctx, sel)
+	if err != nil {
+		return nil, err
+	}
+	sample := ids
+	if len(sample) > bulkEditPreviewSize {
+		sample = sample[:bulkEditPreviewSize]
+	}
+	books, err := database.GetBooksByIDs(sample)
+	if err != nil {
+		return nil, err
+	}
+	return &BookBulkPreview{Total: len(ids), Books: books}, nil
+}
+
+// Start validates the patch, resolves the selection and starts applying the
+// patch in the background. The returned edit is the one being applied; its
+// progress is read back through Get.
+func (s *BookBulkEditService) Start(ctx context.Context, sel models.BookSelection, patch models.BookBulkPatch, createdBy *int64) (*models.BookBulkEdit, error) {
+	if err := patch.Validate(); err != nil {
+		return nil, err
+	}
+	ids, err := resolveSelection(ctx, sel)
+	if err != nil {
+		return nil, err
+	}
+	edit := &models.BookBulkEdit{
+		Selection: sel,
+		Patch:     patch,
+		Total:     len(ids),
+		CreatedBy: createdBy,
+	}
+	if err := database.CreateBookBulkEdit(ctx, edit); err != nil {
+		return nil, err
+	}
+
+	runCtx, cancel := context.WithCancel(context.Background())
+	s.mu.Lock()
+	s.cancels[edit.ID] = cancel
+	s.mu.Unlock()
+
+	go func() {
+		defer func() {
+			s.mu.Lock()
+			delete(s.cancels, edit.ID)
+			s.mu.Unlock()
+			cancel()
+		}()
+		s.run(runCtx, edit, ids)
+	}()
+
+	logging.Infof("Bulk edit %d started: %d books", edit.ID, len(ids))
+	return edit, nil
+}
+
+// run applies the edit to every selected book, one transaction per book.
+func (s *BookBulkEditService) run(ctx context.Context, edit *models.BookBulkEdit, ids []int64) {
+	start := time.Now()
+	s.events.PublishBulkEditStarted(edit.ID, "apply", edit.Total)
+
+	status, errMsg := models.BulkEditCompleted, ""
+	consecutiveFailures := 0
+	for i, id := range ids {
+		if ctx.Err() != nil {
+			status = models.BulkEditCancelled
+			break
+		}
+		changed, err := database.ApplyBookBulkEdit(ctx, edit, id, i)
+		if err != nil && ctx.Err() != nil {
+			status = models.BulkEditCancelled
+			break
+		}
+		edit.Processed++
+		switch {
+		case err != nil:
+			edit.Failed++
+			consecutiveFailures++
+			logging.Warnf("Bulk edit %d: book %d: %v", edit.ID, id, err)
+			if consecutiveFailures >= bulkEditMaxConsecutiveFailures {
+				status, errMsg = models.BulkEditFailed, err.Error()
+			}
+		case changed:
+			edit.Changed++
+			consecutiveFailures = 0
+		default:
+			consecutiveFailures = 0
+		}
+		if status == models.BulkEditFailed {
+			break
+		}
+		if edit.Processed%bulkEditProgressEvery == 0 {
+			if err := database.UpdateBookBulkEditProgress(ctx, edit); err != nil {
+				logging.Warnf("Bulk edit %d: saving progress: %v", edit.ID, err)
+			}
+			s.events.PublishBulkEditProgress(edit.ID, "apply", edit.Total, edit.Processed, edit.Changed, edit.Failed)
+		}
+	}
+
+	// The run's own context may be cancelled by now; the outcome is written
+	// regardless.
+	if err := database.FinishBookBulkEdit(context.Background(), edit, status, errMsg); err != nil {
+		logging.Errorf("Bulk edit %d: saving outcome: %v", edit.ID, err)
+	}
+	logging.Infof("Bulk edit %d %s: %d of %d books processed, %d changed, %d failed",
+		edit.ID, status, edit.Processed, edit.Total, edit.Changed, edit.Failed)
+	s.events.PublishBulkEditCompleted(BulkEditCompletedEvent{
+		EditID:     edit.ID,
+		Operation:  "apply",
+		Status:     status,
+		Total:      edit.Total,
+		Processed:  edit.Processed,
+		Changed:    edit.Changed,
+		Failed:     edit.Failed,
+		Error:      errMsg,
+		DurationMS: time.Since(start).Milliseconds(),
+	})
+}
+
+// Cancel stops a running edit after the book in hand. The books changed so
+// far stay changed, and can be undone. It reports whether the edit was
+// running here.
+func (s *BookBulkEditService) Cancel(editID int64) bool {
+	s.mu.Lock()
+	cancel, ok := s.cancels[editID]
+	s.mu.Unlock()
+	if ok {
+		cancel()
+	}
+	return ok
+}
+
+// Undo starts putting back, in the background, every book the edit changed.
+// A book that fails is left as it is and the edit is marked interrupted, so
+// the undo can be run again.
+func (s *BookBulkEditService) Undo(ctx context.Context, editID int64, undoneBy *int64) (*models.BookBulkEdit, error) {
+	edit, records, err := database.StartBookBulkEditUndo(ctx, editID)
+	if err != nil {
+		return nil, err
+	}
+	go s.runUndo(edit, records, undoneBy)
+	logging.Infof("Bulk edit %d: undo started for %d books", edit.ID, len(records))
+	return edit, nil
+}
+
+func (s *BookBulkEditService) runUndo(edit *models.BookBulkEdit, records []models.BookBulkEditBook, undoneBy *int64) {
+	ctx := context.Background()
+	start := time.Now()
+	total := len(records)
+	s.events.PublishBulkEditStarted(edit.ID, "undo", total)
+
+	processed, failed := 0, 0
+	for i := range records {
+		if err := database.UndoBookBulkEditBook(ctx, edit, &records[i]); err != nil {
+			failed++
+			logging.Warnf("Bulk edit %d: undo of book %d: %v", edit.ID, records[i].BookID, err)
+		}
+		processed++
+		if processed%bulkEditProgressEvery == 0 {
+			s.events.PublishBulkEditProgress(edit.ID, "undo", total, processed, processed-failed, failed)
+		}
+	}
+
+	var err error
+	errMsg := ""
+	if failed > 0 {
+		errMsg = fmt.Sprintf("undo: %d of %d books could not be put back", failed, total)
+		err = database.FinishBookBulkEdit(ctx, edit, models.BulkEditInterrupted, errMsg)
+	} else {
+		err = database.FinishBookBulkEditUndo(ctx, edit, undoneBy)
+	}
+	if err != nil {
+		logging.Errorf("Bulk edit %d: saving undo outcome: %v", edit.ID, err)
+	}
+	logging.Infof("Bulk edit %d %s: %d books put back, %d failed", edit.ID, edit.Status, processed-failed, failed)
+	s.events.PublishBulkEditCompleted(BulkEditCompletedEvent{
+		EditID:     edit.ID,
+		Operation:  "undo",
+		Status:     edit.Status,
+		Total:      total,
+		Processed:  processed,
+		Changed:    processed - failed,
+		Failed:     failed,
+		Error:      errMsg,
+		DurationMS: time.Since(start).Milliseconds(),
+	})
+}
+
+// Get returns one bulk edit.
+func (s *BookBulkEditService) Get(ctx context.Context, editID int64) (*models.BookBulkEdit, error) {
+	return database.GetBookBulkEdit(ctx, editID)
+}
+
+// List returns one page of bulk edits, newest first.
+func (s *BookBulkEditService) List(ctx context.Context, page, pageSize int) ([]models.BookBulkEdit, int, error) {
+	return database.ListBookBulkEdits(ctx, page, pageSize)
+}
@@ -0,0 +1,59 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"gopds-api/models"
+)
+
+func TestResolveSelection_ExplicitIDs(t *testing.T) {
+	ids, err := resolveSelection(context.Background(), models.BookSelection{BookIDs: []int64{5, 3, 5, 0, 7, 3}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []int64{5, 3, 7}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("ids = %v, want %v", ids, want)
+	}
+}
+
+func TestResolveSelection_Rejects(t *testing.T) {
+	tooMany := make([]int64, MaxBulkEditBooks+1)
+	for i := range tooMany {
+		tooMany[i] = int64(i + 1)
+	}
+	tests := []struct {
+		name string
+		sel  models.BookSelection
+		want error
+	}{
+		{"neither", models.BookSelection{}, ErrBulkSelectionInvalid},
+		{"both", models.BookSelection{BookIDs: []int64{1}, Filters: &models.BookFilters{Lang: "ru"}}, ErrBulkSelectionInvalid},
+		{"no valid id", models.BookSelection{BookIDs: []int64{0, -2}}, ErrBulkSelectionEmpty},
+		{"too many", models.BookSelection{BookIDs: tooMany}, ErrBulkSelectionTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := resolveSelection(context.Background(), tt.sel); !errors.Is(err, tt.want) {
+				t.Fatalf("err = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+// A patch that fails validation never reaches the database.
+func TestBookBulkEditService_StartRejectsEmptyPatch(t *testing.T) {
+	s := NewBookBulkEditService(nil)
+	_, err := s.Start(context.Background(), models.BookSelection{BookIDs: []int64{1}}, models.BookBulkPatch{}, nil)
+	if !errors.Is(err, models.ErrBulkPatchEmpty) {
+		t.Fatalf("err = %v, want %v", err, models.ErrBulkPatchEmpty)
+	}
+}
+
+func TestBookBulkEditService_CancelUnknown(t *testing.T) {
+	if NewBookBulkEditService(nil).Cancel(42) {
+		t.Error("Cancel reported a running edit that was never started")
+	}
+}
@@ -0,0 +1,169 @@
+package services
+
+// book_file.go turns a book into the bytes a download sends, in any of the
+// formats a reader can ask for, together with the validators that let a
+// client revalidate or resume it.
+//
+// Every rendition is identified without being produced. The FB2, or the EPUB
+// of a book stored as one, is the very file the MD5 was computed over; the
+// zip wraps that file and nothing else; a conversion is a function of the
+// MD5 and the converter version. So the ETag
+// can be answered from the database row alone, and a reader whose copy is
+// current costs neither an archive read nor a conversion.
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"gopds-api/database"
+	"gopds-api/internal/safeio"
+	"gopds-api/logging"
+	"gopds-api/models"
+	"gopds-api/utils"
+)
+
+// ErrUnknownBookFormat reports a format no download is served in.
+var ErrUnknownBookFormat = errors.New("unknown book format")
+
+// ErrBookFileMissing reports a book whose archive is not on disk.
+var ErrBookFileMissing = errors.New("book file not found")
+
+// ErrBookFormatUnavailable reports a format a book cannot be had in: the
+// converters read FB2, so a book stored as EPUB is served only as it is.
+var ErrBookFormatUnavailable = errors.New("book is not available in this format")
+
+// formatEPUB is the format of a book stored as an EPUB, an uploaded one.
+const formatEPUB = "epub"
+
+// BookFormatAvailable reports whether book can be downloaded in format:
+// an FB2 in any format it converts to, an EPUB only as itself.
+func BookFormatAvailable(book *models.Book, format string) bool {
+	if book.Format == formatEPUB {
+		return format == formatEPUB
+	}
+	if format == "fb2" || format == "zip" {
+		return true
+	}
+	_, ok := conversionFormats[format]
+	return ok
+}
+
+// BookFile is one rendition of a book, opened for reading. The caller closes
+// Content.
+type BookFile struct {
+	Content io.ReadSeekCloser
+	Size    int64
+	// ModTime is the Last-Modified of the download, the archive's time as
+	// BookFileModTime gives it; OpenBookFile sets it for every rendition.
+	ModTime time.Time
+	// ETag is a strong validator: the bytes are a function of the fields it
+	// is made of. Empty when the book has no usable MD5.
+	ETag string
+}
+
+// BookFileETag is the strong validator of book in format, or "" when the
+// book has no MD5 to derive one from or the format is unknown.
+func BookFileETag(book *models.Book, format string) string {
+	md5 := strings.ToLower(book.MD5)
+	if !md5Pattern.MatchString(md5) {
+		return ""
+	}
+	switch {
+	case !BookFormatAvailable(book, format):
+		return ""
+	case format == "fb2", format == "zip", book.Format == formatEPUB:
+		return fmt.Sprintf(`"%s-%s"`, md5, format)
+	default:
+		return ConversionETag(md5, format)
+	}
+}
+
+// BookFileModTime is the Last-Modified of every rendition of book: the
+// modification time of the archive it is read from. Replacing the archive
+// moves it; a new converter version does not, but that changes the ETag,
+// which takes precedence over If-Modified-Since wherever both are sent.
+func BookFileModTime(book *models.Book, filesPath string) (time.Time, error) {
+	info, err := os.Stat(filesPath + book.Path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return time.Time{}, ErrBookFileMissing
+		}
+		return time.Time{}, err
+	}
+	return info.ModTime(), nil
+}
+
+// OpenBookFile returns book in format, read from its archive under
+// filesPath. EPUB and MOBI go through cache (which may be nil); FB2 and zip,
+// and the EPUB of a book stored as one, are read from the archive, whose
+// entries cannot be seeked, so they are held in memory — at most
+// safeio.MaxBookBytes, as everywhere a book is read.
+func OpenBookFile(cache *ConversionCache, book *models.Book, filesPath, format string) (*BookFile, error) {
+	if _, ok := conversionFormats[format]; !ok && format != "fb2" && format != "zip" {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownBookFormat, format)
+	}
+	if !BookFormatAvailable(book, format) {
+		return nil, fmt.Errorf("%w: %q of a book stored as %s", ErrBookFormatUnavailable, format, book.Format)
+	}
+	modTime, err := BookFileModTime(book, filesPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var file *BookFile
+	switch {
+	case format == "fb2", format == "zip", book.Format == formatEPUB:
+		file, err = openRawBookFile(book, filesPath+book.Path, format)
+	default:
+		file, err = cache.ConvertBook(book, filesPath, format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	file.ModTime = modTime
+	return file, nil
+}
+
+// openRawBookFile reads the book's file out of the archive at zipPath,
+// zipped again on its own when format asks for it.
+func openRawBookFile(book *models.Book, zipPath, format string) (*BookFile, error) {
+	bp := utils.NewBookProcessor(book.FileName, zipPath)
+	var (
+		rc  io.ReadCloser
+		err error
+	)
+	if format == "zip" {
+		rc, err = bp.Zip(book.FileName)
+	} else {
+		rc, err = bp.Raw()
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	data, err := safeio.ReadAll(rc, safeio.MaxBookBytes)
+	if err != nil {
+		return nil, err
+	}
+	return &BookFile{
+		Content: nopSeekCloser{bytes.NewReader(data)},
+		Size:    int64(len(data)),
+		ETag:    BookFileETag(book, format),
+	}, nil
+}
+
+// RecordDownload counts a download of a book towards its popularity and
+// the downloading user's history, which their recommendations start from. A
+// failure is logged rather than returned: the reader has the book either way.
+func RecordDownload(ctx context.Context, userID, bookID int64) {
+	if err := database.RecordBookDownload(ctx, userID, bookID); err != nil {
+		logging.Warnf("Recording download of book %d: %v", bookID, err)
+	}
+}
@@ -0,0 +1,141 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gopds-api/models"
+)
+
+// openTestBook lays out an archive holding one FB2 under a files root and
+// returns the root and a book pointing into it.
+func openTestBook(t *testing.T, fb2 string) (string, *models.Book) {
+	t.Helper()
+	root := t.TempDir() + "/"
+	writeZipArchive(t, filepath.Join(root, "lib", "a.zip"), map[string]string{"1.fb2": fb2})
+	return root, &models.Book{Title: "Book", Path: "lib/a.zip", FileName: "1.fb2", MD5: md5A}
+}
+
+func TestOpenBookFileServesTheStoredFB2(t *testing.T) {
+	root, book := openTestBook(t, "<FictionBook/>")
+	archived := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	if err := os.Chtimes(filepath.Join(root, book.Path), archived, archived); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := OpenBookFile(nil, book, root, "fb2")
+	if err != nil {
+		t.Fatalf("OpenBookFile: %v", err)
+	}
+	if got := readConverted(t, file); got != "<FictionBook/>" {
+		t.Errorf("served %q", got)
+	}
+	if file.Size != int64(len("<FictionBook/>")) {
+		t.Errorf("Size = %d", file.Size)
+	}
+	if file.ETag != BookFileETag(book, "fb2") || file.ETag == "" {
+		t.Errorf("ETag = %q, want %q", file.ETag, BookFileETag(book, "fb2"))
+	}
+	// Last-Modified must not move between two downloads of the same file,
+	// or If-Modified-Since could never match.
+	if !file.ModTime.Equal(archived) {
+		t.Errorf("ModTime = %v, want the archive's %v", file.ModTime, archived)
+	}
+}
+
+func TestOpenBookFileZipsTheFB2(t *testing.T) {
+	root, book := openTestBook(t, "<FictionBook/>")
+
+	file, err := OpenBookFile(nil, book, root, "zip")
+	if err != nil {
+		t.Fatalf("OpenBookFile: %v", err)
+	}
+	data := []byte(readConverted(t, file))
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("not a zip: %v", err)
+	}
+	if len(zr.File) != 1 || zr.File[0].Name != "1.fb2.fb2" {
+		t.Fatalf("zip holds %v", zr.File)
+	}
+	rc, err := zr.File[0].Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	if body, _ := io.ReadAll(rc); string(body) != "<FictionBook/>" {
+		t.Errorf("zipped %q", body)
+	}
+}
+
+// The validator of each rendition is its own, and is known from the row
+// alone; a book without a fingerprint gets none rather than a guess.
+func TestBookFileETag(t *testing.T) {
+	book := &models.Book{MD5: md5A}
+	seen := map[string]string{}
+	for _, format := range []string{"fb2", "zip", "epub", "mobi"} {
+		etag := BookFileETag(book, format)
+		if etag == "" {
+			t.Errorf("%s has no ETag", format)
+		}
+		if other, ok := seen[etag]; ok {
+			t.Errorf("%s and %s share ETag %s", format, other, etag)
+		}
+		seen[etag] = format
+	}
+	if got := BookFileETag(book, "epub"); got != ConversionETag(md5A, "epub") {
+		t.Errorf("epub ETag %q differs from the cache's %q", got, ConversionETag(md5A, "epub"))
+	}
+	if got := BookFileETag(&models.Book{}, "fb2"); got != "" {
+		t.Errorf("a book without MD5 got ETag %q", got)
+	}
+	if got := BookFileETag(book, "pdf"); got != "" {
+		t.Errorf("an unknown format got ETag %q", got)
+	}
+}
+
+func TestOpenBookFileReportsMissingArchives(t *testing.T) {
+	book := &models.Book{Path: "nowhere.zip", FileName: "1.fb2", MD5: md5A}
+	if _, err := OpenBookFile(nil, book, t.TempDir()+"/", "fb2"); !errors.Is(err, ErrBookFileMissing) {
+		t.Errorf("err = %v, want ErrBookFileMissing", err)
+	}
+}
+
+func TestOpenBookFileRefusesUnknownFormats(t *testing.T) {
+	root, book := openTestBook(t, "<FictionBook/>")
+	if _, err := OpenBookFile(nil, book, root, "pdf"); !errors.Is(err, ErrUnknownBookFormat) {
+		t.Errorf("err = %v, want ErrUnknownBookFormat", err)
+	}
+}
+
+// An EPUB the library keeps is sent as it is stored; the converters read
+// FB2, so it is had in no other format.
+func TestOpenBookFileServesAStoredEPUBAsItIs(t *testing.T) {
+	root, book := openTestBook(t, "PK-epub")
+	book.Format = formatEPUB
+
+	file, err := OpenBookFile(nil, book, root, "epub")
+	if err != nil {
+		t.Fatalf("OpenBookFile: %v", err)
+	}
+	if got := readConverted(t, file); got != "PK-epub" {
+		t.Errorf("served %q", got)
+	}
+	if file.ETag == "" || file.ETag == ConversionETag(md5A, "epub") {
+		t.Errorf("ETag = %q, want the stored file's", file.ETag)
+	}
+	for _, format := range []string{"fb2", "zip", "mobi"} {
+		if _, err := OpenBookFile(nil, book, root, format); !errors.Is(err, ErrBookFormatUnavailable) {
+			t.Errorf("%s: err = %v, want ErrBookFormatUnavailable", format, err)
+		}
+		if etag := BookFileETag(book, format); etag != "" {
+			t.Errorf("%s: ETag %q for a format the book is not had in", format, etag)
+		}
+	}
+}
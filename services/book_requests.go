@@ -0,0 +1,283 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"gopds-api/database"
+	"gopds-api/email"
+	"gopds-api/logging"
+	"gopds-api/models"
+
+	"github.com/go-pg/pg/v10"
+)
+
+// Book request list paging, as for reviews.
+const (
+	defaultBookRequestPageSize = 20
+	maxBookRequestPageSize     = 100
+)
+
+// BookRequestFulfilledEvent is the WebSocket message type sent to a reader
+// whose request a new book fulfilled.
+const BookRequestFulfilledEvent = "book_request_fulfilled"
+
+// BookRequestNotifier tells one reader that a book they asked for has
+// arrived. A reader it cannot reach, having no address for them, is not
+// an error.
+type BookRequestNotifier interface {
+	BookRequestFulfilled(ctx context.Context, user models.User, fulfilled models.FulfilledBookRequest) error
+}
+
+// BookRequestService keeps the readers' book requests and fulfils them
+// from what the scans bring in.
+type BookRequestService struct {
+	matcher   Matcher
+	visible   func(ctx context.Context, bookID int64) (bool, error)
+	notifiers []BookRequestNotifier
+}
+
+// NewBookRequestService returns the book request service, matching with
+// the curated collection matcher over the books readers can see and
+// telling readers through the given notifiers. Nil notifiers are left out.
+func NewBookRequestService(notifiers ...BookRequestNotifier) *BookRequestService {
+	dl := MatchDecisionLookup{}
+	cf := VisibleCandidateFinder{}
+	s := &BookRequestService{
+		matcher: MatcherFunc(func(ctx context.Context, author, title string) (MatchResult, error) {
+			return MatchOne(ctx, dl, cf, author, title)
+		}),
+		visible: bookVisible,
+	}
+	for _, n := range notifiers {
+		if n != nil {
+			s.notifiers = append(s.notifiers, n)
+		}
+	}
+	return s
+}
+
+// Submit stores a user's request, or their vote for the open request that
+// asks for the same book; created reports which. A book the library
+// already has is refused with a *models.BookInLibraryError naming it.
+func (s *BookRequestService) Submit(
+	ctx context.Context, userID int64, sub models.BookRequestSubmission,
+) (request *models.BookRequestView, created bool, err error) {
+	if err := sub.Validate(); err != nil {
+		return nil, false, err
+	}
+	authorNorm, titleNorm := normalizePair(sub.Author, sub.Title)
+	if titleNorm == "" {
+		return nil, false, fmt.Errorf("%w: title has no letters or digits", models.ErrBookRequestInvalid)
+	}
+	result, err := s.matcher.MatchOne(ctx, sub.Author, sub.Title)
+	if err != nil {
+		return nil, false, err
+	}
+	bookID, ok, err := s.matchedBook(ctx, result)
+	if err != nil {
+		return nil, false, err
+	}
+	if ok {
+		return nil, false, &models.BookInLibraryError{BookID: bookID}
+	}
+	id, created, err := database.SubmitBookRequest(ctx, userID, &models.BookRequest{
+		Title:      sub.Title,
+		Author:     sub.Author,
+		ISBN:       sub.ISBN,
+		TitleNorm:  titleNorm,
+		AuthorNorm: authorNorm,
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	request, err = database.BookRequestByID(ctx, id, userID)
+	if err != nil {
+		return nil, false, err
+	}
+	return request, created, nil
+}
+
+// List returns a page of requests in a state and how many there are.
+func (s *BookRequestService) List(
+	ctx context.Context, filter models.BookRequestFilter, page, pageSize int,
+) ([]models.BookRequestView, int, error) {
+	if !models.IsBookRequestStatus(filter.Status) {
+		return nil, 0, fmt.Errorf("%w: unknown status %q", models.ErrBookRequestInvalid, filter.Status)
+	}
+	limit, offset := bookRequestPage(page, pageSize)
+	return database.ListBookRequests(ctx, filter, limit, offset)
+}
+
+// Vote adds a user's vote to an open request.
+func (s *BookRequestService) Vote(ctx context.Context, id, userID int64) error {
+	return database.VoteBookRequest(ctx, id, userID)
+}
+
+// Unvote takes back a user's vote.
+func (s *BookRequestService) Unvote(ctx context.Context, id, userID int64) error {
+	return database.UnvoteBookRequest(ctx, id, userID)
+}
+
+// Dismiss closes an open request no book is coming for, returning it
+// before and after.
+func (s *BookRequestService) Dismiss(ctx context.Context, id int64) (before, after *models.BookRequest, err error) {
+	return database.DismissBookRequest(ctx, id)
+}
+
+// FulfilOpen runs every open request through the matcher and fulfils the
+// ones it settles on a book, telling their voters. Ambiguous matches are
+// left open: a wrong book announced is worse than a right one announced
+// late. It returns how many requests were fulfilled.
+func (s *BookRequestService) FulfilOpen(ctx context.Context) (int, error) {
+	requests, err := database.OpenBookRequests(ctx)
+	if err != nil {
+		return 0, err
+	}
+	fulfilled := 0
+	for _, request := range requests {
+		if err := ctx.Err(); err != nil {
+			return fulfilled, err
+		}
+		result, err := s.matcher.MatchOne(ctx, request.Author, request.Title)
+		if err != nil {
+			logging.Warnf("Failed to match book request %d: %v", request.ID, err)
+			continue
+		}
+		bookID, ok, err := s.matchedBook(ctx, result)
+		if err != nil {
+			logging.Warnf("Failed to check the match of book request %d: %v", request.ID, err)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		done, err := database.FulfilBookRequest(ctx, request.ID, bookID)
+		if errors.Is(err, database.ErrBookRequestClosed) {
+			continue
+		}
+		if err != nil {
+			logging.Warnf("Failed to fulfil book request %d with book %d: %v", request.ID, bookID, err)
+			continue
+		}
+		fulfilled++
+		s.notify(ctx, *done)
+	}
+	if fulfilled > 0 {
+		logging.Infof("Fulfilled %d of %d open book requests", fulfilled, len(requests))
+	}
+	return fulfilled, nil
+}
+
+// notify tells every voter of a fulfilled request through every notifier.
+// A reader one notifier failed to reach may still be reached by another,
+// so failures are logged and passed over.
+func (s *BookRequestService) notify(ctx context.Context, fulfilled models.FulfilledBookRequest) {
+	for _, userID := range fulfilled.VoterIDs {
+		user, err := database.GetUserByID(ctx, userID)
+		if err != nil {
+			logging.Warnf("Failed to load user %d to tell about book request %d: %v", userID, fulfilled.RequestID, err)
+			continue
+		}
+		for _, n := range s.notifiers {
+			if err := n.BookRequestFulfilled(ctx, user, fulfilled); err != nil {
+				logging.Warnf("Failed to tell user %d about book request %d: %v", userID, fulfilled.RequestID, err)
+			}
+		}
+	}
+}
+
+// matchedBook is the book a match settled on: one the moderators decided
+// on before, or the single close candidate, as long as readers can see it.
+// The candidates are visible ones already, but a moderator's decision may
+// name a book since withdrawn or hidden as a duplicate.
+func (s *BookRequestService) matchedBook(ctx context.Context, result MatchResult) (int64, bool, error) {
+	switch result.Status {
+	case models.MatchStatusManual, models.MatchStatusAutoMatched:
+		if result.BookID == nil {
+			return 0, false, nil
+		}
+		visible, err := s.visible(ctx, *result.BookID)
+		if err != nil || !visible {
+			return 0, false, err
+		}
+		return *result.BookID, true, nil
+	}
+	return 0, false, nil
+}
+
+// bookVisible reports whether readers can see a book: it is approved and
+// not hidden as a duplicate. A book that is gone is not visible.
+func bookVisible(ctx context.Context, bookID int64) (bool, error) {
+	book, err := database.GetBook(bookID)
+	if errors.Is(err, pg.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return book.Approved && !book.DuplicateHidden, nil
+}
+
+// bookRequestPage turns a page number, from one, and a page size into a
+// limit and an offset.
+func bookRequestPage(page, pageSize int) (limit, offset int) {
+	limit = clampLimit(pageSize, defaultBookRequestPageSize, maxBookRequestPageSize)
+	if page < 1 {
+		page = 1
+	}
+	return limit, (page - 1) * limit
+}
+
+// WebSocketBookRequestNotifier tells readers on their open WebSocket
+// connections.
+type WebSocketBookRequestNotifier struct {
+	manager *WebSocketManager
+}
+
+// NewWebSocketBookRequestNotifier returns a notifier sending through the
+// given manager.
+func NewWebSocketBookRequestNotifier(manager *WebSocketManager) *WebSocketBookRequestNotifier {
+	return &WebSocketBookRequestNotifier{manager: manager}
+}
+
+// BookRequestFulfilled implements BookRequestNotifier.
+func (n *WebSocketBookRequestNotifier) BookRequestFulfilled(
+	ctx context.Context, user models.User, fulfilled models.FulfilledBookRequest,
+) error {
+	return n.manager.SendToUser(user.ID, BookRequestFulfilledEvent, fulfilled)
+}
+
+// EmailBookRequestNotifier tells readers by e-mail, with a link to a
+// search for the book.
+type EmailBookRequestNotifier struct {
+	projectURL string
+}
+
+// NewEmailBookRequestNotifier returns a notifier whose links start at the
+// given project URL.
+func NewEmailBookRequestNotifier(projectURL string) *EmailBookRequestNotifier {
+	return &EmailBookRequestNotifier{projectURL: strings.TrimSuffix(projectURL, "/")}
+}
+
+// BookRequestFulfilled implements BookRequestNotifier. Inactive accounts
+// and accounts without an address are passed over.
+func (n *EmailBookRequestNotifier) BookRequestFulfilled(
+	ctx context.Context, user models.User, fulfilled models.FulfilledBookRequest,
+) error {
+	if !user.Active || user.Email == "" {
+		return nil
+	}
+	return email.SendBookRequestFulfilledEmail(email.SendType{
+		Email: user.Email,
+		URL:   n.bookURL(fulfilled.BookTitle),
+	}, fulfilled.BookTitle)
+}
+
+// bookURL is the address of the title search that finds the book.
+func (n *EmailBookRequestNotifier) bookURL(title string) string {
+	return fmt.Sprintf("%s/books/find/title/%s/1", n.projectURL, url.PathEscape(title))
+}
@@ -0,0 +1,80 @@
+package services
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"gopds-api/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// matcherReturning is a Matcher that settles every pair the same way.
+func matcherReturning(result MatchResult) Matcher {
+	return MatcherFunc(func(ctx context.Context, author, title string) (MatchResult, error) {
+		return result, nil
+	})
+}
+
+// visibleBooks is a visibility check that sees only the given books.
+func visibleBooks(ids ...int64) func(ctx context.Context, bookID int64) (bool, error) {
+	return func(ctx context.Context, bookID int64) (bool, error) {
+		return slices.Contains(ids, bookID), nil
+	}
+}
+
+func TestMatchedBook(t *testing.T) {
+	bookID, hiddenID := int64(42), int64(43)
+	svc := &BookRequestService{visible: visibleBooks(bookID)}
+	for _, tc := range []struct {
+		name   string
+		result MatchResult
+		want   bool
+	}{
+		{"a moderator's earlier decision", MatchResult{Status: models.MatchStatusManual, BookID: &bookID}, true},
+		{"the one close candidate", MatchResult{Status: models.MatchStatusAutoMatched, BookID: &bookID}, true},
+		{"a decision for a book readers cannot see", MatchResult{Status: models.MatchStatusManual, BookID: &hiddenID}, false},
+		{"a candidate readers cannot see", MatchResult{Status: models.MatchStatusAutoMatched, BookID: &hiddenID}, false},
+		{"several candidates are not a match", MatchResult{Status: models.MatchStatusAmbiguous}, false},
+		{"nothing found", MatchResult{Status: models.MatchStatusNotFound}, false},
+	} {
+		got, ok, err := svc.matchedBook(context.Background(), tc.result)
+		require.NoError(t, err, tc.name)
+		assert.Equal(t, tc.want, ok, tc.name)
+		if ok {
+			assert.Equal(t, bookID, got, tc.name)
+		}
+	}
+}
+
+// The refusals are decided before the database is asked anything.
+func TestBookRequestServiceRefusals(t *testing.T) {
+	bookID := int64(42)
+	svc := NewBookRequestService(nil, nil)
+	svc.matcher = matcherReturning(MatchResult{Status: models.MatchStatusAutoMatched, BookID: &bookID})
+	svc.visible = visibleBooks(bookID)
+	ctx := context.Background()
+
+	assert.Empty(t, svc.notifiers, "nil notifiers are left out")
+
+	_, _, err := svc.Submit(ctx, 1, models.BookRequestSubmission{Title: "Roadside Picnic"})
+	var inLibrary *models.BookInLibraryError
+	require.ErrorAs(t, err, &inLibrary, "a book the library has is not requested")
+	assert.Equal(t, bookID, inLibrary.BookID)
+
+	_, _, err = svc.Submit(ctx, 1, models.BookRequestSubmission{Title: "?!"})
+	assert.ErrorIs(t, err, models.ErrBookRequestInvalid, "a title of punctuation matches nothing")
+
+	_, _, err = svc.List(ctx, models.BookRequestFilter{Status: "pending"}, 1, 20)
+	assert.ErrorIs(t, err, models.ErrBookRequestInvalid)
+}
+
+func TestEmailBookRequestNotifier_LinksToTheTitleSearch(t *testing.T) {
+	n := NewEmailBookRequestNotifier("https://books.example/")
+	assert.Equal(t, "https://books.example/books/find/title/%D0%9F%D0%B8%D0%BA%D0%BD%D0%B8%D0%BA%2F2/1", n.bookURL("Пикник/2"))
+
+	err := n.BookRequestFulfilled(context.Background(), models.User{Active: true}, models.FulfilledBookRequest{})
+	assert.NoError(t, err, "a reader without an address is passed over")
+}
@@ -2,13 +2,9 @@ package services
 
 import (
 	"archive/zip"
-	"bytes"
-	// #nosec G501 -- MD5 identifies identical files during a scan. It is a
-	// fingerprint, not a security control.
-	"crypto/md5"
-	"encoding/hex"
+	"context"
+	"errors"
 	"fmt"
-	"io"
 	"os"
 	"path/filepath"
 	"strings"
@@ -16,6 +12,7 @@ import (
 	"time"
 
 	"gopds-api/database"
+	"gopds-api/internal/inpx"
 	"gopds-api/internal/parser"
 	"gopds-api/internal/posters"
 	"gopds-api/internal/safeio"
@@ -34,11 +31,15 @@ type BookScanService struct {
 	llmService       *llm.LLMService
 	skipDuplicates   bool
 	publisher        *ScanEventPublisher
-
-	// Progress tracking
-	progressMu          sync.Mutex
-	currentBookIndex    int
-	totalBooksInArchive int
+	index            *inpx.Index
+	workers          int
+	batchSize        int
+
+	// Progress tracking, across every archive the service has scanned, so
+	// that it stays right while several are scanned at once.
+	progressMu sync.Mutex
+	filesDone  int
+	filesTotal int
 }
 
 // ScanReport contains results of a scan operation
@@ -76,6 +77,20 @@ func NewBookScanService(archivesDir, coversDir string, languageDetector *Languag
 		languageDetector: languageDetector,
 		llmService:       llmSvc,
 		skipDuplicates:   skipDuplicates,
+		workers:          defaultScanWorkers,
+		batchSize:        defaultScanBatchSize,
+	}
+}
+
+// SetConcurrency sets how many files of an archive are decompressed and
+// parsed at once, and how many books go into the database per transaction.
+// Values below 1 leave the current setting.
+func (s *BookScanService) SetConcurrency(maxConcurrentFiles, batchSize int) {
+	if maxConcurrentFiles > 0 {
+		s.workers = maxConcurrentFiles
+	}
+	if batchSize > 0 {
+		s.batchSize = batchSize
 	}
 }
 
@@ -84,12 +99,23 @@ func (s *BookScanService) SetScanEventPublisher(publisher *ScanEventPublisher) {
 	s.publisher = publisher
 }
 
-// GetScanProgress returns the current progress of the archive scan.
-// Returns (currentBookIndex, totalBooksInArchive).
+// SetInpxIndex makes the scanner take the books of the archives the index
+// describes from it instead of parsing every FB2. The caller keeps ownership
+// of the index and closes it after the scan.
+func (s *BookScanService) SetInpxIndex(index *inpx.Index) {
+	s.index = index
+}
+
+// errBookSkipped reports a file that needed no work: already in the catalog,
+// or withdrawn by the library its index came from.
+var errBookSkipped = errors.New("skipped")
+
+// GetScanProgress returns how many FB2 files the service has finished with
+// and how many it has found, over all the archives it has scanned so far.
 func (s *BookScanService) GetScanProgress() (processed int, total int) {
 	s.progressMu.Lock()
 	defer s.progressMu.Unlock()
-	return s.currentBookIndex, s.totalBooksInArchive
+	return s.filesDone, s.filesTotal
 }
 
 // PublishProgress sends progress update via WebSocket if publisher is available.
@@ -99,9 +125,9 @@ func (s *BookScanService) PublishProgress(currentArchive string, archivesProcess
 	}
 }
 
-// GetUnscannedArchives returns list of unscanned archive file paths
-func (s *BookScanService) GetUnscannedArchives() ([]string, error) {
-	// 1. Get list of all ZIP files in archives directory
+// listArchiveFiles returns the paths of all ZIP files under the archives
+// directory.
+func (s *BookScanService) listArchiveFiles() ([]string, error) {
 	var archiveFiles []string
 	err := filepath.Walk(s.archivesDir, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
@@ -118,6 +144,16 @@ func (s *BookScanService) GetUnscannedArchives() ([]string, error) {
 	}
 
 	logging.Infof("Found %d ZIP archives in %s", len(archiveFiles), s.archivesDir)
+	return archiveFiles, nil
+}
+
+// GetUnscannedArchives returns list of unscanned archive file paths
+func (s *BookScanService) GetUnscannedArchives() ([]string, error) {
+	// 1. Get list of all ZIP files in archives directory
+	archiveFiles, err := s.listArchiveFiles()
+	if err != nil {
+		return nil, err
+	}
 
 	// 2. Get scanned catalogs from database
 	scannedCatalogs, err := database.GetScannedCatalogNames()
@@ -151,6 +187,59 @@ func (s *BookScanService) GetUnscannedArchives() ([]string, error) {
 	return unscannedArchives, nil
 }
 
+// GetIndexedArchivesToImport returns the paths of the archives on disk whose
+// books the INPX index has not been imported from yet: never scanned, or
+// scanned from an .inp that a newer index has changed. Archives the index
+// describes but the library does not hold are logged and left out.
+func (s *BookScanService) GetIndexedArchivesToImport() ([]string, error) {
+	if s.index == nil {
+		return nil, errors.New("no INPX index")
+	}
+	archiveFiles, err := s.listArchiveFiles()
+	if err != nil {
+		return nil, err
+	}
+	onDisk := make(map[string]string, len(archiveFiles))
+	for _, archivePath := range archiveFiles {
+		if _, seen := onDisk[filepath.Base(archivePath)]; !seen {
+			onDisk[filepath.Base(archivePath)] = archivePath
+		}
+	}
+	catalogs, err := database.GetAllCatalogs()
+	if err != nil {
+		return nil, err
+	}
+	imported := make(map[string]string, len(catalogs))
+	for _, catalog := range catalogs {
+		if catalog.IsScanned {
+			imported[catalog.CatName] = catalog.InpxChecksum
+		}
+	}
+
+	var archives []string
+	missing := 0
+	for _, name := range s.index.Archives() {
+		archivePath, ok := onDisk[name]
+		if !ok {
+			missing++
+			continue
+		}
+		relPath, err := filepath.Rel(s.archivesDir, archivePath)
+		if err != nil {
+			relPath = name
+		}
+		if checksum, scanned := imported[relPath]; scanned && checksum == s.index.Checksum(name) {
+			continue
+		}
+		archives = append(archives, archivePath)
+	}
+	if missing > 0 {
+		logging.Warnf("INPX index describes %d archives that are not in %s", missing, s.archivesDir)
+	}
+	logging.Infof("Found %d archives to import from the INPX index", len(archives))
+	return archives, nil
+}
+
 // ScanArchive scans a single archive and processes all FB2 files in it
 func (s *BookScanService) ScanArchive(archivePath string) (*ArchiveReport, error) {
 	startTime := time.Now()
@@ -205,51 +294,35 @@ func (s *BookScanService) ScanArchive(archivePath string) (*ArchiveReport, error
 	}
 
 	s.progressMu.Lock()
-	s.totalBooksInArchive = len(fb2Files)
-	s.currentBookIndex = 0
+	s.filesTotal += len(fb2Files)
 	s.progressMu.Unlock()
 
 	logging.Infof("Found %d FB2 files in archive %s", len(fb2Files), archiveName)
 
-	// Process each FB2 file in the archive
-	for _, file := range fb2Files {
-		fileName := file.Name
-
-		// Process the book
-		bookID, err := s.ProcessBook(file, archiveName)
-		if err != nil {
-			if err.Error() == "duplicate" {
-				report.BooksSkipped++
-				logging.Debugf("Skipped duplicate book: %s in %s", fileName, archiveName)
-			} else {
-				report.Errors = append(report.Errors, ScanError{
-					FileName:    fileName,
-					ArchiveName: archiveName,
-					Error:       err.Error(),
-					Timestamp:   time.Now(),
-				})
-				logging.Warnf("Failed to process book %s in %s: %v", fileName, archiveName, err)
-			}
-		} else {
-			report.BooksProcessed++
-			logging.Debugf("Successfully processed book ID %d: %s", bookID, fileName)
-		}
-
-		// Increment progress counter after processing (successful or not)
-		s.progressMu.Lock()
-		s.currentBookIndex++
-		s.progressMu.Unlock()
+	records, existing := s.archiveIndex(archiveName)
+	s.runPipeline(archiveName, fb2Files, records, existing, report)
 
-		// Update progress in database periodically
-		if report.BooksProcessed%10 == 0 {
-			_ = database.UpdateScanProgress(archiveName, report.BooksProcessed, len(report.Errors))
+	// What the index lists and the archive does not hold: runPipeline has
+	// taken every file it found out of records.
+	for name, rec := range records {
+		if rec.Deleted {
+			continue
 		}
+		report.Errors = append(report.Errors, ScanError{
+			FileName:    name,
+			ArchiveName: archiveName,
+			Error:       "listed in the INPX index but missing from the archive",
+			Timestamp:   time.Now(),
+		})
 	}
 
-	// Mark archive as scanned
-	err = database.MarkArchiveAsScanned(archiveName, report.BooksProcessed, len(report.Errors))
+	// Mark archive as scanned. A re-import counts the books it found in
+	// place along with those it added.
+	err = database.MarkArchiveAsScanned(archiveName, report.BooksProcessed+len(existing), len(report.Errors))
 	if err != nil {
 		logging.Errorf("Failed to mark archive %s as scanned: %v", archiveName, err)
+	} else if records != nil {
+		_ = database.SetCatalogInpxChecksum(archiveName, s.index.Checksum(archiveName))
 	}
 
 	report.Duration = time.Since(startTime)
@@ -262,171 +335,57 @@ func (s *BookScanService) ScanArchive(archivePath string) (*ArchiveReport, error
 	return report, nil
 }
 
-// ProcessBook processes a single FB2 file from an archive
-func (s *BookScanService) ProcessBook(zipFile *zip.File, archiveName string) (int64, error) {
-	fileName := zipFile.Name
-
-	// 1. Extract and read FB2 content
-	fileReader, err := zipFile.Open()
-	if err != nil {
-		return 0, fmt.Errorf("failed to open file in archive: %w", err)
-	}
-	defer func() {
-		if closeErr := fileReader.Close(); closeErr != nil {
-			logging.Warnf("Failed to close file reader: %v", closeErr)
-		}
-	}()
-
-	fb2Content, err := io.ReadAll(fileReader)
-	if err != nil {
-		return 0, fmt.Errorf("failed to read file content: %w", err)
+// archiveIndex returns the index records of an archive, keyed by file name,
+// and the books already scanned from it. Both are nil when there is no index
+// or it does not describe the archive; an index that cannot be read is
+// logged and the archive parsed file by file.
+func (s *BookScanService) archiveIndex(archiveName string) (map[string]inpx.Record, map[string]int64) {
+	if s.index == nil {
+		return nil, nil
 	}
-
-	// 2. Parse FB2 file
-	fb2Parser := parser.NewFB2Parser(true) // readCover=true
-	parsedBook, err := fb2Parser.Parse(bytes.NewReader(fb2Content))
+	records, err := s.index.Records(archiveName)
 	if err != nil {
-		return 0, fmt.Errorf("failed to parse FB2: %w", err)
-	}
-	if strings.TrimSpace(parsedBook.Title) == "" {
-		return 0, fmt.Errorf("missing title")
-	}
-	if len(parsedBook.Authors) == 0 {
-		parsedBook.Authors = []parser.Author{
-			{
-				Name:    "Автор неизвестен",
-				Sortkey: "Автор неизвестен",
-			},
-		}
-	}
-
-	// 3. Detect language
-	detectedLang := parsedBook.Language
-	if s.languageDetector != nil {
-		langResult := s.languageDetector.DetectLanguage(parsedBook.Language, parsedBook.BodySample)
-		detectedLang = langResult.Language
-		logging.Debugf("Language detected for %s: %s (confidence: %.2f, method: %s)",
-			fileName, detectedLang, langResult.Confidence, langResult.Method)
-	}
-
-	// 4. Check for duplicates if enabled
-	if s.skipDuplicates {
-		isDuplicate, err := s.checkDuplicate(fb2Content, parsedBook.Title, parsedBook.Authors)
-		if err != nil {
-			logging.Warnf("Duplicate check failed for %s, proceeding anyway: %v", fileName, err)
-		} else if isDuplicate {
-			return 0, fmt.Errorf("duplicate")
+		if !errors.Is(err, inpx.ErrNotIndexed) {
+			logging.Warnf("INPX index unusable for %s, parsing every file: %v", archiveName, err)
 		}
+		return nil, nil
 	}
-
-	// 5. Create book record in database
-	book := &models.Book{
-		Path:         archiveName,
-		Format:       "fb2",
-		FileName:     fileName,
-		RegisterDate: time.Now(),
-		DocDate:      parsedBook.DocDate,
-		Lang:         detectedLang,
-		Title:        parsedBook.Title,
-		Cover:        len(parsedBook.Cover) > 0,
-		Annotation:   parsedBook.Annotation,
-		Approved:     true, // Auto-approve scanned books
-	}
-
-	// Compute MD5 hash for duplicate detection
-	// #nosec G401 -- a fingerprint for duplicate detection, see the import.
-	hash := md5.Sum(fb2Content)
-	book.MD5 = hex.EncodeToString(hash[:])
-
-	// Start transaction
-	tx, err := database.GetDB().Begin()
+	existing, err := database.ArchiveBookIDs(archiveName)
 	if err != nil {
-		return 0, fmt.Errorf("failed to start transaction: %w", err)
+		logging.Warnf("Failed to list books of %s, parsing every file: %v", archiveName, err)
+		return nil, nil
 	}
-	defer func() {
-		if rollbackErr := tx.Rollback(); rollbackErr != nil && rollbackErr != pg.ErrTxDone {
-			logging.Warnf("Failed to rollback transaction: %v", rollbackErr)
-		}
-	}()
+	logging.Infof("INPX index describes %d files of %s, %d already in the catalog", len(records), archiveName, len(existing))
+	return records, existing
+}
 
-	// Insert book
-	_, err = tx.Model(book).Insert()
+// ProcessBook processes a single FB2 file from an archive, through the same
+// stages as a scan but on its own.
+func (s *BookScanService) ProcessBook(zipFile *zip.File, archiveName string) (int64, error) {
+	prepared, err := s.prepareFB2(zipFile, archiveName)
 	if err != nil {
-		return 0, fmt.Errorf("failed to insert book: %w", err)
+		return 0, err
 	}
-
-	// 6. Process cover if present
-	if len(parsedBook.Cover) > 0 {
-		err = s.ProcessCover(book, parsedBook.Cover)
-		if err != nil {
-			logging.Warnf("Failed to save cover for %s: %v", fileName, err)
-			// Don't fail the entire operation if cover save fails
+	if s.skipDuplicates {
+		var report ArchiveReport
+		if len(s.dropDuplicates([]*preparedBook{prepared}, &report)) == 0 {
+			return 0, fmt.Errorf("duplicate")
 		}
 	}
-
-	// 7. Process authors
-	err = s.ProcessAuthors(tx, book.ID, parsedBook.Authors)
-	if err != nil {
-		return 0, fmt.Errorf("failed to process authors: %w", err)
-	}
-
-	// 8. Process series if present
-	if parsedBook.Series != nil {
-		err = s.ProcessSeries(tx, book.ID, parsedBook.Series)
-		if err != nil {
-			return 0, fmt.Errorf("failed to process series: %w", err)
-		}
+	if err := s.insertBooks([]*preparedBook{prepared}); err != nil {
+		return 0, err
 	}
-
-	// 9. Process genres/tags if present
-	if len(parsedBook.Tags) > 0 {
-		err = database.UpdateBookTags(tx, book.ID, parsedBook.Tags, s.llmService)
-		if err != nil {
-			return 0, fmt.Errorf("failed to process genres: %w", err)
+	if len(prepared.cover) > 0 {
+		if err := s.ProcessCover(prepared.book, prepared.cover); err != nil {
+			logging.Warnf("Failed to save cover for %s: %v", zipFile.Name, err)
 		}
 	}
 
-	// Commit transaction
-	err = tx.Commit()
-	if err != nil {
-		return 0, fmt.Errorf("failed to commit transaction: %w", err)
-	}
-
-	logging.Infof("Successfully added book ID %d: %s", book.ID, parsedBook.Title)
+	logging.Infof("Successfully added book ID %d: %s", prepared.book.ID, prepared.book.Title)
 	if s.publisher != nil {
-		s.publisher.PublishBookProcessed(archiveName, book.Title, book.ID)
+		s.publisher.PublishBookProcessed(archiveName, prepared.book.Title, prepared.book.ID)
 	}
-	return book.ID, nil
-}
-
-// checkDuplicate checks if a book is a duplicate based on MD5 hash and fuzzy title/author matching
-// Note: title and authors parameters are reserved for future fuzzy matching implementation
-func (s *BookScanService) checkDuplicate(content []byte, title string, authors []parser.Author) (bool, error) {
-	// TODO: Implement fuzzy matching using title and authors parameters
-	_ = title   // Reserved for future use
-	_ = authors // Reserved for future use
-
-	// Check by MD5 hash first (exact duplicate)
-	// #nosec G401 -- a fingerprint for duplicate detection, see the import.
-	hash := md5.Sum(content)
-	md5Hash := hex.EncodeToString(hash[:])
-
-	var count int
-	count, err := database.GetDB().Model((*models.Book)(nil)).
-		Where("md5 = ?", md5Hash).
-		Count()
-	if err != nil {
-		return false, err
-	}
-	if count > 0 {
-		logging.Debugf("Found exact duplicate by MD5: %s", md5Hash)
-		return true, nil
-	}
-
-	// TODO: Implement fuzzy matching by title + authors
-	// For now, only check exact MD5 matches
-
-	return false, nil
+	return prepared.book.ID, nil
 }
 
 // ProcessCover saves the cover image to disk
@@ -450,39 +409,34 @@ func (s *BookScanService) ProcessCover(book *models.Book, coverData []byte) erro
 	return nil
 }
 
-// ProcessAuthors creates or links authors to the book
+// ProcessAuthors creates or links authors to the book. A name an alias maps
+// to an author is linked to that author, once however many of the book's
+// names lead there.
 func (s *BookScanService) ProcessAuthors(tx *pg.Tx, bookID int64, authors []parser.Author) error {
+	linked := make(map[int64]bool, len(authors))
 	for _, parsedAuthor := range authors {
-		// Get or create author
-		author := &models.Author{}
-		err := tx.Model(author).
-			Where("full_name = ?", parsedAuthor.Name).
-			Select()
-
+		if strings.TrimSpace(parsedAuthor.Name) == "" {
+			continue
+		}
+		authorID, err := database.GetOrCreateAuthor(tx, parsedAuthor.Name)
 		if err != nil {
-			if err == pg.ErrNoRows {
-				// Create new author
-				author.FullName = parsedAuthor.Name
-				_, err = tx.Model(author).Insert()
-				if err != nil {
-					return fmt.Errorf("failed to create author %s: %w", parsedAuthor.Name, err)
-				}
-				logging.Debugf("Created new author: %s (ID: %d)", author.FullName, author.ID)
-			} else {
-				return fmt.Errorf("failed to query author: %w", err)
-			}
+			return fmt.Errorf("failed to resolve author %s: %w", parsedAuthor.Name, err)
 		}
+		if linked[authorID] {
+			continue
+		}
+		linked[authorID] = true
 
 		// Link author to book via junction table
 		orderToAuthor := &models.OrderToAuthor{
 			BookID:   bookID,
-			AuthorID: author.ID,
+			AuthorID: authorID,
 		}
 		_, err = tx.Model(orderToAuthor).Insert()
 		if err != nil {
 			return fmt.Errorf("failed to link author to book: %w", err)
 		}
-		logging.Debugf("Linked author %s to book %d", author.FullName, bookID)
+		logging.Debugf("Linked author %s (ID: %d) to book %d", parsedAuthor.Name, authorID, bookID)
 	}
 
 	return nil
@@ -593,6 +547,9 @@ func (s *BookScanService) ScanAll() (*ScanReport, error) {
 
 	logging.Infof("Completed full scan: %d archives, %d books processed, %d skipped, %d errors in %v",
 		report.TotalArchives, report.ProcessedBooks, report.SkippedBooks, len(report.Errors), report.Duration)
+	if report.ProcessedBooks > 0 {
+		RefreshSearchDictionary(context.Background())
+	}
 	if s.publisher != nil {
 		s.publisher.PublishScanCompleted(report)
 	}
@@ -0,0 +1,323 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	// #nosec G501 -- MD5 identifies identical files, as it does in a scan.
+	// It is a fingerprint, not a security control.
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"gopds-api/database"
+	"gopds-api/internal/posters"
+	"gopds-api/internal/safeio"
+	"gopds-api/logging"
+	"gopds-api/models"
+
+	"github.com/go-pg/pg/v10"
+)
+
+// Upload list paging, as for reviews.
+const (
+	defaultUploadPageSize = 20
+	maxUploadPageSize     = 100
+)
+
+// uploadQuotaWindow is the period UploadLimits.DailyLimit counts over.
+const uploadQuotaWindow = 24 * time.Hour
+
+// maxUploadFileNameLength bounds the file name an upload keeps, in
+// characters.
+const maxUploadFileNameLength = 255
+
+var (
+	// ErrUploadsDisabled reports uploads turned off by configuration.
+	ErrUploadsDisabled = errors.New("uploads are turned off")
+	// ErrUploadTooLarge reports a file, or the FB2 unzipped from it, over
+	// the size limit.
+	ErrUploadTooLarge = errors.New("upload is too large")
+	// ErrUploadQuotaExceeded reports a reader over their daily limit or
+	// with too many uploads waiting for a moderator.
+	ErrUploadQuotaExceeded = errors.New("upload quota exceeded")
+	// ErrUploadFormat reports a file that is neither an FB2, a zip holding
+	// one, nor an EPUB.
+	ErrUploadFormat = errors.New("unsupported upload format")
+	// ErrUploadUnreadable reports an FB2 or an EPUB that does not parse or
+	// has no title, or an FB2 that parses only with its body dropped.
+	ErrUploadUnreadable = errors.New("upload is not a readable book")
+	// ErrUploadDuplicate reports the same file uploaded twice at once.
+	ErrUploadDuplicate = errors.New("the same file is already being uploaded")
+)
+
+// UploadLimits bounds what readers upload; see config.UploadsConfig.
+type UploadLimits struct {
+	MaxBytes     int64
+	DailyLimit   int
+	PendingLimit int
+}
+
+// BookUploadService takes books from readers into the upload inbox as
+// unapproved books, and keeps the queue moderators approve or reject them
+// from.
+type BookUploadService struct {
+	scanner *BookScanService
+	inbox   *UploadInbox
+	limits  UploadLimits
+}
+
+// NewBookUploadService returns the upload service, parsing uploads with
+// scanner and storing them in inbox.
+func NewBookUploadService(scanner *BookScanService, inbox *UploadInbox, limits UploadLimits) *BookUploadService {
+	return &BookUploadService{scanner: scanner, inbox: inbox, limits: limits}
+}
+
+// Upload takes a reader's FB2, a zip holding one, or an EPUB into the inbox
+// and registers it as an unapproved book, parsed as a scan would. A file the
+// catalogue has already is refused with a *models.BookInLibraryError
+// naming its book.
+func (s *BookUploadService) Upload(ctx context.Context, userID int64, fileName string, content []byte) (*models.BookUpload, error) {
+	if s.limits.DailyLimit <= 0 {
+		return nil, ErrUploadsDisabled
+	}
+	if int64(len(content)) > s.limits.MaxBytes {
+		return nil, fmt.Errorf("%w: at most %d bytes", ErrUploadTooLarge, s.limits.MaxBytes)
+	}
+	// A cheap count turns a reader over their limits away before the file
+	// is parsed; the count that holds is taken again under a lock, below.
+	if err := s.checkQuota(database.CountUserUploads(ctx, userID, time.Now().Add(-uploadQuotaWindow))); err != nil {
+		return nil, err
+	}
+
+	file, format, err := extractUpload(fileName, content, s.limits.MaxBytes)
+	if err != nil {
+		return nil, err
+	}
+	// #nosec G401 -- a fingerprint for duplicate detection, see the import.
+	hash := md5.Sum(file)
+	sum := hex.EncodeToString(hash[:])
+	bookID, err := database.BookIDByMD5(ctx, sum)
+	if err != nil {
+		return nil, err
+	}
+	if bookID != 0 {
+		return nil, &models.BookInLibraryError{BookID: bookID}
+	}
+
+	// The FB2 parser runs the repair chain, fb2sanitize.Apply, on the
+	// decoded text; the inbox keeps the file as sent, which is what the
+	// MD5 is of and what downloads send.
+	archive, entry := s.inbox.ArchiveFor(time.Now()), sum+"."+format
+	prepare := s.scanner.prepareFB2Content
+	if format == formatEPUB {
+		prepare = s.scanner.prepareEPUBContent
+	}
+	prepared, err := prepare(file, archive, entry)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUploadUnreadable, err)
+	}
+	if prepared.bodyless {
+		return nil, fmt.Errorf("%w: the body is damaged", ErrUploadUnreadable)
+	}
+	prepared.book.Approved = false
+
+	if err := claimInboxCatalog(archive); err != nil {
+		return nil, err
+	}
+	entries, err := s.inbox.Add(archive, entry, file)
+	if errors.Is(err, errInboxEntryExists) {
+		return nil, ErrUploadDuplicate
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	upload := &models.BookUpload{
+		UserID:   &userID,
+		FileName: uploadFileName(fileName),
+		Size:     int64(len(content)),
+		MD5:      sum,
+		Title:    prepared.book.Title,
+		Archive:  archive,
+		Entry:    entry,
+		Status:   models.UploadPending,
+	}
+	err = database.GetDB().RunInTransaction(ctx, func(tx *pg.Tx) error {
+		if err := s.checkQuota(database.LockUserUploads(ctx, tx, userID, time.Now().Add(-uploadQuotaWindow))); err != nil {
+			return err
+		}
+		if err := s.scanner.insertBatch(tx, []*preparedBook{prepared}); err != nil {
+			return err
+		}
+		upload.BookID = &prepared.book.ID
+		return database.CreateBookUpload(tx, upload)
+	})
+	if err != nil {
+		s.discard(archive, entry)
+		return nil, err
+	}
+	if len(prepared.cover) > 0 {
+		if err := s.scanner.ProcessCover(prepared.book, prepared.cover); err != nil {
+			logging.Warnf("Failed to save cover for upload %d: %v", upload.ID, err)
+		}
+	}
+	_ = database.MarkArchiveAsScanned(archive, entries, 0)
+
+	logging.Infof("User %d uploaded book %d: %s", userID, prepared.book.ID, prepared.book.Title)
+	return upload, nil
+}
+
+// checkQuota refuses an upload from a reader who, by the counts given,
+// uploaded their daily limit or has too many uploads waiting.
+func (s *BookUploadService) checkQuota(recent, pending int, err error) error {
+	if err != nil {
+		return err
+	}
+	if recent >= s.limits.DailyLimit {
+		return fmt.Errorf("%w: at most %d uploads a day", ErrUploadQuotaExceeded, s.limits.DailyLimit)
+	}
+	if s.limits.PendingLimit > 0 && pending >= s.limits.PendingLimit {
+		return fmt.Errorf("%w: at most %d uploads waiting for moderation", ErrUploadQuotaExceeded, s.limits.PendingLimit)
+	}
+	return nil
+}
+
+// List returns a page of uploads and how many there are. An empty status
+// lists every state.
+func (s *BookUploadService) List(ctx context.Context, filter models.BookUploadFilter, page, pageSize int) ([]models.BookUploadView, int, error) {
+	if filter.Status != "" && !models.IsUploadStatus(filter.Status) {
+		return nil, 0, fmt.Errorf("%w: unknown upload status %q", models.ErrUploadInvalid, filter.Status)
+	}
+	limit, offset := uploadPage(page, pageSize)
+	return database.ListBookUploads(ctx, filter, limit, offset)
+}
+
+// Moderate approves or rejects a pending upload, returning it before and
+// after the decision. Approving it puts its book in the catalogue;
+// rejecting it deletes the book and takes its file out of the inbox.
+func (s *BookUploadService) Moderate(
+	ctx context.Context, id int64, decision models.UploadModeration, moderatorID *int64,
+) (before, after *models.BookUpload, err error) {
+	if err := decision.Validate(); err != nil {
+		return nil, nil, err
+	}
+	if decision.Status == models.UploadApproved {
+		return database.ApproveBookUpload(ctx, id, moderatorID)
+	}
+	before, after, err = database.RejectBookUpload(ctx, id, decision.Reason, moderatorID)
+	if err != nil {
+		return nil, nil, err
+	}
+	s.discard(before.Archive, before.Entry)
+	return before, after, nil
+}
+
+// discard takes an upload's file out of the inbox and removes its cover.
+// Failures are logged: the book is gone either way, and a file left
+// behind is found by nothing.
+func (s *BookUploadService) discard(archive, entry string) {
+	if err := s.inbox.Remove(archive, entry); err != nil {
+		logging.Warnf("Failed to remove %s from %s: %v", entry, archive, err)
+	}
+	cover := posters.FilePath(s.scanner.coversDir, archive, entry)
+	if err := os.Remove(cover); err != nil && !os.IsNotExist(err) {
+		logging.Warnf("Failed to remove cover %s: %v", cover, err)
+	}
+}
+
+// claimInboxCatalog records an inbox archive as scanned before it is first
+// written, so that a scan of the library never takes its books in,
+// approved, as its own.
+func claimInboxCatalog(archive string) error {
+	catalog, err := database.GetOrCreateCatalog(archive)
+	if err != nil {
+		return err
+	}
+	if catalog.IsScanned {
+		return nil
+	}
+	return database.MarkArchiveAsScanned(archive, 0, 0)
+}
+
+// extractUpload returns the book of an upload and its format: the file
+// itself for an FB2 or an EPUB, or the one FB2 a zip holds, unzipped to at
+// most maxBytes.
+func extractUpload(fileName string, content []byte, maxBytes int64) ([]byte, string, error) {
+	name := strings.ToLower(fileName)
+	switch {
+	case strings.HasSuffix(name, ".fb2"):
+		return content, formatFB2, nil
+	case strings.HasSuffix(name, ".epub"):
+		return content, formatEPUB, nil
+	case strings.HasSuffix(name, ".zip"):
+		fb2, err := unzipUploadFB2(content, maxBytes)
+		return fb2, formatFB2, err
+	default:
+		return nil, "", fmt.Errorf("%w: %q is not .fb2, .fb2.zip or .epub", ErrUploadFormat, path.Ext(name))
+	}
+}
+
+// unzipUploadFB2 reads the one FB2 of a zipped upload.
+func unzipUploadFB2(content []byte, maxBytes int64) ([]byte, error) {
+	r, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUploadUnreadable, err)
+	}
+	var fb2 *zip.File
+	for _, f := range r.File {
+		if f.FileInfo().IsDir() || !strings.HasSuffix(strings.ToLower(f.Name), ".fb2") {
+			continue
+		}
+		if fb2 != nil {
+			return nil, fmt.Errorf("%w: the zip holds more than one FB2", ErrUploadFormat)
+		}
+		fb2 = f
+	}
+	if fb2 == nil {
+		return nil, fmt.Errorf("%w: the zip holds no FB2", ErrUploadFormat)
+	}
+	if fb2.UncompressedSize64 > uint64(maxBytes) { // #nosec G115 -- maxBytes is positive
+		return nil, fmt.Errorf("%w: the FB2 unzips to more than %d bytes", ErrUploadTooLarge, maxBytes)
+	}
+	rc, err := fb2.Open()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUploadUnreadable, err)
+	}
+	defer rc.Close()
+	// The size in the header is the sender's word; the read is bounded
+	// whatever it says.
+	data, err := safeio.ReadAll(rc, maxBytes)
+	if errors.Is(err, safeio.ErrTooLarge) {
+		return nil, fmt.Errorf("%w: the FB2 unzips to more than %d bytes", ErrUploadTooLarge, maxBytes)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUploadUnreadable, err)
+	}
+	return data, nil
+}
+
+// uploadFileName is the name an upload keeps of the file it was sent as.
+func uploadFileName(fileName string) string {
+	name := path.Base(strings.ReplaceAll(strings.TrimSpace(fileName), `\`, "/"))
+	if utf8.RuneCountInString(name) > maxUploadFileNameLength {
+		name = string([]rune(name)[:maxUploadFileNameLength])
+	}
+	return name
+}
+
+// uploadPage turns a page number, from one, and a page size into a limit
+// and an offset.
+func uploadPage(page, pageSize int) (limit, offset int) {
+	limit = clampLimit(pageSize, defaultUploadPageSize, maxUploadPageSize)
+	if page < 1 {
+		page = 1
+	}
+	return limit, (page - 1) * limit
+}
@@ -0,0 +1,194 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	// #nosec G501 -- the tests check the fingerprint the service computes.
+	"crypto/md5"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"gopds-api/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const uploadFB2 = `<?xml version="1.0" encoding="utf-8"?>
+<FictionBook xmlns="http://www.gribuser.ru/xml/fictionbook/2.0">
+<description><title-info><book-title>Улитка на склоне</book-title>
+<author><first-name>Аркадий</first-name><last-name>Стругацкий</last-name></author>
+<lang>ru</lang></title-info></description>
+<body><section><p>Отсюда, с этой высоты, лес был как пестрая застывшая пена.</p></section></body>
+</FictionBook>`
+
+// zipOf zips entries, in order, into an upload.
+func zipOf(t *testing.T, entries ...string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for i := 0; i < len(entries); i += 2 {
+		fw, err := w.Create(entries[i])
+		require.NoError(t, err)
+		_, err = fw.Write([]byte(entries[i+1]))
+		require.NoError(t, err)
+	}
+	require.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+// uploadEPUB is a minimal EPUB of the same book.
+func uploadEPUB(t *testing.T) []byte {
+	t.Helper()
+	return zipOf(t,
+		"mimetype", "application/epub+zip",
+		"META-INF/container.xml", `<container><rootfiles><rootfile full-path="content.opf"/></rootfiles></container>`,
+		"content.opf", `<package xmlns="http://www.idpf.org/2007/opf" xmlns:dc="http://purl.org/dc/elements/1.1/">
+<metadata><dc:title>Улитка на склоне</dc:title><dc:creator>Аркадий Стругацкий</dc:creator><dc:language>ru</dc:language></metadata>
+<manifest><item id="t" href="text.xhtml" media-type="application/xhtml+xml"/></manifest>
+<spine><itemref idref="t"/></spine></package>`,
+		"text.xhtml", `<html><body><p>Отсюда, с этой высоты, лес был как пестрая застывшая пена.</p></body></html>`,
+	)
+}
+
+func TestExtractUpload(t *testing.T) {
+	got, format, err := extractUpload("Book.FB2", []byte(uploadFB2), 1<<20)
+	require.NoError(t, err)
+	assert.Equal(t, uploadFB2, string(got))
+	assert.Equal(t, formatFB2, format)
+
+	got, format, err = extractUpload("book.fb2.zip", zipOf(t, "readme.txt", "hi", "dir/book.fb2", uploadFB2), 1<<20)
+	require.NoError(t, err)
+	assert.Equal(t, uploadFB2, string(got), "the one FB2 of the zip")
+	assert.Equal(t, formatFB2, format)
+
+	epub := uploadEPUB(t)
+	got, format, err = extractUpload("Book.EPUB", epub, 1<<20)
+	require.NoError(t, err)
+	assert.Equal(t, epub, got, "an EPUB is kept as it was sent")
+	assert.Equal(t, formatEPUB, format)
+
+	for _, tc := range []struct {
+		name, file string
+		content    []byte
+		want       error
+	}{
+		{"a pdf", "book.pdf", []byte("%PDF"), ErrUploadFormat},
+		{"a zip of two books", "two.zip", zipOf(t, "a.fb2", uploadFB2, "b.fb2", uploadFB2), ErrUploadFormat},
+		{"a zip without a book", "none.zip", zipOf(t, "a.txt", "text"), ErrUploadFormat},
+		{"not a zip", "broken.fb2.zip", []byte("not a zip"), ErrUploadUnreadable},
+		{"a zip bomb", "big.fb2.zip", zipOf(t, "a.fb2", strings.Repeat("a", 2048)), ErrUploadTooLarge},
+	} {
+		_, _, err := extractUpload(tc.file, tc.content, 1024)
+		assert.ErrorIs(t, err, tc.want, tc.name)
+	}
+}
+
+func TestUploadInbox_AddServeAndRemove(t *testing.T) {
+	root := t.TempDir() + "/"
+	inbox := NewUploadInbox(root, "uploads")
+	archive := inbox.ArchiveFor(time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC))
+	assert.Equal(t, "uploads/inbox-2026-10.zip", archive)
+
+	n, err := inbox.Add(archive, "a.fb2", []byte(uploadFB2))
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	n, err = inbox.Add(archive, "b.fb2", []byte("<FictionBook/>"))
+	require.NoError(t, err)
+	assert.Equal(t, 2, n, "the month's uploads share an archive")
+	_, err = inbox.Add(archive, "a.fb2", []byte(uploadFB2))
+	assert.ErrorIs(t, err, errInboxEntryExists)
+
+	// An uploaded book is read from the inbox as any book from its archive.
+	file, err := OpenBookFile(nil, &models.Book{Path: archive, FileName: "a.fb2"}, root, "fb2")
+	require.NoError(t, err)
+	assert.Equal(t, uploadFB2, readConverted(t, file))
+
+	require.NoError(t, inbox.Remove(archive, "a.fb2"))
+	require.NoError(t, inbox.Remove(archive, "a.fb2"), "removing twice is removing once")
+	require.NoError(t, inbox.Remove("uploads/inbox-1999-01.zip", "a.fb2"), "nor is a missing archive an error")
+	_, err = OpenBookFile(nil, &models.Book{Path: archive, FileName: "a.fb2"}, root, "fb2")
+	assert.Error(t, err)
+	file, err = OpenBookFile(nil, &models.Book{Path: archive, FileName: "b.fb2"}, root, "fb2")
+	require.NoError(t, err)
+	assert.Equal(t, "<FictionBook/>", readConverted(t, file), "the other upload stays")
+
+	leftovers, err := filepath.Glob(filepath.Join(root, "uploads", ".inbox-*"))
+	require.NoError(t, err)
+	assert.Empty(t, leftovers, "no half-written archive is left behind")
+	_, err = os.Stat(filepath.Join(root, "uploads", "inbox-1999-01.zip"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestPrepareFB2Content_ParsesAnUploadAsTheScanDoes(t *testing.T) {
+	scanner := NewBookScanService("", "", nil, true, nil)
+	prepared, err := scanner.prepareFB2Content([]byte(uploadFB2), "uploads/inbox-2026-10.zip", "x.fb2")
+	require.NoError(t, err)
+	assert.Equal(t, "Улитка на склоне", prepared.book.Title)
+	assert.Equal(t, "uploads/inbox-2026-10.zip", prepared.book.Path)
+	assert.Equal(t, "x.fb2", prepared.book.FileName)
+	assert.NotEmpty(t, prepared.book.MD5)
+	assert.False(t, prepared.bodyless)
+}
+
+func TestPrepareEPUBContent_ParsesAnUploadedEPUB(t *testing.T) {
+	scanner := NewBookScanService("", "", nil, true, nil)
+	epub := uploadEPUB(t)
+	prepared, err := scanner.prepareEPUBContent(epub, "uploads/inbox-2026-10.zip", "x.epub")
+	require.NoError(t, err)
+	assert.Equal(t, "Улитка на склоне", prepared.book.Title)
+	assert.Equal(t, formatEPUB, prepared.book.Format)
+	assert.Equal(t, "x.epub", prepared.book.FileName)
+	require.Len(t, prepared.authors, 1)
+	assert.Equal(t, "Стругацкий Аркадий", prepared.authors[0].Name)
+	// #nosec G401 -- the fingerprint the catalogue keeps.
+	sum := md5.Sum(epub)
+	assert.Equal(t, hex.EncodeToString(sum[:]), prepared.book.MD5, "the MD5 is of the file the inbox keeps")
+
+	_, err = scanner.prepareEPUBContent([]byte(uploadFB2), "uploads/inbox-2026-10.zip", "y.epub")
+	assert.Error(t, err, "an FB2 named .epub is not an EPUB")
+}
+
+// A rescan of an uploaded EPUB reads it as an EPUB, not as an FB2.
+func TestParseRescanContent_ReadsEachFormatWithItsParser(t *testing.T) {
+	parsed, err := parseRescanContent(formatEPUB, uploadEPUB(t))
+	require.NoError(t, err)
+	assert.Equal(t, "Улитка на склоне", parsed.Title)
+
+	parsed, err = parseRescanContent(formatFB2, []byte(uploadFB2))
+	require.NoError(t, err)
+	assert.Equal(t, "Улитка на склоне", parsed.Title)
+
+	_, err = parseRescanContent(formatFB2, uploadEPUB(t))
+	assert.Error(t, err)
+}
+
+// The refusals below are decided before the database is asked anything.
+func TestBookUploadService_RefusesBeforeTheDatabase(t *testing.T) {
+	ctx := context.Background()
+	inbox := NewUploadInbox(t.TempDir(), "uploads")
+
+	off := NewBookUploadService(nil, inbox, UploadLimits{MaxBytes: 1 << 20})
+	_, err := off.Upload(ctx, 7, "book.fb2", []byte(uploadFB2))
+	assert.ErrorIs(t, err, ErrUploadsDisabled, "a daily limit of zero turns uploads off")
+
+	small := NewBookUploadService(nil, inbox, UploadLimits{MaxBytes: 16, DailyLimit: 10})
+	_, err = small.Upload(ctx, 7, "book.fb2", []byte(uploadFB2))
+	assert.ErrorIs(t, err, ErrUploadTooLarge)
+
+	_, _, err = small.Moderate(ctx, 1, models.UploadModeration{Status: models.UploadPending}, nil)
+	assert.ErrorIs(t, err, models.ErrUploadInvalid)
+	_, _, err = small.List(ctx, models.BookUploadFilter{Status: "lost"}, 1, 20)
+	assert.ErrorIs(t, err, models.ErrUploadInvalid)
+}
+
+func TestUploadFileName(t *testing.T) {
+	assert.Equal(t, "book.fb2", uploadFileName(`C:\Users\reader\book.fb2`))
+	assert.Equal(t, "book.fb2", uploadFileName(" ../../book.fb2 "))
+	assert.Len(t, []rune(uploadFileName(strings.Repeat("я", 300)+".fb2")), maxUploadFileNameLength)
+}
@@ -0,0 +1,451 @@
+package services
+
+// conversion_cache.go keeps converted books on disk. An EPUB is regenerated
+// from the FB2 on every download and a MOBI additionally goes through
+// kindlegen, yet the result depends on nothing but the source file and the
+// converter: the same book converted twice is the same bytes twice. So the
+// result is addressed by exactly those inputs — the book's MD5, the format,
+// and converter.OutputVersion — and kept until the cache needs the room.
+//
+// The index lives in memory and is rebuilt from the directory at startup.
+// The files are the truth: the name of each carries its whole key, and its
+// modification time carries the last use, so a restart keeps both the content
+// and the eviction order.
+
+import (
+	"bytes"
+	"container/list"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+
+	"gopds-api/internal/converter"
+	"gopds-api/internal/safeio"
+	"gopds-api/logging"
+	"gopds-api/models"
+	"gopds-api/utils"
+)
+
+// ErrUnsupportedConversion reports a format the cache does not produce.
+var ErrUnsupportedConversion = errors.New("conversion: unsupported target format")
+
+// conversionTempPrefix marks a file still being written. Whatever carries it
+// at startup was left by a process that died mid-write and is removed.
+const conversionTempPrefix = ".tmp-"
+
+// md5Pattern is what a key's fingerprint must look like. The MD5 ends up in a
+// file name, so anything else is refused rather than escaped.
+var md5Pattern = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+// conversionFormats are the formats the cache produces, with what makes each.
+var conversionFormats = map[string]func(bp *utils.BookProcessor) (io.ReadCloser, error){
+	"epub": (*utils.BookProcessor).Epub,
+	"mobi": (*utils.BookProcessor).Mobi,
+}
+
+// ConversionProducer makes one converted book. It is called at most once per
+// key at a time, however many readers are waiting for it.
+type ConversionProducer func() (io.ReadCloser, error)
+
+// ConversionEntry describes one cached file.
+type ConversionEntry struct {
+	MD5      string    `json:"md5"`
+	Format   string    `json:"format"`
+	Version  string    `json:"version"`
+	Bytes    int64     `json:"bytes"`
+	LastUsed time.Time `json:"last_used"`
+}
+
+// ConversionCacheStats is the summary the admin endpoint reports.
+type ConversionCacheStats struct {
+	Dir       string `json:"dir"`
+	Version   string `json:"version"`
+	Entries   int    `json:"entries"`
+	Bytes     int64  `json:"bytes"`
+	MaxBytes  int64  `json:"max_bytes"`
+	Hits      int64  `json:"hits"`
+	Misses    int64  `json:"misses"`
+	Evictions int64  `json:"evictions"`
+}
+
+// ConversionCache is a size-bounded LRU of converted books on disk.
+type ConversionCache struct {
+	dir      string
+	maxBytes int64
+	version  string
+
+	mu      sync.Mutex
+	order   *list.List               // front is the most recently used
+	entries map[string]*list.Element // file name → element holding *ConversionEntry
+	size    int64
+
+	hits      int64
+	misses    int64
+	evictions int64
+
+	sf singleflight.Group
+}
+
+// NewConversionCache opens the cache in dir, creating the directory when it
+// is missing, and indexes what an earlier run left there. Files made by
+// another converter version are removed: nothing will ask for them again.
+func NewConversionCache(dir string, maxBytes int64) (*ConversionCache, error) {
+	if strings.TrimSpace(dir) == "" {
+		return nil, errors.New("conversion cache: no directory configured")
+	}
+	if err := os.MkdirAll(dir, safeio.DirMode); err != nil {
+		return nil, fmt.Errorf("conversion cache: creating %s: %w", dir, err)
+	}
+	c := &ConversionCache{
+		dir:      dir,
+		maxBytes: maxBytes,
+		version:  converter.OutputVersion,
+		order:    list.New(),
+		entries:  make(map[string]*list.Element),
+	}
+	if err := c.load(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// load indexes the directory, oldest use last.
+func (c *ConversionCache) load() error {
+	dirEntries, err := os.ReadDir(c.dir)
+	if err != nil {
+		return fmt.Errorf("conversion cache: reading %s: %w", c.dir, err)
+	}
+
+	var found []*ConversionEntry
+	for _, de := range dirEntries {
+		if !de.Type().IsRegular() {
+			continue
+		}
+		name := de.Name()
+		entry, ok := parseConversionName(name)
+		if strings.HasPrefix(name, conversionTempPrefix) || (ok && entry.Version != c.version) {
+			if rmErr := os.Remove(filepath.Join(c.dir, name)); rmErr != nil {
+				logging.Warnf("Conversion cache: could not remove stale file %s: %v", name, rmErr)
+			}
+			continue
+		}
+		if !ok {
+			continue
+		}
+		info, infoErr := de.Info()
+		if infoErr != nil {
+			continue
+		}
+		entry.Bytes = info.Size()
+		entry.LastUsed = info.ModTime()
+		found = append(found, entry)
+	}
+
+	sort.Slice(found, func(i, j int) bool { return found[i].LastUsed.After(found[j].LastUsed) })
+	for _, e := range found {
+		c.entries[conversionName(e.MD5, e.Format, e.Version)] = c.order.PushBack(e)
+		c.size += e.Bytes
+	}
+	c.mu.Lock()
+	c.evictLocked("")
+	c.mu.Unlock()
+
+	logging.Infof("Conversion cache ready in %s: %d files, %d bytes", c.dir, c.order.Len(), c.size)
+	return nil
+}
+
+// conversionName is the file name for one key.
+func conversionName(md5, format, version string) string {
+	return fmt.Sprintf("%s.v%s.%s", md5, version, format)
+}
+
+// parseConversionName reads a key back out of a file name.
+func parseConversionName(name string) (*ConversionEntry, bool) {
+	parts := strings.Split(name, ".")
+	if len(parts) != 3 || !md5Pattern.MatchString(parts[0]) || !strings.HasPrefix(parts[1], "v") {
+		return nil, false
+	}
+	if _, ok := conversionFormats[parts[2]]; !ok {
+		return nil, false
+	}
+	return &ConversionEntry{MD5: parts[0], Version: strings.TrimPrefix(parts[1], "v"), Format: parts[2]}, true
+}
+
+// ConversionETag is the strong validator of a converted book. The MD5 is
+// taken in either case, as the cache keys it.
+func ConversionETag(md5, format string) string {
+	return fmt.Sprintf(`"%s-%s-v%s"`, strings.ToLower(md5), format, converter.OutputVersion)
+}
+
+// ConvertBook returns book in format, converted from the FB2 in its archive
+// under filesPath. A nil cache converts every time and holds the result in
+// memory, which is what downloads did before the cache existed.
+func (c *ConversionCache) ConvertBook(book *models.Book, filesPath, format string) (*BookFile, error) {
+	convert, ok := conversionFormats[format]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedConversion, format)
+	}
+	zipPath := filesPath + book.Path
+	if !utils.FileExists(zipPath) {
+		return nil, fmt.Errorf("book file not found: %s", zipPath)
+	}
+	bp := utils.NewBookProcessor(book.FileName, zipPath)
+	return c.Fetch(strings.ToLower(book.MD5), format, func() (io.ReadCloser, error) {
+		return convert(bp)
+	})
+}
+
+// Fetch returns the cached conversion for (md5, format), running produce to
+// make it on a miss. Concurrent misses on the same key share one run.
+func (c *ConversionCache) Fetch(md5, format string, produce ConversionProducer) (*BookFile, error) {
+	if _, ok := conversionFormats[format]; !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedConversion, format)
+	}
+	if c == nil || !md5Pattern.MatchString(md5) {
+		return convertInMemory(md5, format, produce)
+	}
+
+	name := conversionName(md5, format, c.version)
+	if book, ok := c.open(name, true); ok {
+		return book, nil
+	}
+
+	_, err, _ := c.sf.Do(name, func() (interface{}, error) {
+		// A run that finished between the lookup above and this one has
+		// already done the work.
+		if c.contains(name) {
+			return nil, nil
+		}
+		return nil, c.store(md5, format, name, produce)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if book, ok := c.open(name, false); ok {
+		return book, nil
+	}
+	// Evicted before it could be opened: only possible when a single book
+	// outweighs the whole cache, or a purge raced the conversion. Serving it
+	// uncached is still correct.
+	return convertInMemory(md5, format, produce)
+}
+
+// convertInMemory runs produce and holds the result, for books the cache
+// cannot key.
+func convertInMemory(md5, format string, produce ConversionProducer) (*BookFile, error) {
+	rc, err := produce()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	data, err := safeio.ReadAll(rc, safeio.MaxBookBytes)
+	if err != nil {
+		return nil, err
+	}
+	book := &BookFile{
+		Content: nopSeekCloser{bytes.NewReader(data)},
+		Size:    int64(len(data)),
+	}
+	if md5Pattern.MatchString(md5) {
+		book.ETag = ConversionETag(md5, format)
+	}
+	return book, nil
+}
+
+// nopSeekCloser gives an in-memory reader the Close a file would have.
+type nopSeekCloser struct{ io.ReadSeeker }
+
+func (nopSeekCloser) Close() error { return nil }
+
+// Contains reports whether (md5, format) is cached, without touching its
+// place in the eviction order.
+func (c *ConversionCache) Contains(md5, format string) bool {
+	if c == nil {
+		return false
+	}
+	return c.contains(conversionName(strings.ToLower(md5), format, c.version))
+}
+
+func (c *ConversionCache) contains(name string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, ok := c.entries[name]
+	return ok
+}
+
+// open opens a cached file and marks it used; count says whether the lookup
+// goes into the hit and miss figures. The file is opened under the lock, so
+// an eviction cannot remove it between the lookup and the open; once open,
+// removal no longer affects the reader.
+func (c *ConversionCache) open(name string, count bool) (*BookFile, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[name]
+	if !ok {
+		if count {
+			c.misses++
+		}
+		return nil, false
+	}
+	entry := elem.Value.(*ConversionEntry)
+
+	// #nosec G304 -- name is built by conversionName from a validated hex
+	// MD5, a format from a fixed list and the converter version; the
+	// directory is the configured cache directory.
+	f, err := os.Open(filepath.Join(c.dir, name))
+	if err != nil {
+		// Removed behind the cache's back. Forget it and convert again.
+		c.removeLocked(elem)
+		if count {
+			c.misses++
+		}
+		return nil, false
+	}
+
+	if count {
+		c.hits++
+	}
+	now := time.Now()
+	entry.LastUsed = now
+	c.order.MoveToFront(elem)
+	// The modification time is the recency a restart reads back. Losing it
+	// only costs eviction order, so a failure is not worth failing the read.
+	_ = os.Chtimes(filepath.Join(c.dir, name), now, now)
+
+	return &BookFile{
+		Content: f,
+		Size:    entry.Bytes,
+		ETag:    ConversionETag(entry.MD5, entry.Format),
+	}, true
+}
+
+// store runs produce into a temporary file and publishes it under name.
+func (c *ConversionCache) store(md5, format, name string, produce ConversionProducer) error {
+	rc, err := produce()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	tmp, err := os.CreateTemp(c.dir, conversionTempPrefix+"*")
+	if err != nil {
+		return fmt.Errorf("conversion cache: creating temporary file: %w", err)
+	}
+	tmpName := tmp.Name()
+	written, copyErr := safeio.Copy(tmp, rc, safeio.MaxBookBytes)
+	closeErr := tmp.Close()
+	if copyErr != nil || closeErr != nil {
+		_ = os.Remove(tmpName)
+		if copyErr != nil {
+			return fmt.Errorf("conversion cache: writing %s: %w", name, copyErr)
+		}
+		return fmt.Errorf("conversion cache: writing %s: %w", name, closeErr)
+	}
+	if err := os.Rename(tmpName, filepath.Join(c.dir, name)); err != nil {
+		_ = os.Remove(tmpName)
+		return fmt.Errorf("conversion cache: publishing %s: %w", name, err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if old, ok := c.entries[name]; ok {
+		c.size -= old.Value.(*ConversionEntry).Bytes
+		c.order.Remove(old)
+	}
+	c.entries[name] = c.order.PushFront(&ConversionEntry{
+		MD5:      md5,
+		Format:   format,
+		Version:  c.version,
+		Bytes:    written,
+		LastUsed: time.Now(),
+	})
+	c.size += written
+	c.evictLocked(name)
+	return nil
+}
+
+// evictLocked removes the least recently used files until the cache fits,
+// sparing keep — the file just written, which its reader is about to open.
+func (c *ConversionCache) evictLocked(keep string) {
+	if c.maxBytes <= 0 {
+		return
+	}
+	for elem := c.order.Back(); elem != nil && c.size > c.maxBytes; {
+		prev := elem.Prev()
+		entry := elem.Value.(*ConversionEntry)
+		if conversionName(entry.MD5, entry.Format, entry.Version) != keep {
+			c.removeLocked(elem)
+			c.evictions++
+		}
+		elem = prev
+	}
+}
+
+// removeLocked drops one entry from the index and from the disk.
+func (c *ConversionCache) removeLocked(elem *list.Element) {
+	entry := elem.Value.(*ConversionEntry)
+	name := conversionName(entry.MD5, entry.Format, entry.Version)
+	if err := os.Remove(filepath.Join(c.dir, name)); err != nil && !os.IsNotExist(err) {
+		logging.Warnf("Conversion cache: could not remove %s: %v", name, err)
+	}
+	c.order.Remove(elem)
+	delete(c.entries, name)
+	c.size -= entry.Bytes
+}
+
+// Stats summarizes the cache.
+func (c *ConversionCache) Stats() ConversionCacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return ConversionCacheStats{
+		Dir:       c.dir,
+		Version:   c.version,
+		Entries:   c.order.Len(),
+		Bytes:     c.size,
+		MaxBytes:  c.maxBytes,
+		Hits:      c.hits,
+		Misses:    c.misses,
+		Evictions: c.evictions,
+	}
+}
+
+// Entries lists the cached files, most recently used first.
+func (c *ConversionCache) Entries() []ConversionEntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	out := make([]ConversionEntry, 0, c.order.Len())
+	for elem := c.order.Front(); elem != nil; elem = elem.Next() {
+		out = append(out, *elem.Value.(*ConversionEntry))
+	}
+	return out
+}
+
+// Purge removes every cached conversion of the book with this MD5 and reports
+// how many files went. An empty md5 empties the whole cache.
+func (c *ConversionCache) Purge(md5 string) int {
+	md5 = strings.ToLower(md5)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	removed := 0
+	for elem := c.order.Front(); elem != nil; {
+		next := elem.Next()
+		if md5 == "" || elem.Value.(*ConversionEntry).MD5 == md5 {
+			c.removeLocked(elem)
+			removed++
+		}
+		elem = next
+	}
+	return removed
+}
@@ -0,0 +1,279 @@
+package services
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gopds-api/internal/converter"
+)
+
+// The cache serves bytes in place of a conversion, so what matters is that it
+// never serves the wrong bytes: not another book's, not another format's, not
+// those of an older converter — and that it stays within the room it was given.
+
+const (
+	md5A = "0123456789abcdef0123456789abcdef"
+	md5B = "fedcba9876543210fedcba9876543210"
+	md5C = "00112233445566778899aabbccddeeff"
+)
+
+// producing returns a producer that yields body and counts its calls.
+func producing(body string, calls *int32) ConversionProducer {
+	return func() (io.ReadCloser, error) {
+		atomic.AddInt32(calls, 1)
+		return io.NopCloser(strings.NewReader(body)), nil
+	}
+}
+
+func readConverted(t *testing.T, book *BookFile) string {
+	t.Helper()
+	defer book.Content.Close()
+	data, err := io.ReadAll(book.Content)
+	if err != nil {
+		t.Fatalf("reading converted book: %v", err)
+	}
+	return string(data)
+}
+
+func newTestConversionCache(t *testing.T, dir string, maxBytes int64) *ConversionCache {
+	t.Helper()
+	c, err := NewConversionCache(dir, maxBytes)
+	if err != nil {
+		t.Fatalf("NewConversionCache: %v", err)
+	}
+	return c
+}
+
+func TestConversionCacheConvertsOnceAndServesAfterwards(t *testing.T) {
+	c := newTestConversionCache(t, t.TempDir(), 1<<20)
+	var calls int32
+
+	for i := 0; i < 3; i++ {
+		book, err := c.Fetch(md5A, "epub", producing("epub bytes", &calls))
+		if err != nil {
+			t.Fatalf("Fetch #%d: %v", i, err)
+		}
+		if got := readConverted(t, book); got != "epub bytes" {
+			t.Fatalf("Fetch #%d served %q", i, got)
+		}
+		if book.ETag != ConversionETag(md5A, "epub") {
+			t.Errorf("ETag = %q, want %q", book.ETag, ConversionETag(md5A, "epub"))
+		}
+	}
+
+	if calls != 1 {
+		t.Errorf("converted %d times, want once", calls)
+	}
+	stats := c.Stats()
+	if stats.Hits != 2 || stats.Misses != 1 {
+		t.Errorf("hits/misses = %d/%d, want 2/1", stats.Hits, stats.Misses)
+	}
+}
+
+func TestConversionETagIgnoresMD5Case(t *testing.T) {
+	if got, want := ConversionETag(strings.ToUpper(md5A), "epub"), ConversionETag(md5A, "epub"); got != want {
+		t.Errorf("ConversionETag of the upper-case MD5 = %q, want %q", got, want)
+	}
+}
+
+func TestConversionCacheKeepsFormatsApart(t *testing.T) {
+	c := newTestConversionCache(t, t.TempDir(), 1<<20)
+	var calls int32
+
+	epub, err := c.Fetch(md5A, "epub", producing("as epub", &calls))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mobi, err := c.Fetch(md5A, "mobi", producing("as mobi", &calls))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if readConverted(t, epub) != "as epub" || readConverted(t, mobi) != "as mobi" {
+		t.Error("one format was served for the other")
+	}
+	if epub.ETag == mobi.ETag {
+		t.Error("two formats of one book share an ETag")
+	}
+}
+
+func TestConversionCacheRunsConcurrentMissesOnce(t *testing.T) {
+	c := newTestConversionCache(t, t.TempDir(), 1<<20)
+	var calls int32
+	release := make(chan struct{})
+	slow := func() (io.ReadCloser, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return io.NopCloser(strings.NewReader("slow book")), nil
+	}
+
+	const readers = 8
+	var wg sync.WaitGroup
+	errs := make(chan error, readers)
+	for i := 0; i < readers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			book, err := c.Fetch(md5A, "epub", slow)
+			if err != nil {
+				errs <- err
+				return
+			}
+			_ = book.Content.Close()
+		}()
+	}
+	// Let every reader reach the single flight before the conversion ends.
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Errorf("Fetch: %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("converted %d times for %d concurrent readers, want once", calls, readers)
+	}
+}
+
+func TestConversionCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	c := newTestConversionCache(t, t.TempDir(), 20)
+	var calls int32
+
+	for _, md5 := range []string{md5A, md5B} {
+		book, err := c.Fetch(md5, "epub", producing("0123456789", &calls))
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = book.Content.Close()
+	}
+	// Touch A, so B is now the one used longest ago.
+	book, err := c.Fetch(md5A, "epub", producing("0123456789", &calls))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = book.Content.Close()
+
+	book, err = c.Fetch(md5C, "epub", producing("0123456789", &calls))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = book.Content.Close()
+
+	if c.Contains(md5B, "epub") {
+		t.Error("the least recently used book survived the eviction")
+	}
+	if !c.Contains(md5A, "epub") || !c.Contains(md5C, "epub") {
+		t.Error("a recently used book was evicted")
+	}
+	if stats := c.Stats(); stats.Bytes > 20 || stats.Evictions != 1 {
+		t.Errorf("bytes/evictions = %d/%d, want at most 20/1", stats.Bytes, stats.Evictions)
+	}
+}
+
+func TestConversionCacheSurvivesRestartAndDropsOldVersions(t *testing.T) {
+	dir := t.TempDir()
+	c := newTestConversionCache(t, dir, 1<<20)
+	var calls int32
+	book, err := c.Fetch(md5A, "epub", producing("kept", &calls))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = book.Content.Close()
+
+	stale := filepath.Join(dir, conversionName(md5B, "epub", converter.OutputVersion+"-old"))
+	leftover := filepath.Join(dir, conversionTempPrefix+"123")
+	for _, p := range []string{stale, leftover} {
+		if err := os.WriteFile(p, []byte("x"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	reopened := newTestConversionCache(t, dir, 1<<20)
+	if !reopened.Contains(md5A, "epub") {
+		t.Error("a restart forgot a cached conversion")
+	}
+	for _, p := range []string{stale, leftover} {
+		if _, err := os.Stat(p); !os.IsNotExist(err) {
+			t.Errorf("%s survived the restart", filepath.Base(p))
+		}
+	}
+}
+
+func TestConversionCachePurge(t *testing.T) {
+	c := newTestConversionCache(t, t.TempDir(), 1<<20)
+	var calls int32
+	for _, key := range []struct{ md5, format string }{{md5A, "epub"}, {md5A, "mobi"}, {md5B, "epub"}} {
+		book, err := c.Fetch(key.md5, key.format, producing("x", &calls))
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = book.Content.Close()
+	}
+
+	if removed := c.Purge(strings.ToUpper(md5A)); removed != 2 {
+		t.Errorf("purging one book removed %d files, want 2", removed)
+	}
+	if !c.Contains(md5B, "epub") {
+		t.Error("purging one book removed another")
+	}
+	if removed := c.Purge(""); removed != 1 || len(c.Entries()) != 0 {
+		t.Errorf("purging everything removed %d and left %d", removed, len(c.Entries()))
+	}
+}
+
+func TestConversionCacheDoesNotKeepFailures(t *testing.T) {
+	c := newTestConversionCache(t, t.TempDir(), 1<<20)
+	boom := errors.New("kindlegen exploded")
+
+	_, err := c.Fetch(md5A, "mobi", func() (io.ReadCloser, error) { return nil, boom })
+	if !errors.Is(err, boom) {
+		t.Fatalf("err = %v, want the producer's error", err)
+	}
+	if c.Contains(md5A, "mobi") {
+		t.Error("a failed conversion was cached")
+	}
+}
+
+// A book without a usable MD5 cannot be keyed. It is still converted — just
+// every time — and nothing with a made-up name lands on disk.
+func TestConversionCacheConvertsUnkeyableBooksUncached(t *testing.T) {
+	dir := t.TempDir()
+	c := newTestConversionCache(t, dir, 1<<20)
+	var calls int32
+
+	for i := 0; i < 2; i++ {
+		book, err := c.Fetch("../../etc/passwd", "epub", producing("body", &calls))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if book.ETag != "" {
+			t.Errorf("an unkeyed conversion carries ETag %q", book.ETag)
+		}
+		_ = readConverted(t, book)
+	}
+	if calls != 2 {
+		t.Errorf("converted %d times, want every time", calls)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("an unkeyed conversion left %d files behind", len(entries))
+	}
+}
+
+func TestConversionCacheRefusesUnknownFormats(t *testing.T) {
+	var c *ConversionCache
+	var calls int32
+	if _, err := c.Fetch(md5A, "pdf", producing("x", &calls)); !errors.Is(err, ErrUnsupportedConversion) {
+		t.Errorf("err = %v, want ErrUnsupportedConversion", err)
+	}
+	if calls != 0 {
+		t.Error("an unsupported format reached the converter")
+	}
+}
@@ -0,0 +1,80 @@
+package services
+
+import (
+	"context"
+	"os"
+
+	"gopds-api/database"
+	"gopds-api/internal/posters"
+	"gopds-api/internal/safeio"
+	"gopds-api/logging"
+	"gopds-api/models"
+)
+
+// coverPlaceholderBatch is how many books the backfill reads per query.
+const coverPlaceholderBatch = 200
+
+// coverPlaceholder computes the placeholder of a cover about to be saved. A
+// cover that does not decode gets the empty one, which is recorded as such
+// instead of being retried.
+func coverPlaceholder(cover []byte) posters.Placeholder {
+	p, err := posters.PlaceholderOf(cover)
+	if err != nil {
+		logging.Debugf("No cover placeholder: %v", err)
+		return posters.Placeholder{}
+	}
+	return p
+}
+
+// BackfillCoverPlaceholders computes the placeholder of every book with a
+// cover that has none yet — books scanned before placeholders existed — from
+// the poster files under postersDir. It walks the table in id order, so a
+// poster that cannot be read is recorded as empty and never blocks the rest.
+func BackfillCoverPlaceholders(ctx context.Context, postersDir string) {
+	db := database.GetDB()
+	var afterID int64
+	filled := 0
+	for ctx.Err() == nil {
+		var books []models.Book
+		err := db.ModelContext(ctx, &books).
+			Column("id", "path", "filename").
+			Where("cover AND cover_blurhash IS NULL").
+			Where("id > ?", afterID).
+			Order("id").
+			Limit(coverPlaceholderBatch).
+			Select()
+		if err != nil {
+			logging.Errorf("Cover placeholder backfill: %v", err)
+			return
+		}
+		if len(books) == 0 {
+			break
+		}
+		for _, book := range books {
+			afterID = book.ID
+			var p posters.Placeholder
+			// #nosec G304 -- the path is built by posters.FilePath from the
+			// configured posters directory and sanitized catalog fields.
+			if f, err := os.Open(posters.FilePath(postersDir, book.Path, book.FileName)); err == nil {
+				data, readErr := safeio.ReadAll(f, safeio.MaxBookBytes)
+				_ = f.Close()
+				if readErr == nil {
+					p = coverPlaceholder(data)
+				}
+			}
+			_, err := db.ModelContext(ctx, &models.Book{}).
+				Set("cover_blurhash = ?", p.Blurhash).
+				Set("cover_color = ?", p.Color).
+				Where("id = ?", book.ID).
+				Update()
+			if err != nil {
+				logging.Errorf("Cover placeholder backfill: book %d: %v", book.ID, err)
+				return
+			}
+			filled++
+		}
+	}
+	if filled > 0 {
+		logging.Infof("Cover placeholder backfill: %d books done", filled)
+	}
+}
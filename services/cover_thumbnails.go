@@ -0,0 +1,274 @@
+package services
+
+// cover_thumbnails.go scales covers down on demand. A poster is stored once,
+// at whatever size the book carried it; a list of twenty books then costs
+// twenty full-size downloads when what is drawn is twenty stamps. Thumbnails
+// are made the first time a width is asked for and kept beside each other in
+// their own directory, named after the cover's size and modification time so
+// a replaced cover is never answered with the old picture.
+//
+// JPEG is always available. WebP and AVIF are offered when cwebp and avifenc
+// are installed — the standard library and x/image encode neither — and are
+// chosen only for clients whose Accept header asks for them.
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"mime"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+
+	"gopds-api/internal/posters"
+	"gopds-api/internal/safeio"
+	"gopds-api/internal/safepath"
+	"gopds-api/logging"
+)
+
+// ErrNoCover reports a thumbnail asked for a cover that is not on disk.
+var ErrNoCover = errors.New("cover not found")
+
+// thumbnailEncodeTimeout bounds one run of an external encoder. A thumbnail
+// is a few hundred pixels wide; anything slower than this is stuck.
+const thumbnailEncodeTimeout = 30 * time.Second
+
+// thumbnailFormat is one format a thumbnail can be served in.
+type thumbnailFormat struct {
+	name        string
+	contentType string
+	encode      func(ctx context.Context, img image.Image, dst string) error
+}
+
+// CoverThumbnail is one thumbnail ready to be served from Path.
+type CoverThumbnail struct {
+	Path        string
+	ContentType string
+	ModTime     time.Time
+	ETag        string
+}
+
+// CoverThumbnails makes and keeps scaled copies of the covers in a posters
+// directory.
+type CoverThumbnails struct {
+	postersDir string
+	dir        string
+	// formats in order of preference; JPEG is last and always present.
+	formats []thumbnailFormat
+
+	sf singleflight.Group
+}
+
+// NewCoverThumbnails keeps thumbnails of the covers under postersDir in dir,
+// creating it when missing.
+func NewCoverThumbnails(postersDir, dir string) (*CoverThumbnails, error) {
+	if strings.TrimSpace(dir) == "" {
+		return nil, errors.New("cover thumbnails: no directory configured")
+	}
+	if err := os.MkdirAll(dir, safeio.DirMode); err != nil {
+		return nil, fmt.Errorf("cover thumbnails: creating %s: %w", dir, err)
+	}
+	t := &CoverThumbnails{postersDir: postersDir, dir: dir}
+	if path, err := exec.LookPath("avifenc"); err == nil {
+		t.formats = append(t.formats, thumbnailFormat{"avif", "image/avif", externalEncoder(path, "avifenc")})
+	}
+	if path, err := exec.LookPath("cwebp"); err == nil {
+		t.formats = append(t.formats, thumbnailFormat{"webp", "image/webp", externalEncoder(path, "cwebp")})
+	}
+	t.formats = append(t.formats, thumbnailFormat{"jpeg", "image/jpeg", encodeJPEGThumbnail})
+	return t, nil
+}
+
+// Formats lists the formats thumbnails can be served in, preferred first.
+func (t *CoverThumbnails) Formats() []string {
+	names := make([]string, len(t.formats))
+	for i, f := range t.formats {
+		names[i] = f.name
+	}
+	return names
+}
+
+// Thumbnail returns the cover at relPath under the posters directory scaled
+// to width (snapped to posters.ThumbnailWidths), in the best format accept
+// allows, making it first if it has not been made yet.
+func (t *CoverThumbnails) Thumbnail(relPath string, width int, accept string) (*CoverThumbnail, error) {
+	src, err := safepath.Resolve(t.postersDir, relPath)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(src)
+	if err != nil || info.IsDir() {
+		return nil, ErrNoCover
+	}
+	width = posters.SnapWidth(width)
+	format := t.negotiate(accept)
+
+	// The stamp is what ties a thumbnail to the cover it was made from.
+	stamp := strconv.FormatInt(info.ModTime().UnixNano(), 36) + "-" + strconv.FormatInt(info.Size(), 36)
+	rel, err := filepath.Rel(t.postersDir, src)
+	if err != nil {
+		return nil, err
+	}
+	prefix := filepath.Join(t.dir, strconv.Itoa(width), rel) + "."
+	path := prefix + stamp + "." + format.name
+
+	thumb := &CoverThumbnail{
+		Path:        path,
+		ContentType: format.contentType,
+		ModTime:     info.ModTime(),
+		ETag:        fmt.Sprintf(`"%s-%d-%s"`, stamp, width, format.name),
+	}
+	if _, err := os.Stat(path); err == nil {
+		return thumb, nil
+	}
+	_, err, _ = t.sf.Do(path, func() (interface{}, error) {
+		if _, err := os.Stat(path); err == nil {
+			return nil, nil
+		}
+		return nil, t.render(src, prefix, stamp, width, format)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return thumb, nil
+}
+
+// negotiate picks the first format, in order of preference, that accept
+// names with a non-zero quality. A wildcard does not count: the feeds
+// advertise thumbnails as JPEG, and a client that merely accepts anything
+// gets what it was told to expect.
+func (t *CoverThumbnails) negotiate(accept string) thumbnailFormat {
+	accepted := make(map[string]bool)
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		if q, ok := params["q"]; ok {
+			if v, err := strconv.ParseFloat(q, 64); err != nil || v <= 0 {
+				continue
+			}
+		}
+		accepted[mediaType] = true
+	}
+	for _, f := range t.formats {
+		if accepted[f.contentType] {
+			return f
+		}
+	}
+	return t.formats[len(t.formats)-1]
+}
+
+// render scales the cover at src and writes it under prefix and stamp,
+// removing thumbnails of the same cover at the same width made from an
+// earlier version of it.
+func (t *CoverThumbnails) render(src, prefix, stamp string, width int, format thumbnailFormat) error {
+	path := prefix + stamp + "." + format.name
+	// #nosec G304 -- src comes from safepath.Resolve against the posters
+	// directory.
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("decoding cover %s: %w", src, err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), safeio.DirMode); err != nil {
+		return err
+	}
+
+	// The extension stays last: the external encoders go by it.
+	tmp, err := os.CreateTemp(filepath.Dir(path), conversionTempPrefix+"*."+format.name)
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+	_ = tmp.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), thumbnailEncodeTimeout)
+	defer cancel()
+	if err := format.encode(ctx, posters.Thumbnail(img, width), tmpName); err != nil {
+		_ = os.Remove(tmpName)
+		return fmt.Errorf("encoding %s thumbnail: %w", format.name, err)
+	}
+	if err := os.Rename(tmpName, path); err != nil {
+		_ = os.Remove(tmpName)
+		return err
+	}
+
+	removeStaleThumbnails(prefix, stamp)
+	return nil
+}
+
+// removeStaleThumbnails deletes the files starting with prefix whose stamp is
+// not the current one. Other formats of the current stamp stay.
+func removeStaleThumbnails(prefix, stamp string) {
+	entries, err := os.ReadDir(filepath.Dir(prefix))
+	if err != nil {
+		return
+	}
+	base := filepath.Base(prefix)
+	for _, e := range entries {
+		name := e.Name()
+		if !strings.HasPrefix(name, base) || strings.HasPrefix(name, base+stamp+".") {
+			continue
+		}
+		if err := os.Remove(filepath.Join(filepath.Dir(prefix), name)); err != nil && !os.IsNotExist(err) {
+			logging.Warnf("Cover thumbnails: could not remove %s: %v", name, err)
+		}
+	}
+}
+
+func encodeJPEGThumbnail(_ context.Context, img image.Image, dst string) error {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 80}); err != nil {
+		return err
+	}
+	return os.WriteFile(dst, buf.Bytes(), safeio.FileMode)
+}
+
+// externalEncoder runs an installed encoder over a PNG of the thumbnail.
+func externalEncoder(binary, kind string) func(ctx context.Context, img image.Image, dst string) error {
+	return func(ctx context.Context, img image.Image, dst string) error {
+		in := dst + ".png"
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, img); err != nil {
+			return err
+		}
+		if err := os.WriteFile(in, buf.Bytes(), safeio.FileMode); err != nil {
+			return err
+		}
+		defer removeTmpThumbnail(in)
+
+		var args []string
+		switch kind {
+		case "cwebp":
+			args = []string{"-quiet", "-q", "80", in, "-o", dst}
+		default:
+			args = []string{"-q", "60", in, dst}
+		}
+		// #nosec G204 -- binary is cwebp or avifenc as found on PATH at
+		// startup; the arguments are fixed flags and paths this process made.
+		out, err := exec.CommandContext(ctx, binary, args...).CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("%s: %w: %s", kind, err, strings.TrimSpace(string(out)))
+		}
+		return nil
+	}
+}
+
+func removeTmpThumbnail(path string) {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		logging.Warnf("Cover thumbnails: could not remove %s: %v", path, err)
+	}
+}
@@ -0,0 +1,152 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeTestCover writes a w×h JPEG poster at rel under dir.
+func writeTestCover(t *testing.T, dir, rel string, w, h int) {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 90, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, nil); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, rel)
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func decodeThumbnail(t *testing.T, path string) image.Image {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("thumbnail does not decode: %v", err)
+	}
+	return img
+}
+
+// jpegOnly is the store as it is on a machine without cwebp and avifenc,
+// which these tests must not depend on.
+func jpegOnly(t *testing.T, postersDir string) *CoverThumbnails {
+	t.Helper()
+	thumbs, err := NewCoverThumbnails(postersDir, t.TempDir())
+	if err != nil {
+		t.Fatalf("NewCoverThumbnails: %v", err)
+	}
+	thumbs.formats = thumbs.formats[len(thumbs.formats)-1:]
+	return thumbs
+}
+
+func TestCoverThumbnailIsScaledToTheSnappedWidth(t *testing.T) {
+	posters := t.TempDir()
+	writeTestCover(t, posters, "lib/a-zip/1-fb2.jpg", 600, 900)
+	thumbs := jpegOnly(t, posters)
+
+	thumb, err := thumbs.Thumbnail("/lib/a-zip/1-fb2.jpg", 150, "image/webp,*/*")
+	if err != nil {
+		t.Fatalf("Thumbnail: %v", err)
+	}
+	if thumb.ContentType != "image/jpeg" {
+		t.Errorf("ContentType = %q without a WebP encoder", thumb.ContentType)
+	}
+	if b := decodeThumbnail(t, thumb.Path).Bounds(); b.Dx() != 200 || b.Dy() != 300 {
+		t.Errorf("thumbnail is %dx%d, want 200x300", b.Dx(), b.Dy())
+	}
+
+	again, err := thumbs.Thumbnail("/lib/a-zip/1-fb2.jpg", 200, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.Path != thumb.Path || again.ETag != thumb.ETag {
+		t.Error("the same width was made twice under different names")
+	}
+}
+
+// A replaced cover gets a new thumbnail, and the old one goes.
+func TestCoverThumbnailFollowsAReplacedCover(t *testing.T) {
+	posters := t.TempDir()
+	writeTestCover(t, posters, "a.jpg", 600, 900)
+	thumbs := jpegOnly(t, posters)
+
+	old, err := thumbs.Thumbnail("a.jpg", 96, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeTestCover(t, posters, "a.jpg", 800, 400)
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(filepath.Join(posters, "a.jpg"), later, later); err != nil {
+		t.Fatal(err)
+	}
+
+	fresh, err := thumbs.Thumbnail("a.jpg", 96, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fresh.ETag == old.ETag {
+		t.Error("a replaced cover kept its thumbnail's ETag")
+	}
+	if b := decodeThumbnail(t, fresh.Path).Bounds(); b.Dx() != 96 || b.Dy() != 48 {
+		t.Errorf("thumbnail is %dx%d, want the new cover's 96x48", b.Dx(), b.Dy())
+	}
+	if _, err := os.Stat(old.Path); !os.IsNotExist(err) {
+		t.Error("the thumbnail of the old cover was left behind")
+	}
+}
+
+func TestCoverThumbnailRefusesWhatIsNotACover(t *testing.T) {
+	thumbs := jpegOnly(t, t.TempDir())
+	if _, err := thumbs.Thumbnail("missing.jpg", 96, ""); !errors.Is(err, ErrNoCover) {
+		t.Errorf("err = %v, want ErrNoCover", err)
+	}
+	if _, err := thumbs.Thumbnail("../../etc/passwd", 96, ""); err == nil {
+		t.Error("a path outside the posters directory was accepted")
+	}
+}
+
+// Negotiation goes by what the client names, preferred format first; a
+// wildcard or an explicit q=0 does not earn a format the feed did not
+// advertise.
+func TestCoverThumbnailNegotiation(t *testing.T) {
+	never := func(context.Context, image.Image, string) error { return errors.New("unused") }
+	thumbs := &CoverThumbnails{formats: []thumbnailFormat{
+		{"avif", "image/avif", never},
+		{"webp", "image/webp", never},
+		{"jpeg", "image/jpeg", never},
+	}}
+	cases := map[string]string{
+		"":                                 "jpeg",
+		"*/*":                              "jpeg",
+		"image/webp,*/*;q=0.8":             "webp",
+		"image/avif,image/webp,image/*":    "avif",
+		"image/avif;q=0, image/webp;q=0.5": "webp",
+		"text/html, image/png":             "jpeg",
+	}
+	for accept, want := range cases {
+		if got := thumbs.negotiate(accept).name; got != want {
+			t.Errorf("negotiate(%q) = %s, want %s", accept, got, want)
+		}
+	}
+}
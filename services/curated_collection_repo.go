@@ -43,6 +43,14 @@ func (TrigramCandidateFinder) FindCandidates(ctx context.Context, authorNorm, ti
 	return database.FindCollectionCandidates(ctx, authorNorm, titleNorm)
 }
 
+// VisibleCandidateFinder adapts database.FindVisibleBookCandidates to
+// CandidateFinder.
+type VisibleCandidateFinder struct{}
+
+func (VisibleCandidateFinder) FindCandidates(ctx context.Context, authorNorm, titleNorm string) ([]models.MatchCandidate, error) {
+	return database.FindVisibleBookCandidates(ctx, authorNorm, titleNorm)
+}
+
 // NewCuratedMatcher wires DecisionLookup and CandidateFinder backed by the real db
 // into a Matcher ready for use by Import.
 func NewCuratedMatcher() Matcher {
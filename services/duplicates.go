@@ -2,18 +2,26 @@ package services
 
 import (
 	"archive/zip"
+	"bytes"
 	"context"
 	// #nosec G501 -- MD5 is used below as a content fingerprint for finding
 	// duplicate books, never to protect anything. Collision resistance is
 	// not a property this depends on.
 	"crypto/md5"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"io"
 	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 
+	"gopds-api/internal/parser"
 	"gopds-api/internal/safeio"
 	"gopds-api/logging"
 	"gopds-api/models"
@@ -33,7 +41,9 @@ type DuplicateScanProgress struct {
 	ProcessedBooks  int    `json:"processed_books"`
 	TotalBooks      int    `json:"total_books"`
 	DuplicatesFound int    `json:"duplicates_found"`
-	Error           string `json:"error,omitempty"`
+	// FuzzyGroups is how many near-duplicate groups the scan left for review.
+	FuzzyGroups int    `json:"fuzzy_groups"`
+	Error       string `json:"error,omitempty"`
 }
 
 // DuplicateGroup represents a group of duplicate books
@@ -51,8 +61,22 @@ type HideResult struct {
 }
 
 const (
-	batchSize      = 50        // Send WebSocket updates every N books
-	hashBufferSize = 64 * 1024 // 64KB buffer for hashing
+	batchSize = 50 // Send WebSocket updates every N books
+
+	// fingerprintSampleBytes is how much of a book the parser reads for its
+	// SimHash. The body sample is the first few kilobytes of text, which
+	// follow the description; the rest of a large FB2 is mostly more text
+	// and embedded images, and only the MD5 needs those.
+	fingerprintSampleBytes = 1 << 20
+)
+
+var (
+	// ErrCandidateNotFound reports a duplicate candidate id that does not exist.
+	ErrCandidateNotFound = errors.New("duplicate candidate not found")
+	// ErrCandidateReviewed reports a candidate already resolved or dismissed.
+	ErrCandidateReviewed = errors.New("duplicate candidate already reviewed")
+	// ErrWinnerNotInGroup reports a winner that is not one of the group's books.
+	ErrWinnerNotInGroup = errors.New("winner is not in the group")
 )
 
 var (
@@ -62,8 +86,10 @@ var (
 	cancelMap = make(map[int64]context.CancelFunc)
 )
 
-// ScanDuplicates scans all books and computes MD5 hashes for duplicate detection
-func ScanDuplicates(ctx context.Context, db *pg.DB, jobID int64, wsConn WebSocketConnection, filesPath string, workers int) error {
+// ScanDuplicates scans all books, computes the MD5 hashes and text SimHashes
+// still missing, and then proposes groups of near-duplicates scoring at least
+// fuzzyThreshold for review
+func ScanDuplicates(ctx context.Context, db *pg.DB, jobID int64, wsConn WebSocketConnection, filesPath string, workers int, fuzzyThreshold float64) error {
 	logging.Infof("Starting duplicate scan job %d", jobID)
 
 	// Update job status to running
@@ -93,7 +119,7 @@ func ScanDuplicates(ctx context.Context, db *pg.DB, jobID int64, wsConn WebSocke
 	// Get all books (both approved and unapproved)
 	var books []models.Book
 	err = db.Model(&books).
-		Column("id", "path", "filename", "md5", "title").
+		Column("id", "path", "filename", "md5", "text_simhash", "title").
 		Order("id ASC").
 		Select()
 	if err != nil {
@@ -133,20 +159,21 @@ func ScanDuplicates(ctx context.Context, db *pg.DB, jobID int64, wsConn WebSocke
 					return
 				}
 
-				if book.MD5 == "" {
+				if book.MD5 == "" || book.TextSimhash == nil {
 					filePath := filepath.Join(filesPath, book.Path)
-					hash, hashErr := computeBookMD5(filePath, book.FileName)
+					fp, hashErr := computeBookFingerprint(filePath, book.FileName)
 					if hashErr != nil {
-						logging.Warnf("Failed to compute MD5 for book ID %d (%s/%s): %v", book.ID, filePath, book.FileName, hashErr)
+						logging.Warnf("Failed to fingerprint book ID %d (%s/%s): %v", book.ID, filePath, book.FileName, hashErr)
 						atomic.AddInt64(&errorCount, 1)
 					} else {
-						_, err = db.Model(&models.Book{}).
-							Set("md5 = ?", hash).
+						_, err := db.Model(&models.Book{}).
+							Set("md5 = ?", fp.MD5).
+							Set("text_simhash = ?", fp.Simhash).
 							Set("registerdate = registerdate"). // Preserve registerdate
 							Where("id = ?", book.ID).
 							Update()
 						if err != nil {
-							logging.Errorf("Failed to update MD5 for book ID %d: %v", book.ID, err)
+							logging.Errorf("Failed to update fingerprint for book ID %d: %v", book.ID, err)
 							atomic.AddInt64(&errorCount, 1)
 						}
 					}
@@ -207,6 +234,13 @@ func ScanDuplicates(ctx context.Context, db *pg.DB, jobID int64, wsConn WebSocke
 		logging.Warnf("Failed to calculate duplicates after scan: %v", err)
 	}
 
+	// The near-duplicate pass only proposes; nothing is hidden until an
+	// admin resolves a group.
+	fuzzyGroups, err := scanFuzzyDuplicates(ctx, db, jobID, fuzzyThreshold)
+	if err != nil {
+		logging.Warnf("Near-duplicate pass failed: %v", err)
+	}
+
 	// Mark job as completed
 	finishedAt := time.Now()
 	_, err = db.Model(&models.AdminScanJob{}).
@@ -229,21 +263,32 @@ func ScanDuplicates(ctx context.Context, db *pg.DB, jobID int64, wsConn WebSocke
 			ProcessedBooks:  processedBooks,
 			TotalBooks:      totalBooks,
 			DuplicatesFound: duplicatesFound,
+			FuzzyGroups:     fuzzyGroups,
 		})
 	}
 
-	logging.Infof("Duplicate scan completed. Processed: %d, Duplicates: %d, Errors: %d", processedBooks, duplicatesFound, atomic.LoadInt64(&errorCount))
+	logging.Infof("Duplicate scan completed. Processed: %d, Duplicates: %d, Near-duplicate groups: %d, Errors: %d", processedBooks, duplicatesFound, fuzzyGroups, atomic.LoadInt64(&errorCount))
 	return nil
 }
 
-// computeBookMD5 computes MD5 hash of a book file stored inside a zip archive.
-func computeBookMD5(zipPath string, filename string) (string, error) {
+// bookFingerprint is what a duplicate scan computes from a book's file.
+type bookFingerprint struct {
+	MD5     string
+	Simhash int64
+}
+
+// computeBookFingerprint reads a book file stored inside a zip archive once
+// for both its MD5 and the SimHash of its body sample. Only the first
+// fingerprintSampleBytes are held in memory, for the parser; the rest streams
+// into the hash. A file the parser cannot read still gets its MD5, with a
+// SimHash of 0: no text to compare.
+func computeBookFingerprint(zipPath string, filename string) (bookFingerprint, error) {
 	if filename == "" {
-		return "", errors.New("missing filename")
+		return bookFingerprint{}, errors.New("missing filename")
 	}
 	reader, err := zip.OpenReader(zipPath)
 	if err != nil {
-		return "", err
+		return bookFingerprint{}, err
 	}
 	defer func() {
 		if closeErr := reader.Close(); closeErr != nil {
@@ -259,12 +304,12 @@ func computeBookMD5(zipPath string, filename string) (string, error) {
 		}
 	}
 	if target == nil {
-		return "", errors.New("book not found in archive")
+		return bookFingerprint{}, errors.New("book not found in archive")
 	}
 
 	rc, err := target.Open()
 	if err != nil {
-		return "", err
+		return bookFingerprint{}, err
 	}
 	defer func() {
 		if closeErr := rc.Close(); closeErr != nil {
@@ -274,16 +319,39 @@ func computeBookMD5(zipPath string, filename string) (string, error) {
 
 	// #nosec G401 -- a fingerprint for duplicate detection, see the import.
 	hash := md5.New()
-	buffer := make([]byte, hashBufferSize)
-
-	// Bounded like every other read out of an archive: hashing a bomb costs
-	// time rather than disk, but it costs it on the scan worker.
-	_, err = safeio.CopyBuffer(hash, rc, buffer, safeio.MaxBookBytes)
+	sample, err := io.ReadAll(io.LimitReader(io.TeeReader(rc, hash), fingerprintSampleBytes))
+	if err != nil {
+		return bookFingerprint{}, err
+	}
+	// Bounded like every other read out of an archive: a bomb would cost
+	// time here, and it would cost it on the scan worker.
+	rest, err := safeio.Copy(hash, rc, safeio.MaxBookBytes-int64(len(sample)))
 	if err != nil {
-		return "", err
+		return bookFingerprint{}, err
+	}
+
+	fp := bookFingerprint{MD5: hex.EncodeToString(hash.Sum(nil))}
+	if rest > 0 {
+		sample = closeFB2Sample(sample)
+	}
+	if parsed, err := parser.NewFB2Parser(false).Parse(bytes.NewReader(sample)); err == nil {
+		fp.Simhash = int64(SimHash(parsed.BodySample)) // #nosec G115 -- stored bit for bit
 	}
+	return fp, nil
+}
 
-	return fmt.Sprintf("%x", hash.Sum(nil)), nil
+// closeFB2Sample turns the start of an FB2 into a document the parser reads
+// as it reads the whole book: cut after the last complete tag, so no tag,
+// entity or character is left half-read, and close the root, which closes
+// every element still open. A parser given the cut-off text as it is would
+// fail and fall back to a coarser body sample, and the SimHash would no
+// longer match the one the scan stored for the same text.
+func closeFB2Sample(sample []byte) []byte {
+	end := bytes.LastIndexByte(sample, '>')
+	if end == -1 {
+		return sample
+	}
+	return append(sample[:end+1:end+1], "</FictionBook>"...)
 }
 
 // updateJobProgress updates the progress of a scan job
@@ -360,9 +428,9 @@ func GetDuplicateGroups(_ context.Context, db *pg.DB) ([]DuplicateGroup, error)
 	return groups, nil
 }
 
-// HideDuplicates hides duplicate books based on the "Newest ID" rule
-func HideDuplicates(_ context.Context, db *pg.DB) (*HideResult, error) {
-	logging.Info("Starting to hide duplicates")
+// HideDuplicates hides every book of each MD5 group but the one policy picks
+func HideDuplicates(ctx context.Context, db *pg.DB, policy DuplicateWinnerPolicy, filesPath string) (*HideResult, error) {
+	logging.Infof("Starting to hide duplicates (winner policy %s)", policy)
 
 	// Get all duplicate groups
 	type duplicateHash struct {
@@ -386,40 +454,32 @@ func HideDuplicates(_ context.Context, db *pg.DB) (*HideResult, error) {
 
 	// Process each duplicate group
 	for _, h := range hashes {
-		// Get all books with this hash, ordered by ID DESC
-		var books []models.Book
-		err := db.Model(&books).
+		var ids []int64
+		err := db.Model(&models.Book{}).
 			Column("id").
 			Where("md5 = ?", h.MD5).
-			Order("id DESC"). // Newest first
-			Select()
+			Select(&ids)
 		if err != nil {
 			logging.Warnf("Failed to fetch books for hash %s: %v", h.MD5, err)
 			continue
 		}
 
-		if len(books) <= 1 {
+		if len(ids) <= 1 {
 			skippedEmpty++
 			continue
 		}
 
-		// First book (highest ID) is the winner - keep it visible
-		winnerID := books[0].ID
-
-		// Hide all other books
-		for i := 1; i < len(books); i++ {
-			_, err := db.Model(&models.Book{}).
-				Set("duplicate_hidden = ?", true).
-				Set("duplicate_of_id = ?", winnerID).
-				Set("registerdate = registerdate"). // Preserve registerdate
-				Where("id = ?", books[i].ID).
-				Update()
-			if err != nil {
-				logging.Errorf("Failed to hide book ID %d: %v", books[i].ID, err)
-				continue
-			}
-			hiddenCount++
+		winnerID, err := chooseWinnerOf(ctx, db, ids, policy, filesPath)
+		if err != nil {
+			logging.Warnf("Failed to choose a winner for hash %s: %v", h.MD5, err)
+			continue
 		}
+		hidden, err := hideAllBut(ctx, db, winnerID, ids)
+		if err != nil {
+			logging.Warnf("Failed to hide duplicates for hash %s: %v", h.MD5, err)
+			continue
+		}
+		hiddenCount += hidden
 	}
 
 	logging.Infof("Hidden %d duplicate books, skipped %d empty groups", hiddenCount, skippedEmpty)
@@ -429,6 +489,342 @@ func HideDuplicates(_ context.Context, db *pg.DB) (*HideResult, error) {
 	}, nil
 }
 
+// hideAllBut hides the books of ids other than winnerID as its duplicates and
+// makes sure the winner itself is visible: an earlier run under another policy
+// may have hidden it. It returns how many books it hid.
+func hideAllBut(ctx context.Context, db pg.DBI, winnerID int64, ids []int64) (int, error) {
+	_, err := db.ModelContext(ctx, &models.Book{}).
+		Set("duplicate_hidden = ?", false).
+		Set("duplicate_of_id = NULL").
+		Set("registerdate = registerdate"). // Preserve registerdate
+		Where("id = ?", winnerID).
+		Update()
+	if err != nil {
+		return 0, fmt.Errorf("unhiding winner book %d: %w", winnerID, err)
+	}
+
+	losers := make([]int64, 0, len(ids))
+	for _, id := range ids {
+		if id != winnerID {
+			losers = append(losers, id)
+		}
+	}
+	if len(losers) == 0 {
+		return 0, nil
+	}
+	res, err := db.ModelContext(ctx, &models.Book{}).
+		Set("duplicate_hidden = ?", true).
+		Set("duplicate_of_id = ?", winnerID).
+		Set("registerdate = registerdate"). // Preserve registerdate
+		Where("id IN (?)", pg.In(losers)).
+		Update()
+	if err != nil {
+		return 0, fmt.Errorf("hiding the duplicates of book %d: %w", winnerID, err)
+	}
+	return res.RowsAffected(), nil
+}
+
+// chooseWinnerOf loads what policy compares about the books of ids and
+// returns the one it picks.
+func chooseWinnerOf(ctx context.Context, db *pg.DB, ids []int64, policy DuplicateWinnerPolicy, filesPath string) (int64, error) {
+	if policy == WinnerHighestID {
+		candidates := make([]WinnerCandidate, len(ids))
+		for i, id := range ids {
+			candidates[i] = WinnerCandidate{ID: id}
+		}
+		return ChooseDuplicateWinner(policy, candidates), nil
+	}
+
+	var books []models.Book
+	err := db.ModelContext(ctx, &books).
+		Relation("Authors").
+		Relation("Series").
+		Relation("Genres").
+		WhereIn("book.id IN (?)", ids).
+		Select()
+	if err != nil {
+		return 0, err
+	}
+	if len(books) == 0 {
+		return 0, pg.ErrNoRows
+	}
+	candidates := make([]WinnerCandidate, len(books))
+	for i := range books {
+		candidates[i] = WinnerCandidate{
+			ID:           books[i].ID,
+			RegisterDate: books[i].RegisterDate,
+			Cover:        books[i].Cover,
+			Metadata:     metadataScore(&books[i]),
+		}
+		if policy == WinnerLargest {
+			candidates[i].Size = bookFileSize(filesPath, &books[i])
+		}
+	}
+	return ChooseDuplicateWinner(policy, candidates), nil
+}
+
+// metadataScore counts the descriptive fields a book has filled in: an
+// annotation, a date, a language, a cover, a series, genres and a named
+// author. Two copies of one work differ mostly in how well they were tagged.
+func metadataScore(book *models.Book) int {
+	score := 0
+	for _, filled := range []bool{
+		strings.TrimSpace(book.Annotation) != "",
+		strings.TrimSpace(book.DocDate) != "",
+		book.Lang != "",
+		book.Cover,
+		len(book.Series) > 0,
+		len(book.Genres) > 0,
+	} {
+		if filled {
+			score++
+		}
+	}
+	for _, a := range book.Authors {
+		if duplicateAuthorKey(a.FullName) != "" {
+			score++
+			break
+		}
+	}
+	return score
+}
+
+// bookFileSize returns the uncompressed size of a book's file inside its
+// archive, or 0 when the archive cannot be read.
+func bookFileSize(filesPath string, book *models.Book) int64 {
+	zipPath := filepath.Join(filesPath, book.Path)
+	reader, err := zip.OpenReader(zipPath)
+	if err != nil {
+		return 0
+	}
+	defer func() {
+		if closeErr := reader.Close(); closeErr != nil {
+			logging.Warnf("Failed to close zip %s: %v", zipPath, closeErr)
+		}
+	}()
+	for _, f := range reader.File {
+		if f.Name == book.FileName {
+			return int64(f.UncompressedSize64) // #nosec G115 -- bounded by the archive format
+		}
+	}
+	return 0
+}
+
+// scanFuzzyDuplicates replaces the pending near-duplicate candidates with the
+// groups found among the visible books now. A group an admin already resolved
+// or dismissed is not proposed again as long as it has the same books.
+func scanFuzzyDuplicates(ctx context.Context, db *pg.DB, jobID int64, threshold float64) (int, error) {
+	type fuzzyRow struct {
+		ID          int64    `pg:"id"`
+		Title       string   `pg:"title"`
+		MD5         string   `pg:"md5"`
+		TextSimhash *int64   `pg:"text_simhash"`
+		Authors     []string `pg:"authors,array"`
+	}
+	var rows []fuzzyRow
+	err := db.ModelContext(ctx, &models.Book{}).
+		ColumnExpr("book.id, book.title, book.md5, book.text_simhash").
+		ColumnExpr("ARRAY_AGG(a.full_name) FILTER (WHERE a.full_name IS NOT NULL) AS authors").
+		Join("LEFT JOIN opds_catalog_bauthor AS ba ON ba.book_id = book.id").
+		Join("LEFT JOIN opds_catalog_author AS a ON a.id = ba.author_id").
+		Where("NOT book.duplicate_hidden").
+		Group("book.id").
+		Select(&rows)
+	if err != nil {
+		return 0, err
+	}
+	books := make([]FuzzyBook, len(rows))
+	for i, r := range rows {
+		books[i] = FuzzyBook{ID: r.ID, Title: r.Title, Authors: r.Authors, MD5: r.MD5}
+		if r.TextSimhash != nil {
+			books[i].Simhash = uint64(*r.TextSimhash) // #nosec G115 -- stored bit for bit
+		}
+	}
+	groups := FindFuzzyDuplicates(books, threshold)
+
+	var reviewed []models.DuplicateCandidate
+	err = db.ModelContext(ctx, &reviewed).
+		Column("book_ids").
+		Where("status != ?", models.DuplicateCandidatePending).
+		Select()
+	if err != nil {
+		return 0, err
+	}
+	seen := make(map[string]bool, len(reviewed))
+	for _, r := range reviewed {
+		seen[bookSetKey(r.BookIDs)] = true
+	}
+
+	candidates := make([]models.DuplicateCandidate, 0, len(groups))
+	for _, g := range groups {
+		if seen[bookSetKey(g.BookIDs)] {
+			continue
+		}
+		job := jobID
+		candidates = append(candidates, models.DuplicateCandidate{
+			JobID:    &job,
+			MatchKey: g.Key,
+			Score:    g.Score,
+			BookIDs:  g.BookIDs,
+			Status:   models.DuplicateCandidatePending,
+		})
+	}
+
+	err = db.RunInTransaction(ctx, func(tx *pg.Tx) error {
+		if _, err := tx.Model((*models.DuplicateCandidate)(nil)).
+			Where("status = ?", models.DuplicateCandidatePending).
+			Delete(); err != nil {
+			return err
+		}
+		if len(candidates) == 0 {
+			return nil
+		}
+		_, err := tx.Model(&candidates).Insert()
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	logging.Infof("Near-duplicate pass: %d books, %d groups for review", len(books), len(candidates))
+	return len(candidates), nil
+}
+
+// bookSetKey identifies a set of book ids regardless of their order.
+func bookSetKey(ids []int64) string {
+	sorted := append([]int64(nil), ids...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	parts := make([]string, len(sorted))
+	for i, id := range sorted {
+		parts[i] = strconv.FormatInt(id, 10)
+	}
+	return strings.Join(parts, ",")
+}
+
+// ListDuplicateCandidates returns one page of the near-duplicate groups with
+// the given status, strongest first, and how many there are in all.
+func ListDuplicateCandidates(ctx context.Context, db *pg.DB, status string, page, pageSize int) ([]models.DuplicateCandidate, int, error) {
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 200 {
+		pageSize = 50
+	}
+	var candidates []models.DuplicateCandidate
+	total, err := db.ModelContext(ctx, &candidates).
+		Where("status = ?", status).
+		Order("score DESC", "id ASC").
+		Limit(pageSize).
+		Offset((page - 1) * pageSize).
+		SelectAndCount()
+	if err != nil {
+		return nil, 0, err
+	}
+	return candidates, total, nil
+}
+
+// SuggestDuplicateWinner returns the book of a group policy would keep.
+func SuggestDuplicateWinner(ctx context.Context, db *pg.DB, ids []int64, policy DuplicateWinnerPolicy, filesPath string) (int64, error) {
+	return chooseWinnerOf(ctx, db, ids, policy, filesPath)
+}
+
+// ResolveDuplicateCandidate accepts a near-duplicate group: every book but the
+// winner is hidden as its duplicate. A winnerID of 0 lets policy choose.
+//
+// The group is locked while its books are hidden and it is marked resolved,
+// so of two reviewers resolving it at once, with different winners, one
+// succeeds and the other gets ErrCandidateReviewed with nothing hidden.
+func ResolveDuplicateCandidate(ctx context.Context, db *pg.DB, id, winnerID int64, policy DuplicateWinnerPolicy, filesPath string) (*models.DuplicateCandidate, error) {
+	if winnerID == 0 {
+		// Choosing may read every book's file, so it is done before the
+		// group is locked; the group is checked again under the lock.
+		candidate, err := pendingCandidate(ctx, db, id, false)
+		if err != nil {
+			return nil, err
+		}
+		winnerID, err = chooseWinnerOf(ctx, db, candidate.BookIDs, policy, filesPath)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var candidate *models.DuplicateCandidate
+	var hidden int
+	err := db.RunInTransaction(ctx, func(tx *pg.Tx) error {
+		var err error
+		candidate, err = pendingCandidate(ctx, tx, id, true)
+		if err != nil {
+			return err
+		}
+		if !slices.Contains(candidate.BookIDs, winnerID) {
+			return ErrWinnerNotInGroup
+		}
+		hidden, err = hideAllBut(ctx, tx, winnerID, candidate.BookIDs)
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		candidate.Status = models.DuplicateCandidateResolved
+		candidate.WinnerID = &winnerID
+		candidate.ReviewedAt = &now
+		_, err = tx.ModelContext(ctx, candidate).
+			Column("status", "winner_id", "reviewed_at").
+			WherePK().
+			Update()
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	logging.Infof("Resolved duplicate candidate %d: kept book %d, hid %d", id, winnerID, hidden)
+	return candidate, nil
+}
+
+// DismissDuplicateCandidate rejects a near-duplicate group; later scans do not
+// propose the same set of books again. A group resolved meanwhile stays
+// resolved: dismissing it is ErrCandidateReviewed.
+func DismissDuplicateCandidate(ctx context.Context, db *pg.DB, id int64) (*models.DuplicateCandidate, error) {
+	var candidate *models.DuplicateCandidate
+	err := db.RunInTransaction(ctx, func(tx *pg.Tx) error {
+		var err error
+		candidate, err = pendingCandidate(ctx, tx, id, true)
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		candidate.Status = models.DuplicateCandidateDismissed
+		candidate.ReviewedAt = &now
+		_, err = tx.ModelContext(ctx, candidate).
+			Column("status", "reviewed_at").
+			WherePK().
+			Update()
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return candidate, nil
+}
+
+// pendingCandidate loads a group still awaiting review, locking it for the
+// rest of db's transaction when lock is set.
+func pendingCandidate(ctx context.Context, db pg.DBI, id int64, lock bool) (*models.DuplicateCandidate, error) {
+	candidate := &models.DuplicateCandidate{ID: id}
+	q := db.ModelContext(ctx, candidate).WherePK()
+	if lock {
+		q = q.For("UPDATE")
+	}
+	if err := q.Select(); err != nil {
+		if errors.Is(err, pg.ErrNoRows) {
+			return nil, ErrCandidateNotFound
+		}
+		return nil, err
+	}
+	if candidate.Status != models.DuplicateCandidatePending {
+		return nil, ErrCandidateReviewed
+	}
+	return candidate, nil
+}
+
 // EnsureOneScanRunning checks if a scan is already running or pending
 func EnsureOneScanRunning(_ context.Context, db *pg.DB) error {
 	scanMutex.Lock()
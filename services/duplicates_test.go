@@ -0,0 +1,54 @@
+package services
+
+import (
+	"bytes"
+	// #nosec G501 -- the tests check the fingerprint the service computes.
+	"crypto/md5"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gopds-api/internal/parser"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// A book larger than the parser's sample gets the MD5 of all of it and the
+// SimHash a whole-file parse gives, as the scan stores it.
+func TestComputeBookFingerprint_StreamsALargeBook(t *testing.T) {
+	var book strings.Builder
+	book.WriteString(`<?xml version="1.0" encoding="utf-8"?>
+<FictionBook xmlns="http://www.gribuser.ru/xml/fictionbook/2.0">
+<description><title-info><book-title>Улитка на склоне</book-title><lang>ru</lang></title-info></description>
+<body><section>`)
+	for book.Len() < 2*fingerprintSampleBytes {
+		book.WriteString("<p>Отсюда, с этой высоты, лес был как пестрая застывшая пена.</p>\n")
+	}
+	book.WriteString(`</section></body><binary id="cover.jpg" content-type="image/jpeg">`)
+	book.WriteString(strings.Repeat("QUFB", fingerprintSampleBytes/4))
+	book.WriteString("</binary></FictionBook>")
+	content := []byte(book.String())
+
+	zipPath := filepath.Join(t.TempDir(), "books.zip")
+	require.NoError(t, os.WriteFile(zipPath, zipOf(t, "big.fb2", book.String()), 0o600))
+
+	fp, err := computeBookFingerprint(zipPath, "big.fb2")
+	require.NoError(t, err)
+	// #nosec G401 -- the fingerprint the catalogue keeps.
+	sum := md5.Sum(content)
+	assert.Equal(t, hex.EncodeToString(sum[:]), fp.MD5)
+
+	whole, err := parser.NewFB2Parser(false).Parse(bytes.NewReader(content))
+	require.NoError(t, err)
+	require.NotEmpty(t, whole.BodySample)
+	assert.Equal(t, int64(SimHash(whole.BodySample)), fp.Simhash) // #nosec G115 -- stored bit for bit
+}
+
+func TestCloseFB2Sample(t *testing.T) {
+	assert.Equal(t, "<FictionBook><body><p>Лес</p><p></FictionBook>",
+		string(closeFB2Sample([]byte("<FictionBook><body><p>Лес</p><p>был как пе"))))
+	assert.Equal(t, "no tags", string(closeFB2Sample([]byte("no tags"))))
+}
@@ -305,6 +305,7 @@ func (s *FixScanService) processBook(book models.Book, archivePath string, fileI
 	// cover=false + no cover in FB2 -> no change
 	hasCoverInFB2 := len(parsed.Cover) > 0
 	newCoverFlag := book.Cover // start with current value
+	var placeholder *posters.Placeholder
 
 	if hasCoverInFB2 {
 		// Save/overwrite cover file
@@ -313,6 +314,8 @@ func (s *FixScanService) processBook(book models.Book, archivePath string, fileI
 			// Don't fail the whole book for a cover error
 		} else {
 			newCoverFlag = true
+			p := coverPlaceholder(parsed.Cover)
+			placeholder = &p
 		}
 	}
 	// If book.Cover is true and no cover in FB2, keep cover=true (never downgrade)
@@ -359,15 +362,19 @@ func (s *FixScanService) processBook(book models.Book, archivePath string, fileI
 	}()
 
 	// Update book metadata
-	_, err = tx.Model(&models.Book{}).
+	update := tx.Model(&models.Book{}).
 		Set("title = ?", parsed.Title).
 		Set("annotation = ?", parsed.Annotation).
 		Set("lang = ?", detectedLang).
 		Set("docdate = ?", parsed.DocDate).
 		Set("cover = ?", newCoverFlag).
-		Set("registerdate = registerdate"). // preserve registerdate
-		Where("id = ?", book.ID).
-		Update()
+		Set("registerdate = registerdate") // preserve registerdate
+	if placeholder != nil {
+		update = update.
+			Set("cover_blurhash = ?", placeholder.Blurhash).
+			Set("cover_color = ?", placeholder.Color)
+	}
+	_, err = update.Where("id = ?", book.ID).Update()
 	if err != nil {
 		addError(FixScanError{
 			BookID:      book.ID,
@@ -0,0 +1,421 @@
+package services
+
+// fuzzy_duplicates.go finds books that are the same work without being the
+// same file. An MD5 only groups byte-identical copies; the same novel from two
+// sources differs in OCR, in its images, in the program line of its FB2
+// header, and hashes to something unrelated. What stays is the title and the
+// authors once the noise is normalized away, and the text itself, which a
+// SimHash reduces to 64 bits that move only a little when the text does.
+//
+// Candidate pairs come from blocks, never from the whole library: books must
+// share an author, and within that either the normalized title or one of the
+// four 16-bit bands of the SimHash. Books with one title are always compared.
+// Books with different titles are compared when their hashes share a band,
+// which is certain within three differing bits and likely a little beyond —
+// and a differently titled pair needs text that close to score anyway.
+
+import (
+	"hash/fnv"
+	"math/bits"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultFuzzyDuplicateThreshold is the score a pair must reach for its books
+// to be proposed as one work. A pair with matching text scores above it even
+// with a differently worded title; a pair with no text to compare reaches it
+// only when the normalized titles and authors are identical.
+const DefaultFuzzyDuplicateThreshold = 0.75
+
+// FuzzyBook is what near-duplicate detection knows about one book.
+type FuzzyBook struct {
+	ID      int64
+	Title   string
+	Authors []string
+	MD5     string
+	// Simhash is the book's TextSimhash; 0 when it has no text to compare.
+	Simhash uint64
+}
+
+// FuzzyGroup is one set of books proposed as the same work. Score is that of
+// the weakest link holding the group together.
+type FuzzyGroup struct {
+	Key     string
+	Score   float64
+	BookIDs []int64
+}
+
+// DuplicateWinnerPolicy names how the book a duplicate group keeps visible is
+// chosen.
+type DuplicateWinnerPolicy string
+
+// The winner policies. Every one breaks ties by the highest ID, which alone
+// is the original rule and stays the default.
+const (
+	WinnerHighestID    DuplicateWinnerPolicy = "highest_id"
+	WinnerNewest       DuplicateWinnerPolicy = "newest"
+	WinnerLargest      DuplicateWinnerPolicy = "largest"
+	WinnerBestMetadata DuplicateWinnerPolicy = "best_metadata"
+	WinnerHasCover     DuplicateWinnerPolicy = "has_cover"
+)
+
+// ParseDuplicateWinnerPolicy validates a policy name; empty means the default.
+func ParseDuplicateWinnerPolicy(s string) (DuplicateWinnerPolicy, bool) {
+	switch p := DuplicateWinnerPolicy(strings.ToLower(strings.TrimSpace(s))); p {
+	case "":
+		return WinnerHighestID, true
+	case WinnerHighestID, WinnerNewest, WinnerLargest, WinnerBestMetadata, WinnerHasCover:
+		return p, true
+	default:
+		return "", false
+	}
+}
+
+// WinnerCandidate is what a winner policy compares.
+type WinnerCandidate struct {
+	ID           int64
+	RegisterDate time.Time
+	// Size is the uncompressed FB2 size; only the largest policy reads it.
+	Size  int64
+	Cover bool
+	// Metadata counts the filled-in descriptive fields, see metadataScore.
+	Metadata int
+}
+
+// ChooseDuplicateWinner returns the ID of the candidate policy prefers.
+func ChooseDuplicateWinner(policy DuplicateWinnerPolicy, candidates []WinnerCandidate) int64 {
+	if len(candidates) == 0 {
+		return 0
+	}
+	better := func(a, b WinnerCandidate) bool {
+		switch policy {
+		case WinnerNewest:
+			if !a.RegisterDate.Equal(b.RegisterDate) {
+				return a.RegisterDate.After(b.RegisterDate)
+			}
+		case WinnerLargest:
+			if a.Size != b.Size {
+				return a.Size > b.Size
+			}
+		case WinnerBestMetadata:
+			if a.Metadata != b.Metadata {
+				return a.Metadata > b.Metadata
+			}
+		case WinnerHasCover:
+			if a.Cover != b.Cover {
+				return a.Cover
+			}
+			if a.Metadata != b.Metadata {
+				return a.Metadata > b.Metadata
+			}
+		}
+		return a.ID > b.ID
+	}
+	best := candidates[0]
+	for _, c := range candidates[1:] {
+		if better(c, best) {
+			best = c
+		}
+	}
+	return best.ID
+}
+
+// unknownAuthor is what the scanner names a book's author when the FB2 has
+// none. It says nothing about the work, so it does not make two books alike.
+const unknownAuthor = "автор неизвестен"
+
+// duplicateTitleKey normalizes a title for comparison: lowercase, punctuation
+// folded, ё read as е, and any subtitle dropped — sources disagree on
+// subtitles far more often than on titles.
+func duplicateTitleKey(title string) string {
+	return strings.ReplaceAll(normalizeText(trimSubtitle(title)), "ё", "е")
+}
+
+// duplicateAuthorKey normalizes one author's name with its words sorted, so
+// "Толстой Лев" and "Лев Толстой" are one author. Empty for the unknown one.
+func duplicateAuthorKey(name string) string {
+	words := strings.Fields(strings.ReplaceAll(normalizeText(name), "ё", "е"))
+	sort.Strings(words)
+	key := strings.Join(words, " ")
+	if key == unknownAuthor {
+		return ""
+	}
+	return key
+}
+
+// SimHash fingerprints text: every three-word shingle votes on each of 64 bits
+// with its hash, and the bit is set where the votes for it win. Texts sharing
+// most of their shingles end up with most of their bits in common. Text too
+// short for a shingle hashes its words alone; no words at all hash to 0.
+func SimHash(text string) uint64 {
+	words := strings.Fields(strings.ReplaceAll(normalizeText(text), "ё", "е"))
+	if len(words) == 0 {
+		return 0
+	}
+	const shingle = 3
+	var votes [64]int
+	vote := func(s string) {
+		h := fnv.New64a()
+		_, _ = h.Write([]byte(s))
+		sum := h.Sum64()
+		for bit := 0; bit < 64; bit++ {
+			if sum&(1<<uint(bit)) != 0 {
+				votes[bit]++
+			} else {
+				votes[bit]--
+			}
+		}
+	}
+	if len(words) < shingle {
+		for _, w := range words {
+			vote(w)
+		}
+	} else {
+		for i := 0; i+shingle <= len(words); i++ {
+			vote(strings.Join(words[i:i+shingle], " "))
+		}
+	}
+	var hash uint64
+	for bit, v := range votes {
+		if v > 0 {
+			hash |= 1 << uint(bit)
+		}
+	}
+	return hash
+}
+
+// simhashReach is the Hamming distance at which two texts stop counting as
+// similar at all. Unrelated texts sit around 32 bits apart; editions of one
+// text within a handful.
+const simhashReach = 24
+
+// pairScore rates how likely two books are one work, from 0 to 1.
+func pairScore(a, b *fuzzyEntry) float64 {
+	if a.book.MD5 != "" && a.book.MD5 == b.book.MD5 {
+		return 1
+	}
+	titleSim := 1.0
+	if a.titleKey != b.titleKey {
+		titleSim = wordJaccard(a.titleKey, b.titleKey)
+	}
+	if a.book.Simhash == 0 || b.book.Simhash == 0 {
+		// Nothing but the metadata to go on: never as sure as with the text.
+		return 0.8 * titleSim
+	}
+	distance := bits.OnesCount64(a.book.Simhash ^ b.book.Simhash)
+	textSim := 1 - float64(distance)/simhashReach
+	if textSim < 0 {
+		textSim = 0
+	}
+	return 0.35*titleSim + 0.65*textSim
+}
+
+// wordJaccard is the share of distinct words two strings have in common.
+func wordJaccard(a, b string) float64 {
+	wa, wb := strings.Fields(a), strings.Fields(b)
+	if len(wa) == 0 || len(wb) == 0 {
+		return 0
+	}
+	set := make(map[string]bool, len(wa))
+	for _, w := range wa {
+		set[w] = true
+	}
+	union := len(set)
+	shared := 0
+	seen := make(map[string]bool, len(wb))
+	for _, w := range wb {
+		if seen[w] {
+			continue
+		}
+		seen[w] = true
+		if set[w] {
+			shared++
+		} else {
+			union++
+		}
+	}
+	return float64(shared) / float64(union)
+}
+
+type fuzzyEntry struct {
+	book       *FuzzyBook
+	titleKey   string
+	authorKeys []string
+}
+
+type fuzzyEdge struct {
+	a, b  int
+	score float64
+}
+
+// FindFuzzyDuplicates groups books whose pairwise score reaches threshold.
+// Links chain, except across text: two groups whose members include a pair
+// of texts that do not score are not joined, so a book with no text to
+// compare cannot bridge two different works under one title. A group made
+// only of copies with one MD5 is left out: the exact detector already
+// reports it.
+func FindFuzzyDuplicates(books []FuzzyBook, threshold float64) []FuzzyGroup {
+	entries := make([]fuzzyEntry, len(books))
+	blocks := make(map[string][]int)
+	for i := range books {
+		e := fuzzyEntry{book: &books[i], titleKey: duplicateTitleKey(books[i].Title)}
+		seen := make(map[string]bool)
+		for _, name := range books[i].Authors {
+			if key := duplicateAuthorKey(name); key != "" && !seen[key] {
+				seen[key] = true
+				e.authorKeys = append(e.authorKeys, key)
+				blocks[key] = append(blocks[key], i)
+			}
+		}
+		entries[i] = e
+	}
+
+	scored := make(map[[2]int]bool)
+	var edges []fuzzyEdge
+	consider := func(i, j int) {
+		if i == j {
+			return
+		}
+		if i > j {
+			i, j = j, i
+		}
+		if scored[[2]int{i, j}] {
+			return
+		}
+		scored[[2]int{i, j}] = true
+		if s := pairScore(&entries[i], &entries[j]); s >= threshold {
+			edges = append(edges, fuzzyEdge{i, j, s})
+		}
+	}
+
+	// Blocks are visited in key order so the result does not depend on map
+	// iteration.
+	authorKeys := make([]string, 0, len(blocks))
+	for key := range blocks {
+		authorKeys = append(authorKeys, key)
+	}
+	sort.Strings(authorKeys)
+	for _, key := range authorKeys {
+		members := blocks[key]
+		if len(members) < 2 {
+			continue
+		}
+		sub := make(map[string][]int)
+		for _, i := range members {
+			if entries[i].titleKey != "" {
+				sub["t:"+entries[i].titleKey] = append(sub["t:"+entries[i].titleKey], i)
+			}
+			if h := entries[i].book.Simhash; h != 0 {
+				for band := 0; band < 4; band++ {
+					k := "b" + strconv.Itoa(band) + ":" + strconv.FormatUint(h>>(16*uint(band))&0xffff, 16)
+					sub[k] = append(sub[k], i)
+				}
+			}
+		}
+		subKeys := make([]string, 0, len(sub))
+		for k := range sub {
+			subKeys = append(subKeys, k)
+		}
+		sort.Strings(subKeys)
+		for _, k := range subKeys {
+			group := sub[k]
+			for x := 0; x < len(group); x++ {
+				for y := x + 1; y < len(group); y++ {
+					consider(group[x], group[y])
+				}
+			}
+		}
+	}
+
+	// Strongest links first, so each group is held together by its best
+	// edges and its score is the weakest of those.
+	sort.Slice(edges, func(x, y int) bool {
+		if edges[x].score != edges[y].score {
+			return edges[x].score > edges[y].score
+		}
+		if edges[x].a != edges[y].a {
+			return edges[x].a < edges[y].a
+		}
+		return edges[x].b < edges[y].b
+	})
+	parent := make([]int, len(entries))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		for parent[i] != i {
+			parent[i] = parent[parent[i]]
+			i = parent[i]
+		}
+		return i
+	}
+	weakest := make(map[int]float64)
+	component := make(map[int][]int)
+	for i := range entries {
+		component[i] = []int{i}
+	}
+	conflict := func(ra, rb int) bool {
+		for _, x := range component[ra] {
+			for _, y := range component[rb] {
+				if entries[x].book.Simhash != 0 && entries[y].book.Simhash != 0 &&
+					pairScore(&entries[x], &entries[y]) < threshold {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	for _, e := range edges {
+		ra, rb := find(e.a), find(e.b)
+		if ra == rb || conflict(ra, rb) {
+			continue
+		}
+		component[ra] = append(component[ra], component[rb]...)
+		delete(component, rb)
+		score := e.score
+		for _, r := range []int{ra, rb} {
+			if s, ok := weakest[r]; ok && s < score {
+				score = s
+			}
+			delete(weakest, r)
+		}
+		parent[rb] = ra
+		weakest[ra] = score
+	}
+
+	members := make(map[int][]int)
+	for i := range entries {
+		if _, ok := weakest[find(i)]; ok {
+			members[find(i)] = append(members[find(i)], i)
+		}
+	}
+	groups := make([]FuzzyGroup, 0, len(members))
+	for root, idx := range members {
+		md5s := make(map[string]bool)
+		ids := make([]int64, 0, len(idx))
+		for _, i := range idx {
+			md5s[entries[i].book.MD5] = true
+			ids = append(ids, entries[i].book.ID)
+		}
+		if len(md5s) < 2 {
+			continue
+		}
+		sort.Slice(ids, func(x, y int) bool { return ids[x] < ids[y] })
+		first := entries[idx[0]]
+		key := first.titleKey
+		if len(first.authorKeys) > 0 {
+			key += " / " + first.authorKeys[0]
+		}
+		groups = append(groups, FuzzyGroup{Key: key, Score: weakest[root], BookIDs: ids})
+	}
+	sort.Slice(groups, func(x, y int) bool {
+		if groups[x].Score != groups[y].Score {
+			return groups[x].Score > groups[y].Score
+		}
+		return groups[x].BookIDs[0] < groups[y].BookIDs[0]
+	})
+	return groups
+}
@@ -0,0 +1,121 @@
+package services
+
+import (
+	"math/bits"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// novel is long enough for the shingles of a small edit to be outvoted.
+var novel = strings.Repeat("Он шёл по улице, и снег падал на мостовую медленно и тихо. ", 3) +
+	"В окнах горел свет, где-то далеко играла музыка, и город засыпал. " +
+	"Утром всё было иначе: солнце, шум трамваев, голоса на лестнице, запах хлеба. " +
+	"Она ждала его у моста, как договорились, но он так и не пришёл в тот день."
+
+func TestSimHashMovesLittleWithTheText(t *testing.T) {
+	a := SimHash(novel)
+	edited := SimHash(strings.Replace(novel, "запах хлеба", "запах кофе", 1))
+	unrelated := SimHash("Совершенно другой текст о морских путешествиях, кораблях и дальних странах, " +
+		"где капитан ведёт судно сквозь шторм к неизвестному острову на краю карты.")
+
+	if d := bits.OnesCount64(a ^ edited); d > 12 {
+		t.Errorf("an edited copy is %d bits away", d)
+	}
+	if d := bits.OnesCount64(a ^ unrelated); d < 16 {
+		t.Errorf("an unrelated text is only %d bits away", d)
+	}
+	if SimHash("ёлка") != SimHash("елка") {
+		t.Error("ё and е hash differently")
+	}
+	if SimHash(" \n ") != 0 {
+		t.Error("no text did not hash to 0")
+	}
+}
+
+func TestDuplicateKeysIgnoreOrderAndNoise(t *testing.T) {
+	if duplicateAuthorKey("Толстой Лев") != duplicateAuthorKey("лев  толстой") {
+		t.Error("author word order made two authors")
+	}
+	if duplicateAuthorKey("Автор неизвестен") != "" {
+		t.Error("the unknown author has a key")
+	}
+	if duplicateTitleKey("Война и мир: роман-эпопея") != duplicateTitleKey("ВОЙНА И МИР") {
+		t.Error("a subtitle made two titles")
+	}
+}
+
+func TestFindFuzzyDuplicatesGroupsEditionsOfOneWork(t *testing.T) {
+	text := SimHash(novel)
+	other := SimHash("Совершенно другой текст о морских путешествиях, кораблях и дальних странах.")
+	books := []FuzzyBook{
+		{ID: 1, Title: "Зимний город", Authors: []string{"Иванов Пётр"}, MD5: "a", Simhash: text},
+		{ID: 2, Title: "Зимний город — роман", Authors: []string{"Пётр Иванов"}, MD5: "b", Simhash: text ^ 0b101},
+		// Same title and author, no text to compare: proposed on metadata.
+		{ID: 3, Title: "зимний город", Authors: []string{"ИВАНОВ ПЁТР"}, MD5: "c"},
+		// Same title and author, but a different text: another book.
+		{ID: 4, Title: "Зимний город", Authors: []string{"Иванов Пётр"}, MD5: "d", Simhash: other},
+		// Same text and title under an unknown author: never blocked together.
+		{ID: 5, Title: "Зимний город", Authors: []string{"Автор неизвестен"}, MD5: "e", Simhash: text},
+	}
+	groups := FindFuzzyDuplicates(books, DefaultFuzzyDuplicateThreshold)
+	if len(groups) != 1 {
+		t.Fatalf("groups = %+v, want one", groups)
+	}
+	if want := []int64{1, 2, 3}; !reflect.DeepEqual(groups[0].BookIDs, want) {
+		t.Errorf("group holds %v, want %v", groups[0].BookIDs, want)
+	}
+	if groups[0].Score < DefaultFuzzyDuplicateThreshold || groups[0].Score >= 1 {
+		t.Errorf("group score = %v", groups[0].Score)
+	}
+}
+
+// Byte-identical copies are the exact detector's business.
+func TestFindFuzzyDuplicatesLeavesMD5GroupsAlone(t *testing.T) {
+	books := []FuzzyBook{
+		{ID: 1, Title: "Книга", Authors: []string{"Автор Первый"}, MD5: "same"},
+		{ID: 2, Title: "Книга", Authors: []string{"Автор Первый"}, MD5: "same"},
+	}
+	if groups := FindFuzzyDuplicates(books, DefaultFuzzyDuplicateThreshold); len(groups) != 0 {
+		t.Errorf("groups = %+v, want none", groups)
+	}
+}
+
+func TestChooseDuplicateWinner(t *testing.T) {
+	old := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	candidates := []WinnerCandidate{
+		{ID: 10, RegisterDate: old.AddDate(2, 0, 0), Size: 100, Metadata: 2},
+		{ID: 20, RegisterDate: old, Size: 900, Cover: true, Metadata: 3},
+		{ID: 30, RegisterDate: old, Size: 100, Metadata: 5},
+	}
+	cases := map[DuplicateWinnerPolicy]int64{
+		WinnerHighestID:    30,
+		WinnerNewest:       10,
+		WinnerLargest:      20,
+		WinnerBestMetadata: 30,
+		WinnerHasCover:     20,
+	}
+	for policy, want := range cases {
+		if got := ChooseDuplicateWinner(policy, candidates); got != want {
+			t.Errorf("%s: winner %d, want %d", policy, got, want)
+		}
+	}
+
+	tied := []WinnerCandidate{{ID: 7, Metadata: 1}, {ID: 8, Metadata: 1}}
+	if got := ChooseDuplicateWinner(WinnerBestMetadata, tied); got != 8 {
+		t.Errorf("a tie went to %d, want the highest ID", got)
+	}
+}
+
+func TestParseDuplicateWinnerPolicy(t *testing.T) {
+	if p, ok := ParseDuplicateWinnerPolicy(""); !ok || p != WinnerHighestID {
+		t.Errorf(`"" = %q, %v`, p, ok)
+	}
+	if p, ok := ParseDuplicateWinnerPolicy(" Has_Cover "); !ok || p != WinnerHasCover {
+		t.Errorf(`" Has_Cover " = %q, %v`, p, ok)
+	}
+	if _, ok := ParseDuplicateWinnerPolicy("oldest"); ok {
+		t.Error("an unknown policy was accepted")
+	}
+}
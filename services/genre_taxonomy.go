@@ -0,0 +1,23 @@
+package services
+
+import (
+	"context"
+
+	"gopds-api/database"
+	"gopds-api/internal/genres"
+	"gopds-api/logging"
+)
+
+// SeedGenreTaxonomy puts the built-in FB2 genre tree into a catalog that has
+// none yet. It runs at startup; once the tree is there, admins edit it and
+// restarts leave it alone.
+func SeedGenreTaxonomy(ctx context.Context) {
+	seeded, err := database.SeedGenreTaxonomy(ctx, genres.Sections, genres.Aliases)
+	if err != nil {
+		logging.Errorf("Genre taxonomy seed: %v", err)
+		return
+	}
+	if seeded {
+		logging.Infof("Genre taxonomy seeded: %d sections, %d aliases", len(genres.Sections), len(genres.Aliases))
+	}
+}
@@ -0,0 +1,154 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"gopds-api/database"
+	"gopds-api/internal/oidc"
+	"gopds-api/models"
+	"gopds-api/utils"
+)
+
+var (
+	// ErrNotProvisioned is returned for an identity linked to no user when
+	// accounts are not made at first login.
+	ErrNotProvisioned = errors.New("no account is linked to this identity")
+	// ErrProvisioningDenied is returned for an identity the rules do not
+	// let have an account.
+	ErrProvisioningDenied = errors.New("this identity may not have an account")
+)
+
+// OIDCRules decide whether someone logging in through the provider for
+// the first time gets an account, as an alternative to an invite.
+type OIDCRules struct {
+	// AutoProvision makes accounts at first login. Without it an identity
+	// logs in only once linked to an existing account.
+	AutoProvision bool
+	// GroupsClaim names the ID token claim listing the person's groups.
+	GroupsClaim string
+	// AllowedGroups, when set, admits only members of one of them.
+	AllowedGroups []string
+	// AllowedEmailDomains, when set, admits only verified emails at one of
+	// them.
+	AllowedEmailDomains []string
+}
+
+// Admit reports whether the rules let an identity have an account made.
+func (r OIDCRules) Admit(claims *oidc.Claims) error {
+	if !r.AutoProvision {
+		return ErrNotProvisioned
+	}
+	if len(r.AllowedGroups) > 0 {
+		groups := claims.Strings(r.GroupsClaim)
+		if !slices.ContainsFunc(groups, func(g string) bool { return slices.Contains(r.AllowedGroups, g) }) {
+			return ErrProvisioningDenied
+		}
+	}
+	if len(r.AllowedEmailDomains) > 0 {
+		_, domain, ok := strings.Cut(claims.Email, "@")
+		if !ok || !claims.EmailVerified ||
+			!slices.ContainsFunc(r.AllowedEmailDomains, func(d string) bool { return strings.EqualFold(d, domain) }) {
+			return ErrProvisioningDenied
+		}
+	}
+	return nil
+}
+
+// OIDCService logs people in through an OpenID provider, and links their
+// identities there to local accounts.
+type OIDCService struct {
+	provider *oidc.Provider
+	rules    OIDCRules
+}
+
+// NewOIDCService returns the login service for one provider.
+func NewOIDCService(provider *oidc.Provider, rules OIDCRules) *OIDCService {
+	if rules.GroupsClaim == "" {
+		rules.GroupsClaim = "groups"
+	}
+	return &OIDCService{provider: provider, rules: rules}
+}
+
+// AuthCodeURL returns where to send the browser to log in.
+func (s *OIDCService) AuthCodeURL(ctx context.Context, state, nonce, verifier string) (string, error) {
+	return s.provider.AuthCodeURL(ctx, state, nonce, verifier)
+}
+
+// Exchange trades the code the callback got for the person's claims.
+func (s *OIDCService) Exchange(ctx context.Context, code, verifier, nonce string) (*oidc.Claims, error) {
+	return s.provider.Exchange(ctx, code, verifier, nonce)
+}
+
+// Login returns the user an identity logs in as, making one when the rules
+// allow it. An identity is never matched to an account by email: a local
+// account is only reached through an identity its owner linked.
+func (s *OIDCService) Login(ctx context.Context, claims *oidc.Claims) (models.User, error) {
+	user, err := database.UserByIdentity(ctx, claims.Issuer, claims.Subject)
+	if !errors.Is(err, database.ErrIdentityNotFound) {
+		return user, err
+	}
+	if err := s.rules.Admit(claims); err != nil {
+		return models.User{}, err
+	}
+
+	login := models.LoginFromExternal(claims.PreferredUsername, claims.Email)
+	if login == "" {
+		login = "user"
+	}
+	user = models.User{
+		Login: login,
+		// Nobody knows this password; a password of their own is a reset
+		// away for whoever wants one.
+		Password:   utils.CreatePasswordHash(utils.GetRandomString(32)),
+		Email:      verifiedEmail(claims),
+		FirstName:  claims.GivenName,
+		LastName:   claims.FamilyName,
+		Active:     true,
+		DateJoined: time.Now(),
+	}
+	if user.FirstName == "" && user.LastName == "" {
+		user.FirstName = claims.Name
+	}
+	identity := newIdentity(0, claims)
+	if err := database.ProvisionUser(ctx, &user, identity); err != nil {
+		return models.User{}, err
+	}
+	return user, nil
+}
+
+// Link links an identity to a user.
+func (s *OIDCService) Link(ctx context.Context, userID int64, claims *oidc.Claims) error {
+	return database.LinkIdentity(ctx, newIdentity(userID, claims))
+}
+
+// Identities returns the identities linked to a user.
+func (s *OIDCService) Identities(ctx context.Context, userID int64) ([]models.UserIdentity, error) {
+	return database.ListIdentities(ctx, userID)
+}
+
+// Unlink unlinks one of a user's identities.
+func (s *OIDCService) Unlink(ctx context.Context, userID, id int64) error {
+	return database.DeleteIdentity(ctx, userID, id)
+}
+
+func newIdentity(userID int64, claims *oidc.Claims) *models.UserIdentity {
+	return &models.UserIdentity{
+		UserID:  userID,
+		Issuer:  claims.Issuer,
+		Subject: claims.Subject,
+		Email:   claims.Email,
+	}
+}
+
+// verifiedEmail is the email to give a new account: only one the provider
+// vouches for, since the address is also a login and a reset target.
+func verifiedEmail(claims *oidc.Claims) string {
+	if claims.EmailVerified {
+		return claims.Email
+	}
+	return ""
+}
@@ -0,0 +1,40 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"gopds-api/internal/oidc"
+)
+
+func TestOIDCRules_Admit(t *testing.T) {
+	staff := &oidc.Claims{
+		Email:         "anna@Library.example.org",
+		EmailVerified: true,
+		Raw:           map[string]any{"groups": []interface{}{"readers", "staff"}},
+	}
+	unverified := &oidc.Claims{Email: "anna@library.example.org", Raw: map[string]any{"groups": "staff"}}
+
+	tests := []struct {
+		name   string
+		rules  OIDCRules
+		claims *oidc.Claims
+		want   error
+	}{
+		{"provisioning off", OIDCRules{}, staff, ErrNotProvisioned},
+		{"no rules admit anyone", OIDCRules{AutoProvision: true}, unverified, nil},
+		{"group member", OIDCRules{AutoProvision: true, GroupsClaim: "groups", AllowedGroups: []string{"staff"}}, staff, nil},
+		{"single-string group claim", OIDCRules{AutoProvision: true, GroupsClaim: "groups", AllowedGroups: []string{"staff"}}, unverified, nil},
+		{"not a member", OIDCRules{AutoProvision: true, GroupsClaim: "groups", AllowedGroups: []string{"admins"}}, staff, ErrProvisioningDenied},
+		{"domain, any case", OIDCRules{AutoProvision: true, AllowedEmailDomains: []string{"library.example.org"}}, staff, nil},
+		{"unverified email", OIDCRules{AutoProvision: true, AllowedEmailDomains: []string{"library.example.org"}}, unverified, ErrProvisioningDenied},
+		{"other domain", OIDCRules{AutoProvision: true, AllowedEmailDomains: []string{"example.com"}}, staff, ErrProvisioningDenied},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.rules.Admit(tt.claims); !errors.Is(err, tt.want) {
+				t.Fatalf("Admit() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"gopds-api/database"
+	"gopds-api/models"
+)
+
+// Review list paging: an unnamed page size takes the default, an oversized
+// one is cut to the ceiling.
+const (
+	defaultReviewPageSize = 20
+	maxReviewPageSize     = 100
+)
+
+// RatingService keeps readers' ratings and reviews, and the moderation of
+// the reviews.
+type RatingService struct{}
+
+// NewRatingService returns the ratings service.
+func NewRatingService() *RatingService {
+	return &RatingService{}
+}
+
+// Rate stores a user's rating of a book, replacing the one they gave
+// before. A new or changed review waits for moderation.
+func (s *RatingService) Rate(ctx context.Context, userID, bookID int64, req models.RatingRequest) (*models.BookRating, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+	return database.UpsertBookRating(ctx, userID, bookID, req)
+}
+
+// Rating returns a user's own rating of a book, whatever its review's
+// state.
+func (s *RatingService) Rating(ctx context.Context, userID, bookID int64) (*models.BookRating, error) {
+	return database.UserBookRating(ctx, userID, bookID)
+}
+
+// Unrate removes a user's rating of a book and their review with it.
+func (s *RatingService) Unrate(ctx context.Context, userID, bookID int64) error {
+	return database.DeleteBookRating(ctx, userID, bookID)
+}
+
+// Reviews returns a page of a book's approved reviews, newest first, and
+// how many there are.
+func (s *RatingService) Reviews(ctx context.Context, bookID int64, page, pageSize int) ([]models.BookReview, int, error) {
+	limit, offset := reviewPage(page, pageSize)
+	return database.ApprovedBookReviews(ctx, bookID, limit, offset)
+}
+
+// ReviewQueue returns a page of the reviews in a moderation state, oldest
+// first, and how many there are.
+func (s *RatingService) ReviewQueue(ctx context.Context, status string, page, pageSize int) ([]models.BookReview, int, error) {
+	if !models.IsReviewStatus(status) {
+		return nil, 0, fmt.Errorf("%w: unknown review status %q", models.ErrRatingInvalid, status)
+	}
+	limit, offset := reviewPage(page, pageSize)
+	return database.BookReviewsByStatus(ctx, status, limit, offset)
+}
+
+// Moderate approves or rejects a review, returning its rating before and
+// after the decision.
+func (s *RatingService) Moderate(
+	ctx context.Context, id int64, decision models.ReviewModeration, moderatorID *int64,
+) (before, after *models.BookRating, err error) {
+	if err := decision.Validate(); err != nil {
+		return nil, nil, err
+	}
+	return database.ModerateBookReview(ctx, id, decision.Status, moderatorID)
+}
+
+// reviewPage turns a page number, from one, and a page size into a limit
+// and an offset.
+func reviewPage(page, pageSize int) (limit, offset int) {
+	limit = clampLimit(pageSize, defaultReviewPageSize, maxReviewPageSize)
+	if page < 1 {
+		page = 1
+	}
+	return limit, (page - 1) * limit
+}
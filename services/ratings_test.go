@@ -0,0 +1,41 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"gopds-api/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReviewPage(t *testing.T) {
+	for _, tc := range []struct {
+		name           string
+		page, pageSize int
+		limit, offset  int
+	}{
+		{"nothing asked for is the first page of the default size", 0, 0, defaultReviewPageSize, 0},
+		{"later pages skip the earlier ones", 3, 10, 10, 20},
+		{"an oversized page is cut to the ceiling", 2, 1000, maxReviewPageSize, maxReviewPageSize},
+	} {
+		limit, offset := reviewPage(tc.page, tc.pageSize)
+		assert.Equal(t, tc.limit, limit, tc.name)
+		assert.Equal(t, tc.offset, offset, tc.name)
+	}
+}
+
+// The refusals are decided before the database is asked anything.
+func TestRatingServiceRefusals(t *testing.T) {
+	svc := NewRatingService()
+	ctx := context.Background()
+
+	_, err := svc.Rate(ctx, 1, 2, models.RatingRequest{Rating: 6})
+	assert.ErrorIs(t, err, models.ErrRatingInvalid)
+
+	_, _, err = svc.ReviewQueue(ctx, models.ReviewNone, 1, 20)
+	assert.ErrorIs(t, err, models.ErrRatingInvalid, "ratings without a review are not a queue")
+
+	_, _, err = svc.Moderate(ctx, 1, models.ReviewModeration{Status: models.ReviewPending}, nil)
+	assert.ErrorIs(t, err, models.ErrRatingInvalid, "a moderator approves or rejects")
+}
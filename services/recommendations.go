@@ -0,0 +1,53 @@
+package services
+
+import (
+	"context"
+
+	"gopds-api/models"
+)
+
+// Recommendation list sizing: an unnamed limit takes the default, an
+// oversized one is cut to the ceiling.
+const (
+	defaultRecommendationLimit = 10
+	maxRecommendationLimit     = 50
+)
+
+// RecommendationRepository is the storage port of the recommendation
+// service.
+type RecommendationRepository interface {
+	Recommend(ctx context.Context, req models.RecommendationRequest) ([]models.Book, error)
+}
+
+// Recommendations is the adapter-facing recommendation surface: "more like
+// this" for a book and "recommended for you" for a reader.
+type Recommendations interface {
+	Recommend(ctx context.Context, req models.RecommendationRequest) ([]models.Book, error)
+}
+
+var _ Recommendations = (*RecommendationService)(nil)
+
+// RecommendationService normalizes recommendation requests before they
+// reach the repository, which does the scoring.
+type RecommendationService struct {
+	repo RecommendationRepository
+}
+
+// NewRecommendationService wires the service to a repository.
+func NewRecommendationService(repo RecommendationRepository) *RecommendationService {
+	return &RecommendationService{repo: repo}
+}
+
+// Recommend returns the books like the request's book, or, without one,
+// the caller's personal picks. A personal request without a caller has
+// nothing to start from and gets no books.
+//
+//nolint:gocritic // the port takes the request by value; this implements it
+func (s *RecommendationService) Recommend(ctx context.Context, req models.RecommendationRequest) ([]models.Book, error) {
+	if req.BookID < 0 || (req.Personal() && req.UserID == 0) {
+		return nil, nil
+	}
+	req.Language = normalizeLanguage(req.Language)
+	req.Limit = clampLimit(req.Limit, defaultRecommendationLimit, maxRecommendationLimit)
+	return s.repo.Recommend(ctx, req)
+}
@@ -0,0 +1,72 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"gopds-api/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeRecommendationRepository records the request it receives and answers
+// with canned books or an error.
+type fakeRecommendationRepository struct {
+	req   models.RecommendationRequest
+	calls int
+	books []models.Book
+	err   error
+}
+
+//nolint:gocritic // the port takes the request by value; this implements it
+func (f *fakeRecommendationRepository) Recommend(ctx context.Context, req models.RecommendationRequest) ([]models.Book, error) {
+	f.calls++
+	f.req = req
+	return f.books, f.err
+}
+
+func TestRecommendationServiceValidation(t *testing.T) {
+	t.Run("an unnamed limit takes the default and a large one the ceiling", func(t *testing.T) {
+		repo := &fakeRecommendationRepository{}
+		svc := NewRecommendationService(repo)
+
+		_, err := svc.Recommend(context.Background(), models.RecommendationRequest{BookID: 5})
+		require.NoError(t, err)
+		assert.Equal(t, defaultRecommendationLimit, repo.req.Limit)
+
+		_, err = svc.Recommend(context.Background(), models.RecommendationRequest{BookID: 5, Limit: 1000})
+		require.NoError(t, err)
+		assert.Equal(t, maxRecommendationLimit, repo.req.Limit)
+	})
+
+	t.Run("the whole-library language is no filter", func(t *testing.T) {
+		repo := &fakeRecommendationRepository{}
+		_, err := NewRecommendationService(repo).Recommend(context.Background(),
+			models.RecommendationRequest{BookID: 5, Language: allLanguages})
+		require.NoError(t, err)
+		assert.Empty(t, repo.req.Language)
+	})
+
+	t.Run("a personal list without a caller never reaches the repository", func(t *testing.T) {
+		repo := &fakeRecommendationRepository{}
+		books, err := NewRecommendationService(repo).Recommend(context.Background(), models.RecommendationRequest{})
+		require.NoError(t, err)
+		assert.Empty(t, books)
+		assert.Zero(t, repo.calls)
+	})
+
+	t.Run("repository books and errors pass through", func(t *testing.T) {
+		repo := &fakeRecommendationRepository{books: []models.Book{{ID: 7}}}
+		svc := NewRecommendationService(repo)
+		books, err := svc.Recommend(context.Background(), models.RecommendationRequest{UserID: 3})
+		require.NoError(t, err)
+		assert.Equal(t, []models.Book{{ID: 7}}, books)
+		assert.Equal(t, int64(3), repo.req.UserID)
+
+		repo.err = errors.New("boom")
+		_, err = svc.Recommend(context.Background(), models.RecommendationRequest{UserID: 3})
+		assert.ErrorIs(t, err, repo.err)
+	})
+}
@@ -34,7 +34,7 @@ func NewRescanService(archivesDir, coversDir string, languageDetector *LanguageD
 	}
 }
 
-// RescanBookPreview parses FB2 file and returns preview of changes
+// RescanBookPreview parses the book's file and returns preview of changes
 func (s *RescanService) RescanBookPreview(bookID int64, userID int64) (*models.RescanPreview, error) {
 	// 1. Get existing book from DB
 	book := &models.Book{}
@@ -64,19 +64,18 @@ func (s *RescanService) RescanBookPreview(bookID int64, userID int64) (*models.R
 		return nil, fmt.Errorf("invalid archive path for book %d", bookID)
 	}
 
-	// 3. Open archive and extract FB2 file
-	fbzContent, err := s.extractFB2FromArchive(archivePath, book.FileName)
+	// 3. Open archive and extract the book's file
+	content, err := s.extractFB2FromArchive(archivePath, book.FileName)
 	if err != nil {
-		logging.Errorf("Failed to extract FB2 from archive: %v", err)
-		return nil, fmt.Errorf("failed to extract FB2 file: %w", err)
+		logging.Errorf("Failed to extract book file from archive: %v", err)
+		return nil, fmt.Errorf("failed to extract book file: %w", err)
 	}
 
-	// 4. Parse FB2 metadata
-	fbzParser := parser.NewFB2Parser(true) // readCover=true
-	parsedBook, err := fbzParser.Parse(bytes.NewReader(fbzContent))
+	// 4. Parse metadata
+	parsedBook, err := parseRescanContent(book.Format, content)
 	if err != nil {
-		logging.Errorf("Failed to parse FB2: %v", err)
-		return nil, fmt.Errorf("failed to parse FB2 file: %w", err)
+		logging.Errorf("Failed to parse book %d: %v", bookID, err)
+		return nil, err
 	}
 
 	// 5. Get series number from OrderToSeries table if series exists
@@ -245,6 +244,23 @@ func (s *RescanService) RejectRescan(bookID int64) (*models.RescanApprovalRespon
 
 // Helper methods
 
+// parseRescanContent reads the metadata of a book's file in the book's
+// format: an uploaded EPUB with the EPUB parser, everything else as FB2.
+func parseRescanContent(format string, content []byte) (*parser.BookFile, error) {
+	if format == formatEPUB {
+		parsedBook, err := parser.NewEPUBParser(true).Parse(bytes.NewReader(content), int64(len(content)))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse EPUB file: %w", err)
+		}
+		return parsedBook, nil
+	}
+	parsedBook, err := parser.NewFB2Parser(true).Parse(bytes.NewReader(content)) // readCover=true
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse FB2 file: %w", err)
+	}
+	return parsedBook, nil
+}
+
 func (s *RescanService) extractFB2FromArchive(archivePath, fileName string) ([]byte, error) {
 	// First, try to open as ZIP archive
 	zr, err := zip.OpenReader(archivePath)
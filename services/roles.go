@@ -0,0 +1,64 @@
+package services
+
+import (
+	"context"
+
+	"gopds-api/database"
+	"gopds-api/models"
+)
+
+// RolesService manages admin roles and who holds them.
+type RolesService struct{}
+
+// NewRolesService returns the roles service.
+func NewRolesService() *RolesService {
+	return &RolesService{}
+}
+
+// List returns every role with its permissions.
+func (s *RolesService) List(ctx context.Context) ([]models.Role, error) {
+	return database.ListRoles(ctx)
+}
+
+// Get returns one role.
+func (s *RolesService) Get(ctx context.Context, id int64) (*models.Role, error) {
+	return database.GetRole(ctx, id)
+}
+
+// Create validates and stores a new role.
+func (s *RolesService) Create(ctx context.Context, role models.Role) (*models.Role, error) {
+	if err := role.Validate(); err != nil {
+		return nil, err
+	}
+	if err := database.CreateRole(ctx, &role); err != nil {
+		return nil, err
+	}
+	return &role, nil
+}
+
+// Update validates a role and replaces the stored one. Users holding it get
+// the new permissions with their next access token.
+func (s *RolesService) Update(ctx context.Context, role models.Role) (*models.Role, error) {
+	if err := role.Validate(); err != nil {
+		return nil, err
+	}
+	if err := database.UpdateRole(ctx, &role); err != nil {
+		return nil, err
+	}
+	return &role, nil
+}
+
+// Delete deletes a role.
+func (s *RolesService) Delete(ctx context.Context, id int64) error {
+	return database.DeleteRole(ctx, id)
+}
+
+// UserRoles returns the roles a user holds.
+func (s *RolesService) UserRoles(ctx context.Context, userID int64) ([]models.Role, error) {
+	return database.UserRoles(ctx, userID)
+}
+
+// SetUserRoles replaces the roles a user holds.
+func (s *RolesService) SetUserRoles(ctx context.Context, userID int64, roleIDs []int64, grantedBy *int64) ([]models.Role, error) {
+	return database.SetUserRoles(ctx, userID, roleIDs, grantedBy)
+}
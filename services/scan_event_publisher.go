@@ -19,6 +19,10 @@ const (
 	GenreTitleGenStartedType   = "genre_title_gen_started"
 	GenreTitleGenProgressType  = "genre_title_gen_progress"
 	GenreTitleGenCompletedType = "genre_title_gen_completed"
+
+	BulkEditStartedType   = "bulk_edit_started"
+	BulkEditProgressType  = "bulk_edit_progress"
+	BulkEditCompletedType = "bulk_edit_completed"
 )
 
 type ScanEventPublisher struct {
@@ -332,3 +336,77 @@ func (p *ScanEventPublisher) PublishGenreTitleGenCompleted(total, updated int, d
 		Timestamp:  time.Now(),
 	})
 }
+
+// Bulk edit events. Operation is "apply" while an edit is applied and "undo"
+// while it is undone.
+
+type BulkEditStartedEvent struct {
+	EditID    int64     `json:"edit_id"`
+	Operation string    `json:"operation"`
+	Total     int       `json:"total"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+type BulkEditProgressEvent struct {
+	EditID          int64     `json:"edit_id"`
+	Operation       string    `json:"operation"`
+	Total           int       `json:"total"`
+	Processed       int       `json:"processed"`
+	Changed         int       `json:"changed"`
+	Failed          int       `json:"failed"`
+	ProgressPercent int       `json:"progress_percent"`
+	Timestamp       time.Time `json:"timestamp"`
+}
+
+type BulkEditCompletedEvent struct {
+	EditID     int64     `json:"edit_id"`
+	Operation  string    `json:"operation"`
+	Status     string    `json:"status"`
+	Total      int       `json:"total"`
+	Processed  int       `json:"processed"`
+	Changed    int       `json:"changed"`
+	Failed     int       `json:"failed"`
+	Error      string    `json:"error,omitempty"`
+	DurationMS int64     `json:"duration_ms"`
+	Timestamp  time.Time `json:"timestamp"`
+}
+
+func (p *ScanEventPublisher) PublishBulkEditStarted(editID int64, operation string, total int) {
+	if p == nil || p.wsConn == nil {
+		return
+	}
+	_ = p.wsConn.SendMessage(BulkEditStartedType, BulkEditStartedEvent{
+		EditID:    editID,
+		Operation: operation,
+		Total:     total,
+		Timestamp: time.Now(),
+	})
+}
+
+func (p *ScanEventPublisher) PublishBulkEditProgress(editID int64, operation string, total, processed, changed, failed int) {
+	if p == nil || p.wsConn == nil {
+		return
+	}
+	progressPercent := 0
+	if total > 0 {
+		progressPercent = (processed * 100) / total
+	}
+	_ = p.wsConn.SendMessage(BulkEditProgressType, BulkEditProgressEvent{
+		EditID:          editID,
+		Operation:       operation,
+		Total:           total,
+		Processed:       processed,
+		Changed:         changed,
+		Failed:          failed,
+		ProgressPercent: progressPercent,
+		Timestamp:       time.Now(),
+	})
+}
+
+func (p *ScanEventPublisher) PublishBulkEditCompleted(event BulkEditCompletedEvent) {
+	if p == nil || p.wsConn == nil {
+		return
+	}
+	event.Timestamp = time.Now()
+	_ = p.wsConn.SendMessage(BulkEditCompletedType, event)
+}
@@ -0,0 +1,455 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	// #nosec G501 -- MD5 identifies identical files during a scan. It is a
+	// fingerprint, not a security control.
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"gopds-api/database"
+	"gopds-api/internal/genres"
+	"gopds-api/internal/inpx"
+	"gopds-api/internal/parser"
+	"gopds-api/internal/safeio"
+	"gopds-api/logging"
+	"gopds-api/models"
+
+	"github.com/go-pg/pg/v10"
+)
+
+// Pipeline defaults, for a scanner nobody called SetConcurrency on.
+const (
+	defaultScanWorkers   = 1
+	defaultScanBatchSize = 50
+)
+
+// unknownBookAuthor is who a book without authors is filed under.
+var unknownBookAuthor = parser.Author{Name: "Автор неизвестен", Sortkey: "Автор неизвестен"}
+
+// scanJob is one FB2 file of an archive on its way through the pipeline,
+// with what the INPX index says about it and the id of the book already
+// scanned from it, if any.
+type scanJob struct {
+	file   *zip.File
+	record *inpx.Record
+	bookID int64
+}
+
+// scanResult is a scanJob after the workers: a book ready to insert, or the
+// reason there is none.
+type scanResult struct {
+	fileName string
+	book     *preparedBook
+	err      error
+}
+
+// preparedBook is a file decompressed, parsed and language-tagged, or read
+// from its index record: everything inserting it needs.
+type preparedBook struct {
+	book    *models.Book
+	authors []parser.Author
+	series  *parser.Series
+	tags    []string
+	cover   []byte
+	// bodyless marks a file that parsed only once its body was dropped.
+	bodyless bool
+}
+
+// catalogNames lists the authors, series and genres the books link to,
+// genre tags as UpdateBookTags will resolve them.
+func catalogNames(batch []*preparedBook) []database.CatalogName {
+	var names []database.CatalogName
+	for _, p := range batch {
+		for _, a := range p.authors {
+			names = append(names, database.CatalogName{Kind: database.LockAuthor, Name: a.Name})
+		}
+		if p.series != nil {
+			names = append(names, database.CatalogName{Kind: database.LockSeries, Name: p.series.Title})
+		}
+		for _, tag := range p.tags {
+			names = append(names, database.CatalogName{Kind: database.LockGenre, Name: genres.Canonical(tag)})
+		}
+	}
+	return names
+}
+
+// runPipeline takes the files of an archive through the scan: s.workers
+// goroutines decompress, parse and detect the language of files in parallel,
+// and this goroutine inserts what they produce in batches of s.batchSize.
+// Outcomes land in report. Index records of the files found are taken out
+// of records, so what is left afterwards is missing from the archive.
+func (s *BookScanService) runPipeline(archiveName string, files []*zip.File, records map[string]inpx.Record, existing map[string]int64, report *ArchiveReport) {
+	jobs := make(chan scanJob)
+	results := make(chan scanResult, s.workers)
+
+	var wg sync.WaitGroup
+	for i := 0; i < s.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				book, err := s.prepareJob(job, archiveName)
+				results <- scanResult{fileName: job.file.Name, book: book, err: err}
+			}
+		}()
+	}
+	go func() {
+		for _, file := range files {
+			job := scanJob{file: file, bookID: existing[file.Name]}
+			if rec, ok := records[file.Name]; ok {
+				job.record = &rec
+				delete(records, file.Name)
+			}
+			jobs <- job
+		}
+		close(jobs)
+		wg.Wait()
+		close(results)
+	}()
+
+	batch := make([]*preparedBook, 0, s.batchSize)
+	for res := range results {
+		if res.err != nil {
+			s.recordFailure(report, archiveName, res.fileName, res.err)
+			s.fileDone()
+			continue
+		}
+		batch = append(batch, res.book)
+		if len(batch) >= s.batchSize {
+			s.flushBatch(archiveName, batch, report)
+			batch = batch[:0]
+		}
+	}
+	s.flushBatch(archiveName, batch, report)
+}
+
+// prepareJob is the workers' part of a file: skip it, take it from its index
+// record, or decompress and parse it.
+func (s *BookScanService) prepareJob(job scanJob, archiveName string) (*preparedBook, error) {
+	if job.bookID != 0 {
+		if job.record != nil {
+			if _, err := database.SyncIndexedBook(job.bookID, job.record.LibID, job.record.Deleted); err != nil {
+				return nil, fmt.Errorf("failed to update indexed book: %w", err)
+			}
+		}
+		return nil, errBookSkipped
+	}
+	if rec := job.record; rec != nil {
+		if rec.Deleted {
+			return nil, errBookSkipped
+		}
+		// A record at another size describes another revision of the file.
+		revised := rec.Size > 0 && uint64(rec.Size) != job.file.UncompressedSize64 // #nosec G115 -- rec.Size is positive
+		if !revised && strings.TrimSpace(rec.Title) != "" {
+			return s.prepareIndexed(*rec, archiveName), nil
+		}
+	}
+	return s.prepareFB2(job.file, archiveName)
+}
+
+// prepareFB2 decompresses and parses one FB2 file and detects its language.
+func (s *BookScanService) prepareFB2(zipFile *zip.File, archiveName string) (*preparedBook, error) {
+	// 1. Extract and read FB2 content
+	fileReader, err := zipFile.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file in archive: %w", err)
+	}
+	defer func() {
+		if closeErr := fileReader.Close(); closeErr != nil {
+			logging.Warnf("Failed to close file reader: %v", closeErr)
+		}
+	}()
+
+	fb2Content, err := safeio.ReadAll(fileReader, safeio.MaxBookBytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file content: %w", err)
+	}
+	return s.prepareFB2Content(fb2Content, archiveName, zipFile.Name)
+}
+
+// prepareFB2Content parses the FB2 stored as fileName in archiveName and
+// detects its language.
+func (s *BookScanService) prepareFB2Content(fb2Content []byte, archiveName, fileName string) (*preparedBook, error) {
+	// 2. Parse FB2 file, sanitizing it on the way
+	fb2Parser := parser.NewFB2Parser(true) // readCover=true
+	parsedBook, err := fb2Parser.Parse(bytes.NewReader(fb2Content))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse FB2: %w", err)
+	}
+	return s.prepareParsed(parsedBook, fb2Content, archiveName, fileName, formatFB2)
+}
+
+// prepareEPUBContent builds a book from an EPUB as prepareFB2Content does
+// from an FB2. The MD5 is of the EPUB, the file the archive keeps.
+func (s *BookScanService) prepareEPUBContent(epubContent []byte, archiveName, fileName string) (*preparedBook, error) {
+	parsedBook, err := parser.NewEPUBParser(true).Parse(bytes.NewReader(epubContent), int64(len(epubContent)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse EPUB: %w", err)
+	}
+	return s.prepareParsed(parsedBook, epubContent, archiveName, fileName, formatEPUB)
+}
+
+// prepareParsed builds the book of a parsed file of the given format;
+// content is the file, which the MD5 is computed over.
+func (s *BookScanService) prepareParsed(
+	parsedBook *parser.BookFile, content []byte, archiveName, fileName, format string,
+) (*preparedBook, error) {
+	if strings.TrimSpace(parsedBook.Title) == "" {
+		return nil, fmt.Errorf("missing title")
+	}
+	if len(parsedBook.Authors) == 0 {
+		parsedBook.Authors = []parser.Author{unknownBookAuthor}
+	}
+
+	// 3. Detect language
+	detectedLang := parsedBook.Language
+	if s.languageDetector != nil {
+		langResult := s.languageDetector.DetectLanguage(parsedBook.Language, parsedBook.BodySample)
+		detectedLang = langResult.Language
+		logging.Debugf("Language detected for %s: %s (confidence: %.2f, method: %s)",
+			fileName, detectedLang, langResult.Confidence, langResult.Method)
+	}
+
+	book := &models.Book{
+		Path:         archiveName,
+		Format:       format,
+		FileName:     fileName,
+		RegisterDate: time.Now(),
+		DocDate:      parsedBook.DocDate,
+		Lang:         detectedLang,
+		Title:        parsedBook.Title,
+		Cover:        len(parsedBook.Cover) > 0,
+		Annotation:   parsedBook.Annotation,
+		Approved:     true, // Auto-approve scanned books
+	}
+	if book.Cover {
+		p := coverPlaceholder(parsedBook.Cover)
+		book.CoverBlurhash, book.CoverColor = p.Blurhash, p.Color
+	}
+
+	// Compute MD5 hash for duplicate detection
+	// #nosec G401 -- a fingerprint for duplicate detection, see the import.
+	hash := md5.Sum(content)
+	book.MD5 = hex.EncodeToString(hash[:])
+	simhash := int64(SimHash(parsedBook.BodySample)) // #nosec G115 -- stored bit for bit
+	book.TextSimhash = &simhash
+
+	return &preparedBook{
+		book:     book,
+		authors:  parsedBook.Authors,
+		series:   parsedBook.Series,
+		tags:     parsedBook.Tags,
+		cover:    parsedBook.Cover,
+		bodyless: slices.Contains(parsedBook.Issues, parser.IssueParsedWithoutBody),
+	}, nil
+}
+
+// prepareIndexed builds a book from its INPX record without opening the
+// file. The cover, annotation and fingerprints stay empty until a rescan or
+// a duplicate scan reads it.
+func (s *BookScanService) prepareIndexed(rec inpx.Record, archiveName string) *preparedBook {
+	authors := make([]parser.Author, 0, len(rec.Authors))
+	for _, a := range rec.Authors {
+		authors = append(authors, parser.Author{Name: a.Name(), Sortkey: a.Last})
+	}
+	if len(authors) == 0 {
+		authors = []parser.Author{unknownBookAuthor}
+	}
+
+	lang := rec.Lang
+	if s.languageDetector != nil {
+		lang = s.languageDetector.StandardizeLanguage(rec.Lang)
+	}
+
+	var series *parser.Series
+	if rec.Series != "" {
+		series = &parser.Series{Title: rec.Series}
+		if rec.SerNo > 0 {
+			series.Index = fmt.Sprintf("%d", rec.SerNo)
+		}
+	}
+
+	return &preparedBook{
+		book: &models.Book{
+			Path:         archiveName,
+			Format:       rec.Ext,
+			FileName:     rec.FileName(),
+			RegisterDate: time.Now(),
+			DocDate:      rec.Date,
+			Lang:         lang,
+			Title:        rec.Title,
+			Approved:     true,
+			LibID:        rec.LibID,
+		},
+		authors: authors,
+		series:  series,
+		tags:    rec.Genres,
+	}
+}
+
+// flushBatch inserts a batch in one transaction. Should that fail, the books
+// are inserted one at a time, so one bad book costs only itself.
+func (s *BookScanService) flushBatch(archiveName string, batch []*preparedBook, report *ArchiveReport) {
+	if len(batch) == 0 {
+		return
+	}
+	batch = s.dropDuplicates(batch, report)
+
+	if err := s.insertBooks(batch); err != nil && len(batch) > 1 {
+		logging.Warnf("Batch insert of %d books from %s failed, inserting them one by one: %v", len(batch), archiveName, err)
+		for _, p := range batch {
+			s.finishBook(report, archiveName, p, s.insertBooks([]*preparedBook{p}))
+		}
+	} else {
+		for _, p := range batch {
+			s.finishBook(report, archiveName, p, err)
+		}
+	}
+
+	_ = database.UpdateScanProgress(archiveName, report.BooksProcessed, len(report.Errors))
+}
+
+// dropDuplicates takes out of the batch the books the catalog already has,
+// by MD5 or library id, and the repeats within the batch, when the scanner
+// skips duplicates.
+func (s *BookScanService) dropDuplicates(batch []*preparedBook, report *ArchiveReport) []*preparedBook {
+	if !s.skipDuplicates {
+		return batch
+	}
+	var hashes, libIDs []string
+	for _, p := range batch {
+		if p.book.MD5 != "" {
+			hashes = append(hashes, p.book.MD5)
+		}
+		if p.book.LibID != "" {
+			libIDs = append(libIDs, p.book.LibID)
+		}
+	}
+	knownHashes, err := database.ExistingBookMD5s(hashes)
+	if err != nil {
+		logging.Warnf("Duplicate check failed, proceeding anyway: %v", err)
+		knownHashes = map[string]bool{}
+	}
+	knownLibIDs, err := database.ExistingLibIDs(libIDs)
+	if err != nil {
+		logging.Warnf("Duplicate check failed, proceeding anyway: %v", err)
+		knownLibIDs = map[string]bool{}
+	}
+
+	kept := batch[:0]
+	for _, p := range batch {
+		duplicate := (p.book.MD5 != "" && knownHashes[p.book.MD5]) ||
+			(p.book.LibID != "" && knownLibIDs[p.book.LibID])
+		if duplicate {
+			report.BooksSkipped++
+			logging.Debugf("Skipped duplicate book: %s in %s", p.book.FileName, p.book.Path)
+			s.fileDone()
+			continue
+		}
+		if p.book.MD5 != "" {
+			knownHashes[p.book.MD5] = true
+		}
+		if p.book.LibID != "" {
+			knownLibIDs[p.book.LibID] = true
+		}
+		kept = append(kept, p)
+	}
+	return kept
+}
+
+// insertBooks inserts the books and links their authors, series and genres
+// in one transaction, holding locks on those names so that archives scanned
+// at the same time do not create one author twice.
+func (s *BookScanService) insertBooks(batch []*preparedBook) error {
+	if len(batch) == 0 {
+		return nil
+	}
+	return database.GetDB().RunInTransaction(context.Background(), func(tx *pg.Tx) error {
+		return s.insertBatch(tx, batch)
+	})
+}
+
+// insertBatch is insertBooks within a transaction the caller runs.
+func (s *BookScanService) insertBatch(tx *pg.Tx, batch []*preparedBook) error {
+	books := make([]*models.Book, len(batch))
+	for i, p := range batch {
+		// A failed attempt leaves the ids it was given behind.
+		p.book.ID = 0
+		books[i] = p.book
+	}
+	if err := database.LockCatalogNames(tx, catalogNames(batch)); err != nil {
+		return fmt.Errorf("failed to lock catalog names: %w", err)
+	}
+	if _, err := tx.Model(&books).Insert(); err != nil {
+		return fmt.Errorf("failed to insert book: %w", err)
+	}
+	for _, p := range batch {
+		if err := s.ProcessAuthors(tx, p.book.ID, p.authors); err != nil {
+			return fmt.Errorf("failed to process authors: %w", err)
+		}
+		if p.series != nil {
+			if err := s.ProcessSeries(tx, p.book.ID, p.series); err != nil {
+				return fmt.Errorf("failed to process series: %w", err)
+			}
+		}
+		if len(p.tags) > 0 {
+			if err := database.UpdateBookTags(tx, p.book.ID, p.tags, s.llmService); err != nil {
+				return fmt.Errorf("failed to process genres: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+// finishBook records the outcome of inserting one book and, for a book now
+// in the catalog, writes its cover.
+func (s *BookScanService) finishBook(report *ArchiveReport, archiveName string, p *preparedBook, err error) {
+	defer s.fileDone()
+	if err != nil {
+		s.recordFailure(report, archiveName, p.book.FileName, err)
+		return
+	}
+	if len(p.cover) > 0 {
+		if err := s.ProcessCover(p.book, p.cover); err != nil {
+			logging.Warnf("Failed to save cover for %s: %v", p.book.FileName, err)
+			// Don't fail the entire operation if cover save fails
+		}
+	}
+	report.BooksProcessed++
+	logging.Debugf("Successfully processed book ID %d: %s", p.book.ID, p.book.FileName)
+	if s.publisher != nil {
+		s.publisher.PublishBookProcessed(archiveName, p.book.Title, p.book.ID)
+	}
+}
+
+// recordFailure files a file that produced no book: skipped, or an error.
+func (s *BookScanService) recordFailure(report *ArchiveReport, archiveName, fileName string, err error) {
+	if errors.Is(err, errBookSkipped) {
+		report.BooksSkipped++
+		logging.Debugf("Skipped book: %s in %s", fileName, archiveName)
+		return
+	}
+	report.Errors = append(report.Errors, ScanError{
+		FileName:    fileName,
+		ArchiveName: archiveName,
+		Error:       err.Error(),
+		Timestamp:   time.Now(),
+	})
+	logging.Warnf("Failed to process book %s in %s: %v", fileName, archiveName, err)
+}
+
+// fileDone counts one file of the scan as finished.
+func (s *BookScanService) fileDone() {
+	s.progressMu.Lock()
+	s.filesDone++
+	s.progressMu.Unlock()
+}
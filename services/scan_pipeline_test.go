@@ -0,0 +1,101 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"gopds-api/database"
+	"gopds-api/internal/inpx"
+	"gopds-api/internal/parser"
+)
+
+func TestSetConcurrency_IgnoresNonPositive(t *testing.T) {
+	s := NewBookScanService("", "", nil, false, nil)
+	s.SetConcurrency(0, -1)
+	if s.workers != defaultScanWorkers || s.batchSize != defaultScanBatchSize {
+		t.Fatalf("defaults replaced: workers=%d batch=%d", s.workers, s.batchSize)
+	}
+	s.SetConcurrency(4, 200)
+	if s.workers != 4 || s.batchSize != 200 {
+		t.Fatalf("got workers=%d batch=%d, want 4 and 200", s.workers, s.batchSize)
+	}
+}
+
+func TestCatalogNames(t *testing.T) {
+	batch := []*preparedBook{
+		{authors: []parser.Author{{Name: "Толстой Лев"}}, series: &parser.Series{Title: "Война и мир"}, tags: []string{"prose_classic"}},
+		{authors: []parser.Author{{Name: "Чехов Антон"}}},
+	}
+	got := catalogNames(batch)
+	want := []database.CatalogName{
+		{Kind: database.LockAuthor, Name: "Толстой Лев"},
+		{Kind: database.LockSeries, Name: "Война и мир"},
+		{Kind: database.LockGenre, Name: "prose_classic"},
+		{Kind: database.LockAuthor, Name: "Чехов Антон"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("catalogNames = %v, want %v", got, want)
+	}
+}
+
+// zipFiles builds an archive in memory and returns its entries.
+func zipFiles(t *testing.T, contents map[string]string) []*zip.File {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, body := range contents {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return zr.File
+}
+
+// The pipeline accounts for every file exactly once, however many workers
+// run: deleted index records are skipped, unparsable files are reported.
+// None of these reach the database.
+func TestRunPipeline_CountsSkipsAndFailures(t *testing.T) {
+	contents := make(map[string]string)
+	records := make(map[string]inpx.Record)
+	for i := 0; i < 20; i++ {
+		name := fmt.Sprintf("%d.fb2", i)
+		contents[name] = "not xml"
+		if i%2 == 0 {
+			records[name] = inpx.Record{File: fmt.Sprint(i), Ext: "fb2", Title: "Deleted", Deleted: true}
+		}
+	}
+	files := zipFiles(t, contents)
+
+	s := NewBookScanService("", "", nil, false, nil)
+	s.SetConcurrency(4, 3)
+	s.filesTotal = len(files)
+	report := &ArchiveReport{ArchiveName: "test.zip"}
+	s.runPipeline("test.zip", files, records, nil, report)
+
+	if report.BooksSkipped != 10 {
+		t.Errorf("skipped = %d, want 10", report.BooksSkipped)
+	}
+	if len(report.Errors) != 10 {
+		t.Errorf("errors = %d, want 10", len(report.Errors))
+	}
+	if len(records) != 0 {
+		t.Errorf("records left = %d, want 0", len(records))
+	}
+	if done, total := s.GetScanProgress(); done != total || total != 20 {
+		t.Errorf("progress = %d/%d, want 20/20", done, total)
+	}
+}
@@ -81,6 +81,8 @@ type SearchRepository interface {
 	SearchBooks(ctx context.Context, req models.BookSearchRequest) (models.BookSearchPage, error)
 	SearchAuthors(ctx context.Context, req models.AuthorSearchRequest) (models.AuthorSearchPage, error)
 	Suggestions(ctx context.Context, req models.SuggestionRequest) (models.SuggestionResult, error)
+	SpellingCandidates(ctx context.Context, query string, perWord int) ([]models.TokenCandidates, error)
+	BookFacets(ctx context.Context, req models.BookSearchRequest, sample, perFacet int) (models.BookFacets, error)
 }
 
 // PublicSearch is the adapter-facing search surface: REST handlers, bots and
@@ -95,9 +97,13 @@ var _ PublicSearch = (*SearchService)(nil)
 
 // SearchService validates and normalizes search requests before they reach
 // the repository. It does not rank or filter rows — that is the database's
-// job — it owns the boundary rules: trimming, pagination, language codes.
+// job — it owns the boundary rules: trimming, pagination, language codes —
+// and, when asked to, retries a query typed on the wrong layout or in
+// transliteration and suggests what a query that found nothing meant.
 type SearchService struct {
-	repo SearchRepository
+	repo     SearchRepository
+	rewrites bool
+	spelling bool
 }
 
 // NewSearchService wires the service to a repository.
@@ -131,6 +137,18 @@ func (s *SearchService) SearchBooks(ctx context.Context, req models.BookSearchRe
 		req.IncludeHidden = false
 	}
 	page, err := s.repo.SearchBooks(ctx, req)
+	// A pinned book or an author narrowing is not free text to retype.
+	if err == nil && s.rewrites && page.Total < rewriteBelowTotal &&
+		req.Query != "" && req.ExactBookID <= 0 && req.AuthorQuery == "" {
+		page, err = s.rewriteBooks(ctx, req, page)
+	}
+	if err == nil && s.spelling && page.Total == 0 && req.Offset == 0 &&
+		req.Query != "" && req.ExactBookID <= 0 && req.AuthorQuery == "" {
+		page.DidYouMean, err = s.suggestSpellings(ctx, req)
+	}
+	if err == nil && req.Facets && page.Total > 0 {
+		page.Facets, err = s.bookFacets(ctx, req, &page)
+	}
 	logCompletion(modeBooks, req.Query, req.Language, bookScope(req), len(page.Books), page.Total, page.QueryHash, err, start)
 	return page, err
 }
@@ -152,6 +170,9 @@ func (s *SearchService) SearchAuthors(ctx context.Context, req models.AuthorSear
 		return models.AuthorSearchPage{}, err
 	}
 	page, err := s.repo.SearchAuthors(ctx, req)
+	if err == nil && s.rewrites && page.Total < rewriteBelowTotal {
+		page, err = s.rewriteAuthors(ctx, req, page)
+	}
 	logCompletion(modeAuthors, req.Query, req.Language, scopeNone, len(page.Authors), page.Total, page.QueryHash, err, start)
 	return page, err
 }
@@ -170,7 +191,8 @@ func (s *SearchService) Suggestions(ctx context.Context, req models.SuggestionRe
 		req.Kind = models.SuggestionAll
 	}
 	switch req.Kind {
-	case models.SuggestionAll, models.SuggestionBook, models.SuggestionAuthor:
+	case models.SuggestionAll, models.SuggestionBook, models.SuggestionAuthor,
+		models.SuggestionSeries, models.SuggestionGenre:
 	default:
 		err := ErrInvalidSuggestionKind
 		logCompletion(modeSuggest, req.Query, req.Language, string(req.Kind), 0, 0, "", err, start)
@@ -0,0 +1,42 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"gopds-api/logging"
+	"gopds-api/models"
+)
+
+// Facet sizing. A search that asks for facets has its facetSample most
+// relevant books counted, the facetValues commonest values of each facet
+// kept, and facetTimeout to do it in. Facets refine a result; they are never
+// worth a slow one.
+const (
+	facetSample  = 2000
+	facetValues  = 10
+	facetTimeout = 500 * time.Millisecond
+)
+
+// bookFacets counts a result by facet. It only refines: a failure or a
+// timeout costs the facets, never the search, unless the caller is gone. A
+// page answering a rewritten query is counted for that query, since its
+// books are.
+//
+//nolint:gocritic // the port takes the request by value; so does this
+func (s *SearchService) bookFacets(
+	ctx context.Context, req models.BookSearchRequest, page *models.BookSearchPage,
+) (*models.BookFacets, error) {
+	if page.Rewrite != nil {
+		req.Query = page.Rewrite.Query
+	}
+	facetCtx, cancel := context.WithTimeout(ctx, facetTimeout)
+	defer cancel()
+	facets, err := s.repo.BookFacets(facetCtx, req, facetSample, facetValues)
+	if err != nil {
+		logging.Warnf("Search facets: %v", err)
+		return nil, ctx.Err()
+	}
+	facets.Sampled = page.Total > facetSample
+	return &facets, nil
+}
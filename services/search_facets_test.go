@@ -0,0 +1,101 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"gopds-api/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// facetRepository counts whatever it is asked with canned facets, or fails
+// or stalls the way a slow count does.
+type facetRepository struct {
+	queryRepository
+
+	facets     models.BookFacets
+	facetErr   error
+	blockFacet bool
+
+	facetReqs []models.BookSearchRequest
+	sample    int
+}
+
+//nolint:gocritic // the port takes the request by value; this implements it
+func (r *facetRepository) BookFacets(
+	ctx context.Context, req models.BookSearchRequest, sample, _ int,
+) (models.BookFacets, error) {
+	r.facetReqs = append(r.facetReqs, req)
+	r.sample = sample
+	if r.blockFacet {
+		<-ctx.Done()
+		return models.BookFacets{}, ctx.Err()
+	}
+	return r.facets, r.facetErr
+}
+
+func TestSearchServiceCountsFacets(t *testing.T) {
+	russian := models.BookFacets{Language: []models.FacetCount{{Value: "ru", Label: "ru", Count: 3}}}
+
+	t.Run("a search that asks is counted", func(t *testing.T) {
+		repo := &facetRepository{queryRepository: queryRepository{books: map[string][]int64{"война": {1, 2, 3}}}, facets: russian}
+
+		page, err := NewSearchService(repo).SearchBooks(context.Background(),
+			models.BookSearchRequest{Query: "война", Facets: true, Limit: 2})
+		require.NoError(t, err)
+		require.NotNil(t, page.Facets)
+		assert.Equal(t, russian.Language, page.Facets.Language)
+		assert.False(t, page.Facets.Sampled)
+		assert.Equal(t, facetSample, repo.sample)
+	})
+
+	t.Run("a search that does not ask, or finds nothing, is not", func(t *testing.T) {
+		repo := &facetRepository{queryRepository: queryRepository{books: map[string][]int64{"война": {1}}}, facets: russian}
+		svc := NewSearchService(repo)
+
+		page, err := svc.SearchBooks(context.Background(), models.BookSearchRequest{Query: "война", Limit: 10})
+		require.NoError(t, err)
+		assert.Nil(t, page.Facets)
+
+		page, err = svc.SearchBooks(context.Background(), models.BookSearchRequest{Query: "мир", Facets: true, Limit: 10})
+		require.NoError(t, err)
+		assert.Nil(t, page.Facets)
+		assert.Empty(t, repo.facetReqs)
+	})
+
+	t.Run("a rewritten page is counted for the rewrite", func(t *testing.T) {
+		repo := &facetRepository{queryRepository: queryRepository{books: map[string][]int64{"война": {1, 2, 3}}}, facets: russian}
+
+		_, err := NewSearchService(repo).WithQueryRewrites().SearchBooks(context.Background(),
+			models.BookSearchRequest{Query: "djqyf", Facets: true, Limit: 10})
+		require.NoError(t, err)
+		require.Len(t, repo.facetReqs, 1)
+		assert.Equal(t, "война", repo.facetReqs[0].Query)
+	})
+
+	t.Run("a failed or slow count costs the facets, not the search", func(t *testing.T) {
+		for name, repo := range map[string]*facetRepository{
+			"failed": {facetErr: errors.New("facets exploded")},
+			"slow":   {blockFacet: true},
+		} {
+			repo.books = map[string][]int64{"война": {1, 2}}
+			page, err := NewSearchService(repo).SearchBooks(context.Background(),
+				models.BookSearchRequest{Query: "война", Facets: true, Limit: 10})
+			require.NoError(t, err, name)
+			assert.Len(t, page.Books, 2, name)
+			assert.Nil(t, page.Facets, name)
+		}
+	})
+
+	t.Run("a caller that is gone still gets its error", func(t *testing.T) {
+		repo := &facetRepository{queryRepository: queryRepository{books: map[string][]int64{"война": {1}}}, blockFacet: true}
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err := NewSearchService(repo).SearchBooks(ctx, models.BookSearchRequest{Query: "война", Facets: true, Limit: 10})
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+}
@@ -0,0 +1,166 @@
+package services
+
+import (
+	"context"
+	"strings"
+
+	"gopds-api/models"
+)
+
+// rewriteBelowTotal is where a query starts to score poorly: one that
+// matches fewer books or authors than this is tried again on the other
+// keyboard layout and as transliteration. Typed right, a title or a surname
+// nearly always finds more; typed on the wrong layout it finds nothing, or
+// a stray fuzzy match or two.
+const rewriteBelowTotal = 3
+
+// WithQueryRewrites makes a poorly scoring query try its rewrites. Off by
+// default, so a service built for a tool measures the query as typed unless
+// it asks otherwise.
+func (s *SearchService) WithQueryRewrites() *SearchService {
+	s.rewrites = true
+	return s
+}
+
+// queryRewrites are the variants of q worth a second search, layout first:
+// a wrong layout is the commoner mistake, and its swap is unambiguous.
+func queryRewrites(q string) []models.QueryRewrite {
+	typed := strings.ToLower(q)
+	var out []models.QueryRewrite
+	if v, ok := models.SwapKeyboardLayout(q); ok && v != typed {
+		out = append(out, models.QueryRewrite{Query: v, Kind: models.RewriteLayout})
+	}
+	if v, ok := models.TranslitQuery(q); ok && v != typed && (len(out) == 0 || v != out[0].Query) {
+		out = append(out, models.QueryRewrite{Query: v, Kind: models.RewriteTranslit})
+	}
+	return out
+}
+
+// The rewritten results rank below everything the query as typed found:
+// that is the penalty. Past the original's few rows come the best
+// rewrite's, less the ones already shown. Only a poorly scoring original is
+// merged, so its rows are never more than rewriteBelowTotal-1 and the whole
+// of it is at hand. The rewrite's rows are fetched from the top down to the
+// end of the page asked for, as the window has to be counted after the
+// duplicates are dropped; the database ranks those rows for any OFFSET
+// anyway. A duplicate further down the rewrite than that is not seen, and
+// the total counts it twice.
+
+// rewriteBooks answers a poorly scoring book search with the best of its
+// rewrites merged below it, or with the original page when no rewrite finds
+// anything.
+//
+//nolint:gocritic // the port takes the request by value; so does this
+func (s *SearchService) rewriteBooks(
+	ctx context.Context, req models.BookSearchRequest, orig models.BookSearchPage,
+) (models.BookSearchPage, error) {
+	head := orig.Books
+	if orig.Total > 0 && (req.Offset > 0 || len(head) < orig.Total) {
+		all := req
+		all.Offset, all.Limit = 0, rewriteBelowTotal
+		page, err := s.repo.SearchBooks(ctx, all)
+		if err != nil {
+			return models.BookSearchPage{}, err
+		}
+		head = page.Books
+	}
+
+	var best models.BookSearchPage
+	for _, rw := range queryRewrites(req.Query) {
+		variant := req
+		variant.Query = rw.Query
+		if len(head) > 0 {
+			variant.Offset, variant.Limit = 0, req.Offset+req.Limit+len(head)
+		}
+		page, err := s.repo.SearchBooks(ctx, variant)
+		if err != nil {
+			return models.BookSearchPage{}, err
+		}
+		if page.Total > best.Total {
+			rewrite := rw
+			best, best.Rewrite = page, &rewrite
+		}
+	}
+	if best.Rewrite == nil {
+		return orig, nil
+	}
+
+	merged := best
+	merged.Limit, merged.Offset, merged.QueryHash = req.Limit, req.Offset, orig.QueryHash
+	if len(head) > 0 {
+		var dups int
+		merged.Books, dups = mergeBelow(head, best.Books,
+			func(b models.Book) int64 { return b.ID }, req.Offset, req.Limit)
+		merged.Total = len(head) + best.Total - dups
+	}
+	return merged, nil
+}
+
+// rewriteAuthors is rewriteBooks for authors.
+func (s *SearchService) rewriteAuthors(
+	ctx context.Context, req models.AuthorSearchRequest, orig models.AuthorSearchPage,
+) (models.AuthorSearchPage, error) {
+	head := orig.Authors
+	if orig.Total > 0 && (req.Offset > 0 || len(head) < orig.Total) {
+		all := req
+		all.Offset, all.Limit = 0, rewriteBelowTotal
+		page, err := s.repo.SearchAuthors(ctx, all)
+		if err != nil {
+			return models.AuthorSearchPage{}, err
+		}
+		head = page.Authors
+	}
+
+	var best models.AuthorSearchPage
+	for _, rw := range queryRewrites(req.Query) {
+		variant := req
+		variant.Query = rw.Query
+		if len(head) > 0 {
+			variant.Offset, variant.Limit = 0, req.Offset+req.Limit+len(head)
+		}
+		page, err := s.repo.SearchAuthors(ctx, variant)
+		if err != nil {
+			return models.AuthorSearchPage{}, err
+		}
+		if page.Total > best.Total {
+			rewrite := rw
+			best, best.Rewrite = page, &rewrite
+		}
+	}
+	if best.Rewrite == nil {
+		return orig, nil
+	}
+
+	merged := best
+	merged.Limit, merged.Offset, merged.QueryHash = req.Limit, req.Offset, orig.QueryHash
+	if len(head) > 0 {
+		var dups int
+		merged.Authors, dups = mergeBelow(head, best.Authors,
+			func(a models.Author) int64 { return a.ID }, req.Offset, req.Limit)
+		merged.Total = len(head) + best.Total - dups
+	}
+	return merged, nil
+}
+
+// mergeBelow cuts the page at offset and limit out of head followed by the
+// rows of rest that head does not already hold, and counts those it left
+// out.
+func mergeBelow[T any](head, rest []T, id func(T) int64, offset, limit int) (page []T, dups int) {
+	seen := make(map[int64]struct{}, len(head))
+	all := make([]T, 0, len(head)+len(rest))
+	for _, row := range head {
+		seen[id(row)] = struct{}{}
+		all = append(all, row)
+	}
+	for _, row := range rest {
+		if _, ok := seen[id(row)]; ok {
+			dups++
+			continue
+		}
+		all = append(all, row)
+	}
+	if offset >= len(all) {
+		return []T{}, dups
+	}
+	return all[offset:min(offset+limit, len(all))], dups
+}
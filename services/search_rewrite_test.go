@@ -0,0 +1,168 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"gopds-api/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// queryRepository answers each query with the rows listed for it, paged the
+// way the database pages them, and records every request it receives.
+type queryRepository struct {
+	fakeSearchRepository
+
+	books   map[string][]int64
+	authors map[string][]int64
+
+	bookReqs   []models.BookSearchRequest
+	authorReqs []models.AuthorSearchRequest
+}
+
+func pageOf(ids []int64, offset, limit int) []int64 {
+	if offset >= len(ids) {
+		return nil
+	}
+	return ids[offset:min(offset+limit, len(ids))]
+}
+
+//nolint:gocritic // the port takes the request by value; this implements it
+func (r *queryRepository) SearchBooks(_ context.Context, req models.BookSearchRequest) (models.BookSearchPage, error) {
+	r.bookReqs = append(r.bookReqs, req)
+	ids := r.books[req.Query]
+	page := models.BookSearchPage{Total: len(ids), Limit: req.Limit, Offset: req.Offset, QueryHash: "hash:" + req.Query}
+	for _, id := range pageOf(ids, req.Offset, req.Limit) {
+		page.Books = append(page.Books, models.Book{ID: id})
+	}
+	return page, nil
+}
+
+func (r *queryRepository) SearchAuthors(_ context.Context, req models.AuthorSearchRequest) (models.AuthorSearchPage, error) {
+	r.authorReqs = append(r.authorReqs, req)
+	ids := r.authors[req.Query]
+	page := models.AuthorSearchPage{Total: len(ids), Limit: req.Limit, Offset: req.Offset, QueryHash: "hash:" + req.Query}
+	for _, id := range pageOf(ids, req.Offset, req.Limit) {
+		page.Authors = append(page.Authors, models.Author{ID: id})
+	}
+	return page, nil
+}
+
+func bookIDs(books []models.Book) []int64 {
+	ids := make([]int64, 0, len(books))
+	for i := range books {
+		ids = append(ids, books[i].ID)
+	}
+	return ids
+}
+
+func TestSearchServiceRewritesAPoorQuery(t *testing.T) {
+	t.Run("a query that finds nothing answers with its rewrite's page", func(t *testing.T) {
+		repo := &queryRepository{books: map[string][]int64{"война и мир": {1, 2, 3, 4, 5}}}
+		svc := NewSearchService(repo).WithQueryRewrites()
+
+		page, err := svc.SearchBooks(context.Background(), models.BookSearchRequest{Query: "djqyf b vbh", Limit: 2, Offset: 2})
+		require.NoError(t, err)
+
+		assert.Equal(t, []int64{3, 4}, bookIDs(page.Books))
+		assert.Equal(t, 5, page.Total)
+		assert.Equal(t, 2, page.Limit)
+		assert.Equal(t, 2, page.Offset)
+		assert.Equal(t, &models.QueryRewrite{Query: "война и мир", Kind: models.RewriteLayout}, page.Rewrite)
+		assert.Equal(t, "hash:djqyf b vbh", page.QueryHash, "the log correlates with the query typed")
+	})
+
+	t.Run("transliteration is tried when the layout finds nothing", func(t *testing.T) {
+		repo := &queryRepository{books: map[string][]int64{"война и мир": {7}}}
+		svc := NewSearchService(repo).WithQueryRewrites()
+
+		page, err := svc.SearchBooks(context.Background(), models.BookSearchRequest{Query: "voyna i mir", Limit: 10})
+		require.NoError(t, err)
+
+		assert.Equal(t, []int64{7}, bookIDs(page.Books))
+		assert.Equal(t, &models.QueryRewrite{Query: "война и мир", Kind: models.RewriteTranslit}, page.Rewrite)
+		require.Len(t, repo.bookReqs, 3, "the query, its layout swap, its transliteration")
+	})
+
+	t.Run("what the query finds stays on top and is not repeated", func(t *testing.T) {
+		repo := &queryRepository{books: map[string][]int64{
+			"djqyf": {9, 1},
+			"война": {1, 2, 3, 4},
+		}}
+		svc := NewSearchService(repo).WithQueryRewrites()
+
+		first, err := svc.SearchBooks(context.Background(), models.BookSearchRequest{Query: "djqyf", Limit: 3})
+		require.NoError(t, err)
+		assert.Equal(t, []int64{9, 1, 2}, bookIDs(first.Books))
+		assert.Equal(t, 5, first.Total, "2 + 4, less the book both found")
+		assert.Equal(t, models.RewriteLayout, first.Rewrite.Kind)
+
+		second, err := svc.SearchBooks(context.Background(), models.BookSearchRequest{Query: "djqyf", Limit: 3, Offset: 3})
+		require.NoError(t, err)
+		assert.Equal(t, []int64{3, 4}, bookIDs(second.Books), "the next page follows on without a gap or a repeat")
+		assert.Equal(t, 5, second.Total)
+	})
+
+	t.Run("no rewrite that finds anything leaves the page alone", func(t *testing.T) {
+		repo := &queryRepository{books: map[string][]int64{"solaris": {5}}}
+		svc := NewSearchService(repo).WithQueryRewrites()
+
+		page, err := svc.SearchBooks(context.Background(), models.BookSearchRequest{Query: "solaris", Limit: 10})
+		require.NoError(t, err)
+		assert.Equal(t, []int64{5}, bookIDs(page.Books))
+		assert.Nil(t, page.Rewrite)
+	})
+
+	t.Run("authors are rewritten the same way", func(t *testing.T) {
+		repo := &queryRepository{authors: map[string][]int64{"толстой": {11, 12}}}
+		svc := NewSearchService(repo).WithQueryRewrites()
+
+		page, err := svc.SearchAuthors(context.Background(), models.AuthorSearchRequest{Query: "njkcnjq", Limit: 10})
+		require.NoError(t, err)
+		require.Len(t, page.Authors, 2)
+		assert.Equal(t, 2, page.Total)
+		assert.Equal(t, &models.QueryRewrite{Query: "толстой", Kind: models.RewriteLayout}, page.Rewrite)
+	})
+}
+
+func TestSearchServiceLeavesAGoodQueryAlone(t *testing.T) {
+	cases := []struct {
+		name string
+		svc  func(repo SearchRepository) *SearchService
+		req  models.BookSearchRequest
+	}{
+		{
+			name: "a query that finds enough",
+			svc:  func(repo SearchRepository) *SearchService { return NewSearchService(repo).WithQueryRewrites() },
+			req:  models.BookSearchRequest{Query: "война", Limit: 10},
+		},
+		{
+			name: "a service that was not asked to rewrite",
+			svc:  NewSearchService,
+			req:  models.BookSearchRequest{Query: "djqyf", Limit: 10},
+		},
+		{
+			name: "a pinned book",
+			svc:  func(repo SearchRepository) *SearchService { return NewSearchService(repo).WithQueryRewrites() },
+			req:  models.BookSearchRequest{Query: "djqyf", ExactBookID: 8, Limit: 10},
+		},
+		{
+			name: "a search narrowed by author",
+			svc:  func(repo SearchRepository) *SearchService { return NewSearchService(repo).WithQueryRewrites() },
+			req:  models.BookSearchRequest{Query: "djqyf", AuthorQuery: "njkcnjq", Limit: 10},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			repo := &queryRepository{books: map[string][]int64{"война": {1, 2, 3}}}
+
+			page, err := tc.svc(repo).SearchBooks(context.Background(), tc.req)
+			require.NoError(t, err)
+			assert.Len(t, repo.bookReqs, 1)
+			assert.Nil(t, page.Rewrite)
+		})
+	}
+}
@@ -70,6 +70,15 @@ func (f *fakeSearchRepository) Suggestions(_ context.Context, req models.Suggest
 	return f.suggResult, nil
 }
 
+func (f *fakeSearchRepository) SpellingCandidates(_ context.Context, _ string, _ int) ([]models.TokenCandidates, error) {
+	return nil, f.err
+}
+
+//nolint:gocritic // the port takes the request by value; this implements it
+func (f *fakeSearchRepository) BookFacets(_ context.Context, _ models.BookSearchRequest, _, _ int) (models.BookFacets, error) {
+	return models.BookFacets{}, f.err
+}
+
 func TestSearchServiceBookValidation(t *testing.T) {
 	repoErr := errors.New("repository exploded")
 
@@ -360,6 +369,14 @@ func TestSearchServiceSuggestionsValidation(t *testing.T) {
 				assert.Equal(t, models.SuggestionAll, req.Kind)
 			},
 		},
+		{
+			name:      "the series and genre lanes are kinds of their own",
+			req:       models.SuggestionRequest{Query: "война", Kind: models.SuggestionGenre},
+			wantCalls: 1,
+			check: func(t *testing.T, req models.SuggestionRequest) {
+				assert.Equal(t, models.SuggestionGenre, req.Kind)
+			},
+		},
 		{
 			name:      "an unknown kind is rejected",
 			req:       models.SuggestionRequest{Query: "война", Kind: "titles"},
@@ -0,0 +1,77 @@
+package services
+
+import (
+	"context"
+
+	"gopds-api/database"
+	"gopds-api/models"
+	"gopds-api/sessions"
+)
+
+// SessionService lists the places a user is signed in from and revokes
+// them: web logins and OPDS readers from the session index in Redis, the
+// Telegram bot from the user's record.
+type SessionService struct{}
+
+// NewSessionService returns the session service.
+func NewSessionService() *SessionService {
+	return &SessionService{}
+}
+
+// List returns a user's sessions, most recently used first, with the
+// Telegram bot last. current is the session the request came from.
+func (s *SessionService) List(ctx context.Context, userID int64, current string) ([]models.Session, error) {
+	list, err := sessions.ListSessions(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range list {
+		list[i].Current = current != "" && list[i].ID == current
+	}
+
+	user, err := database.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.BotToken != "" {
+		device := "Telegram bot"
+		if user.TelegramID == 0 {
+			device = "Telegram bot, no account linked yet"
+		}
+		list = append(list, models.Session{
+			ID:     models.SessionTelegram,
+			Kind:   models.SessionTelegram,
+			Device: device,
+		})
+	}
+	return list, nil
+}
+
+// Revoke ends one session. A web session is signed out; an OPDS reader is
+// refused until the password changes, since it holds the password itself;
+// the Telegram bot is taken down and unlinked. It returns
+// sessions.ErrSessionNotFound for a session the user does not have.
+func (s *SessionService) Revoke(ctx context.Context, userID int64, id string) error {
+	switch sessions.SessionKind(id) {
+	case models.SessionTelegram:
+		user, err := database.GetUserByID(ctx, userID)
+		if err != nil {
+			return err
+		}
+		if user.BotToken == "" {
+			return sessions.ErrSessionNotFound
+		}
+		return database.RemoveUserBot(user)
+	case models.SessionOPDS:
+		if err := sessions.RevokeSession(ctx, userID, id); err != nil {
+			return err
+		}
+		user, err := database.GetUserByID(ctx, userID)
+		if err != nil {
+			return err
+		}
+		return sessions.BlockOPDSSession(ctx, userID, id, user.Password)
+	default:
+		return sessions.RevokeSession(ctx, userID, id)
+	}
+}
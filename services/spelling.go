@@ -0,0 +1,225 @@
+package services
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"gopds-api/database"
+	"gopds-api/logging"
+	"gopds-api/models"
+)
+
+// Spelling suggestion sizing. A search that finds nothing reads each of its
+// words against the dictionary's spellingCandidatesPerWord nearest words,
+// composes corrected queries from the spellingChoicesPerWord closest of
+// them, and runs at most maxSpellingChecks of those to keep the
+// maxSpellingSuggestions that find something. The checks are what a
+// suggestion costs; they are paid only by searches that found nothing.
+const (
+	spellingCandidatesPerWord = 8
+	spellingChoicesPerWord    = 2
+	maxSpellingChecks         = 5
+	maxSpellingSuggestions    = 3
+	// maxSpellingCombinations bounds the corrected queries a long query
+	// composes, two choices a word.
+	maxSpellingCombinations = 64
+)
+
+// searchDictionaryInterval is how often the search dictionary is rebuilt
+// besides after a scan: approving, hiding and editing books change it too.
+const searchDictionaryInterval = 24 * time.Hour
+
+// RunSearchDictionaryRefresh rebuilds the search dictionary daily until ctx
+// is done.
+func RunSearchDictionaryRefresh(ctx context.Context) {
+	ticker := time.NewTicker(searchDictionaryInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			RefreshSearchDictionary(ctx)
+		}
+	}
+}
+
+// RefreshSearchDictionary rebuilds the search dictionary now. A failure is
+// logged: suggestions go on from the previous one.
+func RefreshSearchDictionary(ctx context.Context) {
+	start := time.Now()
+	if err := database.RefreshSearchDictionary(ctx); err != nil {
+		logging.Errorf("Search dictionary: %v", err)
+		return
+	}
+	logging.Infof("Search dictionary refreshed in %v", time.Since(start))
+}
+
+// WithSpellingSuggestions makes a search that finds nothing suggest what it
+// may have meant. Off by default, like WithQueryRewrites.
+func (s *SearchService) WithSpellingSuggestions() *SearchService {
+	s.spelling = true
+	return s
+}
+
+// suggestSpellings finds the corrected queries that find something, best
+// first. It only suggests: a failure costs the suggestions, never the
+// search, unless the caller is gone.
+//
+//nolint:gocritic // the port takes the request by value; so does this
+func (s *SearchService) suggestSpellings(ctx context.Context, req models.BookSearchRequest) ([]models.SpellingSuggestion, error) {
+	words, err := s.repo.SpellingCandidates(ctx, req.Query, spellingCandidatesPerWord)
+	if err != nil {
+		logging.Warnf("Spelling suggestions: %v", err)
+		return nil, ctx.Err()
+	}
+	var out []models.SpellingSuggestion
+	for i, query := range correctedQueries(words) {
+		if i == maxSpellingChecks || len(out) == maxSpellingSuggestions {
+			break
+		}
+		check := req
+		check.Query, check.Offset, check.Limit = query, 0, 1
+		page, err := s.repo.SearchBooks(ctx, check)
+		if err != nil {
+			logging.Warnf("Spelling suggestions: %v", err)
+			return out, ctx.Err()
+		}
+		if page.Total > 0 {
+			out = append(out, models.SpellingSuggestion{Query: query, Total: page.Total})
+		}
+	}
+	return out, nil
+}
+
+// spellingChoice is one way to spell a word of a query: its edits from the
+// word typed and how common it is in the catalogue.
+type spellingChoice struct {
+	token     string
+	edits     int
+	frequency int
+}
+
+// correctedQueries composes the queries a query's words may have meant, the
+// fewest edits first and, among equals, the commonest words. A word the
+// catalogue uses is kept as typed, and so is one with nothing near enough
+// to it. No query is returned when no word needs correcting.
+func correctedQueries(words []models.TokenCandidates) []string {
+	choices := make([][]spellingChoice, 0, len(words))
+	corrected := false
+	for _, w := range words {
+		options := wordChoices(w)
+		if options[0].edits > 0 {
+			corrected = true
+		}
+		choices = append(choices, options)
+	}
+	if !corrected {
+		return nil
+	}
+
+	type combination struct {
+		tokens    []string
+		edits     int
+		frequency int
+	}
+	combos := []combination{{}}
+	for _, options := range choices {
+		next := make([]combination, 0, len(combos)*len(options))
+		for _, c := range combos {
+			for _, o := range options {
+				if len(next) == maxSpellingCombinations {
+					break
+				}
+				next = append(next, combination{
+					tokens:    append(append([]string(nil), c.tokens...), o.token),
+					edits:     c.edits + o.edits,
+					frequency: c.frequency + o.frequency,
+				})
+			}
+		}
+		combos = next
+	}
+	sort.SliceStable(combos, func(i, j int) bool {
+		if combos[i].edits != combos[j].edits {
+			return combos[i].edits < combos[j].edits
+		}
+		return combos[i].frequency > combos[j].frequency
+	})
+
+	queries := make([]string, 0, len(combos))
+	for _, c := range combos {
+		if c.edits > 0 {
+			queries = append(queries, strings.Join(c.tokens, " "))
+		}
+	}
+	return queries
+}
+
+// wordChoices are the spellings worth trying for one word, never empty: the
+// word itself when the catalogue uses it or nothing is near enough, else
+// its closest dictionary words within the edits its length allows.
+func wordChoices(w models.TokenCandidates) []spellingChoice {
+	asTyped := []spellingChoice{{token: w.Token}}
+	if w.Known {
+		return asTyped
+	}
+	limit := maxEdits(w.Token)
+	var options []spellingChoice
+	for _, c := range w.Candidates {
+		if d := editDistance(w.Token, c.Token); d <= limit {
+			options = append(options, spellingChoice{token: c.Token, edits: d, frequency: c.Frequency})
+		}
+	}
+	if len(options) == 0 {
+		return asTyped
+	}
+	sort.SliceStable(options, func(i, j int) bool {
+		if options[i].edits != options[j].edits {
+			return options[i].edits < options[j].edits
+		}
+		return options[i].frequency > options[j].frequency
+	})
+	return options[:min(len(options), spellingChoicesPerWord)]
+}
+
+// maxEdits is how far a word may be from its correction: one edit up to
+// five runes, two beyond. Short words allowed more turn into other words.
+func maxEdits(word string) int {
+	const shortWord = 5
+	if runeLength(word) <= shortWord {
+		return 1
+	}
+	return 2
+}
+
+// editDistance counts the insertions, deletions, substitutions and
+// transpositions of neighbours between a and b, in runes.
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	// Three rows of the optimal string alignment table: the transposition
+	// looks two rows back.
+	prev2 := make([]int, len(rb)+1)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				cur[j] = min(cur[j], prev2[j-2]+1)
+			}
+		}
+		prev2, prev, cur = prev, cur, prev2
+	}
+	return prev[len(rb)]
+}
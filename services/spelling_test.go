@@ -0,0 +1,114 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"gopds-api/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// spellingRepository reads the query against a fixed dictionary reading and
+// answers searches from the rows listed for each query.
+type spellingRepository struct {
+	queryRepository
+
+	words []models.TokenCandidates
+}
+
+func (r *spellingRepository) SpellingCandidates(_ context.Context, _ string, _ int) ([]models.TokenCandidates, error) {
+	return r.words, nil
+}
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"война", "война", 0},
+		{"вайна", "война", 1},
+		{"окена", "океан", 1},
+		{"потер", "поттер", 1},
+		{"маргрита", "маргарита", 1},
+		{"мастр", "мастер", 1},
+		{"", "мир", 3},
+		{"kitten", "sitting", 3},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, editDistance(tt.a, tt.b), "%q → %q", tt.a, tt.b)
+	}
+}
+
+func TestCorrectedQueries(t *testing.T) {
+	t.Run("unknown words take their nearest common spellings", func(t *testing.T) {
+		got := correctedQueries([]models.TokenCandidates{
+			{Token: "вайна", Candidates: []models.DictionaryWord{
+				{Token: "война", Frequency: 40}, {Token: "майна", Frequency: 90}, {Token: "стена", Frequency: 500},
+			}},
+			{Token: "и", Known: true},
+			{Token: "мир", Known: true},
+		})
+		assert.Equal(t, []string{"майна и мир", "война и мир"}, got, "both one edit away, the commoner first; стена is two")
+	})
+
+	t.Run("fewer edits outrank a commoner word", func(t *testing.T) {
+		got := correctedQueries([]models.TokenCandidates{
+			{Token: "маргрита", Candidates: []models.DictionaryWord{
+				{Token: "маргариты", Frequency: 300}, {Token: "маргарита", Frequency: 20},
+			}},
+		})
+		assert.Equal(t, []string{"маргарита", "маргариты"}, got)
+	})
+
+	t.Run("nothing to correct is no suggestion", func(t *testing.T) {
+		assert.Empty(t, correctedQueries([]models.TokenCandidates{
+			{Token: "война", Known: true},
+			{Token: "qzxwvfkj", Candidates: []models.DictionaryWord{{Token: "quixote", Frequency: 3}}},
+		}))
+		assert.Empty(t, correctedQueries(nil))
+	})
+}
+
+func TestSearchServiceSuggestsSpellings(t *testing.T) {
+	words := []models.TokenCandidates{
+		{Token: "вайна", Candidates: []models.DictionaryWord{{Token: "война", Frequency: 40}, {Token: "майна", Frequency: 90}}},
+		{Token: "и", Known: true},
+		{Token: "мир", Known: true},
+	}
+
+	t.Run("only corrections that find something are suggested", func(t *testing.T) {
+		repo := &spellingRepository{words: words}
+		repo.books = map[string][]int64{"война и мир": {1, 2, 3}}
+		svc := NewSearchService(repo).WithSpellingSuggestions()
+
+		page, err := svc.SearchBooks(context.Background(), models.BookSearchRequest{Query: "вайна и мир", Limit: 10})
+		require.NoError(t, err)
+		assert.Empty(t, page.Books)
+		assert.Equal(t, []models.SpellingSuggestion{{Query: "война и мир", Total: 3}}, page.DidYouMean)
+		require.Len(t, repo.bookReqs, 3, "the search, then one check per correction")
+		assert.Equal(t, 1, repo.bookReqs[1].Limit, "a check needs the total, not the books")
+	})
+
+	t.Run("a search that finds something suggests nothing", func(t *testing.T) {
+		repo := &spellingRepository{words: words}
+		repo.books = map[string][]int64{"вайна и мир": {9}, "война и мир": {1}}
+		svc := NewSearchService(repo).WithSpellingSuggestions()
+
+		page, err := svc.SearchBooks(context.Background(), models.BookSearchRequest{Query: "вайна и мир", Limit: 10})
+		require.NoError(t, err)
+		assert.Nil(t, page.DidYouMean)
+		assert.Len(t, repo.bookReqs, 1)
+	})
+
+	t.Run("a later page suggests nothing", func(t *testing.T) {
+		repo := &spellingRepository{words: words}
+		repo.books = map[string][]int64{"война и мир": {1}}
+		svc := NewSearchService(repo).WithSpellingSuggestions()
+
+		page, err := svc.SearchBooks(context.Background(), models.BookSearchRequest{Query: "вайна и мир", Limit: 10, Offset: 10})
+		require.NoError(t, err)
+		assert.Nil(t, page.DidYouMean)
+	})
+}
@@ -0,0 +1,253 @@
+package services
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopds-api/database"
+	"gopds-api/internal/totp"
+	"gopds-api/models"
+)
+
+// ErrTwoFactorCode is returned for a TOTP or recovery code that is wrong,
+// spent, or for a time step already used.
+var ErrTwoFactorCode = errors.New("invalid two-factor code")
+
+const (
+	recoveryCodeCount = 10
+	// recoveryCodeAlphabet leaves out the characters people misread: 0, 1,
+	// i, l and o.
+	recoveryCodeAlphabet = "23456789abcdefghjkmnpqrstuvwxyz"
+	recoveryCodeLength   = 10
+)
+
+// TwoFactorService manages TOTP second factors and recovery codes.
+type TwoFactorService struct {
+	issuer string
+	aead   cipher.AEAD
+	now    func() time.Time
+}
+
+// NewTwoFactorService returns the service. Issuer names this installation
+// in authenticator apps; TOTP secrets are encrypted at rest with a key
+// derived from secretKey, so changing secret_key disables every enrolled
+// second factor.
+func NewTwoFactorService(issuer, secretKey string) (*TwoFactorService, error) {
+	key := sha256.Sum256([]byte("gopds totp secret:" + secretKey))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &TwoFactorService{issuer: issuer, aead: aead, now: time.Now}, nil
+}
+
+// Enabled reports whether a user has a second factor in use.
+func (s *TwoFactorService) Enabled(ctx context.Context, userID int64) (bool, error) {
+	return database.TwoFactorEnabled(ctx, userID)
+}
+
+// Status returns whether a user has a second factor, and how many recovery
+// codes they have left.
+func (s *TwoFactorService) Status(ctx context.Context, userID int64) (models.TwoFactorStatus, error) {
+	var status models.TwoFactorStatus
+	enabled, err := database.TwoFactorEnabled(ctx, userID)
+	if err != nil || !enabled {
+		return status, err
+	}
+	status.Enabled = true
+	status.RecoveryCodesLeft, err = database.CountRecoveryCodes(ctx, userID)
+	return status, err
+}
+
+// Begin starts an enrolment: a new secret, not in use until Confirm.
+// Account is how the app lists the user.
+func (s *TwoFactorService) Begin(ctx context.Context, userID int64, account string) (*models.TwoFactorEnrolment, error) {
+	secret := totp.NewSecret()
+	sealed, err := s.seal(userID, secret)
+	if err != nil {
+		return nil, err
+	}
+	if err := database.SaveTOTPSecret(ctx, userID, sealed); err != nil {
+		return nil, err
+	}
+	return &models.TwoFactorEnrolment{
+		Secret: secret,
+		URI:    totp.ProvisioningURI(s.issuer, account, secret),
+	}, nil
+}
+
+// Confirm finishes an enrolment with a code from the app, and returns the
+// user's recovery codes — the only time they are shown.
+func (s *TwoFactorService) Confirm(ctx context.Context, userID int64, code string) ([]string, error) {
+	t, err := database.GetTOTP(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if t.ConfirmedAt != nil {
+		return nil, database.ErrTwoFactorEnabled
+	}
+	secret, err := s.open(userID, t.Secret)
+	if err != nil {
+		return nil, err
+	}
+	step, ok := totp.Validate(secret, normalizeCode(code), s.now(), 0)
+	if !ok {
+		return nil, ErrTwoFactorCode
+	}
+	codes, hashes := newRecoveryCodes()
+	if err := database.ConfirmTOTP(ctx, userID, step, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// Verify checks a second factor: a TOTP code, or a recovery code, which is
+// then spent.
+func (s *TwoFactorService) Verify(ctx context.Context, userID int64, code string) error {
+	t, err := database.GetTOTP(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if t.ConfirmedAt == nil {
+		return database.ErrTwoFactorNotEnrolled
+	}
+
+	code = normalizeCode(code)
+	if isTOTPCode(code) {
+		secret, err := s.open(userID, t.Secret)
+		if err != nil {
+			return err
+		}
+		step, ok := totp.Validate(secret, code, s.now(), t.LastStep)
+		if !ok {
+			return ErrTwoFactorCode
+		}
+		advanced, err := database.AdvanceTOTPStep(ctx, userID, step)
+		if err != nil {
+			return err
+		}
+		if !advanced {
+			return ErrTwoFactorCode
+		}
+		return nil
+	}
+
+	used, err := database.UseRecoveryCode(ctx, userID, hashRecoveryCode(code))
+	if err != nil {
+		return err
+	}
+	if !used {
+		return ErrTwoFactorCode
+	}
+	return nil
+}
+
+// RegenerateRecoveryCodes replaces a user's recovery codes, after checking
+// a second factor.
+func (s *TwoFactorService) RegenerateRecoveryCodes(ctx context.Context, userID int64, code string) ([]string, error) {
+	if err := s.Verify(ctx, userID, code); err != nil {
+		return nil, err
+	}
+	codes, hashes := newRecoveryCodes()
+	if err := database.ReplaceRecoveryCodes(ctx, userID, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// Disable removes a user's second factor, after checking it.
+func (s *TwoFactorService) Disable(ctx context.Context, userID int64, code string) error {
+	if err := s.Verify(ctx, userID, code); err != nil {
+		return err
+	}
+	return database.DeleteTwoFactor(ctx, userID)
+}
+
+// Reset removes a user's second factor without asking for it, for an
+// admin helping someone who lost their device.
+func (s *TwoFactorService) Reset(ctx context.Context, userID int64) error {
+	return database.DeleteTwoFactor(ctx, userID)
+}
+
+// seal encrypts a secret, bound to its user: a sealed secret copied to
+// another row does not open.
+func (s *TwoFactorService) seal(userID int64, secret string) (string, error) {
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := s.aead.Seal(nonce, nonce, []byte(secret), []byte(strconv.FormatInt(userID, 10)))
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func (s *TwoFactorService) open(userID int64, sealed string) (string, error) {
+	raw, err := base64.StdEncoding.DecodeString(sealed)
+	if err != nil || len(raw) < s.aead.NonceSize() {
+		return "", fmt.Errorf("totp secret of user %d is corrupt", userID)
+	}
+	nonce, ciphertext := raw[:s.aead.NonceSize()], raw[s.aead.NonceSize():]
+	secret, err := s.aead.Open(nil, nonce, ciphertext, []byte(strconv.FormatInt(userID, 10)))
+	if err != nil {
+		return "", fmt.Errorf("totp secret of user %d does not decrypt — was secret_key changed?", userID)
+	}
+	return string(secret), nil
+}
+
+// newRecoveryCodes returns a fresh set of recovery codes, formatted for
+// reading, and their hashes for storing.
+func newRecoveryCodes() (codes, hashes []string) {
+	limit := big.NewInt(int64(len(recoveryCodeAlphabet)))
+	for range recoveryCodeCount {
+		b := make([]byte, recoveryCodeLength)
+		for i := range b {
+			n, err := rand.Int(rand.Reader, limit)
+			if err != nil {
+				panic(fmt.Sprintf("crypto/rand: %v", err))
+			}
+			b[i] = recoveryCodeAlphabet[n.Int64()]
+		}
+		code := string(b[:recoveryCodeLength/2]) + "-" + string(b[recoveryCodeLength/2:])
+		codes = append(codes, code)
+		hashes = append(hashes, hashRecoveryCode(normalizeCode(code)))
+	}
+	return codes, hashes
+}
+
+// hashRecoveryCode hashes a normalized recovery code. A bare hash is
+// enough: the codes are random, fifty bits each, not chosen by people.
+func hashRecoveryCode(code string) string {
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
+
+// normalizeCode forgives how a code was typed: spaces, dashes and case.
+func normalizeCode(code string) string {
+	return strings.ToLower(strings.NewReplacer(" ", "", "-", "", "\t", "").Replace(strings.TrimSpace(code)))
+}
+
+func isTOTPCode(code string) bool {
+	if len(code) != totp.Digits {
+		return false
+	}
+	for _, r := range code {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
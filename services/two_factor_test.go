@@ -0,0 +1,72 @@
+package services
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTwoFactorService_SealOpen(t *testing.T) {
+	s, err := NewTwoFactorService("Books", "secret-key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed, err := s.seal(7, "JBSWY3DPEHPK3PXP")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(sealed, "JBSWY3DPEHPK3PXP") {
+		t.Fatal("the secret is stored in the clear")
+	}
+	if got, err := s.open(7, sealed); err != nil || got != "JBSWY3DPEHPK3PXP" {
+		t.Fatalf("open() = %q, %v", got, err)
+	}
+	// Copied to another user's row, it does not open.
+	if _, err := s.open(8, sealed); err == nil {
+		t.Error("a secret opened for another user")
+	}
+	other, _ := NewTwoFactorService("Books", "another-key")
+	if _, err := other.open(7, sealed); err == nil {
+		t.Error("a secret opened under another secret_key")
+	}
+}
+
+func TestNewRecoveryCodes(t *testing.T) {
+	codes, hashes := newRecoveryCodes()
+	if len(codes) != recoveryCodeCount || len(hashes) != recoveryCodeCount {
+		t.Fatalf("got %d codes, %d hashes", len(codes), len(hashes))
+	}
+	seen := map[string]bool{}
+	for i, code := range codes {
+		if len(code) != recoveryCodeLength+1 || code[recoveryCodeLength/2] != '-' {
+			t.Errorf("code %q is not xxxxx-xxxxx", code)
+		}
+		if seen[code] {
+			t.Errorf("code %q twice", code)
+		}
+		seen[code] = true
+		// Typed back any which way, it hashes to what is stored.
+		typed := strings.ToUpper(strings.ReplaceAll(code, "-", " "))
+		if hashRecoveryCode(normalizeCode(typed)) != hashes[i] {
+			t.Errorf("code %q typed as %q does not match", code, typed)
+		}
+		if isTOTPCode(normalizeCode(code)) {
+			t.Errorf("code %q reads as a TOTP code", code)
+		}
+	}
+}
+
+func TestNormalizeCode(t *testing.T) {
+	for in, want := range map[string]string{
+		"123 456":      "123456",
+		" 123456\t":    "123456",
+		"ABCDE-fghjk":  "abcdefghjk",
+		"abcde fghjk ": "abcdefghjk",
+	} {
+		if got := normalizeCode(in); got != want {
+			t.Errorf("normalizeCode(%q) = %q, want %q", in, got, want)
+		}
+	}
+	if !isTOTPCode("123456") || isTOTPCode("12345a") || isTOTPCode("1234567") {
+		t.Error("isTOTPCode misreads a code")
+	}
+}
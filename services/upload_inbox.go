@@ -0,0 +1,161 @@
+package services
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"gopds-api/internal/safeio"
+	"gopds-api/logging"
+)
+
+// errInboxEntryExists reports a file the inbox archive already holds under
+// the name it was to be stored as.
+var errInboxEntryExists = errors.New("inbox archive already holds the file")
+
+// UploadInbox keeps the books readers upload in zip archives of its own
+// under the library directory, one a month, so that they are downloaded,
+// converted and previewed as every other book is.
+type UploadInbox struct {
+	filesPath string
+	dir       string
+
+	// mu serialises the rewrites: each one reads the archive the last one
+	// left.
+	mu sync.Mutex
+}
+
+// NewUploadInbox returns the inbox keeping its archives in dir, relative
+// to filesPath.
+func NewUploadInbox(filesPath, dir string) *UploadInbox {
+	return &UploadInbox{filesPath: filesPath, dir: dir}
+}
+
+// ArchiveFor names the archive, relative to filesPath as a book's path is,
+// that books uploaded at t go to.
+func (in *UploadInbox) ArchiveFor(t time.Time) string {
+	return path.Join(in.dir, "inbox-"+t.Format("2006-01")+".zip")
+}
+
+// Add stores content as entry of archiveName, creating the archive for the
+// month's first upload, and returns how many files the archive holds now.
+func (in *UploadInbox) Add(archiveName, entry string, content []byte) (int, error) {
+	return in.rewrite(archiveName, "", &inboxFile{name: entry, content: content})
+}
+
+// Remove takes entry out of archiveName. An archive or an entry that is
+// not there is left as it is.
+func (in *UploadInbox) Remove(archiveName, entry string) error {
+	_, err := in.rewrite(archiveName, entry, nil)
+	return err
+}
+
+// inboxFile is a file on its way into an inbox archive.
+type inboxFile struct {
+	name    string
+	content []byte
+}
+
+// rewrite writes archiveName anew without drop and with add, and returns
+// how many files it holds. A zip cannot be changed in place, so the new
+// archive is written beside the old one and renamed over it: a reader
+// downloading from it meanwhile reads one or the other whole.
+func (in *UploadInbox) rewrite(archiveName, drop string, add *inboxFile) (int, error) {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+
+	target := filepath.Join(in.filesPath, filepath.FromSlash(archiveName))
+	var old []*zip.File
+	r, err := zip.OpenReader(target)
+	switch {
+	case err == nil:
+		defer func() {
+			if closeErr := r.Close(); closeErr != nil {
+				logging.Warnf("Failed to close inbox archive %s: %v", archiveName, closeErr)
+			}
+		}()
+		old = r.File
+	case errors.Is(err, fs.ErrNotExist) && add == nil:
+		return 0, nil
+	case errors.Is(err, fs.ErrNotExist):
+		if err := os.MkdirAll(filepath.Dir(target), safeio.DirMode); err != nil {
+			return 0, fmt.Errorf("failed to create inbox directory: %w", err)
+		}
+	default:
+		return 0, fmt.Errorf("failed to open inbox archive: %w", err)
+	}
+
+	kept := make([]*zip.File, 0, len(old))
+	for _, f := range old {
+		switch {
+		case f.Name == drop:
+			continue
+		case add != nil && f.Name == add.name:
+			return 0, errInboxEntryExists
+		}
+		kept = append(kept, f)
+	}
+	if add == nil && len(kept) == len(old) {
+		return len(old), nil
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(target), ".inbox-*.zip")
+	if err != nil {
+		return 0, fmt.Errorf("failed to create inbox archive: %w", err)
+	}
+	count, err := writeInboxArchive(tmp, kept, add)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), safeio.FileMode)
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), target)
+	}
+	if err != nil {
+		removeInboxFile(tmp.Name())
+		return 0, fmt.Errorf("failed to write inbox archive %s: %w", archiveName, err)
+	}
+	return count, nil
+}
+
+// writeInboxArchive writes the files kept, and add if any, to out, and
+// returns how many there are.
+func writeInboxArchive(out *os.File, kept []*zip.File, add *inboxFile) (int, error) {
+	w := zip.NewWriter(out)
+	for _, f := range kept {
+		if err := w.Copy(f); err != nil {
+			return 0, fmt.Errorf("failed to copy %s: %w", f.Name, err)
+		}
+	}
+	count := len(kept)
+	if add != nil {
+		fw, err := w.CreateHeader(&zip.FileHeader{Name: add.name, Method: zip.Deflate, Modified: time.Now()})
+		if err != nil {
+			return 0, err
+		}
+		if _, err := fw.Write(add.content); err != nil {
+			return 0, err
+		}
+		count++
+	}
+	if err := w.Close(); err != nil {
+		return 0, err
+	}
+	return count, out.Sync()
+}
+
+// removeInboxFile deletes a file the inbox wrote, saying so when it
+// cannot.
+func removeInboxFile(name string) {
+	if err := os.Remove(name); err != nil && !os.IsNotExist(err) {
+		logging.Warnf("Upload inbox: could not remove %s: %v", name, err)
+	}
+}
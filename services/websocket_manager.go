@@ -103,6 +103,35 @@ func (m *WebSocketManager) BroadcastToAdmins(messageType string, data interface{
 	return nil
 }
 
+// SendToUser sends a message to every connection of one user, the same way
+// BroadcastToAdmins does. A user who is not connected misses it.
+func (m *WebSocketManager) SendToUser(userID int64, messageType string, data interface{}) error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	jsonData, err := json.Marshal(map[string]interface{}{
+		"type": messageType,
+		"data": data,
+	})
+	if err != nil {
+		logging.Errorf("Failed to marshal WebSocket message: %v", err)
+		return err
+	}
+
+	for _, client := range m.clients {
+		if client.UserID != userID {
+			continue
+		}
+
+		select {
+		case client.NotifyChan <- jsonData:
+		default:
+			logging.Warnf("NotifyChan full for user %s, dropping message", client.Username)
+		}
+	}
+	return nil
+}
+
 // GetAdminCount returns the number of connected admin clients
 func (m *WebSocketManager) GetAdminCount() int {
 	m.mu.RLock()
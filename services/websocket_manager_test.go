@@ -189,3 +189,24 @@ func TestConcurrentBroadcast(t *testing.T) {
 		assert.Equal(t, 50, len(ch))
 	}
 }
+
+func TestSendToUser_EveryConnectionOfThatUserOnly(t *testing.T) {
+	m := NewWebSocketManager()
+	laptopCh := make(chan []byte, 4)
+	phoneCh := make(chan []byte, 4)
+	otherCh := make(chan []byte, 4)
+
+	m.RegisterClient(nil, 7, "reader", false, laptopCh)
+	m.RegisterClient(nil, 7, "reader", false, phoneCh)
+	m.RegisterClient(nil, 8, "admin", true, otherCh)
+
+	require.NoError(t, m.SendToUser(7, "book_request_fulfilled", map[string]int{"book_id": 3}))
+
+	require.Len(t, laptopCh, 1)
+	require.Len(t, phoneCh, 1)
+	assert.Empty(t, otherCh, "not even an admin gets another user's message")
+
+	var msg map[string]interface{}
+	require.NoError(t, json.Unmarshal(<-laptopCh, &msg))
+	assert.Equal(t, "book_request_fulfilled", msg["type"])
+}
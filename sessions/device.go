@@ -0,0 +1,62 @@
+package sessions
+
+import "strings"
+
+// browsers and systems are matched in order: Edge and Opera say they are
+// Chrome, Chrome says it is Safari, Android says it is Linux.
+var (
+	browsers = []struct{ token, name string }{
+		{"Edg/", "Edge"},
+		{"OPR/", "Opera"},
+		{"YaBrowser/", "Yandex Browser"},
+		{"Firefox/", "Firefox"},
+		{"Chrome/", "Chrome"},
+		{"CriOS/", "Chrome"},
+		{"Safari/", "Safari"},
+	}
+	systems = []struct{ token, name string }{
+		{"Android", "Android"},
+		{"iPhone", "iOS"},
+		{"iPad", "iPadOS"},
+		{"Windows", "Windows"},
+		{"Mac OS X", "macOS"},
+		{"CrOS", "ChromeOS"},
+		{"Linux", "Linux"},
+	}
+)
+
+// DeviceName is a short, readable name for the client behind a user agent:
+// "Firefox on Linux" for a browser, the product name for anything else —
+// OPDS readers mostly send "KOReader/2024.04" or the like.
+func DeviceName(userAgent string) string {
+	userAgent = strings.TrimSpace(userAgent)
+	if userAgent == "" {
+		return "Unknown device"
+	}
+	if strings.HasPrefix(userAgent, "Mozilla/") {
+		browser, system := "", ""
+		for _, b := range browsers {
+			if strings.Contains(userAgent, b.token) {
+				browser = b.name
+				break
+			}
+		}
+		for _, s := range systems {
+			if strings.Contains(userAgent, s.token) {
+				system = s.name
+				break
+			}
+		}
+		switch {
+		case browser != "" && system != "":
+			return browser + " on " + system
+		case browser != "":
+			return browser
+		case system != "":
+			return system
+		}
+	}
+	product, _, _ := strings.Cut(userAgent, " ")
+	product, _, _ = strings.Cut(product, "/")
+	return product
+}
@@ -0,0 +1,316 @@
+package sessions
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopds-api/models"
+
+	"github.com/google/uuid"
+)
+
+// The session index keeps, per user, what is needed to list their sessions
+// and revoke one without walking the keyspace:
+//
+//	session:index:<user>   hash, session ID → models.Session as JSON
+//	session:seen:<user>    hash, session ID → "<unix time> <ip>" of last use
+//	session:access:<id>    set of the access tokens issued to a session
+//	session:opds-block:<user>  hash, OPDS session ID → password fingerprint
+//
+// Last use is kept apart from the rest so that recording it, on every
+// request, is one HSET rather than a read and a rewrite.
+const (
+	sessionIndexPrefix     = "session:index:"
+	sessionSeenPrefix      = "session:seen:"
+	sessionAccessPrefix    = "session:access:"
+	sessionOPDSBlockPrefix = "session:opds-block:"
+
+	// SessionTTL is how long a session lasts unused: the life of a refresh
+	// token. An OPDS reader unseen for as long is forgotten.
+	SessionTTL = 7 * 24 * time.Hour
+
+	opdsSessionPrefix = "opds-"
+)
+
+// ErrSessionNotFound reports a session that does not exist, was revoked or
+// expired.
+var ErrSessionNotFound = errors.New("session not found")
+
+// NewSessionID returns the ID of a new web session.
+func NewSessionID() string {
+	return uuid.New().String()
+}
+
+// OPDSSessionID is the session an OPDS reader is listed under. Readers send
+// no session of their own, so one is derived from the user agent: each app
+// on each device is a session.
+func OPDSSessionID(userAgent string) string {
+	sum := sha256.Sum256([]byte(userAgent))
+	return opdsSessionPrefix + hex.EncodeToString(sum[:8])
+}
+
+// SessionKind tells the kind of a session from its ID.
+func SessionKind(id string) string {
+	switch {
+	case id == models.SessionTelegram:
+		return models.SessionTelegram
+	case strings.HasPrefix(id, opdsSessionPrefix):
+		return models.SessionOPDS
+	default:
+		return models.SessionWeb
+	}
+}
+
+// OpenWebSession records a new web login and the access token it was
+// opened with.
+func OpenWebSession(ctx context.Context, userID int64, id, accessToken, ip, userAgent string) error {
+	now := time.Now()
+	s := models.Session{
+		ID:         id,
+		Kind:       models.SessionWeb,
+		Device:     DeviceName(userAgent),
+		IP:         ip,
+		UserAgent:  userAgent,
+		CreatedAt:  &now,
+		LastSeenAt: &now,
+	}
+	raw, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	index, seen := userKeys(userID)
+	pipe := rdb.WithContext(ctx).TxPipeline()
+	pipe.HSet(index, id, raw)
+	pipe.HSet(seen, id, seenValue(now, ip))
+	pipe.Expire(index, SessionTTL)
+	pipe.Expire(seen, SessionTTL)
+	pipe.SAdd(sessionAccessPrefix+id, accessToken)
+	pipe.Expire(sessionAccessPrefix+id, SessionTTL)
+	_, err = pipe.Exec()
+	return err
+}
+
+// RenewWebSession records the access token a refresh issued to a session.
+// It returns ErrSessionNotFound for a session revoked since, whose refresh
+// token must not be honoured.
+func RenewWebSession(ctx context.Context, userID int64, id, accessToken, ip string) error {
+	index, seen := userKeys(userID)
+	client := rdb.WithContext(ctx)
+	exists, err := client.HExists(index, id).Result()
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrSessionNotFound
+	}
+	pipe := client.TxPipeline()
+	pipe.HSet(seen, id, seenValue(time.Now(), ip))
+	pipe.Expire(index, SessionTTL)
+	pipe.Expire(seen, SessionTTL)
+	pipe.SAdd(sessionAccessPrefix+id, accessToken)
+	pipe.Expire(sessionAccessPrefix+id, SessionTTL)
+	_, err = pipe.Exec()
+	return err
+}
+
+// WebSessionActive reports whether a web session is still in the index.
+func WebSessionActive(ctx context.Context, userID int64, id string) (bool, error) {
+	index, _ := userKeys(userID)
+	return rdb.WithContext(ctx).HExists(index, id).Result()
+}
+
+// TouchSession records that a session was just used, and from where.
+func TouchSession(ctx context.Context, userID int64, id, ip string) error {
+	_, seen := userKeys(userID)
+	return rdb.WithContext(ctx).HSet(seen, id, seenValue(time.Now(), ip)).Err()
+}
+
+// TouchOPDSSession records a request from an OPDS reader, adding the reader
+// to the index the first time it is seen.
+func TouchOPDSSession(ctx context.Context, userID int64, userAgent, ip string) error {
+	id := OPDSSessionID(userAgent)
+	now := time.Now()
+	raw, err := json.Marshal(models.Session{
+		ID:        id,
+		Kind:      models.SessionOPDS,
+		Device:    DeviceName(userAgent),
+		IP:        ip,
+		UserAgent: userAgent,
+		CreatedAt: &now,
+	})
+	if err != nil {
+		return err
+	}
+	index, seen := userKeys(userID)
+	pipe := rdb.WithContext(ctx).TxPipeline()
+	pipe.HSetNX(index, id, raw)
+	pipe.HSet(seen, id, seenValue(now, ip))
+	pipe.Expire(index, SessionTTL)
+	pipe.Expire(seen, SessionTTL)
+	_, err = pipe.Exec()
+	return err
+}
+
+// ListSessions returns a user's web and OPDS sessions, most recently used
+// first. Sessions unused for longer than SessionTTL are dropped from the
+// index on the way.
+func ListSessions(ctx context.Context, userID int64) ([]models.Session, error) {
+	index, seen := userKeys(userID)
+	client := rdb.WithContext(ctx)
+	entries, err := client.HGetAll(index).Result()
+	if err != nil {
+		return nil, err
+	}
+	lastSeen, err := client.HGetAll(seen).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	cutoff := time.Now().Add(-SessionTTL)
+	out := make([]models.Session, 0, len(entries))
+	var stale []string
+	for id, raw := range entries {
+		var s models.Session
+		if err := json.Unmarshal([]byte(raw), &s); err != nil {
+			stale = append(stale, id)
+			continue
+		}
+		if at, ip, ok := parseSeenValue(lastSeen[id]); ok {
+			s.LastSeenAt = &at
+			if ip != "" {
+				s.IP = ip
+			}
+		}
+		if s.LastSeenAt == nil || s.LastSeenAt.Before(cutoff) {
+			stale = append(stale, id)
+			continue
+		}
+		out = append(out, s)
+	}
+	if len(stale) > 0 {
+		client.HDel(index, stale...)
+		client.HDel(seen, stale...)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].LastSeenAt.After(*out[j].LastSeenAt) })
+	return out, nil
+}
+
+// RevokeSession removes a web or OPDS session from the index. A web
+// session's access tokens stop working at once and its refresh token at the
+// next refresh. It returns ErrSessionNotFound for a session not in the
+// index, which is also what another user's session is: its tokens are left
+// alone.
+func RevokeSession(ctx context.Context, userID int64, id string) error {
+	index, seen := userKeys(userID)
+	client := rdb.WithContext(ctx)
+	removed, err := client.HDel(index, id).Result()
+	if err != nil {
+		return err
+	}
+	if removed == 0 {
+		return ErrSessionNotFound
+	}
+	client.HDel(seen, id)
+	return dropAccessTokens(ctx, id)
+}
+
+// RevokeWebSessions revokes every web session of a user. OPDS readers are
+// left alone: they hold the password, not a session.
+func RevokeWebSessions(ctx context.Context, userID int64) error {
+	index, seen := userKeys(userID)
+	client := rdb.WithContext(ctx)
+	ids, err := client.HKeys(index).Result()
+	if err != nil {
+		return err
+	}
+	var web []string
+	for _, id := range ids {
+		if SessionKind(id) == models.SessionWeb {
+			web = append(web, id)
+		}
+	}
+	if len(web) == 0 {
+		return nil
+	}
+	if err := client.HDel(index, web...).Err(); err != nil {
+		return err
+	}
+	client.HDel(seen, web...)
+	for _, id := range web {
+		if err := dropAccessTokens(ctx, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// BlockOPDSSession keeps an OPDS reader out while the password stays what
+// it is: basic auth sends the password with every request, so nothing short
+// of changing it signs a reader out for good. passwordHash is the user's
+// stored password hash, of which only a fingerprint is kept.
+func BlockOPDSSession(ctx context.Context, userID int64, id, passwordHash string) error {
+	return rdb.WithContext(ctx).HSet(sessionOPDSBlockPrefix+strconv.FormatInt(userID, 10), id, passwordFingerprint(passwordHash)).Err()
+}
+
+// OPDSSessionBlocked reports whether the reader with userAgent was revoked
+// under the password the user still has. A block from before a password
+// change is cleared.
+func OPDSSessionBlocked(ctx context.Context, userID int64, userAgent, passwordHash string) bool {
+	key := sessionOPDSBlockPrefix + strconv.FormatInt(userID, 10)
+	id := OPDSSessionID(userAgent)
+	client := rdb.WithContext(ctx)
+	fingerprint, err := client.HGet(key, id).Result()
+	if err != nil {
+		return false
+	}
+	if fingerprint != passwordFingerprint(passwordHash) {
+		client.HDel(key, id)
+		return false
+	}
+	return true
+}
+
+// dropAccessTokens ends the access tokens issued to a session, and the
+// theme each carries.
+func dropAccessTokens(ctx context.Context, id string) error {
+	client := rdb.WithContext(ctx)
+	tokens, err := client.SMembers(sessionAccessPrefix + id).Result()
+	if err != nil {
+		return err
+	}
+	keys := []string{sessionAccessPrefix + id}
+	for _, token := range tokens {
+		keys = append(keys, token, themeKeyPrefix+token)
+	}
+	return client.Del(keys...).Err()
+}
+
+func userKeys(userID int64) (index, seen string) {
+	id := strconv.FormatInt(userID, 10)
+	return sessionIndexPrefix + id, sessionSeenPrefix + id
+}
+
+func seenValue(at time.Time, ip string) string {
+	return strconv.FormatInt(at.Unix(), 10) + " " + ip
+}
+
+func parseSeenValue(v string) (time.Time, string, bool) {
+	unix, ip, _ := strings.Cut(v, " ")
+	sec, err := strconv.ParseInt(unix, 10, 64)
+	if err != nil {
+		return time.Time{}, "", false
+	}
+	return time.Unix(sec, 0), ip, true
+}
+
+func passwordFingerprint(passwordHash string) string {
+	sum := sha256.Sum256([]byte(passwordHash))
+	return hex.EncodeToString(sum[:8])
+}
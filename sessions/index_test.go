@@ -0,0 +1,135 @@
+package sessions
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"gopds-api/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const firefoxUA = "Mozilla/5.0 (X11; Linux x86_64; rv:128.0) Gecko/20100101 Firefox/128.0"
+
+func TestWebSession_OpenRenewRevoke(t *testing.T) {
+	s := useMiniredis(t)
+	ctx := context.Background()
+
+	require.NoError(t, OpenWebSession(ctx, 7, "s1", "access-1", "10.0.0.1", firefoxUA))
+	require.NoError(t, s.Set("access-1", "anna"))
+	require.NoError(t, RenewWebSession(ctx, 7, "s1", "access-2", "10.0.0.2"))
+	require.NoError(t, s.Set("access-2", "anna"))
+	require.NoError(t, s.Set(themeKeyPrefix+"access-2", "dark"))
+
+	list, err := ListSessions(ctx, 7)
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	assert.Equal(t, "s1", list[0].ID)
+	assert.Equal(t, models.SessionWeb, list[0].Kind)
+	assert.Equal(t, "Firefox on Linux", list[0].Device)
+	assert.Equal(t, "10.0.0.2", list[0].IP, "the address last seen from")
+
+	require.NoError(t, RevokeSession(ctx, 7, "s1"))
+	assert.False(t, s.Exists("access-1"), "every access token of the session is ended")
+	assert.False(t, s.Exists("access-2"))
+	assert.False(t, s.Exists(themeKeyPrefix+"access-2"))
+
+	active, err := WebSessionActive(ctx, 7, "s1")
+	require.NoError(t, err)
+	assert.False(t, active)
+	assert.ErrorIs(t, RenewWebSession(ctx, 7, "s1", "access-3", ""), ErrSessionNotFound)
+	assert.ErrorIs(t, RevokeSession(ctx, 7, "s1"), ErrSessionNotFound)
+}
+
+func TestRevokeSession_LeavesAnotherUsersSession(t *testing.T) {
+	s := useMiniredis(t)
+	ctx := context.Background()
+
+	require.NoError(t, OpenWebSession(ctx, 8, "s3", "a3", "", firefoxUA))
+	require.NoError(t, s.Set("a3", "boris"))
+
+	assert.ErrorIs(t, RevokeSession(ctx, 7, "s3"), ErrSessionNotFound)
+	assert.True(t, s.Exists("a3"), "a session ID is not a key to someone else's tokens")
+	active, err := WebSessionActive(ctx, 8, "s3")
+	require.NoError(t, err)
+	assert.True(t, active)
+}
+
+func TestRevokeWebSessions_LeavesOtherUsersAndReaders(t *testing.T) {
+	s := useMiniredis(t)
+	ctx := context.Background()
+
+	require.NoError(t, OpenWebSession(ctx, 7, "s1", "a1", "", firefoxUA))
+	require.NoError(t, OpenWebSession(ctx, 7, "s2", "a2", "", firefoxUA))
+	require.NoError(t, OpenWebSession(ctx, 8, "s3", "a3", "", firefoxUA))
+	require.NoError(t, TouchOPDSSession(ctx, 7, "KOReader/2024.04", "10.0.0.5"))
+	for _, token := range []string{"a1", "a2", "a3"} {
+		require.NoError(t, s.Set(token, "x"))
+	}
+
+	require.NoError(t, RevokeWebSessions(ctx, 7))
+	assert.False(t, s.Exists("a1"))
+	assert.False(t, s.Exists("a2"))
+	assert.True(t, s.Exists("a3"), "another user's session is untouched")
+
+	list, err := ListSessions(ctx, 7)
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	assert.Equal(t, models.SessionOPDS, list[0].Kind)
+	assert.Equal(t, "KOReader", list[0].Device)
+}
+
+func TestListSessions_DropsStale(t *testing.T) {
+	s := useMiniredis(t)
+	ctx := context.Background()
+
+	require.NoError(t, OpenWebSession(ctx, 7, "old", "a1", "", firefoxUA))
+	require.NoError(t, OpenWebSession(ctx, 7, "new", "a2", "", firefoxUA))
+	long := time.Now().Add(-SessionTTL - time.Hour).Unix()
+	s.HSet(sessionSeenPrefix+"7", "old", strconv.FormatInt(long, 10)+" ")
+
+	list, err := ListSessions(ctx, 7)
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	assert.Equal(t, "new", list[0].ID)
+	assert.Empty(t, s.HGet(sessionIndexPrefix+"7", "old"), "the stale entry is gone from the index")
+}
+
+func TestOPDSSessionBlocked(t *testing.T) {
+	useMiniredis(t)
+	ctx := context.Background()
+	const reader = "KOReader/2024.04"
+
+	assert.False(t, OPDSSessionBlocked(ctx, 7, reader, "hash-1"))
+	require.NoError(t, BlockOPDSSession(ctx, 7, OPDSSessionID(reader), "hash-1"))
+	assert.True(t, OPDSSessionBlocked(ctx, 7, reader, "hash-1"))
+	assert.False(t, OPDSSessionBlocked(ctx, 7, "Librera/9.1", "hash-1"), "other readers are let in")
+	assert.False(t, OPDSSessionBlocked(ctx, 8, reader, "hash-1"), "other users are let in")
+
+	// A new password lifts the block, for good.
+	assert.False(t, OPDSSessionBlocked(ctx, 7, reader, "hash-2"))
+	assert.False(t, OPDSSessionBlocked(ctx, 7, reader, "hash-1"))
+}
+
+func TestSessionKind(t *testing.T) {
+	assert.Equal(t, models.SessionOPDS, SessionKind(OPDSSessionID("KOReader/2024.04")))
+	assert.Equal(t, models.SessionTelegram, SessionKind("telegram"))
+	assert.Equal(t, models.SessionWeb, SessionKind(NewSessionID()))
+}
+
+func TestDeviceName(t *testing.T) {
+	for ua, want := range map[string]string{
+		firefoxUA: "Firefox on Linux",
+		"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/126.0.0.0 Safari/537.36 Edg/126.0.0.0":           "Edge on Windows",
+		"Mozilla/5.0 (iPhone; CPU iPhone OS 17_5 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.5 Mobile/15E148 Safari/604.1": "Safari on iOS",
+		"Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/126.0.0.0 Mobile Safari/537.36":                   "Chrome on Android",
+		"KOReader/2024.04 (https://koreader.rocks/)": "KOReader",
+		"Librera/9.1": "Librera",
+		"":            "Unknown device",
+	} {
+		assert.Equal(t, want, DeviceName(ua), ua)
+	}
+}
@@ -7,7 +7,6 @@ import (
 
 	"gopds-api/logging"
 	"gopds-api/models"
-	"gopds-api/utils"
 
 	"github.com/go-redis/redis"
 	"github.com/google/uuid"
@@ -42,32 +41,6 @@ func DeleteSessionKey(ctx context.Context, lu models.LoggedInUser) error {
 	return nil
 }
 
-// DropAllSessions removes all session keys for a user.
-func DropAllSessions(token string) {
-	username, _, _, err := utils.CheckAccessToken(token)
-	if err != nil {
-		logging.Error(err)
-		return
-	}
-	keys, err := rdb.Keys("*").Result()
-	if err != nil {
-		logging.Error(err)
-		return
-	}
-	for _, k := range keys {
-		if strings.HasPrefix(k, themeKeyPrefix) {
-			keyToken := strings.TrimPrefix(k, themeKeyPrefix)
-			if checkedUser, _, _, err := utils.CheckAccessToken(keyToken); err == nil && checkedUser == username {
-				rdb.Del(k)
-			}
-			continue
-		}
-		if checkedUser, _, _, err := utils.CheckAccessToken(k); err == nil && checkedUser == username {
-			rdb.Del(k, themeKeyPrefix+k)
-		}
-	}
-}
-
 func SetThemeForToken(ctx context.Context, token, theme string) error {
 	ttl, err := rdb.WithContext(ctx).TTL(token).Result()
 	if err != nil || ttl <= 0 {
@@ -0,0 +1,67 @@
+package sessions
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	twoFactorChallengePrefix = "2fa:challenge:"
+	twoFactorAttemptsPrefix  = "2fa:attempts:"
+	// TwoFactorChallengeTTL is how long a login that got past the password
+	// waits for its second factor.
+	TwoFactorChallengeTTL = 5 * time.Minute
+	// maxTwoFactorAttempts is how many codes one challenge may be answered
+	// with. Six digits do not survive unlimited guessing, however slow.
+	maxTwoFactorAttempts = 5
+)
+
+// ErrTwoFactorChallenge reports a challenge that expired, was used, or
+// took too many wrong codes.
+var ErrTwoFactorChallenge = errors.New("two-factor challenge expired")
+
+// CreateTwoFactorChallenge records that userID got past the password and
+// owes a second factor, and returns the handle to answer it with.
+func CreateTwoFactorChallenge(ctx context.Context, userID int64) (string, error) {
+	id := uuid.New().String()
+	err := rdb.WithContext(ctx).Set(twoFactorChallengePrefix+id, strconv.FormatInt(userID, 10), TwoFactorChallengeTTL).Err()
+	if err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// TwoFactorChallengeUser returns whose login a challenge is, counting an
+// attempt at answering it. The challenge is gone after too many attempts.
+func TwoFactorChallengeUser(ctx context.Context, id string) (int64, error) {
+	client := rdb.WithContext(ctx)
+	value, err := client.Get(twoFactorChallengePrefix + id).Result()
+	if err != nil {
+		return 0, ErrTwoFactorChallenge
+	}
+	attempts, err := client.Incr(twoFactorAttemptsPrefix + id).Result()
+	if err != nil {
+		return 0, err
+	}
+	if attempts == 1 {
+		client.Expire(twoFactorAttemptsPrefix+id, TwoFactorChallengeTTL)
+	}
+	if attempts > maxTwoFactorAttempts {
+		DeleteTwoFactorChallenge(ctx, id)
+		return 0, ErrTwoFactorChallenge
+	}
+	userID, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, ErrTwoFactorChallenge
+	}
+	return userID, nil
+}
+
+// DeleteTwoFactorChallenge ends a challenge, once answered.
+func DeleteTwoFactorChallenge(ctx context.Context, id string) {
+	rdb.WithContext(ctx).Del(twoFactorChallengePrefix+id, twoFactorAttemptsPrefix+id)
+}
@@ -0,0 +1,64 @@
+package sessions
+
+import (
+	"context"
+	"testing"
+
+	miniredis "github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func useMiniredis(t *testing.T) *miniredis.Miniredis {
+	t.Helper()
+	s, err := miniredis.Run()
+	require.NoError(t, err)
+	client := redis.NewClient(&redis.Options{Addr: s.Addr()})
+	oldMain, oldToken := rdb, rdbToken
+	SetRedisConnections(client, client)
+	t.Cleanup(func() {
+		SetRedisConnections(oldMain, oldToken)
+		_ = client.Close()
+		s.Close()
+	})
+	return s
+}
+
+func TestTwoFactorChallenge(t *testing.T) {
+	s := useMiniredis(t)
+	ctx := context.Background()
+
+	id, err := CreateTwoFactorChallenge(ctx, 42)
+	require.NoError(t, err)
+
+	userID, err := TwoFactorChallengeUser(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, int64(42), userID)
+
+	DeleteTwoFactorChallenge(ctx, id)
+	_, err = TwoFactorChallengeUser(ctx, id)
+	assert.ErrorIs(t, err, ErrTwoFactorChallenge, "a challenge answered is gone")
+
+	id, err = CreateTwoFactorChallenge(ctx, 42)
+	require.NoError(t, err)
+	s.FastForward(TwoFactorChallengeTTL + 1)
+	_, err = TwoFactorChallengeUser(ctx, id)
+	assert.ErrorIs(t, err, ErrTwoFactorChallenge, "a challenge expires")
+}
+
+func TestTwoFactorChallenge_AttemptLimit(t *testing.T) {
+	useMiniredis(t)
+	ctx := context.Background()
+
+	id, err := CreateTwoFactorChallenge(ctx, 42)
+	require.NoError(t, err)
+	for i := 0; i < maxTwoFactorAttempts; i++ {
+		_, err := TwoFactorChallengeUser(ctx, id)
+		require.NoError(t, err, "attempt %d", i+1)
+	}
+	_, err = TwoFactorChallengeUser(ctx, id)
+	assert.ErrorIs(t, err, ErrTwoFactorChallenge)
+	_, err = TwoFactorChallengeUser(ctx, id)
+	assert.ErrorIs(t, err, ErrTwoFactorChallenge, "the challenge is gone, not just refused once")
+}
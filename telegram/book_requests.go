@@ -0,0 +1,43 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+
+	"gopds-api/models"
+
+	tgbotapi "github.com/go-telegram/bot"
+	tgbot "github.com/go-telegram/bot/models"
+)
+
+// BookRequestFulfilled tells a reader through their own bot that a book
+// they asked for has arrived, with a button that opens its card. A reader
+// without a linked bot is passed over.
+func (bm *BotManager) BookRequestFulfilled(ctx context.Context, user models.User, fulfilled models.FulfilledBookRequest) error {
+	if user.BotToken == "" || user.TelegramID == 0 {
+		return nil
+	}
+	bm.mutex.RLock()
+	bot, ok := bm.bots[user.BotToken]
+	bm.mutex.RUnlock()
+	if !ok {
+		return nil
+	}
+
+	_, err := bot.bot.SendMessage(ctx, bookRequestFulfilledMessage(int64(user.TelegramID), fulfilled))
+	return err
+}
+
+// bookRequestFulfilledMessage is the message announcing a fulfilled
+// request.
+func bookRequestFulfilledMessage(chatID int64, fulfilled models.FulfilledBookRequest) *tgbotapi.SendMessageParams {
+	return &tgbotapi.SendMessageParams{
+		ChatID: chatID,
+		Text:   fmt.Sprintf("📬 В библиотеке появилась книга, которую вы просили: %s", fulfilled.BookTitle),
+		ReplyMarkup: &tgbot.InlineKeyboardMarkup{
+			InlineKeyboard: [][]tgbot.InlineKeyboardButton{{
+				{Text: "📖 Открыть книгу", CallbackData: fmt.Sprintf("select:%d", fulfilled.BookID)},
+			}},
+		},
+	}
+}
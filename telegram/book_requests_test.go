@@ -0,0 +1,35 @@
+package telegram
+
+import (
+	"context"
+	"testing"
+
+	"gopds-api/models"
+
+	tgbot "github.com/go-telegram/bot/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBookRequestFulfilledMessage_OpensTheBookCard(t *testing.T) {
+	params := bookRequestFulfilledMessage(99, models.FulfilledBookRequest{BookID: 42, BookTitle: "Пикник на обочине"})
+
+	assert.Equal(t, int64(99), params.ChatID)
+	assert.Contains(t, params.Text, "Пикник на обочине")
+	markup, ok := params.ReplyMarkup.(*tgbot.InlineKeyboardMarkup)
+	require.True(t, ok)
+	assert.Equal(t, "select:42", markup.InlineKeyboard[0][0].CallbackData, "the button is the search result's own")
+}
+
+func TestBookRequestFulfilled_PassesOverReadersWithoutABot(t *testing.T) {
+	bm := &BotManager{bots: map[string]*Bot{}}
+	fulfilled := models.FulfilledBookRequest{BookID: 42}
+
+	for name, user := range map[string]models.User{
+		"no bot":          {ID: 1, TelegramID: 5},
+		"never linked":    {ID: 2, BotToken: "token"},
+		"bot not running": {ID: 3, BotToken: "token", TelegramID: 5},
+	} {
+		assert.NoError(t, bm.BookRequestFulfilled(context.Background(), user, fulfilled), name)
+	}
+}
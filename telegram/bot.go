@@ -47,7 +47,7 @@ type BotManager struct {
 
 // The bot sees the reader's own words: a search phrase, a name, the running
 // conversation. None of it belongs in a log — what debugging needs is that the
-// path was taken and how much text it carried. These two functions are where
+// path was taken and how much text it carried. These functions are where
 // that decision lives, so it can be pinned by a test instead of restated at
 // every call site.
 
@@ -63,6 +63,17 @@ func logStatefulInput(telegramID int64, state, text string) {
 		telegramID, state, utf8.RuneCountInString(text))
 }
 
+// logCallback records a callback by its data, except that a suggestion
+// button's query is recorded by size.
+func logCallback(telegramID int64, data string) {
+	if query, ok := strings.CutPrefix(data, "dym:"); ok {
+		logging.Infof("Received callback from user %d, data: \"dym:\" with %d query runes",
+			telegramID, utf8.RuneCountInString(query))
+		return
+	}
+	logging.Infof("Received callback from user %d, data: %q", telegramID, data)
+}
+
 // Bot represents a bot linked to a system user
 type Bot struct {
 	token        string
@@ -91,6 +102,16 @@ func NewBotManager(config *Config, redisClient *redis.Client, search services.Pu
 	}
 }
 
+// WithRecommendations gives the bots' processors the recommendation service,
+// for the book card's "similar" button. Call it before any bot is created.
+func (bm *BotManager) WithRecommendations(recommend services.Recommendations) *BotManager {
+	newProcessor := bm.newProcessor
+	bm.newProcessor = func() *commands.CommandProcessor {
+		return newProcessor().WithRecommendations(recommend)
+	}
+	return bm
+}
+
 // InitializeExistingBots initializes bots for all users with tokens
 func (bm *BotManager) InitializeExistingBots() error {
 	users, err := database.GetUsersWithBotTokens()
@@ -449,6 +470,22 @@ func (b *Bot) setupHandlers(conversationManager *ConversationManager) {
 		b.processCommandResult(ctx, bot, conversationManager, result, telegramID, update.Message.Chat.ID)
 	}))
 
+	// /genres command
+	b.bot.RegisterHandler(tgbotapi.HandlerTypeMessageText, "genres", tgbotapi.MatchTypeCommand, b.withAuth(conversationManager, func(ctx context.Context, bot *tgbotapi.Bot, update *tgbot.Update, telegramID int64) {
+		if err := b.validateUserLinked(ctx, bot, conversationManager, telegramID, update.Message.Chat.ID); err != nil {
+			return
+		}
+
+		processor := b.newProcessor()
+		result, err := processor.ExecuteShowGenres(telegramID)
+		if err != nil {
+			b.handleCommandError(ctx, bot, conversationManager, telegramID, update.Message.Chat.ID, "show genres", err)
+			return
+		}
+
+		b.processCommandResult(ctx, bot, conversationManager, result, telegramID, update.Message.Chat.ID)
+	}))
+
 	// /donate command
 	b.bot.RegisterHandler(tgbotapi.HandlerTypeMessageText, "donate", tgbotapi.MatchTypeCommand, b.withAuth(conversationManager, func(ctx context.Context, bot *tgbotapi.Bot, update *tgbot.Update, telegramID int64) {
 		b.sendDonate(ctx, bot, conversationManager, telegramID, update.Message.Chat.ID)
@@ -1034,6 +1071,7 @@ func (b *Bot) registerCommands() error {
 		{Command: "ba", Description: "Exact combined search (author: book)"},
 		{Command: "favorites", Description: "Show your favorite books"},
 		{Command: "collections", Description: "Browse curated book collections"},
+		{Command: "genres", Description: "Browse books by genre"},
 		{Command: "context", Description: "Show conversation context statistics"},
 		{Command: "clear", Description: "Clear conversation context"},
 		{Command: "donate", Description: "Support the project"},
@@ -1097,6 +1135,15 @@ func (b *Bot) handleKeyboardCommand(ctx context.Context, bot *tgbotapi.Bot, conv
 		}
 		b.processCommandResult(ctx, bot, conversationManager, result, telegramID, chatID)
 
+	case "/genres":
+		processor := b.newProcessor()
+		result, err := processor.ExecuteShowGenres(telegramID)
+		if err != nil {
+			b.handleCommandError(ctx, bot, conversationManager, telegramID, chatID, "show genres", err)
+			return
+		}
+		b.processCommandResult(ctx, bot, conversationManager, result, telegramID, chatID)
+
 	case "/a":
 		if err := conversationManager.SetUserState(b.token, telegramID, "waiting_for_author"); err != nil {
 			logging.Errorf("Failed to set user state: %v", err)
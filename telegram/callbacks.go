@@ -16,6 +16,7 @@ import (
 	"gopds-api/internal/safeio"
 	"gopds-api/logging"
 	"gopds-api/models"
+	"gopds-api/services"
 	"gopds-api/utils"
 
 	tgbotapi "github.com/go-telegram/bot"
@@ -51,7 +52,7 @@ func (h *CallbackHandler) Handle(ctx context.Context, b *tgbotapi.Bot, update *t
 	telegramID := q.From.ID
 	callbackData := h.cleanCallbackData(q.Data)
 
-	logging.Infof("Received callback from user %d, data: %q", telegramID, callbackData)
+	logCallback(telegramID, callbackData)
 
 	if !h.bot.isAuthorizedUser(telegramID) {
 		logging.Warnf("Unauthorized callback attempt from user %d", telegramID)
@@ -65,10 +66,20 @@ func (h *CallbackHandler) Handle(ctx context.Context, b *tgbotapi.Bot, update *t
 	switch {
 	case callbackData == "prev_page" || callbackData == "next_page":
 		return h.handlePagination(ctx, b, update, callbackData)
+	case strings.HasPrefix(callbackData, "sort:"):
+		return h.handleSort(ctx, b, update, callbackData)
+	case strings.HasPrefix(callbackData, "dym:"):
+		return h.handleDidYouMean(ctx, b, update, callbackData)
 	case strings.HasPrefix(callbackData, "author:"):
 		return h.handleAuthorSelection(ctx, b, update, callbackData)
 	case strings.HasPrefix(callbackData, "collection:"):
 		return h.handleCollectionSelection(ctx, b, update, callbackData)
+	case callbackData == "genres" || strings.HasPrefix(callbackData, "gsection:") || strings.HasPrefix(callbackData, "genre:"):
+		return h.handleGenreNavigation(ctx, b, update, callbackData)
+	case strings.HasPrefix(callbackData, "series:"):
+		return h.handleSeriesSelection(ctx, b, update, callbackData)
+	case strings.HasPrefix(callbackData, "similar:"):
+		return h.handleSimilarBooks(ctx, b, update, callbackData)
 	case strings.HasPrefix(callbackData, "select:"):
 		return h.handleBookSelection(ctx, b, update, callbackData)
 	case strings.HasPrefix(callbackData, "download:"):
@@ -154,6 +165,70 @@ func (h *CallbackHandler) handlePagination(ctx context.Context, b *tgbotapi.Bot,
 	return h.editMessageWithResult(ctx, b, q, result, telegramID)
 }
 
+// handleSort handles sort:<order> callbacks: the current list again from
+// its first page, in the order chosen.
+func (h *CallbackHandler) handleSort(ctx context.Context, b *tgbotapi.Bot, update *tgbot.Update, callbackData string) error {
+	q := update.CallbackQuery
+	telegramID := q.From.ID
+	logging.Infof("Processing sort callback: %s for user %d", callbackData, telegramID)
+
+	sort, err := commands.ParseSortCallback(callbackData)
+	if err != nil {
+		logging.Errorf("Invalid sort in callback: %s", callbackData)
+		h.answerCallbackText(ctx, b, q, "Invalid sort")
+		return nil
+	}
+
+	convContext, err := h.conversationManager.GetContext(h.bot.token, telegramID)
+	if err != nil {
+		logging.Errorf("Failed to get context for sort: %v", err)
+		h.answerCallbackText(ctx, b, q, "Error getting context")
+		return nil
+	}
+	if convContext.SearchParams == nil {
+		logging.Warnf("No search params found in context for user %d", telegramID)
+		h.answerCallbackText(ctx, b, q, "No active search to sort")
+		return nil
+	}
+
+	params := *convContext.SearchParams
+	params.Sort = sort.String()
+	result, err := h.executeSearchWithPagination(ctx, &params, telegramID, 0)
+	if err != nil {
+		logging.Errorf("Failed to execute sorted search: %v", err)
+		h.answerCallbackText(ctx, b, q, "Search error")
+		return nil
+	}
+
+	h.updateSearchParamsInContext(telegramID, result.SearchParams)
+
+	return h.editMessageWithResult(ctx, b, q, result, telegramID)
+}
+
+// handleDidYouMean searches for the corrected query a reader picked under a
+// search that found nothing, from the first page, in place of that message.
+func (h *CallbackHandler) handleDidYouMean(ctx context.Context, b *tgbotapi.Bot, update *tgbot.Update, callbackData string) error {
+	q := update.CallbackQuery
+	telegramID := q.From.ID
+
+	query := commands.ParseDidYouMeanCallback(callbackData)
+	if query == "" {
+		h.answerCallbackText(ctx, b, q, "Invalid suggestion")
+		return nil
+	}
+
+	result, err := h.bot.newProcessor().ExecuteFindBookWithPagination(ctx, query, telegramID, 0, 5)
+	if err != nil {
+		logging.Errorf("Failed to execute suggested search: %v", err)
+		h.answerCallbackText(ctx, b, q, "Search error")
+		return nil
+	}
+
+	h.updateSearchParamsInContext(telegramID, result.SearchParams)
+
+	return h.editMessageWithResult(ctx, b, q, result, telegramID)
+}
+
 // calculateNewOffset calculates the new offset based on direction
 func (h *CallbackHandler) calculateNewOffset(params *commands.SearchParams, direction string) int {
 	newOffset := params.Offset
@@ -175,7 +250,9 @@ func (h *CallbackHandler) executeSearchWithPagination(
 	telegramID int64,
 	newOffset int,
 ) (*commands.CommandResult, error) {
-	processor := h.bot.newProcessor()
+	// The order was written by a sort button, so it always reads.
+	sort, _ := models.ParseBookSort(params.Sort)
+	processor := h.bot.newProcessor().WithSort(sort)
 
 	switch params.QueryType {
 	case "author":
@@ -191,6 +268,12 @@ func (h *CallbackHandler) executeSearchWithPagination(
 		return processor.ExecuteCollectionBooks(params.RefID, telegramID, newOffset, params.Limit)
 	case "collections":
 		return processor.ExecuteShowCollections(newOffset, params.Limit)
+	case "genre_books":
+		return processor.ExecuteGenreBooks(params.RefID, telegramID, newOffset, params.Limit)
+	case "series_books":
+		return processor.ExecuteSeriesBooks(params.RefID, telegramID, newOffset, params.Limit)
+	case "similar_books":
+		return processor.ExecuteSimilarBooks(ctx, params.RefID, telegramID, newOffset, params.Limit)
 	default:
 		return processor.ExecuteFindBookWithPagination(ctx, params.Query, telegramID, newOffset, params.Limit)
 	}
@@ -254,6 +337,123 @@ func (h *CallbackHandler) handleCollectionSelection(ctx context.Context, b *tgbo
 	return nil
 }
 
+// handleGenreNavigation handles the genres, gsection:ID and genre:ID
+// callbacks: back to the sections, into a section, into a genre's books.
+func (h *CallbackHandler) handleGenreNavigation(ctx context.Context, b *tgbotapi.Bot, update *tgbot.Update, callbackData string) error {
+	q := update.CallbackQuery
+	telegramID := q.From.ID
+	logging.Infof("Processing genre navigation callback: %s for user %d", callbackData, telegramID)
+
+	processor := h.bot.newProcessor()
+	var result *commands.CommandResult
+	var err error
+	switch {
+	case callbackData == "genres":
+		h.answerCallback(ctx, b, q)
+		result, err = processor.ExecuteShowGenres(telegramID)
+	default:
+		kind, idStr, _ := strings.Cut(callbackData, ":")
+		id, parseErr := strconv.ParseInt(idStr, 10, 64)
+		if parseErr != nil {
+			logging.Errorf("Invalid genre ID in callback: %s", callbackData)
+			h.answerCallbackText(ctx, b, q, "Invalid genre ID")
+			return nil
+		}
+		h.answerCallback(ctx, b, q)
+		if kind == "gsection" {
+			result, err = processor.ExecuteShowGenreSection(id, telegramID)
+		} else {
+			result, err = processor.ExecuteGenreBooks(id, telegramID, 0, 5)
+		}
+	}
+	if err != nil {
+		logging.Errorf("Failed to navigate genres for user %d: %v", telegramID, err)
+		h.editOrSend(ctx, b, q, "Error loading genres.", nil)
+		return nil
+	}
+
+	h.editOrSend(ctx, b, q, result.Message, result.ReplyMarkup)
+
+	if result.SearchParams != nil {
+		h.updateSearchParamsInContext(telegramID, result.SearchParams)
+	}
+	h.processOutgoingMessage(telegramID, result.Message)
+
+	return nil
+}
+
+// handleSeriesSelection handles series:ID callbacks: the books of a series a
+// book search offered.
+func (h *CallbackHandler) handleSeriesSelection(ctx context.Context, b *tgbotapi.Bot, update *tgbot.Update, callbackData string) error {
+	q := update.CallbackQuery
+	telegramID := q.From.ID
+	logging.Infof("Processing series selection callback: %s for user %d", callbackData, telegramID)
+
+	seriesID, err := commands.ParseSeriesCallback(callbackData)
+	if err != nil {
+		logging.Errorf("Invalid series ID in callback: %s", callbackData)
+		h.answerCallbackText(ctx, b, q, "Invalid series ID")
+		return nil
+	}
+
+	h.answerCallback(ctx, b, q)
+
+	result, err := h.bot.newProcessor().ExecuteSeriesBooks(seriesID, telegramID, 0, 5)
+	if err != nil {
+		logging.Errorf("Failed to get series books for user %d: %v", telegramID, err)
+		h.editOrSend(ctx, b, q, "Error loading series books.", nil)
+		return nil
+	}
+
+	h.editOrSend(ctx, b, q, result.Message, result.ReplyMarkup)
+
+	if result.SearchParams != nil {
+		h.updateSearchParamsInContext(telegramID, result.SearchParams)
+	}
+	h.processOutgoingMessage(telegramID, result.Message)
+
+	return nil
+}
+
+// handleSimilarBooks handles similar:ID callbacks from the book card. The
+// list comes as a message of its own, so the card and its download buttons
+// stay.
+func (h *CallbackHandler) handleSimilarBooks(ctx context.Context, b *tgbotapi.Bot, update *tgbot.Update, callbackData string) error {
+	q := update.CallbackQuery
+	telegramID := q.From.ID
+	logging.Infof("Processing similar books callback: %s for user %d", callbackData, telegramID)
+
+	bookID, err := commands.ParseSimilarCallback(callbackData)
+	if err != nil {
+		logging.Errorf("Invalid book ID in similar callback: %s", callbackData)
+		h.answerCallbackText(ctx, b, q, "Invalid book ID")
+		return nil
+	}
+
+	h.answerCallback(ctx, b, q)
+
+	chatID, _, hasMsg := callbackMessageInfo(q)
+	if !hasMsg {
+		chatID = q.From.ID
+	}
+
+	result, err := h.bot.newProcessor().ExecuteSimilarBooks(ctx, bookID, telegramID, 0, 5)
+	if err != nil {
+		logging.Errorf("Failed to get similar books for user %d: %v", telegramID, err)
+		h.sendMessage(ctx, b, chatID, "Error loading similar books.", nil)
+		return nil
+	}
+
+	h.sendMessage(ctx, b, chatID, result.Message, result.ReplyMarkup)
+
+	if result.SearchParams != nil {
+		h.updateSearchParamsInContext(telegramID, result.SearchParams)
+	}
+	h.processOutgoingMessage(telegramID, result.Message)
+
+	return nil
+}
+
 // handleAuthorSelection handles author:ID callbacks
 func (h *CallbackHandler) handleAuthorSelection(ctx context.Context, b *tgbotapi.Bot, update *tgbot.Update, callbackData string) error {
 	q := update.CallbackQuery
@@ -392,6 +592,9 @@ func (h *CallbackHandler) buildFormatSelectionKeyboard(bookID int64) *tgbot.Inli
 				{Text: "📱 MOBI", CallbackData: fmt.Sprintf("download:mobi:%d", bookID), Style: "success"},
 				{Text: "🗂 ZIP", CallbackData: fmt.Sprintf("download:zip:%d", bookID), Style: "success"},
 			},
+			{
+				{Text: "🔍 Похожие книги", CallbackData: commands.SimilarCallbackData(bookID)},
+			},
 		},
 	}
 }
@@ -444,6 +647,9 @@ func (h *CallbackHandler) sendBookFile(ctx context.Context, b *tgbotapi.Bot, cha
 	}
 
 	format = strings.ToLower(format)
+	if !services.BookFormatAvailable(&book, format) {
+		return fmt.Errorf("%w: %s", services.ErrBookFormatUnavailable, format)
+	}
 	basePath := viper.GetString("app.files_path")
 	if basePath == "" {
 		return fmt.Errorf("files path not configured")
@@ -486,6 +692,8 @@ func (h *CallbackHandler) sendBookFile(ctx context.Context, b *tgbotapi.Bot, cha
 		return err
 	}
 
+	services.RecordDownload(ctx, h.bot.userID, book.ID)
+
 	msg := fmt.Sprintf("Отправлена книга \"%s\" в формате %s", book.Title, strings.ToUpper(format))
 	h.processOutgoingMessage(chatID, msg)
 
@@ -505,7 +713,12 @@ func (h *CallbackHandler) getBookReader(bp *utils.BookProcessor, book models.Boo
 		rc, err = bp.FB2()
 		fileName = fmt.Sprintf("%s.fb2", book.DownloadName())
 	case "epub":
-		rc, err = bp.Epub()
+		// A book stored as EPUB is sent as it is.
+		if book.Format == "epub" {
+			rc, err = bp.Raw()
+		} else {
+			rc, err = bp.Epub()
+		}
 		fileName = fmt.Sprintf("%s.epub", book.DownloadName())
 	case "mobi":
 		rc, err = bp.Mobi()
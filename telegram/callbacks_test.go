@@ -151,13 +151,17 @@ func TestBuildFormatSelectionKeyboard(t *testing.T) {
 
 	assert.NotNil(t, markup)
 	assert.NotNil(t, markup.InlineKeyboard)
-	assert.Len(t, markup.InlineKeyboard, 2) // Two rows
+	assert.Len(t, markup.InlineKeyboard, 3) // Three rows
 
 	// First row should have FB2 and EPUB buttons
 	assert.Len(t, markup.InlineKeyboard[0], 2)
 
 	// Second row should have MOBI and ZIP buttons
 	assert.Len(t, markup.InlineKeyboard[1], 2)
+
+	// Third row lists the books like this one
+	require.Len(t, markup.InlineKeyboard[2], 1)
+	assert.Equal(t, "similar:42", markup.InlineKeyboard[2][0].CallbackData)
 }
 
 func TestUpdateSearchParamsInContext(t *testing.T) {
@@ -16,6 +16,7 @@ var (
 	btnAuthor      = KeyboardButton{Text: "👤 Автор", Command: "/a"}
 	btnBook        = KeyboardButton{Text: "📚 Книга", Command: "/b"}
 	btnCollections = KeyboardButton{Text: "📦 Подборки", Command: "/collections"}
+	btnGenres      = KeyboardButton{Text: "🏷 Жанры", Command: "/genres"}
 	btnDonate      = KeyboardButton{Text: "❤️ Поддержать", Command: "/donate"}
 )
 
@@ -25,7 +26,8 @@ func GetMainKeyboard() *tgbot.ReplyKeyboardMarkup {
 		Keyboard: [][]tgbot.KeyboardButton{
 			kbRow(btnSearch, btnFavorites),
 			kbRow(btnAuthor, btnBook),
-			kbRow(btnCollections, btnDonate),
+			kbRow(btnCollections, btnGenres),
+			kbRow(btnDonate),
 		},
 		ResizeKeyboard: true,
 		IsPersistent:   true,
@@ -49,6 +51,7 @@ func GetCommandFromButtonText(text string) (string, bool) {
 		btnAuthor,
 		btnBook,
 		btnCollections,
+		btnGenres,
 		btnDonate,
 	}
 
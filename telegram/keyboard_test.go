@@ -12,14 +12,15 @@ func TestGetMainKeyboard(t *testing.T) {
 
 	assert.NotNil(t, keyboard)
 	assert.True(t, keyboard.ResizeKeyboard, "Keyboard should be resizable")
-	assert.Len(t, keyboard.Keyboard, 3, "Keyboard should have 3 rows")
+	assert.Len(t, keyboard.Keyboard, 4, "Keyboard should have 4 rows")
 
 	assert.Equal(t, "🔍 Поиск", keyboard.Keyboard[0][0].Text)
 	assert.Equal(t, "⭐ Избранное", keyboard.Keyboard[0][1].Text)
 	assert.Equal(t, "👤 Автор", keyboard.Keyboard[1][0].Text)
 	assert.Equal(t, "📚 Книга", keyboard.Keyboard[1][1].Text)
 	assert.Equal(t, "📦 Подборки", keyboard.Keyboard[2][0].Text)
-	assert.Equal(t, "❤️ Поддержать", keyboard.Keyboard[2][1].Text)
+	assert.Equal(t, "🏷 Жанры", keyboard.Keyboard[2][1].Text)
+	assert.Equal(t, "❤️ Поддержать", keyboard.Keyboard[3][0].Text)
 }
 
 func TestGetCommandFromButtonText(t *testing.T) {
@@ -34,6 +35,7 @@ func TestGetCommandFromButtonText(t *testing.T) {
 		{"Author button", "👤 Автор", "/a", true},
 		{"Book button", "📚 Книга", "/b", true},
 		{"Collections button", "📦 Подборки", "/collections", true},
+		{"Genres button", "🏷 Жанры", "/genres", true},
 		{"Donate button", "❤️ Поддержать", "/donate", true},
 		{"Unknown button", "абракадабра", "", false},
 	}
@@ -72,3 +72,15 @@ func TestLogStatefulInputKeepsTheInputOut(t *testing.T) {
 	assert.Contains(t, out, "waiting_for_author", "the state is what makes the entry useful")
 	assert.Contains(t, out, "22 input runes")
 }
+
+func TestLogCallbackKeepsASuggestedQueryOut(t *testing.T) {
+	hook := captureLog(t)
+
+	logCallback(9, "dym:война и мир")
+	logCallback(9, "sort:title")
+
+	out := loggedText(hook)
+	assert.NotContains(t, out, "война", "a suggested query reached the log")
+	assert.Contains(t, out, "11 query runes")
+	assert.Contains(t, out, "sort:title", "other callbacks are logged as they were")
+}
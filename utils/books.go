@@ -68,7 +68,7 @@ func removeTmpFile(path string) {
 //
 // It used to carry a second mode that wrote the entry to a temporary file and
 // ran an external converter over it. Nothing reached it: the only caller is
-// FB2, which asks for no conversion, while Epub and Mobi go through
+// Raw, which asks for no conversion, while Epub and Mobi go through
 // extractFB2 and convert in-process. Had anything reached it, the command it
 // assembled began with the book's own filename — it would have tried to
 // execute the book.
@@ -217,10 +217,17 @@ func (bp *BookProcessor) Mobi() (io.ReadCloser, error) {
 	return mobiFile, nil
 }
 
-func (bp *BookProcessor) FB2() (io.ReadCloser, error) {
+// Raw returns the book's entry as the archive stores it, whatever its
+// format: the FB2 of a scanned book, the EPUB of an uploaded one.
+func (bp *BookProcessor) Raw() (io.ReadCloser, error) {
 	return bp.process()
 }
 
+// FB2 returns the FB2 of a book stored as one.
+func (bp *BookProcessor) FB2() (io.ReadCloser, error) {
+	return bp.Raw()
+}
+
 func (bp *BookProcessor) Zip(df string) (io.ReadCloser, error) {
 	buf := new(bytes.Buffer)
 	w := zip.NewWriter(buf)
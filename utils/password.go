@@ -30,7 +30,17 @@ type Token struct {
 	UserID      string
 	DatabaseID  int64
 	IsSuperUser bool
-	TokenType   string // "access" or "refresh"
+	// Permissions are the admin permissions the user's roles granted when
+	// the token was issued. Access tokens only: a refresh reads them afresh,
+	// so a change of roles reaches the user within one access token's life.
+	Permissions []string `json:",omitempty"`
+	// TwoFactor is set when the session was opened with a second factor.
+	// A refresh carries it over to the new pair.
+	TwoFactor bool `json:",omitempty"`
+	// SessionID names the login both tokens of a pair belong to, and every
+	// pair refreshed from it: the entry in the user's session index.
+	SessionID string `json:",omitempty"`
+	TokenType string // "access" or "refresh"
 	jwt.RegisteredClaims
 }
 
@@ -90,12 +100,17 @@ func CheckPbkdf2(password, encoded string, keyLen int, h func() hash.Hash) (bool
 
 // CreateTokenPair creates both access and refresh tokens for the user.
 // Access tokens are signed with sessions.key, refresh tokens with sessions.refresh.
-func CreateTokenPair(user models.User) (string, string, error) {
+// sessionID is the session the pair belongs to; twoFactor records whether
+// it was opened with a second factor.
+func CreateTokenPair(user models.User, sessionID string, twoFactor bool) (string, string, error) {
 	// Create access token (15 minutes)
 	accessToken := Token{
 		UserID:      user.Login,
 		DatabaseID:  user.ID,
 		IsSuperUser: user.IsSuperUser,
+		Permissions: user.Permissions,
+		TwoFactor:   twoFactor,
+		SessionID:   sessionID,
 		TokenType:   "access",
 		RegisteredClaims: jwt.RegisteredClaims{
 			Issuer:    "gopds-api",
@@ -109,6 +124,8 @@ func CreateTokenPair(user models.User) (string, string, error) {
 		UserID:      user.Login,
 		DatabaseID:  user.ID,
 		IsSuperUser: user.IsSuperUser,
+		TwoFactor:   twoFactor,
+		SessionID:   sessionID,
 		TokenType:   "refresh",
 		RegisteredClaims: jwt.RegisteredClaims{
 			Issuer:    "gopds-api",
@@ -133,45 +150,65 @@ func CreateTokenPair(user models.User) (string, string, error) {
 // CheckAccessToken validates an access token: verifies the signature with
 // sessions.key and rejects tokens that are not of type "access".
 func CheckAccessToken(token string) (string, int64, bool, error) {
+	claims, err := ParseAccessToken(token)
+	if err != nil {
+		return "", 0, false, err
+	}
+	return claims.UserID, claims.DatabaseID, claims.IsSuperUser, nil
+}
+
+// ParseAccessToken validates an access token like CheckAccessToken and
+// returns all of its claims, permissions included.
+func ParseAccessToken(token string) (*Token, error) {
 	tokenCheck, err := jwt.ParseWithClaims(token, &Token{}, func(token *jwt.Token) (interface{}, error) {
 		return []byte(viper.GetString("sessions.key")), nil
 	})
 
 	if tokenCheck == nil {
-		return "", 0, false, err
+		return nil, err
 	}
 
 	claims, ok := tokenCheck.Claims.(*Token)
 	if !ok || !tokenCheck.Valid {
-		return "", 0, false, errors.New("invalid_token")
+		return nil, errors.New("invalid_token")
 	}
 
 	if claims.TokenType != "access" {
-		return "", 0, false, errors.New("invalid_token_type")
+		return nil, errors.New("invalid_token_type")
 	}
 
-	return claims.UserID, claims.DatabaseID, claims.IsSuperUser, nil
+	return claims, nil
 }
 
 // CheckRefreshToken validates a refresh token: verifies the signature with
 // sessions.refresh and rejects tokens that are not of type "refresh".
 func CheckRefreshToken(token string) (string, int64, bool, error) {
+	claims, err := ParseRefreshToken(token)
+	if err != nil {
+		return "", 0, false, err
+	}
+	return claims.UserID, claims.DatabaseID, claims.IsSuperUser, nil
+}
+
+// ParseRefreshToken validates a refresh token like CheckRefreshToken and
+// returns all of its claims.
+func ParseRefreshToken(token string) (*Token, error) {
 	tokenCheck, err := jwt.ParseWithClaims(token, &Token{}, func(token *jwt.Token) (interface{}, error) {
 		return []byte(viper.GetString("sessions.refresh")), nil
 	})
 
 	if tokenCheck == nil {
-		return "", 0, false, err
+		return nil, err
 	}
 
 	claims, ok := tokenCheck.Claims.(*Token)
 	if !ok || !tokenCheck.Valid {
-		return "", 0, false, errors.New("invalid_token")
+		return nil, errors.New("invalid_token")
 	}
 
 	if claims.TokenType != "refresh" {
-		return "", 0, false, errors.New("invalid_token_type")
+		return nil, errors.New("invalid_token_type")
 	}
 
-	return claims.UserID, claims.DatabaseID, claims.IsSuperUser, nil
+	return claims, nil
 }